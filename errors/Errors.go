@@ -11,4 +11,10 @@ var (
 
 	// ErrStringDataTooLong base error when required data value is too long for db column to insert
 	ErrStringDataTooLong = fmt.Errorf("string data too long")
+
+	// ErrDBTransactionMissing base error if the operation requires a database transaction in context but theres none
+	ErrDBTransactionMissing = fmt.Errorf("database transaction not in context")
+
+	// ErrDuplicateKey base error when the data to insert have the same unique key as data already in the db table
+	ErrDuplicateKey = fmt.Errorf("duplicate key")
 )
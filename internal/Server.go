@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
@@ -17,8 +18,10 @@ import (
 	"github.com/gorilla/mux"
 	"github.com/hyperjumptech/bookkeeping/internal/config"
 	"github.com/hyperjumptech/bookkeeping/internal/connector"
+	"github.com/hyperjumptech/bookkeeping/internal/contextkeys"
 	"github.com/hyperjumptech/bookkeeping/internal/health"
 	"github.com/hyperjumptech/bookkeeping/internal/logger"
+	"github.com/hyperjumptech/bookkeeping/internal/middlewares"
 	"github.com/hyperjumptech/bookkeeping/internal/router"
 	log "github.com/sirupsen/logrus"
 )
@@ -76,13 +79,66 @@ func InitializeServer() error {
 	accounting.AccountMgr = accounting.NewMySQLAccountManager(dbRepo)
 	accounting.JournalMgr = accounting.NewMySQLJournalManager(dbRepo)
 	accounting.TransactionMgr = accounting.NewMySQLTransactionManager(dbRepo)
+	accounting.MetadataMgr = accounting.NewMySQLMetadataManager(dbRepo, accounting.JournalMgr)
+	accounting.ReferenceMgr = accounting.NewMySQLReferenceManager(dbRepo, accounting.JournalMgr)
+	accounting.SearchMgr = accounting.NewMySQLSearchManager(dbRepo, accounting.JournalMgr)
+	accounting.CursorMgr = accounting.NewMySQLCursorManager(dbRepo, accounting.JournalMgr)
+	accounting.StatementMgr = accounting.NewMySQLStatementManager(dbRepo, config.GetInt("statement.lines.max"))
 	accounting.ExchangeMgr = accounting.NewMySQLExchangeManager(dbRepo)
+	accounting.AccountStateMgr = accounting.NewMySQLAccountStateManager(dbRepo)
+	accounting.AccountLimitMgr = accounting.NewMySQLAccountLimitManager(dbRepo)
+	accounting.PostingTemplateMgr = accounting.NewMySQLPostingTemplateManager(dbRepo)
+	accounting.JournalBatchMgr = accounting.NewMySQLJournalBatchManager(dbRepo)
+	accounting.JournalSimulationMgr = accounting.NewMySQLJournalSimulationManager(dbRepo)
+	accounting.LedgerFeedMgr = accounting.NewMySQLLedgerFeedManager(dbRepo)
+	accounting.AuditMgr = accounting.NewMySQLAuditManager(dbRepo)
+	middlewares.AuditLog = accounting.AuditMgr
+	accounting.IdempotencyMgr = accounting.NewMySQLIdempotencyManager(dbRepo)
 	accounting.UniqueIDGenerator = &acccore.RandomGenUniqueIDGenerator{
 		Length:     16,
 		LowerAlpha: false,
 		UpperAlpha: true,
 		Numeric:    true,
 	}
+	for _, scope := range middlewares.HMACScopes {
+		if !middlewares.KnownScope(scope) {
+			logf.Fatal("unknown scope in hmac.scopes ", scope)
+			panic("Authentication setup failed. please check log.")
+		}
+	}
+	if config.IsProduction() && len(config.Get("auth.session.secret")) == 0 {
+		logf.Fatal("auth.session.secret must be set in production")
+		panic("Authentication setup failed. please check log.")
+	}
+	if config.IsProduction() && len(config.Get("auth.apikey.seal.secret")) == 0 {
+		logf.Fatal("auth.apikey.seal.secret must be set in production")
+		panic("Authentication setup failed. please check log.")
+	}
+	accounting.APIKeyMgr = accounting.NewMySQLAPIKeyManager(dbRepo, accounting.UniqueIDGenerator, config.Get("auth.apikey.seal.secret"))
+	accounting.TenantMgr = accounting.NewMySQLTenantManager(dbRepo)
+	middlewares.APIKeys = accounting.APIKeyMgr
+	middlewares.Authenticators, err = middlewares.NewAuthenticatorChain(strings.Split(config.Get("auth.authenticators"), ","))
+	if err != nil {
+		logf.Fatal("could not setup authentication. Error: ", err)
+		panic("Authentication setup failed. please check log.")
+	}
+	accounting.RateLimitMgr = accounting.NewMySQLRateLimitManager(dbRepo)
+	if middlewares.Limiter != nil {
+		switch config.Get("ratelimit.store") {
+		case "memory":
+		case "db":
+			middlewares.Limiter = accounting.RateLimitMgr
+		default:
+			logf.Fatal("unknown rate limit store ", config.Get("ratelimit.store"))
+			panic("Rate limit setup failed. please check log.")
+		}
+	}
+	accounting.HoldMgr = accounting.NewMySQLHoldManager(dbRepo, accounting.UniqueIDGenerator)
+	accounting.ApprovalMgr = accounting.NewMySQLApprovalManager(dbRepo, accounting.UniqueIDGenerator)
+	accounting.ReversalMgr = accounting.NewMySQLReversalManager(dbRepo, accounting.JournalMgr, accounting.UniqueIDGenerator)
+	accounting.WebhookMgr = accounting.NewMySQLWebhookManager(dbRepo, accounting.UniqueIDGenerator,
+		&http.Client{Timeout: time.Duration(config.GetInt("webhook.timeout.second")) * time.Second},
+		config.GetInt("webhook.max.attempts"), time.Duration(config.GetInt("webhook.backoff.base.second"))*time.Second)
 
 	// setup health monitoring
 	err = health.InitializeHealthCheck(ctx, dbRepo.(*connector.MySQLDBRepository))
@@ -106,6 +162,17 @@ func InitializeServer() error {
 	cr = cron.New()
 	fmt.Println("schedule is: ", config.Get("cron.backup.daily"))
 	cr.AddFunc(config.Get("cron.backup.daily"), func() { cronBackupUpload(context.Background()) })
+	cr.AddFunc(config.Get("cron.holds.expire"), func() { cronExpireHolds(context.Background()) })
+	cr.AddFunc(config.Get("cron.webhooks.deliver"), func() { cronDeliverWebhooks(context.Background()) })
+	if middlewares.Limiter == accounting.RateLimitMgr {
+		cr.AddFunc(config.Get("cron.ratelimits.purge"), func() { cronPurgeRateLimits(context.Background()) })
+	}
+	accounting.RecurringJournalMgr = accounting.NewMySQLRecurringJournalManager(dbRepo, accounting.UniqueIDGenerator, cr)
+	err = accounting.RecurringJournalMgr.ScheduleAll(context.WithValue(ctx, contextkeys.XRequestID, "schedule-recurring-journals"))
+	if err != nil {
+		logf.Warn("could not schedule recurring journals. Error: ", err)
+	}
+	cr.AddFunc(config.Get("cron.recurring.reload"), func() { cronReloadRecurringJournals(context.Background()) })
 	cr.Start()
 
 	// indicate if dev or production mode
@@ -113,6 +180,9 @@ func InitializeServer() error {
 
 	if env == "production" {
 		logf.Info("environment is: ", env)
+		if middlewares.LegacyHMACEnabled {
+			logf.Warn("hmac.legacy.enabled is ignored in production")
+		}
 	} else {
 		logf.Warn("environment is: ", env)
 	}
@@ -161,6 +231,74 @@ func cronBackupUpload(ctx context.Context) error {
 	return nil
 }
 
+// cronReloadRecurringJournals() runs periodically to schedule the recurring journals created or resumed on the other
+// instances, and unschedule the paused ones
+func cronReloadRecurringJournals(ctx context.Context) error {
+	logf := srvLog.WithField("fn", "cronReloadRecurringJournals")
+
+	err := accounting.RecurringJournalMgr.ScheduleAll(context.WithValue(ctx, contextkeys.XRequestID, "cron-reload-recurring-journals"))
+	if err != nil {
+		logf.Error("failed to reload recurring journals, got: ", err)
+	}
+	return err
+}
+
+// cronExpireHolds() runs periodically to release the expired holds
+func cronExpireHolds(ctx context.Context) error {
+	logf := srvLog.WithField("fn", "cronExpireHolds")
+
+	ctx = context.WithValue(ctx, contextkeys.XRequestID, "cron-expire-holds")
+	ctx = context.WithValue(ctx, contextkeys.UserIDContextKey, "SYSTEM")
+	count, err := accounting.HoldMgr.ExpireHolds(ctx)
+	if err != nil {
+		logf.Error("failed to expire holds, got: ", err)
+		return err
+	}
+	if count > 0 {
+		logf.Info("expired holds: ", count)
+	}
+	return nil
+}
+
+// cronDeliverWebhooks() runs periodically to dispatch the outbox events and attempt the due webhook deliveries
+func cronDeliverWebhooks(ctx context.Context) error {
+	logf := srvLog.WithField("fn", "cronDeliverWebhooks")
+
+	ctx = context.WithValue(ctx, contextkeys.XRequestID, "cron-deliver-webhooks")
+	ctx = context.WithValue(ctx, contextkeys.UserIDContextKey, "SYSTEM")
+	dispatched, err := accounting.WebhookMgr.DispatchEvents(ctx)
+	if err != nil {
+		logf.Error("failed to dispatch events, got: ", err)
+		return err
+	}
+	delivered, err := accounting.WebhookMgr.DeliverDue(ctx)
+	if err != nil {
+		logf.Error("failed to deliver webhooks, got: ", err)
+		return err
+	}
+	if dispatched > 0 || delivered > 0 {
+		logf.Infof("dispatched events: %d, delivered webhooks: %d", dispatched, delivered)
+	}
+	return nil
+}
+
+// cronPurgeRateLimits() runs periodically to remove the request counts of the ended rate limit windows
+func cronPurgeRateLimits(ctx context.Context) error {
+	logf := srvLog.WithField("fn", "cronPurgeRateLimits")
+
+	ctx = context.WithValue(ctx, contextkeys.XRequestID, "cron-purge-rate-limits")
+	ctx = context.WithValue(ctx, contextkeys.UserIDContextKey, "SYSTEM")
+	count, err := accounting.RateLimitMgr.PurgeRateLimits(ctx)
+	if err != nil {
+		logf.Error("failed to purge rate limits, got: ", err)
+		return err
+	}
+	if count > 0 {
+		logf.Info("purged rate limits: ", count)
+	}
+	return nil
+}
+
 // StartServer starts listening at given port
 func StartServer() {
 
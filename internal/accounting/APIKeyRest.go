@@ -0,0 +1,273 @@
+package accounting
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/hyperjumptech/bookkeeping/internal/connector"
+	"github.com/hyperjumptech/bookkeeping/internal/contextkeys"
+	"github.com/hyperjumptech/bookkeeping/internal/helpers"
+	"github.com/hyperjumptech/bookkeeping/internal/middlewares"
+)
+
+// IssueAPIKeyRequest is the payload to issue an api key to a client
+type IssueAPIKeyRequest struct {
+	ClientID string `json:"client_id"`
+	// TenantID is the tenant the client works on, the tenant of the caller if it is not specified.
+	// Only the callers with the tenant:admin scope can issue keys for another tenant.
+	TenantID string   `json:"tenant_id"`
+	Scopes   []string `json:"scopes"`
+	// ExpiresAt is the time the key stop being accepted, the key never expires if it is not specified
+	ExpiresAt   *time.Time `json:"expires_at"`
+	Description string     `json:"description"`
+	Creator     string     `json:"creator"`
+}
+
+// RotateAPIKeyRequest is the payload to rotate an api key
+type RotateAPIKeyRequest struct {
+	// ExpiresAt is the time the new key stop being accepted, the key never expires if it is not specified
+	ExpiresAt *time.Time `json:"expires_at"`
+	Creator   string     `json:"creator"`
+}
+
+// RevokeAPIKeyRequest is the payload to revoke an api key
+type RevokeAPIKeyRequest struct {
+	Creator string `json:"creator"`
+}
+
+// apiKeyErrorResponse writes the response for errors returned by APIKeyMgr
+func apiKeyErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, ErrAPIKeyNotFound):
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 404, "api key not found", err.Error(), 0)
+	case errors.Is(err, ErrTenantNotFound):
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 404, "tenant not found", err.Error(), 3)
+	case errors.Is(err, ErrAPIKeyRevoked):
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 409, "api key is revoked", err.Error(), 0)
+	case errors.Is(err, ErrInvalidAPIKey):
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 400, "request rejected", err.Error(), 0)
+	default:
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 500, "backend error", err.Error(), 2)
+	}
+}
+
+// auditIssuedAPIKey keeps the issued key out of the audit log, only its key id is recorded.
+func auditIssuedAPIKey(r *http.Request, key *APIKey) {
+	recorded := *key
+	recorded.APIKey = ""
+	middlewares.AuditSnapshot(r.Context(), nil, &recorded)
+}
+
+// IssueAPIKey issues a new api key to a client. The response carries the key, its the only time the key is revealed.
+func IssueAPIKey(w http.ResponseWriter, r *http.Request) {
+	requestID := r.Context().Value(contextkeys.XRequestID).(string)
+	llog := restLog.WithField("RequestID", requestID).WithField("function", "IssueAPIKey")
+	if r.Context().Err() != nil {
+		llog.Errorf("context is canceled : %s", r.Context().Err().Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 500, "request is canceled", "request is canceled", 0)
+		return
+	}
+	if APIKeyMgr == nil {
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 501, "not implemented", "api key manager is not available", 0)
+		return
+	}
+
+	bodyByte, err := io.ReadAll(r.Body)
+	if err != nil {
+		llog.Errorf("got %s", err.Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 500, "error reading body", err.Error(), 0)
+		return
+	}
+	keyReq := &IssueAPIKeyRequest{}
+	err = json.Unmarshal(bodyByte, keyReq)
+	if err != nil {
+		llog.Errorf("got %s", err.Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 400, "malformed json body", err.Error(), 0)
+		return
+	}
+
+	// the tenant administrators are the only ones reaching beyond their own tenant
+	if !middlewares.HasScope(r.Context(), middlewares.ScopeTenantAdmin) {
+		if len(keyReq.TenantID) > 0 && keyReq.TenantID != connector.TenantFromContext(r.Context()) {
+			helpers.HTTPResponseBuilder(r.Context(), w, r, 403, "forbidden", "issuing api key for another tenant requires scope "+middlewares.ScopeTenantAdmin, middlewares.ErrorCodeInsufficientScope)
+			return
+		}
+		for _, scope := range keyReq.Scopes {
+			if scope == middlewares.ScopeTenantAdmin {
+				helpers.HTTPResponseBuilder(r.Context(), w, r, 403, "forbidden", "granting scope "+middlewares.ScopeTenantAdmin+" requires scope "+middlewares.ScopeTenantAdmin, middlewares.ErrorCodeInsufficientScope)
+				return
+			}
+		}
+	}
+
+	key, err := APIKeyMgr.IssueKey(r.Context(), &APIKey{
+		ClientID:    keyReq.ClientID,
+		TenantID:    keyReq.TenantID,
+		Scopes:      keyReq.Scopes,
+		ExpiresAt:   keyReq.ExpiresAt,
+		Description: keyReq.Description,
+	}, requestCreator(r, keyReq.Creator))
+	if err != nil {
+		llog.Errorf("error while calling APIKeyMgr.IssueKey. got : %s", err.Error())
+		apiKeyErrorResponse(w, r, err)
+		return
+	}
+	auditIssuedAPIKey(r, key)
+	helpers.HTTPResponseBuilder(r.Context(), w, r, 200, "api key "+key.KeyID, key, 0)
+}
+
+// ListAPIKeys lists the api keys, latest first, optionally of the client specified in the client_id query parameter.
+func ListAPIKeys(w http.ResponseWriter, r *http.Request) {
+	requestID := r.Context().Value(contextkeys.XRequestID).(string)
+	llog := restLog.WithField("RequestID", requestID).WithField("function", "ListAPIKeys")
+	if r.Context().Err() != nil {
+		llog.Errorf("context is canceled : %s", r.Context().Err().Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 500, "request is canceled", "request is canceled", 0)
+		return
+	}
+	if APIKeyMgr == nil {
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 501, "not implemented", "api key manager is not available", 0)
+		return
+	}
+
+	keys, err := APIKeyMgr.ListKeys(r.Context(), r.URL.Query().Get("client_id"))
+	if err != nil {
+		llog.Errorf("error while calling APIKeyMgr.ListKeys. got : %s", err.Error())
+		apiKeyErrorResponse(w, r, err)
+		return
+	}
+	helpers.HTTPResponseBuilder(r.Context(), w, r, 200, "OK", keys, 0)
+}
+
+// GetAPIKey returns an api key, without the key itself
+func GetAPIKey(w http.ResponseWriter, r *http.Request) {
+	requestID := r.Context().Value(contextkeys.XRequestID).(string)
+	llog := restLog.WithField("RequestID", requestID).WithField("function", "GetAPIKey")
+	if r.Context().Err() != nil {
+		llog.Errorf("context is canceled : %s", r.Context().Err().Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 500, "request is canceled", "request is canceled", 0)
+		return
+	}
+	if APIKeyMgr == nil {
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 501, "not implemented", "api key manager is not available", 0)
+		return
+	}
+
+	m, err := helpers.ParsePathParams("/api/v1/admin/api-keys/{KeyID}", r.URL.Path)
+	if err != nil {
+		llog.Errorf("error while processing path template /api/v1/admin/api-keys/{KeyID}. got : %s", err.Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 404, "path not found", "path not found", 1)
+		return
+	}
+	key, err := APIKeyMgr.GetKey(r.Context(), m["KeyID"])
+	if err != nil {
+		llog.Errorf("error while calling APIKeyMgr.GetKey. got : %s", err.Error())
+		apiKeyErrorResponse(w, r, err)
+		return
+	}
+	helpers.HTTPResponseBuilder(r.Context(), w, r, 200, "api key "+key.KeyID, key, 0)
+}
+
+// RotateAPIKey issues a new api key in place of an api key, and revokes the old one.
+// The response carries the new key, its the only time the key is revealed.
+func RotateAPIKey(w http.ResponseWriter, r *http.Request) {
+	requestID := r.Context().Value(contextkeys.XRequestID).(string)
+	llog := restLog.WithField("RequestID", requestID).WithField("function", "RotateAPIKey")
+	if r.Context().Err() != nil {
+		llog.Errorf("context is canceled : %s", r.Context().Err().Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 500, "request is canceled", "request is canceled", 0)
+		return
+	}
+	if APIKeyMgr == nil {
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 501, "not implemented", "api key manager is not available", 0)
+		return
+	}
+
+	m, err := helpers.ParsePathParams("/api/v1/admin/api-keys/{KeyID}/rotate", r.URL.Path)
+	if err != nil {
+		llog.Errorf("error while processing path template /api/v1/admin/api-keys/{KeyID}/rotate. got : %s", err.Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 404, "path not found", "path not found", 1)
+		return
+	}
+	bodyByte, err := io.ReadAll(r.Body)
+	if err != nil {
+		llog.Errorf("got %s", err.Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 500, "error reading body", err.Error(), 0)
+		return
+	}
+	rotateReq := &RotateAPIKeyRequest{}
+	if len(bodyByte) > 0 {
+		err = json.Unmarshal(bodyByte, rotateReq)
+		if err != nil {
+			llog.Errorf("got %s", err.Error())
+			helpers.HTTPResponseBuilder(r.Context(), w, r, 400, "malformed json body", err.Error(), 0)
+			return
+		}
+	}
+
+	key, err := APIKeyMgr.RotateKey(r.Context(), m["KeyID"], rotateReq.ExpiresAt, requestCreator(r, rotateReq.Creator))
+	if err != nil {
+		llog.Errorf("error while calling APIKeyMgr.RotateKey. got : %s", err.Error())
+		apiKeyErrorResponse(w, r, err)
+		return
+	}
+	auditIssuedAPIKey(r, key)
+	helpers.HTTPResponseBuilder(r.Context(), w, r, 200, "api key "+key.KeyID, key, 0)
+}
+
+// RevokeAPIKey revokes an api key, it is refused from then on.
+func RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	requestID := r.Context().Value(contextkeys.XRequestID).(string)
+	llog := restLog.WithField("RequestID", requestID).WithField("function", "RevokeAPIKey")
+	if r.Context().Err() != nil {
+		llog.Errorf("context is canceled : %s", r.Context().Err().Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 500, "request is canceled", "request is canceled", 0)
+		return
+	}
+	if APIKeyMgr == nil {
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 501, "not implemented", "api key manager is not available", 0)
+		return
+	}
+
+	m, err := helpers.ParsePathParams("/api/v1/admin/api-keys/{KeyID}/revoke", r.URL.Path)
+	if err != nil {
+		llog.Errorf("error while processing path template /api/v1/admin/api-keys/{KeyID}/revoke. got : %s", err.Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 404, "path not found", "path not found", 1)
+		return
+	}
+	bodyByte, err := io.ReadAll(r.Body)
+	if err != nil {
+		llog.Errorf("got %s", err.Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 500, "error reading body", err.Error(), 0)
+		return
+	}
+	revokeReq := &RevokeAPIKeyRequest{}
+	if len(bodyByte) > 0 {
+		err = json.Unmarshal(bodyByte, revokeReq)
+		if err != nil {
+			llog.Errorf("got %s", err.Error())
+			helpers.HTTPResponseBuilder(r.Context(), w, r, 400, "malformed json body", err.Error(), 0)
+			return
+		}
+	}
+
+	if before, err := APIKeyMgr.GetKey(r.Context(), m["KeyID"]); err == nil {
+		middlewares.AuditSnapshot(r.Context(), before, nil)
+	}
+	err = APIKeyMgr.RevokeKey(r.Context(), m["KeyID"], requestCreator(r, revokeReq.Creator))
+	if err != nil {
+		llog.Errorf("error while calling APIKeyMgr.RevokeKey. got : %s", err.Error())
+		apiKeyErrorResponse(w, r, err)
+		return
+	}
+	key, err := APIKeyMgr.GetKey(r.Context(), m["KeyID"])
+	if err != nil {
+		llog.Errorf("error while calling APIKeyMgr.GetKey. got : %s", err.Error())
+		apiKeyErrorResponse(w, r, err)
+		return
+	}
+	helpers.HTTPResponseBuilder(r.Context(), w, r, 200, "api key "+key.KeyID+" revoked", key, 0)
+}
@@ -0,0 +1,64 @@
+package accounting
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/olekukonko/tablewriter"
+)
+
+// statementAmount formats a debit or credit of the statement, blank when it is 0
+func statementAmount(amount int64) string {
+	if amount == 0 {
+		return ""
+	}
+	return strconv.FormatInt(amount, 10)
+}
+
+// writeStatementCSV writes the statement as CSV, a row per transaction between the opening balance row
+// and the closing balance row, the latter carries the total debit and credit.
+func writeStatementCSV(w io.Writer, statement *AccountStatement) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"transaction_id", "transaction_time", "journal_id", "description", "debit", "credit", "balance"})
+	cw.Write([]string{"", statement.From, "", "Opening balance", "", "", strconv.FormatInt(statement.OpeningBalance, 10)})
+	for _, line := range statement.Lines {
+		cw.Write([]string{line.TransactionID, line.TransactionTime, line.JournalID, line.Description,
+			statementAmount(line.Debit), statementAmount(line.Credit), strconv.FormatInt(line.Balance, 10)})
+	}
+	cw.Write([]string{"", statement.Until, "", "Closing balance",
+		strconv.FormatInt(statement.TotalDebit, 10), strconv.FormatInt(statement.TotalCredit, 10), strconv.FormatInt(statement.ClosingBalance, 10)})
+	cw.Flush()
+	return cw.Error()
+}
+
+// drawStatement renders the statement as printable text, the account details followed by the table of transactions.
+func drawStatement(statement *AccountStatement) string {
+	var buff bytes.Buffer
+	buff.WriteString(fmt.Sprintf("Account Number    : %s\n", statement.AccountNumber))
+	buff.WriteString(fmt.Sprintf("Account Name      : %s\n", statement.Name))
+	buff.WriteString(fmt.Sprintf("Currency          : %s\n", statement.Currency))
+	buff.WriteString(fmt.Sprintf("COA               : %s\n", statement.COA))
+	buff.WriteString(fmt.Sprintf("Alignment         : %s\n", statement.Alignment))
+	buff.WriteString(fmt.Sprintf("Statement From    : %s\n", statement.From))
+	buff.WriteString(fmt.Sprintf("          To      : %s\n", statement.Until))
+	buff.WriteString(fmt.Sprintf("Opening Balance   : %d\n", statement.OpeningBalance))
+	buff.WriteString(fmt.Sprintf("Total Debit       : %d\n", statement.TotalDebit))
+	buff.WriteString(fmt.Sprintf("Total Credit      : %d\n", statement.TotalCredit))
+	buff.WriteString(fmt.Sprintf("Closing Balance   : %d\n", statement.ClosingBalance))
+	buff.WriteString(fmt.Sprintf("#Transactions     : %d\n", len(statement.Lines)))
+
+	table := tablewriter.NewWriter(&buff)
+	table.SetHeader([]string{"TRX ID", "TIME", "JOURNAL ID", "Description", "DEBIT", "CREDIT", "BALANCE"})
+	table.Append([]string{"", statement.From, "", "Opening balance", "", "", strconv.FormatInt(statement.OpeningBalance, 10)})
+	for _, line := range statement.Lines {
+		table.Append([]string{line.TransactionID, line.TransactionTime, line.JournalID, line.Description,
+			statementAmount(line.Debit), statementAmount(line.Credit), strconv.FormatInt(line.Balance, 10)})
+	}
+	table.SetFooter([]string{"", statement.Until, "", "Closing balance",
+		strconv.FormatInt(statement.TotalDebit, 10), strconv.FormatInt(statement.TotalCredit, 10), strconv.FormatInt(statement.ClosingBalance, 10)})
+	table.Render()
+	return buff.String()
+}
@@ -0,0 +1,85 @@
+package accounting
+
+import (
+	"bytes"
+	"encoding/csv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hyperjumptech/acccore"
+)
+
+func testStatement() *AccountStatement {
+	account := &acccore.BaseAccount{}
+	account.SetAccountNumber("CASH").SetName("Gold Cash").SetCOA("1.1").SetCurrency("GOLD").SetAlignment(acccore.DEBIT)
+	trx := func(id string, alignment acccore.Alignment, amount int64) acccore.Transaction {
+		ret := &acccore.BaseTransaction{}
+		ret.SetTransactionID(id).SetTransactionTime(time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)).SetJournalID("J-" + id).
+			SetDescription("trx " + id).SetAlignment(alignment).SetAmount(amount)
+		return ret
+	}
+	return newAccountStatement(account, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), 1000,
+		[]acccore.Transaction{trx("T1", acccore.DEBIT, 300), trx("T2", acccore.CREDIT, 500), trx("T3", acccore.DEBIT, 50)})
+}
+
+func TestNewAccountStatement(t *testing.T) {
+	statement := testStatement()
+	if statement.Alignment != "DEBIT" || statement.OpeningBalance != 1000 {
+		t.Errorf("unexpected statement header %+v", statement)
+	}
+	balances := []int64{1300, 800, 850}
+	for i, line := range statement.Lines {
+		if line.Balance != balances[i] {
+			t.Errorf("line %d : expecting running balance %d, got %d", i, balances[i], line.Balance)
+		}
+	}
+	if statement.Lines[1].Debit != 0 || statement.Lines[1].Credit != 500 {
+		t.Errorf("expecting the credit line on the credit column, got %+v", statement.Lines[1])
+	}
+	if statement.TotalDebit != 350 || statement.TotalCredit != 500 || statement.ClosingBalance != 850 {
+		t.Errorf("expecting debit 350, credit 500 and closing 850, got %d, %d and %d", statement.TotalDebit, statement.TotalCredit, statement.ClosingBalance)
+	}
+
+	// a credit account grows with its credits
+	account := &acccore.BaseAccount{}
+	account.SetAccountNumber("EQUITY").SetAlignment(acccore.CREDIT)
+	credit := &acccore.BaseTransaction{}
+	credit.SetAlignment(acccore.CREDIT).SetAmount(100)
+	statement = newAccountStatement(account, time.Now(), time.Now(), 0, []acccore.Transaction{credit})
+	if statement.Alignment != "CREDIT" || statement.ClosingBalance != 100 {
+		t.Errorf("expecting the credit account closing at 100, got %d", statement.ClosingBalance)
+	}
+}
+
+func TestWriteStatementCSV(t *testing.T) {
+	var buff bytes.Buffer
+	if err := writeStatementCSV(&buff, testStatement()); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := csv.NewReader(&buff).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 6 {
+		t.Fatalf("expecting header, opening, 3 lines and closing rows, got %d", len(rows))
+	}
+	if rows[1][3] != "Opening balance" || rows[1][6] != "1000" {
+		t.Errorf("unexpected opening row %v", rows[1])
+	}
+	if rows[3][0] != "T2" || rows[3][4] != "" || rows[3][5] != "500" || rows[3][6] != "800" {
+		t.Errorf("unexpected line row %v", rows[3])
+	}
+	if rows[5][3] != "Closing balance" || rows[5][4] != "350" || rows[5][5] != "500" || rows[5][6] != "850" {
+		t.Errorf("unexpected closing row %v", rows[5])
+	}
+}
+
+func TestDrawStatement(t *testing.T) {
+	drawing := drawStatement(testStatement())
+	for _, expected := range []string{"Opening Balance   : 1000", "Closing Balance   : 850", "Total Debit       : 350", "T3"} {
+		if !strings.Contains(drawing, expected) {
+			t.Errorf("expecting %q in the drawing\n%s", expected, drawing)
+		}
+	}
+}
@@ -15,8 +15,10 @@ import (
 	"time"
 
 	"github.com/hyperjumptech/acccore"
+	"github.com/hyperjumptech/bookkeeping/internal/connector"
 	"github.com/hyperjumptech/bookkeeping/internal/contextkeys"
 	"github.com/hyperjumptech/bookkeeping/internal/helpers"
+	"github.com/hyperjumptech/bookkeeping/internal/middlewares"
 	"github.com/sirupsen/logrus"
 )
 
@@ -33,6 +35,67 @@ var (
 	// ExchangeMgr is the exchange manager instance used in all rest endpoint
 	ExchangeMgr acccore.ExchangeManager
 
+	// AccountStateMgr is the account state manager instance used in all rest endpoint
+	AccountStateMgr AccountStateManager
+
+	// AccountLimitMgr is the account limit manager instance used in all rest endpoint
+	AccountLimitMgr AccountLimitManager
+
+	// HoldMgr is the hold manager instance used in all rest endpoint
+	HoldMgr HoldManager
+
+	// RecurringJournalMgr is the recurring journal manager instance used in all rest endpoint
+	RecurringJournalMgr RecurringJournalManager
+
+	// ApprovalMgr is the approval manager instance used in all rest endpoint
+	ApprovalMgr ApprovalManager
+
+	// PostingTemplateMgr is the posting template manager instance used in all rest endpoint
+	PostingTemplateMgr PostingTemplateManager
+
+	// JournalBatchMgr is the journal batch manager instance used in all rest endpoint
+	JournalBatchMgr JournalBatchManager
+
+	// IdempotencyMgr is the idempotency manager instance used in all rest endpoint
+	IdempotencyMgr IdempotencyManager
+
+	// JournalSimulationMgr is the journal simulation manager instance used in all rest endpoint
+	JournalSimulationMgr JournalSimulationManager
+
+	// ReversalMgr is the reversal manager instance used in all rest endpoint
+	ReversalMgr ReversalManager
+
+	// WebhookMgr is the webhook manager instance used in all rest endpoint
+	WebhookMgr WebhookManager
+
+	// LedgerFeedMgr is the ledger feed manager instance used in all rest endpoint
+	LedgerFeedMgr LedgerFeedManager
+
+	// AuditMgr is the audit manager instance used in all rest endpoint
+	AuditMgr AuditManager
+
+	// APIKeyMgr is the api key manager instance used in all rest endpoint
+	APIKeyMgr APIKeyManager
+
+	// TenantMgr is the tenant manager instance used in all rest endpoint
+	TenantMgr TenantManager
+
+	// MetadataMgr is the metadata manager instance used in all rest endpoint
+	MetadataMgr MetadataManager
+
+	// ReferenceMgr is the external reference manager instance used in all rest endpoint
+	ReferenceMgr ReferenceManager
+
+	// SearchMgr is the search manager instance used in all rest endpoint
+	SearchMgr SearchManager
+	// CursorMgr is the cursor pagination manager instance used in all rest endpoint
+	CursorMgr CursorManager
+	// StatementMgr is the account statement manager instance used in all rest endpoint
+	StatementMgr StatementManager
+
+	// RateLimitMgr is the rate limit manager instance used by the rate limiting middleware, when the counts are kept in the database
+	RateLimitMgr RateLimitManager
+
 	// UniqueIDGenerator is the UniqueIDGenerator instance used in all rest endpoint
 	UniqueIDGenerator acccore.UniqueIDGenerator
 
@@ -45,6 +108,19 @@ var (
 	RestTimeFormat = "2006-01-02T15:04:05"
 )
 
+// requestCreator returns the user a change is made by. A client authenticated by an api key is the creator of its changes,
+// whatever creator it claims in the request body, and so is middlewares.HMACPrincipal of the requests authenticated with
+// the shared secret. The claimed creator is only used for requests authenticated otherwise.
+func requestCreator(r *http.Request, claimed string) string {
+	if client := middlewares.ClientFromContext(r.Context()); client != nil {
+		return client.ClientID
+	}
+	if user, _ := r.Context().Value(contextkeys.UserIDContextKey).(string); user == middlewares.HMACPrincipal {
+		return middlewares.HMACPrincipal
+	}
+	return claimed
+}
+
 // NewAccountEntity is the structure of request body for creating new Account
 type NewAccountEntity struct {
 	AccountNo   string `json:"account_number"`
@@ -54,6 +130,12 @@ type NewAccountEntity struct {
 	Currency    string `json:"currency"`
 	Alignment   string `json:"alignment"`
 	Creator     string `json:"creator"`
+	// Limits is optional, the balance limits of the new account
+	Limits *AccountLimits `json:"limits,omitempty"`
+	// ClientReference is optional, the idempotency key of the request when the Idempotency-Key header is not used
+	ClientReference string `json:"client_reference,omitempty"`
+	// ExternalReference is optional, the reference the creator knows the account by, unique among the accounts of the creator
+	ExternalReference string `json:"external_reference,omitempty"`
 }
 
 // AccountEntity is the structure of response body that contains an account
@@ -65,6 +147,14 @@ type AccountEntity struct {
 	Currency    string `json:"currency"`
 	Alignment   string `json:"alignment"`
 	Balance     int64  `json:"balance"`
+	Status      string `json:"status,omitempty"`
+
+	LedgerBalance    int64 `json:"ledger_balance"`
+	AvailableBalance int64 `json:"available_balance"`
+
+	Limits *AccountLimits `json:"limits,omitempty"`
+
+	ExternalReference string `json:"external_reference,omitempty"`
 }
 
 // PaginatedResponse is the structure of stuff that requires pagination
@@ -79,6 +169,12 @@ type TransactionListResponse struct {
 	Pagination   acccore.PageResult     `json:"pagination"`
 }
 
+// TransactionCursorListResponse is the transaction list response of the cursor pagination
+type TransactionCursorListResponse struct {
+	Transactions []*TransactionListItem `json:"transactions"`
+	Pagination   CursorPage             `json:"pagination"`
+}
+
 // DrawAccount draws the account activity
 func DrawAccount(w http.ResponseWriter, r *http.Request) {
 	requestID := r.Context().Value(contextkeys.XRequestID).(string)
@@ -205,21 +301,56 @@ func GetAccount(w http.ResponseWriter, r *http.Request) {
 		helpers.HTTPResponseBuilder(r.Context(), w, r, 404, "account number not found", "account number not found", 3)
 		return
 	}
+	ret, err := accountEntityOf(r.Context(), account)
+	if err != nil {
+		llog.Errorf("error while reading the state of account %s. got : %s", accountNo, err.Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 500, "backend error", err.Error(), 2)
+		return
+	}
+	helpers.HTTPResponseBuilder(r.Context(), w, r, 200, "account "+account.GetAccountNumber(), ret, 0)
+}
+
+// accountEntityOf returns the response body of the account, along with its status, available balance and limits
+// when their managers are available.
+func accountEntityOf(ctx context.Context, account acccore.Account) (*AccountEntity, error) {
+	accountNo := account.GetAccountNumber()
 	ret := &AccountEntity{
-		AccountNo:   account.GetAccountNumber(),
+		AccountNo:   accountNo,
 		Name:        account.GetName(),
 		Description: account.GetDescription(),
 		COA:         account.GetCOA(),
 		Currency:    account.GetCurrency(),
 		//Alignment:   account.GetBaseTransactionType(),
-		Balance: account.GetBalance(),
+		Balance:           account.GetBalance(),
+		ExternalReference: externalReferenceOf(account),
 	}
 	if account.GetAlignment() == acccore.DEBIT {
 		ret.Alignment = "DEBIT"
 	} else {
 		ret.Alignment = "CREDIT"
 	}
-	helpers.HTTPResponseBuilder(r.Context(), w, r, 200, "account "+account.GetAccountNumber(), ret, 0)
+	var err error
+	if AccountStateMgr != nil {
+		ret.Status, err = AccountStateMgr.GetAccountStatus(ctx, accountNo)
+		if err != nil {
+			return nil, err
+		}
+	}
+	ret.LedgerBalance = ret.Balance
+	ret.AvailableBalance = ret.Balance
+	if HoldMgr != nil {
+		ret.AvailableBalance, err = HoldMgr.GetAvailableBalance(ctx, accountNo)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if AccountLimitMgr != nil {
+		ret.Limits, err = AccountLimitMgr.GetAccountLimits(ctx, accountNo)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return ret, nil
 }
 
 // ListTransactionByAccount lists transactions given an account
@@ -267,17 +398,21 @@ func ListTransactionByAccount(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	qpage := r.URL.Query()["page"]
-	if qpage == nil || len(qpage[0]) == 0 {
-		llog.Errorf("error missing page field")
-		helpers.HTTPResponseBuilder(r.Context(), w, r, 400, "missing from", "missing from", 1)
-		return
-	}
-	page, err = strconv.Atoi(qpage[0])
-	if err != nil {
-		llog.Errorf("invalid page number format : %s", qpage[0])
-		helpers.HTTPResponseBuilder(r.Context(), w, r, 400, "invalid page number format", "invalid page number format", 1)
-		return
+	// the cursor parameter, even empty for the first page, selects the cursor pagination instead of the page numbers
+	cursor, cursorMode := r.URL.Query()["cursor"]
+	if !cursorMode {
+		qpage := r.URL.Query()["page"]
+		if qpage == nil || len(qpage[0]) == 0 {
+			llog.Errorf("error missing page field")
+			helpers.HTTPResponseBuilder(r.Context(), w, r, 400, "missing from", "missing from", 1)
+			return
+		}
+		page, err = strconv.Atoi(qpage[0])
+		if err != nil {
+			llog.Errorf("invalid page number format : %s", qpage[0])
+			helpers.HTTPResponseBuilder(r.Context(), w, r, 400, "invalid page number format", "invalid page number format", 1)
+			return
+		}
 	}
 
 	qsize := r.URL.Query()["size"]
@@ -293,6 +428,21 @@ func ListTransactionByAccount(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	filter, msg := metadataFilterFromQuery(r)
+	if len(msg) > 0 {
+		llog.Errorf("invalid metadata filter : %s", msg)
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 400, "invalid metadata filter", msg, 1)
+		return
+	}
+	if cursorMode && CursorMgr == nil {
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 501, "not implemented", "cursor manager is not available", 0)
+		return
+	}
+	if !cursorMode && len(filter) > 0 && MetadataMgr == nil {
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 501, "not implemented", "metadata manager is not available", 0)
+		return
+	}
+
 	accountNo := m["AccountNumber"]
 	account, err := AccountMgr.GetAccountByID(r.Context(), accountNo)
 	if err != nil {
@@ -306,34 +456,48 @@ func ListTransactionByAccount(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	pr, transactions, err := TransactionMgr.ListTransactionsOnAccount(r.Context(), from, until, account, acccore.PageRequest{
+	if cursorMode {
+		cp, transactions, err := CursorMgr.ListTransactionsOnAccountAfter(r.Context(), from, until, account.GetAccountNumber(), filter, cursor[0], size)
+		if errors.Is(err, ErrInvalidCursor) {
+			helpers.HTTPResponseBuilder(r.Context(), w, r, 400, "invalid cursor", err.Error(), 1)
+			return
+		}
+		if err != nil {
+			llog.Errorf("error while listing the transactions on account %s after cursor. got : %s", accountNo, err.Error())
+			helpers.HTTPResponseBuilder(r.Context(), w, r, 500, "backend error", err.Error(), 2)
+			return
+		}
+		retTransac := make([]*TransactionListItem, len(transactions))
+		for idx, trx := range transactions {
+			retTransac[idx] = transactionListItemOf(trx)
+		}
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 200, "transaction list", &TransactionCursorListResponse{
+			Transactions: retTransac,
+			Pagination:   cp,
+		}, 2)
+		return
+	}
+
+	pageRequest := acccore.PageRequest{
 		PageNo:   page,
 		ItemSize: size,
-	})
+	}
+	var pr acccore.PageResult
+	var transactions []acccore.Transaction
+	if len(filter) > 0 {
+		pr, transactions, err = MetadataMgr.ListTransactionsOnAccountByMetadata(r.Context(), from, until, account.GetAccountNumber(), filter, pageRequest)
+	} else {
+		pr, transactions, err = TransactionMgr.ListTransactionsOnAccount(r.Context(), from, until, account, pageRequest)
+	}
 	if err != nil {
-		llog.Errorf("error while calling TransactionMgr.ListTransactionsOnAccount. got : %s", err.Error())
+		llog.Errorf("error while listing the transactions on account %s. got : %s", accountNo, err.Error())
 		helpers.HTTPResponseBuilder(r.Context(), w, r, 500, "backend error", err.Error(), 2)
 		return
 	}
 
 	retTransac := make([]*TransactionListItem, len(transactions))
 	for idx, trx := range transactions {
-		align := "DEBIT"
-		if trx.GetAlignment() == acccore.CREDIT {
-			align = "CREDIT"
-		}
-		retTransac[idx] = &TransactionListItem{
-			TransactionID:   trx.GetTransactionID(),
-			TransactionTime: trx.GetTransactionTime().Format(time.RFC3339),
-			AccountNumber:   trx.GetAccountNumber(),
-			JournalID:       trx.GetJournalID(),
-			Description:     trx.GetDescription(),
-			TransactionType: align,
-			Amount:          trx.GetAmount(),
-			AccountBalance:  trx.GetAccountBalance(),
-			CreateTime:      trx.GetCreateTime().Format(time.RFC3339),
-			CreateBy:        trx.GetCreateBy(),
-		}
+		retTransac[idx] = transactionListItemOf(trx)
 	}
 
 	resp := &TransactionListResponse{
@@ -352,22 +516,26 @@ type JournalDetail struct {
 	ReversedJournal string `json:"reversed_journal"`
 	Amount          int64  `json:"amount"`
 	Transactions    []*TransactionListItem
-	CreateTime      string `json:"create_time"`
-	CreateBy        string `json:"create_by"`
+	CreateTime      string   `json:"create_time"`
+	CreateBy        string   `json:"create_by"`
+	Metadata        Metadata `json:"metadata,omitempty"`
+	// ExternalReference is the reference the creator knows the journal by, if any
+	ExternalReference string `json:"external_reference,omitempty"`
 }
 
 // TransactionListItem is the transaction detail
 type TransactionListItem struct {
-	TransactionID   string `json:"transaction_id"`
-	TransactionTime string `json:"transaction_time"`
-	AccountNumber   string `json:"account_number"`
-	JournalID       string `json:"journal_id"`
-	Description     string `json:"description"`
-	TransactionType string `json:"transaction_type"`
-	Amount          int64  `json:"amount"`
-	AccountBalance  int64  `json:"account_balance"`
-	CreateTime      string `json:"create_time"`
-	CreateBy        string `json:"create_by"`
+	TransactionID   string   `json:"transaction_id"`
+	TransactionTime string   `json:"transaction_time"`
+	AccountNumber   string   `json:"account_number"`
+	JournalID       string   `json:"journal_id"`
+	Description     string   `json:"description"`
+	TransactionType string   `json:"transaction_type"`
+	Amount          int64    `json:"amount"`
+	AccountBalance  int64    `json:"account_balance"`
+	CreateTime      string   `json:"create_time"`
+	CreateBy        string   `json:"create_by"`
+	Metadata        Metadata `json:"metadata,omitempty"`
 }
 
 // AccountResponseBody with the account details
@@ -537,10 +705,30 @@ func CreateAccount(w http.ResponseWriter, r *http.Request) {
 		helpers.HTTPResponseBuilder(r.Context(), w, r, 500, "error reading body", err.Error(), 0)
 		return
 	}
+	newEnt.Creator = requestCreator(r, newEnt.Creator)
+
+	payload := *newEnt
+	payload.ClientReference = ""
+	idempotentRequest(w, r, connector.IdempotencyScopeAccount, newEnt.ClientReference, newEnt.Creator, &payload, func(w http.ResponseWriter, r *http.Request) {
+		createAccount(w, r, newEnt)
+	}, func(w http.ResponseWriter, r *http.Request, original *IdempotentRequest) {
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 200, "create account", original.ResourceID, 0)
+	})
+}
+
+// createAccount persists the new account and its limits, and writes the response.
+func createAccount(w http.ResponseWriter, r *http.Request, newEnt *NewAccountEntity) {
+	requestID := r.Context().Value(contextkeys.XRequestID).(string)
+	llog := restLog.WithField("RequestID", requestID).WithField("function", "createAccount")
+
+	if newEnt.Limits != nil && AccountLimitMgr == nil {
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 501, "not implemented", "account limit manager is not available", 0)
+		return
+	}
 
 	nctx := context.WithValue(r.Context(), contextkeys.UserIDContextKey, newEnt.Creator)
 
-	acc := &acccore.BaseAccount{}
+	acc := &ReferencedAccount{ExternalReference: newEnt.ExternalReference}
 	acc.SetAccountNumber(newEnt.AccountNo).SetUpdateTime(time.Now()).SetUpdateBy(newEnt.Creator).
 		SetCreateBy(newEnt.Creator).SetCreateTime(time.Now()).SetBalance(0).SetName(newEnt.Name).
 		SetCOA(newEnt.COA).SetCurrency(newEnt.Currency).SetDescription(newEnt.Description)
@@ -555,7 +743,17 @@ func CreateAccount(w http.ResponseWriter, r *http.Request) {
 		acc.SetAccountNumber(UniqueIDGenerator.NewUniqueID())
 	}
 
-	err = AccountMgr.PersistAccount(nctx, acc)
+	var err error
+	if newEnt.Limits != nil {
+		err = AccountLimitMgr.PersistAccountWithLimits(nctx, acc, newEnt.Limits)
+	} else {
+		err = AccountMgr.PersistAccount(nctx, acc)
+	}
+	if errors.Is(err, ErrExternalReferenceTaken) {
+		llog.Errorf("got %s", err.Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 409, "external reference already taken", err.Error(), 0)
+		return
+	}
 	if err != nil {
 		llog.Errorf("got %s", err.Error())
 		helpers.HTTPResponseBuilder(r.Context(), w, r, 400, "error reading body", err.Error(), 0)
@@ -564,6 +762,246 @@ func CreateAccount(w http.ResponseWriter, r *http.Request) {
 	helpers.HTTPResponseBuilder(r.Context(), w, r, 200, "create account", acc.AccountNumber, 0)
 }
 
+// UpdateAccountRequest is the structure of request body for updating an Account
+type UpdateAccountRequest struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	COA         string `json:"coa"`
+	Creator     string `json:"creator"`
+}
+
+// UpdateAccount renames or re-describes an account. Currency, alignment and balance can not be updated.
+func UpdateAccount(w http.ResponseWriter, r *http.Request) {
+	requestID := r.Context().Value(contextkeys.XRequestID).(string)
+	llog := restLog.WithField("RequestID", requestID).WithField("function", "UpdateAccount")
+	if r.Context().Err() != nil {
+		llog.Errorf("context is canceled : %s", r.Context().Err().Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 500, "request is canceled", "request is canceled", 0)
+		return
+	}
+
+	m, err := helpers.ParsePathParams("/api/v1/accounts/{AccountNumber}", r.URL.Path)
+	if err != nil {
+		llog.Errorf("error while processing path template /api/v1/accounts/{AccountNumber}. got : %s", err.Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 404, "path not found", "path not found", 1)
+		return
+	}
+
+	bodyByte, err := io.ReadAll(r.Body)
+	if err != nil {
+		llog.Errorf("got %s", err.Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 500, "error reading body", err.Error(), 0)
+		return
+	}
+	updEnt := &UpdateAccountRequest{}
+	err = json.Unmarshal(bodyByte, updEnt)
+	if err != nil {
+		llog.Errorf("got %s", err.Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 400, "malformed json body", err.Error(), 0)
+		return
+	}
+	updEnt.Creator = requestCreator(r, updEnt.Creator)
+
+	accountNo := m["AccountNumber"]
+	account, err := AccountMgr.GetAccountByID(r.Context(), accountNo)
+	if err != nil {
+		llog.Errorf("error while calling AccountMgr.GetAccountByID. got : %s", err.Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 500, "backend error", err.Error(), 2)
+		return
+	}
+	if account == nil {
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 404, "account number not found", "account number not found", 3)
+		return
+	}
+	middlewares.AuditSnapshot(r.Context(), &AccountEntity{
+		AccountNo:   account.GetAccountNumber(),
+		Name:        account.GetName(),
+		Description: account.GetDescription(),
+		COA:         account.GetCOA(),
+		Currency:    account.GetCurrency(),
+		Balance:     account.GetBalance(),
+	}, nil)
+
+	if len(updEnt.Name) > 0 {
+		account.SetName(updEnt.Name)
+	}
+	if len(updEnt.Description) > 0 {
+		account.SetDescription(updEnt.Description)
+	}
+	if len(updEnt.COA) > 0 {
+		account.SetCOA(updEnt.COA)
+	}
+	account.SetUpdateBy(updEnt.Creator).SetUpdateTime(time.Now())
+
+	nctx := context.WithValue(r.Context(), contextkeys.UserIDContextKey, updEnt.Creator)
+	err = AccountMgr.UpdateAccount(nctx, account)
+	if err != nil {
+		llog.Errorf("error while calling AccountMgr.UpdateAccount. got : %s", err.Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 400, "error updating account", err.Error(), 0)
+		return
+	}
+	GetAccount(w, r)
+}
+
+// AccountStateRequest is the structure of request body for changing the state of an Account
+type AccountStateRequest struct {
+	DebitOnly bool   `json:"debit_only"`
+	Creator   string `json:"creator"`
+}
+
+// FreezeAccount freezes an account. If debit_only is set, the account still accept CREDIT postings.
+func FreezeAccount(w http.ResponseWriter, r *http.Request) {
+	changeAccountState(w, r, "/api/v1/accounts/{AccountNumber}/freeze", "FreezeAccount",
+		func(ctx context.Context, accountNo string, req *AccountStateRequest) error {
+			return AccountStateMgr.FreezeAccount(ctx, accountNo, req.DebitOnly)
+		})
+}
+
+// UnfreezeAccount makes a frozen account active again
+func UnfreezeAccount(w http.ResponseWriter, r *http.Request) {
+	changeAccountState(w, r, "/api/v1/accounts/{AccountNumber}/unfreeze", "UnfreezeAccount",
+		func(ctx context.Context, accountNo string, req *AccountStateRequest) error {
+			return AccountStateMgr.UnfreezeAccount(ctx, accountNo)
+		})
+}
+
+// CloseAccount permanently close an account. Only account with zero balance can be closed.
+func CloseAccount(w http.ResponseWriter, r *http.Request) {
+	changeAccountState(w, r, "/api/v1/accounts/{AccountNumber}/close", "CloseAccount",
+		func(ctx context.Context, accountNo string, req *AccountStateRequest) error {
+			return AccountStateMgr.CloseAccount(ctx, accountNo)
+		})
+}
+
+// changeAccountState is the common controller for FreezeAccount, UnfreezeAccount and CloseAccount
+func changeAccountState(w http.ResponseWriter, r *http.Request, pathTemplate, function string, apply func(ctx context.Context, accountNo string, req *AccountStateRequest) error) {
+	requestID := r.Context().Value(contextkeys.XRequestID).(string)
+	llog := restLog.WithField("RequestID", requestID).WithField("function", function)
+	if r.Context().Err() != nil {
+		llog.Errorf("context is canceled : %s", r.Context().Err().Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 500, "request is canceled", "request is canceled", 0)
+		return
+	}
+	if AccountStateMgr == nil {
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 501, "not implemented", "account state manager is not available", 0)
+		return
+	}
+
+	m, err := helpers.ParsePathParams(pathTemplate, r.URL.Path)
+	if err != nil {
+		llog.Errorf("error while processing path template %s. got : %s", pathTemplate, err.Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 404, "path not found", "path not found", 1)
+		return
+	}
+
+	bodyByte, err := io.ReadAll(r.Body)
+	if err != nil {
+		llog.Errorf("got %s", err.Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 500, "error reading body", err.Error(), 0)
+		return
+	}
+	stateReq := &AccountStateRequest{}
+	err = json.Unmarshal(bodyByte, stateReq)
+	if err != nil {
+		llog.Errorf("got %s", err.Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 400, "malformed json body", err.Error(), 0)
+		return
+	}
+	stateReq.Creator = requestCreator(r, stateReq.Creator)
+
+	accountNo := m["AccountNumber"]
+	if before, err := AccountStateMgr.GetAccountStatus(r.Context(), accountNo); err == nil {
+		middlewares.AuditSnapshot(r.Context(), before, nil)
+	}
+	nctx := context.WithValue(r.Context(), contextkeys.UserIDContextKey, stateReq.Creator)
+	err = apply(nctx, accountNo, stateReq)
+	if err != nil {
+		llog.Errorf("error while calling AccountStateMgr.%s. got : %s", function, err.Error())
+		if errors.Is(err, acccore.ErrAccountIsNotPersisted) {
+			helpers.HTTPResponseBuilder(r.Context(), w, r, 404, "account number not found", "account number not found", 3)
+			return
+		}
+		if errors.Is(err, ErrAccountClosed) || errors.Is(err, ErrAccountBalanceNotZero) || errors.Is(err, ErrAccountHasActiveHolds) {
+			helpers.HTTPResponseBuilder(r.Context(), w, r, 400, "invalid account state", err.Error(), 0)
+			return
+		}
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 500, "backend error", err.Error(), 2)
+		return
+	}
+
+	status, err := AccountStateMgr.GetAccountStatus(r.Context(), accountNo)
+	if err != nil {
+		llog.Errorf("error while calling AccountStateMgr.GetAccountStatus. got : %s", err.Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 500, "backend error", err.Error(), 2)
+		return
+	}
+	helpers.HTTPResponseBuilder(r.Context(), w, r, 200, "account "+accountNo, status, 0)
+}
+
+// AccountLimitsRequest is the structure of request body for setting the balance limits of an Account
+type AccountLimitsRequest struct {
+	AccountLimits
+	Creator string `json:"creator"`
+}
+
+// SetAccountLimits replaces the balance limits of an account. A limit that is not specified is removed.
+func SetAccountLimits(w http.ResponseWriter, r *http.Request) {
+	requestID := r.Context().Value(contextkeys.XRequestID).(string)
+	llog := restLog.WithField("RequestID", requestID).WithField("function", "SetAccountLimits")
+	if r.Context().Err() != nil {
+		llog.Errorf("context is canceled : %s", r.Context().Err().Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 500, "request is canceled", "request is canceled", 0)
+		return
+	}
+	if AccountLimitMgr == nil {
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 501, "not implemented", "account limit manager is not available", 0)
+		return
+	}
+
+	m, err := helpers.ParsePathParams("/api/v1/accounts/{AccountNumber}/limits", r.URL.Path)
+	if err != nil {
+		llog.Errorf("error while processing path template /api/v1/accounts/{AccountNumber}/limits. got : %s", err.Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 404, "path not found", "path not found", 1)
+		return
+	}
+
+	bodyByte, err := io.ReadAll(r.Body)
+	if err != nil {
+		llog.Errorf("got %s", err.Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 500, "error reading body", err.Error(), 0)
+		return
+	}
+	limitReq := &AccountLimitsRequest{}
+	err = json.Unmarshal(bodyByte, limitReq)
+	if err != nil {
+		llog.Errorf("got %s", err.Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 400, "malformed json body", err.Error(), 0)
+		return
+	}
+	limitReq.Creator = requestCreator(r, limitReq.Creator)
+
+	accountNo := m["AccountNumber"]
+	if before, err := AccountLimitMgr.GetAccountLimits(r.Context(), accountNo); err == nil {
+		middlewares.AuditSnapshot(r.Context(), before, nil)
+	}
+	nctx := context.WithValue(r.Context(), contextkeys.UserIDContextKey, limitReq.Creator)
+	err = AccountLimitMgr.SetAccountLimits(nctx, accountNo, &limitReq.AccountLimits)
+	if err != nil {
+		llog.Errorf("error while calling AccountLimitMgr.SetAccountLimits. got : %s", err.Error())
+		if errors.Is(err, acccore.ErrAccountIsNotPersisted) {
+			helpers.HTTPResponseBuilder(r.Context(), w, r, 404, "account number not found", "account number not found", 3)
+			return
+		}
+		if errors.Is(err, ErrInvalidAccountLimits) {
+			helpers.HTTPResponseBuilder(r.Context(), w, r, 400, "invalid account limits", err.Error(), 0)
+			return
+		}
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 500, "backend error", err.Error(), 2)
+		return
+	}
+	helpers.HTTPResponseBuilder(r.Context(), w, r, 200, "account "+accountNo, &limitReq.AccountLimits, 0)
+}
+
 // GetJournal fetches a journal from journal ID
 func GetJournal(w http.ResponseWriter, r *http.Request) {
 	requestID := r.Context().Value(contextkeys.XRequestID).(string)
@@ -595,44 +1033,56 @@ func GetJournal(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	helpers.HTTPResponseBuilder(r.Context(), w, r, 200, "OK", journalDetailOf(j), 1)
+}
+
+// journalDetailOf returns the response body of the journal and its transactions
+func journalDetailOf(j acccore.Journal) *JournalDetail {
 	reversedJournal := ""
 	if j.IsReversal() && j.GetReversedJournal() != nil {
 		reversedJournal = j.GetReversedJournal().GetJournalID()
 	}
 
 	retJournal := &JournalDetail{
-		JournalID:       j.GetJournalID(),
-		JournalingTime:  j.GetJournalingTime().Format(time.RFC3339),
-		Description:     j.GetDescription(),
-		Reversal:        j.IsReversal(),
-		ReversedJournal: reversedJournal,
-		Amount:          j.GetAmount(),
-		Transactions:    nil,
-		CreateTime:      j.GetCreateTime().Format(time.RFC3339),
-		CreateBy:        j.GetCreateBy(),
+		JournalID:         j.GetJournalID(),
+		JournalingTime:    j.GetJournalingTime().Format(time.RFC3339),
+		Description:       j.GetDescription(),
+		Reversal:          j.IsReversal(),
+		ReversedJournal:   reversedJournal,
+		Amount:            j.GetAmount(),
+		Transactions:      nil,
+		CreateTime:        j.GetCreateTime().Format(time.RFC3339),
+		CreateBy:          j.GetCreateBy(),
+		Metadata:          metadataOf(j),
+		ExternalReference: externalReferenceOf(j),
 	}
 	retTrxes := make([]*TransactionListItem, len(j.GetTransactions()))
 	for idx, trx := range j.GetTransactions() {
-		align := "DEBIT"
-		if trx.GetAlignment() == acccore.CREDIT {
-			align = "CREDIT"
-		}
-		retTrxes[idx] = &TransactionListItem{
-			TransactionID:   trx.GetTransactionID(),
-			TransactionTime: trx.GetTransactionTime().Format(time.RFC3339),
-			AccountNumber:   trx.GetAccountNumber(),
-			JournalID:       trx.GetJournalID(),
-			Description:     trx.GetDescription(),
-			TransactionType: align,
-			Amount:          trx.GetAmount(),
-			AccountBalance:  trx.GetAccountBalance(),
-			CreateTime:      trx.GetCreateTime().Format(time.RFC3339),
-			CreateBy:        trx.GetCreateBy(),
-		}
+		retTrxes[idx] = transactionListItemOf(trx)
 	}
 	retJournal.Transactions = retTrxes
+	return retJournal
+}
 
-	helpers.HTTPResponseBuilder(r.Context(), w, r, 200, "OK", retJournal, 1)
+// transactionListItemOf returns the response body of the transaction
+func transactionListItemOf(trx acccore.Transaction) *TransactionListItem {
+	align := "DEBIT"
+	if trx.GetAlignment() == acccore.CREDIT {
+		align = "CREDIT"
+	}
+	return &TransactionListItem{
+		TransactionID:   trx.GetTransactionID(),
+		TransactionTime: trx.GetTransactionTime().Format(time.RFC3339),
+		AccountNumber:   trx.GetAccountNumber(),
+		JournalID:       trx.GetJournalID(),
+		Description:     trx.GetDescription(),
+		TransactionType: align,
+		Amount:          trx.GetAmount(),
+		AccountBalance:  trx.GetAccountBalance(),
+		CreateTime:      trx.GetCreateTime().Format(time.RFC3339),
+		CreateBy:        trx.GetCreateBy(),
+		Metadata:        metadataOf(trx),
+	}
 }
 
 // DrawJournal draws the journal activity for easier debugging
@@ -673,12 +1123,38 @@ func DrawJournal(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte(drawing))
 }
 
+// metadataFilterFromQuery reads the metadata filter from the `metadata.<key>=<value>` query parameters,
+// a key with an empty value only needs to be present. It returns a message telling what is wrong with the filter.
+func metadataFilterFromQuery(r *http.Request) (Metadata, string) {
+	var filter Metadata
+	for name, values := range r.URL.Query() {
+		key, ok := strings.CutPrefix(name, "metadata.")
+		if !ok {
+			continue
+		}
+		if err := ValidateMetadataKey(key); err != nil {
+			return nil, err.Error()
+		}
+		if filter == nil {
+			filter = make(Metadata)
+		}
+		filter[key] = values[0]
+	}
+	return filter, ""
+}
+
 // PaginatedJournalsResponse is the journal response paginated
 type PaginatedJournalsResponse struct {
 	Journals   []acccore.Journal  `json:"journals"`
 	Pagination acccore.PageResult `json:"pagination"`
 }
 
+// CursorJournalsResponse is the journal response of the cursor pagination
+type CursorJournalsResponse struct {
+	Journals   []acccore.Journal `json:"journals"`
+	Pagination CursorPage        `json:"pagination"`
+}
+
 // ListJournal lists the journal given
 func ListJournal(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
@@ -694,8 +1170,10 @@ func ListJournal(w http.ResponseWriter, r *http.Request) {
 	untilA, uOk := r.URL.Query()["until"]
 	pageA, pOk := r.URL.Query()["page"]
 	sizeA, sOk := r.URL.Query()["size"]
+	// the cursor parameter, even empty for the first page, selects the cursor pagination instead of the page numbers
+	cursor, cursorMode := r.URL.Query()["cursor"]
 
-	if !fOk || !uOk || !pOk || !sOk {
+	if !fOk || !uOk || !(pOk || cursorMode) || !sOk {
 		helpers.HTTPResponseBuilder(ctx, w, r, 400, "invalid request", "either from, until, page or size is missing", 0)
 		return
 	}
@@ -705,18 +1183,59 @@ func ListJournal(w http.ResponseWriter, r *http.Request) {
 		helpers.HTTPResponseBuilder(ctx, w, r, 400, "invalid request", "either from, until time format not correct", 0)
 		return
 	}
-	page, perr := strconv.Atoi(pageA[0])
+	var page int
+	var perr error
+	if !cursorMode {
+		page, perr = strconv.Atoi(pageA[0])
+	}
 	size, serr := strconv.Atoi(sizeA[0])
 	if perr != nil || serr != nil {
 		helpers.HTTPResponseBuilder(ctx, w, r, 400, "invalid request", "either page, size is not number", 0)
 		return
 	}
 
-	pr, journals, err := JournalMgr.ListJournals(r.Context(), fTime, uTime, acccore.PageRequest{
+	filter, msg := metadataFilterFromQuery(r)
+	if len(msg) > 0 {
+		helpers.HTTPResponseBuilder(ctx, w, r, 400, "invalid metadata filter", msg, 0)
+		return
+	}
+	if cursorMode {
+		if CursorMgr == nil {
+			helpers.HTTPResponseBuilder(ctx, w, r, 501, "not implemented", "cursor manager is not available", 0)
+			return
+		}
+		cp, journals, err := CursorMgr.ListJournalsAfter(ctx, fTime, uTime, filter, cursor[0], size)
+		if errors.Is(err, ErrInvalidCursor) {
+			helpers.HTTPResponseBuilder(ctx, w, r, 400, "invalid cursor", err.Error(), 0)
+			return
+		}
+		if err != nil {
+			helpers.HTTPResponseBuilder(ctx, w, r, 500, "internal server error", err.Error(), 0)
+			return
+		}
+		helpers.HTTPResponseBuilder(ctx, w, r, 200, "OK", &CursorJournalsResponse{
+			Journals:   journals,
+			Pagination: cp,
+		}, 0)
+		return
+	}
+	pageRequest := acccore.PageRequest{
 		PageNo:   page,
 		ItemSize: size,
 		Sorts:    nil,
-	})
+	}
+	var pr acccore.PageResult
+	var journals []acccore.Journal
+	var err error
+	if len(filter) > 0 {
+		if MetadataMgr == nil {
+			helpers.HTTPResponseBuilder(ctx, w, r, 501, "not implemented", "metadata manager is not available", 0)
+			return
+		}
+		pr, journals, err = MetadataMgr.ListJournalsByMetadata(ctx, fTime, uTime, filter, pageRequest)
+	} else {
+		pr, journals, err = JournalMgr.ListJournals(ctx, fTime, uTime, pageRequest)
+	}
 	if err != nil {
 		helpers.HTTPResponseBuilder(ctx, w, r, 500, "internal server error", err.Error(), 0)
 		return
@@ -732,6 +1251,9 @@ type CreateReversalRequest struct {
 	Description string `json:"description"`
 	JournalID   string `json:"journal_id"`
 	Creator     string `json:"creator"`
+	// Transactions is optional, the lines and amounts to reverse for a partial reversal.
+	// When not specified all that is left to reverse of the journal is reversed.
+	Transactions []*ReversalLine `json:"transactions,omitempty"`
 }
 
 // CreateJournalRequest is the create journal request paylaod
@@ -739,6 +1261,12 @@ type CreateJournalRequest struct {
 	Description  string                `json:"description"`
 	Creator      string                `json:"creator"`
 	Transactions []*TransactionRequest `json:"transactions"`
+	// ClientReference is optional, the idempotency key of the request when the Idempotency-Key header is not used
+	ClientReference string `json:"client_reference,omitempty"`
+	// Metadata is optional, the key value pairs to attach to the journal
+	Metadata Metadata `json:"metadata,omitempty"`
+	// ExternalReference is optional, the reference the creator knows the journal by, unique among the journals of the creator
+	ExternalReference string `json:"external_reference,omitempty"`
 }
 
 // TransactionRequest is the create transaction request payload
@@ -747,6 +1275,52 @@ type TransactionRequest struct {
 	Description   string `json:"description"`
 	Alignment     string `json:"alignment"`
 	Amount        int64  `json:"amount"`
+	// Metadata is optional, the key value pairs to attach to the transaction
+	Metadata Metadata `json:"metadata,omitempty"`
+}
+
+// NewJournalFromRequest builds a new, not yet persisted, journal out of the create journal request.
+// The journal and its transactions are IDed using the idGenerator.
+func NewJournalFromRequest(req *CreateJournalRequest, idGenerator acccore.UniqueIDGenerator) *MetadataJournal {
+	journal := &MetadataJournal{
+		BaseJournal: acccore.BaseJournal{
+			JournalID:       idGenerator.NewUniqueID(),
+			JournalingTime:  time.Now(),
+			Description:     req.Description,
+			Reversal:        false,
+			ReversedJournal: nil,
+			Amount:          0,
+			Transactions:    make([]acccore.Transaction, 0),
+			CreateTime:      time.Now(),
+			CreatedBy:       req.Creator,
+		},
+		Metadata:          req.Metadata,
+		ExternalReference: req.ExternalReference,
+	}
+
+	for _, tx := range req.Transactions {
+		ntx := &MetadataTransaction{
+			BaseTransaction: acccore.BaseTransaction{
+				TransactionID:   idGenerator.NewUniqueID(),
+				TransactionTime: time.Now(),
+				AccountNumber:   tx.AccountNumber,
+				JournalID:       journal.JournalID,
+				Description:     tx.Description,
+				Amount:          tx.Amount,
+				AccountBalance:  0,
+				CreateTime:      time.Now(),
+				CreateBy:        req.Creator,
+			},
+			Metadata: tx.Metadata,
+		}
+		if strings.ToUpper(tx.Alignment) == "DEBIT" {
+			ntx.TransactionType = acccore.DEBIT
+		} else {
+			ntx.TransactionType = acccore.CREDIT
+		}
+		journal.Transactions = append(journal.Transactions, ntx)
+	}
+	return journal
 }
 
 // CreateJournal creates a journal
@@ -770,48 +1344,73 @@ func CreateJournal(w http.ResponseWriter, r *http.Request) {
 		helpers.HTTPResponseBuilder(r.Context(), w, r, 400, "malformed json body", err.Error(), 0)
 		return
 	}
+	reqBod.Creator = requestCreator(r, reqBod.Creator)
 
-	journal := &acccore.BaseJournal{
-		JournalID:       UniqueIDGenerator.NewUniqueID(),
-		JournalingTime:  time.Now(),
-		Description:     reqBod.Description,
-		Reversal:        false,
-		ReversedJournal: nil,
-		Amount:          0,
-		Transactions:    make([]acccore.Transaction, 0),
-		CreateTime:      time.Now(),
-		CreatedBy:       reqBod.Creator,
-	}
-
-	for _, tx := range reqBod.Transactions {
-		ntx := &acccore.BaseTransaction{
-			TransactionID:   UniqueIDGenerator.NewUniqueID(),
-			TransactionTime: time.Now(),
-			AccountNumber:   tx.AccountNumber,
-			JournalID:       journal.JournalID,
-			Description:     tx.Description,
-			Amount:          tx.Amount,
-			AccountBalance:  0,
-			CreateTime:      time.Now(),
-			CreateBy:        reqBod.Creator,
+	payload := *reqBod
+	payload.ClientReference = ""
+	idempotentRequest(w, r, connector.IdempotencyScopeJournal, reqBod.ClientReference, reqBod.Creator, &payload, func(w http.ResponseWriter, r *http.Request) {
+		postJournalRequest(w, r, reqBod)
+	}, replayJournalRequest)
+}
+
+// replayJournalRequest writes the response of the journal request made with the same idempotency key,
+// the journal it posted or the journal it submitted as pending journal.
+func replayJournalRequest(w http.ResponseWriter, r *http.Request, original *IdempotentRequest) {
+	requestID := r.Context().Value(contextkeys.XRequestID).(string)
+	llog := restLog.WithField("RequestID", requestID).WithField("function", "replayJournalRequest")
+
+	if original.Code == http.StatusAccepted && ApprovalMgr != nil {
+		pj, err := ApprovalMgr.GetPendingJournal(r.Context(), original.ResourceID)
+		if err != nil {
+			llog.Errorf("error while calling ApprovalMgr.GetPendingJournal. got : %s", err.Error())
+			approvalErrorResponse(w, r, err)
+			return
 		}
-		if strings.ToUpper(tx.Alignment) == "DEBIT" {
-			ntx.TransactionType = acccore.DEBIT
-		} else {
-			ntx.TransactionType = acccore.CREDIT
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 202, "journal needs approval, pending journal "+pj.PendingID, pj, 0)
+		return
+	}
+	helpers.HTTPResponseBuilder(r.Context(), w, r, 200, "OK", original.ResourceID, 0)
+}
+
+// postJournalRequest posts the journal request, or submit it as pending journal if it matches an approval rule,
+// and writes the response.
+func postJournalRequest(w http.ResponseWriter, r *http.Request, reqBod *CreateJournalRequest) {
+	requestID := r.Context().Value(contextkeys.XRequestID).(string)
+	llog := restLog.WithField("RequestID", requestID).WithField("function", "postJournalRequest")
+
+	if ApprovalMgr != nil {
+		needApproval, err := ApprovalMgr.RequiresApproval(r.Context(), reqBod)
+		if err != nil {
+			llog.Errorf("error while calling ApprovalMgr.RequiresApproval. got : %s", err.Error())
+			helpers.HTTPResponseBuilder(r.Context(), w, r, 500, "backend error", err.Error(), 2)
+			return
+		}
+		if needApproval {
+			pj, err := ApprovalMgr.SubmitJournal(r.Context(), reqBod)
+			if err != nil {
+				llog.Errorf("error while calling ApprovalMgr.SubmitJournal. got : %s", err.Error())
+				approvalErrorResponse(w, r, err)
+				return
+			}
+			helpers.HTTPResponseBuilder(r.Context(), w, r, 202, "journal needs approval, pending journal "+pj.PendingID, pj, 0)
+			return
 		}
-		journal.Transactions = append(journal.Transactions, ntx)
 	}
 
+	journal := NewJournalFromRequest(reqBod, UniqueIDGenerator)
+
 	journalContext := context.WithValue(r.Context(), contextkeys.UserIDContextKey, reqBod.Creator)
 
-	err = JournalMgr.PersistJournal(journalContext, journal)
+	err := JournalMgr.PersistJournal(journalContext, journal)
+	if errors.Is(err, ErrExternalReferenceTaken) {
+		helpers.HTTPResponseBuilder(journalContext, w, r, 409, "external reference already taken", err.Error(), 0)
+		return
+	}
 	if err != nil {
 		helpers.HTTPResponseBuilder(journalContext, w, r, 400, "malformed json body", err.Error(), 0)
 		return
 	}
 	helpers.HTTPResponseBuilder(journalContext, w, r, 200, "OK", journal.JournalID, 0)
-
 }
 
 // CreateReversalJournal creates a reversal journal response
@@ -823,6 +1422,10 @@ func CreateReversalJournal(w http.ResponseWriter, r *http.Request) {
 		helpers.HTTPResponseBuilder(r.Context(), w, r, 500, "request is canceled", "request is canceled", 0)
 		return
 	}
+	if ReversalMgr == nil {
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 501, "not implemented", "reversal manager is not available", 0)
+		return
+	}
 
 	byteBody, err := io.ReadAll(r.Body)
 	if err != nil {
@@ -836,64 +1439,15 @@ func CreateReversalJournal(w http.ResponseWriter, r *http.Request) {
 		helpers.HTTPResponseBuilder(r.Context(), w, r, 400, "invalid request body", err.Error(), 0)
 		return
 	}
+	rBody.Creator = requestCreator(r, rBody.Creator)
 
-	rJournal, err := JournalMgr.GetJournalByID(r.Context(), rBody.JournalID)
+	journal, err := ReversalMgr.ReverseJournal(r.Context(), rBody.JournalID, rBody.Transactions, rBody.Description, rBody.Creator)
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) || errors.Is(err, acccore.ErrJournalIDNotFound) {
-			helpers.HTTPResponseBuilder(r.Context(), w, r, 404, "journal not found", "journal to reverse not found", 0)
-			return
-		}
-		helpers.HTTPResponseBuilder(r.Context(), w, r, 500, "internal server error when fetching journal", err.Error(), 0)
-		return
-	}
-	if rJournal == nil {
-		helpers.HTTPResponseBuilder(r.Context(), w, r, 404, "journal not found", "journal to reverse not found", 0)
+		llog.Errorf("error while calling ReversalMgr.ReverseJournal. got : %s", err.Error())
+		reversalErrorResponse(w, r, err)
 		return
 	}
-
-	journal := &acccore.BaseJournal{
-		JournalID:       UniqueIDGenerator.NewUniqueID(),
-		JournalingTime:  time.Now(),
-		Reversal:        true,
-		ReversedJournal: rJournal,
-		Description:     rBody.Description,
-		CreatedBy:       rBody.Creator,
-		CreateTime:      time.Now(),
-	}
-
-	transacs := make([]acccore.Transaction, 0)
-
-	// make sure all Transactions have accounts of the same Currency
-	for _, txinfo := range rJournal.GetTransactions() {
-		tx := acccore.DEBIT
-		if txinfo.GetAlignment() == acccore.DEBIT {
-			tx = acccore.CREDIT
-		}
-
-		newTransaction := &acccore.BaseTransaction{
-			TransactionID:   UniqueIDGenerator.NewUniqueID(),
-			TransactionTime: time.Time{},
-			AccountNumber:   txinfo.GetAccountNumber(),
-			JournalID:       journal.JournalID,
-			Description:     fmt.Sprintf("%s - reversed", txinfo.GetDescription()),
-			TransactionType: tx,
-			Amount:          txinfo.GetAmount(),
-			AccountBalance:  txinfo.GetAmount(),
-			CreateTime:      time.Now(),
-			CreateBy:        rBody.Creator,
-		}
-		transacs = append(transacs, newTransaction)
-	}
-
-	journal.SetTransactions(transacs)
-	journalContext := context.WithValue(r.Context(), contextkeys.UserIDContextKey, rBody.Creator)
-
-	err = JournalMgr.PersistJournal(journalContext, journal)
-	if err != nil {
-		helpers.HTTPResponseBuilder(r.Context(), w, r, 500, "internal server error when reversing journal", err.Error(), 0)
-		return
-	}
-	helpers.HTTPResponseBuilder(r.Context(), w, r, 200, "OK", journal.JournalID, 0)
+	helpers.HTTPResponseBuilder(r.Context(), w, r, 200, "OK", journal.GetJournalID(), 0)
 }
 
 // GetTransaction retrieves a transaction from its ID
@@ -946,6 +1500,8 @@ func SetCommonDenominator(w http.ResponseWriter, r *http.Request) {
 		helpers.HTTPResponseBuilder(r.Context(), w, r, 400, "Malformed request", "denom must be a number (could be float)", 0)
 		return
 	}
+	before, _ := ExchangeMgr.GetDenom(r.Context()).Float64()
+	middlewares.AuditSnapshot(r.Context(), before, nil)
 	ExchangeMgr.SetDenom(r.Context(), big.NewFloat(f))
 	helpers.HTTPResponseBuilder(r.Context(), w, r, 200, "OK", f, 0)
 }
@@ -995,6 +1551,7 @@ func SetCurrency(w http.ResponseWriter, r *http.Request) {
 		helpers.HTTPResponseBuilder(r.Context(), w, r, 400, "malformed json", err.Error(), 1)
 		return
 	}
+	setBody.Author = requestCreator(r, setBody.Author)
 
 	createNew := false
 
@@ -1028,6 +1585,11 @@ func SetCurrency(w http.ResponseWriter, r *http.Request) {
 		}, 1)
 		return
 	}
+	middlewares.AuditSnapshot(r.Context(), &CurrencyRet{
+		Code:     cur.GetCode(),
+		Name:     cur.GetName(),
+		Exchange: cur.GetExchange(),
+	}, nil)
 	cur.SetExchange(setBody.Exchange).SetName(setBody.Name)
 	err = ExchangeMgr.UpdateCurrency(r.Context(), m["code"], cur, setBody.Author)
 	if err != nil {
@@ -84,6 +84,10 @@ func TestRestAll(t *testing.T) {
 	ExchangeMgr = exchangeManager
 	UniqueIDGenerator = uniqueIDGenerator
 
+	// the shared secret is disabled by default
+	middlewares.SecretKey = "rest-test-secret"
+	middlewares.LegacyHMACEnabled = true
+
 	Router = mux.NewRouter()
 
 	Router.Use(middlewares.SetupContextMiddleware, middlewares.Logger, middlewares.HMACMiddleware)
@@ -0,0 +1,336 @@
+package accounting
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/hyperjumptech/acccore"
+	"github.com/hyperjumptech/bookkeeping/internal/connector"
+	"github.com/hyperjumptech/bookkeeping/internal/contextkeys"
+	"github.com/hyperjumptech/bookkeeping/internal/helpers"
+	"github.com/hyperjumptech/bookkeeping/internal/middlewares"
+)
+
+// ReviewPendingJournalRequest is the structure of request body for approving or rejecting a pending journal
+type ReviewPendingJournalRequest struct {
+	// Reviewer must not be the one who submitted the pending journal
+	Reviewer string `json:"reviewer"`
+	Note     string `json:"note"`
+}
+
+// ApprovalRuleRequest is the structure of request body for creating an approval rule
+type ApprovalRuleRequest struct {
+	Description string `json:"description"`
+	// MinAmount is optional, when specified only journals of at least this amount needs approval
+	MinAmount *int64 `json:"min_amount,omitempty"`
+	// Coa is optional, when specified only journals posting into accounts whose COA starts with it needs approval
+	Coa     string `json:"coa,omitempty"`
+	Creator string `json:"creator"`
+}
+
+// PaginatedPendingJournalsResponse is the pending journal response paginated
+type PaginatedPendingJournalsResponse struct {
+	PendingJournals []*PendingJournal `json:"pending_journals"`
+	Pagination      *PageResultBody   `json:"pagination"`
+}
+
+// approvalErrorResponse writes the response for errors returned by ApprovalMgr
+func approvalErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, ErrPendingJournalNotFound):
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 404, "pending journal not found", err.Error(), 3)
+	case errors.Is(err, ErrApprovalRuleNotFound):
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 404, "approval rule not found", err.Error(), 3)
+	case errors.Is(err, ErrSelfApproval):
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 403, "review rejected", err.Error(), 0)
+	case errors.Is(err, ErrPendingJournalNotPending),
+		errors.Is(err, ErrMissingReviewer),
+		errors.Is(err, ErrInvalidApprovalRule),
+		errors.Is(err, acccore.ErrJournalMissingAuthor),
+		errors.Is(err, acccore.ErrJournalNoTransaction),
+		errors.Is(err, acccore.ErrJournalNotBalance),
+		errors.Is(err, acccore.ErrJournalTransactionAccountNotPersist),
+		errors.Is(err, ErrInvalidMetadata),
+		errors.Is(err, ErrInvalidExternalReference):
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 400, "request rejected", err.Error(), 0)
+	case errors.Is(err, ErrExternalReferenceTaken):
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 409, "journal rejected", err.Error(), 0)
+	default:
+		// journal rejected by the journal manager when approved, eg. frozen account or balance limit
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 400, "journal rejected", err.Error(), 0)
+	}
+}
+
+// SubmitPendingJournal submits a journal to be posted only after approved by another user
+func SubmitPendingJournal(w http.ResponseWriter, r *http.Request) {
+	requestID := r.Context().Value(contextkeys.XRequestID).(string)
+	llog := restLog.WithField("RequestID", requestID).WithField("function", "SubmitPendingJournal")
+	if r.Context().Err() != nil {
+		llog.Errorf("context is canceled : %s", r.Context().Err().Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 500, "request is canceled", "request is canceled", 0)
+		return
+	}
+	if ApprovalMgr == nil {
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 501, "not implemented", "approval manager is not available", 0)
+		return
+	}
+
+	bodyByte, err := io.ReadAll(r.Body)
+	if err != nil {
+		llog.Errorf("got %s", err.Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 500, "error reading body", err.Error(), 0)
+		return
+	}
+	journalReq := &CreateJournalRequest{}
+	err = json.Unmarshal(bodyByte, journalReq)
+	if err != nil {
+		llog.Errorf("got %s", err.Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 400, "malformed json body", err.Error(), 0)
+		return
+	}
+	journalReq.Creator = requestCreator(r, journalReq.Creator)
+
+	pj, err := ApprovalMgr.SubmitJournal(r.Context(), journalReq)
+	if err != nil {
+		llog.Errorf("error while calling ApprovalMgr.SubmitJournal. got : %s", err.Error())
+		approvalErrorResponse(w, r, err)
+		return
+	}
+	helpers.HTTPResponseBuilder(r.Context(), w, r, 202, "pending journal "+pj.PendingID, pj, 0)
+}
+
+// ListPendingJournals lists the pending journals of a status, PENDING if the status is not specified
+func ListPendingJournals(w http.ResponseWriter, r *http.Request) {
+	requestID := r.Context().Value(contextkeys.XRequestID).(string)
+	llog := restLog.WithField("RequestID", requestID).WithField("function", "ListPendingJournals")
+	if r.Context().Err() != nil {
+		llog.Errorf("context is canceled : %s", r.Context().Err().Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 500, "request is canceled", "request is canceled", 0)
+		return
+	}
+	if ApprovalMgr == nil {
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 501, "not implemented", "approval manager is not available", 0)
+		return
+	}
+
+	pageRequest, msg := pageRequestFromQuery(r)
+	if len(msg) > 0 {
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 400, "invalid request", msg, 0)
+		return
+	}
+	status := strings.ToUpper(r.URL.Query().Get("status"))
+	switch status {
+	case "":
+		status = connector.PendingJournalStatusPending
+	case connector.PendingJournalStatusPending, connector.PendingJournalStatusApproved, connector.PendingJournalStatusRejected:
+	default:
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 400, "invalid request", "status must be PENDING, APPROVED or REJECTED", 0)
+		return
+	}
+
+	pr, pjs, err := ApprovalMgr.ListPendingJournals(r.Context(), status, pageRequest)
+	if err != nil {
+		llog.Errorf("error while calling ApprovalMgr.ListPendingJournals. got : %s", err.Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 500, "backend error", err.Error(), 2)
+		return
+	}
+	helpers.HTTPResponseBuilder(r.Context(), w, r, 200, "OK", &PaginatedPendingJournalsResponse{
+		PendingJournals: pjs,
+		Pagination:      FromAccorePageResult(pr),
+	}, 0)
+}
+
+// GetPendingJournal fetches a pending journal from its pending ID
+func GetPendingJournal(w http.ResponseWriter, r *http.Request) {
+	requestID := r.Context().Value(contextkeys.XRequestID).(string)
+	llog := restLog.WithField("RequestID", requestID).WithField("function", "GetPendingJournal")
+	if r.Context().Err() != nil {
+		llog.Errorf("context is canceled : %s", r.Context().Err().Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 500, "request is canceled", "request is canceled", 0)
+		return
+	}
+	if ApprovalMgr == nil {
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 501, "not implemented", "approval manager is not available", 0)
+		return
+	}
+
+	m, err := helpers.ParsePathParams("/api/v1/pending-journals/{PendingID}", r.URL.Path)
+	if err != nil {
+		llog.Errorf("error while processing path template /api/v1/pending-journals/{PendingID}. got : %s", err.Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 404, "path not found", "path not found", 1)
+		return
+	}
+
+	pj, err := ApprovalMgr.GetPendingJournal(r.Context(), m["PendingID"])
+	if err != nil {
+		llog.Errorf("error while calling ApprovalMgr.GetPendingJournal. got : %s", err.Error())
+		if errors.Is(err, ErrPendingJournalNotFound) {
+			approvalErrorResponse(w, r, err)
+			return
+		}
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 500, "backend error", err.Error(), 2)
+		return
+	}
+	helpers.HTTPResponseBuilder(r.Context(), w, r, 200, "pending journal "+pj.PendingID, pj, 0)
+}
+
+// ApprovePendingJournal approves and posts a pending journal
+func ApprovePendingJournal(w http.ResponseWriter, r *http.Request) {
+	reviewPendingJournal(w, r, "/api/v1/pending-journals/{PendingID}/approve", "ApprovePendingJournal", ApprovalManager.ApproveJournal)
+}
+
+// RejectPendingJournal rejects a pending journal
+func RejectPendingJournal(w http.ResponseWriter, r *http.Request) {
+	reviewPendingJournal(w, r, "/api/v1/pending-journals/{PendingID}/reject", "RejectPendingJournal", ApprovalManager.RejectJournal)
+}
+
+// reviewPendingJournal is the common handler of approving and rejecting a pending journal
+func reviewPendingJournal(w http.ResponseWriter, r *http.Request, pathTemplate, function string, review func(am ApprovalManager, ctx context.Context, pendingID, reviewer, note string) (*PendingJournal, error)) {
+	requestID := r.Context().Value(contextkeys.XRequestID).(string)
+	llog := restLog.WithField("RequestID", requestID).WithField("function", function)
+	if r.Context().Err() != nil {
+		llog.Errorf("context is canceled : %s", r.Context().Err().Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 500, "request is canceled", "request is canceled", 0)
+		return
+	}
+	if ApprovalMgr == nil {
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 501, "not implemented", "approval manager is not available", 0)
+		return
+	}
+
+	m, err := helpers.ParsePathParams(pathTemplate, r.URL.Path)
+	if err != nil {
+		llog.Errorf("error while processing path template %s. got : %s", pathTemplate, err.Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 404, "path not found", "path not found", 1)
+		return
+	}
+
+	bodyByte, err := io.ReadAll(r.Body)
+	if err != nil {
+		llog.Errorf("got %s", err.Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 500, "error reading body", err.Error(), 0)
+		return
+	}
+	reviewReq := &ReviewPendingJournalRequest{}
+	err = json.Unmarshal(bodyByte, reviewReq)
+	if err != nil {
+		llog.Errorf("got %s", err.Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 400, "malformed json body", err.Error(), 0)
+		return
+	}
+	reviewReq.Reviewer = requestCreator(r, reviewReq.Reviewer)
+
+	pj, err := review(ApprovalMgr, r.Context(), m["PendingID"], reviewReq.Reviewer, reviewReq.Note)
+	if err != nil {
+		llog.Errorf("error while reviewing pending journal. got : %s", err.Error())
+		approvalErrorResponse(w, r, err)
+		return
+	}
+	helpers.HTTPResponseBuilder(r.Context(), w, r, 200, "pending journal "+pj.PendingID, pj, 0)
+}
+
+// CreateApprovalRule adds a rule deciding which journals needs approval
+func CreateApprovalRule(w http.ResponseWriter, r *http.Request) {
+	requestID := r.Context().Value(contextkeys.XRequestID).(string)
+	llog := restLog.WithField("RequestID", requestID).WithField("function", "CreateApprovalRule")
+	if r.Context().Err() != nil {
+		llog.Errorf("context is canceled : %s", r.Context().Err().Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 500, "request is canceled", "request is canceled", 0)
+		return
+	}
+	if ApprovalMgr == nil {
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 501, "not implemented", "approval manager is not available", 0)
+		return
+	}
+
+	bodyByte, err := io.ReadAll(r.Body)
+	if err != nil {
+		llog.Errorf("got %s", err.Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 500, "error reading body", err.Error(), 0)
+		return
+	}
+	ruleReq := &ApprovalRuleRequest{}
+	err = json.Unmarshal(bodyByte, ruleReq)
+	if err != nil {
+		llog.Errorf("got %s", err.Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 400, "malformed json body", err.Error(), 0)
+		return
+	}
+	ruleReq.Creator = requestCreator(r, ruleReq.Creator)
+
+	rule, err := ApprovalMgr.CreateApprovalRule(r.Context(), &ApprovalRule{
+		Description: ruleReq.Description,
+		MinAmount:   ruleReq.MinAmount,
+		Coa:         ruleReq.Coa,
+	}, ruleReq.Creator)
+	if err != nil {
+		llog.Errorf("error while calling ApprovalMgr.CreateApprovalRule. got : %s", err.Error())
+		approvalErrorResponse(w, r, err)
+		return
+	}
+	helpers.HTTPResponseBuilder(r.Context(), w, r, 200, "approval rule "+rule.RuleID, rule, 0)
+}
+
+// ListApprovalRules lists all approval rules
+func ListApprovalRules(w http.ResponseWriter, r *http.Request) {
+	requestID := r.Context().Value(contextkeys.XRequestID).(string)
+	llog := restLog.WithField("RequestID", requestID).WithField("function", "ListApprovalRules")
+	if r.Context().Err() != nil {
+		llog.Errorf("context is canceled : %s", r.Context().Err().Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 500, "request is canceled", "request is canceled", 0)
+		return
+	}
+	if ApprovalMgr == nil {
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 501, "not implemented", "approval manager is not available", 0)
+		return
+	}
+
+	rules, err := ApprovalMgr.ListApprovalRules(r.Context())
+	if err != nil {
+		llog.Errorf("error while calling ApprovalMgr.ListApprovalRules. got : %s", err.Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 500, "backend error", err.Error(), 2)
+		return
+	}
+	helpers.HTTPResponseBuilder(r.Context(), w, r, 200, "OK", rules, 0)
+}
+
+// DeleteApprovalRule removes an approval rule
+func DeleteApprovalRule(w http.ResponseWriter, r *http.Request) {
+	requestID := r.Context().Value(contextkeys.XRequestID).(string)
+	llog := restLog.WithField("RequestID", requestID).WithField("function", "DeleteApprovalRule")
+	if r.Context().Err() != nil {
+		llog.Errorf("context is canceled : %s", r.Context().Err().Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 500, "request is canceled", "request is canceled", 0)
+		return
+	}
+	if ApprovalMgr == nil {
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 501, "not implemented", "approval manager is not available", 0)
+		return
+	}
+
+	m, err := helpers.ParsePathParams("/api/v1/approval-rules/{RuleID}", r.URL.Path)
+	if err != nil {
+		llog.Errorf("error while processing path template /api/v1/approval-rules/{RuleID}. got : %s", err.Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 404, "path not found", "path not found", 1)
+		return
+	}
+
+	if rules, err := ApprovalMgr.ListApprovalRules(r.Context()); err == nil {
+		for _, before := range rules {
+			if before.RuleID == m["RuleID"] {
+				middlewares.AuditSnapshot(r.Context(), before, nil)
+			}
+		}
+	}
+	err = ApprovalMgr.DeleteApprovalRule(r.Context(), m["RuleID"])
+	if err != nil {
+		llog.Errorf("error while calling ApprovalMgr.DeleteApprovalRule. got : %s", err.Error())
+		approvalErrorResponse(w, r, err)
+		return
+	}
+	helpers.HTTPResponseBuilder(r.Context(), w, r, 200, "approval rule "+m["RuleID"]+" deleted", m["RuleID"], 0)
+}
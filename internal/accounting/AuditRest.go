@@ -0,0 +1,80 @@
+package accounting
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/hyperjumptech/bookkeeping/internal/connector"
+	"github.com/hyperjumptech/bookkeeping/internal/contextkeys"
+	"github.com/hyperjumptech/bookkeeping/internal/helpers"
+)
+
+// PaginatedAuditLogsResponse is the audit log response paginated
+type PaginatedAuditLogsResponse struct {
+	AuditLogs  []*AuditLogEntry `json:"audit_logs"`
+	Pagination *PageResultBody  `json:"pagination"`
+}
+
+// ListAuditLogs lists the audit records of the write requests, latest first.
+// The records can be filtered by principal, request_id, method, endpoint (the route template), outcome
+// and by the from (inclusive) and to (exclusive) RFC3339 times.
+func ListAuditLogs(w http.ResponseWriter, r *http.Request) {
+	requestID := r.Context().Value(contextkeys.XRequestID).(string)
+	llog := restLog.WithField("RequestID", requestID).WithField("function", "ListAuditLogs")
+	if r.Context().Err() != nil {
+		llog.Errorf("context is canceled : %s", r.Context().Err().Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 500, "request is canceled", "request is canceled", 0)
+		return
+	}
+	if AuditMgr == nil {
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 501, "not implemented", "audit manager is not available", 0)
+		return
+	}
+
+	pageRequest, msg := pageRequestFromQuery(r)
+	if len(msg) > 0 {
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 400, "invalid request", msg, 0)
+		return
+	}
+	query := r.URL.Query()
+	filter := &AuditLogFilter{
+		Principal: query.Get("principal"),
+		RequestID: query.Get("request_id"),
+		Method:    strings.ToUpper(query.Get("method")),
+		Endpoint:  query.Get("endpoint"),
+		Outcome:   strings.ToUpper(query.Get("outcome")),
+	}
+	switch filter.Outcome {
+	case "", connector.AuditOutcomeSuccess, connector.AuditOutcomeFailure:
+	default:
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 400, "invalid request", "outcome must be SUCCESS or FAILURE", 0)
+		return
+	}
+	var err error
+	if from := query.Get("from"); len(from) > 0 {
+		filter.From, err = time.Parse(time.RFC3339, from)
+		if err != nil {
+			helpers.HTTPResponseBuilder(r.Context(), w, r, 400, "invalid request", "from must be in RFC3339 format", 0)
+			return
+		}
+	}
+	if to := query.Get("to"); len(to) > 0 {
+		filter.To, err = time.Parse(time.RFC3339, to)
+		if err != nil {
+			helpers.HTTPResponseBuilder(r.Context(), w, r, 400, "invalid request", "to must be in RFC3339 format", 0)
+			return
+		}
+	}
+
+	pr, logs, err := AuditMgr.ListAuditLogs(r.Context(), filter, pageRequest)
+	if err != nil {
+		llog.Errorf("error while calling AuditMgr.ListAuditLogs. got : %s", err.Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 500, "backend error", err.Error(), 2)
+		return
+	}
+	helpers.HTTPResponseBuilder(r.Context(), w, r, 200, "OK", &PaginatedAuditLogsResponse{
+		AuditLogs:  logs,
+		Pagination: FromAccorePageResult(pr),
+	}, 0)
+}
@@ -0,0 +1,36 @@
+package accounting
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hyperjumptech/bookkeeping/internal/connector"
+)
+
+// encodeCursor returns the opaque cursor of the position after the record at the time with the id.
+// Clients must not rely on its content, it only has to be handed back to list the next page.
+func encodeCursor(t time.Time, id string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(t.UTC().Format(time.RFC3339Nano) + " " + id))
+}
+
+// decodeCursor returns the position encoded in the cursor, nil if the cursor is empty.
+func decodeCursor(cursor string) (*connector.ListCursor, error) {
+	if len(cursor) == 0 {
+		return nil, nil
+	}
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, fmt.Errorf("%w : %q", ErrInvalidCursor, cursor)
+	}
+	t, id, ok := strings.Cut(string(b), " ")
+	if !ok || len(id) == 0 {
+		return nil, fmt.Errorf("%w : %q", ErrInvalidCursor, cursor)
+	}
+	pos, err := time.Parse(time.RFC3339Nano, t)
+	if err != nil {
+		return nil, fmt.Errorf("%w : %q", ErrInvalidCursor, cursor)
+	}
+	return &connector.ListCursor{Time: pos, ID: id}, nil
+}
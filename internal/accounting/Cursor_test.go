@@ -0,0 +1,34 @@
+package accounting
+
+import (
+	"encoding/base64"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCursor(t *testing.T) {
+	pos := time.Date(2024, 3, 1, 10, 30, 15, 0, time.FixedZone("WIB", 7*3600))
+	cursor := encodeCursor(pos, "TRX 0001")
+	after, err := decodeCursor(cursor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !after.Time.Equal(pos) || after.ID != "TRX 0001" {
+		t.Errorf("expecting %s TRX 0001, got %s %s", pos, after.Time, after.ID)
+	}
+
+	if after, err := decodeCursor(""); after != nil || err != nil {
+		t.Errorf("expecting no position for the empty cursor, got %v %v", after, err)
+	}
+	for _, cursor := range []string{
+		"not base64!",
+		base64.RawURLEncoding.EncodeToString([]byte("2024-03-01T10:30:15Z")),
+		base64.RawURLEncoding.EncodeToString([]byte("2024-03-01T10:30:15Z ")),
+		base64.RawURLEncoding.EncodeToString([]byte("yesterday TRX0001")),
+	} {
+		if _, err := decodeCursor(cursor); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("%q : expecting ErrInvalidCursor, got %v", cursor, err)
+		}
+	}
+}
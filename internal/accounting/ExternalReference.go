@@ -0,0 +1,48 @@
+package accounting
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/hyperjumptech/acccore"
+)
+
+var externalReferenceRegex = regexp.MustCompile(`^[a-zA-Z0-9_.:-]{1,64}$`)
+
+// ExternalReferenceHolder is a journal or an account that carries the reference its creator knows it by,
+// eg. the order id or the customer id in the client's own system.
+type ExternalReferenceHolder interface {
+	GetExternalReference() string
+}
+
+// GetExternalReference returns the external reference of the journal
+func (journal *MetadataJournal) GetExternalReference() string {
+	return journal.ExternalReference
+}
+
+// ReferencedAccount is an account with an external reference
+type ReferencedAccount struct {
+	acccore.BaseAccount
+	ExternalReference string `json:"external_reference,omitempty"`
+}
+
+// GetExternalReference returns the external reference of the account
+func (account *ReferencedAccount) GetExternalReference() string {
+	return account.ExternalReference
+}
+
+// externalReferenceOf returns the external reference of the journal or account, empty if it does not carry one
+func externalReferenceOf(x interface{}) string {
+	if holder, ok := x.(ExternalReferenceHolder); ok {
+		return holder.GetExternalReference()
+	}
+	return ""
+}
+
+// validateExternalReference make sure the reference is empty, or 1 to 64 letters, digits, '_', '.', ':' or '-'
+func validateExternalReference(reference string) error {
+	if len(reference) > 0 && !externalReferenceRegex.MatchString(reference) {
+		return fmt.Errorf("%w : %q", ErrInvalidExternalReference, reference)
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package accounting
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/hyperjumptech/acccore"
+)
+
+func TestValidateExternalReference(t *testing.T) {
+	testData := []struct {
+		reference string
+		valid     bool
+	}{
+		{"", true},
+		{"ORD-2024:0001", true},
+		{"cust_42.wallet", true},
+		{strings.Repeat("r", 64), true},
+		{strings.Repeat("r", 65), false},
+		{"ORD 1", false},
+		{"ORD/1", false},
+	}
+	for _, td := range testData {
+		err := validateExternalReference(td.reference)
+		if td.valid && err != nil {
+			t.Errorf("%q : expecting valid reference, got %s", td.reference, err.Error())
+		}
+		if !td.valid && !errors.Is(err, ErrInvalidExternalReference) {
+			t.Errorf("%q : expecting ErrInvalidExternalReference, got %v", td.reference, err)
+		}
+	}
+}
+
+func TestExternalReferenceOf(t *testing.T) {
+	journal := NewJournalFromRequest(&CreateJournalRequest{
+		Description:       "purchase",
+		Creator:           "aCreator",
+		ExternalReference: "ORD-1",
+		Transactions: []*TransactionRequest{
+			{AccountNumber: "CUSTOMER", Alignment: "DEBIT", Amount: 100},
+			{AccountNumber: "MERCHANT", Alignment: "CREDIT", Amount: 100},
+		},
+	}, &acccore.RandomGenUniqueIDGenerator{Length: 16, UpperAlpha: true, Numeric: true})
+	if externalReferenceOf(journal) != "ORD-1" {
+		t.Errorf("expecting the journal reference, got %q", externalReferenceOf(journal))
+	}
+	if externalReferenceOf(&ReferencedAccount{ExternalReference: "CUST-1"}) != "CUST-1" {
+		t.Errorf("expecting the account reference")
+	}
+	if externalReferenceOf(&acccore.BaseAccount{}) != "" || externalReferenceOf(&acccore.BaseJournal{}) != "" {
+		t.Errorf("expecting no reference on a base account or journal")
+	}
+}
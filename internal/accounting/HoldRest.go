@@ -0,0 +1,253 @@
+package accounting
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/hyperjumptech/acccore"
+	"github.com/hyperjumptech/bookkeeping/internal/config"
+	"github.com/hyperjumptech/bookkeeping/internal/contextkeys"
+	"github.com/hyperjumptech/bookkeeping/internal/helpers"
+	"github.com/hyperjumptech/bookkeeping/internal/middlewares"
+)
+
+// PlaceHoldRequest is the structure of request body for placing a hold
+type PlaceHoldRequest struct {
+	AccountNumber string `json:"account_number"`
+	Amount        int64  `json:"amount"`
+	Description   string `json:"description"`
+	// ExpiresAt is optional, when not specified the hold expires after hold.expiry.default.minute configuration
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	Creator   string     `json:"creator"`
+}
+
+// CaptureHoldRequest is the structure of request body for capturing a hold
+type CaptureHoldRequest struct {
+	CounterAccountNumber string `json:"counter_account_number"`
+	// Amount is optional, when not specified the whole held amount is captured
+	Amount      int64  `json:"amount"`
+	Description string `json:"description"`
+	Creator     string `json:"creator"`
+}
+
+// VoidHoldRequest is the structure of request body for voiding a hold
+type VoidHoldRequest struct {
+	Creator string `json:"creator"`
+}
+
+// holdErrorResponse writes the response for errors returned by HoldMgr
+func holdErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, ErrHoldNotFound):
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 404, "hold not found", err.Error(), 3)
+	case errors.Is(err, acccore.ErrAccountIsNotPersisted):
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 404, "account number not found", err.Error(), 3)
+	default:
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 400, "hold rejected", err.Error(), 0)
+	}
+}
+
+// PlaceHold reserves an account fund
+func PlaceHold(w http.ResponseWriter, r *http.Request) {
+	requestID := r.Context().Value(contextkeys.XRequestID).(string)
+	llog := restLog.WithField("RequestID", requestID).WithField("function", "PlaceHold")
+	if r.Context().Err() != nil {
+		llog.Errorf("context is canceled : %s", r.Context().Err().Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 500, "request is canceled", "request is canceled", 0)
+		return
+	}
+	if HoldMgr == nil {
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 501, "not implemented", "hold manager is not available", 0)
+		return
+	}
+
+	bodyByte, err := io.ReadAll(r.Body)
+	if err != nil {
+		llog.Errorf("got %s", err.Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 500, "error reading body", err.Error(), 0)
+		return
+	}
+	holdReq := &PlaceHoldRequest{}
+	err = json.Unmarshal(bodyByte, holdReq)
+	if err != nil {
+		llog.Errorf("got %s", err.Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 400, "malformed json body", err.Error(), 0)
+		return
+	}
+	holdReq.Creator = requestCreator(r, holdReq.Creator)
+
+	expiresAt := time.Now().Add(time.Duration(config.GetInt("hold.expiry.default.minute")) * time.Minute)
+	if holdReq.ExpiresAt != nil {
+		expiresAt = *holdReq.ExpiresAt
+	}
+
+	hold, err := HoldMgr.PlaceHold(r.Context(), holdReq.AccountNumber, holdReq.Amount, holdReq.Description, expiresAt, holdReq.Creator)
+	if err != nil {
+		llog.Errorf("error while calling HoldMgr.PlaceHold. got : %s", err.Error())
+		holdErrorResponse(w, r, err)
+		return
+	}
+	helpers.HTTPResponseBuilder(r.Context(), w, r, 200, "hold "+hold.HoldID, hold, 0)
+}
+
+// GetHold fetches a hold from hold ID
+func GetHold(w http.ResponseWriter, r *http.Request) {
+	requestID := r.Context().Value(contextkeys.XRequestID).(string)
+	llog := restLog.WithField("RequestID", requestID).WithField("function", "GetHold")
+	if r.Context().Err() != nil {
+		llog.Errorf("context is canceled : %s", r.Context().Err().Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 500, "request is canceled", "request is canceled", 0)
+		return
+	}
+	if HoldMgr == nil {
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 501, "not implemented", "hold manager is not available", 0)
+		return
+	}
+
+	m, err := helpers.ParsePathParams("/api/v1/holds/{HoldID}", r.URL.Path)
+	if err != nil {
+		llog.Errorf("error while processing path template /api/v1/holds/{HoldID}. got : %s", err.Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 404, "path not found", "path not found", 1)
+		return
+	}
+
+	hold, err := HoldMgr.GetHold(r.Context(), m["HoldID"])
+	if err != nil {
+		llog.Errorf("error while calling HoldMgr.GetHold. got : %s", err.Error())
+		if errors.Is(err, ErrHoldNotFound) {
+			holdErrorResponse(w, r, err)
+			return
+		}
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 500, "backend error", err.Error(), 2)
+		return
+	}
+	helpers.HTTPResponseBuilder(r.Context(), w, r, 200, "hold "+hold.HoldID, hold, 0)
+}
+
+// CaptureHold turns a hold into a journal
+func CaptureHold(w http.ResponseWriter, r *http.Request) {
+	requestID := r.Context().Value(contextkeys.XRequestID).(string)
+	llog := restLog.WithField("RequestID", requestID).WithField("function", "CaptureHold")
+	if r.Context().Err() != nil {
+		llog.Errorf("context is canceled : %s", r.Context().Err().Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 500, "request is canceled", "request is canceled", 0)
+		return
+	}
+	if HoldMgr == nil {
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 501, "not implemented", "hold manager is not available", 0)
+		return
+	}
+
+	m, err := helpers.ParsePathParams("/api/v1/holds/{HoldID}/capture", r.URL.Path)
+	if err != nil {
+		llog.Errorf("error while processing path template /api/v1/holds/{HoldID}/capture. got : %s", err.Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 404, "path not found", "path not found", 1)
+		return
+	}
+
+	bodyByte, err := io.ReadAll(r.Body)
+	if err != nil {
+		llog.Errorf("got %s", err.Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 500, "error reading body", err.Error(), 0)
+		return
+	}
+	captureReq := &CaptureHoldRequest{}
+	err = json.Unmarshal(bodyByte, captureReq)
+	if err != nil {
+		llog.Errorf("got %s", err.Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 400, "malformed json body", err.Error(), 0)
+		return
+	}
+	captureReq.Creator = requestCreator(r, captureReq.Creator)
+
+	nctx := context.WithValue(r.Context(), contextkeys.UserIDContextKey, captureReq.Creator)
+	hold, err := HoldMgr.CaptureHold(nctx, m["HoldID"], captureReq.CounterAccountNumber, captureReq.Amount, captureReq.Description, captureReq.Creator)
+	if err != nil {
+		llog.Errorf("error while calling HoldMgr.CaptureHold. got : %s", err.Error())
+		holdErrorResponse(w, r, err)
+		return
+	}
+	helpers.HTTPResponseBuilder(r.Context(), w, r, 200, "hold "+hold.HoldID, hold, 0)
+}
+
+// VoidHold releases a hold
+func VoidHold(w http.ResponseWriter, r *http.Request) {
+	requestID := r.Context().Value(contextkeys.XRequestID).(string)
+	llog := restLog.WithField("RequestID", requestID).WithField("function", "VoidHold")
+	if r.Context().Err() != nil {
+		llog.Errorf("context is canceled : %s", r.Context().Err().Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 500, "request is canceled", "request is canceled", 0)
+		return
+	}
+	if HoldMgr == nil {
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 501, "not implemented", "hold manager is not available", 0)
+		return
+	}
+
+	m, err := helpers.ParsePathParams("/api/v1/holds/{HoldID}/void", r.URL.Path)
+	if err != nil {
+		llog.Errorf("error while processing path template /api/v1/holds/{HoldID}/void. got : %s", err.Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 404, "path not found", "path not found", 1)
+		return
+	}
+
+	bodyByte, err := io.ReadAll(r.Body)
+	if err != nil {
+		llog.Errorf("got %s", err.Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 500, "error reading body", err.Error(), 0)
+		return
+	}
+	voidReq := &VoidHoldRequest{}
+	err = json.Unmarshal(bodyByte, voidReq)
+	if err != nil {
+		llog.Errorf("got %s", err.Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 400, "malformed json body", err.Error(), 0)
+		return
+	}
+	voidReq.Creator = requestCreator(r, voidReq.Creator)
+
+	if before, err := HoldMgr.GetHold(r.Context(), m["HoldID"]); err == nil {
+		middlewares.AuditSnapshot(r.Context(), before, nil)
+	}
+	hold, err := HoldMgr.VoidHold(r.Context(), m["HoldID"], voidReq.Creator)
+	if err != nil {
+		llog.Errorf("error while calling HoldMgr.VoidHold. got : %s", err.Error())
+		holdErrorResponse(w, r, err)
+		return
+	}
+	helpers.HTTPResponseBuilder(r.Context(), w, r, 200, "hold "+hold.HoldID, hold, 0)
+}
+
+// ListHoldsByAccount lists the active holds on an account
+func ListHoldsByAccount(w http.ResponseWriter, r *http.Request) {
+	requestID := r.Context().Value(contextkeys.XRequestID).(string)
+	llog := restLog.WithField("RequestID", requestID).WithField("function", "ListHoldsByAccount")
+	if r.Context().Err() != nil {
+		llog.Errorf("context is canceled : %s", r.Context().Err().Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 500, "request is canceled", "request is canceled", 0)
+		return
+	}
+	if HoldMgr == nil {
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 501, "not implemented", "hold manager is not available", 0)
+		return
+	}
+
+	m, err := helpers.ParsePathParams("/api/v1/accounts/{AccountNumber}/holds", r.URL.Path)
+	if err != nil {
+		llog.Errorf("error while processing path template /api/v1/accounts/{AccountNumber}/holds. got : %s", err.Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 404, "path not found", "path not found", 1)
+		return
+	}
+
+	holds, err := HoldMgr.ListActiveHolds(r.Context(), m["AccountNumber"])
+	if err != nil {
+		llog.Errorf("error while calling HoldMgr.ListActiveHolds. got : %s", err.Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 500, "backend error", err.Error(), 2)
+		return
+	}
+	helpers.HTTPResponseBuilder(r.Context(), w, r, 200, "holds of account "+m["AccountNumber"], holds, 0)
+}
@@ -0,0 +1,118 @@
+package accounting
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/hyperjumptech/bookkeeping/internal/contextkeys"
+	"github.com/hyperjumptech/bookkeeping/internal/helpers"
+)
+
+const (
+	// IdempotencyKeyHeader is the request header carrying the idempotency key, it takes precedence over client_reference
+	IdempotencyKeyHeader = "Idempotency-Key"
+
+	// IdempotentReplayedHeader is set to true on a response that is replayed from the original request
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+)
+
+// idempotencyResponseRecorder keeps the response of the handled request, it is written to the client unless the request
+// turns out to repeat a request processed concurrently.
+type idempotencyResponseRecorder struct {
+	http.ResponseWriter
+	code int
+	body bytes.Buffer
+}
+
+// WriteHeader implements http.ResponseWriter
+func (rec *idempotencyResponseRecorder) WriteHeader(code int) {
+	rec.code = code
+}
+
+// Write implements http.ResponseWriter
+func (rec *idempotencyResponseRecorder) Write(b []byte) (int, error) {
+	return rec.body.Write(b)
+}
+
+// requestDigest returns the SHA256 hex digest of the JSON encoded request payload
+func requestDigest(payload interface{}) (string, error) {
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// idempotentRequest calls handle only once for all requests of the client within the scope carrying the same idempotency key,
+// taken from the Idempotency-Key header or else the clientReference. The handled request claims the key, which is stored in the
+// same database transaction as the journal, pending journal or account the request creates. A repeated request is answered
+// by replay out of the stored key, a repeated request with a different payload is rejected. Failed requests store nothing,
+// so they can be retried. The payload must not contain the client reference, so the header and the client_reference are
+// interchangeable.
+func idempotentRequest(w http.ResponseWriter, r *http.Request, scope, clientReference, client string, payload interface{},
+	handle func(w http.ResponseWriter, r *http.Request), replay func(w http.ResponseWriter, r *http.Request, original *IdempotentRequest)) {
+	requestID := r.Context().Value(contextkeys.XRequestID).(string)
+	llog := restLog.WithField("RequestID", requestID).WithField("function", "idempotentRequest")
+
+	key := r.Header.Get(IdempotencyKeyHeader)
+	if len(key) == 0 {
+		key = clientReference
+	}
+	if len(key) == 0 {
+		handle(w, r)
+		return
+	}
+	if IdempotencyMgr == nil {
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 501, "not implemented", "idempotency manager is not available", 0)
+		return
+	}
+
+	digest, err := requestDigest(payload)
+	if err != nil {
+		llog.Errorf("error while calculating request digest. got : %s", err.Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 500, "backend error", err.Error(), 2)
+		return
+	}
+	original, err := IdempotencyMgr.GetIdempotentRequest(r.Context(), client, scope, key)
+	if errors.Is(err, ErrInvalidIdempotencyKey) {
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 400, "request rejected", err.Error(), 0)
+		return
+	}
+	if err != nil {
+		llog.Errorf("error while calling IdempotencyMgr.GetIdempotentRequest. got : %s", err.Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 500, "backend error", err.Error(), 2)
+		return
+	}
+
+	if original == nil {
+		claim := &IdempotencyClaim{ClientID: client, Scope: scope, Key: key, Digest: digest}
+		rec := &idempotencyResponseRecorder{ResponseWriter: w, code: http.StatusOK}
+		handle(rec, r.WithContext(withIdempotencyClaim(r.Context(), claim)))
+		if !claim.taken {
+			w.WriteHeader(rec.code)
+			w.Write(rec.body.Bytes())
+			return
+		}
+		// the same request made concurrently have stored the key first, this one created nothing.
+		original, err = IdempotencyMgr.GetIdempotentRequest(r.Context(), client, scope, key)
+		if err != nil || original == nil {
+			llog.Errorf("error while reading idempotency key %s of %s taken by another request. got : %v", key, scope, err)
+			helpers.HTTPResponseBuilder(r.Context(), w, r, 500, "backend error", "idempotency key is taken by another request", 2)
+			return
+		}
+	}
+
+	if original.Digest != digest {
+		llog.Warnf("idempotency key %s of %s is reused with different payload", key, scope)
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 422, "idempotency key conflict", ErrIdempotencyKeyConflict.Error(), 0)
+		return
+	}
+	llog.Infof("replaying the original response of idempotency key %s of %s", key, scope)
+	w.Header().Set(IdempotentReplayedHeader, "true")
+	replay(w, r, original)
+}
@@ -0,0 +1,127 @@
+package accounting
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hyperjumptech/bookkeeping/internal/connector"
+	"github.com/hyperjumptech/bookkeeping/internal/contextkeys"
+	"github.com/hyperjumptech/bookkeeping/internal/helpers"
+)
+
+// memoryIdempotencyManager is an in memory IdempotencyManager for testing the rest flow
+type memoryIdempotencyManager struct {
+	requests map[string]*IdempotentRequest
+}
+
+func (im *memoryIdempotencyManager) GetIdempotentRequest(ctx context.Context, clientID, scope, key string) (*IdempotentRequest, error) {
+	if len(key) > 64 {
+		return nil, ErrInvalidIdempotencyKey
+	}
+	return im.requests[clientID+scope+key], nil
+}
+
+// claim stores the key claimed in the context the way claimIdempotencyKey does along with the created resource
+func (im *memoryIdempotencyManager) claim(ctx context.Context, resourceID string) error {
+	claim, ok := ctx.Value(contextkeys.IdempotencyClaimContextKey).(*IdempotencyClaim)
+	if !ok {
+		return nil
+	}
+	if _, ok := im.requests[claim.ClientID+claim.Scope+claim.Key]; ok {
+		claim.taken = true
+		return ErrIdempotencyKeyTaken
+	}
+	im.requests[claim.ClientID+claim.Scope+claim.Key] = &IdempotentRequest{Digest: claim.Digest, Code: 200, ResourceID: resourceID}
+	return nil
+}
+
+func TestIdempotentRequest(t *testing.T) {
+	saved := IdempotencyMgr
+	defer func() { IdempotencyMgr = saved }()
+	manager := &memoryIdempotencyManager{requests: make(map[string]*IdempotentRequest)}
+	IdempotencyMgr = manager
+
+	handled := 0
+	call := func(key, client string, payload *CreateJournalRequest, code int) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/api/v1/journals", nil)
+		r = r.WithContext(context.WithValue(r.Context(), contextkeys.XRequestID, "1234567890"))
+		if len(key) > 0 {
+			r.Header.Set(IdempotencyKeyHeader, key)
+		}
+		w := httptest.NewRecorder()
+		idempotentRequest(w, r, connector.IdempotencyScopeJournal, payload.ClientReference, client, payload, func(w http.ResponseWriter, r *http.Request) {
+			handled++
+			resourceID := fmt.Sprintf("J%d", handled)
+			if code == 200 {
+				if err := manager.claim(r.Context(), resourceID); err != nil {
+					helpers.HTTPResponseBuilder(r.Context(), w, r, 400, "rejected", err.Error(), 0)
+					return
+				}
+			}
+			helpers.HTTPResponseBuilder(r.Context(), w, r, code, "OK", resourceID, 0)
+		}, func(w http.ResponseWriter, r *http.Request, original *IdempotentRequest) {
+			helpers.HTTPResponseBuilder(r.Context(), w, r, original.Code, "OK", original.ResourceID, 0)
+		})
+		return w
+	}
+	payment := &CreateJournalRequest{Description: "payment", Creator: "tester"}
+	refund := &CreateJournalRequest{Description: "refund", Creator: "tester"}
+
+	first := call("key-1", "client-1", payment, 200)
+	second := call("key-1", "client-1", payment, 200)
+	if handled != 1 {
+		t.Errorf("expecting repeated request not handled again, handled %d times", handled)
+	}
+	if second.Code != 200 || second.Body.String() != first.Body.String() || second.Header().Get(IdempotentReplayedHeader) != "true" {
+		t.Errorf("expecting original response replayed, got %d %s", second.Code, second.Body.String())
+	}
+	if w := call("key-1", "client-1", refund, 200); w.Code != 422 || handled != 1 {
+		t.Errorf("expecting conflicting payload rejected with 422, got %d", w.Code)
+	}
+
+	// the keys of a client do not collide with the keys of another
+	if w := call("key-1", "client-2", refund, 200); w.Code != 200 || handled != 2 || len(w.Header().Get(IdempotentReplayedHeader)) > 0 {
+		t.Errorf("expecting the same key of another client handled, got %d handled %d times", w.Code, handled)
+	}
+
+	// failed requests store nothing and can be retried
+	call("key-2", "client-1", refund, 400)
+	if w := call("key-2", "client-1", refund, 200); w.Code != 200 || handled != 4 {
+		t.Errorf("expecting failed request retried, got %d handled %d times", w.Code, handled)
+	}
+
+	// a request losing the key to the same request made concurrently answers as the latter
+	manager.requests["client-1"+connector.IdempotencyScopeJournal+"key-3"] = &IdempotentRequest{Digest: mustDigest(t, payment), Code: 200, ResourceID: "J-CONCURRENT"}
+	r := httptest.NewRequest(http.MethodPost, "/api/v1/journals", nil)
+	r = r.WithContext(context.WithValue(r.Context(), contextkeys.XRequestID, "1234567890"))
+	r.Header.Set(IdempotencyKeyHeader, "key-3")
+	w := httptest.NewRecorder()
+	idempotentRequest(w, r, connector.IdempotencyScopeJournal, "", "client-1", payment, func(w http.ResponseWriter, r *http.Request) {
+		// the key is stored after this request looked it up
+		r.Context().Value(contextkeys.IdempotencyClaimContextKey).(*IdempotencyClaim).taken = true
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 400, "rejected", ErrIdempotencyKeyTaken.Error(), 0)
+	}, func(w http.ResponseWriter, r *http.Request, original *IdempotentRequest) {
+		helpers.HTTPResponseBuilder(r.Context(), w, r, original.Code, "OK", original.ResourceID, 0)
+	})
+	if w.Code != 200 || w.Header().Get(IdempotentReplayedHeader) != "true" {
+		t.Errorf("expecting the concurrent request response replayed, got %d %s", w.Code, w.Body.String())
+	}
+
+	// without key every request is handled
+	call("", "client-1", payment, 200)
+	call("", "client-1", payment, 200)
+	if handled != 6 {
+		t.Errorf("expecting requests without key always handled, handled %d times", handled)
+	}
+}
+
+func mustDigest(t *testing.T, payload interface{}) string {
+	digest, err := requestDigest(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return digest
+}
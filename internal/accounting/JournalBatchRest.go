@@ -0,0 +1,116 @@
+package accounting
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/hyperjumptech/acccore"
+	"github.com/hyperjumptech/bookkeeping/internal/config"
+	"github.com/hyperjumptech/bookkeeping/internal/contextkeys"
+	"github.com/hyperjumptech/bookkeeping/internal/helpers"
+)
+
+// CreateJournalBatchRequest is the structure of request body for creating several journals atomically
+type CreateJournalBatchRequest struct {
+	Journals []*CreateJournalRequest `json:"journals"`
+}
+
+// JournalBatchItemError is the reason a journal in the batch is rejected
+type JournalBatchItemError struct {
+	Index int    `json:"index"`
+	Error string `json:"error"`
+}
+
+// CreateJournalBatch validates and persists all journals in the request body in a single database transaction.
+// Either all the journals are persisted, or none of them.
+func CreateJournalBatch(w http.ResponseWriter, r *http.Request) {
+	requestID := r.Context().Value(contextkeys.XRequestID).(string)
+	llog := restLog.WithField("RequestID", requestID).WithField("function", "CreateJournalBatch")
+	if r.Context().Err() != nil {
+		llog.Errorf("context is canceled : %s", r.Context().Err().Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 500, "request is canceled", "request is canceled", 0)
+		return
+	}
+	if JournalBatchMgr == nil {
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 501, "not implemented", "journal batch manager is not available", 0)
+		return
+	}
+
+	bodyByte, err := io.ReadAll(r.Body)
+	if err != nil {
+		llog.Errorf("got %s", err.Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 500, "error reading body", err.Error(), 0)
+		return
+	}
+	batchReq := &CreateJournalBatchRequest{}
+	err = json.Unmarshal(bodyByte, batchReq)
+	if err != nil {
+		llog.Errorf("got %s", err.Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 400, "malformed json body", err.Error(), 0)
+		return
+	}
+	for _, journalReq := range batchReq.Journals {
+		if journalReq != nil {
+			journalReq.Creator = requestCreator(r, journalReq.Creator)
+		}
+	}
+	if len(batchReq.Journals) == 0 {
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 400, "request rejected", "batch contains no journal", 0)
+		return
+	}
+	if maxSize := config.GetInt("journal.batch.max.size"); len(batchReq.Journals) > maxSize {
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 400, "request rejected", fmt.Sprintf("batch contains more than %d journals", maxSize), 0)
+		return
+	}
+
+	itemErrors := make([]*JournalBatchItemError, 0)
+	journals := make([]acccore.Journal, len(batchReq.Journals))
+	journalIDs := make([]string, len(batchReq.Journals))
+	for idx, reqBod := range batchReq.Journals {
+		if reqBod == nil {
+			itemErrors = append(itemErrors, &JournalBatchItemError{Index: idx, Error: acccore.ErrJournalNil.Error()})
+			continue
+		}
+		// a journal matching an approval rule have to wait for its reviewer, it can not be part of an atomic batch.
+		if ApprovalMgr != nil {
+			needApproval, err := ApprovalMgr.RequiresApproval(r.Context(), reqBod)
+			if err != nil {
+				llog.Errorf("error while calling ApprovalMgr.RequiresApproval. got : %s", err.Error())
+				helpers.HTTPResponseBuilder(r.Context(), w, r, 500, "backend error", err.Error(), 2)
+				return
+			}
+			if needApproval {
+				itemErrors = append(itemErrors, &JournalBatchItemError{Index: idx, Error: ErrJournalRequiresApproval.Error()})
+				continue
+			}
+		}
+		journal := NewJournalFromRequest(reqBod, UniqueIDGenerator)
+		journals[idx] = journal
+		journalIDs[idx] = journal.JournalID
+	}
+	if len(itemErrors) > 0 {
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 400, "journal batch rejected, nothing is persisted", itemErrors, 0)
+		return
+	}
+
+	err = JournalBatchMgr.PersistJournals(r.Context(), journals)
+	if err != nil {
+		llog.Errorf("error while calling JournalBatchMgr.PersistJournals. got : %s", err.Error())
+		batchErr := &JournalBatchError{}
+		if errors.As(err, &batchErr) {
+			for idx := range journals {
+				if itemErr, ok := batchErr.Errors[idx]; ok {
+					itemErrors = append(itemErrors, &JournalBatchItemError{Index: idx, Error: itemErr.Error()})
+				}
+			}
+			helpers.HTTPResponseBuilder(r.Context(), w, r, 400, "journal batch rejected, nothing is persisted", itemErrors, 0)
+			return
+		}
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 500, "backend error", err.Error(), 2)
+		return
+	}
+	helpers.HTTPResponseBuilder(r.Context(), w, r, 200, "OK", journalIDs, 0)
+}
@@ -0,0 +1,61 @@
+package accounting
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/hyperjumptech/bookkeeping/internal/contextkeys"
+	"github.com/hyperjumptech/bookkeeping/internal/helpers"
+)
+
+// SimulateJournal tells if the journal in the request body would be accepted and the projected balance of its accounts,
+// without persisting anything.
+func SimulateJournal(w http.ResponseWriter, r *http.Request) {
+	requestID := r.Context().Value(contextkeys.XRequestID).(string)
+	llog := restLog.WithField("RequestID", requestID).WithField("function", "SimulateJournal")
+	if r.Context().Err() != nil {
+		llog.Errorf("context is canceled : %s", r.Context().Err().Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 500, "request is canceled", "request is canceled", 0)
+		return
+	}
+	if JournalSimulationMgr == nil {
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 501, "not implemented", "journal simulation manager is not available", 0)
+		return
+	}
+
+	bodyByte, err := io.ReadAll(r.Body)
+	if err != nil {
+		llog.Errorf("got %s", err.Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 500, "error reading body", err.Error(), 0)
+		return
+	}
+	reqBod := &CreateJournalRequest{}
+	err = json.Unmarshal(bodyByte, reqBod)
+	if err != nil {
+		llog.Errorf("got %s", err.Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 400, "malformed json body", err.Error(), 0)
+		return
+	}
+	reqBod.Creator = requestCreator(r, reqBod.Creator)
+
+	simulation, err := JournalSimulationMgr.SimulateJournal(r.Context(), NewJournalFromRequest(reqBod, UniqueIDGenerator))
+	if err != nil {
+		llog.Errorf("error while calling JournalSimulationMgr.SimulateJournal. got : %s", err.Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 500, "backend error", err.Error(), 2)
+		return
+	}
+	if simulation.Accepted && ApprovalMgr != nil {
+		simulation.RequiresApproval, err = ApprovalMgr.RequiresApproval(r.Context(), reqBod)
+		if err != nil {
+			llog.Errorf("error while calling ApprovalMgr.RequiresApproval. got : %s", err.Error())
+			helpers.HTTPResponseBuilder(r.Context(), w, r, 500, "backend error", err.Error(), 2)
+			return
+		}
+	}
+	if !simulation.Accepted {
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 200, "journal would be rejected", simulation, 0)
+		return
+	}
+	helpers.HTTPResponseBuilder(r.Context(), w, r, 200, "journal would be accepted", simulation, 0)
+}
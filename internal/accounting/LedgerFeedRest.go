@@ -0,0 +1,55 @@
+package accounting
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/hyperjumptech/bookkeeping/internal/config"
+	"github.com/hyperjumptech/bookkeeping/internal/contextkeys"
+	"github.com/hyperjumptech/bookkeeping/internal/helpers"
+)
+
+// ListLedgerFeed returns the journals committed after the after_sequence query parameter, with their transactions,
+// in commit order. Consumers resume the feed from the last_sequence of the previous response.
+func ListLedgerFeed(w http.ResponseWriter, r *http.Request) {
+	requestID := r.Context().Value(contextkeys.XRequestID).(string)
+	llog := restLog.WithField("RequestID", requestID).WithField("function", "ListLedgerFeed")
+	if r.Context().Err() != nil {
+		llog.Errorf("context is canceled : %s", r.Context().Err().Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 500, "request is canceled", "request is canceled", 0)
+		return
+	}
+	if LedgerFeedMgr == nil {
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 501, "not implemented", "ledger feed manager is not available", 0)
+		return
+	}
+
+	afterSequence := int64(0)
+	if after := r.URL.Query().Get("after_sequence"); len(after) > 0 {
+		var err error
+		afterSequence, err = strconv.ParseInt(after, 10, 64)
+		if err != nil || afterSequence < 0 {
+			helpers.HTTPResponseBuilder(r.Context(), w, r, 400, "invalid request", "after_sequence must be a non negative number", 0)
+			return
+		}
+	}
+	maxLimit := config.GetInt("feed.limit.max")
+	limit := config.GetInt("feed.limit.default")
+	if l := r.URL.Query().Get("limit"); len(l) > 0 {
+		var err error
+		limit, err = strconv.Atoi(l)
+		if err != nil || limit < 1 || limit > maxLimit {
+			helpers.HTTPResponseBuilder(r.Context(), w, r, 400, "invalid request", fmt.Sprintf("limit must be a number between 1 and %d", maxLimit), 0)
+			return
+		}
+	}
+
+	feed, err := LedgerFeedMgr.ListFeed(r.Context(), afterSequence, limit)
+	if err != nil {
+		llog.Errorf("error while calling LedgerFeedMgr.ListFeed. got : %s", err.Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 500, "backend error", err.Error(), 2)
+		return
+	}
+	helpers.HTTPResponseBuilder(r.Context(), w, r, 200, "OK", feed, 0)
+}
@@ -0,0 +1,878 @@
+package accounting
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hyperjumptech/acccore"
+	"github.com/hyperjumptech/bookkeeping/internal/middlewares"
+)
+
+var (
+	// ErrAccountFrozen is returned when a journal tries to post into an account that is frozen for all postings
+	ErrAccountFrozen = errors.New("account is frozen")
+
+	// ErrAccountDebitFrozen is returned when a journal tries to DEBIT an account that is frozen for debit
+	ErrAccountDebitFrozen = errors.New("account is frozen for debit")
+
+	// ErrAccountClosed is returned when a journal tries to post into, or a state change is requested for, a closed account
+	ErrAccountClosed = errors.New("account is closed")
+
+	// ErrAccountBalanceNotZero is returned when closing an account that still have balance
+	ErrAccountBalanceNotZero = errors.New("account balance is not zero")
+
+	// ErrAccountHasActiveHolds is returned when closing an account that still have fund on hold
+	ErrAccountHasActiveHolds = errors.New("account have active holds")
+
+	// ErrAccountMinimumBalance is returned when a journal would bring an account balance below its minimum balance
+	ErrAccountMinimumBalance = errors.New("account balance would fall below its minimum balance")
+
+	// ErrInsufficientBalance is returned when a journal would take the amount on hold, or a hold more than the available balance,
+	// of an account that have neither minimum balance nor overdraft limit
+	ErrInsufficientBalance = errors.New("account available balance is not sufficient")
+
+	// ErrAccountOverdraftLimit is returned when a journal would overdraw an account beyond its overdraft limit
+	ErrAccountOverdraftLimit = errors.New("account balance would exceed its overdraft limit")
+
+	// ErrAccountMaximumBalance is returned when a journal would bring an account balance above its maximum balance
+	ErrAccountMaximumBalance = errors.New("account balance would exceed its maximum balance")
+
+	// ErrInvalidAccountLimits is returned when the account limits to set contradict each other
+	ErrInvalidAccountLimits = errors.New("invalid account limits")
+
+	// ErrHoldNotFound is returned when the hold is not exist
+	ErrHoldNotFound = errors.New("hold not found")
+
+	// ErrHoldNotActive is returned when capturing or voiding a hold that have been captured, voided or expired
+	ErrHoldNotActive = errors.New("hold is not active")
+
+	// ErrHoldExpired is returned when capturing a hold that have expired
+	ErrHoldExpired = errors.New("hold is expired")
+
+	// ErrInvalidHoldAmount is returned when placing a hold with non positive amount, or capturing more than the held amount
+	ErrInvalidHoldAmount = errors.New("invalid hold amount")
+
+	// ErrRecurringJournalNotFound is returned when the recurring journal is not exist
+	ErrRecurringJournalNotFound = errors.New("recurring journal not found")
+
+	// ErrRecurringJournalNotActive is returned when running a recurring journal that is paused
+	ErrRecurringJournalNotActive = errors.New("recurring journal is not active")
+
+	// ErrRecurringJournalRunClaimed is returned when running a recurring journal on a fire time that have been run
+	ErrRecurringJournalRunClaimed = errors.New("recurring journal have been run on this fire time")
+
+	// ErrInvalidCronExpression is returned when the recurring journal schedule is not a valid cron expression
+	ErrInvalidCronExpression = errors.New("invalid cron expression")
+
+	// ErrPendingJournalNotFound is returned when the pending journal is not exist
+	ErrPendingJournalNotFound = errors.New("pending journal not found")
+
+	// ErrPendingJournalNotPending is returned when approving or rejecting a pending journal that have been reviewed
+	ErrPendingJournalNotPending = errors.New("pending journal have been reviewed")
+
+	// ErrJournalRequiresApproval is returned when a journal posted without its own request, by a recurring journal,
+	// a hold capture or a reversal, matches an approval rule
+	ErrJournalRequiresApproval = errors.New("journal requires approval, submit it as pending journal")
+
+	// ErrHoldRequiresApproval is returned when a hold is placed on an account for an amount matching an approval rule,
+	// its capture could never be posted without review
+	ErrHoldRequiresApproval = errors.New("hold matches an approval rule, submit the journal as pending journal instead")
+
+	// ErrSelfApproval is returned when the reviewer of a pending journal is the one who submitted it
+	ErrSelfApproval = errors.New("pending journal can not be reviewed by its submitter")
+
+	// ErrMissingReviewer is returned when approving or rejecting a pending journal without a reviewer
+	ErrMissingReviewer = errors.New("missing reviewer")
+
+	// ErrApprovalRuleNotFound is returned when the approval rule is not exist
+	ErrApprovalRuleNotFound = errors.New("approval rule not found")
+
+	// ErrInvalidApprovalRule is returned when the approval rule minimum amount is negative
+	ErrInvalidApprovalRule = errors.New("invalid approval rule")
+
+	// ErrPostingTemplateNotFound is returned when the posting template is not exist
+	ErrPostingTemplateNotFound = errors.New("posting template not found")
+
+	// ErrInvalidPostingTemplate is returned when saving a malformed posting template
+	ErrInvalidPostingTemplate = errors.New("invalid posting template")
+
+	// ErrInvalidTemplateParameter is returned when the parameters given can not be expanded by the posting template
+	ErrInvalidTemplateParameter = errors.New("invalid posting template parameter")
+
+	// ErrJournalBatchFailed is returned when any journal in a batch can not be persisted, none of the batch is persisted
+	ErrJournalBatchFailed = errors.New("journal batch failed")
+
+	// ErrIdempotencyKeyConflict is returned when an idempotency key is reused with a different request payload
+	ErrIdempotencyKeyConflict = errors.New("idempotency key is used by a different request")
+
+	// ErrIdempotencyKeyTaken is returned when the idempotency key claimed by a request is stored by another request first
+	ErrIdempotencyKeyTaken = errors.New("idempotency key is taken by another request")
+
+	// ErrInvalidReversal is returned when a reversal journal posts into an account that is not in the reversed journal,
+	// in the same alignment as the reversed journal, or a non positive amount
+	ErrInvalidReversal = errors.New("invalid reversal")
+
+	// ErrReversalExceedsOriginal is returned when a reversal journal would reverse more than what is left to reverse of the reversed journal
+	ErrReversalExceedsOriginal = errors.New("reversal exceeds the amount left to reverse")
+
+	// ErrInvalidIdempotencyKey is returned when the idempotency key is longer than 64 characters
+	ErrInvalidIdempotencyKey = errors.New("invalid idempotency key")
+
+	// ErrWebhookSubscriptionNotFound is returned when the webhook subscription is not exist
+	ErrWebhookSubscriptionNotFound = errors.New("webhook subscription not found")
+
+	// ErrInvalidWebhookSubscription is returned when the webhook subscription url is not an absolute http(s) url,
+	// or it subscribes to an unknown event type
+	ErrInvalidWebhookSubscription = errors.New("invalid webhook subscription")
+
+	// ErrWebhookDeliveryNotFound is returned when the webhook delivery is not exist
+	ErrWebhookDeliveryNotFound = errors.New("webhook delivery not found")
+
+	// ErrWebhookDeliveryPending is returned when replaying a webhook delivery that is still waiting to be delivered
+	ErrWebhookDeliveryPending = errors.New("webhook delivery is still pending")
+
+	// ErrAPIKeyNotFound is returned when the api key is not exist
+	ErrAPIKeyNotFound = errors.New("api key not found")
+
+	// ErrInvalidAPIKey is returned when the client id of an api key is not 1 to 16 letters, digits, '-', '_' or '.',
+	// a scope contains a comma or a space, or the key is already expired
+	ErrInvalidAPIKey = errors.New("invalid api key")
+
+	// ErrAPIKeyRevoked is returned when rotating or revoking an api key that is already revoked
+	ErrAPIKeyRevoked = errors.New("api key is revoked")
+
+	// ErrCrossTenantPosting is returned when a journal posts to an account in the books of another tenant
+	ErrCrossTenantPosting = errors.New("journal posts to an account of another tenant")
+
+	// ErrTenantNotFound is returned when the tenant is not exist
+	ErrTenantNotFound = errors.New("tenant not found")
+
+	// ErrInvalidTenant is returned when the tenant id is not 1 to 16 letters, digits, '-', '_' or '.', or the tenant has no name
+	ErrInvalidTenant = errors.New("invalid tenant")
+
+	// ErrTenantAlreadyExist is returned when provisioning a tenant whose tenant id is already taken
+	ErrTenantAlreadyExist = errors.New("tenant already exist")
+
+	// ErrInvalidMetadata is returned when the metadata of a journal or a transaction have more than 32 keys,
+	// a key that is not 1 to 64 letters, digits, '_', '.', ':' or '-', or a value longer than 256 characters
+	ErrInvalidMetadata = errors.New("invalid metadata")
+
+	// ErrInvalidExternalReference is returned when the external reference is not 1 to 64 letters, digits, '_', '.', ':' or '-',
+	// or is given to a journal that may be posted more than once
+	ErrInvalidExternalReference = errors.New("invalid external reference")
+
+	// ErrExternalReferenceTaken is returned when the creator already created a journal or an account with the external reference
+	ErrExternalReferenceTaken = errors.New("external reference already taken")
+
+	// ErrExternalReferenceNotFound is returned when the creator created no journal or account with the external reference
+	ErrExternalReferenceNotFound = errors.New("external reference not found")
+
+	// ErrInvalidSearch is returned when a search has an unknown alignment or sort field, or an empty amount range
+	ErrInvalidSearch = errors.New("invalid search")
+
+	// ErrInvalidCursor is returned when a page cursor is not one handed out by a previous page
+	ErrInvalidCursor = errors.New("invalid cursor")
+
+	// ErrStatementTooLong is returned when the statement time range has more transactions than a statement can have
+	ErrStatementTooLong = errors.New("statement too long")
+)
+
+// TransactionSearchFilter specifies which transactions to search. Empty fields are not filtered.
+type TransactionSearchFilter struct {
+	// AccountNumbers selects the transactions of any of the accounts
+	AccountNumbers []string
+	// Coa selects the transactions of the accounts whose COA starts with it
+	Coa string
+	// Alignment is DEBIT or CREDIT
+	Alignment string
+	// MinAmount and MaxAmount are the inclusive amount range
+	MinAmount *int64
+	MaxAmount *int64
+	Creator   string
+	// Reversal selects the transactions of reversal journals if true, or of the other journals if false
+	Reversal *bool
+	// Description selects the transactions whose description contains it
+	Description string
+	From        time.Time
+	Until       time.Time
+}
+
+// JournalSearchFilter specifies which journals to search. Empty fields are not filtered.
+type JournalSearchFilter struct {
+	// AccountNumbers selects the journals posting to any of the accounts
+	AccountNumbers []string
+	// Coa selects the journals posting to an account whose COA starts with it
+	Coa string
+	// Alignment selects the journals posting on that side, to the selected accounts if any
+	Alignment string
+	// MinAmount and MaxAmount are the inclusive range of the total amount
+	MinAmount *int64
+	MaxAmount *int64
+	Creator   string
+	Reversal  *bool
+	// Description selects the journals whose description contains it
+	Description string
+	From        time.Time
+	Until       time.Time
+}
+
+// SearchManager searches the journals and transactions by any combination of criteria.
+// The request sorts are the fields to sort by, the transactions by transaction_time, amount, account_number, journal_id
+// or created_by and the journals by journaling_time, total_amount, created_by or sequence.
+type SearchManager interface {
+	// SearchTransactions lists the transactions matching the filter. This function uses pagination.
+	SearchTransactions(ctx context.Context, filter *TransactionSearchFilter, request acccore.PageRequest) (acccore.PageResult, []acccore.Transaction, error)
+
+	// SearchJournals lists the journals matching the filter. This function uses pagination.
+	SearchJournals(ctx context.Context, filter *JournalSearchFilter, request acccore.PageRequest) (acccore.PageResult, []acccore.Journal, error)
+}
+
+// CursorPage tells where the next page of a cursor paginated list starts.
+type CursorPage struct {
+	// NextCursor is the cursor to list the next page with. It is the requested cursor if the page is empty,
+	// so a client that reached the end can keep polling for the records arriving later.
+	NextCursor string `json:"next_cursor"`
+	// HasMore tells if there are more records after NextCursor
+	HasMore bool `json:"has_more"`
+}
+
+// CursorManager lists the journals and the transactions of an account page after page, each page starting
+// after the cursor the previous page ended with. Unlike the acccore.PageRequest pages, the pages do not shift
+// when new records arrive between two requests, and they do not get slower the further they are in the list.
+// An empty cursor lists the first page.
+type CursorManager interface {
+	// ListJournalsAfter lists up to size journals between the `from` and `until` time range whose metadata matches
+	// the filter, in journaling time order, after the cursor.
+	ListJournalsAfter(ctx context.Context, from time.Time, until time.Time, filter Metadata, cursor string, size int) (CursorPage, []acccore.Journal, error)
+
+	// ListTransactionsOnAccountAfter lists up to size transactions of the account between the `from` and `until`
+	// time range whose metadata matches the filter, in transaction time order, after the cursor.
+	ListTransactionsOnAccountAfter(ctx context.Context, from time.Time, until time.Time, accountNumber string, filter Metadata, cursor string, size int) (CursorPage, []acccore.Transaction, error)
+}
+
+// StatementLine is a transaction of an account statement, with the balance of the account once it is made.
+type StatementLine struct {
+	TransactionID   string `json:"transaction_id"`
+	TransactionTime string `json:"transaction_time"`
+	JournalID       string `json:"journal_id"`
+	Description     string `json:"description"`
+	// Debit or Credit is the amount of the transaction, depending on its alignment, the other one is 0
+	Debit  int64 `json:"debit"`
+	Credit int64 `json:"credit"`
+	// Balance is the running balance, the opening balance plus the lines up to this one
+	Balance int64 `json:"balance"`
+}
+
+// AccountStatement is the activity of an account within a time range, from the balance it opened with
+// to the balance it closed with.
+type AccountStatement struct {
+	AccountNumber string `json:"account_number"`
+	Name          string `json:"name"`
+	Currency      string `json:"currency"`
+	COA           string `json:"coa"`
+	Alignment     string `json:"alignment"`
+	From          string `json:"from"`
+	Until         string `json:"until"`
+	// OpeningBalance is the balance after the transactions made up to From
+	OpeningBalance int64 `json:"opening_balance"`
+	TotalDebit     int64 `json:"total_debit"`
+	TotalCredit    int64 `json:"total_credit"`
+	// ClosingBalance is the balance after the transactions made before Until
+	ClosingBalance int64            `json:"closing_balance"`
+	Lines          []*StatementLine `json:"lines"`
+}
+
+// StatementManager draws up the account statements.
+type StatementManager interface {
+	// GetAccountStatement returns the statement of the account between the `from` and `until` time range,
+	// the transactions are listed in transaction time order.
+	GetAccountStatement(ctx context.Context, from time.Time, until time.Time, account acccore.Account) (*AccountStatement, error)
+}
+
+// ReferenceManager finds the journals and accounts by the external reference their creator gave them.
+// The references are unique per creator, so the same reference given by two creators finds two different records.
+type ReferenceManager interface {
+	// GetJournalByReference returns the journal the creator created with the reference, or ErrExternalReferenceNotFound
+	GetJournalByReference(ctx context.Context, creator, reference string) (acccore.Journal, error)
+
+	// GetAccountByReference returns the account the creator created with the reference, or ErrExternalReferenceNotFound
+	GetAccountByReference(ctx context.Context, creator, reference string) (acccore.Account, error)
+}
+
+// MetadataManager lists the journals and transactions by their metadata.
+// A filter selects those whose metadata have every key of the filter mapped to the same value,
+// a key mapped to an empty value only needs to be present.
+type MetadataManager interface {
+	// ListJournalsByMetadata lists the journals between the `from` and `until` time range whose metadata matches the filter.
+	// This function uses pagination.
+	ListJournalsByMetadata(ctx context.Context, from time.Time, until time.Time, filter Metadata, request acccore.PageRequest) (acccore.PageResult, []acccore.Journal, error)
+
+	// ListTransactionsOnAccountByMetadata lists the transactions of the account between the `from` and `until` time range
+	// whose metadata matches the filter. This function uses pagination.
+	ListTransactionsOnAccountByMetadata(ctx context.Context, from time.Time, until time.Time, accountNumber string, filter Metadata, request acccore.PageRequest) (acccore.PageResult, []acccore.Transaction, error)
+}
+
+// JournalBatchError reports why each of the failing journals in a batch can not be persisted.
+type JournalBatchError struct {
+	// Errors maps the index of the failing journal in the batch to its error
+	Errors map[int]error
+}
+
+// Error implements the error interface
+func (e *JournalBatchError) Error() string {
+	return fmt.Sprintf("%s : %d journal(s) rejected", ErrJournalBatchFailed.Error(), len(e.Errors))
+}
+
+// Unwrap makes errors.Is(err, ErrJournalBatchFailed) true
+func (e *JournalBatchError) Unwrap() error {
+	return ErrJournalBatchFailed
+}
+
+// JournalBatchManager persists several journals atomically.
+type JournalBatchManager interface {
+	// PersistJournals validates and persists all the journals in a single database transaction, in order.
+	// A journal sees the balances left by the journals before it in the batch.
+	// If any of the journals is rejected, nothing is persisted and a *JournalBatchError is returned.
+	PersistJournals(ctx context.Context, journals []acccore.Journal) error
+}
+
+// AccountStateManager manages the lifecycle state of an account (active, frozen, closed).
+// The acccore.AccountManager have no notion of account state, thus its managed separately.
+type AccountStateManager interface {
+	// GetAccountStatus returns the current status of the account, one of the connector.AccountStatus constants.
+	GetAccountStatus(ctx context.Context, accountNumber string) (string, error)
+
+	// FreezeAccount will freeze the account. If debitOnly is true, the account will still accept CREDIT
+	// postings, otherwise the account will not accept any posting at all.
+	FreezeAccount(ctx context.Context, accountNumber string, debitOnly bool) error
+
+	// UnfreezeAccount will make a frozen account active again.
+	UnfreezeAccount(ctx context.Context, accountNumber string) error
+
+	// CloseAccount will permanently close the account. Only account with zero balance can be closed.
+	CloseAccount(ctx context.Context, accountNumber string) error
+}
+
+// AccountLimits are the balance constraints of an account. A nil limit means the constraint is not set.
+// When an overdraft limit is set, the account may go below its minimum balance (or zero, if no minimum balance is set)
+// by at most the overdraft limit.
+type AccountLimits struct {
+	MinBalance     *int64 `json:"min_balance,omitempty"`
+	OverdraftLimit *int64 `json:"overdraft_limit,omitempty"`
+	MaxBalance     *int64 `json:"max_balance,omitempty"`
+}
+
+// AccountLimitManager manages the balance constraints of an account.
+// The constraints are enforced by the JournalManager when the account balance is updated.
+type AccountLimitManager interface {
+	// GetAccountLimits returns the balance limits of the account.
+	GetAccountLimits(ctx context.Context, accountNumber string) (*AccountLimits, error)
+
+	// SetAccountLimits replaces the balance limits of the account.
+	SetAccountLimits(ctx context.Context, accountNumber string, limits *AccountLimits) error
+
+	// PersistAccountWithLimits persists the new account along with its balance limits, in a single database transaction.
+	// Invalid limits are rejected before anything is written.
+	PersistAccountWithLimits(ctx context.Context, account acccore.Account, limits *AccountLimits) error
+}
+
+// Hold is a reservation of an account fund. The held amount is not available to any journal, until
+// the hold is captured into a journal, voided or expired.
+type Hold struct {
+	HoldID        string    `json:"hold_id"`
+	AccountNumber string    `json:"account_number"`
+	Description   string    `json:"description"`
+	Amount        int64     `json:"amount"`
+	Status        string    `json:"status"`
+	ExpiresAt     time.Time `json:"expires_at"`
+	JournalID     string    `json:"journal_id,omitempty"`
+	CreateTime    time.Time `json:"created_at"`
+	CreateBy      string    `json:"created_by"`
+}
+
+// HoldManager manages the holds on account funds.
+type HoldManager interface {
+	// PlaceHold reserves the amount from the account until expiresAt. The hold is rejected if the account
+	// available balance can not cover the amount within the account limits.
+	PlaceHold(ctx context.Context, accountNumber string, amount int64, description string, expiresAt time.Time, creator string) (*Hold, error)
+
+	// GetHold returns the hold of the specified holdID
+	GetHold(ctx context.Context, holdID string) (*Hold, error)
+
+	// ListActiveHolds returns all holds on the account that still reserve the account fund.
+	ListActiveHolds(ctx context.Context, accountNumber string) ([]*Hold, error)
+
+	// CaptureHold turns the hold into a journal that moves the amount from the held account into the counter account.
+	// Amount of zero captures the whole held amount, capturing less than the held amount releases the rest.
+	CaptureHold(ctx context.Context, holdID, counterAccountNumber string, amount int64, description, creator string) (*Hold, error)
+
+	// VoidHold releases the hold.
+	VoidHold(ctx context.Context, holdID, creator string) (*Hold, error)
+
+	// ExpireHolds releases all holds that have expired, returning the number of released holds.
+	ExpireHolds(ctx context.Context) (int64, error)
+
+	// GetAvailableBalance returns the account balance minus the amount still on hold.
+	GetAvailableBalance(ctx context.Context, accountNumber string) (int64, error)
+}
+
+// RecurringJournal is a journal that is posted repeatedly following its cron expression schedule.
+type RecurringJournal struct {
+	ScheduleID     string                `json:"schedule_id"`
+	CronExpression string                `json:"cron_expression"`
+	Description    string                `json:"description"`
+	Transactions   []*TransactionRequest `json:"transactions"`
+	Metadata       Metadata              `json:"metadata,omitempty"`
+	Status         string                `json:"status"`
+	// NextRun is the next time the journal will be posted, nil if the recurring journal is paused
+	NextRun    *time.Time `json:"next_run,omitempty"`
+	CreateTime time.Time  `json:"created_at"`
+	CreateBy   string     `json:"created_by"`
+}
+
+// RecurringJournalRun is the result of a single posting of a recurring journal
+type RecurringJournalRun struct {
+	RunID        string    `json:"run_id"`
+	ScheduleID   string    `json:"schedule_id"`
+	RunAt        time.Time `json:"run_at"`
+	Success      bool      `json:"success"`
+	JournalID    string    `json:"journal_id,omitempty"`
+	ErrorMessage string    `json:"error_message,omitempty"`
+}
+
+// RecurringJournalManager manages journals that are posted on schedule.
+type RecurringJournalManager interface {
+	// CreateRecurringJournal stores the journal to be posted on the cron expression schedule, and schedule it.
+	CreateRecurringJournal(ctx context.Context, cronExpression string, journal *CreateJournalRequest) (*RecurringJournal, error)
+
+	// GetRecurringJournal returns the recurring journal of the specified scheduleID
+	GetRecurringJournal(ctx context.Context, scheduleID string) (*RecurringJournal, error)
+
+	// ListRecurringJournals list all recurring journals, paused or not.
+	ListRecurringJournals(ctx context.Context, request acccore.PageRequest) (acccore.PageResult, []*RecurringJournal, error)
+
+	// PauseRecurringJournal stops posting the recurring journal until resumed.
+	PauseRecurringJournal(ctx context.Context, scheduleID string) (*RecurringJournal, error)
+
+	// ResumeRecurringJournal continue posting a paused recurring journal.
+	ResumeRecurringJournal(ctx context.Context, scheduleID string) (*RecurringJournal, error)
+
+	// RunRecurringJournal posts the recurring journal once and records the run. A journal that fails to post
+	// is recorded as a failed run, not returned as error.
+	RunRecurringJournal(ctx context.Context, scheduleID string) (*RecurringJournalRun, error)
+
+	// ListRecurringJournalRuns list the run history of the recurring journal, latest run first.
+	ListRecurringJournalRuns(ctx context.Context, scheduleID string, request acccore.PageRequest) (acccore.PageResult, []*RecurringJournalRun, error)
+
+	// ScheduleAll schedules the active recurring journals and unschedules the paused ones, to be called when the server
+	// starts and then periodically, so every instance sees the recurring journals created, paused or resumed on another.
+	ScheduleAll(ctx context.Context) error
+}
+
+// PendingJournal is a journal waiting to be approved or rejected by someone other than its submitter.
+// Only approval posts the journal.
+type PendingJournal struct {
+	PendingID    string                `json:"pending_id"`
+	Description  string                `json:"description"`
+	Transactions []*TransactionRequest `json:"transactions"`
+	Metadata     Metadata              `json:"metadata,omitempty"`
+	// ExternalReference is the reference the journal is known by once posted
+	ExternalReference string `json:"external_reference,omitempty"`
+	Amount            int64  `json:"amount"`
+	Status            string `json:"status"`
+	// JournalID is the posted journal, only available when the pending journal is approved
+	JournalID   string     `json:"journal_id,omitempty"`
+	ReviewNote  string     `json:"review_note,omitempty"`
+	SubmittedAt time.Time  `json:"submitted_at"`
+	SubmittedBy string     `json:"submitted_by"`
+	ReviewedAt  *time.Time `json:"reviewed_at,omitempty"`
+	ReviewedBy  string     `json:"reviewed_by,omitempty"`
+}
+
+// ApprovalRule decides which journals need approval. A journal needs approval if it matches any rule.
+// A journal matches a rule if its amount is at least MinAmount and one of its transactions posts into an account
+// whose COA starts with Coa. An unset criteria matches any journal.
+type ApprovalRule struct {
+	RuleID      string    `json:"rule_id"`
+	Description string    `json:"description"`
+	MinAmount   *int64    `json:"min_amount,omitempty"`
+	Coa         string    `json:"coa,omitempty"`
+	CreateTime  time.Time `json:"created_at"`
+	CreateBy    string    `json:"created_by"`
+}
+
+// ApprovalManager manages the maker-checker workflow of journals.
+type ApprovalManager interface {
+	// RequiresApproval check if the journal matches any of the approval rules.
+	RequiresApproval(ctx context.Context, journal *CreateJournalRequest) (bool, error)
+
+	// SubmitJournal validates the journal and stores it as pending, waiting for approval.
+	SubmitJournal(ctx context.Context, journal *CreateJournalRequest) (*PendingJournal, error)
+
+	// GetPendingJournal returns the pending journal of the specified pendingID
+	GetPendingJournal(ctx context.Context, pendingID string) (*PendingJournal, error)
+
+	// ListPendingJournals list the pending journals of the specified status, oldest submission first.
+	ListPendingJournals(ctx context.Context, status string, request acccore.PageRequest) (acccore.PageResult, []*PendingJournal, error)
+
+	// ApproveJournal posts the pending journal. The reviewer must not be the submitter.
+	ApproveJournal(ctx context.Context, pendingID, reviewer, note string) (*PendingJournal, error)
+
+	// RejectJournal discards the pending journal. The reviewer must not be the submitter.
+	RejectJournal(ctx context.Context, pendingID, reviewer, note string) (*PendingJournal, error)
+
+	// CreateApprovalRule adds a new approval rule.
+	CreateApprovalRule(ctx context.Context, rule *ApprovalRule, creator string) (*ApprovalRule, error)
+
+	// ListApprovalRules list all approval rules.
+	ListApprovalRules(ctx context.Context) ([]*ApprovalRule, error)
+
+	// DeleteApprovalRule removes an approval rule.
+	DeleteApprovalRule(ctx context.Context, ruleID string) error
+}
+
+// PostingTemplateManager manages the posting templates.
+type PostingTemplateManager interface {
+	// SavePostingTemplate creates the posting template, or replaces it if a template of the same name exist.
+	SavePostingTemplate(ctx context.Context, template *PostingTemplate, creator string) (*PostingTemplate, error)
+
+	// GetPostingTemplate returns the posting template of the specified name
+	GetPostingTemplate(ctx context.Context, name string) (*PostingTemplate, error)
+
+	// ListPostingTemplates list all posting templates.
+	ListPostingTemplates(ctx context.Context) ([]*PostingTemplate, error)
+
+	// DeletePostingTemplate removes a posting template.
+	DeletePostingTemplate(ctx context.Context, name string) error
+
+	// ExpandPostingTemplate turns the posting template of the specified name into a journal request using the parameters.
+	ExpandPostingTemplate(ctx context.Context, name string, params map[string]interface{}, description, creator string) (*CreateJournalRequest, error)
+}
+
+// IdempotentRequest is a request made with an idempotency key, stored along with the resource it created.
+type IdempotentRequest struct {
+	// Digest is the SHA256 hex digest of the request payload
+	Digest string
+	// Code is the http status of the original response
+	Code int
+	// ResourceID is the journal, pending journal or account created by the request
+	ResourceID string
+}
+
+// IdempotencyClaim is the idempotency key claimed by a request. It is carried in the request context, and stored in the same
+// database transaction as the journal, pending journal or account the request creates, see claimIdempotencyKey.
+type IdempotencyClaim struct {
+	ClientID string
+	Scope    string
+	Key      string
+	Digest   string
+	// taken tells another request have stored the key first, the request created nothing.
+	taken bool
+}
+
+// IdempotencyManager makes sure a request repeated by a client with the same idempotency key is processed only once.
+type IdempotencyManager interface {
+	// GetIdempotentRequest returns the request the client made with the idempotency key within the scope, or nil if the client
+	// have not used the key. ErrInvalidIdempotencyKey is returned for a key longer than 64 characters.
+	GetIdempotentRequest(ctx context.Context, clientID, scope, key string) (*IdempotentRequest, error)
+}
+
+// ProjectedBalance is the balance of an account before and after a simulated journal.
+type ProjectedBalance struct {
+	AccountNumber    string `json:"account_number"`
+	Currency         string `json:"currency"`
+	Alignment        string `json:"alignment"`
+	Balance          int64  `json:"balance"`
+	ProjectedBalance int64  `json:"projected_balance"`
+}
+
+// JournalSimulation is the outcome of a simulated journal.
+type JournalSimulation struct {
+	// Accepted tells if the journal would be persisted, if not Error tells why
+	Accepted bool   `json:"accepted"`
+	Error    string `json:"error,omitempty"`
+	// RequiresApproval tells if the journal would be submitted as pending journal instead of posted
+	RequiresApproval bool                `json:"requires_approval"`
+	Balances         []*ProjectedBalance `json:"balances"`
+}
+
+// JournalSimulationManager tells if a journal would be accepted, without persisting it.
+type JournalSimulationManager interface {
+	// SimulateJournal runs every validation of persisting the journal and returns the projected balance of each account
+	// the journal posts into. Nothing is persisted. An error is only returned if the simulation itself fails.
+	SimulateJournal(ctx context.Context, journal acccore.Journal) (*JournalSimulation, error)
+}
+
+// ReversalLine is the amount to reverse of a line of the reversed journal, the line is identified by its account number.
+type ReversalLine struct {
+	AccountNumber string `json:"account_number"`
+	// Amount to reverse, zero reverses all that is left of the line
+	Amount int64 `json:"amount"`
+}
+
+// ReversalSummaryLine is a line of a journal and how much of it have been reversed.
+type ReversalSummaryLine struct {
+	AccountNumber  string `json:"account_number"`
+	Alignment      string `json:"alignment"`
+	Amount         int64  `json:"amount"`
+	ReversedAmount int64  `json:"reversed_amount"`
+}
+
+// ReversalSummary tells how much of a journal have been reversed, and by which journals.
+type ReversalSummary struct {
+	JournalID      string                 `json:"journal_id"`
+	TotalAmount    int64                  `json:"total_amount"`
+	ReversedAmount int64                  `json:"reversed_amount"`
+	FullyReversed  bool                   `json:"fully_reversed"`
+	Lines          []*ReversalSummaryLine `json:"lines"`
+	ReversalIDs    []string               `json:"reversal_journal_ids"`
+}
+
+// ReversalManager reverses journals in full or in part, eg. for refunds.
+type ReversalManager interface {
+	// ReverseJournal creates and persists a reversal journal of the specified journal. Without lines, all that is left to
+	// reverse of every line is reversed. ErrReversalExceedsOriginal is returned when a line would be over reversed.
+	ReverseJournal(ctx context.Context, journalID string, lines []*ReversalLine, description, creator string) (acccore.Journal, error)
+
+	// GetReversalSummary returns the cumulative reversed amount of each line of the journal.
+	GetReversalSummary(ctx context.Context, journalID string) (*ReversalSummary, error)
+}
+
+const (
+	// EventJournalPosted is published when a journal is persisted
+	EventJournalPosted = "journal.posted"
+	// EventJournalReversed is published when a reversal journal is persisted, instead of EventJournalPosted
+	EventJournalReversed = "journal.reversed"
+	// EventAccountCreated is published when an account is persisted
+	EventAccountCreated = "account.created"
+	// EventBalanceLow is published when a journal brings an account balance below its minimum balance, or below zero
+	// for an account without minimum balance
+	EventBalanceLow = "balance.low"
+)
+
+// WebhookEventTypes lists the event types a webhook can subscribe to.
+var WebhookEventTypes = []string{EventJournalPosted, EventJournalReversed, EventAccountCreated, EventBalanceLow}
+
+// WebhookEvent is the body POSTed to the webhook subscribers.
+type WebhookEvent struct {
+	EventID   int64           `json:"event_id"`
+	EventType string          `json:"event_type"`
+	CreatedAt time.Time       `json:"created_at"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// WebhookSubscription is an url the events of the subscribed types are delivered to.
+type WebhookSubscription struct {
+	SubscriptionID string `json:"subscription_id"`
+	URL            string `json:"url"`
+	// EventTypes subscribed, "*" subscribes to all event types
+	EventTypes  []string `json:"event_types"`
+	Description string   `json:"description"`
+	// Secret used to sign the deliveries, only returned when the subscription is created
+	Secret     string    `json:"secret,omitempty"`
+	CreateTime time.Time `json:"created_at"`
+	CreateBy   string    `json:"created_by"`
+}
+
+// WebhookDelivery is the delivery of an event to a webhook subscription.
+type WebhookDelivery struct {
+	DeliveryID       int64      `json:"delivery_id"`
+	EventID          int64      `json:"event_id"`
+	SubscriptionID   string     `json:"subscription_id"`
+	Status           string     `json:"status"`
+	Attempts         int        `json:"attempts"`
+	NextAttemptAt    time.Time  `json:"next_attempt_at"`
+	LastResponseCode int        `json:"last_response_code"`
+	LastError        string     `json:"last_error,omitempty"`
+	CreatedAt        time.Time  `json:"created_at"`
+	DeliveredAt      *time.Time `json:"delivered_at,omitempty"`
+}
+
+// WebhookManager manages the webhook subscriptions and delivers the events written to the outbox.
+type WebhookManager interface {
+	// CreateSubscription subscribes the url to the event types, and generates the secret the deliveries are signed with.
+	CreateSubscription(ctx context.Context, subscription *WebhookSubscription, creator string) (*WebhookSubscription, error)
+
+	// GetSubscription returns the webhook subscription, without its secret.
+	GetSubscription(ctx context.Context, subscriptionID string) (*WebhookSubscription, error)
+
+	// ListSubscriptions list all webhook subscriptions, without their secret.
+	ListSubscriptions(ctx context.Context) ([]*WebhookSubscription, error)
+
+	// DeleteSubscription removes a webhook subscription, its pending deliveries are moved to the dead letter queue when attempted.
+	DeleteSubscription(ctx context.Context, subscriptionID string) error
+
+	// ListDeliveries list the deliveries of a webhook subscription, optionally of the specified status, latest first.
+	ListDeliveries(ctx context.Context, subscriptionID, status string, request acccore.PageRequest) (acccore.PageResult, []*WebhookDelivery, error)
+
+	// ReplayDelivery schedules a delivered or dead delivery to be delivered again, with its attempts reset.
+	ReplayDelivery(ctx context.Context, deliveryID int64) error
+
+	// ReplayDeadDeliveries schedules all dead deliveries of a webhook subscription to be delivered again.
+	// It returns the number of deliveries replayed.
+	ReplayDeadDeliveries(ctx context.Context, subscriptionID string) (int, error)
+
+	// DispatchEvents fans out the events in the outbox into a delivery for each subscription of the event type.
+	// It returns the number of events dispatched.
+	DispatchEvents(ctx context.Context) (int, error)
+
+	// DeliverDue attempts every delivery that is due. A failed delivery is retried with exponential backoff,
+	// until it runs out of attempts and is moved to the dead letter queue. It returns the number of deliveries delivered.
+	DeliverDue(ctx context.Context) (int, error)
+}
+
+// FeedJournal is a journal in the ledger change feed, with its transactions.
+type FeedJournal struct {
+	// Sequence is the gapless number of the journal in commit order
+	Sequence        int64                  `json:"sequence"`
+	JournalID       string                 `json:"journal_id"`
+	JournalingTime  string                 `json:"journaling_time"`
+	Description     string                 `json:"description"`
+	Reversal        bool                   `json:"reversal"`
+	ReversedJournal string                 `json:"reversed_journal"`
+	Amount          int64                  `json:"amount"`
+	CreateTime      string                 `json:"create_time"`
+	CreateBy        string                 `json:"create_by"`
+	Metadata        Metadata               `json:"metadata,omitempty"`
+	Transactions    []*TransactionListItem `json:"transactions"`
+}
+
+// LedgerFeed is a page of the ledger change feed.
+type LedgerFeed struct {
+	Journals []*FeedJournal `json:"journals"`
+	// LastSequence is the sequence to resume the feed after, it stays the requested sequence if there's no new journal
+	LastSequence int64 `json:"last_sequence"`
+	// HasMore tells if there are more journals after LastSequence
+	HasMore bool `json:"has_more"`
+}
+
+// LedgerFeedManager streams every journal in the order they are committed.
+type LedgerFeedManager interface {
+	// ListFeed returns up to limit journals whose sequence is greater than afterSequence, in sequence order.
+	ListFeed(ctx context.Context, afterSequence int64, limit int) (*LedgerFeed, error)
+}
+
+// AuditLogEntry is the audit record of a write request.
+type AuditLogEntry struct {
+	AuditID       int64  `json:"audit_id"`
+	Principal     string `json:"principal"`
+	RequestID     string `json:"request_id"`
+	Method        string `json:"method"`
+	Endpoint      string `json:"endpoint"`
+	Path          string `json:"path"`
+	PayloadDigest string `json:"payload_digest"`
+	// Before is the resource before the change, if the endpoint took its snapshot
+	Before json.RawMessage `json:"before,omitempty"`
+	// After is the resource after the change, only for successful requests
+	After      json.RawMessage `json:"after,omitempty"`
+	StatusCode int             `json:"status_code"`
+	Outcome    string          `json:"outcome"`
+	CreateTime string          `json:"create_time"`
+}
+
+// AuditLogFilter specifies which audit records to list. Empty fields are not filtered.
+type AuditLogFilter struct {
+	Principal string
+	RequestID string
+	Method    string
+	Endpoint  string
+	Outcome   string
+	From      time.Time
+	To        time.Time
+}
+
+// AuditManager records who changed what, and lists the records for the administrators.
+type AuditManager interface {
+	// RecordAudit stores the audit record of a write request, it makes AuditManager a middlewares.AuditSink.
+	RecordAudit(ctx context.Context, rec *middlewares.AuditRecord) error
+
+	// ListAuditLogs list the audit records matching the filter, latest first.
+	ListAuditLogs(ctx context.Context, filter *AuditLogFilter, request acccore.PageRequest) (acccore.PageResult, []*AuditLogEntry, error)
+}
+
+// APIKey is the key a client authenticates with. Only the hash of the key is stored.
+type APIKey struct {
+	KeyID    string `json:"key_id"`
+	ClientID string `json:"client_id"`
+	// TenantID is the tenant whose books the client works on
+	TenantID string   `json:"tenant_id"`
+	Scopes   []string `json:"scopes"`
+	// ExpiresAt is the time the key stop being accepted, nil if the key never expires
+	ExpiresAt   *time.Time `json:"expires_at"`
+	Description string     `json:"description"`
+	Revoked     bool       `json:"revoked"`
+	RevokedAt   *time.Time `json:"revoked_at,omitempty"`
+	// ReplacedBy is the key issued when this key was rotated
+	ReplacedBy string `json:"replaced_by,omitempty"`
+	// APIKey is the key to put in the Authorization header as "ApiKey <api_key>", only returned when the key is issued
+	APIKey     string    `json:"api_key,omitempty"`
+	CreateTime time.Time `json:"created_at"`
+	CreateBy   string    `json:"created_by"`
+}
+
+// APIKeyManager issues the api keys of the clients and authenticates them.
+type APIKeyManager interface {
+	// IssueKey issues a new key for the client, with the scopes, expiry and description of the specified key.
+	// The key is issued for the tenant of the specified key, or the tenant of the caller if it has none.
+	IssueKey(ctx context.Context, key *APIKey, creator string) (*APIKey, error)
+
+	// GetKey returns the api key of the specified key id, the keys of the other tenants are not found.
+	GetKey(ctx context.Context, keyID string) (*APIKey, error)
+
+	// ListKeys list the keys of the client in the tenant of the caller, latest first. An empty clientID lists the keys of all clients.
+	ListKeys(ctx context.Context, clientID string) ([]*APIKey, error)
+
+	// RotateKey issues a new key in place of the specified key, with the same client, scopes and description,
+	// and revokes the old key. The new key expires at expiresAt, or never if it is nil.
+	RotateKey(ctx context.Context, keyID string, expiresAt *time.Time, creator string) (*APIKey, error)
+
+	// RevokeKey revokes the specified key, it is refused from then on.
+	RevokeKey(ctx context.Context, keyID string, creator string) error
+
+	// AuthenticateAPIKey returns the client owning the key if the secret matches, and the key is neither revoked nor expired.
+	// It makes APIKeyManager a middlewares.APIKeyAuthenticator.
+	AuthenticateAPIKey(ctx context.Context, keyID, secret string) (*middlewares.Client, error)
+
+	// APIKeySigningKey returns the client owning the key and the key its requests are signed with,
+	// if the key is neither revoked nor expired. It makes APIKeyManager a middlewares.APIKeyAuthenticator.
+	APIKeySigningKey(ctx context.Context, keyID string) (*middlewares.Client, string, error)
+
+	// APIKeyClient returns the client owning the key if the key is neither revoked nor expired.
+	// It makes APIKeyManager a middlewares.APIKeyAuthenticator.
+	APIKeyClient(ctx context.Context, keyID string) (*middlewares.Client, error)
+}
+
+// RateLimitManager counts the requests of the clients in the database, so the rate limits are shared by all instances.
+type RateLimitManager interface {
+	// Hit counts a request of the key in the window starting at windowStart and lasting window,
+	// and returns the number of requests of the key counted in the window so far.
+	// It makes RateLimitManager a middlewares.RateLimiter.
+	Hit(ctx context.Context, key string, windowStart time.Time, window time.Duration) (int, error)
+
+	// PurgeRateLimits removes the counts of the ended windows, returning the number of removed counts.
+	PurgeRateLimits(ctx context.Context) (int64, error)
+}
+
+// Tenant owns a set of books, isolated from the books of the other tenants.
+type Tenant struct {
+	TenantID    string    `json:"tenant_id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	CreateTime  time.Time `json:"created_at"`
+	CreateBy    string    `json:"created_by"`
+}
+
+// TenantManager provisions the tenants.
+type TenantManager interface {
+	// CreateTenant provisions a new tenant with empty books.
+	CreateTenant(ctx context.Context, tenant *Tenant, creator string) (*Tenant, error)
+
+	// GetTenant returns the tenant of the specified tenant id.
+	GetTenant(ctx context.Context, tenantID string) (*Tenant, error)
+
+	// ListTenants list the tenants sorted by their tenant id, in paginated fashion.
+	ListTenants(ctx context.Context, request acccore.PageRequest) (acccore.PageResult, []*Tenant, error)
+}
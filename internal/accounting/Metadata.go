@@ -0,0 +1,107 @@
+package accounting
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+
+	"github.com/hyperjumptech/acccore"
+)
+
+const (
+	// MaxMetadataKeys is the maximum number of keys in the metadata of a journal or a transaction
+	MaxMetadataKeys = 32
+	// MaxMetadataValueLength is the maximum length of a metadata value
+	MaxMetadataValueLength = 256
+)
+
+var metadataKeyRegex = regexp.MustCompile(`^[a-zA-Z0-9_.:-]{1,64}$`)
+
+// Metadata is the free form key value pairs the clients attach to a journal or a transaction,
+// eg. the order id or the channel that caused it.
+type Metadata map[string]string
+
+// MetadataHolder is a journal or a transaction that carries metadata
+type MetadataHolder interface {
+	GetMetadata() Metadata
+}
+
+// MetadataJournal is a journal with metadata and an external reference
+type MetadataJournal struct {
+	acccore.BaseJournal
+	Metadata          Metadata `json:"metadata,omitempty"`
+	ExternalReference string   `json:"external_reference,omitempty"`
+}
+
+// GetMetadata returns the metadata of the journal
+func (journal *MetadataJournal) GetMetadata() Metadata {
+	return journal.Metadata
+}
+
+// MetadataTransaction is a transaction with metadata
+type MetadataTransaction struct {
+	acccore.BaseTransaction
+	Metadata Metadata `json:"metadata,omitempty"`
+}
+
+// GetMetadata returns the metadata of the transaction
+func (trx *MetadataTransaction) GetMetadata() Metadata {
+	return trx.Metadata
+}
+
+// metadataOf returns the metadata of the journal or transaction, nil if it does not carry metadata
+func metadataOf(x interface{}) Metadata {
+	if holder, ok := x.(MetadataHolder); ok {
+		return holder.GetMetadata()
+	}
+	return nil
+}
+
+// ValidateMetadataKey make sure the key is 1 to 64 letters, digits, '_', '.', ':' or '-'
+func ValidateMetadataKey(key string) error {
+	if !metadataKeyRegex.MatchString(key) {
+		return fmt.Errorf("%w : key %q", ErrInvalidMetadata, key)
+	}
+	return nil
+}
+
+// validateMetadata make sure the metadata have no more than MaxMetadataKeys keys, the keys are valid
+// and no value is longer than MaxMetadataValueLength.
+func validateMetadata(metadata Metadata) error {
+	if len(metadata) > MaxMetadataKeys {
+		return fmt.Errorf("%w : more than %d keys", ErrInvalidMetadata, MaxMetadataKeys)
+	}
+	for key, value := range metadata {
+		if err := ValidateMetadataKey(key); err != nil {
+			return err
+		}
+		if len(value) > MaxMetadataValueLength {
+			return fmt.Errorf("%w : value of %q is longer than %d", ErrInvalidMetadata, key, MaxMetadataValueLength)
+		}
+	}
+	return nil
+}
+
+// encodeMetadata encodes the metadata into the json stored in the metadata columns, empty metadata is stored as null
+func encodeMetadata(metadata Metadata) string {
+	if len(metadata) == 0 {
+		return ""
+	}
+	b, err := json.Marshal(metadata)
+	if err != nil {
+		return ""
+	}
+	return string(b)
+}
+
+// decodeMetadata decodes the json of the metadata columns, it returns nil for null or malformed json
+func decodeMetadata(s string) Metadata {
+	if len(s) == 0 {
+		return nil
+	}
+	metadata := make(Metadata)
+	if err := json.Unmarshal([]byte(s), &metadata); err != nil || len(metadata) == 0 {
+		return nil
+	}
+	return metadata
+}
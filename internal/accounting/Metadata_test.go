@@ -0,0 +1,94 @@
+package accounting
+
+import (
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/hyperjumptech/acccore"
+)
+
+func TestValidateMetadata(t *testing.T) {
+	tooMany := make(Metadata)
+	for i := 0; i <= MaxMetadataKeys; i++ {
+		tooMany[fmt.Sprintf("key%d", i)] = "value"
+	}
+	testData := []struct {
+		name     string
+		metadata Metadata
+		valid    bool
+	}{
+		{"nil", nil, true},
+		{"valid", Metadata{"order_id": "ORD-1", "channel:web": "", "a.b-c": "x"}, true},
+		{"empty key", Metadata{"": "value"}, false},
+		{"key with space", Metadata{"order id": "ORD-1"}, false},
+		{"key too long", Metadata{strings.Repeat("k", 65): "value"}, false},
+		{"value too long", Metadata{"order_id": strings.Repeat("v", MaxMetadataValueLength+1)}, false},
+		{"too many keys", tooMany, false},
+	}
+	for _, td := range testData {
+		err := validateMetadata(td.metadata)
+		if td.valid && err != nil {
+			t.Errorf("%s : expecting valid metadata, got %s", td.name, err.Error())
+		}
+		if !td.valid && !errors.Is(err, ErrInvalidMetadata) {
+			t.Errorf("%s : expecting ErrInvalidMetadata, got %v", td.name, err)
+		}
+	}
+}
+
+func TestEncodeDecodeMetadata(t *testing.T) {
+	if encodeMetadata(nil) != "" || encodeMetadata(Metadata{}) != "" {
+		t.Errorf("expecting empty metadata encoded as null")
+	}
+	metadata := decodeMetadata(encodeMetadata(Metadata{"order_id": "ORD-1"}))
+	if len(metadata) != 1 || metadata["order_id"] != "ORD-1" {
+		t.Errorf("expecting the metadata decoded back, got %v", metadata)
+	}
+	if decodeMetadata("") != nil || decodeMetadata("not json") != nil {
+		t.Errorf("expecting null or malformed metadata decoded as nil")
+	}
+}
+
+func TestNewJournalFromRequest_Metadata(t *testing.T) {
+	journal := NewJournalFromRequest(&CreateJournalRequest{
+		Description: "purchase",
+		Creator:     "aCreator",
+		Metadata:    Metadata{"order_id": "ORD-1"},
+		Transactions: []*TransactionRequest{
+			{AccountNumber: "CUSTOMER", Alignment: "DEBIT", Amount: 100, Metadata: Metadata{"sku": "SKU-1"}},
+			{AccountNumber: "MERCHANT", Alignment: "CREDIT", Amount: 100},
+		},
+	}, &acccore.RandomGenUniqueIDGenerator{Length: 16, UpperAlpha: true, Numeric: true})
+	if metadataOf(journal)["order_id"] != "ORD-1" {
+		t.Errorf("expecting the journal metadata, got %v", metadataOf(journal))
+	}
+	if metadataOf(journal.GetTransactions()[0])["sku"] != "SKU-1" {
+		t.Errorf("expecting the transaction metadata, got %v", metadataOf(journal.GetTransactions()[0]))
+	}
+	if metadataOf(journal.GetTransactions()[1]) != nil {
+		t.Errorf("expecting no metadata on the second transaction")
+	}
+	if metadataOf(&acccore.BaseJournal{}) != nil {
+		t.Errorf("expecting no metadata on a base journal")
+	}
+}
+
+func TestMetadataFilterFromQuery(t *testing.T) {
+	filter, msg := metadataFilterFromQuery(httptest.NewRequest("GET", "/api/v1/journals?page=1&size=10&metadata.order_id=ORD-1&metadata.refund=", nil))
+	if len(msg) > 0 {
+		t.Fatalf("expecting a valid filter, got %s", msg)
+	}
+	if len(filter) != 2 || filter["order_id"] != "ORD-1" || filter["refund"] != "" {
+		t.Errorf("expecting order_id and refund in the filter, got %v", filter)
+	}
+	filter, msg = metadataFilterFromQuery(httptest.NewRequest("GET", "/api/v1/journals?page=1&size=10", nil))
+	if len(msg) > 0 || filter != nil {
+		t.Errorf("expecting no filter, got %v %s", filter, msg)
+	}
+	if _, msg = metadataFilterFromQuery(httptest.NewRequest("GET", "/api/v1/journals?metadata.=x", nil)); len(msg) == 0 {
+		t.Errorf("expecting an empty key refused")
+	}
+}
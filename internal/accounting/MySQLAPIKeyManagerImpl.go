@@ -0,0 +1,379 @@
+package accounting
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/hyperjumptech/acccore"
+	"github.com/hyperjumptech/bookkeeping/internal/connector"
+	"github.com/hyperjumptech/bookkeeping/internal/contextkeys"
+	"github.com/hyperjumptech/bookkeeping/internal/middlewares"
+)
+
+// API KEY MANAGER ------------------------------------------------------------------
+
+// errSigningKeySeal is returned when a sealed signing key can not be opened, it is sealed with another seal secret
+var errSigningKeySeal = errors.New("signing key can not be opened with the seal secret")
+
+// NewMySQLAPIKeyManager returns new sql api key manager, the signing keys of the api keys are sealed with the sealSecret.
+func NewMySQLAPIKeyManager(repo connector.DBRepository, idGenerator acccore.UniqueIDGenerator, sealSecret string) APIKeyManager {
+	sealKey := sha256.Sum256([]byte(sealSecret))
+	return &MySQLAPIKeyManager{repo: repo, idGenerator: idGenerator, sealKey: sealKey[:]}
+}
+
+// MySQLAPIKeyManager implementation of APIKeyManager using the api_keys table in MySQL.
+type MySQLAPIKeyManager struct {
+	repo        connector.DBRepository
+	idGenerator acccore.UniqueIDGenerator
+	// sealKey is the AES-256 key the signing keys are sealed with
+	sealKey []byte
+}
+
+// hashAPIKeySecret returns the hex encoded SHA-256 of the secret, the secret is random so it does not need a slow hash.
+// The hash is only used to verify the secret, the client signs its requests with middlewares.SigningKey of the secret.
+func hashAPIKeySecret(secret string) string {
+	h := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(h[:])
+}
+
+// sealSigningKey encrypts the signing key with AES-256-GCM, so the stored keys do not sign requests without the seal secret.
+func (km *MySQLAPIKeyManager) sealSigningKey(signingKey string) (string, error) {
+	block, err := aes.NewCipher(km.sealKey)
+	if err != nil {
+		return "", err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(gcm.Seal(nonce, nonce, []byte(signingKey), nil)), nil
+}
+
+// openSigningKey decrypts the signing key sealed by sealSigningKey
+func (km *MySQLAPIKeyManager) openSigningKey(sealed string) (string, error) {
+	block, err := aes.NewCipher(km.sealKey)
+	if err != nil {
+		return "", err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", err
+	}
+	data, err := base64.RawURLEncoding.DecodeString(sealed)
+	if err != nil || len(data) < gcm.NonceSize() {
+		return "", errSigningKeySeal
+	}
+	signingKey, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+	if err != nil {
+		return "", errSigningKeySeal
+	}
+	return string(signingKey), nil
+}
+
+// validateAPIKey makes sure the client id is well formed, the scopes of the key are known scopes or roles, and it is not already expired
+func validateAPIKey(key *APIKey) error {
+	if !middlewares.ClientIDPattern.MatchString(key.ClientID) {
+		return ErrInvalidAPIKey
+	}
+	for _, scope := range key.Scopes {
+		if !middlewares.KnownScope(scope) {
+			return ErrInvalidAPIKey
+		}
+	}
+	if key.ExpiresAt != nil && !key.ExpiresAt.After(time.Now()) {
+		return ErrInvalidAPIKey
+	}
+	return nil
+}
+
+// apiKeyFromRecord converts the record into APIKey, without the key itself
+func apiKeyFromRecord(rec *connector.APIKeyRecord) *APIKey {
+	scopes := make([]string, 0)
+	if len(rec.Scopes) > 0 {
+		scopes = strings.Split(rec.Scopes, ",")
+	}
+	return &APIKey{
+		KeyID:       rec.KeyID,
+		ClientID:    rec.ClientID,
+		TenantID:    rec.TenantID,
+		Scopes:      scopes,
+		ExpiresAt:   rec.ExpiresAt,
+		Description: rec.Description,
+		Revoked:     rec.Revoked,
+		RevokedAt:   rec.RevokedAt,
+		ReplacedBy:  rec.ReplacedBy,
+		CreateTime:  rec.CreatedAt,
+		CreateBy:    rec.CreatedBy,
+	}
+}
+
+// insertKey generates the secret of a new key and stores its hash. The returned key carries the key to hand over to the client.
+func (km *MySQLAPIKeyManager) insertKey(ctx context.Context, key *APIKey) (*APIKey, error) {
+	requestID := ctx.Value(contextkeys.XRequestID).(string)
+	lLog := dbLog.WithField("RequestID", requestID).WithField("function", "insertKey")
+
+	secretBytes := make([]byte, 32)
+	if _, err := rand.Read(secretBytes); err != nil {
+		lLog.Errorf("error while generating api key secret. got %s", err.Error())
+		return nil, err
+	}
+	secret := base64.RawURLEncoding.EncodeToString(secretBytes)
+	signingKey, err := km.sealSigningKey(middlewares.SigningKey(secret))
+	if err != nil {
+		lLog.Errorf("error while sealing api key signing key. got %s", err.Error())
+		return nil, err
+	}
+	rec := &connector.APIKeyRecord{
+		KeyID:       km.idGenerator.NewUniqueID(),
+		TenantID:    key.TenantID,
+		ClientID:    key.ClientID,
+		KeyHash:     hashAPIKeySecret(secret),
+		SigningKey:  signingKey,
+		Scopes:      strings.Join(key.Scopes, ","),
+		Description: key.Description,
+		ExpiresAt:   key.ExpiresAt,
+	}
+	err = km.repo.InsertAPIKey(ctx, rec)
+	if err != nil {
+		lLog.Errorf("error while calling km.repo.InsertAPIKey. got %s", err.Error())
+		return nil, err
+	}
+	ret := apiKeyFromRecord(rec)
+	ret.APIKey = middlewares.FormatAPIKey(rec.KeyID, secret)
+	return ret, nil
+}
+
+// IssueKey issues a new key for the client, with the scopes, expiry and description of the specified key.
+// The key is issued for the tenant of the specified key, or the tenant of the caller if it has none.
+func (km *MySQLAPIKeyManager) IssueKey(ctx context.Context, key *APIKey, creator string) (*APIKey, error) {
+	requestID := ctx.Value(contextkeys.XRequestID).(string)
+	lLog := dbLog.WithField("RequestID", requestID).WithField("function", "IssueKey")
+
+	if err := validateAPIKey(key); err != nil {
+		return nil, err
+	}
+	if len(key.TenantID) == 0 {
+		key.TenantID = connector.TenantFromContext(ctx)
+	}
+	// the default tenant is always there, the books of the requests without a tenant
+	if key.TenantID != connector.DefaultTenantID {
+		tenant, err := km.repo.GetTenant(ctx, key.TenantID)
+		if err != nil {
+			lLog.Errorf("error while calling km.repo.GetTenant. got %s", err.Error())
+			return nil, err
+		}
+		if tenant == nil {
+			return nil, ErrTenantNotFound
+		}
+	}
+	return km.insertKey(context.WithValue(ctx, contextkeys.UserIDContextKey, creator), key)
+}
+
+// GetKey returns the api key of the specified key id, the keys of the other tenants are not found.
+func (km *MySQLAPIKeyManager) GetKey(ctx context.Context, keyID string) (*APIKey, error) {
+	requestID := ctx.Value(contextkeys.XRequestID).(string)
+	lLog := dbLog.WithField("RequestID", requestID).WithField("function", "GetKey")
+
+	rec, err := km.repo.GetAPIKey(ctx, keyID)
+	if err != nil {
+		lLog.Errorf("error while calling km.repo.GetAPIKey. got %s", err.Error())
+		return nil, err
+	}
+	// the key is looked up across the tenants, the keys of the other tenants are not to be seen
+	if rec == nil || rec.TenantID != connector.TenantFromContext(ctx) {
+		return nil, ErrAPIKeyNotFound
+	}
+	return apiKeyFromRecord(rec), nil
+}
+
+// ListKeys list the keys of the client, latest first. An empty clientID lists the keys of all clients.
+func (km *MySQLAPIKeyManager) ListKeys(ctx context.Context, clientID string) ([]*APIKey, error) {
+	requestID := ctx.Value(contextkeys.XRequestID).(string)
+	lLog := dbLog.WithField("RequestID", requestID).WithField("function", "ListKeys")
+
+	recs, err := km.repo.ListAPIKeys(ctx, clientID)
+	if err != nil {
+		lLog.Errorf("error while calling km.repo.ListAPIKeys. got %s", err.Error())
+		return nil, err
+	}
+	ret := make([]*APIKey, len(recs))
+	for i, rec := range recs {
+		ret[i] = apiKeyFromRecord(rec)
+	}
+	return ret, nil
+}
+
+// RotateKey issues a new key in place of the specified key, with the same client, scopes and description,
+// and revokes the old key. The new key expires at expiresAt, or never if it is nil.
+func (km *MySQLAPIKeyManager) RotateKey(ctx context.Context, keyID string, expiresAt *time.Time, creator string) (*APIKey, error) {
+	requestID := ctx.Value(contextkeys.XRequestID).(string)
+	lLog := dbLog.WithField("RequestID", requestID).WithField("function", "RotateKey")
+
+	old, err := km.GetKey(ctx, keyID)
+	if err != nil {
+		return nil, err
+	}
+	if old.Revoked {
+		return nil, ErrAPIKeyRevoked
+	}
+	key := &APIKey{
+		ClientID:    old.ClientID,
+		TenantID:    old.TenantID,
+		Scopes:      old.Scopes,
+		Description: old.Description,
+		ExpiresAt:   expiresAt,
+	}
+	if err := validateAPIKey(key); err != nil {
+		return nil, err
+	}
+
+	// BEGIN transaction, the old key is only revoked if the new key is issued.
+	tx, err := km.repo.DB().BeginTxx(ctx, nil)
+	if err != nil {
+		lLog.Errorf("error creating transaction. got %s", err.Error())
+		return nil, err
+	}
+	txCtx := context.WithValue(context.WithValue(ctx, contextkeys.UserIDContextKey, creator), contextkeys.DBTransactionContextKey, tx)
+	rollback := func(err error) (*APIKey, error) {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			lLog.Errorf("error rolling back transaction. got %s", rbErr.Error())
+		}
+		return nil, err
+	}
+	ret, err := km.insertKey(txCtx, key)
+	if err != nil {
+		return rollback(err)
+	}
+	revoked, err := km.repo.RevokeAPIKey(txCtx, keyID, ret.KeyID)
+	if err != nil {
+		lLog.Errorf("error while calling km.repo.RevokeAPIKey. got %s", err.Error())
+		return rollback(err)
+	}
+	if !revoked {
+		// revoked concurrently
+		return rollback(ErrAPIKeyRevoked)
+	}
+	if err := tx.Commit(); err != nil {
+		lLog.Errorf("error committing transaction. got %s", err.Error())
+		return nil, err
+	}
+	return ret, nil
+}
+
+// RevokeKey revokes the specified key, it is refused from then on.
+func (km *MySQLAPIKeyManager) RevokeKey(ctx context.Context, keyID string, creator string) error {
+	requestID := ctx.Value(contextkeys.XRequestID).(string)
+	lLog := dbLog.WithField("RequestID", requestID).WithField("function", "RevokeKey")
+
+	revoked, err := km.repo.RevokeAPIKey(context.WithValue(ctx, contextkeys.UserIDContextKey, creator), keyID, "")
+	if err != nil {
+		lLog.Errorf("error while calling km.repo.RevokeAPIKey. got %s", err.Error())
+		return err
+	}
+	if !revoked {
+		if _, err := km.GetKey(ctx, keyID); err != nil {
+			return err
+		}
+		return ErrAPIKeyRevoked
+	}
+	return nil
+}
+
+// validAPIKey retrieves the key of the specified keyID, it returns nil if the key is not found, revoked or expired.
+func (km *MySQLAPIKeyManager) validAPIKey(ctx context.Context, keyID string) (*connector.APIKeyRecord, error) {
+	requestID := ctx.Value(contextkeys.XRequestID).(string)
+	lLog := dbLog.WithField("RequestID", requestID).WithField("function", "validAPIKey")
+
+	rec, err := km.repo.GetAPIKey(ctx, keyID)
+	if err != nil {
+		lLog.Errorf("error while calling km.repo.GetAPIKey. got %s", err.Error())
+		return nil, err
+	}
+	if rec == nil {
+		lLog.Warnf("api key %s is not found", keyID)
+		return nil, nil
+	}
+	if rec.Revoked {
+		lLog.Warnf("api key %s is revoked", keyID)
+		return nil, nil
+	}
+	if rec.ExpiresAt != nil && !rec.ExpiresAt.After(time.Now()) {
+		lLog.Warnf("api key %s is expired", keyID)
+		return nil, nil
+	}
+	return rec, nil
+}
+
+// clientOfAPIKey returns the client authenticated by the key
+func clientOfAPIKey(rec *connector.APIKeyRecord) *middlewares.Client {
+	key := apiKeyFromRecord(rec)
+	return &middlewares.Client{
+		ClientID: key.ClientID,
+		KeyID:    key.KeyID,
+		Scopes:   key.Scopes,
+		TenantID: key.TenantID,
+	}
+}
+
+// AuthenticateAPIKey returns the client owning the key if the secret matches, and the key is neither revoked nor expired.
+// It returns nil client if the key is not valid.
+func (km *MySQLAPIKeyManager) AuthenticateAPIKey(ctx context.Context, keyID, secret string) (*middlewares.Client, error) {
+	requestID := ctx.Value(contextkeys.XRequestID).(string)
+	lLog := dbLog.WithField("RequestID", requestID).WithField("function", "AuthenticateAPIKey")
+
+	rec, err := km.validAPIKey(ctx, keyID)
+	if err != nil || rec == nil {
+		return nil, err
+	}
+	if subtle.ConstantTimeCompare([]byte(rec.KeyHash), []byte(hashAPIKeySecret(secret))) != 1 {
+		lLog.Warnf("api key %s secret mismatch", keyID)
+		return nil, nil
+	}
+	return clientOfAPIKey(rec), nil
+}
+
+// APIKeyClient returns the client owning the key if the key is neither revoked nor expired.
+// It returns nil client if the key is not valid.
+func (km *MySQLAPIKeyManager) APIKeyClient(ctx context.Context, keyID string) (*middlewares.Client, error) {
+	rec, err := km.validAPIKey(ctx, keyID)
+	if err != nil || rec == nil {
+		return nil, err
+	}
+	return clientOfAPIKey(rec), nil
+}
+
+// APIKeySigningKey returns the client owning the key and the key its requests are signed with,
+// if the key is neither revoked nor expired. It returns nil client if the key is not valid,
+// or has no signing key since it was issued before the signing keys were sealed.
+func (km *MySQLAPIKeyManager) APIKeySigningKey(ctx context.Context, keyID string) (*middlewares.Client, string, error) {
+	requestID := ctx.Value(contextkeys.XRequestID).(string)
+	lLog := dbLog.WithField("RequestID", requestID).WithField("function", "APIKeySigningKey")
+
+	rec, err := km.validAPIKey(ctx, keyID)
+	if err != nil || rec == nil {
+		return nil, "", err
+	}
+	if len(rec.SigningKey) == 0 {
+		lLog.Warnf("api key %s has no signing key, it must be rotated to sign requests", keyID)
+		return nil, "", nil
+	}
+	signingKey, err := km.openSigningKey(rec.SigningKey)
+	if err != nil {
+		lLog.Errorf("error while opening the signing key of api key %s. got %s", keyID, err.Error())
+		return nil, "", err
+	}
+	return clientOfAPIKey(rec), signingKey, nil
+}
@@ -0,0 +1,409 @@
+package accounting
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"html"
+	"net/http"
+	"strings"
+
+	"github.com/hyperjumptech/acccore"
+	"github.com/hyperjumptech/bookkeeping/internal/connector"
+	"github.com/hyperjumptech/bookkeeping/internal/contextkeys"
+)
+
+// APPROVAL MANAGER ------------------------------------------------------------------
+
+// NewMySQLApprovalManager returns new sql approval manager. Approved journals are persisted using the same persisting rules as MySQLJournalManager.
+func NewMySQLApprovalManager(repo connector.DBRepository, idGenerator acccore.UniqueIDGenerator) ApprovalManager {
+	return &MySQLApprovalManager{
+		repo:           repo,
+		journalManager: &MySQLJournalManager{repo: repo},
+		idGenerator:    idGenerator,
+	}
+}
+
+// MySQLApprovalManager implementation of ApprovalManager using the pending_journals and approval_rules table in MySQL.
+type MySQLApprovalManager struct {
+	repo           connector.DBRepository
+	journalManager *MySQLJournalManager
+	idGenerator    acccore.UniqueIDGenerator
+}
+
+// pendingJournalFromRecord converts the PendingJournalRecord into PendingJournal
+func pendingJournalFromRecord(rec *connector.PendingJournalRecord) (*PendingJournal, error) {
+	ret := &PendingJournal{
+		PendingID:         rec.PendingID,
+		Description:       rec.Description,
+		Transactions:      make([]*TransactionRequest, 0),
+		Metadata:          decodeMetadata(rec.Metadata),
+		ExternalReference: rec.ExternalReference,
+		Amount:            rec.Amount,
+		Status:            rec.Status,
+		JournalID:         rec.JournalID,
+		ReviewNote:        rec.ReviewNote,
+		SubmittedAt:       rec.SubmittedAt,
+		SubmittedBy:       rec.SubmittedBy,
+		ReviewedAt:        rec.ReviewedAt,
+		ReviewedBy:        rec.ReviewedBy,
+	}
+	err := json.Unmarshal([]byte(rec.Transactions), &ret.Transactions)
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// approvalRuleFromRecord converts the ApprovalRuleRecord into ApprovalRule
+func approvalRuleFromRecord(rec *connector.ApprovalRuleRecord) *ApprovalRule {
+	return &ApprovalRule{
+		RuleID:      rec.RuleID,
+		Description: rec.Description,
+		MinAmount:   rec.MinAmount,
+		Coa:         rec.Coa,
+		CreateTime:  rec.CreatedAt,
+		CreateBy:    rec.CreatedBy,
+	}
+}
+
+// matchApprovalRule check if a journal of the specified amount, posting into the accounts, matches the approval rule.
+func matchApprovalRule(rule *connector.ApprovalRuleRecord, amount int64, accounts map[string]*connector.AccountRecord) bool {
+	if rule.MinAmount != nil && amount < *rule.MinAmount {
+		return false
+	}
+	if len(rule.Coa) == 0 {
+		return true
+	}
+	for _, account := range accounts {
+		if strings.HasPrefix(account.Coa, rule.Coa) {
+			return true
+		}
+	}
+	return false
+}
+
+// reviewerName returns the reviewer the way it is recorded as submitter, so both can be compared.
+func reviewerName(reviewer string) string {
+	if len(reviewer) > 16 {
+		reviewer = reviewer[:16]
+	}
+	return html.EscapeString(reviewer)
+}
+
+// RequiresApproval check if the journal matches any of the approval rules.
+func (am *MySQLApprovalManager) RequiresApproval(ctx context.Context, journal *CreateJournalRequest) (bool, error) {
+	return journalRequiresApproval(ctx, am.repo, journal)
+}
+
+// journalRequiresApproval check if the journal request matches any of the approval rules.
+func journalRequiresApproval(ctx context.Context, repo connector.DBRepository, journal *CreateJournalRequest) (bool, error) {
+	var amount int64
+	accountNumbers := make([]string, 0, len(journal.Transactions))
+	for _, trx := range journal.Transactions {
+		if strings.ToUpper(trx.Alignment) == "DEBIT" {
+			amount += trx.Amount
+		}
+		accountNumbers = append(accountNumbers, trx.AccountNumber)
+	}
+	return requiresApproval(ctx, repo, amount, accountNumbers)
+}
+
+// requiresApproval check if a journal of the specified amount, posting into the accounts, matches any of the approval rules.
+// The journals posted without a request of their own, by a recurring journal or a hold capture, are checked with it too.
+func requiresApproval(ctx context.Context, repo connector.DBRepository, amount int64, accountNumbers []string) (bool, error) {
+	requestID := ctx.Value(contextkeys.XRequestID).(string)
+	lLog := dbLog.WithField("RequestID", requestID).WithField("function", "RequiresApproval")
+
+	rules, err := repo.ListApprovalRules(ctx)
+	if err != nil {
+		lLog.Errorf("error while calling repo.ListApprovalRules. got %s", err.Error())
+		return false, err
+	}
+	if len(rules) == 0 {
+		return false, nil
+	}
+
+	accounts := make(map[string]*connector.AccountRecord)
+	for _, accountNumber := range accountNumbers {
+		if _, ok := accounts[accountNumber]; ok {
+			continue
+		}
+		account, err := repo.GetAccount(ctx, accountNumber)
+		if err != nil {
+			lLog.Errorf("error while calling repo.GetAccount. got %s", err.Error())
+			return false, err
+		}
+		// unknown account is left for the journal manager to reject
+		if account != nil {
+			accounts[accountNumber] = account
+		}
+	}
+	for _, rule := range rules {
+		if matchApprovalRule(rule, amount, accounts) {
+			lLog.Debugf("journal matches approval rule %s", rule.RuleID)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// SubmitJournal validates the journal and stores it as pending, waiting for approval.
+// The idempotency key of the request, if any, is stored in the same database transaction as the pending journal.
+func (am *MySQLApprovalManager) SubmitJournal(ctx context.Context, journal *CreateJournalRequest) (*PendingJournal, error) {
+	requestID := ctx.Value(contextkeys.XRequestID).(string)
+	lLog := dbLog.WithField("RequestID", requestID).WithField("function", "SubmitJournal")
+
+	_, amount, err := validateJournalRequest(ctx, am.repo, journal)
+	if err != nil {
+		lLog.Errorf("error submitting journal. got %s", err.Error())
+		return nil, err
+	}
+	transactions, err := json.Marshal(journal.Transactions)
+	if err != nil {
+		return nil, err
+	}
+	rec := &connector.PendingJournalRecord{
+		PendingID:         am.idGenerator.NewUniqueID(),
+		Description:       journal.Description,
+		Transactions:      string(transactions),
+		Metadata:          encodeMetadata(journal.Metadata),
+		ExternalReference: journal.ExternalReference,
+		Amount:            amount,
+		Status:            connector.PendingJournalStatusPending,
+	}
+	tx, err := am.repo.DB().BeginTxx(ctx, nil)
+	if err != nil {
+		lLog.Errorf("error creating transaction. got %s", err.Error())
+		return nil, err
+	}
+	txCtx := context.WithValue(context.WithValue(ctx, contextkeys.UserIDContextKey, journal.Creator), contextkeys.DBTransactionContextKey, tx)
+	_, err = am.repo.InsertPendingJournal(txCtx, rec)
+	if err != nil {
+		lLog.Errorf("error while calling am.repo.InsertPendingJournal. got %s", err.Error())
+	} else {
+		err = claimIdempotencyKey(txCtx, am.repo, rec.PendingID, http.StatusAccepted)
+	}
+	if err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			lLog.Errorf("error rolling back transaction. got %s", rbErr.Error())
+		}
+		return nil, err
+	}
+	err = tx.Commit()
+	if err != nil {
+		lLog.Errorf("error committing transaction. got %s", err.Error())
+		return nil, err
+	}
+	return pendingJournalFromRecord(rec)
+}
+
+// GetPendingJournal returns the pending journal of the specified pendingID
+func (am *MySQLApprovalManager) GetPendingJournal(ctx context.Context, pendingID string) (*PendingJournal, error) {
+	requestID := ctx.Value(contextkeys.XRequestID).(string)
+	lLog := dbLog.WithField("RequestID", requestID).WithField("function", "GetPendingJournal")
+
+	rec, err := am.repo.GetPendingJournal(ctx, pendingID)
+	if err != nil {
+		lLog.Errorf("error while calling am.repo.GetPendingJournal. got %s", err.Error())
+		return nil, err
+	}
+	if rec == nil {
+		return nil, ErrPendingJournalNotFound
+	}
+	return pendingJournalFromRecord(rec)
+}
+
+// ListPendingJournals list the pending journals of the specified status, oldest submission first.
+func (am *MySQLApprovalManager) ListPendingJournals(ctx context.Context, status string, request acccore.PageRequest) (acccore.PageResult, []*PendingJournal, error) {
+	requestID := ctx.Value(contextkeys.XRequestID).(string)
+	lLog := dbLog.WithField("RequestID", requestID).WithField("function", "ListPendingJournals")
+
+	count, err := am.repo.CountPendingJournalsByStatus(ctx, status)
+	if err != nil {
+		lLog.Errorf("error while calling am.repo.CountPendingJournalsByStatus. got %s", err.Error())
+		return acccore.PageResult{}, nil, err
+	}
+	pResult := acccore.PageResultFor(request, count)
+	recs, err := am.repo.ListPendingJournalByStatus(ctx, status, pResult.Offset, pResult.PageSize)
+	if err != nil {
+		lLog.Errorf("error while calling am.repo.ListPendingJournalByStatus. got %s", err.Error())
+		return acccore.PageResult{}, nil, err
+	}
+	ret := make([]*PendingJournal, 0)
+	for _, rec := range recs {
+		pj, err := pendingJournalFromRecord(rec)
+		if err != nil {
+			lLog.Errorf("Error while reading pending journal %s. got %s. skipping", rec.PendingID, err.Error())
+		} else {
+			ret = append(ret, pj)
+		}
+	}
+	return pResult, ret, nil
+}
+
+// getReviewablePendingJournal returns the pending journal to be reviewed by the reviewer,
+// making sure its still pending and the reviewer is not its submitter.
+func (am *MySQLApprovalManager) getReviewablePendingJournal(ctx context.Context, pendingID, reviewer string) (*connector.PendingJournalRecord, error) {
+	if len(reviewer) == 0 {
+		return nil, ErrMissingReviewer
+	}
+	rec, err := am.repo.GetPendingJournal(ctx, pendingID)
+	if err != nil {
+		return nil, err
+	}
+	if rec == nil {
+		return nil, ErrPendingJournalNotFound
+	}
+	if rec.Status != connector.PendingJournalStatusPending {
+		return nil, ErrPendingJournalNotPending
+	}
+	if rec.SubmittedBy == reviewerName(reviewer) {
+		return nil, ErrSelfApproval
+	}
+	return rec, nil
+}
+
+// ApproveJournal posts the pending journal. The reviewer must not be the submitter.
+// The pending journal is marked as approved and the journal is persisted in a single database transaction.
+func (am *MySQLApprovalManager) ApproveJournal(ctx context.Context, pendingID, reviewer, note string) (*PendingJournal, error) {
+	requestID := ctx.Value(contextkeys.XRequestID).(string)
+	lLog := dbLog.WithField("RequestID", requestID).WithField("function", "ApproveJournal")
+	rctx := context.WithValue(ctx, contextkeys.UserIDContextKey, reviewer)
+
+	rec, err := am.getReviewablePendingJournal(rctx, pendingID, reviewer)
+	if err != nil {
+		lLog.Errorf("error approving pending journal %s. got %s", pendingID, err.Error())
+		return nil, err
+	}
+	req := &CreateJournalRequest{
+		Description:       rec.Description,
+		Creator:           rec.SubmittedBy,
+		Metadata:          decodeMetadata(rec.Metadata),
+		ExternalReference: rec.ExternalReference,
+	}
+	err = json.Unmarshal([]byte(rec.Transactions), &req.Transactions)
+	if err != nil {
+		lLog.Errorf("error reading transactions of pending journal %s. got %s", pendingID, err.Error())
+		return nil, err
+	}
+	journal := NewJournalFromRequest(req, am.idGenerator)
+
+	// BEGIN transaction
+	tx, err := am.repo.DB().BeginTxx(ctx, &sql.TxOptions{
+		Isolation: 0,
+		ReadOnly:  false,
+	})
+	if err != nil {
+		lLog.Errorf("error creating transaction. got %s", err.Error())
+		return nil, err
+	}
+	rollback := func(err error) (*PendingJournal, error) {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			lLog.Errorf("error rolling back transaction. got %s", rbErr.Error())
+		}
+		return nil, err
+	}
+
+	// Mark the pending journal as approved first, so no other approval or rejection can take place.
+	approved, err := am.repo.UpdatePendingJournalStatus(context.WithValue(rctx, contextkeys.DBTransactionContextKey, tx), pendingID, connector.PendingJournalStatusPending, connector.PendingJournalStatusApproved, journal.JournalID, note)
+	if err != nil {
+		lLog.Errorf("error while calling am.repo.UpdatePendingJournalStatus. got %s", err.Error())
+		return rollback(err)
+	}
+	if !approved {
+		return rollback(ErrPendingJournalNotPending)
+	}
+
+	jctx := context.WithValue(context.WithValue(ctx, contextkeys.UserIDContextKey, rec.SubmittedBy), contextkeys.DBTransactionContextKey, tx)
+	err = am.journalManager.persistJournal(jctx, journal)
+	if err != nil {
+		lLog.Errorf("error persisting pending journal %s. got %s", pendingID, err.Error())
+		return rollback(err)
+	}
+
+	// COMMIT transaction
+	err = tx.Commit()
+	if err != nil {
+		lLog.Errorf("error committing transaction. got %s", err.Error())
+		return nil, err
+	}
+	return am.GetPendingJournal(ctx, pendingID)
+}
+
+// RejectJournal discards the pending journal. The reviewer must not be the submitter.
+func (am *MySQLApprovalManager) RejectJournal(ctx context.Context, pendingID, reviewer, note string) (*PendingJournal, error) {
+	requestID := ctx.Value(contextkeys.XRequestID).(string)
+	lLog := dbLog.WithField("RequestID", requestID).WithField("function", "RejectJournal")
+	rctx := context.WithValue(ctx, contextkeys.UserIDContextKey, reviewer)
+
+	_, err := am.getReviewablePendingJournal(rctx, pendingID, reviewer)
+	if err != nil {
+		lLog.Errorf("error rejecting pending journal %s. got %s", pendingID, err.Error())
+		return nil, err
+	}
+	rejected, err := am.repo.UpdatePendingJournalStatus(rctx, pendingID, connector.PendingJournalStatusPending, connector.PendingJournalStatusRejected, "", note)
+	if err != nil {
+		lLog.Errorf("error while calling am.repo.UpdatePendingJournalStatus. got %s", err.Error())
+		return nil, err
+	}
+	if !rejected {
+		return nil, ErrPendingJournalNotPending
+	}
+	return am.GetPendingJournal(ctx, pendingID)
+}
+
+// CreateApprovalRule adds a new approval rule.
+func (am *MySQLApprovalManager) CreateApprovalRule(ctx context.Context, rule *ApprovalRule, creator string) (*ApprovalRule, error) {
+	requestID := ctx.Value(contextkeys.XRequestID).(string)
+	lLog := dbLog.WithField("RequestID", requestID).WithField("function", "CreateApprovalRule")
+
+	if rule.MinAmount != nil && *rule.MinAmount < 0 {
+		return nil, ErrInvalidApprovalRule
+	}
+	rec := &connector.ApprovalRuleRecord{
+		RuleID:      am.idGenerator.NewUniqueID(),
+		Description: rule.Description,
+		MinAmount:   rule.MinAmount,
+		Coa:         rule.Coa,
+	}
+	_, err := am.repo.InsertApprovalRule(context.WithValue(ctx, contextkeys.UserIDContextKey, creator), rec)
+	if err != nil {
+		lLog.Errorf("error while calling am.repo.InsertApprovalRule. got %s", err.Error())
+		return nil, err
+	}
+	return approvalRuleFromRecord(rec), nil
+}
+
+// ListApprovalRules list all approval rules.
+func (am *MySQLApprovalManager) ListApprovalRules(ctx context.Context) ([]*ApprovalRule, error) {
+	requestID := ctx.Value(contextkeys.XRequestID).(string)
+	lLog := dbLog.WithField("RequestID", requestID).WithField("function", "ListApprovalRules")
+
+	recs, err := am.repo.ListApprovalRules(ctx)
+	if err != nil {
+		lLog.Errorf("error while calling am.repo.ListApprovalRules. got %s", err.Error())
+		return nil, err
+	}
+	ret := make([]*ApprovalRule, len(recs))
+	for i, rec := range recs {
+		ret[i] = approvalRuleFromRecord(rec)
+	}
+	return ret, nil
+}
+
+// DeleteApprovalRule removes an approval rule.
+func (am *MySQLApprovalManager) DeleteApprovalRule(ctx context.Context, ruleID string) error {
+	requestID := ctx.Value(contextkeys.XRequestID).(string)
+	lLog := dbLog.WithField("RequestID", requestID).WithField("function", "DeleteApprovalRule")
+
+	deleted, err := am.repo.DeleteApprovalRule(ctx, ruleID)
+	if err != nil {
+		lLog.Errorf("error while calling am.repo.DeleteApprovalRule. got %s", err.Error())
+		return err
+	}
+	if !deleted {
+		return ErrApprovalRuleNotFound
+	}
+	return nil
+}
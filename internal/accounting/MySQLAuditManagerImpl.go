@@ -0,0 +1,101 @@
+package accounting
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/hyperjumptech/acccore"
+	"github.com/hyperjumptech/bookkeeping/internal/connector"
+	"github.com/hyperjumptech/bookkeeping/internal/contextkeys"
+	"github.com/hyperjumptech/bookkeeping/internal/middlewares"
+)
+
+// AUDIT MANAGER ------------------------------------------------------------------
+
+// NewMySQLAuditManager returns new sql audit manager
+func NewMySQLAuditManager(repo connector.DBRepository) AuditManager {
+	return &MySQLAuditManager{repo: repo}
+}
+
+// MySQLAuditManager implementation of AuditManager using the audit_log table in MySQL.
+type MySQLAuditManager struct {
+	repo connector.DBRepository
+}
+
+// RecordAudit stores the audit record of a write request.
+func (am *MySQLAuditManager) RecordAudit(ctx context.Context, rec *middlewares.AuditRecord) error {
+	requestID := ctx.Value(contextkeys.XRequestID).(string)
+	lLog := dbLog.WithField("RequestID", requestID).WithField("function", "RecordAudit")
+
+	_, err := am.repo.InsertAuditLog(ctx, &connector.AuditLogRecord{
+		Principal:      rec.Principal,
+		RequestID:      rec.RequestID,
+		Method:         rec.Method,
+		Endpoint:       rec.Endpoint,
+		Path:           rec.Path,
+		PayloadDigest:  rec.PayloadDigest,
+		BeforeSnapshot: rec.Before,
+		AfterSnapshot:  rec.After,
+		StatusCode:     rec.StatusCode,
+		Outcome:        rec.Outcome,
+		CreatedAt:      rec.CreatedAt,
+	})
+	if err != nil {
+		lLog.Errorf("error while calling am.repo.InsertAuditLog. got %s", err.Error())
+		return err
+	}
+	return nil
+}
+
+// ListAuditLogs list the audit records matching the filter, latest first.
+func (am *MySQLAuditManager) ListAuditLogs(ctx context.Context, filter *AuditLogFilter, request acccore.PageRequest) (acccore.PageResult, []*AuditLogEntry, error) {
+	requestID := ctx.Value(contextkeys.XRequestID).(string)
+	lLog := dbLog.WithField("RequestID", requestID).WithField("function", "ListAuditLogs")
+
+	recFilter := &connector.AuditLogFilter{}
+	if filter != nil {
+		recFilter = &connector.AuditLogFilter{
+			Principal: filter.Principal,
+			RequestID: filter.RequestID,
+			Method:    filter.Method,
+			Endpoint:  filter.Endpoint,
+			Outcome:   filter.Outcome,
+			From:      filter.From,
+			To:        filter.To,
+		}
+	}
+	count, err := am.repo.CountAuditLogs(ctx, recFilter)
+	if err != nil {
+		lLog.Errorf("error while calling am.repo.CountAuditLogs. got %s", err.Error())
+		return acccore.PageResult{}, nil, err
+	}
+	pResult := acccore.PageResultFor(request, count)
+	recs, err := am.repo.ListAuditLogs(ctx, recFilter, pResult.Offset, pResult.PageSize)
+	if err != nil {
+		lLog.Errorf("error while calling am.repo.ListAuditLogs. got %s", err.Error())
+		return acccore.PageResult{}, nil, err
+	}
+	ret := make([]*AuditLogEntry, len(recs))
+	for i, rec := range recs {
+		ret[i] = &AuditLogEntry{
+			AuditID:       rec.AuditID,
+			Principal:     rec.Principal,
+			RequestID:     rec.RequestID,
+			Method:        rec.Method,
+			Endpoint:      rec.Endpoint,
+			Path:          rec.Path,
+			PayloadDigest: rec.PayloadDigest,
+			StatusCode:    rec.StatusCode,
+			Outcome:       rec.Outcome,
+			CreateTime:    rec.CreatedAt.Format(time.RFC3339),
+		}
+		if len(rec.BeforeSnapshot) > 0 {
+			ret[i].Before = json.RawMessage(rec.BeforeSnapshot)
+		}
+		if len(rec.AfterSnapshot) > 0 {
+			ret[i].After = json.RawMessage(rec.AfterSnapshot)
+		}
+	}
+	return pResult, ret, nil
+}
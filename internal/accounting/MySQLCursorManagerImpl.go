@@ -0,0 +1,96 @@
+package accounting
+
+import (
+	"context"
+	"time"
+
+	"github.com/hyperjumptech/acccore"
+	"github.com/hyperjumptech/bookkeeping/internal/connector"
+	"github.com/hyperjumptech/bookkeeping/internal/contextkeys"
+)
+
+// CURSOR MANAGER ------------------------------------------------------------------
+
+// NewMySQLCursorManager returns new sql cursor manager. The journals listed are loaded using the journalManager.
+func NewMySQLCursorManager(repo connector.DBRepository, journalManager acccore.JournalManager) CursorManager {
+	return &MySQLCursorManager{repo: repo, journalManager: journalManager}
+}
+
+// MySQLCursorManager implementation of CursorManager using keyset pagination on the journals and transactions tables in MySQL
+type MySQLCursorManager struct {
+	repo           connector.DBRepository
+	journalManager acccore.JournalManager
+}
+
+// ListJournalsAfter lists up to size journals between the `from` and `until` time range whose metadata matches
+// the filter, in journaling time order, after the cursor.
+func (cm *MySQLCursorManager) ListJournalsAfter(ctx context.Context, from time.Time, until time.Time, filter Metadata, cursor string, size int) (CursorPage, []acccore.Journal, error) {
+	requestID := ctx.Value(contextkeys.XRequestID).(string)
+	lLog := dbLog.WithField("RequestID", requestID).WithField("function", "ListJournalsAfter")
+
+	after, err := decodeCursor(cursor)
+	if err != nil {
+		return CursorPage{}, nil, err
+	}
+	if size < 1 {
+		size = 1
+	}
+	// read one more journal than asked, to tell if there are more to come.
+	jRecords, err := cm.repo.ListJournalByTimeRangeAfter(ctx, from, until, connector.MetadataFilter(filter), after, size+1)
+	if err != nil {
+		lLog.Errorf("error while calling cm.repo.ListJournalByTimeRangeAfter. got %s", err.Error())
+		return CursorPage{}, nil, err
+	}
+	page := CursorPage{NextCursor: cursor, HasMore: len(jRecords) > size}
+	if page.HasMore {
+		jRecords = jRecords[:size]
+	}
+	ret := make([]acccore.Journal, 0, len(jRecords))
+	for _, jrnl := range jRecords {
+		journal, err := cm.journalManager.GetJournalByID(ctx, jrnl.JournalID)
+		if err != nil {
+			lLog.Errorf("Error while retrieving journal %s. got %s. skipping", jrnl.JournalID, err.Error())
+		} else {
+			ret = append(ret, journal)
+		}
+	}
+	if len(jRecords) > 0 {
+		last := jRecords[len(jRecords)-1]
+		page.NextCursor = encodeCursor(last.JournalingTime, last.JournalID)
+	}
+	return page, ret, nil
+}
+
+// ListTransactionsOnAccountAfter lists up to size transactions of the account between the `from` and `until`
+// time range whose metadata matches the filter, in transaction time order, after the cursor.
+func (cm *MySQLCursorManager) ListTransactionsOnAccountAfter(ctx context.Context, from time.Time, until time.Time, accountNumber string, filter Metadata, cursor string, size int) (CursorPage, []acccore.Transaction, error) {
+	requestID := ctx.Value(contextkeys.XRequestID).(string)
+	lLog := dbLog.WithField("RequestID", requestID).WithField("function", "ListTransactionsOnAccountAfter")
+
+	after, err := decodeCursor(cursor)
+	if err != nil {
+		return CursorPage{}, nil, err
+	}
+	if size < 1 {
+		size = 1
+	}
+	// read one more transaction than asked, to tell if there are more to come.
+	records, err := cm.repo.ListTransactionByAccountNumberAfter(ctx, accountNumber, from, until, connector.MetadataFilter(filter), after, size+1)
+	if err != nil {
+		lLog.Errorf("error while calling cm.repo.ListTransactionByAccountNumberAfter. got %s", err.Error())
+		return CursorPage{}, nil, err
+	}
+	page := CursorPage{NextCursor: cursor, HasMore: len(records) > size}
+	if page.HasMore {
+		records = records[:size]
+	}
+	ret := make([]acccore.Transaction, 0, len(records))
+	for _, tx := range records {
+		ret = append(ret, transactionFromRecord(tx))
+	}
+	if len(records) > 0 {
+		last := records[len(records)-1]
+		page.NextCursor = encodeCursor(last.TransactionTime, last.TransactionID)
+	}
+	return page, ret, nil
+}
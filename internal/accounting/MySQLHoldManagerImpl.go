@@ -0,0 +1,382 @@
+package accounting
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hyperjumptech/acccore"
+	"github.com/hyperjumptech/bookkeeping/internal/connector"
+	"github.com/hyperjumptech/bookkeeping/internal/contextkeys"
+)
+
+// HOLD MANAGER ------------------------------------------------------------------
+
+// NewMySQLHoldManager returns new sql hold manager. Captured holds are persisted as journal using the same persisting rules as MySQLJournalManager.
+func NewMySQLHoldManager(repo connector.DBRepository, idGenerator acccore.UniqueIDGenerator) HoldManager {
+	return &MySQLHoldManager{repo: repo, journalManager: &MySQLJournalManager{repo: repo}, idGenerator: idGenerator}
+}
+
+// MySQLHoldManager implementation of HoldManager using the holds table in MySQL
+type MySQLHoldManager struct {
+	repo           connector.DBRepository
+	journalManager *MySQLJournalManager
+	idGenerator    acccore.UniqueIDGenerator
+}
+
+// holdFromRecord converts the HoldRecord into Hold
+func holdFromRecord(rec *connector.HoldRecord) *Hold {
+	return &Hold{
+		HoldID:        rec.HoldID,
+		AccountNumber: rec.AccountNumber,
+		Description:   rec.Description,
+		Amount:        rec.Amount,
+		Status:        rec.Status,
+		ExpiresAt:     rec.ExpiresAt,
+		JournalID:     rec.JournalID,
+		CreateTime:    rec.CreatedAt,
+		CreateBy:      rec.CreatedBy,
+	}
+}
+
+// withdrawalAlignment returns the transaction alignment that reduces the balance of the account
+func withdrawalAlignment(account *connector.AccountRecord) acccore.Alignment {
+	if strings.ToUpper(account.Alignment) == "DEBIT" {
+		return acccore.CREDIT
+	}
+	return acccore.DEBIT
+}
+
+// checkHoldLimits make sure the account can hold the amount on top of the amount it already holds. An account with neither
+// minimum balance nor overdraft limit can not hold more than its available balance, the others hold within their limits.
+func checkHoldLimits(account *connector.AccountRecord, amount, held int64) error {
+	if account.MinBalance == nil && account.OverdraftLimit == nil {
+		if account.Balance-held-amount < min(0, account.Balance) {
+			return fmt.Errorf("%w : %s", ErrInsufficientBalance, account.AccountNumber)
+		}
+		return nil
+	}
+	return checkAccountLimits(account, account.Balance-amount, held)
+}
+
+// PlaceHold reserves the amount from the account until expiresAt. The hold is rejected if the account
+// available balance can not cover the amount within the account limits, or if the amount on the account
+// matches an approval rule as its capture would then never be posted.
+func (hm *MySQLHoldManager) PlaceHold(ctx context.Context, accountNumber string, amount int64, description string, expiresAt time.Time, creator string) (*Hold, error) {
+	requestID := ctx.Value(contextkeys.XRequestID).(string)
+	lLog := dbLog.WithField("RequestID", requestID).WithField("function", "PlaceHold")
+
+	if amount <= 0 {
+		lLog.Errorf("error placing hold on account %s. amount %d is not positive", accountNumber, amount)
+		return nil, ErrInvalidHoldAmount
+	}
+	if !expiresAt.After(time.Now()) {
+		lLog.Errorf("error placing hold on account %s. hold is already expired at %s", accountNumber, expiresAt)
+		return nil, ErrHoldExpired
+	}
+	needApproval, err := requiresApproval(ctx, hm.repo, amount, []string{accountNumber})
+	if err != nil {
+		lLog.Errorf("error while calling requiresApproval. got %s", err.Error())
+		return nil, err
+	}
+	if needApproval {
+		lLog.Errorf("error placing hold on account %s. amount %d matches an approval rule", accountNumber, amount)
+		return nil, ErrHoldRequiresApproval
+	}
+
+	// BEGIN transaction, the account is locked so concurrent holds and journals can not spend the same fund.
+	tx, err := hm.repo.DB().BeginTxx(ctx, nil)
+	if err != nil {
+		lLog.Errorf("error creating transaction. got %s", err.Error())
+		return nil, err
+	}
+	txCtx := context.WithValue(context.WithValue(ctx, contextkeys.UserIDContextKey, creator), contextkeys.DBTransactionContextKey, tx)
+	rollback := func(err error) (*Hold, error) {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			lLog.Errorf("error rolling back transaction. got %s", rbErr.Error())
+		}
+		return nil, err
+	}
+
+	account, err := hm.repo.GetAccountForUpdate(txCtx, accountNumber)
+	if err != nil {
+		lLog.Errorf("error while calling hm.repo.GetAccountForUpdate. got %s", err.Error())
+		return rollback(err)
+	}
+	if account == nil {
+		return rollback(acccore.ErrAccountIsNotPersisted)
+	}
+	if err := checkAccountStatus(account, withdrawalAlignment(account)); err != nil {
+		lLog.Errorf("error placing hold on account %s. got %s", accountNumber, err.Error())
+		return rollback(err)
+	}
+	held, err := hm.repo.SumActiveHoldsByAccountNumberForUpdate(txCtx, accountNumber, time.Now())
+	if err != nil {
+		lLog.Errorf("error while calling hm.repo.SumActiveHoldsByAccountNumberForUpdate. got %s", err.Error())
+		return rollback(err)
+	}
+	if err := checkHoldLimits(account, amount, held); err != nil {
+		lLog.Errorf("error placing hold on account %s. got %s", accountNumber, err.Error())
+		return rollback(err)
+	}
+
+	rec := &connector.HoldRecord{
+		HoldID:        hm.idGenerator.NewUniqueID(),
+		AccountNumber: accountNumber,
+		Description:   description,
+		Amount:        amount,
+		Status:        connector.HoldStatusActive,
+		ExpiresAt:     expiresAt,
+	}
+	_, err = hm.repo.InsertHold(txCtx, rec)
+	if err != nil {
+		lLog.Errorf("error while calling hm.repo.InsertHold. got %s", err.Error())
+		return rollback(err)
+	}
+
+	// COMMIT transaction
+	err = tx.Commit()
+	if err != nil {
+		lLog.Errorf("error committing transaction. got %s", err.Error())
+		return nil, err
+	}
+	return holdFromRecord(rec), nil
+}
+
+// GetHold returns the hold of the specified holdID
+func (hm *MySQLHoldManager) GetHold(ctx context.Context, holdID string) (*Hold, error) {
+	requestID := ctx.Value(contextkeys.XRequestID).(string)
+	lLog := dbLog.WithField("RequestID", requestID).WithField("function", "GetHold")
+
+	rec, err := hm.repo.GetHold(ctx, holdID)
+	if err != nil {
+		lLog.Errorf("error while calling hm.repo.GetHold. got %s", err.Error())
+		return nil, err
+	}
+	if rec == nil {
+		return nil, ErrHoldNotFound
+	}
+	return holdFromRecord(rec), nil
+}
+
+// ListActiveHolds returns all holds on the account that still reserve the account fund.
+func (hm *MySQLHoldManager) ListActiveHolds(ctx context.Context, accountNumber string) ([]*Hold, error) {
+	requestID := ctx.Value(contextkeys.XRequestID).(string)
+	lLog := dbLog.WithField("RequestID", requestID).WithField("function", "ListActiveHolds")
+
+	recs, err := hm.repo.ListActiveHoldsByAccountNumber(ctx, accountNumber, time.Now())
+	if err != nil {
+		lLog.Errorf("error while calling hm.repo.ListActiveHoldsByAccountNumber. got %s", err.Error())
+		return nil, err
+	}
+	ret := make([]*Hold, len(recs))
+	for i, rec := range recs {
+		ret[i] = holdFromRecord(rec)
+	}
+	return ret, nil
+}
+
+// CaptureHold turns the hold into a journal that moves the amount from the held account into the counter account.
+// Amount of zero captures the whole held amount, capturing less than the held amount releases the rest.
+// The hold is marked as captured and the journal is persisted in a single database transaction.
+func (hm *MySQLHoldManager) CaptureHold(ctx context.Context, holdID, counterAccountNumber string, amount int64, description, creator string) (*Hold, error) {
+	requestID := ctx.Value(contextkeys.XRequestID).(string)
+	lLog := dbLog.WithField("RequestID", requestID).WithField("function", "CaptureHold")
+	hctx := context.WithValue(ctx, contextkeys.UserIDContextKey, creator)
+
+	rec, err := hm.repo.GetHold(hctx, holdID)
+	if err != nil {
+		lLog.Errorf("error while calling hm.repo.GetHold. got %s", err.Error())
+		return nil, err
+	}
+	if rec == nil {
+		return nil, ErrHoldNotFound
+	}
+	if rec.Status != connector.HoldStatusActive {
+		return nil, ErrHoldNotActive
+	}
+	if !rec.ExpiresAt.After(time.Now()) {
+		if _, err := hm.repo.UpdateHoldStatus(hctx, holdID, connector.HoldStatusActive, connector.HoldStatusExpired, ""); err != nil {
+			lLog.Errorf("error while expiring hold %s. got %s", holdID, err.Error())
+		}
+		return nil, ErrHoldExpired
+	}
+	if amount == 0 {
+		amount = rec.Amount
+	}
+	if amount < 0 || amount > rec.Amount {
+		lLog.Errorf("error capturing hold %s. amount %d is not within the held amount %d", holdID, amount, rec.Amount)
+		return nil, ErrInvalidHoldAmount
+	}
+	if len(description) == 0 {
+		description = rec.Description
+	}
+	// nobody reviews the journal of the capture
+	needApproval, err := requiresApproval(hctx, hm.repo, amount, []string{rec.AccountNumber, counterAccountNumber})
+	if err != nil {
+		lLog.Errorf("error while calling requiresApproval. got %s", err.Error())
+		return nil, err
+	}
+	if needApproval {
+		lLog.Errorf("error capturing hold %s. journal matches an approval rule", holdID)
+		return nil, ErrJournalRequiresApproval
+	}
+
+	// BEGIN transaction
+	tx, err := hm.repo.DB().BeginTxx(ctx, &sql.TxOptions{
+		Isolation: 0,
+		ReadOnly:  false,
+	})
+	if err != nil {
+		lLog.Errorf("error creating transaction. got %s", err.Error())
+		return nil, err
+	}
+	txCtx := context.WithValue(hctx, contextkeys.DBTransactionContextKey, tx)
+	rollback := func(err error) (*Hold, error) {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			lLog.Errorf("error rolling back transaction. got %s", rbErr.Error())
+		}
+		return nil, err
+	}
+
+	// Lock both accounts before the hold, in the same order as the journal does, so the capture do not dead lock
+	// with a journal counting the holds of the account.
+	accountNumbers := []string{rec.AccountNumber, counterAccountNumber}
+	sort.Strings(accountNumbers)
+	lockedAccounts := make(map[string]*connector.AccountRecord)
+	for _, accountNumber := range accountNumbers {
+		// non existent counter account is reported by the capturing journal
+		locked, err := hm.repo.GetAccountForUpdate(txCtx, accountNumber)
+		if err != nil {
+			lLog.Errorf("error locking account %s in transaction. got %s", accountNumber, err.Error())
+			return rollback(err)
+		}
+		lockedAccounts[accountNumber] = locked
+	}
+	account := lockedAccounts[rec.AccountNumber]
+	if account == nil {
+		return rollback(acccore.ErrAccountIsNotPersisted)
+	}
+
+	// Mark the hold as captured first, so the fund it reserves is available to the capturing journal,
+	// and no other capture or void can take place.
+	journalID := hm.idGenerator.NewUniqueID()
+	captured, err := hm.repo.UpdateHoldStatus(txCtx, holdID, connector.HoldStatusActive, connector.HoldStatusCaptured, journalID)
+	if err != nil {
+		lLog.Errorf("error while calling hm.repo.UpdateHoldStatus. got %s", err.Error())
+		return rollback(err)
+	}
+	if !captured {
+		return rollback(ErrHoldNotActive)
+	}
+
+	heldAlignment := withdrawalAlignment(account)
+	counterAlignment := acccore.DEBIT
+	if heldAlignment == acccore.DEBIT {
+		counterAlignment = acccore.CREDIT
+	}
+	journal := &acccore.BaseJournal{
+		JournalID:      journalID,
+		JournalingTime: time.Now(),
+		Description:    description,
+		Amount:         amount,
+		CreateTime:     time.Now(),
+		CreatedBy:      creator,
+	}
+	journal.SetTransactions([]acccore.Transaction{
+		&acccore.BaseTransaction{
+			TransactionID:   hm.idGenerator.NewUniqueID(),
+			TransactionTime: time.Now(),
+			AccountNumber:   rec.AccountNumber,
+			JournalID:       journalID,
+			Description:     description,
+			TransactionType: heldAlignment,
+			Amount:          amount,
+			CreateTime:      time.Now(),
+			CreateBy:        creator,
+		},
+		&acccore.BaseTransaction{
+			TransactionID:   hm.idGenerator.NewUniqueID(),
+			TransactionTime: time.Now(),
+			AccountNumber:   counterAccountNumber,
+			JournalID:       journalID,
+			Description:     description,
+			TransactionType: counterAlignment,
+			Amount:          amount,
+			CreateTime:      time.Now(),
+			CreateBy:        creator,
+		},
+	})
+
+	err = hm.journalManager.persistJournal(txCtx, journal)
+	if err != nil {
+		lLog.Errorf("error persisting journal of hold %s. got %s", holdID, err.Error())
+		return rollback(err)
+	}
+
+	// COMMIT transaction
+	err = tx.Commit()
+	if err != nil {
+		lLog.Errorf("error committing transaction. got %s", err.Error())
+		return nil, err
+	}
+	return hm.GetHold(hctx, holdID)
+}
+
+// VoidHold releases the hold.
+func (hm *MySQLHoldManager) VoidHold(ctx context.Context, holdID, creator string) (*Hold, error) {
+	requestID := ctx.Value(contextkeys.XRequestID).(string)
+	lLog := dbLog.WithField("RequestID", requestID).WithField("function", "VoidHold")
+	hctx := context.WithValue(ctx, contextkeys.UserIDContextKey, creator)
+
+	voided, err := hm.repo.UpdateHoldStatus(hctx, holdID, connector.HoldStatusActive, connector.HoldStatusVoided, "")
+	if err != nil {
+		lLog.Errorf("error while calling hm.repo.UpdateHoldStatus. got %s", err.Error())
+		return nil, err
+	}
+	hold, err := hm.GetHold(hctx, holdID)
+	if err != nil {
+		return nil, err
+	}
+	if !voided {
+		return nil, ErrHoldNotActive
+	}
+	return hold, nil
+}
+
+// ExpireHolds releases all holds that have expired, returning the number of released holds.
+func (hm *MySQLHoldManager) ExpireHolds(ctx context.Context) (int64, error) {
+	requestID := ctx.Value(contextkeys.XRequestID).(string)
+	lLog := dbLog.WithField("RequestID", requestID).WithField("function", "ExpireHolds")
+
+	count, err := hm.repo.ExpireHolds(ctx, time.Now())
+	if err != nil {
+		lLog.Errorf("error while calling hm.repo.ExpireHolds. got %s", err.Error())
+		return 0, err
+	}
+	return count, nil
+}
+
+// GetAvailableBalance returns the account balance minus the amount still on hold.
+func (hm *MySQLHoldManager) GetAvailableBalance(ctx context.Context, accountNumber string) (int64, error) {
+	requestID := ctx.Value(contextkeys.XRequestID).(string)
+	lLog := dbLog.WithField("RequestID", requestID).WithField("function", "GetAvailableBalance")
+
+	account, err := hm.repo.GetAccount(ctx, accountNumber)
+	if err != nil {
+		lLog.Errorf("error while calling hm.repo.GetAccount. got %s", err.Error())
+		return 0, err
+	}
+	if account == nil {
+		return 0, acccore.ErrAccountIsNotPersisted
+	}
+	held, err := hm.repo.SumActiveHoldsByAccountNumber(ctx, accountNumber, time.Now())
+	if err != nil {
+		lLog.Errorf("error while calling hm.repo.SumActiveHoldsByAccountNumber. got %s", err.Error())
+		return 0, err
+	}
+	return account.Balance - held, nil
+}
@@ -0,0 +1,71 @@
+package accounting
+
+import (
+	"context"
+	"errors"
+
+	dberrors "github.com/hyperjumptech/bookkeeping/errors"
+	"github.com/hyperjumptech/bookkeeping/internal/connector"
+	"github.com/hyperjumptech/bookkeeping/internal/contextkeys"
+)
+
+// IDEMPOTENCY MANAGER ------------------------------------------------------------------
+
+// NewMySQLIdempotencyManager returns new sql idempotency manager.
+func NewMySQLIdempotencyManager(repo connector.DBRepository) IdempotencyManager {
+	return &MySQLIdempotencyManager{repo: repo}
+}
+
+// MySQLIdempotencyManager implementation of IdempotencyManager using the idempotency_keys table in MySQL.
+type MySQLIdempotencyManager struct {
+	repo connector.DBRepository
+}
+
+// GetIdempotentRequest returns the request the client made with the idempotency key within the scope, or nil if the client
+// have not used the key. ErrInvalidIdempotencyKey is returned for a key longer than 64 characters.
+func (im *MySQLIdempotencyManager) GetIdempotentRequest(ctx context.Context, clientID, scope, key string) (*IdempotentRequest, error) {
+	requestID := ctx.Value(contextkeys.XRequestID).(string)
+	lLog := dbLog.WithField("RequestID", requestID).WithField("function", "GetIdempotentRequest")
+
+	if len(key) == 0 || len(key) > 64 {
+		return nil, ErrInvalidIdempotencyKey
+	}
+	rec, err := im.repo.GetIdempotencyKey(ctx, clientID, scope, key)
+	if err != nil {
+		lLog.Errorf("error while calling im.repo.GetIdempotencyKey. got %s", err.Error())
+		return nil, err
+	}
+	if rec == nil {
+		return nil, nil
+	}
+	return &IdempotentRequest{Digest: rec.RequestDigest, Code: rec.ResponseCode, ResourceID: rec.ResourceID}, nil
+}
+
+// withIdempotencyClaim returns the context carrying the idempotency key claimed by the request.
+func withIdempotencyClaim(ctx context.Context, claim *IdempotencyClaim) context.Context {
+	return context.WithValue(ctx, contextkeys.IdempotencyClaimContextKey, claim)
+}
+
+// claimIdempotencyKey stores the idempotency key claimed by the request in the context, if any, as the key of the resource
+// created within the database transaction carried in the context, so the key is stored if and only if the resource is.
+// ErrIdempotencyKeyTaken is returned when another request of the client stored the key first, the transaction must be
+// rolled back, and the request answered as the other request.
+func claimIdempotencyKey(ctx context.Context, repo connector.DBRepository, resourceID string, code int) error {
+	claim, ok := ctx.Value(contextkeys.IdempotencyClaimContextKey).(*IdempotencyClaim)
+	if !ok || claim == nil {
+		return nil
+	}
+	err := repo.InsertIdempotencyKey(ctx, &connector.IdempotencyKeyRecord{
+		ClientID:       claim.ClientID,
+		Scope:          claim.Scope,
+		IdempotencyKey: claim.Key,
+		RequestDigest:  claim.Digest,
+		ResponseCode:   code,
+		ResourceID:     resourceID,
+	})
+	if errors.Is(err, dberrors.ErrDuplicateKey) {
+		claim.taken = true
+		return ErrIdempotencyKeyTaken
+	}
+	return err
+}
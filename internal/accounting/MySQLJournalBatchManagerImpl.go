@@ -0,0 +1,113 @@
+package accounting
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+
+	"github.com/hyperjumptech/acccore"
+	"github.com/hyperjumptech/bookkeeping/internal/connector"
+	"github.com/hyperjumptech/bookkeeping/internal/contextkeys"
+)
+
+// JOURNAL BATCH MANAGER ------------------------------------------------------------------
+
+// NewMySQLJournalBatchManager returns new sql journal batch manager
+func NewMySQLJournalBatchManager(repo connector.DBRepository) JournalBatchManager {
+	return &MySQLJournalBatchManager{journalManager: &MySQLJournalManager{repo: repo}}
+}
+
+// MySQLJournalBatchManager implementation of JournalBatchManager using the same persisting rules as MySQLJournalManager.
+type MySQLJournalBatchManager struct {
+	journalManager *MySQLJournalManager
+}
+
+// PersistJournals validates and persists all the journals in a single database transaction, in order.
+// Each journal is written under its own savepoint, so a rejected journal is undone before the next one is
+// validated, this way every journal in the batch is checked and reported, not only the first failing one.
+// Each journal is persisted as its own creator.
+func (bm *MySQLJournalBatchManager) PersistJournals(ctx context.Context, journals []acccore.Journal) error {
+	requestID := ctx.Value(contextkeys.XRequestID).(string)
+	lLog := dbLog.WithField("RequestID", requestID).WithField("function", "PersistJournals")
+
+	if len(journals) == 0 {
+		return acccore.ErrJournalNil
+	}
+
+	// BEGIN transaction
+	tx, err := bm.journalManager.repo.DB().BeginTxx(ctx, &sql.TxOptions{
+		Isolation: 0,
+		ReadOnly:  false,
+	})
+	if err != nil {
+		lLog.Errorf("error creating transaction. got %s", err.Error())
+		return err
+	}
+	txCtx := context.WithValue(ctx, contextkeys.DBTransactionContextKey, tx)
+	rollback := func() {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			lLog.Errorf("error rolling back transaction. got %s", rbErr.Error())
+		}
+	}
+
+	// Lock every account in the batch upfront, always in the same order to avoid dead lock between concurrent batches.
+	accountNumbers := make([]string, 0)
+	accountSeen := make(map[string]bool)
+	for _, journal := range journals {
+		if journal == nil {
+			continue
+		}
+		for _, trx := range journal.GetTransactions() {
+			if !accountSeen[trx.GetAccountNumber()] {
+				accountSeen[trx.GetAccountNumber()] = true
+				accountNumbers = append(accountNumbers, trx.GetAccountNumber())
+			}
+		}
+	}
+	sort.Strings(accountNumbers)
+	for _, accountNumber := range accountNumbers {
+		// non existent accounts are reported by the journal posting into it
+		if _, err := bm.journalManager.repo.GetAccountForUpdate(txCtx, accountNumber); err != nil {
+			lLog.Errorf("error locking account %s in transaction. got %s", accountNumber, err.Error())
+			rollback()
+			return err
+		}
+	}
+
+	batchErr := &JournalBatchError{Errors: make(map[int]error)}
+	for idx, journal := range journals {
+		savepoint := fmt.Sprintf("batch_journal_%d", idx)
+		if _, err := tx.ExecContext(txCtx, "SAVEPOINT "+savepoint); err != nil {
+			lLog.Errorf("error creating savepoint %s. got %s", savepoint, err.Error())
+			rollback()
+			return err
+		}
+		journalCtx := txCtx
+		if journal != nil {
+			journalCtx = context.WithValue(txCtx, contextkeys.UserIDContextKey, journal.GetCreateBy())
+		}
+		err := bm.journalManager.persistJournal(journalCtx, journal)
+		if err != nil {
+			lLog.Errorf("journal %d in batch rejected. got %s", idx, err.Error())
+			batchErr.Errors[idx] = err
+			if _, err := tx.ExecContext(txCtx, "ROLLBACK TO SAVEPOINT "+savepoint); err != nil {
+				lLog.Errorf("error rolling back to savepoint %s. got %s", savepoint, err.Error())
+				rollback()
+				return err
+			}
+		}
+	}
+	if len(batchErr.Errors) > 0 {
+		rollback()
+		return batchErr
+	}
+
+	// COMMIT transaction
+	err = tx.Commit()
+	if err != nil {
+		lLog.Errorf("error committing transaction. got %s", err.Error())
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package accounting
+
+import (
+	"context"
+	"sort"
+
+	"github.com/hyperjumptech/acccore"
+	"github.com/hyperjumptech/bookkeeping/internal/connector"
+	"github.com/hyperjumptech/bookkeeping/internal/contextkeys"
+)
+
+// JOURNAL SIMULATION MANAGER ------------------------------------------------------------------
+
+// NewMySQLJournalSimulationManager returns new sql journal simulation manager
+func NewMySQLJournalSimulationManager(repo connector.DBRepository) JournalSimulationManager {
+	return &MySQLJournalSimulationManager{journalManager: &MySQLJournalManager{repo: repo}}
+}
+
+// MySQLJournalSimulationManager implementation of JournalSimulationManager using the same persisting rules as MySQLJournalManager.
+type MySQLJournalSimulationManager struct {
+	journalManager *MySQLJournalManager
+}
+
+// SimulateJournal runs every validation of persisting the journal and returns the projected balance of each account
+// the journal posts into. Nothing is persisted. An error is only returned if the simulation itself fails.
+// The journal is validated by the very validation of PersistJournal, so the simulation can not disagree with it,
+// but on plain reads : no account is locked, no journal number is taken and nothing is written.
+func (sm *MySQLJournalSimulationManager) SimulateJournal(ctx context.Context, journal acccore.Journal) (*JournalSimulation, error) {
+	requestID := ctx.Value(contextkeys.XRequestID).(string)
+	lLog := dbLog.WithField("RequestID", requestID).WithField("function", "SimulateJournal")
+
+	if journal != nil {
+		ctx = context.WithValue(ctx, contextkeys.UserIDContextKey, journal.GetCreateBy())
+	}
+	posting, err := sm.journalManager.validateJournal(ctx, journal, false)
+	if err != nil {
+		lLog.Debugf("simulated journal rejected. got %s", err.Error())
+		return &JournalSimulation{Accepted: false, Error: err.Error(), Balances: make([]*ProjectedBalance, 0)}, nil
+	}
+
+	accountNumbers := make([]string, 0, len(posting.accounts))
+	for accountNumber := range posting.accounts {
+		accountNumbers = append(accountNumbers, accountNumber)
+	}
+	sort.Strings(accountNumbers)
+	balances := make([]*ProjectedBalance, 0, len(accountNumbers))
+	for _, accountNumber := range accountNumbers {
+		account := posting.accounts[accountNumber]
+		balances = append(balances, &ProjectedBalance{
+			AccountNumber:    account.AccountNumber,
+			Currency:         account.CurrencyCode,
+			Alignment:        account.Alignment,
+			Balance:          account.Balance,
+			ProjectedBalance: posting.balances[accountNumber],
+		})
+	}
+	return &JournalSimulation{Accepted: true, Balances: balances}, nil
+}
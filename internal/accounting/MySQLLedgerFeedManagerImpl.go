@@ -0,0 +1,88 @@
+package accounting
+
+import (
+	"context"
+	"time"
+
+	"github.com/hyperjumptech/bookkeeping/internal/connector"
+	"github.com/hyperjumptech/bookkeeping/internal/contextkeys"
+)
+
+// LEDGER FEED MANAGER ------------------------------------------------------------------
+
+// NewMySQLLedgerFeedManager returns new sql ledger feed manager
+func NewMySQLLedgerFeedManager(repo connector.DBRepository) LedgerFeedManager {
+	return &MySQLLedgerFeedManager{repo: repo}
+}
+
+// MySQLLedgerFeedManager implementation of LedgerFeedManager using the sequence column of the journals table in MySQL.
+type MySQLLedgerFeedManager struct {
+	repo connector.DBRepository
+}
+
+// ListFeed returns up to limit journals whose sequence is greater than afterSequence, in sequence order.
+func (fm *MySQLLedgerFeedManager) ListFeed(ctx context.Context, afterSequence int64, limit int) (*LedgerFeed, error) {
+	requestID := ctx.Value(contextkeys.XRequestID).(string)
+	lLog := dbLog.WithField("RequestID", requestID).WithField("function", "ListFeed")
+
+	// read one more journal than asked, to tell if there are more to come.
+	recs, err := fm.repo.ListJournalsAfterSequence(ctx, afterSequence, limit+1)
+	if err != nil {
+		lLog.Errorf("error while calling fm.repo.ListJournalsAfterSequence. got %s", err.Error())
+		return nil, err
+	}
+	feed := &LedgerFeed{
+		Journals:     make([]*FeedJournal, 0, len(recs)),
+		LastSequence: afterSequence,
+		HasMore:      len(recs) > limit,
+	}
+	if feed.HasMore {
+		recs = recs[:limit]
+	}
+	if len(recs) == 0 {
+		return feed, nil
+	}
+
+	journalIDs := make([]string, len(recs))
+	for i, rec := range recs {
+		journalIDs[i] = rec.JournalID
+	}
+	trxs, err := fm.repo.ListTransactionByJournalIDs(ctx, journalIDs)
+	if err != nil {
+		lLog.Errorf("error while calling fm.repo.ListTransactionByJournalIDs. got %s", err.Error())
+		return nil, err
+	}
+	trxByJournal := make(map[string][]*TransactionListItem)
+	for _, trx := range trxs {
+		trxByJournal[trx.JournalID] = append(trxByJournal[trx.JournalID], &TransactionListItem{
+			TransactionID:   trx.TransactionID,
+			TransactionTime: trx.TransactionTime.Format(time.RFC3339),
+			AccountNumber:   trx.AccountNumber,
+			JournalID:       trx.JournalID,
+			Description:     trx.Description,
+			TransactionType: trx.Alignment,
+			Amount:          trx.Amount,
+			AccountBalance:  trx.Balance,
+			CreateTime:      trx.CreatedAt.Format(time.RFC3339),
+			CreateBy:        trx.CreatedBy,
+			Metadata:        decodeMetadata(trx.Metadata),
+		})
+	}
+	for _, rec := range recs {
+		feed.Journals = append(feed.Journals, &FeedJournal{
+			Sequence:        rec.Sequence,
+			JournalID:       rec.JournalID,
+			JournalingTime:  rec.JournalingTime.Format(time.RFC3339),
+			Description:     rec.Description,
+			Reversal:        rec.IsReversal,
+			ReversedJournal: rec.ReversedJournalID,
+			Amount:          rec.TotalAmount,
+			CreateTime:      rec.CreatedAt.Format(time.RFC3339),
+			CreateBy:        rec.CreatedBy,
+			Metadata:        decodeMetadata(rec.Metadata),
+			Transactions:    trxByJournal[rec.JournalID],
+		})
+		feed.LastSequence = rec.Sequence
+	}
+	return feed, nil
+}
@@ -7,7 +7,10 @@ import (
 	"errors"
 	"fmt"
 	"math/big"
+	"net/http"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/hyperjumptech/acccore"
@@ -45,6 +48,11 @@ func (jm *MySQLJournalManager) NewJournal(ctx context.Context) acccore.Journal {
 //    3.Each of this account must belong to the same Currency
 //    4.Balanced. The total sum of DEBIT and total sum of CREDIT is equal.
 //    5.No duplicate transaction that belongs to the same Account.
+//    6.Keeps every account balance within the limits configured on the account.
+//    7.For a reversal journal, reverses no more than what is left to reverse of each line of the reversed journal.
+//    8.For a reversal journal, matches no approval rule as it can not be submitted as pending journal.
+// The journal.posted (or journal.reversed) and balance.low events are written into the outbox
+// in the same database transaction as the journal.
 // If your database support 2 phased commit, you can make all balance changes in
 // accounts and transactions. If your db do not support this, you can implement your own 2 phase commits mechanism
 // on the CommitJournal and CancelJournal
@@ -52,22 +60,102 @@ func (jm *MySQLJournalManager) PersistJournal(ctx context.Context, journalToPers
 	requestID := ctx.Value(contextkeys.XRequestID).(string)
 	lLog := dbLog.WithField("RequestID", requestID).WithField("function", "PersistJournal")
 
+	// BEGIN transaction
+	tx, err := jm.repo.DB().BeginTxx(ctx, &sql.TxOptions{
+		// todo investigate the use of this.
+		Isolation: 0,
+		ReadOnly:  false,
+	})
+	if err != nil {
+		lLog.Errorf("error creating transaction. got %s", err.Error())
+		return err
+	}
+	txCtx := context.WithValue(ctx, contextkeys.DBTransactionContextKey, tx)
+
+	err = jm.persistJournal(txCtx, journalToPersist)
+	if err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			lLog.Errorf("error rolling back transaction. got %s", rbErr.Error())
+		}
+		return err
+	}
+
+	// COMMIT transaction
+	err = tx.Commit()
+	if err != nil {
+		lLog.Errorf("error committing transaction. got %s", err.Error())
+		return err
+	}
+
+	return nil
+}
+
+// checkExternalReference make sure the reference is valid and the user in context, who is recorded as the creator
+// of the journal, did not give it to another journal yet. The unique index of the journals table is the last guard
+// against concurrent journals given the same reference.
+func (jm *MySQLJournalManager) checkExternalReference(ctx context.Context, reference string) error {
+	if len(reference) == 0 {
+		return nil
+	}
+	if err := validateExternalReference(reference); err != nil {
+		return err
+	}
+	theUser, _ := ctx.Value(contextkeys.UserIDContextKey).(string)
+	existing, err := jm.repo.GetJournalByExternalReference(ctx, theUser, reference)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return fmt.Errorf("%w : %q is the reference of journal %s", ErrExternalReferenceTaken, reference, existing.JournalID)
+	}
+	return nil
+}
+
+// journalPosting is a journal that passed every validation, along with the accounts it posts into
+// as they were read before the posting.
+type journalPosting struct {
+	// accounts the journal posts into, by account number
+	accounts map[string]*connector.AccountRecord
+	// balances of the accounts after the posting, by account number
+	balances map[string]int64
+	currency string
+	amount   int64
+}
+
+// validateJournal runs every check of persisting the journal and returns the accounts it posts into, along with
+// their balance after the posting. Nothing is written.
+// With forUpdate, the accounts and the reversed journal are locked until the database transaction carried in the
+// context ends, so the checks still hold when the journal is written. Without, nothing is locked and the checks are
+// only as good as the moment they are made.
+func (jm *MySQLJournalManager) validateJournal(ctx context.Context, journalToPersist acccore.Journal, forUpdate bool) (*journalPosting, error) {
+	requestID := ctx.Value(contextkeys.XRequestID).(string)
+	lLog := dbLog.WithField("RequestID", requestID).WithField("function", "validateJournal")
+
 	// First we have to make sure that the journalToPersist is not yet in our database.
 	// 1. Checking if anything mandatory is not missing
 	if journalToPersist == nil {
-		return acccore.ErrJournalNil
+		return nil, acccore.ErrJournalNil
 	}
 	if len(journalToPersist.GetJournalID()) == 0 {
 		lLog.Errorf("error persisting journal. journal is missing the journalID")
-		return acccore.ErrJournalMissingID
+		return nil, acccore.ErrJournalMissingID
 	}
 	if len(journalToPersist.GetTransactions()) == 0 {
 		lLog.Errorf("error persisting journal %s. journal contains no transactions.", journalToPersist.GetJournalID())
-		return acccore.ErrJournalNoTransaction
+		return nil, acccore.ErrJournalNoTransaction
 	}
 	if len(journalToPersist.GetCreateBy()) == 0 {
 		lLog.Errorf("error persisting journal %s. journal author not known.", journalToPersist.GetJournalID())
-		return acccore.ErrJournalMissingAuthor
+		return nil, acccore.ErrJournalMissingAuthor
+	}
+
+	if err := validateMetadata(metadataOf(journalToPersist)); err != nil {
+		lLog.Errorf("error persisting journal %s. got %s", journalToPersist.GetJournalID(), err.Error())
+		return nil, err
+	}
+	if err := jm.checkExternalReference(ctx, externalReferenceOf(journalToPersist)); err != nil {
+		lLog.Errorf("error persisting journal %s. got %s", journalToPersist.GetJournalID(), err.Error())
+		return nil, err
 	}
 
 	// 2. Checking if the journal ID must not in the Database (already persisted)
@@ -77,19 +165,23 @@ func (jm *MySQLJournalManager) PersistJournal(ctx context.Context, journalToPers
 	if err != nil {
 		if !errors.Is(err, sql.ErrNoRows) && !errors.Is(err, acccore.ErrJournalIDNotFound) {
 			lLog.Errorf("error while fetching journal %s. got %s", journalToPersist.GetJournalID(), err.Error())
-			return err
+			return nil, err
 		}
 	}
 	if j != nil {
 		lLog.Errorf("error persisting journal %s. journal already exist.", journalToPersist.GetJournalID())
-		return acccore.ErrJournalAlreadyPersisted
+		return nil, acccore.ErrJournalAlreadyPersisted
 	}
 
 	// 3. Make sure all journal transactions are IDed.
 	for idx, trx := range journalToPersist.GetTransactions() {
 		if len(trx.GetTransactionID()) == 0 {
 			lLog.Errorf("error persisting journal %s. transaction %d is missing transactionID.", journalToPersist.GetJournalID(), idx)
-			return acccore.ErrJournalTransactionMissingID
+			return nil, acccore.ErrJournalTransactionMissingID
+		}
+		if err := validateMetadata(metadataOf(trx)); err != nil {
+			lLog.Errorf("error persisting journal %s. transaction %d got %s", journalToPersist.GetJournalID(), idx, err.Error())
+			return nil, err
 		}
 	}
 
@@ -99,12 +191,12 @@ func (jm *MySQLJournalManager) PersistJournal(ctx context.Context, journalToPers
 		if err != nil {
 			if !errors.Is(err, sql.ErrNoRows) && !errors.Is(err, acccore.ErrJournalIDNotFound) {
 				lLog.Errorf("error while fetching transaction %s. got %s", trx.GetTransactionID(), err.Error())
-				return err
+				return nil, err
 			}
 		}
 		if t != nil {
 			lLog.Errorf("error persisting journal %s. transaction %d is already exist.", journalToPersist.GetJournalID(), idx)
-			return acccore.ErrJournalAlreadyPersisted
+			return nil, acccore.ErrJournalAlreadyPersisted
 		}
 	}
 
@@ -120,7 +212,7 @@ func (jm *MySQLJournalManager) PersistJournal(ctx context.Context, journalToPers
 	}
 	if creditSum != debitSum {
 		lLog.Errorf("error persisting journal %s. debit (%d) != credit (%d). journal not balance", journalToPersist.GetJournalID(), debitSum, creditSum)
-		return acccore.ErrJournalNotBalance
+		return nil, acccore.ErrJournalNotBalance
 	}
 
 	// 6. Make sure transactions account are not appear twice in the journal
@@ -128,17 +220,25 @@ func (jm *MySQLJournalManager) PersistJournal(ctx context.Context, journalToPers
 	for _, trx := range journalToPersist.GetTransactions() {
 		if _, exist := accountDupCheck[trx.GetAccountNumber()]; exist {
 			lLog.Errorf("error persisting journal %s. multiple transaction belong to the same account (%s)", journalToPersist.GetJournalID(), trx.GetAccountNumber())
-			return acccore.ErrJournalTransactionAccountDuplicate
+			return nil, acccore.ErrJournalTransactionAccountDuplicate
 		}
 		accountDupCheck[trx.GetAccountNumber()] = true
 	}
 
-	// 7. Make sure transactions are all belong to existing accounts
+	// 7. Make sure transactions are all belong to existing accounts, whether those accounts accept the posting is
+	//    checked once they are locked.
+	alignments := make(map[string]acccore.Alignment)
 	for _, trx := range journalToPersist.GetTransactions() {
+		alignments[trx.GetAccountNumber()] = trx.GetAlignment()
 		account, err := jm.repo.GetAccount(ctx, trx.GetAccountNumber())
 		if err != nil || account == nil {
 			lLog.Errorf("error persisting journal %s. theres a transaction belong to non existent account (%s)", journalToPersist.GetJournalID(), trx.GetAccountNumber())
-			return acccore.ErrJournalTransactionAccountNotPersist
+			return nil, acccore.ErrJournalTransactionAccountNotPersist
+		}
+		// the accounts are looked up in the books of the tenant already, this guards against a repository that does not.
+		if account.TenantID != connector.TenantFromContext(ctx) {
+			lLog.Errorf("error persisting journal %s. account %s belongs to tenant %s", journalToPersist.GetJournalID(), trx.GetAccountNumber(), account.TenantID)
+			return nil, ErrCrossTenantPosting
 		}
 	}
 
@@ -147,7 +247,7 @@ func (jm *MySQLJournalManager) PersistJournal(ctx context.Context, journalToPers
 	for idx, trx := range journalToPersist.GetTransactions() {
 		account, err := jm.repo.GetAccount(ctx, trx.GetAccountNumber())
 		if err != nil || account == nil {
-			return acccore.ErrAccountIDNotFound
+			return nil, acccore.ErrAccountIDNotFound
 		}
 		cur := account.CurrencyCode
 		if idx == 0 {
@@ -155,36 +255,112 @@ func (jm *MySQLJournalManager) PersistJournal(ctx context.Context, journalToPers
 		} else {
 			if cur != currency {
 				lLog.Errorf("error persisting journal %s. transactions here uses account with different currencies", journalToPersist.GetJournalID())
-				return acccore.ErrJournalTransactionMixCurrency
+				return nil, acccore.ErrJournalTransactionMixCurrency
 			}
 		}
 	}
 
-	// 9. If this is a reversal journal, make sure the journal being reversed have not been reversed before.
+	// 9. Read all the accounts again, locked when forUpdate, always in the same order to avoid dead lock between concurrent journals.
+	//    Holds are placed under the same account lock, and counted with a locking read, so the held amount is the latest one.
+	accountNumbers := make([]string, 0, len(accountDupCheck))
+	for accountNumber := range accountDupCheck {
+		accountNumbers = append(accountNumbers, accountNumber)
+	}
+	sort.Strings(accountNumbers)
+	posting := &journalPosting{
+		accounts: make(map[string]*connector.AccountRecord),
+		balances: make(map[string]int64),
+		currency: currency,
+		amount:   creditSum,
+	}
+	heldAmounts := make(map[string]int64)
+	for _, accountNumber := range accountNumbers {
+		var account *connector.AccountRecord
+		var held int64
+		if forUpdate {
+			account, err = jm.repo.GetAccountForUpdate(ctx, accountNumber)
+		} else {
+			account, err = jm.repo.GetAccount(ctx, accountNumber)
+		}
+		if err == nil && account == nil {
+			err = acccore.ErrJournalTransactionAccountNotPersist
+		}
+		if err == nil {
+			if forUpdate {
+				held, err = jm.repo.SumActiveHoldsByAccountNumberForUpdate(ctx, accountNumber, time.Now())
+			} else {
+				held, err = jm.repo.SumActiveHoldsByAccountNumber(ctx, accountNumber, time.Now())
+			}
+		}
+		if err != nil {
+			lLog.Errorf("error reading account %s. got %s", accountNumber, err.Error())
+			return nil, err
+		}
+		// the status is checked on the account read here, locked with forUpdate, so a concurrent freeze or close is not missed.
+		if err := checkAccountStatus(account, alignments[accountNumber]); err != nil {
+			lLog.Errorf("error persisting journal %s. got %s", journalToPersist.GetJournalID(), err.Error())
+			return nil, err
+		}
+		posting.accounts[accountNumber] = account
+		heldAmounts[accountNumber] = held
+	}
+
+	// 10. If this is a reversal journal, make sure it do not reverse more than what is left of the journal being reversed.
+	//     The reversed journal is locked while checking, so concurrent reversals of the same journal are checked one after another.
 	if journalToPersist.GetReversedJournal() != nil {
-		reversed, err := jm.IsJournalIDReversed(ctx, journalToPersist.GetJournalID())
+		err := jm.checkReversal(ctx, journalToPersist, forUpdate)
 		if err != nil {
-			return err
+			lLog.Errorf("error persisting journal %s. got %s", journalToPersist.GetJournalID(), err.Error())
+			return nil, err
+		}
+	}
+
+	// 11. Make sure the new balances are within the account limits, funds on hold are not available to this journal
+	for _, trx := range journalToPersist.GetTransactions() {
+		account := posting.accounts[trx.GetAccountNumber()]
+		newBalance := account.Balance - trx.GetAmount()
+		if (trx.GetAlignment() == acccore.DEBIT) == (account.Alignment == "DEBIT") {
+			newBalance = account.Balance + trx.GetAmount()
 		}
-		if reversed {
-			lLog.Errorf("error persisting journal %s. this journal try to make reverse transaction on journals thats already reversed %s", journalToPersist.GetJournalID(), journalToPersist.GetJournalID())
-			return acccore.ErrJournalCanNotDoubleReverse
+		if err := checkAccountLimits(account, newBalance, heldAmounts[account.AccountNumber]); err != nil {
+			lLog.Errorf("error persisting journal %s. got %s", journalToPersist.GetJournalID(), err.Error())
+			return nil, err
 		}
+		posting.balances[account.AccountNumber] = newBalance
 	}
+	return posting, nil
+}
 
-	// ALL is OK. So lets start persisting.
+// persistJournal validates and writes the journal, its transactions and the account balances using
+// the database transaction carried in the context. The caller is responsible to commit or roll back.
+func (jm *MySQLJournalManager) persistJournal(ctx context.Context, journalToPersist acccore.Journal) error {
+	requestID := ctx.Value(contextkeys.XRequestID).(string)
+	lLog := dbLog.WithField("RequestID", requestID).WithField("function", "persistJournal")
 
-	// BEGIN transaction
-	tx, err := jm.repo.DB().BeginTxx(ctx, &sql.TxOptions{
-		// todo investigate the use of this.
-		Isolation: 0,
-		ReadOnly:  false,
-	})
+	posting, err := jm.validateJournal(ctx, journalToPersist, true)
 	if err != nil {
-		lLog.Errorf("error creating transaction. got %s", err.Error())
 		return err
 	}
 
+	// A reversal have no pending journal to be submitted as, nobody would review it.
+	if journalToPersist.GetReversedJournal() != nil {
+		accountNumbers := make([]string, 0, len(posting.accounts))
+		for accountNumber := range posting.accounts {
+			accountNumbers = append(accountNumbers, accountNumber)
+		}
+		needApproval, err := requiresApproval(ctx, jm.repo, posting.amount, accountNumbers)
+		if err != nil {
+			lLog.Errorf("error while calling requiresApproval. got %s", err.Error())
+			return err
+		}
+		if needApproval {
+			lLog.Errorf("error persisting reversal %s. journal matches an approval rule", journalToPersist.GetJournalID())
+			return ErrJournalRequiresApproval
+		}
+	}
+
+	// ALL is OK. So lets start persisting, the context carries the database transaction.
+
 	// 1. Save the Journal
 	journalToInsert := &connector.JournalRecord{
 		JournalID:         journalToPersist.GetJournalID(),
@@ -192,9 +368,11 @@ func (jm *MySQLJournalManager) PersistJournal(ctx context.Context, journalToPers
 		Description:       journalToPersist.GetDescription(),
 		IsReversal:        false,
 		ReversedJournalID: "",
-		TotalAmount:       creditSum,
+		TotalAmount:       posting.amount,
 		CreatedAt:         time.Now(),
 		CreatedBy:         journalToPersist.GetCreateBy(),
+		Metadata:          encodeMetadata(metadataOf(journalToPersist)),
+		ExternalReference: externalReferenceOf(journalToPersist),
 	}
 
 	if journalToPersist.GetReversedJournal() != nil {
@@ -202,17 +380,40 @@ func (jm *MySQLJournalManager) PersistJournal(ctx context.Context, journalToPers
 		journalToInsert.IsReversal = true
 	}
 
+	// The sequence stays locked until the journal is committed or rolled back, so every journal
+	// is numbered without gap in the order they are committed.
+	journalToInsert.Sequence, err = jm.repo.NextSequence(ctx, connector.JournalSequence)
+	if err != nil {
+		lLog.Errorf("error numbering new journal %s . got %s", journalToInsert.JournalID, err.Error())
+		return err
+	}
+
 	journalID, err := jm.repo.InsertJournal(ctx, journalToInsert)
 	if err != nil {
-		lLog.Errorf("error inserting new journal %s . got %s. rolling back transaction.", journalToInsert.JournalID, err.Error())
-		err = tx.Rollback()
-		if err != nil {
-			lLog.Errorf("error rolling back transaction. got %s", err.Error())
-		}
+		lLog.Errorf("error inserting new journal %s . got %s", journalToInsert.JournalID, err.Error())
+		return err
+	}
+	err = claimIdempotencyKey(ctx, jm.repo, journalID, http.StatusOK)
+	if err != nil {
+		lLog.Errorf("error claiming the idempotency key of journal %s . got %s", journalID, err.Error())
 		return err
 	}
 
-	// 2 Save the Transactions
+	journalEvent := &JournalEventData{
+		JournalID:         journalID,
+		Sequence:          journalToInsert.Sequence,
+		ReversedJournalID: journalToInsert.ReversedJournalID,
+		Description:       journalToInsert.Description,
+		Currency:          posting.currency,
+		TotalAmount:       journalToInsert.TotalAmount,
+		CreatedBy:         journalToInsert.CreatedBy,
+		Metadata:          metadataOf(journalToPersist),
+		ExternalReference: journalToInsert.ExternalReference,
+		Transactions:      make([]*TransactionEventData, 0, len(journalToPersist.GetTransactions())),
+	}
+	lowBalances := make([]*BalanceLowEventData, 0)
+
+	// 2. Save the Transactions
 	for _, trx := range journalToPersist.GetTransactions() {
 		transactionToInsert := &connector.TransactionRecord{
 			TransactionID:   trx.GetTransactionID(),
@@ -225,6 +426,7 @@ func (jm *MySQLJournalManager) PersistJournal(ctx context.Context, journalToPers
 			Balance:   trx.GetAccountBalance(),
 			CreatedAt: time.Now(),
 			CreatedBy: trx.GetCreateBy(),
+			Metadata:  encodeMetadata(metadataOf(trx)),
 		}
 
 		if trx.GetAlignment() == acccore.DEBIT {
@@ -233,32 +435,13 @@ func (jm *MySQLJournalManager) PersistJournal(ctx context.Context, journalToPers
 			transactionToInsert.Alignment = "CREDIT"
 		}
 
-		account, err := jm.repo.GetAccount(ctx, trx.GetAccountNumber())
-		if err != nil {
-			lLog.Errorf("error retrieving account %s in transaction. got %s. rolling back transaction.", trx.GetAccountNumber(), err.Error())
-			err = tx.Rollback()
-			if err != nil {
-				lLog.Errorf("error rolling back transaction. got %s", err.Error())
-			}
-			return err
-		}
-		balance, accountTrxType := account.Balance, account.Alignment
-
-		newBalance := int64(0)
-		if transactionToInsert.Alignment == accountTrxType {
-			newBalance = balance + transactionToInsert.Amount
-		} else {
-			newBalance = balance - transactionToInsert.Amount
-		}
+		account := posting.accounts[trx.GetAccountNumber()]
+		balance, newBalance := account.Balance, posting.balances[trx.GetAccountNumber()]
 		transactionToInsert.Balance = newBalance
 
 		_, err = jm.repo.InsertTransaction(ctx, transactionToInsert)
 		if err != nil {
-			lLog.Errorf("error inserting new transaction %s in transaction. got %s. rolling back transaction.", transactionToInsert.TransactionID, err.Error())
-			err = tx.Rollback()
-			if err != nil {
-				lLog.Errorf("error rolling back transaction. got %s", err.Error())
-			}
+			lLog.Errorf("error inserting new transaction %s in transaction. got %s", transactionToInsert.TransactionID, err.Error())
 			return err
 		}
 
@@ -269,25 +452,196 @@ func (jm *MySQLJournalManager) PersistJournal(ctx context.Context, journalToPers
 		account.UpdatedBy = trx.GetCreateBy()
 		err = jm.repo.UpdateAccount(ctx, account)
 		if err != nil {
-			lLog.Errorf("error updating account %s in transaction. got %s. rolling back transaction.", account.AccountNumber, err.Error())
-			err = tx.Rollback()
-			if err != nil {
-				lLog.Errorf("error rolling back transaction. got %s", err.Error())
-			}
+			lLog.Errorf("error updating account %s in transaction. got %s", account.AccountNumber, err.Error())
 			return err
 		}
+
+		journalEvent.Transactions = append(journalEvent.Transactions, &TransactionEventData{
+			TransactionID: transactionToInsert.TransactionID,
+			AccountNumber: transactionToInsert.AccountNumber,
+			Alignment:     transactionToInsert.Alignment,
+			Amount:        transactionToInsert.Amount,
+			Balance:       newBalance,
+			Metadata:      metadataOf(trx),
+		})
+		if threshold := lowBalanceThreshold(account); balance >= threshold && newBalance < threshold {
+			lowBalances = append(lowBalances, &BalanceLowEventData{
+				AccountNumber:   account.AccountNumber,
+				Currency:        account.CurrencyCode,
+				JournalID:       journalID,
+				PreviousBalance: balance,
+				Balance:         newBalance,
+				Threshold:       threshold,
+			})
+		}
 	}
 
-	// COMMIT transaction
-	err = tx.Commit()
+	// 3. Publish the events into the outbox, they are committed or rolled back along with the journal.
+	eventType := EventJournalPosted
+	if journalToInsert.IsReversal {
+		eventType = EventJournalReversed
+	}
+	err = publishEvent(ctx, jm.repo, eventType, journalToInsert.CreatedBy, journalEvent)
+	for i := 0; err == nil && i < len(lowBalances); i++ {
+		err = publishEvent(ctx, jm.repo, EventBalanceLow, journalToInsert.CreatedBy, lowBalances[i])
+	}
 	if err != nil {
-		lLog.Errorf("error committing transaction. got %s", err.Error())
+		lLog.Errorf("error publishing events of journal %s in transaction. got %s", journalID, err.Error())
+		return err
+	}
+
+	return nil
+}
+
+// checkReversal make sure each transaction of a reversal journal reverses a line of the reversed journal, in the opposite
+// alignment, and the cumulative reversed amount of each line do not exceed the line amount.
+// With forUpdate, the reversed journal stays locked until the database transaction in the context ends, so the next
+// reversal of the same journal waits and counts this one.
+func (jm *MySQLJournalManager) checkReversal(ctx context.Context, reversal acccore.Journal, forUpdate bool) error {
+	reversedJournalID := reversal.GetReversedJournal().GetJournalID()
+	getReversed, sumReversed := jm.repo.GetJournal, jm.repo.SumReversedAmountByJournalID
+	if forUpdate {
+		getReversed, sumReversed = jm.repo.GetJournalForUpdate, jm.repo.SumReversedAmountByJournalIDForUpdate
+	}
+	if _, err := getReversed(ctx, reversedJournalID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("%w : %s", acccore.ErrJournalIDNotFound, reversedJournalID)
+		}
 		return err
 	}
+	lines, err := jm.repo.ListTransactionByJournalID(ctx, reversedJournalID)
+	if err != nil {
+		return err
+	}
+	if len(lines) == 0 {
+		return fmt.Errorf("%w : %s", acccore.ErrJournalIDNotFound, reversedJournalID)
+	}
+	lineByAccount := make(map[string]*connector.TransactionRecord)
+	for _, line := range lines {
+		lineByAccount[line.AccountNumber] = line
+	}
+	reversed, err := sumReversed(ctx, reversedJournalID)
+	if err != nil {
+		return err
+	}
+	for _, trx := range reversal.GetTransactions() {
+		line, ok := lineByAccount[trx.GetAccountNumber()]
+		if !ok {
+			return fmt.Errorf("%w : account %s is not in journal %s", ErrInvalidReversal, trx.GetAccountNumber(), reversedJournalID)
+		}
+		if (line.Alignment == "DEBIT") == (trx.GetAlignment() == acccore.DEBIT) {
+			return fmt.Errorf("%w : account %s must be reversed in the opposite alignment", ErrInvalidReversal, trx.GetAccountNumber())
+		}
+		if trx.GetAmount() <= 0 {
+			return fmt.Errorf("%w : account %s reversal amount must be positive", ErrInvalidReversal, trx.GetAccountNumber())
+		}
+		remaining := line.Amount - reversed[trx.GetAccountNumber()]
+		if remaining <= 0 {
+			return fmt.Errorf("%w : account %s of journal %s is fully reversed", acccore.ErrJournalCanNotDoubleReverse, trx.GetAccountNumber(), reversedJournalID)
+		}
+		if trx.GetAmount() > remaining {
+			return fmt.Errorf("%w : account %s have %d left to reverse", ErrReversalExceedsOriginal, trx.GetAccountNumber(), remaining)
+		}
+	}
+	return nil
+}
 
+// checkAccountStatus make sure the account accept a transaction of the specified alignment.
+func checkAccountStatus(account *connector.AccountRecord, alignment acccore.Alignment) error {
+	switch account.Status {
+	case connector.AccountStatusClosed:
+		return fmt.Errorf("%w : %s", ErrAccountClosed, account.AccountNumber)
+	case connector.AccountStatusFrozen:
+		return fmt.Errorf("%w : %s", ErrAccountFrozen, account.AccountNumber)
+	case connector.AccountStatusDebitFrozen:
+		if alignment == acccore.DEBIT {
+			return fmt.Errorf("%w : %s", ErrAccountDebitFrozen, account.AccountNumber)
+		}
+	}
 	return nil
 }
 
+// checkAccountLimits make sure the new balance of an account do not violate the balance limits configured on the account.
+// The held amount is not available, thus it is taken into account against the minimum balance and overdraft limit,
+// but not against the maximum balance. An account with neither minimum balance nor overdraft limit may go below zero,
+// but not into the amount on hold, the held amount must stay covered by the balance or by what the balance was.
+// Only a balance moving toward the violated limit is rejected, so an account that already violates a newly configured limit
+// can still be brought back within its limits.
+func checkAccountLimits(account *connector.AccountRecord, newBalance, held int64) error {
+	if account.MaxBalance != nil && newBalance > account.Balance && newBalance > *account.MaxBalance {
+		return fmt.Errorf("%w : %s", ErrAccountMaximumBalance, account.AccountNumber)
+	}
+	if newBalance >= account.Balance {
+		return nil
+	}
+	available := newBalance - held
+	if account.MinBalance == nil && account.OverdraftLimit == nil {
+		if held > 0 && available < min(0, account.Balance) {
+			return fmt.Errorf("%w : %s", ErrInsufficientBalance, account.AccountNumber)
+		}
+		return nil
+	}
+	floor := int64(0)
+	if account.MinBalance != nil {
+		floor = *account.MinBalance
+	}
+	if account.OverdraftLimit != nil {
+		if available < floor-*account.OverdraftLimit {
+			return fmt.Errorf("%w : %s", ErrAccountOverdraftLimit, account.AccountNumber)
+		}
+		return nil
+	}
+	if available < floor {
+		return fmt.Errorf("%w : %s", ErrAccountMinimumBalance, account.AccountNumber)
+	}
+	return nil
+}
+
+// validateJournalRequest make sure a journal request that is stored to be posted later is complete, balanced and posting
+// into existing accounts, so it will not be rejected for those reasons when it is posted.
+// It returns the accounts the journal posts into, keyed by account number, and the journal amount (its total debit).
+func validateJournalRequest(ctx context.Context, repo connector.DBRepository, journal *CreateJournalRequest) (map[string]*connector.AccountRecord, int64, error) {
+	if len(journal.Creator) == 0 {
+		return nil, 0, acccore.ErrJournalMissingAuthor
+	}
+	if len(journal.Transactions) == 0 {
+		return nil, 0, acccore.ErrJournalNoTransaction
+	}
+	if err := validateMetadata(journal.Metadata); err != nil {
+		return nil, 0, err
+	}
+	if err := validateExternalReference(journal.ExternalReference); err != nil {
+		return nil, 0, err
+	}
+	accounts := make(map[string]*connector.AccountRecord)
+	var creditSum, debitSum int64
+	for _, trx := range journal.Transactions {
+		if err := validateMetadata(trx.Metadata); err != nil {
+			return nil, 0, err
+		}
+		if strings.ToUpper(trx.Alignment) == "DEBIT" {
+			debitSum += trx.Amount
+		} else {
+			creditSum += trx.Amount
+		}
+		if _, ok := accounts[trx.AccountNumber]; ok {
+			continue
+		}
+		account, err := repo.GetAccount(ctx, trx.AccountNumber)
+		if err != nil {
+			return nil, 0, err
+		}
+		if account == nil {
+			return nil, 0, fmt.Errorf("%w : %s", acccore.ErrJournalTransactionAccountNotPersist, trx.AccountNumber)
+		}
+		accounts[trx.AccountNumber] = account
+	}
+	if creditSum != debitSum {
+		return nil, 0, acccore.ErrJournalNotBalance
+	}
+	return accounts, debitSum, nil
+}
+
 // CommitJournal will commit the journal into the system
 // Only non committed journal can be committed.
 // use this if the implementation database do not support 2 phased commit.
@@ -352,7 +706,7 @@ func (jm *MySQLJournalManager) GetJournalByID(ctx context.Context, journalID str
 		lLog.Errorf("error while calling GetJournal, journal is NIL but not throwing any error.")
 		return nil, fmt.Errorf("error while calling GetJournal, journal is NIL but not throwing any error")
 	}
-	ret := &acccore.BaseJournal{}
+	ret := &MetadataJournal{Metadata: decodeMetadata(journal.Metadata), ExternalReference: journal.ExternalReference}
 	ret.SetAmount(journal.TotalAmount).SetDescription(journal.Description).SetReversal(journal.IsReversal).
 		SetJournalingTime(journal.JournalingTime).SetCreateBy(journal.CreatedBy).SetCreateTime(journal.CreatedAt).
 		SetJournalID(journal.JournalID)
@@ -374,16 +728,7 @@ func (jm *MySQLJournalManager) GetJournalByID(ctx context.Context, journalID str
 		return nil, err
 	}
 	for _, trx := range trxs {
-		transaction := &acccore.BaseTransaction{}
-		transaction.SetJournalID(trx.JournalID).SetTransactionTime(trx.TransactionTime).
-			SetAccountNumber(trx.AccountNumber).SetTransactionID(trx.TransactionID).SetDescription(trx.Description).
-			SetCreateTime(trx.CreatedAt).SetCreateBy(trx.CreatedBy).SetAccountBalance(trx.Balance).SetAmount(trx.Amount)
-		if strings.ToUpper(trx.Alignment) == "DEBIT" {
-			transaction.SetAlignment(acccore.DEBIT)
-		} else {
-			transaction.SetAlignment(acccore.CREDIT)
-		}
-		transactions = append(transactions, transaction)
+		transactions = append(transactions, transactionFromRecord(trx))
 	}
 	ret.SetTransactions(transactions)
 
@@ -461,6 +806,21 @@ func (am *MySQLTransactionManager) NewTransaction(ctx context.Context) acccore.T
 	return &acccore.BaseTransaction{}
 }
 
+// transactionFromRecord converts the record into a transaction carrying its metadata
+func transactionFromRecord(tx *connector.TransactionRecord) *MetadataTransaction {
+	trx := &MetadataTransaction{Metadata: decodeMetadata(tx.Metadata)}
+	trx.SetAmount(tx.Amount).SetAccountBalance(tx.Balance).SetCreateBy(tx.CreatedBy).SetCreateTime(tx.CreatedAt).
+		SetDescription(tx.Description).SetTransactionID(tx.TransactionID).SetAccountNumber(tx.AccountNumber).
+		SetTransactionTime(tx.TransactionTime).SetJournalID(tx.JournalID)
+
+	if strings.ToUpper(tx.Alignment) == "DEBIT" {
+		trx.SetAlignment(acccore.DEBIT)
+	} else {
+		trx.SetAlignment(acccore.CREDIT)
+	}
+	return trx
+}
+
 // IsTransactionIDExist will check if an Transaction ID/number is exist in the database.
 func (am *MySQLTransactionManager) IsTransactionIDExist(ctx context.Context, id string) (bool, error) {
 	requestID := ctx.Value(contextkeys.XRequestID).(string)
@@ -491,17 +851,7 @@ func (am *MySQLTransactionManager) GetTransactionByID(ctx context.Context, id st
 		lLog.Errorf("error transaction not found")
 		return nil, acccore.ErrTransactionNotFound
 	}
-	trx := &acccore.BaseTransaction{}
-	trx.SetAmount(tx.Amount).SetAccountBalance(tx.Balance).SetCreateBy(tx.CreatedBy).SetCreateTime(tx.CreatedAt).
-		SetDescription(tx.Description).SetTransactionID(tx.TransactionID).SetAccountNumber(tx.AccountNumber).
-		SetTransactionTime(tx.TransactionTime).SetJournalID(tx.JournalID)
-
-	if strings.ToUpper(tx.Alignment) == "DEBIT" {
-		trx.SetAlignment(acccore.DEBIT)
-	} else {
-		trx.SetAlignment(acccore.CREDIT)
-	}
-	return trx, nil
+	return transactionFromRecord(tx), nil
 }
 
 // ListTransactionsOnAccount retrieves list of transactions that belongs to this account
@@ -524,17 +874,7 @@ func (am *MySQLTransactionManager) ListTransactionsOnAccount(ctx context.Context
 	}
 	ret := make([]acccore.Transaction, 0)
 	for _, tx := range records {
-		trx := &acccore.BaseTransaction{}
-		trx.SetAmount(tx.Amount).SetAccountBalance(tx.Balance).SetCreateBy(tx.CreatedBy).SetCreateTime(tx.CreatedAt).
-			SetDescription(tx.Description).SetTransactionID(tx.TransactionID).SetAccountNumber(tx.AccountNumber).
-			SetTransactionTime(tx.TransactionTime).SetJournalID(tx.JournalID)
-
-		if strings.ToUpper(tx.Alignment) == "DEBIT" {
-			trx.SetAlignment(acccore.DEBIT)
-		} else {
-			trx.SetAlignment(acccore.CREDIT)
-		}
-		ret = append(ret, trx)
+		ret = append(ret, transactionFromRecord(tx))
 	}
 	return pageResult, ret, nil
 }
@@ -597,6 +937,11 @@ func (am *MySQLAccountManager) NewAccount(ctx context.Context) acccore.Account {
 // PersistAccount will save the account into database.
 // will throw error if the account already persisted
 func (am *MySQLAccountManager) PersistAccount(ctx context.Context, AccountToPersist acccore.Account) error {
+	return am.persistAccount(ctx, AccountToPersist, nil)
+}
+
+// persistAccount persists the new account, with its balance limits if limits is not nil.
+func (am *MySQLAccountManager) persistAccount(ctx context.Context, AccountToPersist acccore.Account, limits *AccountLimits) error {
 	requestID := ctx.Value(contextkeys.XRequestID).(string)
 	lLog := dbLog.WithField("RequestID", requestID).WithField("function", "PersistAccount")
 
@@ -612,6 +957,22 @@ func (am *MySQLAccountManager) PersistAccount(ctx context.Context, AccountToPers
 	if len(AccountToPersist.GetCreateBy()) == 0 {
 		return acccore.ErrAccountMissingCreator
 	}
+	reference := externalReferenceOf(AccountToPersist)
+	if err := validateExternalReference(reference); err != nil {
+		return err
+	}
+	if len(reference) > 0 {
+		// the account is recorded as created by the user in context
+		theUser, _ := ctx.Value(contextkeys.UserIDContextKey).(string)
+		existing, err := am.repo.GetAccountByExternalReference(ctx, theUser, reference)
+		if err != nil {
+			lLog.Errorf("error while calling am.repo.GetAccountByExternalReference. got %s", err.Error())
+			return err
+		}
+		if existing != nil {
+			return fmt.Errorf("%w : %q is the reference of account %s", ErrExternalReferenceTaken, reference, existing.AccountNumber)
+		}
+	}
 
 	curRec, err := am.repo.GetCurrency(ctx, AccountToPersist.GetCurrency())
 	if err != nil {
@@ -629,12 +990,13 @@ func (am *MySQLAccountManager) PersistAccount(ctx context.Context, AccountToPers
 		CurrencyCode:  AccountToPersist.GetCurrency(),
 		Description:   AccountToPersist.GetDescription(),
 		// Alignment:     AccountToPersist.GetBaseTransactionType(),
-		Balance:   AccountToPersist.GetBalance(),
-		Coa:       AccountToPersist.GetCOA(),
-		CreatedAt: time.Now(),
-		CreatedBy: AccountToPersist.GetCreateBy(),
-		UpdatedAt: time.Now(),
-		UpdatedBy: AccountToPersist.GetUpdateBy(),
+		Balance:           AccountToPersist.GetBalance(),
+		Coa:               AccountToPersist.GetCOA(),
+		CreatedAt:         time.Now(),
+		CreatedBy:         AccountToPersist.GetCreateBy(),
+		UpdatedAt:         time.Now(),
+		UpdatedBy:         AccountToPersist.GetUpdateBy(),
+		ExternalReference: reference,
 	}
 	if AccountToPersist.GetAlignment() == acccore.DEBIT {
 		ar.Alignment = "DEBIT"
@@ -642,11 +1004,48 @@ func (am *MySQLAccountManager) PersistAccount(ctx context.Context, AccountToPers
 		ar.Alignment = "CREDIT"
 	}
 
-	_, err = am.repo.InsertAccount(ctx, ar)
+	// The limits, the account.created event and the idempotency key of the request are written in the same database transaction as the account.
+	tx, err := am.repo.DB().BeginTxx(ctx, nil)
+	if err != nil {
+		lLog.Errorf("error creating transaction. got %s", err.Error())
+		return err
+	}
+	txCtx := context.WithValue(ctx, contextkeys.DBTransactionContextKey, tx)
+	_, err = am.repo.InsertAccount(txCtx, ar)
+	if err == nil && limits != nil {
+		err = am.repo.UpdateAccountLimits(txCtx, ar.AccountNumber, limits.MinBalance, limits.OverdraftLimit, limits.MaxBalance)
+	}
+	if err == nil {
+		err = claimIdempotencyKey(txCtx, am.repo, ar.AccountNumber, http.StatusOK)
+	}
+	if err == nil {
+		err = publishEvent(txCtx, am.repo, EventAccountCreated, ar.CreatedBy, &AccountEventData{
+			AccountNumber:     ar.AccountNumber,
+			Name:              ar.Name,
+			Description:       ar.Description,
+			Currency:          ar.CurrencyCode,
+			Alignment:         ar.Alignment,
+			Coa:               ar.Coa,
+			Balance:           ar.Balance,
+			CreatedBy:         ar.CreatedBy,
+			ExternalReference: ar.ExternalReference,
+		})
+	}
+	if err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			lLog.Errorf("error rolling back transaction. got %s", rbErr.Error())
+		}
+		return err
+	}
+	err = tx.Commit()
+	if err != nil {
+		lLog.Errorf("error committing transaction. got %s", err.Error())
+	}
 	return err
 }
 
 // UpdateAccount will update the account database to reflect to the provided account information.
+// Only the name, description and COA are updated, the balance is only ever changed by the journals.
 // This update account function will fail if the account ID/number is not existing in the database.
 func (am *MySQLAccountManager) UpdateAccount(ctx context.Context, AccountToUpdate acccore.Account) error {
 	requestID := ctx.Value(contextkeys.XRequestID).(string)
@@ -676,27 +1075,7 @@ func (am *MySQLAccountManager) UpdateAccount(ctx context.Context, AccountToUpdat
 		return acccore.ErrAccountIsNotPersisted
 	}
 
-	ar := &connector.AccountRecord{
-		AccountNumber: AccountToUpdate.GetAccountNumber(),
-		Name:          AccountToUpdate.GetName(),
-		CurrencyCode:  AccountToUpdate.GetCurrency(),
-		Description:   AccountToUpdate.GetDescription(),
-		// Alignment:     AccountToPersist.GetBaseTransactionType(),
-		Balance:   AccountToUpdate.GetBalance(),
-		Coa:       AccountToUpdate.GetCOA(),
-		CreatedAt: time.Now(),
-		CreatedBy: AccountToUpdate.GetCreateBy(),
-		UpdatedAt: time.Now(),
-		UpdatedBy: AccountToUpdate.GetUpdateBy(),
-	}
-	if AccountToUpdate.GetAlignment() == acccore.DEBIT {
-		ar.Alignment = "DEBIT"
-	} else {
-		ar.Alignment = "CREDIT"
-	}
-
-	return am.repo.UpdateAccount(ctx, ar)
-
+	return am.repo.UpdateAccountDetails(ctx, AccountToUpdate.GetAccountNumber(), AccountToUpdate.GetName(), AccountToUpdate.GetDescription(), AccountToUpdate.GetCOA())
 }
 
 // IsAccountIDExist will check if an account ID/number is exist in the database.
@@ -729,7 +1108,12 @@ func (am *MySQLAccountManager) GetAccountByID(ctx context.Context, id string) (a
 	if rec == nil {
 		return nil, nil
 	}
-	ret := &acccore.BaseAccount{}
+	return accountFromRecord(rec), nil
+}
+
+// accountFromRecord returns the account of the record, along with its external reference
+func accountFromRecord(rec *connector.AccountRecord) *ReferencedAccount {
+	ret := &ReferencedAccount{ExternalReference: rec.ExternalReference}
 	ret.SetAccountNumber(rec.AccountNumber).SetDescription(rec.Description).SetCreateTime(rec.CreatedAt).
 		SetCreateBy(rec.CreatedBy).SetCurrency(rec.CurrencyCode).SetCOA(rec.Coa).SetName(rec.Name).
 		SetBalance(rec.Balance).SetUpdateBy(rec.UpdatedBy).SetUpdateTime(rec.UpdatedAt)
@@ -739,8 +1123,7 @@ func (am *MySQLAccountManager) GetAccountByID(ctx context.Context, id string) (a
 	} else {
 		ret.SetAlignment(acccore.CREDIT)
 	}
-
-	return ret, nil
+	return ret
 }
 
 // ListAccounts list all account in the database.
@@ -852,15 +1235,225 @@ func (am *MySQLAccountManager) FindAccounts(ctx context.Context, nameLike string
 	return pResult, ret, nil
 }
 
+// ACCOUNT STATE MANAGER ------------------------------------------------------------------
+
+// NewMySQLAccountStateManager returns new sql account state manager
+func NewMySQLAccountStateManager(repo connector.DBRepository) AccountStateManager {
+	return &MySQLAccountStateManager{repo: repo}
+}
+
+// MySQLAccountStateManager implementation of AccountStateManager using the status column of Account table in MySQL
+type MySQLAccountStateManager struct {
+	repo connector.DBRepository
+}
+
+// GetAccountStatus returns the current status of the account, one of the connector.AccountStatus constants.
+func (sm *MySQLAccountStateManager) GetAccountStatus(ctx context.Context, accountNumber string) (string, error) {
+	requestID := ctx.Value(contextkeys.XRequestID).(string)
+	lLog := dbLog.WithField("RequestID", requestID).WithField("function", "GetAccountStatus")
+
+	rec, err := sm.repo.GetAccount(ctx, accountNumber)
+	if err != nil {
+		lLog.Errorf("error while calling sm.repo.GetAccount. got %s", err.Error())
+		return "", err
+	}
+	if rec == nil {
+		return "", acccore.ErrAccountIsNotPersisted
+	}
+	return rec.Status, nil
+}
+
+// FreezeAccount will freeze the account. If debitOnly is true, the account will still accept CREDIT
+// postings, otherwise the account will not accept any posting at all.
+func (sm *MySQLAccountStateManager) FreezeAccount(ctx context.Context, accountNumber string, debitOnly bool) error {
+	requestID := ctx.Value(contextkeys.XRequestID).(string)
+	lLog := dbLog.WithField("RequestID", requestID).WithField("function", "FreezeAccount")
+
+	newStatus := connector.AccountStatusFrozen
+	if debitOnly {
+		newStatus = connector.AccountStatusDebitFrozen
+	}
+	return sm.updateAccountStatus(ctx, accountNumber, newStatus, func(ctx context.Context, rec *connector.AccountRecord) error {
+		if rec.Status == connector.AccountStatusClosed {
+			lLog.Errorf("error freezing account %s. account is closed", accountNumber)
+			return ErrAccountClosed
+		}
+		return nil
+	})
+}
+
+// UnfreezeAccount will make a frozen account active again.
+func (sm *MySQLAccountStateManager) UnfreezeAccount(ctx context.Context, accountNumber string) error {
+	requestID := ctx.Value(contextkeys.XRequestID).(string)
+	lLog := dbLog.WithField("RequestID", requestID).WithField("function", "UnfreezeAccount")
+
+	return sm.updateAccountStatus(ctx, accountNumber, connector.AccountStatusActive, func(ctx context.Context, rec *connector.AccountRecord) error {
+		if rec.Status == connector.AccountStatusClosed {
+			lLog.Errorf("error unfreezing account %s. account is closed", accountNumber)
+			return ErrAccountClosed
+		}
+		return nil
+	})
+}
+
+// CloseAccount will permanently close the account. Only account with zero balance and no active hold can be closed.
+func (sm *MySQLAccountStateManager) CloseAccount(ctx context.Context, accountNumber string) error {
+	requestID := ctx.Value(contextkeys.XRequestID).(string)
+	lLog := dbLog.WithField("RequestID", requestID).WithField("function", "CloseAccount")
+
+	return sm.updateAccountStatus(ctx, accountNumber, connector.AccountStatusClosed, func(ctx context.Context, rec *connector.AccountRecord) error {
+		if rec.Status == connector.AccountStatusClosed {
+			lLog.Errorf("error closing account %s. account is already closed", accountNumber)
+			return ErrAccountClosed
+		}
+		if rec.Balance != 0 {
+			lLog.Errorf("error closing account %s. balance is %d", accountNumber, rec.Balance)
+			return ErrAccountBalanceNotZero
+		}
+		held, err := sm.repo.SumActiveHoldsByAccountNumberForUpdate(ctx, accountNumber, time.Now())
+		if err != nil {
+			lLog.Errorf("error while calling sm.repo.SumActiveHoldsByAccountNumberForUpdate. got %s", err.Error())
+			return err
+		}
+		if held != 0 {
+			lLog.Errorf("error closing account %s. %d is on hold", accountNumber, held)
+			return ErrAccountHasActiveHolds
+		}
+		return nil
+	})
+}
+
+// updateAccountStatus locks the account, makes sure the status change is allowed using the check function, and
+// changes the status in a single database transaction, so no journal or hold can slip in between the check and the change.
+func (sm *MySQLAccountStateManager) updateAccountStatus(ctx context.Context, accountNumber, status string, check func(ctx context.Context, rec *connector.AccountRecord) error) error {
+	requestID := ctx.Value(contextkeys.XRequestID).(string)
+	lLog := dbLog.WithField("RequestID", requestID).WithField("function", "updateAccountStatus")
+
+	// BEGIN transaction
+	tx, err := sm.repo.DB().BeginTxx(ctx, &sql.TxOptions{
+		Isolation: 0,
+		ReadOnly:  false,
+	})
+	if err != nil {
+		lLog.Errorf("error creating transaction. got %s", err.Error())
+		return err
+	}
+	txCtx := context.WithValue(ctx, contextkeys.DBTransactionContextKey, tx)
+	rollback := func(err error) error {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			lLog.Errorf("error rolling back transaction. got %s", rbErr.Error())
+		}
+		return err
+	}
+
+	rec, err := sm.repo.GetAccountForUpdate(txCtx, accountNumber)
+	if err != nil {
+		lLog.Errorf("error while calling sm.repo.GetAccountForUpdate. got %s", err.Error())
+		return rollback(err)
+	}
+	if rec == nil {
+		return rollback(acccore.ErrAccountIsNotPersisted)
+	}
+	if err := check(txCtx, rec); err != nil {
+		return rollback(err)
+	}
+	if err := sm.repo.UpdateAccountStatus(txCtx, accountNumber, status); err != nil {
+		return rollback(err)
+	}
+
+	// COMMIT transaction
+	err = tx.Commit()
+	if err != nil {
+		lLog.Errorf("error committing transaction. got %s", err.Error())
+		return err
+	}
+	return nil
+}
+
+// ACCOUNT LIMIT MANAGER ------------------------------------------------------------------
+
+// NewMySQLAccountLimitManager returns new sql account limit manager
+func NewMySQLAccountLimitManager(repo connector.DBRepository) AccountLimitManager {
+	return &MySQLAccountLimitManager{repo: repo}
+}
+
+// MySQLAccountLimitManager implementation of AccountLimitManager using the limit columns of Account table in MySQL
+type MySQLAccountLimitManager struct {
+	repo connector.DBRepository
+}
+
+// GetAccountLimits returns the balance limits of the account.
+func (lm *MySQLAccountLimitManager) GetAccountLimits(ctx context.Context, accountNumber string) (*AccountLimits, error) {
+	requestID := ctx.Value(contextkeys.XRequestID).(string)
+	lLog := dbLog.WithField("RequestID", requestID).WithField("function", "GetAccountLimits")
+
+	rec, err := lm.repo.GetAccount(ctx, accountNumber)
+	if err != nil {
+		lLog.Errorf("error while calling lm.repo.GetAccount. got %s", err.Error())
+		return nil, err
+	}
+	if rec == nil {
+		return nil, acccore.ErrAccountIsNotPersisted
+	}
+	return &AccountLimits{
+		MinBalance:     rec.MinBalance,
+		OverdraftLimit: rec.OverdraftLimit,
+		MaxBalance:     rec.MaxBalance,
+	}, nil
+}
+
+// SetAccountLimits replaces the balance limits of the account.
+func (lm *MySQLAccountLimitManager) SetAccountLimits(ctx context.Context, accountNumber string, limits *AccountLimits) error {
+	requestID := ctx.Value(contextkeys.XRequestID).(string)
+	lLog := dbLog.WithField("RequestID", requestID).WithField("function", "SetAccountLimits")
+
+	if err := validateAccountLimits(limits); err != nil {
+		lLog.Errorf("error setting limits of account %s. got %s", accountNumber, err.Error())
+		return err
+	}
+
+	rec, err := lm.repo.GetAccount(ctx, accountNumber)
+	if err != nil {
+		lLog.Errorf("error while calling lm.repo.GetAccount. got %s", err.Error())
+		return err
+	}
+	if rec == nil {
+		return acccore.ErrAccountIsNotPersisted
+	}
+	return lm.repo.UpdateAccountLimits(ctx, accountNumber, limits.MinBalance, limits.OverdraftLimit, limits.MaxBalance)
+}
+
+// PersistAccountWithLimits persists the new account along with its balance limits, in a single database transaction.
+// Invalid limits are rejected before anything is written.
+func (lm *MySQLAccountLimitManager) PersistAccountWithLimits(ctx context.Context, account acccore.Account, limits *AccountLimits) error {
+	if err := validateAccountLimits(limits); err != nil {
+		return err
+	}
+	return (&MySQLAccountManager{repo: lm.repo}).persistAccount(ctx, account, limits)
+}
+
+// validateAccountLimits make sure the limits do not contradict each other
+func validateAccountLimits(limits *AccountLimits) error {
+	if limits.OverdraftLimit != nil && *limits.OverdraftLimit < 0 {
+		return fmt.Errorf("%w : overdraft limit can not be negative", ErrInvalidAccountLimits)
+	}
+	if limits.MinBalance != nil && limits.MaxBalance != nil && *limits.MinBalance > *limits.MaxBalance {
+		return fmt.Errorf("%w : minimum balance is greater than maximum balance", ErrInvalidAccountLimits)
+	}
+	return nil
+}
+
 // NewMySQLExchangeManager new sqlexcnage amanager
 func NewMySQLExchangeManager(repo connector.DBRepository) acccore.ExchangeManager {
-	return &MySQLExchangeManager{repo: repo, commonDenominator: 1.0}
+	return &MySQLExchangeManager{repo: repo, commonDenominators: make(map[string]float64)}
 }
 
 // MySQLExchangeManager is the manager struct
 type MySQLExchangeManager struct {
-	repo              connector.DBRepository
-	commonDenominator float64
+	repo connector.DBRepository
+	// commonDenominators is the common denominator of each tenant, 1.0 until it is set
+	commonDenominators map[string]float64
+	mutex              sync.RWMutex
 }
 
 // IsCurrencyExist will check in the exchange system for a currency existence
@@ -881,21 +1474,28 @@ func (am *MySQLExchangeManager) IsCurrencyExist(ctx context.Context, currency st
 	return true, nil
 }
 
-// GetDenom get the current common denominator used in the exchange
+// GetDenom get the current common denominator used in the exchange of the tenant
 func (am *MySQLExchangeManager) GetDenom(ctx context.Context) *big.Float {
 	//requestID := ctx.Value(contextkeys.XRequestID).(string)
 	//lLog := dbLog.WithField("RequestID", requestID).WithField("function", "GetDenom")
 
-	return big.NewFloat(am.commonDenominator)
+	am.mutex.RLock()
+	defer am.mutex.RUnlock()
+	if denom, ok := am.commonDenominators[connector.TenantFromContext(ctx)]; ok {
+		return big.NewFloat(denom)
+	}
+	return big.NewFloat(1.0)
 }
 
-// SetDenom set the current common denominator value into the specified value
+// SetDenom set the current common denominator of the tenant into the specified value
 func (am *MySQLExchangeManager) SetDenom(ctx context.Context, denom *big.Float) {
 	//requestID := ctx.Value(contextkeys.XRequestID).(string)
 	//lLog := dbLog.WithField("RequestID", requestID).WithField("function", "SetDenom")
 
 	f, _ := denom.Float64()
-	am.commonDenominator = f
+	am.mutex.Lock()
+	defer am.mutex.Unlock()
+	am.commonDenominators[connector.TenantFromContext(ctx)] = f
 }
 
 // GetCurrency retrieve currency data indicated by the code argument
@@ -2,7 +2,15 @@ package accounting
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"io"
 	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -10,6 +18,8 @@ import (
 	"github.com/hyperjumptech/bookkeeping/internal/config"
 	"github.com/hyperjumptech/bookkeeping/internal/connector"
 	"github.com/hyperjumptech/bookkeeping/internal/contextkeys"
+	"github.com/hyperjumptech/bookkeeping/internal/middlewares"
+	"github.com/robfig/cron/v3"
 )
 
 func TestAccounting_CreateNewAccount(t *testing.T) {
@@ -259,3 +269,1756 @@ func TestAccounting_CreateNewJournal(t *testing.T) {
 		t.Log(render)
 	}
 }
+
+// connectTestRepository connects to the test database, clears all tables and creates the GOLD currency.
+func connectTestRepository(ctx context.Context, t *testing.T) (*connector.MySQLDBRepository, *acccore.Accounting) {
+	config.GetInt("")
+	config.Set("db.host", "localhost")
+	config.Set("db.port", "6603")
+	config.Set("db.user", "devuser")
+	config.Set("db.password", "devuser")
+	config.Set("db.name", "devdb")
+
+	repo := &connector.MySQLDBRepository{}
+	err := repo.Connect(ctx)
+	if err != nil {
+		t.Errorf("cannot connect to db. got %s", err.Error())
+		t.FailNow()
+	}
+	err = repo.ClearTables(ctx)
+	if err != nil {
+		t.Errorf("cannot clear tables. got %s", err.Error())
+		t.FailNow()
+	}
+	_, err = NewMySQLExchangeManager(repo).CreateCurrency(ctx, "GOLD", "Gold Bullion", big.NewFloat(1.0), "TESTING")
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	acc := acccore.NewAccounting(NewMySQLAccountManager(repo), NewMySQLTransactionManager(repo), NewMySQLJournalManager(repo), &acccore.RandomGenUniqueIDGenerator{
+		Length:     16,
+		UpperAlpha: true,
+		Numeric:    true,
+	})
+	return repo, acc
+}
+
+func TestAccounting_AccountLifecycle(t *testing.T) {
+	if testing.Short() {
+		t.Skip("account lifecycle is only implemented by the MySQL managers")
+	}
+	ctx := context.WithValue(context.Background(), contextkeys.XRequestID, "1234567890")
+	ctx = context.WithValue(ctx, contextkeys.UserIDContextKey, "TESTING")
+
+	repo, acc := connectTestRepository(ctx, t)
+	stateManager := NewMySQLAccountStateManager(repo)
+
+	reserve, err := acc.CreateNewAccount(ctx, "", "Gold Reserve", "Gold reserve", "1.1", "GOLD", acccore.DEBIT, "aCreator")
+	if err != nil {
+		t.Error(err.Error())
+		t.FailNow()
+	}
+	equity, err := acc.CreateNewAccount(ctx, "", "Gold Equity", "Gold equity", "2.1", "GOLD", acccore.CREDIT, "aCreator")
+	if err != nil {
+		t.Error(err.Error())
+		t.FailNow()
+	}
+
+	post := func(amount int64, reserveType acccore.Alignment) error {
+		equityType := acccore.CREDIT
+		if reserveType == acccore.CREDIT {
+			equityType = acccore.DEBIT
+		}
+		_, err := acc.CreateNewJournal(ctx, "lifecycle", []acccore.TransactionInfo{
+			{AccountNumber: reserve.GetAccountNumber(), Description: "reserve", TxType: reserveType, Amount: amount},
+			{AccountNumber: equity.GetAccountNumber(), Description: "equity", TxType: equityType, Amount: amount},
+		}, "aCreator")
+		return err
+	}
+
+	stale, err := acc.GetAccountManager().GetAccountByID(ctx, reserve.GetAccountNumber())
+	if err != nil {
+		t.Error(err.Error())
+		t.FailNow()
+	}
+	if err := post(1000, acccore.DEBIT); err != nil {
+		t.Errorf("posting into active account should succeed. got %s", err.Error())
+	}
+	// updating the account details out of a stale read do not overwrite the balance
+	stale.SetName("Gold Vault")
+	if err := acc.GetAccountManager().UpdateAccount(ctx, stale); err != nil {
+		t.Error(err.Error())
+		t.FailNow()
+	}
+	if account, _ := acc.GetAccountManager().GetAccountByID(ctx, reserve.GetAccountNumber()); account.GetName() != "Gold Vault" || account.GetBalance() != 1000 {
+		t.Errorf("expecting the account renamed with balance 1000, got %s with %d", account.GetName(), account.GetBalance())
+	}
+
+	if err := stateManager.FreezeAccount(ctx, reserve.GetAccountNumber(), true); err != nil {
+		t.Error(err.Error())
+		t.FailNow()
+	}
+	if err := post(100, acccore.DEBIT); !errors.Is(err, ErrAccountDebitFrozen) {
+		t.Errorf("expecting ErrAccountDebitFrozen, got %v", err)
+	}
+	if err := post(100, acccore.CREDIT); err != nil {
+		t.Errorf("crediting a debit frozen account should succeed. got %s", err.Error())
+	}
+
+	if err := stateManager.FreezeAccount(ctx, reserve.GetAccountNumber(), false); err != nil {
+		t.Error(err.Error())
+		t.FailNow()
+	}
+	if err := post(100, acccore.CREDIT); !errors.Is(err, ErrAccountFrozen) {
+		t.Errorf("expecting ErrAccountFrozen, got %v", err)
+	}
+
+	if err := stateManager.UnfreezeAccount(ctx, reserve.GetAccountNumber()); err != nil {
+		t.Error(err.Error())
+		t.FailNow()
+	}
+	if err := stateManager.CloseAccount(ctx, reserve.GetAccountNumber()); !errors.Is(err, ErrAccountBalanceNotZero) {
+		t.Errorf("expecting ErrAccountBalanceNotZero, got %v", err)
+	}
+	if err := post(900, acccore.CREDIT); err != nil {
+		t.Error(err.Error())
+		t.FailNow()
+	}
+	// a fund on hold, within the overdraft, keeps the account open
+	overdraft := int64(100)
+	if err := NewMySQLAccountLimitManager(repo).SetAccountLimits(ctx, reserve.GetAccountNumber(), &AccountLimits{OverdraftLimit: &overdraft}); err != nil {
+		t.Error(err.Error())
+		t.FailNow()
+	}
+	holdManager := NewMySQLHoldManager(repo, acc.GetUniqueIDGenerator())
+	hold, err := holdManager.PlaceHold(ctx, reserve.GetAccountNumber(), 50, "order", time.Now().Add(time.Hour), "aCreator")
+	if err != nil {
+		t.Error(err.Error())
+		t.FailNow()
+	}
+	if err := stateManager.CloseAccount(ctx, reserve.GetAccountNumber()); !errors.Is(err, ErrAccountHasActiveHolds) {
+		t.Errorf("expecting ErrAccountHasActiveHolds, got %v", err)
+	}
+	if _, err := holdManager.VoidHold(ctx, hold.HoldID, "aCreator"); err != nil {
+		t.Error(err.Error())
+		t.FailNow()
+	}
+	if err := stateManager.CloseAccount(ctx, reserve.GetAccountNumber()); err != nil {
+		t.Error(err.Error())
+		t.FailNow()
+	}
+	if err := post(100, acccore.DEBIT); !errors.Is(err, ErrAccountClosed) {
+		t.Errorf("expecting ErrAccountClosed, got %v", err)
+	}
+	if err := stateManager.UnfreezeAccount(ctx, reserve.GetAccountNumber()); !errors.Is(err, ErrAccountClosed) {
+		t.Errorf("expecting ErrAccountClosed on unfreezing closed account, got %v", err)
+	}
+}
+
+func TestAccounting_PersistAccountWithLimits(t *testing.T) {
+	if testing.Short() {
+		t.Skip("account limits are only implemented by the MySQL managers")
+	}
+	ctx := context.WithValue(context.Background(), contextkeys.XRequestID, "1234567890")
+	ctx = context.WithValue(ctx, contextkeys.UserIDContextKey, "TESTING")
+
+	repo, acc := connectTestRepository(ctx, t)
+	limitManager := NewMySQLAccountLimitManager(repo)
+	account := func(accountNumber string) acccore.Account {
+		ret := &acccore.BaseAccount{}
+		ret.SetAccountNumber(accountNumber).SetName("Gold Wallet").SetDescription("Gold wallet").SetCOA("2.1").SetCurrency("GOLD").
+			SetAlignment(acccore.CREDIT).SetCreateBy("aCreator").SetUpdateBy("aCreator")
+		return ret
+	}
+
+	minBalance, maxBalance := int64(100), int64(10)
+	err := limitManager.PersistAccountWithLimits(ctx, account("WALLET1"), &AccountLimits{MinBalance: &minBalance, MaxBalance: &maxBalance})
+	if !errors.Is(err, ErrInvalidAccountLimits) {
+		t.Errorf("expecting ErrInvalidAccountLimits, got %v", err)
+	}
+	if existing, _ := acc.GetAccountManager().GetAccountByID(ctx, "WALLET1"); existing != nil {
+		t.Errorf("expecting the account not created with invalid limits")
+	}
+
+	zero := int64(0)
+	if err := limitManager.PersistAccountWithLimits(ctx, account("WALLET1"), &AccountLimits{MinBalance: &zero}); err != nil {
+		t.Error(err.Error())
+		t.FailNow()
+	}
+	limits, err := limitManager.GetAccountLimits(ctx, "WALLET1")
+	if err != nil || limits.MinBalance == nil || *limits.MinBalance != 0 || limits.MaxBalance != nil {
+		t.Errorf("expecting the account created with a zero minimum balance, got %+v %v", limits, err)
+	}
+}
+
+func TestCheckAccountLimits(t *testing.T) {
+	limit := func(v int64) *int64 { return &v }
+	testData := []struct {
+		name       string
+		account    *connector.AccountRecord
+		newBalance int64
+		held       int64
+		expect     error
+	}{
+		{"no limits", &connector.AccountRecord{Balance: 0}, -100, 0, nil},
+		{"no limits held fund is not available", &connector.AccountRecord{Balance: 100}, 50, 60, ErrInsufficientBalance},
+		{"no limits held fund covered", &connector.AccountRecord{Balance: 100}, 60, 60, nil},
+		{"no limits negative with held fund", &connector.AccountRecord{Balance: -100}, -150, 10, ErrInsufficientBalance},
+		{"no limits recovering toward zero", &connector.AccountRecord{Balance: -100}, -50, 10, nil},
+		{"above minimum", &connector.AccountRecord{Balance: 100, MinBalance: limit(0)}, 0, 0, nil},
+		{"below minimum", &connector.AccountRecord{Balance: 100, MinBalance: limit(0)}, -1, 0, ErrAccountMinimumBalance},
+		{"within overdraft", &connector.AccountRecord{Balance: 100, OverdraftLimit: limit(50)}, -50, 0, nil},
+		{"beyond overdraft", &connector.AccountRecord{Balance: 100, OverdraftLimit: limit(50)}, -51, 0, ErrAccountOverdraftLimit},
+		{"overdraft below minimum", &connector.AccountRecord{Balance: 100, MinBalance: limit(20), OverdraftLimit: limit(50)}, -30, 0, nil},
+		{"beyond overdraft below minimum", &connector.AccountRecord{Balance: 100, MinBalance: limit(20), OverdraftLimit: limit(50)}, -31, 0, ErrAccountOverdraftLimit},
+		{"below maximum", &connector.AccountRecord{Balance: 100, MaxBalance: limit(200)}, 200, 0, nil},
+		{"above maximum", &connector.AccountRecord{Balance: 100, MaxBalance: limit(200)}, 201, 0, ErrAccountMaximumBalance},
+		{"recovering toward minimum", &connector.AccountRecord{Balance: -100, MinBalance: limit(0)}, -50, 0, nil},
+		{"recovering toward maximum", &connector.AccountRecord{Balance: 300, MaxBalance: limit(200)}, 250, 0, nil},
+		{"held fund is not available", &connector.AccountRecord{Balance: 100, MinBalance: limit(0)}, 50, 60, ErrAccountMinimumBalance},
+		{"held fund within overdraft", &connector.AccountRecord{Balance: 100, OverdraftLimit: limit(50)}, 50, 100, nil},
+		{"held fund ignored by maximum", &connector.AccountRecord{Balance: 100, MaxBalance: limit(200)}, 200, 100, nil},
+	}
+	for _, td := range testData {
+		err := checkAccountLimits(td.account, td.newBalance, td.held)
+		if td.expect == nil && err != nil {
+			t.Errorf("%s : expecting no error, got %s", td.name, err.Error())
+		}
+		if td.expect != nil && !errors.Is(err, td.expect) {
+			t.Errorf("%s : expecting %v, got %v", td.name, td.expect, err)
+		}
+	}
+}
+
+func TestAccounting_Holds(t *testing.T) {
+	if testing.Short() {
+		t.Skip("holds are only implemented by the MySQL managers")
+	}
+	ctx := context.WithValue(context.Background(), contextkeys.XRequestID, "1234567890")
+	ctx = context.WithValue(ctx, contextkeys.UserIDContextKey, "TESTING")
+
+	repo, acc := connectTestRepository(ctx, t)
+	holdManager := NewMySQLHoldManager(repo, acc.GetUniqueIDGenerator())
+
+	wallet, err := acc.CreateNewAccount(ctx, "", "Gold Wallet", "Customer gold wallet", "2.1", "GOLD", acccore.CREDIT, "aCreator")
+	if err != nil {
+		t.Error(err.Error())
+		t.FailNow()
+	}
+	merchant, err := acc.CreateNewAccount(ctx, "", "Gold Merchant", "Merchant gold wallet", "2.2", "GOLD", acccore.CREDIT, "aCreator")
+	if err != nil {
+		t.Error(err.Error())
+		t.FailNow()
+	}
+	reserve, err := acc.CreateNewAccount(ctx, "", "Gold Reserve", "Gold reserve", "1.1", "GOLD", acccore.DEBIT, "aCreator")
+	if err != nil {
+		t.Error(err.Error())
+		t.FailNow()
+	}
+	zero := int64(0)
+	if err := NewMySQLAccountLimitManager(repo).SetAccountLimits(ctx, wallet.GetAccountNumber(), &AccountLimits{MinBalance: &zero}); err != nil {
+		t.Error(err.Error())
+		t.FailNow()
+	}
+	_, err = acc.CreateNewJournal(ctx, "topup", []acccore.TransactionInfo{
+		{AccountNumber: reserve.GetAccountNumber(), Description: "topup", TxType: acccore.DEBIT, Amount: 1000},
+		{AccountNumber: wallet.GetAccountNumber(), Description: "topup", TxType: acccore.CREDIT, Amount: 1000},
+	}, "aCreator")
+	if err != nil {
+		t.Error(err.Error())
+		t.FailNow()
+	}
+
+	hold, err := holdManager.PlaceHold(ctx, wallet.GetAccountNumber(), 700, "order", time.Now().Add(time.Hour), "aCreator")
+	if err != nil {
+		t.Error(err.Error())
+		t.FailNow()
+	}
+	if available, _ := holdManager.GetAvailableBalance(ctx, wallet.GetAccountNumber()); available != 300 {
+		t.Errorf("expecting available balance 300, got %d", available)
+	}
+	if _, err := holdManager.PlaceHold(ctx, wallet.GetAccountNumber(), 400, "another order", time.Now().Add(time.Hour), "aCreator"); !errors.Is(err, ErrAccountMinimumBalance) {
+		t.Errorf("expecting ErrAccountMinimumBalance, got %v", err)
+	}
+
+	hold, err = holdManager.CaptureHold(ctx, hold.HoldID, merchant.GetAccountNumber(), 500, "", "aCreator")
+	if err != nil {
+		t.Error(err.Error())
+		t.FailNow()
+	}
+	if hold.Status != "CAPTURED" || len(hold.JournalID) == 0 {
+		t.Errorf("expecting captured hold with journal, got %s %s", hold.Status, hold.JournalID)
+	}
+	if available, _ := holdManager.GetAvailableBalance(ctx, wallet.GetAccountNumber()); available != 500 {
+		t.Errorf("expecting available balance 500, got %d", available)
+	}
+	if _, err := holdManager.VoidHold(ctx, hold.HoldID, "aCreator"); !errors.Is(err, ErrHoldNotActive) {
+		t.Errorf("expecting ErrHoldNotActive, got %v", err)
+	}
+
+	hold, err = holdManager.PlaceHold(ctx, wallet.GetAccountNumber(), 500, "order", time.Now().Add(time.Hour), "aCreator")
+	if err != nil {
+		t.Error(err.Error())
+		t.FailNow()
+	}
+	if _, err := holdManager.VoidHold(ctx, hold.HoldID, "aCreator"); err != nil {
+		t.Error(err.Error())
+	}
+	if available, _ := holdManager.GetAvailableBalance(ctx, wallet.GetAccountNumber()); available != 500 {
+		t.Errorf("expecting available balance 500 after void, got %d", available)
+	}
+}
+
+func TestAccounting_RecurringJournals(t *testing.T) {
+	if testing.Short() {
+		t.Skip("recurring journals are only implemented by the MySQL managers")
+	}
+	ctx := context.WithValue(context.Background(), contextkeys.XRequestID, "1234567890")
+	ctx = context.WithValue(ctx, contextkeys.UserIDContextKey, "TESTING")
+
+	repo, acc := connectTestRepository(ctx, t)
+	recurringManager := NewMySQLRecurringJournalManager(repo, acc.GetUniqueIDGenerator(), cron.New())
+
+	salary, err := acc.CreateNewAccount(ctx, "", "Gold Salary", "Salary expense", "5.1", "GOLD", acccore.DEBIT, "aCreator")
+	if err != nil {
+		t.Error(err.Error())
+		t.FailNow()
+	}
+	payable, err := acc.CreateNewAccount(ctx, "", "Gold Payable", "Salary payable", "2.1", "GOLD", acccore.CREDIT, "aCreator")
+	if err != nil {
+		t.Error(err.Error())
+		t.FailNow()
+	}
+	journal := &CreateJournalRequest{
+		Description: "monthly salary",
+		Creator:     "aCreator",
+		Transactions: []*TransactionRequest{
+			{AccountNumber: salary.GetAccountNumber(), Description: "salary", Alignment: "DEBIT", Amount: 100},
+			{AccountNumber: payable.GetAccountNumber(), Description: "salary", Alignment: "CREDIT", Amount: 100},
+		},
+	}
+
+	if _, err := recurringManager.CreateRecurringJournal(ctx, "every month", journal); !errors.Is(err, ErrInvalidCronExpression) {
+		t.Errorf("expecting ErrInvalidCronExpression, got %v", err)
+	}
+	rj, err := recurringManager.CreateRecurringJournal(ctx, "0 0 1 * *", journal)
+	if err != nil {
+		t.Error(err.Error())
+		t.FailNow()
+	}
+	if rj.Status != connector.RecurringJournalStatusActive || rj.NextRun == nil {
+		t.Errorf("expecting active and scheduled recurring journal, got %s %v", rj.Status, rj.NextRun)
+	}
+
+	run, err := recurringManager.RunRecurringJournal(ctx, rj.ScheduleID)
+	if err != nil {
+		t.Error(err.Error())
+		t.FailNow()
+	}
+	if !run.Success || len(run.JournalID) == 0 {
+		t.Errorf("expecting successful run with journal, got %v %s", run.Success, run.ErrorMessage)
+	}
+	if account, _ := acc.GetAccountManager().GetAccountByID(ctx, payable.GetAccountNumber()); account.GetBalance() != 100 {
+		t.Errorf("expecting payable balance 100, got %d", account.GetBalance())
+	}
+
+	if err := NewMySQLAccountStateManager(repo).FreezeAccount(ctx, payable.GetAccountNumber(), false); err != nil {
+		t.Error(err.Error())
+		t.FailNow()
+	}
+	run, err = recurringManager.RunRecurringJournal(ctx, rj.ScheduleID)
+	if err != nil {
+		t.Error(err.Error())
+		t.FailNow()
+	}
+	if run.Success || len(run.ErrorMessage) == 0 {
+		t.Errorf("expecting failed run on frozen account")
+	}
+	_, runs, err := recurringManager.ListRecurringJournalRuns(ctx, rj.ScheduleID, acccore.PageRequest{PageNo: 1, ItemSize: 10})
+	if err != nil {
+		t.Error(err.Error())
+		t.FailNow()
+	}
+	if len(runs) != 2 || runs[0].Success || !runs[1].Success {
+		t.Errorf("expecting failed run followed by successful run, got %d runs", len(runs))
+	}
+
+	rj, err = recurringManager.PauseRecurringJournal(ctx, rj.ScheduleID)
+	if err != nil {
+		t.Error(err.Error())
+		t.FailNow()
+	}
+	if rj.Status != connector.RecurringJournalStatusPaused || rj.NextRun != nil {
+		t.Errorf("expecting paused recurring journal, got %s %v", rj.Status, rj.NextRun)
+	}
+	if _, err := recurringManager.RunRecurringJournal(ctx, rj.ScheduleID); !errors.Is(err, ErrRecurringJournalNotActive) {
+		t.Errorf("expecting ErrRecurringJournalNotActive running a paused recurring journal, got %v", err)
+	}
+	rj, err = recurringManager.ResumeRecurringJournal(ctx, rj.ScheduleID)
+	if err != nil {
+		t.Error(err.Error())
+		t.FailNow()
+	}
+	if rj.Status != connector.RecurringJournalStatusActive || rj.NextRun == nil {
+		t.Errorf("expecting resumed recurring journal, got %s %v", rj.Status, rj.NextRun)
+	}
+
+	// every instance scheduling the recurring journal fires it, only the first claims the fire time
+	if err := NewMySQLAccountStateManager(repo).UnfreezeAccount(ctx, payable.GetAccountNumber()); err != nil {
+		t.Error(err.Error())
+		t.FailNow()
+	}
+	fireTime := time.Now().Truncate(time.Minute)
+	run, err = recurringManager.(*MySQLRecurringJournalManager).runRecurringJournal(ctx, rj.ScheduleID, &fireTime)
+	if err != nil || !run.Success {
+		t.Errorf("expecting successful scheduled run, got %v", err)
+	}
+	if _, err := recurringManager.(*MySQLRecurringJournalManager).runRecurringJournal(ctx, rj.ScheduleID, &fireTime); !errors.Is(err, ErrRecurringJournalRunClaimed) {
+		t.Errorf("expecting ErrRecurringJournalRunClaimed running the same fire time twice, got %v", err)
+	}
+	if account, _ := acc.GetAccountManager().GetAccountByID(ctx, payable.GetAccountNumber()); account.GetBalance() != 200 {
+		t.Errorf("expecting payable balance 200, got %d", account.GetBalance())
+	}
+
+	// another instance picks up the recurring journals changed by this one
+	other := NewMySQLRecurringJournalManager(repo, acc.GetUniqueIDGenerator(), cron.New()).(*MySQLRecurringJournalManager)
+	if err := other.ScheduleAll(ctx); err != nil {
+		t.Error(err.Error())
+		t.FailNow()
+	}
+	if _, ok := other.entries[rj.ScheduleID]; !ok {
+		t.Errorf("expecting the active recurring journal scheduled on the other instance")
+	}
+	if _, err := recurringManager.PauseRecurringJournal(ctx, rj.ScheduleID); err != nil {
+		t.Error(err.Error())
+		t.FailNow()
+	}
+	if err := other.ScheduleAll(ctx); err != nil {
+		t.Error(err.Error())
+		t.FailNow()
+	}
+	if _, ok := other.entries[rj.ScheduleID]; ok || len(other.scheduler.Entries()) != len(other.entries) {
+		t.Errorf("expecting the paused recurring journal unscheduled on the other instance")
+	}
+}
+
+func TestMatchApprovalRule(t *testing.T) {
+	limit := func(v int64) *int64 { return &v }
+	accounts := map[string]*connector.AccountRecord{
+		"1": {AccountNumber: "1", Coa: "1.1"},
+		"2": {AccountNumber: "2", Coa: "5.2"},
+	}
+	testData := []struct {
+		name   string
+		rule   *connector.ApprovalRuleRecord
+		amount int64
+		expect bool
+	}{
+		{"no criteria", &connector.ApprovalRuleRecord{}, 1, true},
+		{"below threshold", &connector.ApprovalRuleRecord{MinAmount: limit(1000)}, 999, false},
+		{"at threshold", &connector.ApprovalRuleRecord{MinAmount: limit(1000)}, 1000, true},
+		{"matching coa", &connector.ApprovalRuleRecord{Coa: "5"}, 1, true},
+		{"other coa", &connector.ApprovalRuleRecord{Coa: "3"}, 1, false},
+		{"matching coa below threshold", &connector.ApprovalRuleRecord{MinAmount: limit(1000), Coa: "5.2"}, 10, false},
+		{"matching coa above threshold", &connector.ApprovalRuleRecord{MinAmount: limit(1000), Coa: "5.2"}, 5000, true},
+	}
+	for _, td := range testData {
+		if got := matchApprovalRule(td.rule, td.amount, accounts); got != td.expect {
+			t.Errorf("%s : expecting %v, got %v", td.name, td.expect, got)
+		}
+	}
+}
+
+func TestAccounting_Approval(t *testing.T) {
+	if testing.Short() {
+		t.Skip("approval is only implemented by the MySQL managers")
+	}
+	ctx := context.WithValue(context.Background(), contextkeys.XRequestID, "1234567890")
+	ctx = context.WithValue(ctx, contextkeys.UserIDContextKey, "TESTING")
+
+	repo, acc := connectTestRepository(ctx, t)
+	approvalManager := NewMySQLApprovalManager(repo, acc.GetUniqueIDGenerator())
+
+	expense, err := acc.CreateNewAccount(ctx, "", "Gold Expense", "Adjustment expense", "5.1", "GOLD", acccore.DEBIT, "aCreator")
+	if err != nil {
+		t.Error(err.Error())
+		t.FailNow()
+	}
+	cash, err := acc.CreateNewAccount(ctx, "", "Gold Cash", "Gold cash", "1.1", "GOLD", acccore.DEBIT, "aCreator")
+	if err != nil {
+		t.Error(err.Error())
+		t.FailNow()
+	}
+	journal := func(amount int64) *CreateJournalRequest {
+		return &CreateJournalRequest{
+			Description: "manual adjustment",
+			Creator:     "maker",
+			Transactions: []*TransactionRequest{
+				{AccountNumber: cash.GetAccountNumber(), Description: "adjustment", Alignment: "DEBIT", Amount: amount},
+				{AccountNumber: expense.GetAccountNumber(), Description: "adjustment", Alignment: "CREDIT", Amount: amount},
+			},
+		}
+	}
+
+	threshold := int64(1000)
+	if _, err := approvalManager.CreateApprovalRule(ctx, &ApprovalRule{Description: "big adjustment", MinAmount: &threshold}, "admin"); err != nil {
+		t.Error(err.Error())
+		t.FailNow()
+	}
+	if need, _ := approvalManager.RequiresApproval(ctx, journal(999)); need {
+		t.Errorf("expecting journal below threshold not to need approval")
+	}
+	if need, _ := approvalManager.RequiresApproval(ctx, journal(1000)); !need {
+		t.Errorf("expecting journal at threshold to need approval")
+	}
+
+	pending, err := approvalManager.SubmitJournal(ctx, journal(1000))
+	if err != nil {
+		t.Error(err.Error())
+		t.FailNow()
+	}
+	if _, err := approvalManager.ApproveJournal(ctx, pending.PendingID, "maker", ""); !errors.Is(err, ErrSelfApproval) {
+		t.Errorf("expecting ErrSelfApproval, got %v", err)
+	}
+	if account, _ := acc.GetAccountManager().GetAccountByID(ctx, cash.GetAccountNumber()); account.GetBalance() != 0 {
+		t.Errorf("expecting pending journal not posted, got balance %d", account.GetBalance())
+	}
+	pending, err = approvalManager.ApproveJournal(ctx, pending.PendingID, "checker", "looks good")
+	if err != nil {
+		t.Error(err.Error())
+		t.FailNow()
+	}
+	if pending.Status != connector.PendingJournalStatusApproved || len(pending.JournalID) == 0 || pending.ReviewedBy != "checker" {
+		t.Errorf("expecting approved pending journal with journal, got %s %s %s", pending.Status, pending.JournalID, pending.ReviewedBy)
+	}
+	approvedJournalID := pending.JournalID
+	if account, _ := acc.GetAccountManager().GetAccountByID(ctx, cash.GetAccountNumber()); account.GetBalance() != 1000 {
+		t.Errorf("expecting approved journal posted, got balance %d", account.GetBalance())
+	}
+	if _, err := approvalManager.RejectJournal(ctx, pending.PendingID, "checker", ""); !errors.Is(err, ErrPendingJournalNotPending) {
+		t.Errorf("expecting ErrPendingJournalNotPending, got %v", err)
+	}
+
+	pending, err = approvalManager.SubmitJournal(ctx, journal(2000))
+	if err != nil {
+		t.Error(err.Error())
+		t.FailNow()
+	}
+	pending, err = approvalManager.RejectJournal(ctx, pending.PendingID, "checker", "wrong amount")
+	if err != nil {
+		t.Error(err.Error())
+		t.FailNow()
+	}
+	if pending.Status != connector.PendingJournalStatusRejected {
+		t.Errorf("expecting rejected pending journal, got %s", pending.Status)
+	}
+	if account, _ := acc.GetAccountManager().GetAccountByID(ctx, cash.GetAccountNumber()); account.GetBalance() != 1000 {
+		t.Errorf("expecting rejected journal not posted, got balance %d", account.GetBalance())
+	}
+
+	// the journals posted without their own request can not go around the approval rules
+	reversalManager := NewMySQLReversalManager(repo, acc.GetJournalManager(), acc.GetUniqueIDGenerator())
+	if _, err := reversalManager.ReverseJournal(ctx, approvedJournalID, nil, "undo", "maker"); !errors.Is(err, ErrJournalRequiresApproval) {
+		t.Errorf("expecting ErrJournalRequiresApproval reversing journal, got %v", err)
+	}
+	if account, _ := acc.GetAccountManager().GetAccountByID(ctx, cash.GetAccountNumber()); account.GetBalance() != 1000 {
+		t.Errorf("expecting the reversal not posted, got balance %d", account.GetBalance())
+	}
+	recurringManager := NewMySQLRecurringJournalManager(repo, acc.GetUniqueIDGenerator(), cron.New())
+	if _, err := recurringManager.CreateRecurringJournal(ctx, "0 0 1 * *", journal(1000)); !errors.Is(err, ErrJournalRequiresApproval) {
+		t.Errorf("expecting ErrJournalRequiresApproval creating recurring journal, got %v", err)
+	}
+	holdManager := NewMySQLHoldManager(repo, acc.GetUniqueIDGenerator())
+	if _, err := holdManager.PlaceHold(ctx, cash.GetAccountNumber(), 1000, "big order", time.Now().Add(time.Hour), "maker"); !errors.Is(err, ErrHoldRequiresApproval) {
+		t.Errorf("expecting ErrHoldRequiresApproval placing hold, got %v", err)
+	}
+	hold, err := holdManager.PlaceHold(ctx, cash.GetAccountNumber(), 100, "small order", time.Now().Add(time.Hour), "maker")
+	if err != nil {
+		t.Error(err.Error())
+		t.FailNow()
+	}
+	if _, err := holdManager.CaptureHold(ctx, hold.HoldID, expense.GetAccountNumber(), 0, "", "maker"); err != nil {
+		t.Errorf("expecting the hold below the approval rule captured, got %v", err)
+	}
+	if account, _ := acc.GetAccountManager().GetAccountByID(ctx, cash.GetAccountNumber()); account.GetBalance() != 900 {
+		t.Errorf("expecting the hold captured, got balance %d", account.GetBalance())
+	}
+}
+
+func TestAccounting_JournalBatch(t *testing.T) {
+	if testing.Short() {
+		t.Skip("journal batch is only implemented by the MySQL managers")
+	}
+	ctx := context.WithValue(context.Background(), contextkeys.XRequestID, "1234567890")
+	ctx = context.WithValue(ctx, contextkeys.UserIDContextKey, "TESTING")
+
+	repo, acc := connectTestRepository(ctx, t)
+	batchManager := NewMySQLJournalBatchManager(repo)
+
+	cash, err := acc.CreateNewAccount(ctx, "", "Gold Cash", "Gold cash", "1.1", "GOLD", acccore.DEBIT, "aCreator")
+	if err != nil {
+		t.Error(err.Error())
+		t.FailNow()
+	}
+	equity, err := acc.CreateNewAccount(ctx, "", "Gold Equity", "Gold equity", "3.1", "GOLD", acccore.CREDIT, "aCreator")
+	if err != nil {
+		t.Error(err.Error())
+		t.FailNow()
+	}
+	journal := func(debit, credit string, amount int64) acccore.Journal {
+		return NewJournalFromRequest(&CreateJournalRequest{
+			Description: "batch",
+			Creator:     "aCreator",
+			Transactions: []*TransactionRequest{
+				{AccountNumber: debit, Description: "batch", Alignment: "DEBIT", Amount: amount},
+				{AccountNumber: credit, Description: "batch", Alignment: "CREDIT", Amount: amount},
+			},
+		}, acc.GetUniqueIDGenerator())
+	}
+
+	err = batchManager.PersistJournals(ctx, []acccore.Journal{
+		journal(cash.GetAccountNumber(), equity.GetAccountNumber(), 1000),
+		journal(cash.GetAccountNumber(), "NOTEXIST", 500),
+		journal(cash.GetAccountNumber(), equity.GetAccountNumber(), 200),
+	})
+	batchErr := &JournalBatchError{}
+	if !errors.As(err, &batchErr) || len(batchErr.Errors) != 1 || batchErr.Errors[1] == nil {
+		t.Fatalf("expecting journal 1 rejected, got %v", err)
+	}
+	if account, _ := acc.GetAccountManager().GetAccountByID(ctx, cash.GetAccountNumber()); account.GetBalance() != 0 {
+		t.Errorf("expecting nothing persisted from rejected batch, got balance %d", account.GetBalance())
+	}
+
+	err = batchManager.PersistJournals(ctx, []acccore.Journal{
+		journal(cash.GetAccountNumber(), equity.GetAccountNumber(), 1000),
+		journal(equity.GetAccountNumber(), cash.GetAccountNumber(), 300),
+	})
+	if err != nil {
+		t.Error(err.Error())
+		t.FailNow()
+	}
+	if account, _ := acc.GetAccountManager().GetAccountByID(ctx, cash.GetAccountNumber()); account.GetBalance() != 700 {
+		t.Errorf("expecting both journals persisted, got balance %d", account.GetBalance())
+	}
+}
+
+func TestAccounting_Idempotency(t *testing.T) {
+	if testing.Short() {
+		t.Skip("idempotency is only implemented by the MySQL managers")
+	}
+	ctx := context.WithValue(context.Background(), contextkeys.XRequestID, "1234567890")
+	ctx = context.WithValue(ctx, contextkeys.UserIDContextKey, "TESTING")
+
+	repo, acc := connectTestRepository(ctx, t)
+	idempotencyManager := NewMySQLIdempotencyManager(repo)
+
+	cash, err := acc.CreateNewAccount(ctx, "", "Gold Cash", "Gold cash", "1.1", "GOLD", acccore.DEBIT, "aCreator")
+	if err != nil {
+		t.Error(err.Error())
+		t.FailNow()
+	}
+	equity, err := acc.CreateNewAccount(ctx, "", "Gold Equity", "Gold equity", "3.1", "GOLD", acccore.CREDIT, "aCreator")
+	if err != nil {
+		t.Error(err.Error())
+		t.FailNow()
+	}
+	journal := func() acccore.Journal {
+		return NewJournalFromRequest(&CreateJournalRequest{
+			Description: "idempotent",
+			Creator:     "aCreator",
+			Transactions: []*TransactionRequest{
+				{AccountNumber: cash.GetAccountNumber(), Description: "idempotent", Alignment: "DEBIT", Amount: 100},
+				{AccountNumber: equity.GetAccountNumber(), Description: "idempotent", Alignment: "CREDIT", Amount: 100},
+			},
+		}, acc.GetUniqueIDGenerator())
+	}
+	claim := func(client string) *IdempotencyClaim {
+		return &IdempotencyClaim{ClientID: client, Scope: connector.IdempotencyScopeJournal, Key: "key-1", Digest: "digest-1"}
+	}
+
+	if req, err := idempotencyManager.GetIdempotentRequest(ctx, "client-1", connector.IdempotencyScopeJournal, "key-1"); req != nil || err != nil {
+		t.Fatalf("expecting unused key, got %v %v", req, err)
+	}
+	first := journal()
+	if err := acc.GetJournalManager().PersistJournal(withIdempotencyClaim(ctx, claim("client-1")), first); err != nil {
+		t.Error(err.Error())
+		t.FailNow()
+	}
+	req, err := idempotencyManager.GetIdempotentRequest(ctx, "client-1", connector.IdempotencyScopeJournal, "key-1")
+	if err != nil || req == nil || req.Code != 200 || req.ResourceID != first.GetJournalID() || req.Digest != "digest-1" {
+		t.Errorf("expecting key stored with the journal, got %v %v", req, err)
+	}
+
+	// a second journal with the key taken is not persisted
+	taken := claim("client-1")
+	if err := acc.GetJournalManager().PersistJournal(withIdempotencyClaim(ctx, taken), journal()); !errors.Is(err, ErrIdempotencyKeyTaken) || !taken.taken {
+		t.Errorf("expecting ErrIdempotencyKeyTaken, got %v", err)
+	}
+	if account, _ := acc.GetAccountManager().GetAccountByID(ctx, cash.GetAccountNumber()); account.GetBalance() != 100 {
+		t.Errorf("expecting only the first journal persisted, got balance %d", account.GetBalance())
+	}
+
+	// the keys of a client are not the keys of another
+	if err := acc.GetJournalManager().PersistJournal(withIdempotencyClaim(ctx, claim("client-2")), journal()); err != nil {
+		t.Errorf("expecting the key of another client stored, got %v", err)
+	}
+	// nor the keys of another scope
+	if req, err := idempotencyManager.GetIdempotentRequest(ctx, "client-1", connector.IdempotencyScopeAccount, "key-1"); req != nil || err != nil {
+		t.Errorf("expecting the key unused in account scope, got %v %v", req, err)
+	}
+	if _, err := idempotencyManager.GetIdempotentRequest(ctx, "client-1", connector.IdempotencyScopeJournal, strings.Repeat("k", 65)); !errors.Is(err, ErrInvalidIdempotencyKey) {
+		t.Errorf("expecting ErrInvalidIdempotencyKey, got %v", err)
+	}
+}
+
+func TestAccounting_SimulateJournal(t *testing.T) {
+	if testing.Short() {
+		t.Skip("journal simulation is only implemented by the MySQL managers")
+	}
+	ctx := context.WithValue(context.Background(), contextkeys.XRequestID, "1234567890")
+	ctx = context.WithValue(ctx, contextkeys.UserIDContextKey, "TESTING")
+
+	repo, acc := connectTestRepository(ctx, t)
+	simulationManager := NewMySQLJournalSimulationManager(repo)
+
+	cash, err := acc.CreateNewAccount(ctx, "", "Gold Cash", "Gold cash", "1.1", "GOLD", acccore.DEBIT, "aCreator")
+	if err != nil {
+		t.Error(err.Error())
+		t.FailNow()
+	}
+	equity, err := acc.CreateNewAccount(ctx, "", "Gold Equity", "Gold equity", "3.1", "GOLD", acccore.CREDIT, "aCreator")
+	if err != nil {
+		t.Error(err.Error())
+		t.FailNow()
+	}
+	journal := func(debit, credit string, amount int64) acccore.Journal {
+		return NewJournalFromRequest(&CreateJournalRequest{
+			Description: "simulation",
+			Creator:     "aCreator",
+			Transactions: []*TransactionRequest{
+				{AccountNumber: debit, Description: "simulation", Alignment: "DEBIT", Amount: amount},
+				{AccountNumber: credit, Description: "simulation", Alignment: "CREDIT", Amount: amount},
+			},
+		}, acc.GetUniqueIDGenerator())
+	}
+
+	simulation, err := simulationManager.SimulateJournal(ctx, journal(cash.GetAccountNumber(), equity.GetAccountNumber(), 1000))
+	if err != nil {
+		t.Error(err.Error())
+		t.FailNow()
+	}
+	if !simulation.Accepted || len(simulation.Balances) != 2 {
+		t.Fatalf("expecting accepted simulation with 2 balances, got %+v", simulation)
+	}
+	for _, balance := range simulation.Balances {
+		if balance.Balance != 0 || balance.ProjectedBalance != 1000 {
+			t.Errorf("expecting balance 0 projected to 1000, got %d projected to %d", balance.Balance, balance.ProjectedBalance)
+		}
+	}
+	if account, _ := acc.GetAccountManager().GetAccountByID(ctx, cash.GetAccountNumber()); account.GetBalance() != 0 {
+		t.Errorf("expecting nothing persisted by simulation, got balance %d", account.GetBalance())
+	}
+
+	simulation, err = simulationManager.SimulateJournal(ctx, journal(cash.GetAccountNumber(), "NOTEXIST", 1000))
+	if err != nil {
+		t.Error(err.Error())
+		t.FailNow()
+	}
+	if simulation.Accepted || len(simulation.Error) == 0 {
+		t.Errorf("expecting rejected simulation, got %+v", simulation)
+	}
+}
+
+func TestAccounting_PartialReversal(t *testing.T) {
+	if testing.Short() {
+		t.Skip("partial reversal is only implemented by the MySQL managers")
+	}
+	ctx := context.WithValue(context.Background(), contextkeys.XRequestID, "1234567890")
+	ctx = context.WithValue(ctx, contextkeys.UserIDContextKey, "TESTING")
+
+	repo, acc := connectTestRepository(ctx, t)
+	reversalManager := NewMySQLReversalManager(repo, acc.GetJournalManager(), acc.GetUniqueIDGenerator())
+
+	customer, err := acc.CreateNewAccount(ctx, "", "Customer", "Customer wallet", "2.1", "GOLD", acccore.CREDIT, "aCreator")
+	if err != nil {
+		t.Error(err.Error())
+		t.FailNow()
+	}
+	merchant, err := acc.CreateNewAccount(ctx, "", "Merchant", "Merchant wallet", "2.2", "GOLD", acccore.CREDIT, "aCreator")
+	if err != nil {
+		t.Error(err.Error())
+		t.FailNow()
+	}
+	cash, err := acc.CreateNewAccount(ctx, "", "Gold Cash", "Gold cash", "1.1", "GOLD", acccore.DEBIT, "aCreator")
+	if err != nil {
+		t.Error(err.Error())
+		t.FailNow()
+	}
+	topup := NewJournalFromRequest(&CreateJournalRequest{
+		Description: "topup", Creator: "aCreator",
+		Transactions: []*TransactionRequest{
+			{AccountNumber: cash.GetAccountNumber(), Description: "topup", Alignment: "DEBIT", Amount: 1000},
+			{AccountNumber: customer.GetAccountNumber(), Description: "topup", Alignment: "CREDIT", Amount: 1000},
+		},
+	}, acc.GetUniqueIDGenerator())
+	purchase := NewJournalFromRequest(&CreateJournalRequest{
+		Description: "purchase", Creator: "aCreator",
+		Transactions: []*TransactionRequest{
+			{AccountNumber: customer.GetAccountNumber(), Description: "purchase", Alignment: "DEBIT", Amount: 600},
+			{AccountNumber: merchant.GetAccountNumber(), Description: "purchase", Alignment: "CREDIT", Amount: 600},
+		},
+	}, acc.GetUniqueIDGenerator())
+	for _, journal := range []acccore.Journal{topup, purchase} {
+		if err := acc.GetJournalManager().PersistJournal(ctx, journal); err != nil {
+			t.Error(err.Error())
+			t.FailNow()
+		}
+	}
+
+	refund := []*ReversalLine{
+		{AccountNumber: customer.GetAccountNumber(), Amount: 250},
+		{AccountNumber: merchant.GetAccountNumber(), Amount: 250},
+	}
+	if _, err := reversalManager.ReverseJournal(ctx, purchase.GetJournalID(), refund, "refund", "aCreator"); err != nil {
+		t.Error(err.Error())
+		t.FailNow()
+	}
+	if _, err := reversalManager.ReverseJournal(ctx, purchase.GetJournalID(), refund, "refund", "aCreator"); err != nil {
+		t.Error(err.Error())
+		t.FailNow()
+	}
+	if _, err := reversalManager.ReverseJournal(ctx, purchase.GetJournalID(), refund, "refund", "aCreator"); !errors.Is(err, ErrReversalExceedsOriginal) {
+		t.Errorf("expecting ErrReversalExceedsOriginal, got %v", err)
+	}
+	if _, err := reversalManager.ReverseJournal(ctx, purchase.GetJournalID(), []*ReversalLine{{AccountNumber: cash.GetAccountNumber(), Amount: 1}}, "refund", "aCreator"); !errors.Is(err, ErrInvalidReversal) {
+		t.Errorf("expecting ErrInvalidReversal, got %v", err)
+	}
+
+	summary, err := reversalManager.GetReversalSummary(ctx, purchase.GetJournalID())
+	if err != nil {
+		t.Error(err.Error())
+		t.FailNow()
+	}
+	if summary.ReversedAmount != 500 || summary.FullyReversed || len(summary.ReversalIDs) != 2 {
+		t.Errorf("expecting 500 reversed by 2 journals, got %d by %d", summary.ReversedAmount, len(summary.ReversalIDs))
+	}
+
+	// reversing without lines reverses what is left
+	if _, err := reversalManager.ReverseJournal(ctx, purchase.GetJournalID(), nil, "refund the rest", "aCreator"); err != nil {
+		t.Error(err.Error())
+		t.FailNow()
+	}
+	if account, _ := acc.GetAccountManager().GetAccountByID(ctx, customer.GetAccountNumber()); account.GetBalance() != 1000 {
+		t.Errorf("expecting customer fully refunded, got balance %d", account.GetBalance())
+	}
+	if _, err := reversalManager.ReverseJournal(ctx, purchase.GetJournalID(), nil, "refund again", "aCreator"); !errors.Is(err, acccore.ErrJournalCanNotDoubleReverse) {
+		t.Errorf("expecting ErrJournalCanNotDoubleReverse, got %v", err)
+	}
+}
+
+func TestAccounting_ConcurrentReversal(t *testing.T) {
+	if testing.Short() {
+		t.Skip("partial reversal is only implemented by the MySQL managers")
+	}
+	ctx := context.WithValue(context.Background(), contextkeys.XRequestID, "1234567890")
+	ctx = context.WithValue(ctx, contextkeys.UserIDContextKey, "TESTING")
+
+	repo, acc := connectTestRepository(ctx, t)
+	reversalManager := NewMySQLReversalManager(repo, acc.GetJournalManager(), acc.GetUniqueIDGenerator())
+
+	customer, err := acc.CreateNewAccount(ctx, "", "Customer", "Customer wallet", "2.1", "GOLD", acccore.CREDIT, "aCreator")
+	if err != nil {
+		t.Error(err.Error())
+		t.FailNow()
+	}
+	cash, err := acc.CreateNewAccount(ctx, "", "Gold Cash", "Gold cash", "1.1", "GOLD", acccore.DEBIT, "aCreator")
+	if err != nil {
+		t.Error(err.Error())
+		t.FailNow()
+	}
+	topup, err := acc.CreateNewJournal(ctx, "topup", []acccore.TransactionInfo{
+		{AccountNumber: cash.GetAccountNumber(), Description: "topup", TxType: acccore.DEBIT, Amount: 1000},
+		{AccountNumber: customer.GetAccountNumber(), Description: "topup", TxType: acccore.CREDIT, Amount: 1000},
+	}, "aCreator")
+	if err != nil {
+		t.Error(err.Error())
+		t.FailNow()
+	}
+
+	// 5 concurrent reversals of 400 out of 1000, only 2 of them fit.
+	var wg sync.WaitGroup
+	var reversed int32
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := reversalManager.ReverseJournal(ctx, topup.GetJournalID(), []*ReversalLine{
+				{AccountNumber: cash.GetAccountNumber(), Amount: 400},
+				{AccountNumber: customer.GetAccountNumber(), Amount: 400},
+			}, "refund", "aCreator")
+			if err == nil {
+				atomic.AddInt32(&reversed, 1)
+			} else if !errors.Is(err, ErrReversalExceedsOriginal) {
+				t.Errorf("expecting ErrReversalExceedsOriginal, got %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if reversed != 2 {
+		t.Errorf("expecting 2 reversals to succeed, got %d", reversed)
+	}
+	if account, _ := acc.GetAccountManager().GetAccountByID(ctx, customer.GetAccountNumber()); account.GetBalance() != 200 {
+		t.Errorf("expecting customer balance 200, got %d", account.GetBalance())
+	}
+}
+
+func TestWebhookBackoff(t *testing.T) {
+	testData := []struct {
+		attempts int
+		expect   time.Duration
+	}{
+		{0, 30 * time.Second},
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{3, 2 * time.Minute},
+		{8, 64 * time.Minute},
+		{100, webhookMaxBackoff},
+	}
+	for _, td := range testData {
+		if got := webhookBackoff(30*time.Second, td.attempts); got != td.expect {
+			t.Errorf("attempts %d : expecting %s, got %s", td.attempts, td.expect, got)
+		}
+	}
+	if !subscribesTo("journal.posted,balance.low", EventBalanceLow) || subscribesTo("journal.posted", EventAccountCreated) || !subscribesTo("*", EventAccountCreated) {
+		t.Errorf("unexpected event type subscription matching")
+	}
+	if err := validateWebhookSubscription(&WebhookSubscription{URL: "ftp://example.com", EventTypes: []string{"*"}}); !errors.Is(err, ErrInvalidWebhookSubscription) {
+		t.Errorf("expecting ErrInvalidWebhookSubscription for non http url, got %v", err)
+	}
+	if err := validateWebhookSubscription(&WebhookSubscription{URL: "https://example.com/hook", EventTypes: []string{"journal.deleted"}}); !errors.Is(err, ErrInvalidWebhookSubscription) {
+		t.Errorf("expecting ErrInvalidWebhookSubscription for unknown event type, got %v", err)
+	}
+	if err := validateWebhookSubscription(&WebhookSubscription{URL: "https://example.com/hook", EventTypes: []string{EventJournalPosted}}); err != nil {
+		t.Errorf("expecting valid subscription, got %v", err)
+	}
+}
+
+func TestAccounting_Webhooks(t *testing.T) {
+	if testing.Short() {
+		t.Skip("webhooks are only implemented by the MySQL managers")
+	}
+	ctx := context.WithValue(context.Background(), contextkeys.XRequestID, "1234567890")
+	ctx = context.WithValue(ctx, contextkeys.UserIDContextKey, "TESTING")
+
+	repo, acc := connectTestRepository(ctx, t)
+
+	var failing int32 = 1
+	var secret string
+	received := make(chan *WebhookEvent, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&failing) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		if !middlewares.ValidatePayloadSignature(r.Header.Get(WebhookSignatureHeader), body, secret, time.Minute) {
+			t.Errorf("expecting delivery %s to be signed", r.Header.Get(WebhookDeliveryHeader))
+		}
+		event := &WebhookEvent{}
+		if err := json.Unmarshal(body, event); err != nil {
+			t.Error(err.Error())
+		}
+		received <- event
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	webhookManager := NewMySQLWebhookManager(repo, acc.GetUniqueIDGenerator(), srv.Client(), 2, 0)
+	subscription, err := webhookManager.CreateSubscription(ctx, &WebhookSubscription{
+		URL:        srv.URL,
+		EventTypes: []string{EventJournalPosted, EventBalanceLow},
+	}, "aCreator")
+	if err != nil {
+		t.Error(err.Error())
+		t.FailNow()
+	}
+	secret = subscription.Secret
+
+	customer, err := acc.CreateNewAccount(ctx, "", "Customer", "Customer wallet", "2.1", "GOLD", acccore.CREDIT, "aCreator")
+	if err != nil {
+		t.Error(err.Error())
+		t.FailNow()
+	}
+	merchant, err := acc.CreateNewAccount(ctx, "", "Merchant", "Merchant wallet", "2.2", "GOLD", acccore.CREDIT, "aCreator")
+	if err != nil {
+		t.Error(err.Error())
+		t.FailNow()
+	}
+	// the customer balance goes below zero, thus the balance is low
+	purchase := NewJournalFromRequest(&CreateJournalRequest{
+		Description: "purchase", Creator: "aCreator",
+		Transactions: []*TransactionRequest{
+			{AccountNumber: customer.GetAccountNumber(), Description: "purchase", Alignment: "DEBIT", Amount: 100},
+			{AccountNumber: merchant.GetAccountNumber(), Description: "purchase", Alignment: "CREDIT", Amount: 100},
+		},
+	}, acc.GetUniqueIDGenerator())
+	if err := acc.GetJournalManager().PersistJournal(ctx, purchase); err != nil {
+		t.Error(err.Error())
+		t.FailNow()
+	}
+
+	// 2 account.created, 1 journal.posted and 1 balance.low
+	if count, err := webhookManager.DispatchEvents(ctx); err != nil || count != 4 {
+		t.Errorf("expecting 4 events dispatched, got %d %v", count, err)
+	}
+	if count, err := webhookManager.DispatchEvents(ctx); err != nil || count != 0 {
+		t.Errorf("expecting no more events to dispatch, got %d %v", count, err)
+	}
+
+	// the subscriber is down, deliveries are retried until they run out of attempts
+	for i := 0; i < 2; i++ {
+		if count, err := webhookManager.DeliverDue(ctx); err != nil || count != 0 {
+			t.Errorf("expecting no delivery to succeed, got %d %v", count, err)
+		}
+	}
+	pr, dead, err := webhookManager.ListDeliveries(ctx, subscription.SubscriptionID, connector.WebhookDeliveryStatusDead, acccore.PageRequest{PageNo: 1, ItemSize: 10})
+	if err != nil || pr.TotalEntries != 2 || len(dead) != 2 {
+		t.Errorf("expecting 2 dead deliveries, got %d %v", pr.TotalEntries, err)
+		t.FailNow()
+	}
+	if dead[0].Attempts != 2 || dead[0].LastResponseCode != http.StatusServiceUnavailable {
+		t.Errorf("expecting dead delivery after 2 attempts of 503, got %d attempts of %d", dead[0].Attempts, dead[0].LastResponseCode)
+	}
+
+	// the subscriber is back, replay the dead letters
+	atomic.StoreInt32(&failing, 0)
+	if count, err := webhookManager.ReplayDeadDeliveries(ctx, subscription.SubscriptionID); err != nil || count != 2 {
+		t.Errorf("expecting 2 dead deliveries replayed, got %d %v", count, err)
+	}
+	if count, err := webhookManager.DeliverDue(ctx); err != nil || count != 2 {
+		t.Errorf("expecting 2 deliveries to succeed, got %d %v", count, err)
+	}
+	close(received)
+	eventTypes := make(map[string]bool)
+	for event := range received {
+		eventTypes[event.EventType] = true
+	}
+	if !eventTypes[EventJournalPosted] || !eventTypes[EventBalanceLow] {
+		t.Errorf("expecting journal.posted and balance.low events, got %v", eventTypes)
+	}
+
+	if err := webhookManager.ReplayDelivery(ctx, dead[0].DeliveryID); err != nil {
+		t.Errorf("expecting delivered delivery to be replayed, got %v", err)
+	}
+	if err := webhookManager.ReplayDelivery(ctx, dead[0].DeliveryID); !errors.Is(err, ErrWebhookDeliveryPending) {
+		t.Errorf("expecting ErrWebhookDeliveryPending, got %v", err)
+	}
+}
+
+func TestAccounting_LedgerFeed(t *testing.T) {
+	if testing.Short() {
+		t.Skip("ledger feed is only implemented by the MySQL managers")
+	}
+	ctx := context.WithValue(context.Background(), contextkeys.XRequestID, "1234567890")
+	ctx = context.WithValue(ctx, contextkeys.UserIDContextKey, "TESTING")
+
+	repo, acc := connectTestRepository(ctx, t)
+	feedManager := NewMySQLLedgerFeedManager(repo)
+	simulationManager := NewMySQLJournalSimulationManager(repo)
+
+	cash, err := acc.CreateNewAccount(ctx, "", "Gold Cash", "Gold cash", "1.1", "GOLD", acccore.DEBIT, "aCreator")
+	if err != nil {
+		t.Error(err.Error())
+		t.FailNow()
+	}
+	equity, err := acc.CreateNewAccount(ctx, "", "Gold Equity", "Gold equity", "3.1", "GOLD", acccore.CREDIT, "aCreator")
+	if err != nil {
+		t.Error(err.Error())
+		t.FailNow()
+	}
+	journal := func(amount int64) acccore.Journal {
+		return NewJournalFromRequest(&CreateJournalRequest{
+			Description: "feed",
+			Creator:     "aCreator",
+			Transactions: []*TransactionRequest{
+				{AccountNumber: cash.GetAccountNumber(), Description: "feed", Alignment: "DEBIT", Amount: amount},
+				{AccountNumber: equity.GetAccountNumber(), Description: "feed", Alignment: "CREDIT", Amount: amount},
+			},
+		}, acc.GetUniqueIDGenerator())
+	}
+	for i := int64(1); i <= 3; i++ {
+		if err := acc.GetJournalManager().PersistJournal(ctx, journal(i*100)); err != nil {
+			t.Error(err.Error())
+			t.FailNow()
+		}
+		// simulated journals do not take a number, so they leave no gap in the sequence
+		if _, err := simulationManager.SimulateJournal(ctx, journal(i)); err != nil {
+			t.Error(err.Error())
+			t.FailNow()
+		}
+	}
+
+	feed, err := feedManager.ListFeed(ctx, 0, 2)
+	if err != nil {
+		t.Error(err.Error())
+		t.FailNow()
+	}
+	if len(feed.Journals) != 2 || !feed.HasMore || feed.LastSequence != 2 {
+		t.Errorf("expecting first 2 journals with more to come, got %d journals up to %d, has more %v", len(feed.Journals), feed.LastSequence, feed.HasMore)
+	}
+	for i, j := range feed.Journals {
+		if j.Sequence != int64(i+1) || j.Amount != int64(i+1)*100 || len(j.Transactions) != 2 {
+			t.Errorf("expecting journal %d of amount %d with 2 transactions, got journal %d of amount %d with %d transactions", i+1, (i+1)*100, j.Sequence, j.Amount, len(j.Transactions))
+		}
+	}
+	feed, err = feedManager.ListFeed(ctx, feed.LastSequence, 2)
+	if err != nil {
+		t.Error(err.Error())
+		t.FailNow()
+	}
+	if len(feed.Journals) != 1 || feed.HasMore || feed.LastSequence != 3 || feed.Journals[0].Amount != 300 {
+		t.Errorf("expecting the last journal, got %d journals up to %d, has more %v", len(feed.Journals), feed.LastSequence, feed.HasMore)
+	}
+	feed, err = feedManager.ListFeed(ctx, feed.LastSequence, 2)
+	if err != nil || len(feed.Journals) != 0 || feed.LastSequence != 3 {
+		t.Errorf("expecting no new journal after 3, got %v %v", feed, err)
+	}
+}
+
+func TestAccounting_Metadata(t *testing.T) {
+	if testing.Short() {
+		t.Skip("metadata is only implemented by the MySQL managers")
+	}
+	ctx := context.WithValue(context.Background(), contextkeys.XRequestID, "1234567890")
+	ctx = context.WithValue(ctx, contextkeys.UserIDContextKey, "TESTING")
+
+	repo, acc := connectTestRepository(ctx, t)
+	metadataManager := NewMySQLMetadataManager(repo, acc.GetJournalManager())
+
+	cash, err := acc.CreateNewAccount(ctx, "", "Gold Cash", "Gold cash", "1.1", "GOLD", acccore.DEBIT, "aCreator")
+	if err != nil {
+		t.Error(err.Error())
+		t.FailNow()
+	}
+	equity, err := acc.CreateNewAccount(ctx, "", "Gold Equity", "Gold equity", "3.1", "GOLD", acccore.CREDIT, "aCreator")
+	if err != nil {
+		t.Error(err.Error())
+		t.FailNow()
+	}
+	journal := func(orderID string, metadata Metadata) *MetadataJournal {
+		return NewJournalFromRequest(&CreateJournalRequest{
+			Description: "order",
+			Creator:     "aCreator",
+			Metadata:    Metadata{"order_id": orderID},
+			Transactions: []*TransactionRequest{
+				{AccountNumber: cash.GetAccountNumber(), Description: "order", Alignment: "DEBIT", Amount: 100, Metadata: metadata},
+				{AccountNumber: equity.GetAccountNumber(), Description: "order", Alignment: "CREDIT", Amount: 100},
+			},
+		}, acc.GetUniqueIDGenerator())
+	}
+	from := time.Now().Add(-time.Minute)
+	first := journal("ORD-1", Metadata{"channel": "web"})
+	if err := acc.GetJournalManager().PersistJournal(ctx, first); err != nil {
+		t.Error(err.Error())
+		t.FailNow()
+	}
+	if err := acc.GetJournalManager().PersistJournal(ctx, journal("ORD-2", Metadata{"channel": "pos"})); err != nil {
+		t.Error(err.Error())
+		t.FailNow()
+	}
+	if err := acc.GetJournalManager().PersistJournal(ctx, journal("ORD-3", Metadata{"bad key": "x"})); !errors.Is(err, ErrInvalidMetadata) {
+		t.Errorf("expecting ErrInvalidMetadata but %v", err)
+	}
+	until := time.Now().Add(time.Minute)
+
+	loaded, err := acc.GetJournalManager().GetJournalByID(ctx, first.JournalID)
+	if err != nil {
+		t.Error(err.Error())
+		t.FailNow()
+	}
+	if metadataOf(loaded)["order_id"] != "ORD-1" {
+		t.Errorf("expecting the journal metadata persisted, got %v", metadataOf(loaded))
+	}
+	trx, err := acc.GetTransactionManager().GetTransactionByID(ctx, first.GetTransactions()[0].GetTransactionID())
+	if err != nil {
+		t.Error(err.Error())
+		t.FailNow()
+	}
+	if metadataOf(trx)["channel"] != "web" {
+		t.Errorf("expecting the transaction metadata persisted, got %v", metadataOf(trx))
+	}
+
+	_, journals, err := metadataManager.ListJournalsByMetadata(ctx, from, until, Metadata{"order_id": "ORD-2"}, acccore.PageRequest{PageNo: 1, ItemSize: 10})
+	if err != nil || len(journals) != 1 || metadataOf(journals[0])["order_id"] != "ORD-2" {
+		t.Errorf("expecting the journal of ORD-2, got %d journals %v", len(journals), err)
+	}
+	_, journals, err = metadataManager.ListJournalsByMetadata(ctx, from, until, Metadata{"order_id": ""}, acccore.PageRequest{PageNo: 1, ItemSize: 10})
+	if err != nil || len(journals) != 2 {
+		t.Errorf("expecting 2 journals having an order id, got %d journals %v", len(journals), err)
+	}
+	_, transactions, err := metadataManager.ListTransactionsOnAccountByMetadata(ctx, from, until, cash.GetAccountNumber(), Metadata{"channel": "web"}, acccore.PageRequest{PageNo: 1, ItemSize: 10})
+	if err != nil || len(transactions) != 1 || transactions[0].GetJournalID() != first.JournalID {
+		t.Errorf("expecting the web transaction, got %d transactions %v", len(transactions), err)
+	}
+	_, transactions, err = metadataManager.ListTransactionsOnAccountByMetadata(ctx, from, until, equity.GetAccountNumber(), Metadata{"channel": ""}, acccore.PageRequest{PageNo: 1, ItemSize: 10})
+	if err != nil || len(transactions) != 0 {
+		t.Errorf("expecting no equity transaction having a channel, got %d transactions %v", len(transactions), err)
+	}
+}
+
+func TestAccounting_AuditLogs(t *testing.T) {
+	if testing.Short() {
+		t.Skip("audit log is only implemented by the MySQL managers")
+	}
+	ctx := context.WithValue(context.Background(), contextkeys.XRequestID, "1234567890")
+	ctx = context.WithValue(ctx, contextkeys.UserIDContextKey, "TESTING")
+
+	repo, _ := connectTestRepository(ctx, t)
+	auditManager := NewMySQLAuditManager(repo)
+
+	records := []*middlewares.AuditRecord{
+		{Principal: "alice", RequestID: "R1", Method: "PUT", Endpoint: "/api/v1/exchange/denom", Path: "/api/v1/exchange/denom",
+			PayloadDigest: strings.Repeat("a", 64), Before: "1", After: "2", StatusCode: 200, Outcome: connector.AuditOutcomeSuccess, CreatedAt: time.Now()},
+		{Principal: "bob", RequestID: "R2", Method: "PUT", Endpoint: "/api/v1/currencies/{code}", Path: "/api/v1/currencies/GOLD",
+			PayloadDigest: strings.Repeat("b", 64), Before: `{"code":"GOLD"}`, StatusCode: 404, Outcome: connector.AuditOutcomeFailure, CreatedAt: time.Now()},
+	}
+	for _, rec := range records {
+		if err := auditManager.RecordAudit(ctx, rec); err != nil {
+			t.Error(err.Error())
+			t.FailNow()
+		}
+	}
+
+	pr, logs, err := auditManager.ListAuditLogs(ctx, &AuditLogFilter{}, acccore.PageRequest{PageNo: 1, ItemSize: 10})
+	if err != nil {
+		t.Error(err.Error())
+		t.FailNow()
+	}
+	if pr.TotalEntries != 2 || len(logs) != 2 {
+		t.Errorf("expecting 2 audit logs but %d", pr.TotalEntries)
+		t.FailNow()
+	}
+	if logs[0].RequestID != "R2" {
+		t.Errorf("expecting latest audit log first but %s", logs[0].RequestID)
+	}
+
+	_, logs, err = auditManager.ListAuditLogs(ctx, &AuditLogFilter{Principal: "alice", Outcome: connector.AuditOutcomeSuccess}, acccore.PageRequest{PageNo: 1, ItemSize: 10})
+	if err != nil {
+		t.Error(err.Error())
+		t.FailNow()
+	}
+	if len(logs) != 1 || logs[0].RequestID != "R1" {
+		t.Errorf("expecting the audit log of alice only but %d", len(logs))
+		t.FailNow()
+	}
+	if string(logs[0].Before) != "1" || string(logs[0].After) != "2" {
+		t.Errorf("unexpected snapshots before %s after %s", logs[0].Before, logs[0].After)
+	}
+
+	_, logs, err = auditManager.ListAuditLogs(ctx, &AuditLogFilter{Endpoint: "/api/v1/currencies/{code}", From: time.Now().Add(time.Hour)}, acccore.PageRequest{PageNo: 1, ItemSize: 10})
+	if err != nil {
+		t.Error(err.Error())
+		t.FailNow()
+	}
+	if len(logs) != 0 {
+		t.Errorf("expecting no audit log in the future but %d", len(logs))
+	}
+}
+
+func TestRequestCreator(t *testing.T) {
+	req := httptest.NewRequest("POST", "/api/v1/journals", nil)
+	if creator := requestCreator(req, "claimed"); creator != "claimed" {
+		t.Errorf("expecting the claimed creator without api key but %s", creator)
+	}
+	hmacReq := req.WithContext(context.WithValue(req.Context(), contextkeys.UserIDContextKey, middlewares.HMACPrincipal))
+	if creator := requestCreator(hmacReq, "claimed"); creator != middlewares.HMACPrincipal {
+		t.Errorf("expecting the shared secret principal but %s", creator)
+	}
+	req = req.WithContext(context.WithValue(req.Context(), contextkeys.ClientContextKey, &middlewares.Client{ClientID: "acme", KeyID: "K1"}))
+	if creator := requestCreator(req, "claimed"); creator != "acme" {
+		t.Errorf("expecting the client of the api key but %s", creator)
+	}
+}
+
+func TestAccounting_APIKeys(t *testing.T) {
+	if testing.Short() {
+		t.Skip("api keys are only implemented by the MySQL managers")
+	}
+	ctx := context.WithValue(context.Background(), contextkeys.XRequestID, "1234567890")
+	ctx = context.WithValue(ctx, contextkeys.UserIDContextKey, "TESTING")
+
+	repo, acc := connectTestRepository(ctx, t)
+	keyManager := NewMySQLAPIKeyManager(repo, acc.GetUniqueIDGenerator(), "seal secret")
+
+	if _, err := keyManager.IssueKey(ctx, &APIKey{ClientID: "not a client id"}, "admin"); !errors.Is(err, ErrInvalidAPIKey) {
+		t.Errorf("expecting ErrInvalidAPIKey but %v", err)
+	}
+	past := time.Now().Add(-time.Minute)
+	if _, err := keyManager.IssueKey(ctx, &APIKey{ClientID: "acme", ExpiresAt: &past}, "admin"); !errors.Is(err, ErrInvalidAPIKey) {
+		t.Errorf("expecting ErrInvalidAPIKey for an expired key but %v", err)
+	}
+
+	key, err := keyManager.IssueKey(ctx, &APIKey{ClientID: "acme", Scopes: []string{"ledger:read", "journal:write"}, Description: "acme backend"}, "admin")
+	if err != nil {
+		t.Error(err.Error())
+		t.FailNow()
+	}
+	keyID, secret, ok := middlewares.ParseAPIKey(middlewares.APIKeyScheme + " " + key.APIKey)
+	if !ok || keyID != key.KeyID {
+		t.Errorf("expecting the issued key to be parseable but %s", key.APIKey)
+		t.FailNow()
+	}
+	stored, err := keyManager.GetKey(ctx, key.KeyID)
+	if err != nil {
+		t.Error(err.Error())
+		t.FailNow()
+	}
+	if len(stored.APIKey) != 0 || len(stored.Scopes) != 2 || stored.CreateBy != "admin" {
+		t.Errorf("unexpected stored key %+v", stored)
+	}
+
+	client, err := keyManager.AuthenticateAPIKey(ctx, keyID, secret)
+	if err != nil || client == nil || client.ClientID != "acme" {
+		t.Errorf("expecting the key to authenticate acme but %v %v", client, err)
+	}
+	if client, _ := keyManager.AuthenticateAPIKey(ctx, keyID, secret+"x"); client != nil {
+		t.Errorf("expecting wrong secret to be refused")
+	}
+	client, signingKey, err := keyManager.APIKeySigningKey(ctx, keyID)
+	if err != nil || client == nil || signingKey != middlewares.SigningKey(secret) {
+		t.Errorf("expecting the signing key of the secret but %v %v", client, err)
+	}
+	if rec, _ := repo.GetAPIKey(ctx, keyID); rec == nil || rec.SigningKey == signingKey || rec.KeyHash == signingKey {
+		t.Errorf("expecting the signing key not stored as is")
+	}
+	if _, _, err := NewMySQLAPIKeyManager(repo, acc.GetUniqueIDGenerator(), "another secret").APIKeySigningKey(ctx, keyID); err == nil {
+		t.Errorf("expecting the signing key not opened by another seal secret")
+	}
+
+	rotated, err := keyManager.RotateKey(ctx, key.KeyID, nil, "admin")
+	if err != nil {
+		t.Error(err.Error())
+		t.FailNow()
+	}
+	if client, _ := keyManager.AuthenticateAPIKey(ctx, keyID, secret); client != nil {
+		t.Errorf("expecting the rotated key to be refused")
+	}
+	newKeyID, newSecret, _ := middlewares.ParseAPIKey(middlewares.APIKeyScheme + " " + rotated.APIKey)
+	if client, _ := keyManager.AuthenticateAPIKey(ctx, newKeyID, newSecret); client == nil || client.ClientID != "acme" || len(client.Scopes) != 2 {
+		t.Errorf("expecting the new key to authenticate acme with the same scopes but %v", client)
+	}
+	old, _ := keyManager.GetKey(ctx, key.KeyID)
+	if !old.Revoked || old.ReplacedBy != rotated.KeyID {
+		t.Errorf("expecting the old key to be replaced by %s but %+v", rotated.KeyID, old)
+	}
+	if _, err := keyManager.RotateKey(ctx, key.KeyID, nil, "admin"); !errors.Is(err, ErrAPIKeyRevoked) {
+		t.Errorf("expecting ErrAPIKeyRevoked but %v", err)
+	}
+
+	if err := keyManager.RevokeKey(ctx, rotated.KeyID, "admin"); err != nil {
+		t.Error(err.Error())
+	}
+	if client, _ := keyManager.AuthenticateAPIKey(ctx, newKeyID, newSecret); client != nil {
+		t.Errorf("expecting the revoked key to be refused")
+	}
+	if client, err := keyManager.APIKeyClient(ctx, newKeyID); client != nil || err != nil {
+		t.Errorf("expecting the revoked key to be no longer valid but %v %v", client, err)
+	}
+	if err := keyManager.RevokeKey(ctx, "NOTEXIST", "admin"); !errors.Is(err, ErrAPIKeyNotFound) {
+		t.Errorf("expecting ErrAPIKeyNotFound but %v", err)
+	}
+	keys, err := keyManager.ListKeys(ctx, "acme")
+	if err != nil || len(keys) != 2 {
+		t.Errorf("expecting 2 keys of acme but %d %v", len(keys), err)
+	}
+}
+
+func TestSealSigningKey(t *testing.T) {
+	km := NewMySQLAPIKeyManager(nil, nil, "seal secret").(*MySQLAPIKeyManager)
+	signingKey := middlewares.SigningKey("secret")
+	if signingKey == hashAPIKeySecret("secret") {
+		t.Errorf("expecting the signing key to differ from the hash of the secret")
+	}
+	sealed, err := km.sealSigningKey(signingKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sealed) > 128 {
+		t.Errorf("expecting the sealed key to fit the signing_key column, got %d characters", len(sealed))
+	}
+	if opened, err := km.openSigningKey(sealed); err != nil || opened != signingKey {
+		t.Errorf("expecting the signing key opened, got %q %v", opened, err)
+	}
+	other := NewMySQLAPIKeyManager(nil, nil, "another secret").(*MySQLAPIKeyManager)
+	if _, err := other.openSigningKey(sealed); !errors.Is(err, errSigningKeySeal) {
+		t.Errorf("expecting errSigningKeySeal, got %v", err)
+	}
+}
+
+func TestAccounting_Tenants(t *testing.T) {
+	if testing.Short() {
+		t.Skip("tenants are only implemented by the MySQL managers")
+	}
+	ctx := context.WithValue(context.Background(), contextkeys.XRequestID, "1234567890")
+	ctx = context.WithValue(ctx, contextkeys.UserIDContextKey, "TESTING")
+
+	repo, acc := connectTestRepository(ctx, t)
+	tenantManager := NewMySQLTenantManager(repo)
+
+	if _, err := tenantManager.CreateTenant(ctx, &Tenant{TenantID: "not a tenant", Name: "Wallet"}, "admin"); !errors.Is(err, ErrInvalidTenant) {
+		t.Errorf("expecting ErrInvalidTenant but %v", err)
+	}
+	tenant, err := tenantManager.CreateTenant(ctx, &Tenant{TenantID: "wallet", Name: "Wallet"}, "admin")
+	if err != nil {
+		t.Error(err.Error())
+		t.FailNow()
+	}
+	if tenant.CreateBy != "admin" {
+		t.Errorf("expecting the tenant created by admin but %s", tenant.CreateBy)
+	}
+	if _, err := tenantManager.CreateTenant(ctx, &Tenant{TenantID: "wallet", Name: "Wallet"}, "admin"); !errors.Is(err, ErrTenantAlreadyExist) {
+		t.Errorf("expecting ErrTenantAlreadyExist but %v", err)
+	}
+	if _, err := tenantManager.GetTenant(ctx, "loyalty"); !errors.Is(err, ErrTenantNotFound) {
+		t.Errorf("expecting ErrTenantNotFound but %v", err)
+	}
+
+	// each tenant has its own currencies
+	walletCtx := connector.WithTenant(ctx, "wallet")
+	if _, err := NewMySQLExchangeManager(repo).CreateCurrency(walletCtx, "GOLD", "Gold Bullion", big.NewFloat(1.0), "TESTING"); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	walletReserve, err := acc.CreateNewAccount(walletCtx, "", "Gold Reserve", "Gold reserve", "1.1", "GOLD", acccore.DEBIT, "aCreator")
+	if err != nil {
+		t.Error(err.Error())
+		t.FailNow()
+	}
+	walletCustomer, err := acc.CreateNewAccount(walletCtx, "", "Gold Wallet", "Customer gold wallet", "2.1", "GOLD", acccore.CREDIT, "aCreator")
+	if err != nil {
+		t.Error(err.Error())
+		t.FailNow()
+	}
+	reserve, err := acc.CreateNewAccount(ctx, "", "Gold Reserve", "Gold reserve", "1.1", "GOLD", acccore.DEBIT, "aCreator")
+	if err != nil {
+		t.Error(err.Error())
+		t.FailNow()
+	}
+
+	// the books of the other tenants are not visible
+	if account, _ := acc.GetAccountManager().GetAccountByID(ctx, walletCustomer.GetAccountNumber()); account != nil {
+		t.Errorf("expecting the account of the wallet tenant not found in the default tenant")
+	}
+	if _, err := acc.CreateNewJournal(ctx, "topup", []acccore.TransactionInfo{
+		{AccountNumber: reserve.GetAccountNumber(), Description: "topup", TxType: acccore.DEBIT, Amount: 1000},
+		{AccountNumber: walletCustomer.GetAccountNumber(), Description: "topup", TxType: acccore.CREDIT, Amount: 1000},
+	}, "aCreator"); err == nil {
+		t.Errorf("expecting the cross tenant posting refused")
+	}
+	if _, err := acc.CreateNewJournal(walletCtx, "topup", []acccore.TransactionInfo{
+		{AccountNumber: walletReserve.GetAccountNumber(), Description: "topup", TxType: acccore.DEBIT, Amount: 1000},
+		{AccountNumber: walletCustomer.GetAccountNumber(), Description: "topup", TxType: acccore.CREDIT, Amount: 1000},
+	}, "aCreator"); err != nil {
+		t.Error(err.Error())
+	}
+
+	// the api keys are issued for a tenant
+	keyManager := NewMySQLAPIKeyManager(repo, acc.GetUniqueIDGenerator(), "seal secret")
+	if _, err := keyManager.IssueKey(ctx, &APIKey{ClientID: "acme", TenantID: "loyalty", Scopes: []string{"ledger:read"}}, "admin"); !errors.Is(err, ErrTenantNotFound) {
+		t.Errorf("expecting ErrTenantNotFound but %v", err)
+	}
+	key, err := keyManager.IssueKey(ctx, &APIKey{ClientID: "acme", TenantID: "wallet", Scopes: []string{"ledger:read"}}, "admin")
+	if err != nil {
+		t.Error(err.Error())
+		t.FailNow()
+	}
+	keyID, secret, _ := middlewares.ParseAPIKey(middlewares.APIKeyScheme + " " + key.APIKey)
+	if client, _ := keyManager.AuthenticateAPIKey(ctx, keyID, secret); client == nil || client.TenantID != "wallet" {
+		t.Errorf("expecting the key to authenticate a client of the wallet tenant but %v", client)
+	}
+	if _, err := keyManager.GetKey(ctx, key.KeyID); !errors.Is(err, ErrAPIKeyNotFound) {
+		t.Errorf("expecting the key of the wallet tenant not found in the default tenant but %v", err)
+	}
+}
+
+func TestAccounting_ExternalReference(t *testing.T) {
+	if testing.Short() {
+		t.Skip("external references are only implemented by the MySQL managers")
+	}
+	ctx := context.WithValue(context.Background(), contextkeys.XRequestID, "1234567890")
+	ctx = context.WithValue(ctx, contextkeys.UserIDContextKey, "TESTING")
+
+	repo, acc := connectTestRepository(ctx, t)
+	referenceManager := NewMySQLReferenceManager(repo, acc.GetJournalManager())
+	reference := "REF-" + acc.GetUniqueIDGenerator().NewUniqueID()
+
+	account := func() *ReferencedAccount {
+		ret := &ReferencedAccount{ExternalReference: reference}
+		ret.SetAccountNumber(acc.GetUniqueIDGenerator().NewUniqueID()).SetName("Gold Cash").SetDescription("Gold cash").
+			SetCOA("1.1").SetCurrency("GOLD").SetAlignment(acccore.DEBIT).SetCreateBy("aCreator").SetUpdateBy("aCreator")
+		return ret
+	}
+	cash := account()
+	if err := acc.GetAccountManager().PersistAccount(ctx, cash); err != nil {
+		t.Error(err.Error())
+		t.FailNow()
+	}
+	if err := acc.GetAccountManager().PersistAccount(ctx, account()); !errors.Is(err, ErrExternalReferenceTaken) {
+		t.Errorf("expecting ErrExternalReferenceTaken but %v", err)
+	}
+	equity, err := acc.CreateNewAccount(ctx, "", "Gold Equity", "Gold equity", "3.1", "GOLD", acccore.CREDIT, "aCreator")
+	if err != nil {
+		t.Error(err.Error())
+		t.FailNow()
+	}
+
+	journal := func() *MetadataJournal {
+		return NewJournalFromRequest(&CreateJournalRequest{
+			Description:       "order",
+			Creator:           "aCreator",
+			ExternalReference: reference,
+			Transactions: []*TransactionRequest{
+				{AccountNumber: cash.GetAccountNumber(), Description: "order", Alignment: "DEBIT", Amount: 100},
+				{AccountNumber: equity.GetAccountNumber(), Description: "order", Alignment: "CREDIT", Amount: 100},
+			},
+		}, acc.GetUniqueIDGenerator())
+	}
+	first := journal()
+	if err := acc.GetJournalManager().PersistJournal(ctx, first); err != nil {
+		t.Error(err.Error())
+		t.FailNow()
+	}
+	if err := acc.GetJournalManager().PersistJournal(ctx, journal()); !errors.Is(err, ErrExternalReferenceTaken) {
+		t.Errorf("expecting ErrExternalReferenceTaken but %v", err)
+	}
+
+	// the records are created by the user in context
+	found, err := referenceManager.GetJournalByReference(ctx, "TESTING", reference)
+	if err != nil || found.GetJournalID() != first.JournalID || externalReferenceOf(found) != reference {
+		t.Errorf("expecting journal %s found by its reference, got %v", first.JournalID, err)
+	}
+	foundAccount, err := referenceManager.GetAccountByReference(ctx, "TESTING", reference)
+	if err != nil || foundAccount.GetAccountNumber() != cash.GetAccountNumber() || externalReferenceOf(foundAccount) != reference {
+		t.Errorf("expecting account %s found by its reference, got %v", cash.GetAccountNumber(), err)
+	}
+
+	// the same reference given by another creator is another record
+	otherCtx := context.WithValue(ctx, contextkeys.UserIDContextKey, "OTHER")
+	if _, err := referenceManager.GetJournalByReference(otherCtx, "OTHER", reference); !errors.Is(err, ErrExternalReferenceNotFound) {
+		t.Errorf("expecting ErrExternalReferenceNotFound but %v", err)
+	}
+	if err := acc.GetJournalManager().PersistJournal(otherCtx, journal()); err != nil {
+		t.Errorf("expecting the reference free for another creator, got %s", err.Error())
+	}
+}
+
+func TestAccounting_Search(t *testing.T) {
+	if testing.Short() {
+		t.Skip("search is only implemented by the MySQL managers")
+	}
+	ctx := context.WithValue(context.Background(), contextkeys.XRequestID, "1234567890")
+	ctx = context.WithValue(ctx, contextkeys.UserIDContextKey, "TESTING")
+
+	repo, acc := connectTestRepository(ctx, t)
+	searchManager := NewMySQLSearchManager(repo, acc.GetJournalManager())
+
+	cash, err := acc.CreateNewAccount(ctx, "", "Gold Cash", "Gold cash", "1.1", "GOLD", acccore.DEBIT, "aCreator")
+	if err != nil {
+		t.Error(err.Error())
+		t.FailNow()
+	}
+	equity, err := acc.CreateNewAccount(ctx, "", "Gold Equity", "Gold equity", "3.1", "GOLD", acccore.CREDIT, "aCreator")
+	if err != nil {
+		t.Error(err.Error())
+		t.FailNow()
+	}
+	for _, amount := range []int64{100, 250, 400} {
+		journal := NewJournalFromRequest(&CreateJournalRequest{
+			Description: "deposit 100%",
+			Creator:     "aCreator",
+			Transactions: []*TransactionRequest{
+				{AccountNumber: cash.GetAccountNumber(), Description: "deposit", Alignment: "DEBIT", Amount: amount},
+				{AccountNumber: equity.GetAccountNumber(), Description: "deposit", Alignment: "CREDIT", Amount: amount},
+			},
+		}, acc.GetUniqueIDGenerator())
+		if err := acc.GetJournalManager().PersistJournal(ctx, journal); err != nil {
+			t.Error(err.Error())
+			t.FailNow()
+		}
+	}
+
+	minAmount := int64(200)
+	pr, transactions, err := searchManager.SearchTransactions(ctx, &TransactionSearchFilter{
+		AccountNumbers: []string{cash.GetAccountNumber(), equity.GetAccountNumber()},
+		Alignment:      "debit",
+		MinAmount:      &minAmount,
+	}, acccore.PageRequest{PageNo: 1, ItemSize: 10, Sorts: []acccore.Sort{{Column: "amount", Ascending: false}}})
+	if err != nil {
+		t.Error(err.Error())
+		t.FailNow()
+	}
+	if pr.TotalEntries != 2 || len(transactions) != 2 || transactions[0].GetAmount() != 400 || transactions[1].GetAmount() != 250 {
+		t.Errorf("expecting the 400 and 250 debits, got %d entries", pr.TotalEntries)
+	}
+
+	reversal := false
+	pr, journals, err := searchManager.SearchJournals(ctx, &JournalSearchFilter{
+		AccountNumbers: []string{equity.GetAccountNumber()},
+		Reversal:       &reversal,
+		Description:    "100%",
+	}, acccore.PageRequest{PageNo: 1, ItemSize: 2})
+	if err != nil {
+		t.Error(err.Error())
+		t.FailNow()
+	}
+	if pr.TotalEntries != 3 || len(journals) != 2 {
+		t.Errorf("expecting the first 2 of 3 journals, got %d of %d", len(journals), pr.TotalEntries)
+	}
+
+	if _, _, err := searchManager.SearchJournals(ctx, nil, acccore.PageRequest{PageNo: 1, ItemSize: 2, Sorts: []acccore.Sort{{Column: "amount"}}}); !errors.Is(err, ErrInvalidSearch) {
+		t.Errorf("expecting ErrInvalidSearch but %v", err)
+	}
+}
+
+func TestAccounting_CursorPagination(t *testing.T) {
+	if testing.Short() {
+		t.Skip("cursor pagination is only implemented by the MySQL managers")
+	}
+	ctx := context.WithValue(context.Background(), contextkeys.XRequestID, "1234567890")
+	ctx = context.WithValue(ctx, contextkeys.UserIDContextKey, "TESTING")
+
+	repo, acc := connectTestRepository(ctx, t)
+	cursorManager := NewMySQLCursorManager(repo, acc.GetJournalManager())
+
+	cash, err := acc.CreateNewAccount(ctx, "", "Gold Cash", "Gold cash", "1.1", "GOLD", acccore.DEBIT, "aCreator")
+	if err != nil {
+		t.Error(err.Error())
+		t.FailNow()
+	}
+	equity, err := acc.CreateNewAccount(ctx, "", "Gold Equity", "Gold equity", "3.1", "GOLD", acccore.CREDIT, "aCreator")
+	if err != nil {
+		t.Error(err.Error())
+		t.FailNow()
+	}
+	post := func() {
+		journal := NewJournalFromRequest(&CreateJournalRequest{
+			Description: "deposit",
+			Creator:     "aCreator",
+			Transactions: []*TransactionRequest{
+				{AccountNumber: cash.GetAccountNumber(), Description: "deposit", Alignment: "DEBIT", Amount: 100},
+				{AccountNumber: equity.GetAccountNumber(), Description: "deposit", Alignment: "CREDIT", Amount: 100},
+			},
+		}, acc.GetUniqueIDGenerator())
+		if err := acc.GetJournalManager().PersistJournal(ctx, journal); err != nil {
+			t.Error(err.Error())
+			t.FailNow()
+		}
+	}
+	// the transactions share the same second, the transaction id tells them apart
+	for i := 0; i < 5; i++ {
+		post()
+	}
+	from, until := time.Now().Add(-time.Hour), time.Now().Add(time.Hour)
+
+	seen := make(map[string]bool)
+	cursor := ""
+	for pages := 0; ; pages++ {
+		page, transactions, err := cursorManager.ListTransactionsOnAccountAfter(ctx, from, until, cash.GetAccountNumber(), nil, cursor, 2)
+		if err != nil {
+			t.Error(err.Error())
+			t.FailNow()
+		}
+		for _, trx := range transactions {
+			if seen[trx.GetTransactionID()] {
+				t.Errorf("transaction %s listed twice", trx.GetTransactionID())
+			}
+			seen[trx.GetTransactionID()] = true
+		}
+		cursor = page.NextCursor
+		if !page.HasMore {
+			break
+		}
+		if pages > 5 {
+			t.Fatal("expecting the last page")
+		}
+	}
+	if len(seen) != 5 {
+		t.Errorf("expecting 5 transactions, got %d", len(seen))
+	}
+
+	// the last cursor picks up the transactions arriving later
+	post()
+	page, transactions, err := cursorManager.ListTransactionsOnAccountAfter(ctx, from, until, cash.GetAccountNumber(), nil, cursor, 2)
+	if err != nil || len(transactions) != 1 || page.HasMore || seen[transactions[0].GetTransactionID()] {
+		t.Errorf("expecting only the new transaction after the last cursor, got %d, %v", len(transactions), err)
+	}
+
+	page, journals, err := cursorManager.ListJournalsAfter(ctx, from, until, nil, "", 4)
+	if err != nil || len(journals) != 4 || !page.HasMore {
+		t.Errorf("expecting the first 4 journals and more, got %d, %v", len(journals), err)
+	}
+	if _, _, err := cursorManager.ListJournalsAfter(ctx, from, until, nil, "garbage", 4); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("expecting ErrInvalidCursor but %v", err)
+	}
+}
+
+func TestAccounting_Statement(t *testing.T) {
+	if testing.Short() {
+		t.Skip("statements are only implemented by the MySQL managers")
+	}
+	ctx := context.WithValue(context.Background(), contextkeys.XRequestID, "1234567890")
+	ctx = context.WithValue(ctx, contextkeys.UserIDContextKey, "TESTING")
+
+	repo, acc := connectTestRepository(ctx, t)
+	statementManager := NewMySQLStatementManager(repo, 2)
+
+	cash, err := acc.CreateNewAccount(ctx, "", "Gold Cash", "Gold cash", "1.1", "GOLD", acccore.DEBIT, "aCreator")
+	if err != nil {
+		t.Error(err.Error())
+		t.FailNow()
+	}
+	equity, err := acc.CreateNewAccount(ctx, "", "Gold Equity", "Gold equity", "3.1", "GOLD", acccore.CREDIT, "aCreator")
+	if err != nil {
+		t.Error(err.Error())
+		t.FailNow()
+	}
+	post := func(alignment string, amount int64) {
+		other := "CREDIT"
+		if alignment == "CREDIT" {
+			other = "DEBIT"
+		}
+		journal := NewJournalFromRequest(&CreateJournalRequest{
+			Description: "cash",
+			Creator:     "aCreator",
+			Transactions: []*TransactionRequest{
+				{AccountNumber: cash.GetAccountNumber(), Description: "cash", Alignment: alignment, Amount: amount},
+				{AccountNumber: equity.GetAccountNumber(), Description: "cash", Alignment: other, Amount: amount},
+			},
+		}, acc.GetUniqueIDGenerator())
+		if err := acc.GetJournalManager().PersistJournal(ctx, journal); err != nil {
+			t.Error(err.Error())
+			t.FailNow()
+		}
+	}
+	post("DEBIT", 1000)
+	// the transactions made up to from are in the opening balance, the transaction times are rounded to the second
+	time.Sleep(1100 * time.Millisecond)
+	from := time.Now()
+	time.Sleep(1100 * time.Millisecond)
+	post("DEBIT", 300)
+	post("CREDIT", 500)
+	until := time.Now().Add(time.Hour)
+
+	statement, err := statementManager.GetAccountStatement(ctx, from, until, cash)
+	if err != nil {
+		t.Error(err.Error())
+		t.FailNow()
+	}
+	if statement.OpeningBalance != 1000 || len(statement.Lines) != 2 || statement.TotalDebit != 300 || statement.TotalCredit != 500 || statement.ClosingBalance != 800 {
+		t.Errorf("expecting opening 1000, debit 300, credit 500 and closing 800, got %+v", statement)
+	}
+	post("DEBIT", 1)
+	if _, err := statementManager.GetAccountStatement(ctx, from, until, cash); !errors.Is(err, ErrStatementTooLong) {
+		t.Errorf("expecting ErrStatementTooLong but %v", err)
+	}
+}
@@ -0,0 +1,76 @@
+package accounting
+
+import (
+	"context"
+	"time"
+
+	"github.com/hyperjumptech/acccore"
+	"github.com/hyperjumptech/bookkeeping/internal/connector"
+	"github.com/hyperjumptech/bookkeeping/internal/contextkeys"
+)
+
+// METADATA MANAGER ------------------------------------------------------------------
+
+// NewMySQLMetadataManager returns new sql metadata manager. The journals found are loaded using the journalManager.
+func NewMySQLMetadataManager(repo connector.DBRepository, journalManager acccore.JournalManager) MetadataManager {
+	return &MySQLMetadataManager{repo: repo, journalManager: journalManager}
+}
+
+// MySQLMetadataManager implementation of MetadataManager using the metadata columns of the journals and transactions tables in MySQL
+type MySQLMetadataManager struct {
+	repo           connector.DBRepository
+	journalManager acccore.JournalManager
+}
+
+// ListJournalsByMetadata lists the journals between the `from` and `until` time range whose metadata matches the filter.
+// This function uses pagination.
+func (mm *MySQLMetadataManager) ListJournalsByMetadata(ctx context.Context, from time.Time, until time.Time, filter Metadata, request acccore.PageRequest) (acccore.PageResult, []acccore.Journal, error) {
+	requestID := ctx.Value(contextkeys.XRequestID).(string)
+	lLog := dbLog.WithField("RequestID", requestID).WithField("function", "ListJournalsByMetadata")
+
+	count, err := mm.repo.CountJournalByMetadata(ctx, from, until, connector.MetadataFilter(filter))
+	if err != nil {
+		lLog.Errorf("error while calling mm.repo.CountJournalByMetadata. got %s", err.Error())
+		return acccore.PageResult{}, nil, err
+	}
+	pResult := acccore.PageResultFor(request, count)
+	jRecords, err := mm.repo.ListJournalByMetadata(ctx, from, until, connector.MetadataFilter(filter), pResult.Offset, pResult.PageSize)
+	if err != nil {
+		lLog.Errorf("error while calling mm.repo.ListJournalByMetadata. got %s", err.Error())
+		return acccore.PageResult{}, nil, err
+	}
+	ret := make([]acccore.Journal, 0)
+	for _, jrnl := range jRecords {
+		journal, err := mm.journalManager.GetJournalByID(ctx, jrnl.JournalID)
+		if err != nil {
+			lLog.Errorf("Error while retrieving journal %s. got %s. skipping", jrnl.JournalID, err.Error())
+		} else {
+			ret = append(ret, journal)
+		}
+	}
+	return pResult, ret, nil
+}
+
+// ListTransactionsOnAccountByMetadata lists the transactions of the account between the `from` and `until` time range
+// whose metadata matches the filter. This function uses pagination.
+func (mm *MySQLMetadataManager) ListTransactionsOnAccountByMetadata(ctx context.Context, from time.Time, until time.Time, accountNumber string, filter Metadata, request acccore.PageRequest) (acccore.PageResult, []acccore.Transaction, error) {
+	requestID := ctx.Value(contextkeys.XRequestID).(string)
+	lLog := dbLog.WithField("RequestID", requestID).WithField("function", "ListTransactionsOnAccountByMetadata")
+
+	count, err := mm.repo.CountTransactionByAccountNumberAndMetadata(ctx, accountNumber, from, until, connector.MetadataFilter(filter))
+	if err != nil {
+		lLog.Errorf("error while calling mm.repo.CountTransactionByAccountNumberAndMetadata. got %s", err.Error())
+		return acccore.PageResult{}, nil, err
+	}
+	pageResult := acccore.PageResultFor(request, count)
+	records, err := mm.repo.ListTransactionByAccountNumberAndMetadata(ctx, accountNumber, from, until, connector.MetadataFilter(filter), pageResult.Offset, pageResult.PageSize)
+	if err != nil {
+		lLog.Errorf("error while calling mm.repo.ListTransactionByAccountNumberAndMetadata. got %s", err.Error())
+		return acccore.PageResult{}, nil, err
+	}
+	ret := make([]acccore.Transaction, 0, len(records))
+	for _, tx := range records {
+		ret = append(ret, transactionFromRecord(tx))
+	}
+	return pageResult, ret, nil
+}
@@ -0,0 +1,162 @@
+package accounting
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperjumptech/bookkeeping/internal/connector"
+	"github.com/hyperjumptech/bookkeeping/internal/contextkeys"
+)
+
+// POSTING TEMPLATE MANAGER ------------------------------------------------------------------
+
+// NewMySQLPostingTemplateManager returns new sql posting template manager
+func NewMySQLPostingTemplateManager(repo connector.DBRepository) PostingTemplateManager {
+	return &MySQLPostingTemplateManager{repo: repo}
+}
+
+// MySQLPostingTemplateManager implementation of PostingTemplateManager using the posting_templates table in MySQL.
+type MySQLPostingTemplateManager struct {
+	repo connector.DBRepository
+}
+
+// postingTemplateDefinition is the part of posting template stored as JSON in the definition column
+type postingTemplateDefinition struct {
+	Parameters []*PostingTemplateParameter `json:"parameters"`
+	Variables  []*PostingTemplateVariable  `json:"variables"`
+	Lines      []*PostingTemplateLine      `json:"lines"`
+}
+
+// postingTemplateFromRecord converts the PostingTemplateRecord into PostingTemplate
+func postingTemplateFromRecord(rec *connector.PostingTemplateRecord) (*PostingTemplate, error) {
+	def := &postingTemplateDefinition{}
+	err := json.Unmarshal([]byte(rec.Definition), def)
+	if err != nil {
+		return nil, err
+	}
+	return &PostingTemplate{
+		Name:        rec.Name,
+		Description: rec.Description,
+		Parameters:  def.Parameters,
+		Variables:   def.Variables,
+		Lines:       def.Lines,
+		CreateTime:  rec.CreatedAt,
+		CreateBy:    rec.CreatedBy,
+		UpdateTime:  rec.UpdatedAt,
+		UpdateBy:    rec.UpdatedBy,
+	}, nil
+}
+
+// SavePostingTemplate creates the posting template, or replaces it if a template of the same name exist.
+func (pm *MySQLPostingTemplateManager) SavePostingTemplate(ctx context.Context, template *PostingTemplate, creator string) (*PostingTemplate, error) {
+	requestID := ctx.Value(contextkeys.XRequestID).(string)
+	lLog := dbLog.WithField("RequestID", requestID).WithField("function", "SavePostingTemplate")
+
+	if err := template.Validate(); err != nil {
+		lLog.Errorf("error saving posting template %s. got %s", template.Name, err.Error())
+		return nil, err
+	}
+	definition, err := json.Marshal(&postingTemplateDefinition{
+		Parameters: template.Parameters,
+		Variables:  template.Variables,
+		Lines:      template.Lines,
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = pm.repo.SavePostingTemplate(context.WithValue(ctx, contextkeys.UserIDContextKey, creator), &connector.PostingTemplateRecord{
+		Name:        template.Name,
+		Description: template.Description,
+		Definition:  string(definition),
+	})
+	if err != nil {
+		lLog.Errorf("error while calling pm.repo.SavePostingTemplate. got %s", err.Error())
+		return nil, err
+	}
+	return pm.GetPostingTemplate(ctx, template.Name)
+}
+
+// GetPostingTemplate returns the posting template of the specified name
+func (pm *MySQLPostingTemplateManager) GetPostingTemplate(ctx context.Context, name string) (*PostingTemplate, error) {
+	requestID := ctx.Value(contextkeys.XRequestID).(string)
+	lLog := dbLog.WithField("RequestID", requestID).WithField("function", "GetPostingTemplate")
+
+	rec, err := pm.repo.GetPostingTemplate(ctx, name)
+	if err != nil {
+		lLog.Errorf("error while calling pm.repo.GetPostingTemplate. got %s", err.Error())
+		return nil, err
+	}
+	if rec == nil {
+		return nil, fmt.Errorf("%w : %s", ErrPostingTemplateNotFound, name)
+	}
+	return postingTemplateFromRecord(rec)
+}
+
+// ListPostingTemplates list all posting templates.
+func (pm *MySQLPostingTemplateManager) ListPostingTemplates(ctx context.Context) ([]*PostingTemplate, error) {
+	requestID := ctx.Value(contextkeys.XRequestID).(string)
+	lLog := dbLog.WithField("RequestID", requestID).WithField("function", "ListPostingTemplates")
+
+	recs, err := pm.repo.ListPostingTemplates(ctx)
+	if err != nil {
+		lLog.Errorf("error while calling pm.repo.ListPostingTemplates. got %s", err.Error())
+		return nil, err
+	}
+	ret := make([]*PostingTemplate, 0)
+	for _, rec := range recs {
+		tpl, err := postingTemplateFromRecord(rec)
+		if err != nil {
+			lLog.Errorf("Error while reading posting template %s. got %s. skipping", rec.Name, err.Error())
+		} else {
+			ret = append(ret, tpl)
+		}
+	}
+	return ret, nil
+}
+
+// DeletePostingTemplate removes a posting template.
+func (pm *MySQLPostingTemplateManager) DeletePostingTemplate(ctx context.Context, name string) error {
+	requestID := ctx.Value(contextkeys.XRequestID).(string)
+	lLog := dbLog.WithField("RequestID", requestID).WithField("function", "DeletePostingTemplate")
+
+	deleted, err := pm.repo.DeletePostingTemplate(ctx, name)
+	if err != nil {
+		lLog.Errorf("error while calling pm.repo.DeletePostingTemplate. got %s", err.Error())
+		return err
+	}
+	if !deleted {
+		return fmt.Errorf("%w : %s", ErrPostingTemplateNotFound, name)
+	}
+	return nil
+}
+
+// ExpandPostingTemplate turns the posting template of the specified name into a journal request using the parameters.
+// When description is empty, the template description is used.
+func (pm *MySQLPostingTemplateManager) ExpandPostingTemplate(ctx context.Context, name string, params map[string]interface{}, description, creator string) (*CreateJournalRequest, error) {
+	requestID := ctx.Value(contextkeys.XRequestID).(string)
+	lLog := dbLog.WithField("RequestID", requestID).WithField("function", "ExpandPostingTemplate")
+
+	tpl, err := pm.GetPostingTemplate(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	transactions, err := tpl.Expand(params)
+	if err != nil {
+		lLog.Errorf("error expanding posting template %s. got %s", name, err.Error())
+		return nil, err
+	}
+	if len(description) == 0 {
+		description = tpl.Description
+	}
+	for _, trx := range transactions {
+		if len(trx.Description) == 0 {
+			trx.Description = description
+		}
+	}
+	return &CreateJournalRequest{
+		Description:  description,
+		Creator:      creator,
+		Transactions: transactions,
+	}, nil
+}
@@ -0,0 +1,48 @@
+package accounting
+
+import (
+	"context"
+	"time"
+
+	"github.com/hyperjumptech/bookkeeping/internal/connector"
+	"github.com/hyperjumptech/bookkeeping/internal/contextkeys"
+)
+
+// RATE LIMIT MANAGER ------------------------------------------------------------------
+
+// NewMySQLRateLimitManager returns new sql rate limit manager.
+func NewMySQLRateLimitManager(repo connector.DBRepository) RateLimitManager {
+	return &MySQLRateLimitManager{repo: repo}
+}
+
+// MySQLRateLimitManager implementation of RateLimitManager using the rate_limits table in MySQL.
+type MySQLRateLimitManager struct {
+	repo connector.DBRepository
+}
+
+// Hit counts a request of the key in the window starting at windowStart and lasting window,
+// and returns the number of requests of the key counted in the window so far.
+func (rm *MySQLRateLimitManager) Hit(ctx context.Context, key string, windowStart time.Time, window time.Duration) (int, error) {
+	requestID, _ := ctx.Value(contextkeys.XRequestID).(string)
+	lLog := dbLog.WithField("RequestID", requestID).WithField("function", "Hit")
+
+	count, err := rm.repo.IncrementRateLimit(ctx, key, windowStart, windowStart.Add(window))
+	if err != nil {
+		lLog.Errorf("error while calling rm.repo.IncrementRateLimit. got %s", err.Error())
+		return 0, err
+	}
+	return count, nil
+}
+
+// PurgeRateLimits removes the counts of the ended windows, returning the number of removed counts.
+func (rm *MySQLRateLimitManager) PurgeRateLimits(ctx context.Context) (int64, error) {
+	requestID := ctx.Value(contextkeys.XRequestID).(string)
+	lLog := dbLog.WithField("RequestID", requestID).WithField("function", "PurgeRateLimits")
+
+	count, err := rm.repo.DeleteRateLimitsBefore(ctx, time.Now())
+	if err != nil {
+		lLog.Errorf("error while calling rm.repo.DeleteRateLimitsBefore. got %s", err.Error())
+		return 0, err
+	}
+	return count, nil
+}
@@ -0,0 +1,428 @@
+package accounting
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/hyperjumptech/acccore"
+	dberrors "github.com/hyperjumptech/bookkeeping/errors"
+	"github.com/hyperjumptech/bookkeeping/internal/connector"
+	"github.com/hyperjumptech/bookkeeping/internal/contextkeys"
+	"github.com/robfig/cron/v3"
+)
+
+// RECURRING JOURNAL MANAGER ------------------------------------------------------------------
+
+// NewMySQLRecurringJournalManager returns new sql recurring journal manager. The recurring journals are scheduled
+// into the scheduler and posted using the same persisting rules as MySQLJournalManager.
+func NewMySQLRecurringJournalManager(repo connector.DBRepository, idGenerator acccore.UniqueIDGenerator, scheduler *cron.Cron) RecurringJournalManager {
+	return &MySQLRecurringJournalManager{
+		repo:           repo,
+		journalManager: &MySQLJournalManager{repo: repo},
+		idGenerator:    idGenerator,
+		scheduler:      scheduler,
+		entries:        make(map[string]cron.EntryID),
+	}
+}
+
+// MySQLRecurringJournalManager implementation of RecurringJournalManager using the recurring_journals table in MySQL.
+// A recurring journal created, paused or resumed is scheduled or unscheduled right away only on the instance serving
+// the request, the other instances catch up on their next ScheduleAll. Every instance fires the active recurring journals
+// it has scheduled, and claims the fire so it is posted only once.
+type MySQLRecurringJournalManager struct {
+	repo           connector.DBRepository
+	journalManager *MySQLJournalManager
+	idGenerator    acccore.UniqueIDGenerator
+	scheduler      *cron.Cron
+
+	mutex   sync.Mutex
+	entries map[string]cron.EntryID
+}
+
+// toRecurringJournal converts the RecurringJournalRecord into RecurringJournal
+func (rm *MySQLRecurringJournalManager) toRecurringJournal(rec *connector.RecurringJournalRecord) (*RecurringJournal, error) {
+	ret := &RecurringJournal{
+		ScheduleID:     rec.ScheduleID,
+		CronExpression: rec.CronExpression,
+		Description:    rec.Description,
+		Transactions:   make([]*TransactionRequest, 0),
+		Metadata:       decodeMetadata(rec.Metadata),
+		Status:         rec.Status,
+		CreateTime:     rec.CreatedAt,
+		CreateBy:       rec.CreatedBy,
+	}
+	err := json.Unmarshal([]byte(rec.Transactions), &ret.Transactions)
+	if err != nil {
+		return nil, err
+	}
+	if rec.Status == connector.RecurringJournalStatusActive {
+		if schedule, err := cron.ParseStandard(rec.CronExpression); err == nil {
+			next := schedule.Next(time.Now())
+			ret.NextRun = &next
+		}
+	}
+	return ret, nil
+}
+
+// schedule adds the recurring journal into the scheduler, if its not yet scheduled. It is posted to the books of the tenant.
+func (rm *MySQLRecurringJournalManager) schedule(tenantID, scheduleID, cronExpression string) error {
+	rm.mutex.Lock()
+	defer rm.mutex.Unlock()
+	if _, ok := rm.entries[scheduleID]; ok {
+		return nil
+	}
+	entryID, err := rm.scheduler.AddFunc(cronExpression, func() {
+		ctx := connector.WithTenant(context.WithValue(context.Background(), contextkeys.XRequestID, "cron-recurring-"+scheduleID), tenantID)
+		// the standard cron expression fires on the minute, every instance scheduling the journal claims the same fire time.
+		fireTime := time.Now().Truncate(time.Minute)
+		run, err := rm.runRecurringJournal(ctx, scheduleID, &fireTime)
+		if errors.Is(err, ErrRecurringJournalRunClaimed) || errors.Is(err, ErrRecurringJournalNotActive) {
+			dbLog.WithField("function", "RunRecurringJournal").Infof("recurring journal %s is not run at %s. %s", scheduleID, fireTime.Format(time.RFC3339), err.Error())
+		} else if err != nil {
+			dbLog.WithField("function", "RunRecurringJournal").Errorf("error running recurring journal %s. got %s", scheduleID, err.Error())
+		} else if !run.Success {
+			dbLog.WithField("function", "RunRecurringJournal").Warnf("recurring journal %s failed to post. got %s", scheduleID, run.ErrorMessage)
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("%w : %s", ErrInvalidCronExpression, err.Error())
+	}
+	rm.entries[scheduleID] = entryID
+	return nil
+}
+
+// unschedule removes the recurring journal from the scheduler
+func (rm *MySQLRecurringJournalManager) unschedule(scheduleID string) {
+	rm.mutex.Lock()
+	defer rm.mutex.Unlock()
+	if entryID, ok := rm.entries[scheduleID]; ok {
+		rm.scheduler.Remove(entryID)
+		delete(rm.entries, scheduleID)
+	}
+}
+
+// CreateRecurringJournal stores the journal to be posted on the cron expression schedule, and schedule it.
+func (rm *MySQLRecurringJournalManager) CreateRecurringJournal(ctx context.Context, cronExpression string, journal *CreateJournalRequest) (*RecurringJournal, error) {
+	requestID := ctx.Value(contextkeys.XRequestID).(string)
+	lLog := dbLog.WithField("RequestID", requestID).WithField("function", "CreateRecurringJournal")
+
+	if _, err := cron.ParseStandard(cronExpression); err != nil {
+		lLog.Errorf("error creating recurring journal. cron expression %s is invalid. got %s", cronExpression, err.Error())
+		return nil, fmt.Errorf("%w : %s", ErrInvalidCronExpression, err.Error())
+	}
+	if _, _, err := validateJournalRequest(ctx, rm.repo, journal); err != nil {
+		lLog.Errorf("error creating recurring journal. got %s", err.Error())
+		return nil, err
+	}
+	// nobody reviews the journals posted on schedule
+	needApproval, err := journalRequiresApproval(ctx, rm.repo, journal)
+	if err != nil {
+		lLog.Errorf("error while calling journalRequiresApproval. got %s", err.Error())
+		return nil, err
+	}
+	if needApproval {
+		lLog.Errorf("error creating recurring journal. journal matches an approval rule")
+		return nil, ErrJournalRequiresApproval
+	}
+	// every run posts a journal, they can not all be known by the same reference
+	if len(journal.ExternalReference) > 0 {
+		lLog.Errorf("error creating recurring journal. external reference %s given", journal.ExternalReference)
+		return nil, fmt.Errorf("%w : a recurring journal can not have an external reference", ErrInvalidExternalReference)
+	}
+
+	transactions, err := json.Marshal(journal.Transactions)
+	if err != nil {
+		return nil, err
+	}
+	rec := &connector.RecurringJournalRecord{
+		ScheduleID:     rm.idGenerator.NewUniqueID(),
+		CronExpression: cronExpression,
+		Description:    journal.Description,
+		Transactions:   string(transactions),
+		Metadata:       encodeMetadata(journal.Metadata),
+		Status:         connector.RecurringJournalStatusActive,
+	}
+	_, err = rm.repo.InsertRecurringJournal(context.WithValue(ctx, contextkeys.UserIDContextKey, journal.Creator), rec)
+	if err != nil {
+		lLog.Errorf("error while calling rm.repo.InsertRecurringJournal. got %s", err.Error())
+		return nil, err
+	}
+	err = rm.schedule(connector.TenantFromContext(ctx), rec.ScheduleID, rec.CronExpression)
+	if err != nil {
+		lLog.Errorf("error scheduling recurring journal %s. got %s", rec.ScheduleID, err.Error())
+		return nil, err
+	}
+	return rm.toRecurringJournal(rec)
+}
+
+// GetRecurringJournal returns the recurring journal of the specified scheduleID
+func (rm *MySQLRecurringJournalManager) GetRecurringJournal(ctx context.Context, scheduleID string) (*RecurringJournal, error) {
+	requestID := ctx.Value(contextkeys.XRequestID).(string)
+	lLog := dbLog.WithField("RequestID", requestID).WithField("function", "GetRecurringJournal")
+
+	rec, err := rm.repo.GetRecurringJournal(ctx, scheduleID)
+	if err != nil {
+		lLog.Errorf("error while calling rm.repo.GetRecurringJournal. got %s", err.Error())
+		return nil, err
+	}
+	if rec == nil {
+		return nil, ErrRecurringJournalNotFound
+	}
+	return rm.toRecurringJournal(rec)
+}
+
+// ListRecurringJournals list all recurring journals, paused or not.
+func (rm *MySQLRecurringJournalManager) ListRecurringJournals(ctx context.Context, request acccore.PageRequest) (acccore.PageResult, []*RecurringJournal, error) {
+	requestID := ctx.Value(contextkeys.XRequestID).(string)
+	lLog := dbLog.WithField("RequestID", requestID).WithField("function", "ListRecurringJournals")
+
+	count, err := rm.repo.CountRecurringJournals(ctx)
+	if err != nil {
+		lLog.Errorf("error while calling rm.repo.CountRecurringJournals. got %s", err.Error())
+		return acccore.PageResult{}, nil, err
+	}
+	pResult := acccore.PageResultFor(request, count)
+	recs, err := rm.repo.ListRecurringJournal(ctx, pResult.Offset, pResult.PageSize)
+	if err != nil {
+		lLog.Errorf("error while calling rm.repo.ListRecurringJournal. got %s", err.Error())
+		return acccore.PageResult{}, nil, err
+	}
+	ret := make([]*RecurringJournal, 0)
+	for _, rec := range recs {
+		rj, err := rm.toRecurringJournal(rec)
+		if err != nil {
+			lLog.Errorf("Error while reading recurring journal %s. got %s. skipping", rec.ScheduleID, err.Error())
+		} else {
+			ret = append(ret, rj)
+		}
+	}
+	return pResult, ret, nil
+}
+
+// PauseRecurringJournal stops posting the recurring journal until resumed.
+func (rm *MySQLRecurringJournalManager) PauseRecurringJournal(ctx context.Context, scheduleID string) (*RecurringJournal, error) {
+	requestID := ctx.Value(contextkeys.XRequestID).(string)
+	lLog := dbLog.WithField("RequestID", requestID).WithField("function", "PauseRecurringJournal")
+
+	rec, err := rm.repo.GetRecurringJournal(ctx, scheduleID)
+	if err != nil {
+		lLog.Errorf("error while calling rm.repo.GetRecurringJournal. got %s", err.Error())
+		return nil, err
+	}
+	if rec == nil {
+		return nil, ErrRecurringJournalNotFound
+	}
+	err = rm.repo.UpdateRecurringJournalStatus(ctx, scheduleID, connector.RecurringJournalStatusPaused)
+	if err != nil {
+		lLog.Errorf("error while calling rm.repo.UpdateRecurringJournalStatus. got %s", err.Error())
+		return nil, err
+	}
+	rm.unschedule(scheduleID)
+	rec.Status = connector.RecurringJournalStatusPaused
+	return rm.toRecurringJournal(rec)
+}
+
+// ResumeRecurringJournal continue posting a paused recurring journal.
+func (rm *MySQLRecurringJournalManager) ResumeRecurringJournal(ctx context.Context, scheduleID string) (*RecurringJournal, error) {
+	requestID := ctx.Value(contextkeys.XRequestID).(string)
+	lLog := dbLog.WithField("RequestID", requestID).WithField("function", "ResumeRecurringJournal")
+
+	rec, err := rm.repo.GetRecurringJournal(ctx, scheduleID)
+	if err != nil {
+		lLog.Errorf("error while calling rm.repo.GetRecurringJournal. got %s", err.Error())
+		return nil, err
+	}
+	if rec == nil {
+		return nil, ErrRecurringJournalNotFound
+	}
+	err = rm.repo.UpdateRecurringJournalStatus(ctx, scheduleID, connector.RecurringJournalStatusActive)
+	if err != nil {
+		lLog.Errorf("error while calling rm.repo.UpdateRecurringJournalStatus. got %s", err.Error())
+		return nil, err
+	}
+	err = rm.schedule(rec.TenantID, scheduleID, rec.CronExpression)
+	if err != nil {
+		lLog.Errorf("error scheduling recurring journal %s. got %s", scheduleID, err.Error())
+		return nil, err
+	}
+	rec.Status = connector.RecurringJournalStatusActive
+	return rm.toRecurringJournal(rec)
+}
+
+// RunRecurringJournal posts the recurring journal once and records the run. A journal that fails to post
+// is recorded as a failed run, not returned as error.
+func (rm *MySQLRecurringJournalManager) RunRecurringJournal(ctx context.Context, scheduleID string) (*RecurringJournalRun, error) {
+	return rm.runRecurringJournal(ctx, scheduleID, nil)
+}
+
+// runRecurringJournal posts the recurring journal if it is still active, and records the run in the same transaction.
+// A scheduled run claims the fire time of the recurring journal, the run of another instance that have claimed it
+// is refused with ErrRecurringJournalRunClaimed. A manual run, without fire time, claims nothing.
+func (rm *MySQLRecurringJournalManager) runRecurringJournal(ctx context.Context, scheduleID string, fireTime *time.Time) (*RecurringJournalRun, error) {
+	requestID := ctx.Value(contextkeys.XRequestID).(string)
+	lLog := dbLog.WithField("RequestID", requestID).WithField("function", "RunRecurringJournal")
+
+	// BEGIN transaction
+	tx, err := rm.repo.DB().BeginTxx(ctx, &sql.TxOptions{
+		Isolation: 0,
+		ReadOnly:  false,
+	})
+	if err != nil {
+		lLog.Errorf("error creating transaction. got %s", err.Error())
+		return nil, err
+	}
+	txCtx := context.WithValue(ctx, contextkeys.DBTransactionContextKey, tx)
+	rollback := func(err error) (*RecurringJournalRun, error) {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			lLog.Errorf("error rolling back transaction. got %s", rbErr.Error())
+		}
+		return nil, err
+	}
+
+	// Lock the recurring journal, so it is not paused while being posted.
+	rec, err := rm.repo.GetRecurringJournalForUpdate(txCtx, scheduleID)
+	if err != nil {
+		lLog.Errorf("error while calling rm.repo.GetRecurringJournalForUpdate. got %s", err.Error())
+		return rollback(err)
+	}
+	if rec == nil {
+		return rollback(ErrRecurringJournalNotFound)
+	}
+	if rec.Status != connector.RecurringJournalStatusActive {
+		return rollback(ErrRecurringJournalNotActive)
+	}
+	req := &CreateJournalRequest{
+		Description: rec.Description,
+		Creator:     rec.CreatedBy,
+		Metadata:    decodeMetadata(rec.Metadata),
+	}
+	err = json.Unmarshal([]byte(rec.Transactions), &req.Transactions)
+	if err != nil {
+		lLog.Errorf("error reading transactions of recurring journal %s. got %s", scheduleID, err.Error())
+		return rollback(err)
+	}
+
+	journal := NewJournalFromRequest(req, rm.idGenerator)
+	jctx := context.WithValue(ctx, contextkeys.UserIDContextKey, rec.CreatedBy)
+	txCtx = context.WithValue(txCtx, contextkeys.UserIDContextKey, rec.CreatedBy)
+	run := &connector.RecurringJournalRunRecord{
+		RunID:      rm.idGenerator.NewUniqueID(),
+		ScheduleID: scheduleID,
+		RunAt:      time.Now(),
+		FireTime:   fireTime,
+		Success:    true,
+		JournalID:  journal.GetJournalID(),
+	}
+	// Claim the fire time before posting, the fire time can only be claimed once.
+	_, err = rm.repo.InsertRecurringJournalRun(txCtx, run)
+	if errors.Is(err, dberrors.ErrDuplicateKey) {
+		return rollback(ErrRecurringJournalRunClaimed)
+	}
+	if err != nil {
+		lLog.Errorf("error while calling rm.repo.InsertRecurringJournalRun. got %s", err.Error())
+		return rollback(err)
+	}
+	// an approval rule could have been added after the recurring journal is created
+	needApproval, err := journalRequiresApproval(txCtx, rm.repo, req)
+	if err == nil && needApproval {
+		err = ErrJournalRequiresApproval
+	}
+	if err == nil {
+		err = rm.journalManager.persistJournal(txCtx, journal)
+	}
+	if err == nil {
+		// COMMIT transaction
+		err = tx.Commit()
+		if err != nil {
+			lLog.Errorf("error committing transaction. got %s", err.Error())
+			return nil, err
+		}
+		return runFromRecord(run), nil
+	}
+
+	// The journal is not posted, the failed run is recorded on its own.
+	lLog.Errorf("error posting recurring journal %s. got %s", scheduleID, err.Error())
+	if rbErr := tx.Rollback(); rbErr != nil {
+		lLog.Errorf("error rolling back transaction. got %s", rbErr.Error())
+	}
+	run.Success = false
+	run.JournalID = ""
+	run.ErrorMessage = err.Error()
+	_, err = rm.repo.InsertRecurringJournalRun(jctx, run)
+	if errors.Is(err, dberrors.ErrDuplicateKey) {
+		return nil, ErrRecurringJournalRunClaimed
+	}
+	if err != nil {
+		lLog.Errorf("error while calling rm.repo.InsertRecurringJournalRun. got %s", err.Error())
+		return nil, err
+	}
+	return runFromRecord(run), nil
+}
+
+// runFromRecord converts the RecurringJournalRunRecord into RecurringJournalRun
+func runFromRecord(rec *connector.RecurringJournalRunRecord) *RecurringJournalRun {
+	return &RecurringJournalRun{
+		RunID:        rec.RunID,
+		ScheduleID:   rec.ScheduleID,
+		RunAt:        rec.RunAt,
+		Success:      rec.Success,
+		JournalID:    rec.JournalID,
+		ErrorMessage: rec.ErrorMessage,
+	}
+}
+
+// ListRecurringJournalRuns list the run history of the recurring journal, latest run first.
+func (rm *MySQLRecurringJournalManager) ListRecurringJournalRuns(ctx context.Context, scheduleID string, request acccore.PageRequest) (acccore.PageResult, []*RecurringJournalRun, error) {
+	requestID := ctx.Value(contextkeys.XRequestID).(string)
+	lLog := dbLog.WithField("RequestID", requestID).WithField("function", "ListRecurringJournalRuns")
+
+	count, err := rm.repo.CountRecurringJournalRuns(ctx, scheduleID)
+	if err != nil {
+		lLog.Errorf("error while calling rm.repo.CountRecurringJournalRuns. got %s", err.Error())
+		return acccore.PageResult{}, nil, err
+	}
+	pResult := acccore.PageResultFor(request, count)
+	recs, err := rm.repo.ListRecurringJournalRun(ctx, scheduleID, pResult.Offset, pResult.PageSize)
+	if err != nil {
+		lLog.Errorf("error while calling rm.repo.ListRecurringJournalRun. got %s", err.Error())
+		return acccore.PageResult{}, nil, err
+	}
+	ret := make([]*RecurringJournalRun, len(recs))
+	for i, rec := range recs {
+		ret[i] = runFromRecord(rec)
+	}
+	return pResult, ret, nil
+}
+
+// ScheduleAll schedules the active recurring journals not yet scheduled and unschedules the paused ones.
+// It is called when the server starts, then periodically to pick up the changes made on the other instances.
+func (rm *MySQLRecurringJournalManager) ScheduleAll(ctx context.Context) error {
+	requestID := ctx.Value(contextkeys.XRequestID).(string)
+	lLog := dbLog.WithField("RequestID", requestID).WithField("function", "ScheduleAll")
+
+	recs, err := rm.repo.ListRecurringJournalByStatus(ctx, connector.RecurringJournalStatusActive)
+	if err != nil {
+		lLog.Errorf("error while calling rm.repo.ListRecurringJournalByStatus. got %s", err.Error())
+		return err
+	}
+	for _, rec := range recs {
+		err = rm.schedule(rec.TenantID, rec.ScheduleID, rec.CronExpression)
+		if err != nil {
+			lLog.Errorf("error scheduling recurring journal %s. got %s. skipping", rec.ScheduleID, err.Error())
+		}
+	}
+	paused, err := rm.repo.ListRecurringJournalByStatus(ctx, connector.RecurringJournalStatusPaused)
+	if err != nil {
+		lLog.Errorf("error while calling rm.repo.ListRecurringJournalByStatus. got %s", err.Error())
+		return err
+	}
+	for _, rec := range paused {
+		rm.unschedule(rec.ScheduleID)
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package accounting
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hyperjumptech/acccore"
+	"github.com/hyperjumptech/bookkeeping/internal/connector"
+	"github.com/hyperjumptech/bookkeeping/internal/contextkeys"
+)
+
+// REFERENCE MANAGER ------------------------------------------------------------------
+
+// NewMySQLReferenceManager returns new sql reference manager. The journals found are loaded using the journalManager.
+func NewMySQLReferenceManager(repo connector.DBRepository, journalManager acccore.JournalManager) ReferenceManager {
+	return &MySQLReferenceManager{repo: repo, journalManager: journalManager}
+}
+
+// MySQLReferenceManager implementation of ReferenceManager using the external_reference columns of the journals and accounts tables in MySQL
+type MySQLReferenceManager struct {
+	repo           connector.DBRepository
+	journalManager acccore.JournalManager
+}
+
+// GetJournalByReference returns the journal the creator created with the reference, or ErrExternalReferenceNotFound
+func (rm *MySQLReferenceManager) GetJournalByReference(ctx context.Context, creator, reference string) (acccore.Journal, error) {
+	requestID := ctx.Value(contextkeys.XRequestID).(string)
+	lLog := dbLog.WithField("RequestID", requestID).WithField("function", "GetJournalByReference")
+
+	rec, err := rm.repo.GetJournalByExternalReference(ctx, creator, reference)
+	if err != nil {
+		lLog.Errorf("error while calling rm.repo.GetJournalByExternalReference. got %s", err.Error())
+		return nil, err
+	}
+	if rec == nil {
+		return nil, fmt.Errorf("%w : journal %q of %s", ErrExternalReferenceNotFound, reference, creator)
+	}
+	return rm.journalManager.GetJournalByID(ctx, rec.JournalID)
+}
+
+// GetAccountByReference returns the account the creator created with the reference, or ErrExternalReferenceNotFound
+func (rm *MySQLReferenceManager) GetAccountByReference(ctx context.Context, creator, reference string) (acccore.Account, error) {
+	requestID := ctx.Value(contextkeys.XRequestID).(string)
+	lLog := dbLog.WithField("RequestID", requestID).WithField("function", "GetAccountByReference")
+
+	rec, err := rm.repo.GetAccountByExternalReference(ctx, creator, reference)
+	if err != nil {
+		lLog.Errorf("error while calling rm.repo.GetAccountByExternalReference. got %s", err.Error())
+		return nil, err
+	}
+	if rec == nil {
+		return nil, fmt.Errorf("%w : account %q of %s", ErrExternalReferenceNotFound, reference, creator)
+	}
+	return accountFromRecord(rec), nil
+}
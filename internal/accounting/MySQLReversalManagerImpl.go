@@ -0,0 +1,165 @@
+package accounting
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hyperjumptech/acccore"
+	"github.com/hyperjumptech/bookkeeping/internal/connector"
+	"github.com/hyperjumptech/bookkeeping/internal/contextkeys"
+)
+
+// REVERSAL MANAGER ------------------------------------------------------------------
+
+// NewMySQLReversalManager returns new sql reversal manager
+func NewMySQLReversalManager(repo connector.DBRepository, journalManager acccore.JournalManager, idGenerator acccore.UniqueIDGenerator) ReversalManager {
+	return &MySQLReversalManager{repo: repo, journalManager: journalManager, idGenerator: idGenerator}
+}
+
+// MySQLReversalManager implementation of ReversalManager using the journals and transactions table in MySQL.
+// The reversed amount is not stored, its summed from the reversal journals.
+type MySQLReversalManager struct {
+	repo           connector.DBRepository
+	journalManager acccore.JournalManager
+	idGenerator    acccore.UniqueIDGenerator
+}
+
+// ReverseJournal creates and persists a reversal journal of the specified journal. Without lines, all that is left to
+// reverse of every line is reversed. ErrReversalExceedsOriginal is returned when a line would be over reversed.
+// The amount left is checked again by PersistJournal while the reversed journal is locked, so concurrent reversals can not
+// over reverse the journal. ErrJournalRequiresApproval is returned when the reversal matches an approval rule.
+func (rm *MySQLReversalManager) ReverseJournal(ctx context.Context, journalID string, lines []*ReversalLine, description, creator string) (acccore.Journal, error) {
+	requestID := ctx.Value(contextkeys.XRequestID).(string)
+	lLog := dbLog.WithField("RequestID", requestID).WithField("function", "ReverseJournal")
+
+	summary, err := rm.GetReversalSummary(ctx, journalID)
+	if err != nil {
+		return nil, err
+	}
+	reversed, err := rm.journalManager.GetJournalByID(ctx, journalID)
+	if err != nil {
+		lLog.Errorf("error while calling rm.journalManager.GetJournalByID. got %s", err.Error())
+		return nil, err
+	}
+	summaryLines := make(map[string]*ReversalSummaryLine)
+	for _, line := range summary.Lines {
+		summaryLines[line.AccountNumber] = line
+	}
+	lineDescriptions := make(map[string]string)
+	for _, trx := range reversed.GetTransactions() {
+		lineDescriptions[trx.GetAccountNumber()] = trx.GetDescription()
+	}
+
+	if len(lines) == 0 {
+		if summary.FullyReversed {
+			return nil, fmt.Errorf("%w : %s", acccore.ErrJournalCanNotDoubleReverse, journalID)
+		}
+		for _, line := range summary.Lines {
+			if line.Amount > line.ReversedAmount {
+				lines = append(lines, &ReversalLine{AccountNumber: line.AccountNumber})
+			}
+		}
+	}
+
+	journal := &acccore.BaseJournal{
+		JournalID:       rm.idGenerator.NewUniqueID(),
+		JournalingTime:  time.Now(),
+		Reversal:        true,
+		ReversedJournal: reversed,
+		Description:     description,
+		CreatedBy:       creator,
+		CreateTime:      time.Now(),
+	}
+	transactions := make([]acccore.Transaction, 0, len(lines))
+	for _, line := range lines {
+		summaryLine, ok := summaryLines[line.AccountNumber]
+		if !ok {
+			return nil, fmt.Errorf("%w : account %s is not in journal %s", ErrInvalidReversal, line.AccountNumber, journalID)
+		}
+		amount := line.Amount
+		if amount == 0 {
+			amount = summaryLine.Amount - summaryLine.ReversedAmount
+		}
+		alignment := acccore.DEBIT
+		if summaryLine.Alignment == "DEBIT" {
+			alignment = acccore.CREDIT
+		}
+		transactions = append(transactions, &acccore.BaseTransaction{
+			TransactionID:   rm.idGenerator.NewUniqueID(),
+			TransactionTime: time.Now(),
+			AccountNumber:   line.AccountNumber,
+			JournalID:       journal.JournalID,
+			Description:     fmt.Sprintf("%s - reversed", lineDescriptions[line.AccountNumber]),
+			TransactionType: alignment,
+			Amount:          amount,
+			CreateTime:      time.Now(),
+			CreateBy:        creator,
+		})
+	}
+	journal.SetTransactions(transactions)
+
+	err = rm.journalManager.PersistJournal(context.WithValue(ctx, contextkeys.UserIDContextKey, creator), journal)
+	if err != nil {
+		lLog.Errorf("error while persisting reversal of journal %s. got %s", journalID, err.Error())
+		return nil, err
+	}
+	return journal, nil
+}
+
+// GetReversalSummary returns the cumulative reversed amount of each line of the journal.
+func (rm *MySQLReversalManager) GetReversalSummary(ctx context.Context, journalID string) (*ReversalSummary, error) {
+	requestID := ctx.Value(contextkeys.XRequestID).(string)
+	lLog := dbLog.WithField("RequestID", requestID).WithField("function", "GetReversalSummary")
+
+	journal, err := rm.repo.GetJournal(ctx, journalID)
+	if err != nil {
+		lLog.Errorf("error while calling rm.repo.GetJournal. got %s", err.Error())
+		return nil, err
+	}
+	if journal == nil {
+		return nil, fmt.Errorf("%w : %s", acccore.ErrJournalIDNotFound, journalID)
+	}
+	trxs, err := rm.repo.ListTransactionByJournalID(ctx, journalID)
+	if err != nil {
+		lLog.Errorf("error while calling rm.repo.ListTransactionByJournalID. got %s", err.Error())
+		return nil, err
+	}
+	reversedAmounts, err := rm.repo.SumReversedAmountByJournalID(ctx, journalID)
+	if err != nil {
+		lLog.Errorf("error while calling rm.repo.SumReversedAmountByJournalID. got %s", err.Error())
+		return nil, err
+	}
+	reversals, err := rm.repo.ListJournalByReversedJournalID(ctx, journalID)
+	if err != nil {
+		lLog.Errorf("error while calling rm.repo.ListJournalByReversedJournalID. got %s", err.Error())
+		return nil, err
+	}
+
+	summary := &ReversalSummary{
+		JournalID:     journal.JournalID,
+		TotalAmount:   journal.TotalAmount,
+		FullyReversed: true,
+		Lines:         make([]*ReversalSummaryLine, 0, len(trxs)),
+		ReversalIDs:   make([]string, 0, len(reversals)),
+	}
+	for _, trx := range trxs {
+		line := &ReversalSummaryLine{
+			AccountNumber:  trx.AccountNumber,
+			Alignment:      trx.Alignment,
+			Amount:         trx.Amount,
+			ReversedAmount: reversedAmounts[trx.AccountNumber],
+		}
+		if line.Alignment == "DEBIT" {
+			summary.ReversedAmount += line.ReversedAmount
+		}
+		if line.ReversedAmount < line.Amount {
+			summary.FullyReversed = false
+		}
+		summary.Lines = append(summary.Lines, line)
+	}
+	for _, reversal := range reversals {
+		summary.ReversalIDs = append(summary.ReversalIDs, reversal.JournalID)
+	}
+	return summary, nil
+}
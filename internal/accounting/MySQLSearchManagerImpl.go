@@ -0,0 +1,144 @@
+package accounting
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/hyperjumptech/acccore"
+	"github.com/hyperjumptech/bookkeeping/internal/connector"
+	"github.com/hyperjumptech/bookkeeping/internal/contextkeys"
+)
+
+// SEARCH MANAGER ------------------------------------------------------------------
+
+// NewMySQLSearchManager returns new sql search manager. The journals found are loaded using the journalManager.
+func NewMySQLSearchManager(repo connector.DBRepository, journalManager acccore.JournalManager) SearchManager {
+	return &MySQLSearchManager{repo: repo, journalManager: journalManager}
+}
+
+// MySQLSearchManager implementation of SearchManager using the journals and transactions tables in MySQL
+type MySQLSearchManager struct {
+	repo           connector.DBRepository
+	journalManager acccore.JournalManager
+}
+
+// searchSorts converts the request sorts into the sorts of the repository, the fields must be in the columns
+func searchSorts(sorts []acccore.Sort, columns map[string]string) ([]connector.SearchSort, error) {
+	ret := make([]connector.SearchSort, 0, len(sorts))
+	for _, sort := range sorts {
+		if _, ok := columns[sort.Column]; !ok {
+			return nil, fmt.Errorf("%w : can not sort by %q", ErrInvalidSearch, sort.Column)
+		}
+		ret = append(ret, connector.SearchSort{Field: sort.Column, Ascending: sort.Ascending})
+	}
+	return ret, nil
+}
+
+// validateSearchRange make sure the alignment is empty, DEBIT or CREDIT and the amount range is not empty
+func validateSearchRange(alignment string, minAmount, maxAmount *int64) error {
+	switch alignment {
+	case "", "DEBIT", "CREDIT":
+	default:
+		return fmt.Errorf("%w : alignment must be DEBIT or CREDIT", ErrInvalidSearch)
+	}
+	if minAmount != nil && maxAmount != nil && *minAmount > *maxAmount {
+		return fmt.Errorf("%w : min amount is greater than max amount", ErrInvalidSearch)
+	}
+	return nil
+}
+
+// SearchTransactions lists the transactions matching the filter. This function uses pagination.
+func (sm *MySQLSearchManager) SearchTransactions(ctx context.Context, filter *TransactionSearchFilter, request acccore.PageRequest) (acccore.PageResult, []acccore.Transaction, error) {
+	requestID := ctx.Value(contextkeys.XRequestID).(string)
+	lLog := dbLog.WithField("RequestID", requestID).WithField("function", "SearchTransactions")
+
+	sorts, err := searchSorts(request.Sorts, connector.TransactionSearchSorts)
+	if err != nil {
+		return acccore.PageResult{}, nil, err
+	}
+	recFilter := &connector.TransactionSearchFilter{}
+	if filter != nil {
+		if err := validateSearchRange(strings.ToUpper(filter.Alignment), filter.MinAmount, filter.MaxAmount); err != nil {
+			return acccore.PageResult{}, nil, err
+		}
+		recFilter = &connector.TransactionSearchFilter{
+			AccountNumbers: filter.AccountNumbers,
+			Coa:            filter.Coa,
+			Alignment:      strings.ToUpper(filter.Alignment),
+			MinAmount:      filter.MinAmount,
+			MaxAmount:      filter.MaxAmount,
+			CreatedBy:      filter.Creator,
+			Reversal:       filter.Reversal,
+			Description:    filter.Description,
+			From:           filter.From,
+			To:             filter.Until,
+		}
+	}
+	count, err := sm.repo.CountTransactionSearch(ctx, recFilter)
+	if err != nil {
+		lLog.Errorf("error while calling sm.repo.CountTransactionSearch. got %s", err.Error())
+		return acccore.PageResult{}, nil, err
+	}
+	pResult := acccore.PageResultFor(request, count)
+	records, err := sm.repo.SearchTransactions(ctx, recFilter, sorts, pResult.Offset, pResult.PageSize)
+	if err != nil {
+		lLog.Errorf("error while calling sm.repo.SearchTransactions. got %s", err.Error())
+		return acccore.PageResult{}, nil, err
+	}
+	ret := make([]acccore.Transaction, 0, len(records))
+	for _, tx := range records {
+		ret = append(ret, transactionFromRecord(tx))
+	}
+	return pResult, ret, nil
+}
+
+// SearchJournals lists the journals matching the filter. This function uses pagination.
+func (sm *MySQLSearchManager) SearchJournals(ctx context.Context, filter *JournalSearchFilter, request acccore.PageRequest) (acccore.PageResult, []acccore.Journal, error) {
+	requestID := ctx.Value(contextkeys.XRequestID).(string)
+	lLog := dbLog.WithField("RequestID", requestID).WithField("function", "SearchJournals")
+
+	sorts, err := searchSorts(request.Sorts, connector.JournalSearchSorts)
+	if err != nil {
+		return acccore.PageResult{}, nil, err
+	}
+	recFilter := &connector.JournalSearchFilter{}
+	if filter != nil {
+		if err := validateSearchRange(strings.ToUpper(filter.Alignment), filter.MinAmount, filter.MaxAmount); err != nil {
+			return acccore.PageResult{}, nil, err
+		}
+		recFilter = &connector.JournalSearchFilter{
+			AccountNumbers: filter.AccountNumbers,
+			Coa:            filter.Coa,
+			Alignment:      strings.ToUpper(filter.Alignment),
+			MinAmount:      filter.MinAmount,
+			MaxAmount:      filter.MaxAmount,
+			CreatedBy:      filter.Creator,
+			Reversal:       filter.Reversal,
+			Description:    filter.Description,
+			From:           filter.From,
+			To:             filter.Until,
+		}
+	}
+	count, err := sm.repo.CountJournalSearch(ctx, recFilter)
+	if err != nil {
+		lLog.Errorf("error while calling sm.repo.CountJournalSearch. got %s", err.Error())
+		return acccore.PageResult{}, nil, err
+	}
+	pResult := acccore.PageResultFor(request, count)
+	records, err := sm.repo.SearchJournals(ctx, recFilter, sorts, pResult.Offset, pResult.PageSize)
+	if err != nil {
+		lLog.Errorf("error while calling sm.repo.SearchJournals. got %s", err.Error())
+		return acccore.PageResult{}, nil, err
+	}
+	ret := make([]acccore.Journal, 0, len(records))
+	for _, jrnl := range records {
+		journal, err := sm.journalManager.GetJournalByID(ctx, jrnl.JournalID)
+		if err != nil {
+			lLog.Errorf("Error while retrieving journal %s. got %s. skipping", jrnl.JournalID, err.Error())
+		} else {
+			ret = append(ret, journal)
+		}
+	}
+	return pResult, ret, nil
+}
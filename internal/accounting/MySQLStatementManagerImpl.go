@@ -0,0 +1,97 @@
+package accounting
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hyperjumptech/acccore"
+	"github.com/hyperjumptech/bookkeeping/internal/connector"
+	"github.com/hyperjumptech/bookkeeping/internal/contextkeys"
+)
+
+// STATEMENT MANAGER ------------------------------------------------------------------
+
+// NewMySQLStatementManager returns new sql statement manager. A statement can have up to maxLines transactions.
+func NewMySQLStatementManager(repo connector.DBRepository, maxLines int) StatementManager {
+	return &MySQLStatementManager{repo: repo, maxLines: maxLines}
+}
+
+// MySQLStatementManager implementation of StatementManager using the accounts and transactions tables in MySQL
+type MySQLStatementManager struct {
+	repo     connector.DBRepository
+	maxLines int
+}
+
+// GetAccountStatement returns the statement of the account between the `from` and `until` time range,
+// the transactions are listed in transaction time order.
+func (sm *MySQLStatementManager) GetAccountStatement(ctx context.Context, from time.Time, until time.Time, account acccore.Account) (*AccountStatement, error) {
+	requestID := ctx.Value(contextkeys.XRequestID).(string)
+	lLog := dbLog.WithField("RequestID", requestID).WithField("function", "GetAccountStatement")
+
+	// the transactions listed are after from, the transactions made at from are in the opening balance.
+	opening, err := sm.repo.GetAccountBalanceAt(ctx, account.GetAccountNumber(), from)
+	if err != nil {
+		lLog.Errorf("error while calling sm.repo.GetAccountBalanceAt. got %s", err.Error())
+		return nil, err
+	}
+	// read one more transaction than allowed, to tell if the statement is too long.
+	records, err := sm.repo.ListTransactionByAccountNumberAfter(ctx, account.GetAccountNumber(), from, until, nil, nil, sm.maxLines+1)
+	if err != nil {
+		lLog.Errorf("error while calling sm.repo.ListTransactionByAccountNumberAfter. got %s", err.Error())
+		return nil, err
+	}
+	if len(records) > sm.maxLines {
+		return nil, fmt.Errorf("%w : more than %d transactions, narrow the time range", ErrStatementTooLong, sm.maxLines)
+	}
+	transactions := make([]acccore.Transaction, len(records))
+	for i, tx := range records {
+		transactions[i] = transactionFromRecord(tx)
+	}
+	return newAccountStatement(account, from, until, opening, transactions), nil
+}
+
+// newAccountStatement draws up the statement of the account out of its opening balance and the transactions
+// made within the time range, in the order they are listed.
+func newAccountStatement(account acccore.Account, from, until time.Time, opening int64, transactions []acccore.Transaction) *AccountStatement {
+	statement := &AccountStatement{
+		AccountNumber:  account.GetAccountNumber(),
+		Name:           account.GetName(),
+		Currency:       account.GetCurrency(),
+		COA:            account.GetCOA(),
+		Alignment:      "CREDIT",
+		From:           from.Format(time.RFC3339),
+		Until:          until.Format(time.RFC3339),
+		OpeningBalance: opening,
+		Lines:          make([]*StatementLine, 0, len(transactions)),
+	}
+	if account.GetAlignment() == acccore.DEBIT {
+		statement.Alignment = "DEBIT"
+	}
+	balance := opening
+	for _, trx := range transactions {
+		line := &StatementLine{
+			TransactionID:   trx.GetTransactionID(),
+			TransactionTime: trx.GetTransactionTime().Format(time.RFC3339),
+			JournalID:       trx.GetJournalID(),
+			Description:     trx.GetDescription(),
+		}
+		if trx.GetAlignment() == acccore.DEBIT {
+			line.Debit = trx.GetAmount()
+			statement.TotalDebit += trx.GetAmount()
+		} else {
+			line.Credit = trx.GetAmount()
+			statement.TotalCredit += trx.GetAmount()
+		}
+		// a transaction on the side of the account alignment increases its balance
+		if trx.GetAlignment() == account.GetAlignment() {
+			balance += trx.GetAmount()
+		} else {
+			balance -= trx.GetAmount()
+		}
+		line.Balance = balance
+		statement.Lines = append(statement.Lines, line)
+	}
+	statement.ClosingBalance = balance
+	return statement
+}
@@ -0,0 +1,105 @@
+package accounting
+
+import (
+	"context"
+	"regexp"
+	"strings"
+
+	"github.com/hyperjumptech/acccore"
+	"github.com/hyperjumptech/bookkeeping/internal/connector"
+	"github.com/hyperjumptech/bookkeeping/internal/contextkeys"
+)
+
+// TENANT MANAGER ------------------------------------------------------------------
+
+// tenantIDPattern is the format of a tenant id, it must fit into the tenant_id columns
+var tenantIDPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,16}$`)
+
+// NewMySQLTenantManager returns new sql tenant manager
+func NewMySQLTenantManager(repo connector.DBRepository) TenantManager {
+	return &MySQLTenantManager{repo: repo}
+}
+
+// MySQLTenantManager implementation of TenantManager using the tenants table in MySQL.
+type MySQLTenantManager struct {
+	repo connector.DBRepository
+}
+
+// tenantFromRecord converts the record into Tenant
+func tenantFromRecord(rec *connector.TenantRecord) *Tenant {
+	return &Tenant{
+		TenantID:    rec.TenantID,
+		Name:        rec.Name,
+		Description: rec.Description,
+		CreateTime:  rec.CreatedAt,
+		CreateBy:    rec.CreatedBy,
+	}
+}
+
+// CreateTenant provisions a new tenant with empty books.
+func (tm *MySQLTenantManager) CreateTenant(ctx context.Context, tenant *Tenant, creator string) (*Tenant, error) {
+	requestID := ctx.Value(contextkeys.XRequestID).(string)
+	lLog := dbLog.WithField("RequestID", requestID).WithField("function", "CreateTenant")
+
+	if !tenantIDPattern.MatchString(tenant.TenantID) || len(strings.TrimSpace(tenant.Name)) == 0 {
+		return nil, ErrInvalidTenant
+	}
+	existing, err := tm.repo.GetTenant(ctx, tenant.TenantID)
+	if err != nil {
+		lLog.Errorf("error while calling tm.repo.GetTenant. got %s", err.Error())
+		return nil, err
+	}
+	if existing != nil {
+		return nil, ErrTenantAlreadyExist
+	}
+	rec := &connector.TenantRecord{
+		TenantID:    tenant.TenantID,
+		Name:        tenant.Name,
+		Description: tenant.Description,
+	}
+	err = tm.repo.InsertTenant(context.WithValue(ctx, contextkeys.UserIDContextKey, creator), rec)
+	if err != nil {
+		lLog.Errorf("error while calling tm.repo.InsertTenant. got %s", err.Error())
+		return nil, err
+	}
+	return tenantFromRecord(rec), nil
+}
+
+// GetTenant returns the tenant of the specified tenant id.
+func (tm *MySQLTenantManager) GetTenant(ctx context.Context, tenantID string) (*Tenant, error) {
+	requestID := ctx.Value(contextkeys.XRequestID).(string)
+	lLog := dbLog.WithField("RequestID", requestID).WithField("function", "GetTenant")
+
+	rec, err := tm.repo.GetTenant(ctx, tenantID)
+	if err != nil {
+		lLog.Errorf("error while calling tm.repo.GetTenant. got %s", err.Error())
+		return nil, err
+	}
+	if rec == nil {
+		return nil, ErrTenantNotFound
+	}
+	return tenantFromRecord(rec), nil
+}
+
+// ListTenants list the tenants sorted by their tenant id, in paginated fashion.
+func (tm *MySQLTenantManager) ListTenants(ctx context.Context, request acccore.PageRequest) (acccore.PageResult, []*Tenant, error) {
+	requestID := ctx.Value(contextkeys.XRequestID).(string)
+	lLog := dbLog.WithField("RequestID", requestID).WithField("function", "ListTenants")
+
+	count, err := tm.repo.CountTenants(ctx)
+	if err != nil {
+		lLog.Errorf("error while calling tm.repo.CountTenants. got %s", err.Error())
+		return acccore.PageResult{}, nil, err
+	}
+	pResult := acccore.PageResultFor(request, count)
+	recs, err := tm.repo.ListTenants(ctx, pResult.Offset, pResult.PageSize)
+	if err != nil {
+		lLog.Errorf("error while calling tm.repo.ListTenants. got %s", err.Error())
+		return acccore.PageResult{}, nil, err
+	}
+	ret := make([]*Tenant, len(recs))
+	for i, rec := range recs {
+		ret[i] = tenantFromRecord(rec)
+	}
+	return pResult, ret, nil
+}
@@ -0,0 +1,510 @@
+package accounting
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hyperjumptech/acccore"
+	"github.com/hyperjumptech/bookkeeping/internal/connector"
+	"github.com/hyperjumptech/bookkeeping/internal/contextkeys"
+	"github.com/hyperjumptech/bookkeeping/internal/middlewares"
+)
+
+const (
+	// WebhookEventHeader is the http header of a delivery telling its event type
+	WebhookEventHeader = "X-Webhook-Event"
+	// WebhookDeliveryHeader is the http header of a delivery telling its delivery id
+	WebhookDeliveryHeader = "X-Webhook-Delivery"
+	// WebhookSignatureHeader is the http header of a delivery carrying the signature of its body,
+	// see middlewares.SignPayload
+	WebhookSignatureHeader = "X-Webhook-Signature"
+
+	// webhookBatchSize is the number of events dispatched, or deliveries attempted, in a single run
+	webhookBatchSize = 100
+	// webhookMaxBackoff caps the delay between two attempts of a delivery
+	webhookMaxBackoff = 24 * time.Hour
+)
+
+// TransactionEventData is a transaction in the data of journal events.
+type TransactionEventData struct {
+	TransactionID string   `json:"transaction_id"`
+	AccountNumber string   `json:"account_number"`
+	Alignment     string   `json:"alignment"`
+	Amount        int64    `json:"amount"`
+	Balance       int64    `json:"balance"`
+	Metadata      Metadata `json:"metadata,omitempty"`
+}
+
+// JournalEventData is the data of the journal.posted and journal.reversed events.
+type JournalEventData struct {
+	JournalID         string                  `json:"journal_id"`
+	Sequence          int64                   `json:"sequence"`
+	ReversedJournalID string                  `json:"reversed_journal_id,omitempty"`
+	Description       string                  `json:"description"`
+	Currency          string                  `json:"currency"`
+	TotalAmount       int64                   `json:"total_amount"`
+	CreatedBy         string                  `json:"created_by"`
+	Metadata          Metadata                `json:"metadata,omitempty"`
+	ExternalReference string                  `json:"external_reference,omitempty"`
+	Transactions      []*TransactionEventData `json:"transactions"`
+}
+
+// AccountEventData is the data of the account.created event.
+type AccountEventData struct {
+	AccountNumber     string `json:"account_number"`
+	Name              string `json:"name"`
+	Description       string `json:"description"`
+	Currency          string `json:"currency"`
+	Alignment         string `json:"alignment"`
+	Coa               string `json:"coa"`
+	Balance           int64  `json:"balance"`
+	CreatedBy         string `json:"created_by"`
+	ExternalReference string `json:"external_reference,omitempty"`
+}
+
+// BalanceLowEventData is the data of the balance.low event.
+type BalanceLowEventData struct {
+	AccountNumber   string `json:"account_number"`
+	Currency        string `json:"currency"`
+	JournalID       string `json:"journal_id"`
+	PreviousBalance int64  `json:"previous_balance"`
+	Balance         int64  `json:"balance"`
+	Threshold       int64  `json:"threshold"`
+}
+
+// publishEvent writes the event into the outbox, using the database transaction carried in the context if any,
+// so the event is only published if the transaction commits.
+func publishEvent(ctx context.Context, repo connector.DBRepository, eventType, creator string, data interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	_, err = repo.InsertOutboxEvent(context.WithValue(ctx, contextkeys.UserIDContextKey, creator), &connector.OutboxEventRecord{
+		EventType: eventType,
+		Payload:   string(payload),
+	})
+	return err
+}
+
+// lowBalanceThreshold returns the balance below which the account balance is low, its minimum balance or zero
+// if it have none.
+func lowBalanceThreshold(account *connector.AccountRecord) int64 {
+	if account.MinBalance != nil {
+		return *account.MinBalance
+	}
+	return 0
+}
+
+// WEBHOOK MANAGER ------------------------------------------------------------------
+
+// NewMySQLWebhookManager returns new sql webhook manager. Deliveries are POSTed using the client, a failed delivery
+// is retried after backoffBase, doubled on every attempt, until maxAttempts is reached.
+func NewMySQLWebhookManager(repo connector.DBRepository, idGenerator acccore.UniqueIDGenerator, client *http.Client, maxAttempts int, backoffBase time.Duration) WebhookManager {
+	return &MySQLWebhookManager{
+		repo:        repo,
+		idGenerator: idGenerator,
+		client:      client,
+		maxAttempts: maxAttempts,
+		backoffBase: backoffBase,
+	}
+}
+
+// MySQLWebhookManager implementation of WebhookManager using the outbox_events, webhook_subscriptions and
+// webhook_deliveries table in MySQL.
+type MySQLWebhookManager struct {
+	repo        connector.DBRepository
+	idGenerator acccore.UniqueIDGenerator
+	client      *http.Client
+	maxAttempts int
+	backoffBase time.Duration
+}
+
+// webhookBackoff returns the delay before the next attempt of a delivery that have made the specified number of attempts.
+func webhookBackoff(base time.Duration, attempts int) time.Duration {
+	if attempts < 1 {
+		attempts = 1
+	}
+	backoff := base
+	for i := 1; i < attempts && backoff < webhookMaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > webhookMaxBackoff {
+		return webhookMaxBackoff
+	}
+	return backoff
+}
+
+// subscribesTo check if the comma separated event types subscribes to the event type.
+func subscribesTo(eventTypes, eventType string) bool {
+	for _, et := range strings.Split(eventTypes, ",") {
+		if et == "*" || et == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// validateWebhookSubscription make sure the url is an absolute http(s) url and the event types are known.
+func validateWebhookSubscription(subscription *WebhookSubscription) error {
+	u, err := url.Parse(subscription.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || len(u.Host) == 0 {
+		return fmt.Errorf("%w : url must be an absolute http or https url", ErrInvalidWebhookSubscription)
+	}
+	if len(subscription.EventTypes) == 0 {
+		return fmt.Errorf("%w : no event type subscribed", ErrInvalidWebhookSubscription)
+	}
+	for _, et := range subscription.EventTypes {
+		known := et == "*"
+		for _, wet := range WebhookEventTypes {
+			known = known || et == wet
+		}
+		if !known {
+			return fmt.Errorf("%w : unknown event type %s", ErrInvalidWebhookSubscription, et)
+		}
+	}
+	return nil
+}
+
+// webhookSubscriptionFromRecord converts the WebhookSubscriptionRecord into WebhookSubscription, without its secret
+func webhookSubscriptionFromRecord(rec *connector.WebhookSubscriptionRecord) *WebhookSubscription {
+	return &WebhookSubscription{
+		SubscriptionID: rec.SubscriptionID,
+		URL:            rec.URL,
+		EventTypes:     strings.Split(rec.EventTypes, ","),
+		Description:    rec.Description,
+		CreateTime:     rec.CreatedAt,
+		CreateBy:       rec.CreatedBy,
+	}
+}
+
+// webhookDeliveryFromRecord converts the WebhookDeliveryRecord into WebhookDelivery
+func webhookDeliveryFromRecord(rec *connector.WebhookDeliveryRecord) *WebhookDelivery {
+	return &WebhookDelivery{
+		DeliveryID:       rec.DeliveryID,
+		EventID:          rec.EventID,
+		SubscriptionID:   rec.SubscriptionID,
+		Status:           rec.Status,
+		Attempts:         rec.Attempts,
+		NextAttemptAt:    rec.NextAttemptAt,
+		LastResponseCode: rec.LastResponseCode,
+		LastError:        rec.LastError,
+		CreatedAt:        rec.CreatedAt,
+		DeliveredAt:      rec.DeliveredAt,
+	}
+}
+
+// CreateSubscription subscribes the url to the event types, and generates the secret the deliveries are signed with.
+func (wm *MySQLWebhookManager) CreateSubscription(ctx context.Context, subscription *WebhookSubscription, creator string) (*WebhookSubscription, error) {
+	requestID := ctx.Value(contextkeys.XRequestID).(string)
+	lLog := dbLog.WithField("RequestID", requestID).WithField("function", "CreateSubscription")
+
+	if err := validateWebhookSubscription(subscription); err != nil {
+		return nil, err
+	}
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		lLog.Errorf("error while generating webhook secret. got %s", err.Error())
+		return nil, err
+	}
+	rec := &connector.WebhookSubscriptionRecord{
+		SubscriptionID: wm.idGenerator.NewUniqueID(),
+		URL:            subscription.URL,
+		EventTypes:     strings.Join(subscription.EventTypes, ","),
+		Secret:         hex.EncodeToString(secret),
+		Description:    subscription.Description,
+	}
+	_, err := wm.repo.InsertWebhookSubscription(context.WithValue(ctx, contextkeys.UserIDContextKey, creator), rec)
+	if err != nil {
+		lLog.Errorf("error while calling wm.repo.InsertWebhookSubscription. got %s", err.Error())
+		return nil, err
+	}
+	ret := webhookSubscriptionFromRecord(rec)
+	ret.Secret = rec.Secret
+	return ret, nil
+}
+
+// GetSubscription returns the webhook subscription, without its secret.
+func (wm *MySQLWebhookManager) GetSubscription(ctx context.Context, subscriptionID string) (*WebhookSubscription, error) {
+	requestID := ctx.Value(contextkeys.XRequestID).(string)
+	lLog := dbLog.WithField("RequestID", requestID).WithField("function", "GetSubscription")
+
+	rec, err := wm.repo.GetWebhookSubscription(ctx, subscriptionID)
+	if err != nil {
+		lLog.Errorf("error while calling wm.repo.GetWebhookSubscription. got %s", err.Error())
+		return nil, err
+	}
+	if rec == nil {
+		return nil, ErrWebhookSubscriptionNotFound
+	}
+	return webhookSubscriptionFromRecord(rec), nil
+}
+
+// ListSubscriptions list all webhook subscriptions, without their secret.
+func (wm *MySQLWebhookManager) ListSubscriptions(ctx context.Context) ([]*WebhookSubscription, error) {
+	requestID := ctx.Value(contextkeys.XRequestID).(string)
+	lLog := dbLog.WithField("RequestID", requestID).WithField("function", "ListSubscriptions")
+
+	recs, err := wm.repo.ListWebhookSubscriptions(ctx)
+	if err != nil {
+		lLog.Errorf("error while calling wm.repo.ListWebhookSubscriptions. got %s", err.Error())
+		return nil, err
+	}
+	ret := make([]*WebhookSubscription, len(recs))
+	for i, rec := range recs {
+		ret[i] = webhookSubscriptionFromRecord(rec)
+	}
+	return ret, nil
+}
+
+// DeleteSubscription removes a webhook subscription, its pending deliveries are moved to the dead letter queue when attempted.
+func (wm *MySQLWebhookManager) DeleteSubscription(ctx context.Context, subscriptionID string) error {
+	requestID := ctx.Value(contextkeys.XRequestID).(string)
+	lLog := dbLog.WithField("RequestID", requestID).WithField("function", "DeleteSubscription")
+
+	deleted, err := wm.repo.DeleteWebhookSubscription(ctx, subscriptionID)
+	if err != nil {
+		lLog.Errorf("error while calling wm.repo.DeleteWebhookSubscription. got %s", err.Error())
+		return err
+	}
+	if !deleted {
+		return ErrWebhookSubscriptionNotFound
+	}
+	return nil
+}
+
+// ListDeliveries list the deliveries of a webhook subscription, optionally of the specified status, latest first.
+func (wm *MySQLWebhookManager) ListDeliveries(ctx context.Context, subscriptionID, status string, request acccore.PageRequest) (acccore.PageResult, []*WebhookDelivery, error) {
+	requestID := ctx.Value(contextkeys.XRequestID).(string)
+	lLog := dbLog.WithField("RequestID", requestID).WithField("function", "ListDeliveries")
+
+	if _, err := wm.GetSubscription(ctx, subscriptionID); err != nil {
+		return acccore.PageResult{}, nil, err
+	}
+	count, err := wm.repo.CountWebhookDeliveries(ctx, subscriptionID, status)
+	if err != nil {
+		lLog.Errorf("error while calling wm.repo.CountWebhookDeliveries. got %s", err.Error())
+		return acccore.PageResult{}, nil, err
+	}
+	pResult := acccore.PageResultFor(request, count)
+	recs, err := wm.repo.ListWebhookDeliveries(ctx, subscriptionID, status, pResult.Offset, pResult.PageSize)
+	if err != nil {
+		lLog.Errorf("error while calling wm.repo.ListWebhookDeliveries. got %s", err.Error())
+		return acccore.PageResult{}, nil, err
+	}
+	ret := make([]*WebhookDelivery, len(recs))
+	for i, rec := range recs {
+		ret[i] = webhookDeliveryFromRecord(rec)
+	}
+	return pResult, ret, nil
+}
+
+// ReplayDelivery schedules a delivered or dead delivery to be delivered again, with its attempts reset.
+func (wm *MySQLWebhookManager) ReplayDelivery(ctx context.Context, deliveryID int64) error {
+	requestID := ctx.Value(contextkeys.XRequestID).(string)
+	lLog := dbLog.WithField("RequestID", requestID).WithField("function", "ReplayDelivery")
+
+	rec, err := wm.repo.GetWebhookDelivery(ctx, deliveryID)
+	if err != nil {
+		lLog.Errorf("error while calling wm.repo.GetWebhookDelivery. got %s", err.Error())
+		return err
+	}
+	if rec == nil {
+		return ErrWebhookDeliveryNotFound
+	}
+	replayed, err := wm.repo.ReplayWebhookDelivery(ctx, deliveryID)
+	if err != nil {
+		lLog.Errorf("error while calling wm.repo.ReplayWebhookDelivery. got %s", err.Error())
+		return err
+	}
+	if !replayed {
+		return ErrWebhookDeliveryPending
+	}
+	return nil
+}
+
+// ReplayDeadDeliveries schedules all dead deliveries of a webhook subscription to be delivered again.
+// It returns the number of deliveries replayed.
+func (wm *MySQLWebhookManager) ReplayDeadDeliveries(ctx context.Context, subscriptionID string) (int, error) {
+	requestID := ctx.Value(contextkeys.XRequestID).(string)
+	lLog := dbLog.WithField("RequestID", requestID).WithField("function", "ReplayDeadDeliveries")
+
+	if _, err := wm.GetSubscription(ctx, subscriptionID); err != nil {
+		return 0, err
+	}
+	count, err := wm.repo.ReplayDeadWebhookDeliveries(ctx, subscriptionID)
+	if err != nil {
+		lLog.Errorf("error while calling wm.repo.ReplayDeadWebhookDeliveries. got %s", err.Error())
+		return 0, err
+	}
+	return count, nil
+}
+
+// DispatchEvents fans out the events in the outbox into a delivery for each subscription of the event type.
+// Each event is dispatched in its own database transaction. It returns the number of events dispatched.
+func (wm *MySQLWebhookManager) DispatchEvents(ctx context.Context) (int, error) {
+	requestID := ctx.Value(contextkeys.XRequestID).(string)
+	lLog := dbLog.WithField("RequestID", requestID).WithField("function", "DispatchEvents")
+
+	events, err := wm.repo.ListUndispatchedOutboxEvents(ctx, webhookBatchSize)
+	if err != nil {
+		lLog.Errorf("error while calling wm.repo.ListUndispatchedOutboxEvents. got %s", err.Error())
+		return 0, err
+	}
+	if len(events) == 0 {
+		return 0, nil
+	}
+	// the events of every tenant are delivered to the subscriptions of that tenant only
+	subscriptions := make(map[string][]*connector.WebhookSubscriptionRecord)
+	count := 0
+	for _, event := range events {
+		tenantCtx := connector.WithTenant(ctx, event.TenantID)
+		if _, ok := subscriptions[event.TenantID]; !ok {
+			subscriptions[event.TenantID], err = wm.repo.ListWebhookSubscriptions(tenantCtx)
+			if err != nil {
+				lLog.Errorf("error while calling wm.repo.ListWebhookSubscriptions. got %s", err.Error())
+				return count, err
+			}
+		}
+		dispatched, err := wm.dispatchEvent(tenantCtx, event, subscriptions[event.TenantID])
+		if err != nil {
+			lLog.Errorf("error while dispatching event %d. got %s", event.EventID, err.Error())
+			return count, err
+		}
+		if dispatched {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// dispatchEvent creates the deliveries of the event and mark it dispatched, in a single database transaction.
+// It returns false if the event is dispatched by another worker.
+func (wm *MySQLWebhookManager) dispatchEvent(ctx context.Context, event *connector.OutboxEventRecord, subscriptions []*connector.WebhookSubscriptionRecord) (bool, error) {
+	tx, err := wm.repo.DB().BeginTxx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	txCtx := context.WithValue(ctx, contextkeys.DBTransactionContextKey, tx)
+	dispatched, err := wm.repo.MarkOutboxEventDispatched(txCtx, event.EventID)
+	if err == nil && dispatched {
+		for _, subscription := range subscriptions {
+			if !subscribesTo(subscription.EventTypes, event.EventType) {
+				continue
+			}
+			_, err = wm.repo.InsertWebhookDelivery(txCtx, &connector.WebhookDeliveryRecord{
+				EventID:        event.EventID,
+				SubscriptionID: subscription.SubscriptionID,
+			})
+			if err != nil {
+				break
+			}
+		}
+	}
+	if err != nil || !dispatched {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			dbLog.Errorf("error rolling back transaction. got %s", rbErr.Error())
+		}
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// DeliverDue attempts every delivery that is due. A failed delivery is retried with exponential backoff,
+// until it runs out of attempts and is moved to the dead letter queue. It returns the number of deliveries delivered.
+func (wm *MySQLWebhookManager) DeliverDue(ctx context.Context) (int, error) {
+	requestID := ctx.Value(contextkeys.XRequestID).(string)
+	lLog := dbLog.WithField("RequestID", requestID).WithField("function", "DeliverDue")
+
+	now := time.Now()
+	deliveries, err := wm.repo.ListDueWebhookDeliveries(ctx, now, webhookBatchSize)
+	if err != nil {
+		lLog.Errorf("error while calling wm.repo.ListDueWebhookDeliveries. got %s", err.Error())
+		return 0, err
+	}
+	count := 0
+	for _, delivery := range deliveries {
+		ctx := connector.WithTenant(ctx, delivery.TenantID)
+		// claim the delivery, postponing its next attempt as if this attempt fails, in case the worker dies before recording the result.
+		attempts := delivery.Attempts + 1
+		claimed, err := wm.repo.ClaimWebhookDelivery(ctx, delivery.DeliveryID, delivery.Attempts, now.Add(webhookBackoff(wm.backoffBase, attempts)))
+		if err != nil {
+			lLog.Errorf("error while calling wm.repo.ClaimWebhookDelivery. got %s", err.Error())
+			return count, err
+		}
+		if !claimed {
+			continue
+		}
+		responseCode, err := wm.attemptDelivery(ctx, delivery)
+		status, lastError := connector.WebhookDeliveryStatusDelivered, ""
+		if err != nil {
+			lLog.Warnf("delivery %d attempt %d failed. got %s", delivery.DeliveryID, attempts, err.Error())
+			status, lastError = connector.WebhookDeliveryStatusPending, err.Error()
+			if attempts >= wm.maxAttempts || errors.Is(err, ErrWebhookSubscriptionNotFound) {
+				status = connector.WebhookDeliveryStatusDead
+			}
+		} else {
+			count++
+		}
+		_, err = wm.repo.UpdateWebhookDeliveryResult(ctx, delivery.DeliveryID, status, responseCode, lastError)
+		if err != nil {
+			lLog.Errorf("error while calling wm.repo.UpdateWebhookDeliveryResult. got %s", err.Error())
+			return count, err
+		}
+	}
+	return count, nil
+}
+
+// attemptDelivery POSTs the event of the delivery to its subscription url, signed with the subscription secret.
+// It returns the http status of the response, and an error if the subscriber did not acknowledge the event with a 2xx status.
+func (wm *MySQLWebhookManager) attemptDelivery(ctx context.Context, delivery *connector.WebhookDeliveryRecord) (int, error) {
+	subscription, err := wm.repo.GetWebhookSubscription(ctx, delivery.SubscriptionID)
+	if err != nil {
+		return 0, err
+	}
+	if subscription == nil {
+		return 0, ErrWebhookSubscriptionNotFound
+	}
+	event, err := wm.repo.GetOutboxEvent(ctx, delivery.EventID)
+	if err != nil {
+		return 0, err
+	}
+	if event == nil {
+		return 0, fmt.Errorf("event %d not found", delivery.EventID)
+	}
+	body, err := json.Marshal(&WebhookEvent{
+		EventID:   event.EventID,
+		EventType: event.EventType,
+		CreatedAt: event.CreatedAt,
+		Data:      json.RawMessage(event.Payload),
+	})
+	if err != nil {
+		return 0, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, subscription.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookEventHeader, event.EventType)
+	req.Header.Set(WebhookDeliveryHeader, strconv.FormatInt(delivery.DeliveryID, 10))
+	req.Header.Set(WebhookSignatureHeader, middlewares.SignPayload(body, subscription.Secret, time.Now()))
+	resp, err := wm.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("subscriber responded with status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,392 @@
+package accounting
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	// TemplateParameterAccount is the type of posting template parameter that holds an account number
+	TemplateParameterAccount = "ACCOUNT"
+	// TemplateParameterNumber is the type of posting template parameter that holds a number, such as an amount or a rate
+	TemplateParameterNumber = "NUMBER"
+)
+
+var (
+	templateNameRegex       = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+	templateIdentifierRegex = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+)
+
+// PostingTemplate is a named rule that expands its parameters into the transactions of a balanced journal.
+// Variables and line amounts are arithmetic expressions (+ - * / and parentheses) over the NUMBER parameters
+// and the variables declared before them. Each of them is rounded half away from zero into an integer amount.
+type PostingTemplate struct {
+	Name        string                      `json:"name"`
+	Description string                      `json:"description"`
+	Parameters  []*PostingTemplateParameter `json:"parameters"`
+	Variables   []*PostingTemplateVariable  `json:"variables,omitempty"`
+	Lines       []*PostingTemplateLine      `json:"lines"`
+	CreateTime  time.Time                   `json:"created_at"`
+	CreateBy    string                      `json:"created_by"`
+	UpdateTime  time.Time                   `json:"updated_at"`
+	UpdateBy    string                      `json:"updated_by"`
+}
+
+// PostingTemplateParameter is a parameter the caller must provide when posting using the template
+type PostingTemplateParameter struct {
+	Name string `json:"name"`
+	// Type is either ACCOUNT or NUMBER
+	Type        string `json:"type"`
+	Description string `json:"description,omitempty"`
+}
+
+// PostingTemplateVariable is an amount computed from the parameters, eg. fee = amount * fee_rate
+type PostingTemplateVariable struct {
+	Name       string `json:"name"`
+	Expression string `json:"expression"`
+}
+
+// PostingTemplateLine is a transaction of the expanded journal. The account is either taken from an ACCOUNT
+// parameter or is a fixed account number. Lines of zero amount are left out of the journal.
+type PostingTemplateLine struct {
+	AccountParameter string `json:"account_parameter,omitempty"`
+	AccountNumber    string `json:"account_number,omitempty"`
+	Alignment        string `json:"alignment"`
+	Amount           string `json:"amount"`
+	Description      string `json:"description,omitempty"`
+}
+
+// Validate make sure the template is well formed, so it can be expanded.
+func (tpl *PostingTemplate) Validate() error {
+	if !templateNameRegex.MatchString(tpl.Name) {
+		return fmt.Errorf("%w : name must be 1 to 64 letters, digits, - or _", ErrInvalidPostingTemplate)
+	}
+	if len(tpl.Lines) < 2 {
+		return fmt.Errorf("%w : template must have at least 2 lines", ErrInvalidPostingTemplate)
+	}
+	accounts := make(map[string]bool)
+	numbers := make(map[string]bool)
+	for _, param := range tpl.Parameters {
+		if !templateIdentifierRegex.MatchString(param.Name) {
+			return fmt.Errorf("%w : invalid parameter name %s", ErrInvalidPostingTemplate, param.Name)
+		}
+		if accounts[param.Name] || numbers[param.Name] {
+			return fmt.Errorf("%w : duplicate parameter %s", ErrInvalidPostingTemplate, param.Name)
+		}
+		switch strings.ToUpper(param.Type) {
+		case TemplateParameterAccount:
+			accounts[param.Name] = true
+		case TemplateParameterNumber:
+			numbers[param.Name] = true
+		default:
+			return fmt.Errorf("%w : parameter %s type must be ACCOUNT or NUMBER", ErrInvalidPostingTemplate, param.Name)
+		}
+	}
+	for _, variable := range tpl.Variables {
+		if !templateIdentifierRegex.MatchString(variable.Name) {
+			return fmt.Errorf("%w : invalid variable name %s", ErrInvalidPostingTemplate, variable.Name)
+		}
+		if accounts[variable.Name] || numbers[variable.Name] {
+			return fmt.Errorf("%w : variable %s is already declared", ErrInvalidPostingTemplate, variable.Name)
+		}
+		if err := validateExpression(variable.Expression, numbers); err != nil {
+			return fmt.Errorf("%w : variable %s : %s", ErrInvalidPostingTemplate, variable.Name, err.Error())
+		}
+		numbers[variable.Name] = true
+	}
+	for i, line := range tpl.Lines {
+		if (len(line.AccountParameter) == 0) == (len(line.AccountNumber) == 0) {
+			return fmt.Errorf("%w : line %d must have either account_parameter or account_number", ErrInvalidPostingTemplate, i+1)
+		}
+		if len(line.AccountParameter) > 0 && !accounts[line.AccountParameter] {
+			return fmt.Errorf("%w : line %d account_parameter %s is not an ACCOUNT parameter", ErrInvalidPostingTemplate, i+1, line.AccountParameter)
+		}
+		if alignment := strings.ToUpper(line.Alignment); alignment != "DEBIT" && alignment != "CREDIT" {
+			return fmt.Errorf("%w : line %d alignment must be DEBIT or CREDIT", ErrInvalidPostingTemplate, i+1)
+		}
+		if err := validateExpression(line.Amount, numbers); err != nil {
+			return fmt.Errorf("%w : line %d amount : %s", ErrInvalidPostingTemplate, i+1, err.Error())
+		}
+	}
+	return nil
+}
+
+// Expand turns the template into journal transactions using the parameter values. ACCOUNT parameters must be
+// strings, NUMBER parameters must be numbers or strings of decimal number.
+func (tpl *PostingTemplate) Expand(params map[string]interface{}) ([]*TransactionRequest, error) {
+	accounts := make(map[string]string)
+	numbers := make(map[string]*big.Rat)
+	for _, param := range tpl.Parameters {
+		value, ok := params[param.Name]
+		if !ok {
+			return nil, fmt.Errorf("%w : missing %s", ErrInvalidTemplateParameter, param.Name)
+		}
+		if strings.ToUpper(param.Type) == TemplateParameterAccount {
+			account, ok := value.(string)
+			if !ok || len(account) == 0 {
+				return nil, fmt.Errorf("%w : %s must be an account number", ErrInvalidTemplateParameter, param.Name)
+			}
+			accounts[param.Name] = account
+			continue
+		}
+		var number *big.Rat
+		switch v := value.(type) {
+		case json.Number:
+			number, ok = new(big.Rat).SetString(v.String())
+		case string:
+			number, ok = new(big.Rat).SetString(v)
+		case float64:
+			number, ok = new(big.Rat).SetString(fmt.Sprint(v))
+		case int64:
+			number, ok = new(big.Rat).SetInt64(v), true
+		case int:
+			number, ok = new(big.Rat).SetInt64(int64(v)), true
+		default:
+			ok = false
+		}
+		if !ok {
+			return nil, fmt.Errorf("%w : %s must be a number", ErrInvalidTemplateParameter, param.Name)
+		}
+		numbers[param.Name] = number
+	}
+	for _, variable := range tpl.Variables {
+		amount, err := evaluateAmount(variable.Expression, numbers)
+		if err != nil {
+			return nil, fmt.Errorf("%w : variable %s : %s", ErrInvalidTemplateParameter, variable.Name, err.Error())
+		}
+		numbers[variable.Name] = new(big.Rat).SetInt64(amount)
+	}
+
+	ret := make([]*TransactionRequest, 0, len(tpl.Lines))
+	for i, line := range tpl.Lines {
+		amount, err := evaluateAmount(line.Amount, numbers)
+		if err != nil {
+			return nil, fmt.Errorf("%w : line %d : %s", ErrInvalidTemplateParameter, i+1, err.Error())
+		}
+		if amount < 0 {
+			return nil, fmt.Errorf("%w : line %d amount is negative", ErrInvalidTemplateParameter, i+1)
+		}
+		if amount == 0 {
+			continue
+		}
+		accountNumber := line.AccountNumber
+		if len(line.AccountParameter) > 0 {
+			accountNumber = accounts[line.AccountParameter]
+		}
+		ret = append(ret, &TransactionRequest{
+			AccountNumber: accountNumber,
+			Description:   line.Description,
+			Alignment:     strings.ToUpper(line.Alignment),
+			Amount:        amount,
+		})
+	}
+	return ret, nil
+}
+
+// evaluateAmount evaluates the expression and rounds the result half away from zero into an integer amount.
+func evaluateAmount(expression string, values map[string]*big.Rat) (int64, error) {
+	node, err := parseExpression(expression)
+	if err != nil {
+		return 0, err
+	}
+	result, err := node.eval(values)
+	if err != nil {
+		return 0, err
+	}
+	quo, rem := new(big.Int).QuoRem(result.Num(), result.Denom(), new(big.Int))
+	if new(big.Int).Mul(new(big.Int).Abs(rem), big.NewInt(2)).Cmp(result.Denom()) >= 0 {
+		quo.Add(quo, big.NewInt(int64(result.Sign())))
+	}
+	if !quo.IsInt64() {
+		return 0, fmt.Errorf("amount is out of range")
+	}
+	return quo.Int64(), nil
+}
+
+// validateExpression make sure the expression is parsable and only refers to the known identifiers.
+func validateExpression(expression string, known map[string]bool) error {
+	node, err := parseExpression(expression)
+	if err != nil {
+		return err
+	}
+	for _, ident := range node.identifiers(nil) {
+		if !known[ident] {
+			return fmt.Errorf("unknown identifier %s", ident)
+		}
+	}
+	return nil
+}
+
+// exprNode is a node of a parsed arithmetic expression
+type exprNode struct {
+	op          byte // 0 for leaf, one of + - * / for binary operation, 'n' for negation
+	number      *big.Rat
+	ident       string
+	left, right *exprNode
+}
+
+func (n *exprNode) eval(values map[string]*big.Rat) (*big.Rat, error) {
+	switch n.op {
+	case 0:
+		if n.number != nil {
+			return n.number, nil
+		}
+		value, ok := values[n.ident]
+		if !ok {
+			return nil, fmt.Errorf("unknown identifier %s", n.ident)
+		}
+		return value, nil
+	case 'n':
+		value, err := n.left.eval(values)
+		if err != nil {
+			return nil, err
+		}
+		return new(big.Rat).Neg(value), nil
+	}
+	left, err := n.left.eval(values)
+	if err != nil {
+		return nil, err
+	}
+	right, err := n.right.eval(values)
+	if err != nil {
+		return nil, err
+	}
+	switch n.op {
+	case '+':
+		return new(big.Rat).Add(left, right), nil
+	case '-':
+		return new(big.Rat).Sub(left, right), nil
+	case '*':
+		return new(big.Rat).Mul(left, right), nil
+	default:
+		if right.Sign() == 0 {
+			return nil, fmt.Errorf("division by zero")
+		}
+		return new(big.Rat).Quo(left, right), nil
+	}
+}
+
+func (n *exprNode) identifiers(into []string) []string {
+	if n == nil {
+		return into
+	}
+	if n.op == 0 && n.number == nil {
+		into = append(into, n.ident)
+	}
+	return n.right.identifiers(n.left.identifiers(into))
+}
+
+// exprParser is a recursive descent parser of arithmetic expression
+type exprParser struct {
+	input string
+	pos   int
+}
+
+func parseExpression(expression string) (*exprNode, error) {
+	p := &exprParser{input: expression}
+	node, err := p.parseSum()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpaces()
+	if p.pos < len(p.input) {
+		return nil, fmt.Errorf("unexpected %q at position %d", p.input[p.pos], p.pos+1)
+	}
+	return node, nil
+}
+
+func (p *exprParser) skipSpaces() {
+	for p.pos < len(p.input) && p.input[p.pos] == ' ' {
+		p.pos++
+	}
+}
+
+func (p *exprParser) parseSum() (*exprNode, error) {
+	left, err := p.parseProduct()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		p.skipSpaces()
+		if p.pos >= len(p.input) || (p.input[p.pos] != '+' && p.input[p.pos] != '-') {
+			return left, nil
+		}
+		op := p.input[p.pos]
+		p.pos++
+		right, err := p.parseProduct()
+		if err != nil {
+			return nil, err
+		}
+		left = &exprNode{op: op, left: left, right: right}
+	}
+}
+
+func (p *exprParser) parseProduct() (*exprNode, error) {
+	left, err := p.parseFactor()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		p.skipSpaces()
+		if p.pos >= len(p.input) || (p.input[p.pos] != '*' && p.input[p.pos] != '/') {
+			return left, nil
+		}
+		op := p.input[p.pos]
+		p.pos++
+		right, err := p.parseFactor()
+		if err != nil {
+			return nil, err
+		}
+		left = &exprNode{op: op, left: left, right: right}
+	}
+}
+
+func (p *exprParser) parseFactor() (*exprNode, error) {
+	p.skipSpaces()
+	if p.pos >= len(p.input) {
+		return nil, fmt.Errorf("unexpected end of expression")
+	}
+	c := p.input[p.pos]
+	switch {
+	case c == '-':
+		p.pos++
+		operand, err := p.parseFactor()
+		if err != nil {
+			return nil, err
+		}
+		return &exprNode{op: 'n', left: operand}, nil
+	case c == '(':
+		p.pos++
+		node, err := p.parseSum()
+		if err != nil {
+			return nil, err
+		}
+		p.skipSpaces()
+		if p.pos >= len(p.input) || p.input[p.pos] != ')' {
+			return nil, fmt.Errorf("missing closing parenthesis")
+		}
+		p.pos++
+		return node, nil
+	case (c >= '0' && c <= '9') || c == '.':
+		start := p.pos
+		for p.pos < len(p.input) && ((p.input[p.pos] >= '0' && p.input[p.pos] <= '9') || p.input[p.pos] == '.') {
+			p.pos++
+		}
+		number, ok := new(big.Rat).SetString(p.input[start:p.pos])
+		if !ok {
+			return nil, fmt.Errorf("invalid number %s", p.input[start:p.pos])
+		}
+		return &exprNode{number: number}, nil
+	case c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z'):
+		start := p.pos
+		for p.pos < len(p.input) && templateIdentifierRegex.MatchString(p.input[start:p.pos+1]) {
+			p.pos++
+		}
+		return &exprNode{ident: p.input[start:p.pos]}, nil
+	}
+	return nil, fmt.Errorf("unexpected %q at position %d", c, p.pos+1)
+}
@@ -0,0 +1,230 @@
+package accounting
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/hyperjumptech/bookkeeping/internal/contextkeys"
+	"github.com/hyperjumptech/bookkeeping/internal/helpers"
+	"github.com/hyperjumptech/bookkeeping/internal/middlewares"
+)
+
+// PostingTemplateRequest is the structure of request body for saving a posting template
+type PostingTemplateRequest struct {
+	Description string                      `json:"description"`
+	Parameters  []*PostingTemplateParameter `json:"parameters"`
+	Variables   []*PostingTemplateVariable  `json:"variables"`
+	Lines       []*PostingTemplateLine      `json:"lines"`
+	Creator     string                      `json:"creator"`
+}
+
+// JournalFromTemplateRequest is the structure of request body for creating a journal using a posting template
+type JournalFromTemplateRequest struct {
+	// Description is optional, when not specified the template description is used
+	Description string                 `json:"description"`
+	Parameters  map[string]interface{} `json:"parameters"`
+	Creator     string                 `json:"creator"`
+}
+
+// postingTemplateErrorResponse writes the response for errors returned by PostingTemplateMgr
+func postingTemplateErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, ErrPostingTemplateNotFound):
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 404, "posting template not found", err.Error(), 3)
+	case errors.Is(err, ErrInvalidPostingTemplate), errors.Is(err, ErrInvalidTemplateParameter):
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 400, "request rejected", err.Error(), 0)
+	default:
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 500, "backend error", err.Error(), 2)
+	}
+}
+
+// SavePostingTemplate creates or replaces a posting template
+func SavePostingTemplate(w http.ResponseWriter, r *http.Request) {
+	requestID := r.Context().Value(contextkeys.XRequestID).(string)
+	llog := restLog.WithField("RequestID", requestID).WithField("function", "SavePostingTemplate")
+	if r.Context().Err() != nil {
+		llog.Errorf("context is canceled : %s", r.Context().Err().Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 500, "request is canceled", "request is canceled", 0)
+		return
+	}
+	if PostingTemplateMgr == nil {
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 501, "not implemented", "posting template manager is not available", 0)
+		return
+	}
+
+	m, err := helpers.ParsePathParams("/api/v1/posting-templates/{Name}", r.URL.Path)
+	if err != nil {
+		llog.Errorf("error while processing path template /api/v1/posting-templates/{Name}. got : %s", err.Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 404, "path not found", "path not found", 1)
+		return
+	}
+
+	bodyByte, err := io.ReadAll(r.Body)
+	if err != nil {
+		llog.Errorf("got %s", err.Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 500, "error reading body", err.Error(), 0)
+		return
+	}
+	tplReq := &PostingTemplateRequest{}
+	err = json.Unmarshal(bodyByte, tplReq)
+	if err != nil {
+		llog.Errorf("got %s", err.Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 400, "malformed json body", err.Error(), 0)
+		return
+	}
+	tplReq.Creator = requestCreator(r, tplReq.Creator)
+
+	if before, err := PostingTemplateMgr.GetPostingTemplate(r.Context(), m["Name"]); err == nil {
+		middlewares.AuditSnapshot(r.Context(), before, nil)
+	}
+	tpl, err := PostingTemplateMgr.SavePostingTemplate(r.Context(), &PostingTemplate{
+		Name:        m["Name"],
+		Description: tplReq.Description,
+		Parameters:  tplReq.Parameters,
+		Variables:   tplReq.Variables,
+		Lines:       tplReq.Lines,
+	}, tplReq.Creator)
+	if err != nil {
+		llog.Errorf("error while calling PostingTemplateMgr.SavePostingTemplate. got : %s", err.Error())
+		postingTemplateErrorResponse(w, r, err)
+		return
+	}
+	helpers.HTTPResponseBuilder(r.Context(), w, r, 200, "posting template "+tpl.Name, tpl, 0)
+}
+
+// GetPostingTemplate fetches a posting template from its name
+func GetPostingTemplate(w http.ResponseWriter, r *http.Request) {
+	requestID := r.Context().Value(contextkeys.XRequestID).(string)
+	llog := restLog.WithField("RequestID", requestID).WithField("function", "GetPostingTemplate")
+	if r.Context().Err() != nil {
+		llog.Errorf("context is canceled : %s", r.Context().Err().Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 500, "request is canceled", "request is canceled", 0)
+		return
+	}
+	if PostingTemplateMgr == nil {
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 501, "not implemented", "posting template manager is not available", 0)
+		return
+	}
+
+	m, err := helpers.ParsePathParams("/api/v1/posting-templates/{Name}", r.URL.Path)
+	if err != nil {
+		llog.Errorf("error while processing path template /api/v1/posting-templates/{Name}. got : %s", err.Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 404, "path not found", "path not found", 1)
+		return
+	}
+
+	tpl, err := PostingTemplateMgr.GetPostingTemplate(r.Context(), m["Name"])
+	if err != nil {
+		llog.Errorf("error while calling PostingTemplateMgr.GetPostingTemplate. got : %s", err.Error())
+		postingTemplateErrorResponse(w, r, err)
+		return
+	}
+	helpers.HTTPResponseBuilder(r.Context(), w, r, 200, "posting template "+tpl.Name, tpl, 0)
+}
+
+// ListPostingTemplates lists all posting templates
+func ListPostingTemplates(w http.ResponseWriter, r *http.Request) {
+	requestID := r.Context().Value(contextkeys.XRequestID).(string)
+	llog := restLog.WithField("RequestID", requestID).WithField("function", "ListPostingTemplates")
+	if r.Context().Err() != nil {
+		llog.Errorf("context is canceled : %s", r.Context().Err().Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 500, "request is canceled", "request is canceled", 0)
+		return
+	}
+	if PostingTemplateMgr == nil {
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 501, "not implemented", "posting template manager is not available", 0)
+		return
+	}
+
+	tpls, err := PostingTemplateMgr.ListPostingTemplates(r.Context())
+	if err != nil {
+		llog.Errorf("error while calling PostingTemplateMgr.ListPostingTemplates. got : %s", err.Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 500, "backend error", err.Error(), 2)
+		return
+	}
+	helpers.HTTPResponseBuilder(r.Context(), w, r, 200, "OK", tpls, 0)
+}
+
+// DeletePostingTemplate removes a posting template
+func DeletePostingTemplate(w http.ResponseWriter, r *http.Request) {
+	requestID := r.Context().Value(contextkeys.XRequestID).(string)
+	llog := restLog.WithField("RequestID", requestID).WithField("function", "DeletePostingTemplate")
+	if r.Context().Err() != nil {
+		llog.Errorf("context is canceled : %s", r.Context().Err().Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 500, "request is canceled", "request is canceled", 0)
+		return
+	}
+	if PostingTemplateMgr == nil {
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 501, "not implemented", "posting template manager is not available", 0)
+		return
+	}
+
+	m, err := helpers.ParsePathParams("/api/v1/posting-templates/{Name}", r.URL.Path)
+	if err != nil {
+		llog.Errorf("error while processing path template /api/v1/posting-templates/{Name}. got : %s", err.Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 404, "path not found", "path not found", 1)
+		return
+	}
+
+	if before, err := PostingTemplateMgr.GetPostingTemplate(r.Context(), m["Name"]); err == nil {
+		middlewares.AuditSnapshot(r.Context(), before, nil)
+	}
+	err = PostingTemplateMgr.DeletePostingTemplate(r.Context(), m["Name"])
+	if err != nil {
+		llog.Errorf("error while calling PostingTemplateMgr.DeletePostingTemplate. got : %s", err.Error())
+		postingTemplateErrorResponse(w, r, err)
+		return
+	}
+	helpers.HTTPResponseBuilder(r.Context(), w, r, 200, "posting template "+m["Name"]+" deleted", m["Name"], 0)
+}
+
+// CreateJournalFromTemplate creates a journal by expanding a posting template with the given parameters
+func CreateJournalFromTemplate(w http.ResponseWriter, r *http.Request) {
+	requestID := r.Context().Value(contextkeys.XRequestID).(string)
+	llog := restLog.WithField("RequestID", requestID).WithField("function", "CreateJournalFromTemplate")
+	if r.Context().Err() != nil {
+		llog.Errorf("context is canceled : %s", r.Context().Err().Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 500, "request is canceled", "request is canceled", 0)
+		return
+	}
+	if PostingTemplateMgr == nil {
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 501, "not implemented", "posting template manager is not available", 0)
+		return
+	}
+
+	m, err := helpers.ParsePathParams("/api/v1/journals/from-template/{Name}", r.URL.Path)
+	if err != nil {
+		llog.Errorf("error while processing path template /api/v1/journals/from-template/{Name}. got : %s", err.Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 404, "path not found", "path not found", 1)
+		return
+	}
+
+	bodyByte, err := io.ReadAll(r.Body)
+	if err != nil {
+		llog.Errorf("got %s", err.Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 500, "error reading body", err.Error(), 0)
+		return
+	}
+	fromReq := &JournalFromTemplateRequest{}
+	// keep the numbers as json.Number so big amounts are not rounded into float
+	decoder := json.NewDecoder(bytes.NewReader(bodyByte))
+	decoder.UseNumber()
+	err = decoder.Decode(fromReq)
+	if err != nil {
+		llog.Errorf("got %s", err.Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 400, "malformed json body", err.Error(), 0)
+		return
+	}
+	fromReq.Creator = requestCreator(r, fromReq.Creator)
+
+	journalReq, err := PostingTemplateMgr.ExpandPostingTemplate(r.Context(), m["Name"], fromReq.Parameters, fromReq.Description, fromReq.Creator)
+	if err != nil {
+		llog.Errorf("error while calling PostingTemplateMgr.ExpandPostingTemplate. got : %s", err.Error())
+		postingTemplateErrorResponse(w, r, err)
+		return
+	}
+	postJournalRequest(w, r, journalReq)
+}
@@ -0,0 +1,136 @@
+package accounting
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func purchaseWithFeeTemplate() *PostingTemplate {
+	return &PostingTemplate{
+		Name:        "purchase-with-fee",
+		Description: "purchase with fee",
+		Parameters: []*PostingTemplateParameter{
+			{Name: "source", Type: "ACCOUNT"},
+			{Name: "destination", Type: "ACCOUNT"},
+			{Name: "amount", Type: "NUMBER"},
+			{Name: "fee_rate", Type: "NUMBER"},
+		},
+		Variables: []*PostingTemplateVariable{
+			{Name: "fee", Expression: "amount * fee_rate"},
+		},
+		Lines: []*PostingTemplateLine{
+			{AccountParameter: "source", Alignment: "DEBIT", Amount: "amount"},
+			{AccountParameter: "destination", Alignment: "CREDIT", Amount: "amount - fee"},
+			{AccountNumber: "FEE-INCOME", Alignment: "CREDIT", Amount: "fee"},
+		},
+	}
+}
+
+func TestPostingTemplate_Validate(t *testing.T) {
+	if err := purchaseWithFeeTemplate().Validate(); err != nil {
+		t.Errorf("expecting valid template, got %s", err.Error())
+	}
+	testData := []struct {
+		name   string
+		modify func(tpl *PostingTemplate)
+	}{
+		{"invalid name", func(tpl *PostingTemplate) { tpl.Name = "purchase with fee" }},
+		{"single line", func(tpl *PostingTemplate) { tpl.Lines = tpl.Lines[:1] }},
+		{"unknown parameter type", func(tpl *PostingTemplate) { tpl.Parameters[2].Type = "TEXT" }},
+		{"duplicate parameter", func(tpl *PostingTemplate) { tpl.Parameters[1].Name = "source" }},
+		{"variable shadowing parameter", func(tpl *PostingTemplate) { tpl.Variables[0].Name = "amount" }},
+		{"unknown identifier", func(tpl *PostingTemplate) { tpl.Lines[2].Amount = "fees" }},
+		{"account used as number", func(tpl *PostingTemplate) { tpl.Lines[0].Amount = "source" }},
+		{"malformed expression", func(tpl *PostingTemplate) { tpl.Lines[0].Amount = "amount *" }},
+		{"unbalanced parenthesis", func(tpl *PostingTemplate) { tpl.Lines[0].Amount = "(amount" }},
+		{"both account parameter and number", func(tpl *PostingTemplate) { tpl.Lines[0].AccountNumber = "X" }},
+		{"number parameter as account", func(tpl *PostingTemplate) { tpl.Lines[0].AccountParameter = "amount" }},
+		{"invalid alignment", func(tpl *PostingTemplate) { tpl.Lines[0].Alignment = "LEFT" }},
+	}
+	for _, td := range testData {
+		tpl := purchaseWithFeeTemplate()
+		td.modify(tpl)
+		if err := tpl.Validate(); !errors.Is(err, ErrInvalidPostingTemplate) {
+			t.Errorf("%s : expecting ErrInvalidPostingTemplate, got %v", td.name, err)
+		}
+	}
+}
+
+func TestPostingTemplate_Expand(t *testing.T) {
+	tpl := purchaseWithFeeTemplate()
+	transactions, err := tpl.Expand(map[string]interface{}{
+		"source":      "CUSTOMER",
+		"destination": "MERCHANT",
+		"amount":      json.Number("1005"),
+		"fee_rate":    "0.015",
+	})
+	if err != nil {
+		t.Error(err.Error())
+		t.FailNow()
+	}
+	expect := []TransactionRequest{
+		{AccountNumber: "CUSTOMER", Alignment: "DEBIT", Amount: 1005},
+		{AccountNumber: "MERCHANT", Alignment: "CREDIT", Amount: 990},
+		{AccountNumber: "FEE-INCOME", Alignment: "CREDIT", Amount: 15},
+	}
+	if len(transactions) != len(expect) {
+		t.Fatalf("expecting %d transactions, got %d", len(expect), len(transactions))
+	}
+	for i, trx := range transactions {
+		if !reflect.DeepEqual(*trx, expect[i]) {
+			t.Errorf("transaction %d : expecting %+v, got %+v", i, expect[i], *trx)
+		}
+	}
+
+	// a fee of zero leaves the fee line out of the journal
+	transactions, err = tpl.Expand(map[string]interface{}{
+		"source": "CUSTOMER", "destination": "MERCHANT", "amount": 1000, "fee_rate": 0,
+	})
+	if err != nil || len(transactions) != 2 {
+		t.Errorf("expecting 2 transactions without fee, got %d %v", len(transactions), err)
+	}
+
+	testData := []struct {
+		name   string
+		params map[string]interface{}
+	}{
+		{"missing parameter", map[string]interface{}{"source": "CUSTOMER", "destination": "MERCHANT", "amount": 1000}},
+		{"account is not a string", map[string]interface{}{"source": 1, "destination": "MERCHANT", "amount": 1000, "fee_rate": 0}},
+		{"amount is not a number", map[string]interface{}{"source": "CUSTOMER", "destination": "MERCHANT", "amount": "a lot", "fee_rate": 0}},
+		{"negative line amount", map[string]interface{}{"source": "CUSTOMER", "destination": "MERCHANT", "amount": 1000, "fee_rate": 2}},
+	}
+	for _, td := range testData {
+		if _, err := tpl.Expand(td.params); !errors.Is(err, ErrInvalidTemplateParameter) {
+			t.Errorf("%s : expecting ErrInvalidTemplateParameter, got %v", td.name, err)
+		}
+	}
+}
+
+func TestEvaluateAmount(t *testing.T) {
+	testData := []struct {
+		expression string
+		expect     int64
+	}{
+		{"1 + 2 * 3", 7},
+		{"(1 + 2) * 3", 9},
+		{"10 / 4", 3},
+		{"-10 / 4", -3},
+		{"10 / 3", 3},
+		{"0.5", 1},
+		{"-0.5", -1},
+		{"100 - -5", 105},
+	}
+	for _, td := range testData {
+		got, err := evaluateAmount(td.expression, nil)
+		if err != nil {
+			t.Errorf("%s : got error %s", td.expression, err.Error())
+		} else if got != td.expect {
+			t.Errorf("%s : expecting %d, got %d", td.expression, td.expect, got)
+		}
+	}
+	if _, err := evaluateAmount("1 / 0", nil); err == nil {
+		t.Errorf("expecting division by zero error")
+	}
+}
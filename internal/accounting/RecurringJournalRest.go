@@ -0,0 +1,274 @@
+package accounting
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/hyperjumptech/acccore"
+	"github.com/hyperjumptech/bookkeeping/internal/contextkeys"
+	"github.com/hyperjumptech/bookkeeping/internal/helpers"
+	"github.com/hyperjumptech/bookkeeping/internal/middlewares"
+)
+
+// CreateRecurringJournalRequest is the structure of request body for creating a recurring journal
+type CreateRecurringJournalRequest struct {
+	// CronExpression is a standard 5 field cron expression or descriptor such as @daily or @every 1h
+	CronExpression string `json:"cron_expression"`
+	CreateJournalRequest
+}
+
+// PaginatedRecurringJournalsResponse is the recurring journal response paginated
+type PaginatedRecurringJournalsResponse struct {
+	RecurringJournals []*RecurringJournal `json:"recurring_journals"`
+	Pagination        *PageResultBody     `json:"pagination"`
+}
+
+// PaginatedRecurringJournalRunsResponse is the recurring journal run history response paginated
+type PaginatedRecurringJournalRunsResponse struct {
+	Runs       []*RecurringJournalRun `json:"runs"`
+	Pagination *PageResultBody        `json:"pagination"`
+}
+
+// pageRequestFromQuery reads the mandatory page and size query parameters
+func pageRequestFromQuery(r *http.Request) (acccore.PageRequest, string) {
+	pageA, pOk := r.URL.Query()["page"]
+	sizeA, sOk := r.URL.Query()["size"]
+	if !pOk || !sOk || len(pageA[0]) == 0 || len(sizeA[0]) == 0 {
+		return acccore.PageRequest{}, "either page or size is missing"
+	}
+	page, perr := strconv.Atoi(pageA[0])
+	size, serr := strconv.Atoi(sizeA[0])
+	if perr != nil || serr != nil {
+		return acccore.PageRequest{}, "either page, size is not number"
+	}
+	return acccore.PageRequest{
+		PageNo:   page,
+		ItemSize: size,
+	}, ""
+}
+
+// recurringJournalErrorResponse writes the response for errors returned by RecurringJournalMgr
+func recurringJournalErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, ErrRecurringJournalNotFound):
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 404, "recurring journal not found", err.Error(), 3)
+	case errors.Is(err, ErrInvalidCronExpression),
+		errors.Is(err, acccore.ErrJournalMissingAuthor),
+		errors.Is(err, acccore.ErrJournalNoTransaction),
+		errors.Is(err, acccore.ErrJournalNotBalance),
+		errors.Is(err, acccore.ErrJournalTransactionAccountNotPersist),
+		errors.Is(err, ErrInvalidMetadata),
+		errors.Is(err, ErrInvalidExternalReference),
+		errors.Is(err, ErrJournalRequiresApproval):
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 400, "recurring journal rejected", err.Error(), 0)
+	default:
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 500, "backend error", err.Error(), 2)
+	}
+}
+
+// CreateRecurringJournal creates a journal to be posted on a schedule
+func CreateRecurringJournal(w http.ResponseWriter, r *http.Request) {
+	requestID := r.Context().Value(contextkeys.XRequestID).(string)
+	llog := restLog.WithField("RequestID", requestID).WithField("function", "CreateRecurringJournal")
+	if r.Context().Err() != nil {
+		llog.Errorf("context is canceled : %s", r.Context().Err().Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 500, "request is canceled", "request is canceled", 0)
+		return
+	}
+	if RecurringJournalMgr == nil {
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 501, "not implemented", "recurring journal manager is not available", 0)
+		return
+	}
+
+	bodyByte, err := io.ReadAll(r.Body)
+	if err != nil {
+		llog.Errorf("got %s", err.Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 500, "error reading body", err.Error(), 0)
+		return
+	}
+	createReq := &CreateRecurringJournalRequest{}
+	err = json.Unmarshal(bodyByte, createReq)
+	if err != nil {
+		llog.Errorf("got %s", err.Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 400, "malformed json body", err.Error(), 0)
+		return
+	}
+	createReq.Creator = requestCreator(r, createReq.Creator)
+
+	rj, err := RecurringJournalMgr.CreateRecurringJournal(r.Context(), createReq.CronExpression, &createReq.CreateJournalRequest)
+	if err != nil {
+		llog.Errorf("error while calling RecurringJournalMgr.CreateRecurringJournal. got : %s", err.Error())
+		recurringJournalErrorResponse(w, r, err)
+		return
+	}
+	helpers.HTTPResponseBuilder(r.Context(), w, r, 200, "recurring journal "+rj.ScheduleID, rj, 0)
+}
+
+// ListRecurringJournals lists all recurring journals
+func ListRecurringJournals(w http.ResponseWriter, r *http.Request) {
+	requestID := r.Context().Value(contextkeys.XRequestID).(string)
+	llog := restLog.WithField("RequestID", requestID).WithField("function", "ListRecurringJournals")
+	if r.Context().Err() != nil {
+		llog.Errorf("context is canceled : %s", r.Context().Err().Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 500, "request is canceled", "request is canceled", 0)
+		return
+	}
+	if RecurringJournalMgr == nil {
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 501, "not implemented", "recurring journal manager is not available", 0)
+		return
+	}
+
+	pageRequest, msg := pageRequestFromQuery(r)
+	if len(msg) > 0 {
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 400, "invalid request", msg, 0)
+		return
+	}
+
+	pr, rjs, err := RecurringJournalMgr.ListRecurringJournals(r.Context(), pageRequest)
+	if err != nil {
+		llog.Errorf("error while calling RecurringJournalMgr.ListRecurringJournals. got : %s", err.Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 500, "backend error", err.Error(), 2)
+		return
+	}
+	helpers.HTTPResponseBuilder(r.Context(), w, r, 200, "OK", &PaginatedRecurringJournalsResponse{
+		RecurringJournals: rjs,
+		Pagination:        FromAccorePageResult(pr),
+	}, 0)
+}
+
+// GetRecurringJournal fetches a recurring journal from its schedule ID
+func GetRecurringJournal(w http.ResponseWriter, r *http.Request) {
+	requestID := r.Context().Value(contextkeys.XRequestID).(string)
+	llog := restLog.WithField("RequestID", requestID).WithField("function", "GetRecurringJournal")
+	if r.Context().Err() != nil {
+		llog.Errorf("context is canceled : %s", r.Context().Err().Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 500, "request is canceled", "request is canceled", 0)
+		return
+	}
+	if RecurringJournalMgr == nil {
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 501, "not implemented", "recurring journal manager is not available", 0)
+		return
+	}
+
+	m, err := helpers.ParsePathParams("/api/v1/recurring-journals/{ScheduleID}", r.URL.Path)
+	if err != nil {
+		llog.Errorf("error while processing path template /api/v1/recurring-journals/{ScheduleID}. got : %s", err.Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 404, "path not found", "path not found", 1)
+		return
+	}
+
+	rj, err := RecurringJournalMgr.GetRecurringJournal(r.Context(), m["ScheduleID"])
+	if err != nil {
+		llog.Errorf("error while calling RecurringJournalMgr.GetRecurringJournal. got : %s", err.Error())
+		recurringJournalErrorResponse(w, r, err)
+		return
+	}
+	helpers.HTTPResponseBuilder(r.Context(), w, r, 200, "recurring journal "+rj.ScheduleID, rj, 0)
+}
+
+// PauseRecurringJournal stops a recurring journal from being posted
+func PauseRecurringJournal(w http.ResponseWriter, r *http.Request) {
+	requestID := r.Context().Value(contextkeys.XRequestID).(string)
+	llog := restLog.WithField("RequestID", requestID).WithField("function", "PauseRecurringJournal")
+	if r.Context().Err() != nil {
+		llog.Errorf("context is canceled : %s", r.Context().Err().Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 500, "request is canceled", "request is canceled", 0)
+		return
+	}
+	if RecurringJournalMgr == nil {
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 501, "not implemented", "recurring journal manager is not available", 0)
+		return
+	}
+
+	m, err := helpers.ParsePathParams("/api/v1/recurring-journals/{ScheduleID}/pause", r.URL.Path)
+	if err != nil {
+		llog.Errorf("error while processing path template /api/v1/recurring-journals/{ScheduleID}/pause. got : %s", err.Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 404, "path not found", "path not found", 1)
+		return
+	}
+
+	if before, err := RecurringJournalMgr.GetRecurringJournal(r.Context(), m["ScheduleID"]); err == nil {
+		middlewares.AuditSnapshot(r.Context(), before, nil)
+	}
+	rj, err := RecurringJournalMgr.PauseRecurringJournal(r.Context(), m["ScheduleID"])
+	if err != nil {
+		llog.Errorf("error while calling RecurringJournalMgr.PauseRecurringJournal. got : %s", err.Error())
+		recurringJournalErrorResponse(w, r, err)
+		return
+	}
+	helpers.HTTPResponseBuilder(r.Context(), w, r, 200, "recurring journal "+rj.ScheduleID, rj, 0)
+}
+
+// ResumeRecurringJournal continues posting a paused recurring journal
+func ResumeRecurringJournal(w http.ResponseWriter, r *http.Request) {
+	requestID := r.Context().Value(contextkeys.XRequestID).(string)
+	llog := restLog.WithField("RequestID", requestID).WithField("function", "ResumeRecurringJournal")
+	if r.Context().Err() != nil {
+		llog.Errorf("context is canceled : %s", r.Context().Err().Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 500, "request is canceled", "request is canceled", 0)
+		return
+	}
+	if RecurringJournalMgr == nil {
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 501, "not implemented", "recurring journal manager is not available", 0)
+		return
+	}
+
+	m, err := helpers.ParsePathParams("/api/v1/recurring-journals/{ScheduleID}/resume", r.URL.Path)
+	if err != nil {
+		llog.Errorf("error while processing path template /api/v1/recurring-journals/{ScheduleID}/resume. got : %s", err.Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 404, "path not found", "path not found", 1)
+		return
+	}
+
+	if before, err := RecurringJournalMgr.GetRecurringJournal(r.Context(), m["ScheduleID"]); err == nil {
+		middlewares.AuditSnapshot(r.Context(), before, nil)
+	}
+	rj, err := RecurringJournalMgr.ResumeRecurringJournal(r.Context(), m["ScheduleID"])
+	if err != nil {
+		llog.Errorf("error while calling RecurringJournalMgr.ResumeRecurringJournal. got : %s", err.Error())
+		recurringJournalErrorResponse(w, r, err)
+		return
+	}
+	helpers.HTTPResponseBuilder(r.Context(), w, r, 200, "recurring journal "+rj.ScheduleID, rj, 0)
+}
+
+// ListRecurringJournalRuns lists the run history of a recurring journal, latest run first
+func ListRecurringJournalRuns(w http.ResponseWriter, r *http.Request) {
+	requestID := r.Context().Value(contextkeys.XRequestID).(string)
+	llog := restLog.WithField("RequestID", requestID).WithField("function", "ListRecurringJournalRuns")
+	if r.Context().Err() != nil {
+		llog.Errorf("context is canceled : %s", r.Context().Err().Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 500, "request is canceled", "request is canceled", 0)
+		return
+	}
+	if RecurringJournalMgr == nil {
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 501, "not implemented", "recurring journal manager is not available", 0)
+		return
+	}
+
+	m, err := helpers.ParsePathParams("/api/v1/recurring-journals/{ScheduleID}/runs", r.URL.Path)
+	if err != nil {
+		llog.Errorf("error while processing path template /api/v1/recurring-journals/{ScheduleID}/runs. got : %s", err.Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 404, "path not found", "path not found", 1)
+		return
+	}
+	pageRequest, msg := pageRequestFromQuery(r)
+	if len(msg) > 0 {
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 400, "invalid request", msg, 0)
+		return
+	}
+
+	pr, runs, err := RecurringJournalMgr.ListRecurringJournalRuns(r.Context(), m["ScheduleID"], pageRequest)
+	if err != nil {
+		llog.Errorf("error while calling RecurringJournalMgr.ListRecurringJournalRuns. got : %s", err.Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 500, "backend error", err.Error(), 2)
+		return
+	}
+	helpers.HTTPResponseBuilder(r.Context(), w, r, 200, "OK", &PaginatedRecurringJournalRunsResponse{
+		Runs:       runs,
+		Pagination: FromAccorePageResult(pr),
+	}, 0)
+}
@@ -0,0 +1,100 @@
+package accounting
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/hyperjumptech/bookkeeping/internal/contextkeys"
+	"github.com/hyperjumptech/bookkeeping/internal/helpers"
+)
+
+// referenceErrorResponse writes the response for errors returned by ReferenceMgr
+func referenceErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, ErrExternalReferenceNotFound):
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 404, "external reference not found", err.Error(), 3)
+	default:
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 500, "backend error", err.Error(), 2)
+	}
+}
+
+// referenceCreator returns the creator whose references are looked up, the client of the api key or the creator query parameter
+func referenceCreator(r *http.Request) string {
+	return requestCreator(r, r.URL.Query().Get("creator"))
+}
+
+// GetJournalByReference fetches the journal the creator created with the external reference
+func GetJournalByReference(w http.ResponseWriter, r *http.Request) {
+	requestID := r.Context().Value(contextkeys.XRequestID).(string)
+	llog := restLog.WithField("RequestID", requestID).WithField("function", "GetJournalByReference")
+	if r.Context().Err() != nil {
+		llog.Errorf("context is canceled : %s", r.Context().Err().Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 500, "request is canceled", "request is canceled", 0)
+		return
+	}
+	if ReferenceMgr == nil {
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 501, "not implemented", "reference manager is not available", 0)
+		return
+	}
+
+	m, err := helpers.ParsePathParams("/api/v1/journals/by-reference/{Reference}", r.URL.Path)
+	if err != nil {
+		llog.Errorf("error while processing path template /api/v1/journals/by-reference/{Reference}. got : %s", err.Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 404, "path not found", "path not found", 1)
+		return
+	}
+	creator := referenceCreator(r)
+	if len(creator) == 0 {
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 400, "invalid request", "creator query parameter is required", 0)
+		return
+	}
+
+	j, err := ReferenceMgr.GetJournalByReference(r.Context(), creator, m["Reference"])
+	if err != nil {
+		llog.Errorf("error while calling ReferenceMgr.GetJournalByReference. got : %s", err.Error())
+		referenceErrorResponse(w, r, err)
+		return
+	}
+	helpers.HTTPResponseBuilder(r.Context(), w, r, 200, "OK", journalDetailOf(j), 0)
+}
+
+// GetAccountByReference fetches the account the creator created with the external reference
+func GetAccountByReference(w http.ResponseWriter, r *http.Request) {
+	requestID := r.Context().Value(contextkeys.XRequestID).(string)
+	llog := restLog.WithField("RequestID", requestID).WithField("function", "GetAccountByReference")
+	if r.Context().Err() != nil {
+		llog.Errorf("context is canceled : %s", r.Context().Err().Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 500, "request is canceled", "request is canceled", 0)
+		return
+	}
+	if ReferenceMgr == nil {
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 501, "not implemented", "reference manager is not available", 0)
+		return
+	}
+
+	m, err := helpers.ParsePathParams("/api/v1/accounts/by-reference/{Reference}", r.URL.Path)
+	if err != nil {
+		llog.Errorf("error while processing path template /api/v1/accounts/by-reference/{Reference}. got : %s", err.Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 404, "path not found", "path not found", 1)
+		return
+	}
+	creator := referenceCreator(r)
+	if len(creator) == 0 {
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 400, "invalid request", "creator query parameter is required", 0)
+		return
+	}
+
+	account, err := ReferenceMgr.GetAccountByReference(r.Context(), creator, m["Reference"])
+	if err != nil {
+		llog.Errorf("error while calling ReferenceMgr.GetAccountByReference. got : %s", err.Error())
+		referenceErrorResponse(w, r, err)
+		return
+	}
+	ret, err := accountEntityOf(r.Context(), account)
+	if err != nil {
+		llog.Errorf("error while reading the state of account %s. got : %s", account.GetAccountNumber(), err.Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 500, "backend error", err.Error(), 2)
+		return
+	}
+	helpers.HTTPResponseBuilder(r.Context(), w, r, 200, "account "+account.GetAccountNumber(), ret, 0)
+}
@@ -0,0 +1,61 @@
+package accounting
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+
+	"github.com/hyperjumptech/acccore"
+	"github.com/hyperjumptech/bookkeeping/internal/contextkeys"
+	"github.com/hyperjumptech/bookkeeping/internal/helpers"
+)
+
+// reversalErrorResponse writes the response for errors returned by ReversalMgr
+func reversalErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, sql.ErrNoRows), errors.Is(err, acccore.ErrJournalIDNotFound):
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 404, "journal not found", "journal to reverse not found", 0)
+	case errors.Is(err, acccore.ErrJournalCanNotDoubleReverse), errors.Is(err, ErrReversalExceedsOriginal):
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 409, "journal over reversed", err.Error(), 0)
+	case errors.Is(err, ErrInvalidReversal), errors.Is(err, acccore.ErrJournalNotBalance),
+		errors.Is(err, acccore.ErrJournalNoTransaction), errors.Is(err, acccore.ErrJournalMissingAuthor),
+		errors.Is(err, ErrJournalRequiresApproval):
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 400, "request rejected", err.Error(), 0)
+	default:
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 500, "internal server error when reversing journal", err.Error(), 0)
+	}
+}
+
+// GetJournalReversals returns how much of each line of a journal have been reversed, and by which journals
+func GetJournalReversals(w http.ResponseWriter, r *http.Request) {
+	requestID := r.Context().Value(contextkeys.XRequestID).(string)
+	llog := restLog.WithField("RequestID", requestID).WithField("function", "GetJournalReversals")
+	if r.Context().Err() != nil {
+		llog.Errorf("context is canceled : %s", r.Context().Err().Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 500, "request is canceled", "request is canceled", 0)
+		return
+	}
+	if ReversalMgr == nil {
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 501, "not implemented", "reversal manager is not available", 0)
+		return
+	}
+
+	m, err := helpers.ParsePathParams("/api/v1/journals/{JournalID}/reversals", r.URL.Path)
+	if err != nil {
+		llog.Errorf("error while processing path template /api/v1/journals/{JournalID}/reversals. got : %s", err.Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 404, "path not found", "path not found", 1)
+		return
+	}
+
+	summary, err := ReversalMgr.GetReversalSummary(r.Context(), m["JournalID"])
+	if err != nil {
+		llog.Errorf("error while calling ReversalMgr.GetReversalSummary. got : %s", err.Error())
+		if errors.Is(err, acccore.ErrJournalIDNotFound) {
+			helpers.HTTPResponseBuilder(r.Context(), w, r, 404, "journal not found", err.Error(), 3)
+			return
+		}
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 500, "backend error", err.Error(), 2)
+		return
+	}
+	helpers.HTTPResponseBuilder(r.Context(), w, r, 200, "reversals of journal "+summary.JournalID, summary, 0)
+}
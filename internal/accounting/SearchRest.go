@@ -0,0 +1,205 @@
+package accounting
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hyperjumptech/acccore"
+	"github.com/hyperjumptech/bookkeeping/internal/contextkeys"
+	"github.com/hyperjumptech/bookkeeping/internal/helpers"
+)
+
+// PaginatedTransactionSearchResponse is the transaction search response paginated
+type PaginatedTransactionSearchResponse struct {
+	Transactions []*TransactionListItem `json:"transactions"`
+	Pagination   *PageResultBody        `json:"pagination"`
+}
+
+// PaginatedJournalSearchResponse is the journal search response paginated
+type PaginatedJournalSearchResponse struct {
+	Journals   []*JournalDetail `json:"journals"`
+	Pagination *PageResultBody  `json:"pagination"`
+}
+
+// searchQuery holds the criteria shared by the transaction and the journal search
+type searchQuery struct {
+	accountNumbers []string
+	coa            string
+	alignment      string
+	minAmount      *int64
+	maxAmount      *int64
+	creator        string
+	reversal       *bool
+	description    string
+	from           time.Time
+	until          time.Time
+}
+
+// searchQueryFromQuery reads the search criteria and the page request out of the query parameters.
+// The accounts are given with repeated or comma separated account parameters, the sort parameter lists
+// the fields to sort by separated by comma, a field prefixed by '-' is sorted in descending order.
+func searchQueryFromQuery(r *http.Request) (*searchQuery, acccore.PageRequest, string) {
+	pageRequest, msg := pageRequestFromQuery(r)
+	if len(msg) > 0 {
+		return nil, pageRequest, msg
+	}
+	query := r.URL.Query()
+	ret := &searchQuery{
+		coa:         query.Get("coa"),
+		alignment:   strings.ToUpper(query.Get("alignment")),
+		creator:     query.Get("creator"),
+		description: query.Get("description"),
+	}
+	for _, accounts := range query["account"] {
+		for _, account := range strings.Split(accounts, ",") {
+			if account = strings.TrimSpace(account); len(account) > 0 {
+				ret.accountNumbers = append(ret.accountNumbers, account)
+			}
+		}
+	}
+	for _, param := range []struct {
+		name   string
+		amount **int64
+	}{{"min_amount", &ret.minAmount}, {"max_amount", &ret.maxAmount}} {
+		if s := query.Get(param.name); len(s) > 0 {
+			amount, err := strconv.ParseInt(s, 10, 64)
+			if err != nil {
+				return nil, pageRequest, param.name + " is not number"
+			}
+			*param.amount = &amount
+		}
+	}
+	if s := query.Get("reversal"); len(s) > 0 {
+		reversal, err := strconv.ParseBool(s)
+		if err != nil {
+			return nil, pageRequest, "reversal must be true or false"
+		}
+		ret.reversal = &reversal
+	}
+	var err error
+	if s := query.Get("from"); len(s) > 0 {
+		if ret.from, err = time.Parse(RestTimeFormat, s); err != nil {
+			return nil, pageRequest, "from time format not correct"
+		}
+	}
+	if s := query.Get("until"); len(s) > 0 {
+		if ret.until, err = time.Parse(RestTimeFormat, s); err != nil {
+			return nil, pageRequest, "until time format not correct"
+		}
+	}
+	for _, field := range strings.Split(query.Get("sort"), ",") {
+		if field = strings.TrimSpace(field); len(field) > 0 {
+			pageRequest.Sorts = append(pageRequest.Sorts, acccore.Sort{
+				Column:    strings.TrimPrefix(field, "-"),
+				Ascending: !strings.HasPrefix(field, "-"),
+			})
+		}
+	}
+	return ret, pageRequest, ""
+}
+
+// searchErrorResponse writes the response for errors returned by SearchMgr
+func searchErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, ErrInvalidSearch) {
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 400, "invalid search", err.Error(), 0)
+		return
+	}
+	helpers.HTTPResponseBuilder(r.Context(), w, r, 500, "backend error", err.Error(), 2)
+}
+
+// SearchTransactions searches the transactions by accounts, COA, alignment, amount range, creator,
+// reversal, description and time range. Requires the page and size query parameters.
+func SearchTransactions(w http.ResponseWriter, r *http.Request) {
+	requestID := r.Context().Value(contextkeys.XRequestID).(string)
+	llog := restLog.WithField("RequestID", requestID).WithField("function", "SearchTransactions")
+	if r.Context().Err() != nil {
+		llog.Errorf("context is canceled : %s", r.Context().Err().Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 500, "request is canceled", "request is canceled", 0)
+		return
+	}
+	if SearchMgr == nil {
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 501, "not implemented", "search manager is not available", 0)
+		return
+	}
+
+	sq, pageRequest, msg := searchQueryFromQuery(r)
+	if len(msg) > 0 {
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 400, "invalid request", msg, 0)
+		return
+	}
+	pr, transactions, err := SearchMgr.SearchTransactions(r.Context(), &TransactionSearchFilter{
+		AccountNumbers: sq.accountNumbers,
+		Coa:            sq.coa,
+		Alignment:      sq.alignment,
+		MinAmount:      sq.minAmount,
+		MaxAmount:      sq.maxAmount,
+		Creator:        sq.creator,
+		Reversal:       sq.reversal,
+		Description:    sq.description,
+		From:           sq.from,
+		Until:          sq.until,
+	}, pageRequest)
+	if err != nil {
+		llog.Errorf("error while calling SearchMgr.SearchTransactions. got : %s", err.Error())
+		searchErrorResponse(w, r, err)
+		return
+	}
+	items := make([]*TransactionListItem, len(transactions))
+	for idx, trx := range transactions {
+		items[idx] = transactionListItemOf(trx)
+	}
+	helpers.HTTPResponseBuilder(r.Context(), w, r, 200, "OK", &PaginatedTransactionSearchResponse{
+		Transactions: items,
+		Pagination:   FromAccorePageResult(pr),
+	}, 0)
+}
+
+// SearchJournals searches the journals by the accounts, COA and alignment of their transactions, total amount range,
+// creator, reversal, description and time range. Requires the page and size query parameters.
+func SearchJournals(w http.ResponseWriter, r *http.Request) {
+	requestID := r.Context().Value(contextkeys.XRequestID).(string)
+	llog := restLog.WithField("RequestID", requestID).WithField("function", "SearchJournals")
+	if r.Context().Err() != nil {
+		llog.Errorf("context is canceled : %s", r.Context().Err().Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 500, "request is canceled", "request is canceled", 0)
+		return
+	}
+	if SearchMgr == nil {
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 501, "not implemented", "search manager is not available", 0)
+		return
+	}
+
+	sq, pageRequest, msg := searchQueryFromQuery(r)
+	if len(msg) > 0 {
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 400, "invalid request", msg, 0)
+		return
+	}
+	pr, journals, err := SearchMgr.SearchJournals(r.Context(), &JournalSearchFilter{
+		AccountNumbers: sq.accountNumbers,
+		Coa:            sq.coa,
+		Alignment:      sq.alignment,
+		MinAmount:      sq.minAmount,
+		MaxAmount:      sq.maxAmount,
+		Creator:        sq.creator,
+		Reversal:       sq.reversal,
+		Description:    sq.description,
+		From:           sq.from,
+		Until:          sq.until,
+	}, pageRequest)
+	if err != nil {
+		llog.Errorf("error while calling SearchMgr.SearchJournals. got : %s", err.Error())
+		searchErrorResponse(w, r, err)
+		return
+	}
+	items := make([]*JournalDetail, len(journals))
+	for idx, j := range journals {
+		items[idx] = journalDetailOf(j)
+	}
+	helpers.HTTPResponseBuilder(r.Context(), w, r, 200, "OK", &PaginatedJournalSearchResponse{
+		Journals:   items,
+		Pagination: FromAccorePageResult(pr),
+	}, 0)
+}
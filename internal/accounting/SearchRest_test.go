@@ -0,0 +1,97 @@
+package accounting
+
+import (
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/hyperjumptech/acccore"
+	"github.com/hyperjumptech/bookkeeping/internal/connector"
+)
+
+func TestSearchQueryFromQuery(t *testing.T) {
+	r := httptest.NewRequest("GET", "/api/v1/transactions/search?page=2&size=10&account=CASH,BANK&account=EQUITY"+
+		"&coa=1.1&alignment=debit&min_amount=100&max_amount=500&creator=alice&reversal=false&description=fee"+
+		"&from=2024-01-01T00:00:00&until=2024-02-01T00:00:00&sort=-amount,transaction_time", nil)
+	sq, pageRequest, msg := searchQueryFromQuery(r)
+	if len(msg) > 0 {
+		t.Fatalf("expecting valid query, got %s", msg)
+	}
+	if pageRequest.PageNo != 2 || pageRequest.ItemSize != 10 {
+		t.Errorf("expecting page 2 of size 10, got %d of size %d", pageRequest.PageNo, pageRequest.ItemSize)
+	}
+	if len(pageRequest.Sorts) != 2 || pageRequest.Sorts[0] != (acccore.Sort{Column: "amount", Ascending: false}) ||
+		pageRequest.Sorts[1] != (acccore.Sort{Column: "transaction_time", Ascending: true}) {
+		t.Errorf("expecting amount descending then transaction_time ascending, got %v", pageRequest.Sorts)
+	}
+	if len(sq.accountNumbers) != 3 || sq.accountNumbers[0] != "CASH" || sq.accountNumbers[2] != "EQUITY" {
+		t.Errorf("expecting 3 accounts, got %v", sq.accountNumbers)
+	}
+	if sq.coa != "1.1" || sq.alignment != "DEBIT" || sq.creator != "alice" || sq.description != "fee" {
+		t.Errorf("unexpected criteria %+v", sq)
+	}
+	if sq.minAmount == nil || *sq.minAmount != 100 || sq.maxAmount == nil || *sq.maxAmount != 500 {
+		t.Errorf("expecting amount range 100 to 500")
+	}
+	if sq.reversal == nil || *sq.reversal {
+		t.Errorf("expecting non reversal")
+	}
+	if !sq.from.Equal(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)) || !sq.until.Equal(time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected time range %s to %s", sq.from, sq.until)
+	}
+
+	for _, query := range []string{
+		"size=10",
+		"page=1&size=10&min_amount=ten",
+		"page=1&size=10&reversal=maybe",
+		"page=1&size=10&from=2024-01-01",
+	} {
+		if _, _, msg := searchQueryFromQuery(httptest.NewRequest("GET", "/api/v1/journals/search?"+query, nil)); len(msg) == 0 {
+			t.Errorf("%s : expecting invalid query", query)
+		}
+	}
+}
+
+func TestSearchSorts(t *testing.T) {
+	sorts, err := searchSorts([]acccore.Sort{{Column: "amount"}, {Column: "transaction_time", Ascending: true}}, connector.TransactionSearchSorts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sorts) != 2 || sorts[0] != (connector.SearchSort{Field: "amount"}) || sorts[1] != (connector.SearchSort{Field: "transaction_time", Ascending: true}) {
+		t.Errorf("unexpected sorts %v", sorts)
+	}
+	if _, err := searchSorts([]acccore.Sort{{Column: "amount"}}, connector.JournalSearchSorts); !errors.Is(err, ErrInvalidSearch) {
+		t.Errorf("expecting ErrInvalidSearch sorting journals by amount, got %v", err)
+	}
+	if _, err := searchSorts([]acccore.Sort{{Column: "1; DROP TABLE journals"}}, connector.JournalSearchSorts); !errors.Is(err, ErrInvalidSearch) {
+		t.Errorf("expecting ErrInvalidSearch, got %v", err)
+	}
+}
+
+func TestValidateSearchRange(t *testing.T) {
+	amount := func(a int64) *int64 {
+		return &a
+	}
+	testData := []struct {
+		alignment string
+		min, max  *int64
+		valid     bool
+	}{
+		{"", nil, nil, true},
+		{"DEBIT", amount(100), nil, true},
+		{"CREDIT", nil, amount(100), true},
+		{"", amount(100), amount(100), true},
+		{"", amount(101), amount(100), false},
+		{"BOTH", nil, nil, false},
+	}
+	for _, td := range testData {
+		err := validateSearchRange(td.alignment, td.min, td.max)
+		if td.valid && err != nil {
+			t.Errorf("%+v : expecting valid range, got %s", td, err.Error())
+		}
+		if !td.valid && !errors.Is(err, ErrInvalidSearch) {
+			t.Errorf("%+v : expecting ErrInvalidSearch, got %v", td, err)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package accounting
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/hyperjumptech/bookkeeping/internal/contextkeys"
+	"github.com/hyperjumptech/bookkeeping/internal/helpers"
+	"github.com/hyperjumptech/bookkeeping/internal/middlewares"
+)
+
+// SessionTokenRequest is the payload to request a session token
+type SessionTokenRequest struct {
+	// Scopes narrows down the scopes of the token, it is granted every scope of the caller if empty
+	Scopes []string `json:"scopes"`
+}
+
+// SessionTokenResponse carries the session token, to be sent as "Session <token>" in the Authorization header
+type SessionTokenResponse struct {
+	Token     string    `json:"token,omitempty"`
+	TokenType string    `json:"token_type"`
+	Principal string    `json:"principal"`
+	Scopes    []string  `json:"scopes"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// IssueSessionToken issues a short lived session token to the caller, such as the dashboard after its user logs in with an api key.
+func IssueSessionToken(w http.ResponseWriter, r *http.Request) {
+	requestID := r.Context().Value(contextkeys.XRequestID).(string)
+	llog := restLog.WithField("RequestID", requestID).WithField("function", "IssueSessionToken")
+	if r.Context().Err() != nil {
+		llog.Errorf("context is canceled : %s", r.Context().Err().Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 500, "request is canceled", "request is canceled", 0)
+		return
+	}
+
+	bodyByte, err := io.ReadAll(r.Body)
+	if err != nil {
+		llog.Errorf("got %s", err.Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 500, "error reading body", err.Error(), 0)
+		return
+	}
+	tokenReq := &SessionTokenRequest{}
+	if len(bodyByte) > 0 {
+		err = json.Unmarshal(bodyByte, tokenReq)
+		if err != nil {
+			llog.Errorf("got %s", err.Error())
+			helpers.HTTPResponseBuilder(r.Context(), w, r, 400, "malformed json body", err.Error(), 0)
+			return
+		}
+	}
+
+	token, st, err := middlewares.IssueSessionToken(r.Context(), tokenReq.Scopes, time.Now())
+	if err != nil {
+		llog.Errorf("error while calling middlewares.IssueSessionToken. got : %s", err.Error())
+		switch {
+		case errors.Is(err, middlewares.ErrScopeNotGranted):
+			helpers.HTTPResponseBuilder(r.Context(), w, r, 403, "insufficient scope", err.Error(), middlewares.ErrorCodeInsufficientScope)
+		case errors.Is(err, middlewares.ErrSessionNotRenewable), errors.Is(err, middlewares.ErrSessionUnauthenticated):
+			helpers.HTTPResponseBuilder(r.Context(), w, r, 403, "session token refused", err.Error(), 0)
+		default:
+			helpers.HTTPResponseBuilder(r.Context(), w, r, 500, "backend error", err.Error(), 2)
+		}
+		return
+	}
+	resp := &SessionTokenResponse{
+		TokenType: middlewares.SessionScheme,
+		Principal: st.Principal,
+		Scopes:    st.Scopes,
+		ExpiresAt: time.Unix(st.ExpiresAt, 0),
+	}
+	// the token is kept out of the audit log
+	middlewares.AuditSnapshot(r.Context(), nil, resp)
+	resp.Token = token
+	helpers.HTTPResponseBuilder(r.Context(), w, r, 200, "OK", resp, 0)
+}
@@ -0,0 +1,100 @@
+package accounting
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/hyperjumptech/bookkeeping/internal/contextkeys"
+	"github.com/hyperjumptech/bookkeeping/internal/helpers"
+)
+
+// GetAccountStatement returns the statement of the account between the from and until query parameters,
+// with its opening, running and closing balances. The format query parameter selects the rendering :
+// json (the default), csv or text, printable like DrawAccount.
+func GetAccountStatement(w http.ResponseWriter, r *http.Request) {
+	requestID := r.Context().Value(contextkeys.XRequestID).(string)
+	llog := restLog.WithField("RequestID", requestID).WithField("function", "GetAccountStatement")
+	if r.Context().Err() != nil {
+		llog.Errorf("context is canceled : %s", r.Context().Err().Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 500, "request is canceled", "request is canceled", 0)
+		return
+	}
+	if StatementMgr == nil {
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 501, "not implemented", "statement manager is not available", 0)
+		return
+	}
+
+	m, err := helpers.ParsePathParams("/api/v1/accounts/{AccountNumber}/statement", r.URL.Path)
+	if err != nil {
+		llog.Errorf("error while processing path template /api/v1/accounts/{AccountNumber}/statement. got : %s", err.Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 404, "path not found", "path not found", 1)
+		return
+	}
+
+	query := r.URL.Query()
+	from, ferr := time.Parse(RestTimeFormat, query.Get("from"))
+	until, uerr := time.Parse(RestTimeFormat, query.Get("until"))
+	if ferr != nil || uerr != nil {
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 400, "invalid request", "either from, until is missing or its time format not correct", 0)
+		return
+	}
+	if !until.After(from) {
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 400, "invalid request", "until must be after from", 0)
+		return
+	}
+	format := query.Get("format")
+	switch format {
+	case "":
+		format = "json"
+	case "json", "csv", "text":
+	default:
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 400, "invalid request", "format must be json, csv or text", 0)
+		return
+	}
+
+	accountNo := m["AccountNumber"]
+	account, err := AccountMgr.GetAccountByID(r.Context(), accountNo)
+	if err != nil {
+		llog.Errorf("error while calling AccountMgr.GetAccountByID. got : %s", err.Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 500, "backend error", err.Error(), 2)
+		return
+	}
+	if account == nil {
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 404, "account number not found", "account number not found", 3)
+		return
+	}
+
+	statement, err := StatementMgr.GetAccountStatement(r.Context(), from, until, account)
+	if errors.Is(err, ErrStatementTooLong) {
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 400, "statement too long", err.Error(), 0)
+		return
+	}
+	if err != nil {
+		llog.Errorf("error while calling StatementMgr.GetAccountStatement. got : %s", err.Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 500, "backend error", err.Error(), 2)
+		return
+	}
+
+	switch format {
+	case "csv":
+		var buff bytes.Buffer
+		if err := writeStatementCSV(&buff, statement); err != nil {
+			llog.Errorf("error while writing the statement of account %s as csv. got : %s", accountNo, err.Error())
+			helpers.HTTPResponseBuilder(r.Context(), w, r, 500, "backend error", err.Error(), 2)
+			return
+		}
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"statement-%s.csv\"", account.GetAccountNumber()))
+		w.WriteHeader(http.StatusOK)
+		w.Write(buff.Bytes())
+	case "text":
+		w.Header().Set("Content-Type", "text/plain")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(drawStatement(statement)))
+	default:
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 200, "statement of account "+account.GetAccountNumber(), statement, 0)
+	}
+}
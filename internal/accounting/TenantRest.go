@@ -0,0 +1,146 @@
+package accounting
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/hyperjumptech/bookkeeping/internal/contextkeys"
+	"github.com/hyperjumptech/bookkeeping/internal/helpers"
+	"github.com/hyperjumptech/bookkeeping/internal/middlewares"
+)
+
+// CreateTenantRequest is the payload to provision a tenant
+type CreateTenantRequest struct {
+	TenantID    string `json:"tenant_id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Creator     string `json:"creator"`
+}
+
+// PaginatedTenantsResponse is the tenant list response paginated
+type PaginatedTenantsResponse struct {
+	Tenants    []*Tenant       `json:"tenants"`
+	Pagination *PageResultBody `json:"pagination"`
+}
+
+// tenantErrorResponse writes the response for errors returned by TenantMgr
+func tenantErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, ErrTenantNotFound):
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 404, "tenant not found", err.Error(), 3)
+	case errors.Is(err, ErrTenantAlreadyExist):
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 409, "tenant already exist", err.Error(), 0)
+	case errors.Is(err, ErrInvalidTenant):
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 400, "request rejected", err.Error(), 0)
+	default:
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 500, "backend error", err.Error(), 2)
+	}
+}
+
+// CreateTenant provisions a new tenant with its own empty books
+func CreateTenant(w http.ResponseWriter, r *http.Request) {
+	requestID := r.Context().Value(contextkeys.XRequestID).(string)
+	llog := restLog.WithField("RequestID", requestID).WithField("function", "CreateTenant")
+	if r.Context().Err() != nil {
+		llog.Errorf("context is canceled : %s", r.Context().Err().Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 500, "request is canceled", "request is canceled", 0)
+		return
+	}
+	if TenantMgr == nil {
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 501, "not implemented", "tenant manager is not available", 0)
+		return
+	}
+
+	bodyByte, err := io.ReadAll(r.Body)
+	if err != nil {
+		llog.Errorf("got %s", err.Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 500, "error reading body", err.Error(), 0)
+		return
+	}
+	tenantReq := &CreateTenantRequest{}
+	err = json.Unmarshal(bodyByte, tenantReq)
+	if err != nil {
+		llog.Errorf("got %s", err.Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 400, "malformed json body", err.Error(), 0)
+		return
+	}
+
+	// the tenant already holding the id, if any, is what the request would have replaced
+	if before, err := TenantMgr.GetTenant(r.Context(), tenantReq.TenantID); err == nil {
+		middlewares.AuditSnapshot(r.Context(), before, nil)
+	}
+	tenant, err := TenantMgr.CreateTenant(r.Context(), &Tenant{
+		TenantID:    tenantReq.TenantID,
+		Name:        tenantReq.Name,
+		Description: tenantReq.Description,
+	}, requestCreator(r, tenantReq.Creator))
+	if err != nil {
+		llog.Errorf("error while calling TenantMgr.CreateTenant. got : %s", err.Error())
+		tenantErrorResponse(w, r, err)
+		return
+	}
+	helpers.HTTPResponseBuilder(r.Context(), w, r, 200, "tenant "+tenant.TenantID, tenant, 0)
+}
+
+// ListTenants lists the tenants sorted by their tenant id, requires the page and size query parameters
+func ListTenants(w http.ResponseWriter, r *http.Request) {
+	requestID := r.Context().Value(contextkeys.XRequestID).(string)
+	llog := restLog.WithField("RequestID", requestID).WithField("function", "ListTenants")
+	if r.Context().Err() != nil {
+		llog.Errorf("context is canceled : %s", r.Context().Err().Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 500, "request is canceled", "request is canceled", 0)
+		return
+	}
+	if TenantMgr == nil {
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 501, "not implemented", "tenant manager is not available", 0)
+		return
+	}
+
+	pageRequest, msg := pageRequestFromQuery(r)
+	if len(msg) > 0 {
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 400, "invalid request", msg, 0)
+		return
+	}
+
+	pr, tenants, err := TenantMgr.ListTenants(r.Context(), pageRequest)
+	if err != nil {
+		llog.Errorf("error while calling TenantMgr.ListTenants. got : %s", err.Error())
+		tenantErrorResponse(w, r, err)
+		return
+	}
+	helpers.HTTPResponseBuilder(r.Context(), w, r, 200, "OK", &PaginatedTenantsResponse{
+		Tenants:    tenants,
+		Pagination: FromAccorePageResult(pr),
+	}, 0)
+}
+
+// GetTenant returns a tenant from its tenant id
+func GetTenant(w http.ResponseWriter, r *http.Request) {
+	requestID := r.Context().Value(contextkeys.XRequestID).(string)
+	llog := restLog.WithField("RequestID", requestID).WithField("function", "GetTenant")
+	if r.Context().Err() != nil {
+		llog.Errorf("context is canceled : %s", r.Context().Err().Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 500, "request is canceled", "request is canceled", 0)
+		return
+	}
+	if TenantMgr == nil {
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 501, "not implemented", "tenant manager is not available", 0)
+		return
+	}
+
+	m, err := helpers.ParsePathParams("/api/v1/admin/tenants/{TenantID}", r.URL.Path)
+	if err != nil {
+		llog.Errorf("error while processing path template /api/v1/admin/tenants/{TenantID}. got : %s", err.Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 404, "path not found", "path not found", 1)
+		return
+	}
+	tenant, err := TenantMgr.GetTenant(r.Context(), m["TenantID"])
+	if err != nil {
+		llog.Errorf("error while calling TenantMgr.GetTenant. got : %s", err.Error())
+		tenantErrorResponse(w, r, err)
+		return
+	}
+	helpers.HTTPResponseBuilder(r.Context(), w, r, 200, "tenant "+tenant.TenantID, tenant, 0)
+}
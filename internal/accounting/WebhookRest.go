@@ -0,0 +1,287 @@
+package accounting
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/hyperjumptech/bookkeeping/internal/connector"
+	"github.com/hyperjumptech/bookkeeping/internal/contextkeys"
+	"github.com/hyperjumptech/bookkeeping/internal/helpers"
+	"github.com/hyperjumptech/bookkeeping/internal/middlewares"
+)
+
+// WebhookSubscriptionRequest is the payload to subscribe an url to events
+type WebhookSubscriptionRequest struct {
+	URL string `json:"url"`
+	// EventTypes to subscribe to, "*" subscribes to all event types
+	EventTypes  []string `json:"event_types"`
+	Description string   `json:"description"`
+	Creator     string   `json:"creator"`
+}
+
+// PaginatedWebhookDeliveriesResponse is the webhook delivery response paginated
+type PaginatedWebhookDeliveriesResponse struct {
+	Deliveries []*WebhookDelivery `json:"deliveries"`
+	Pagination *PageResultBody    `json:"pagination"`
+}
+
+// webhookErrorResponse writes the response for errors returned by WebhookMgr
+func webhookErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, ErrWebhookSubscriptionNotFound):
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 404, "webhook subscription not found", err.Error(), 0)
+	case errors.Is(err, ErrWebhookDeliveryNotFound):
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 404, "webhook delivery not found", err.Error(), 0)
+	case errors.Is(err, ErrWebhookDeliveryPending):
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 409, "webhook delivery is pending", err.Error(), 0)
+	case errors.Is(err, ErrInvalidWebhookSubscription):
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 400, "request rejected", err.Error(), 0)
+	default:
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 500, "backend error", err.Error(), 2)
+	}
+}
+
+// CreateWebhookSubscription subscribes an url to events. The response carries the secret the deliveries are signed with,
+// its the only time the secret is revealed.
+func CreateWebhookSubscription(w http.ResponseWriter, r *http.Request) {
+	requestID := r.Context().Value(contextkeys.XRequestID).(string)
+	llog := restLog.WithField("RequestID", requestID).WithField("function", "CreateWebhookSubscription")
+	if r.Context().Err() != nil {
+		llog.Errorf("context is canceled : %s", r.Context().Err().Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 500, "request is canceled", "request is canceled", 0)
+		return
+	}
+	if WebhookMgr == nil {
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 501, "not implemented", "webhook manager is not available", 0)
+		return
+	}
+
+	bodyByte, err := io.ReadAll(r.Body)
+	if err != nil {
+		llog.Errorf("got %s", err.Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 500, "error reading body", err.Error(), 0)
+		return
+	}
+	subReq := &WebhookSubscriptionRequest{}
+	err = json.Unmarshal(bodyByte, subReq)
+	if err != nil {
+		llog.Errorf("got %s", err.Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 400, "malformed json body", err.Error(), 0)
+		return
+	}
+	subReq.Creator = requestCreator(r, subReq.Creator)
+
+	subscription, err := WebhookMgr.CreateSubscription(r.Context(), &WebhookSubscription{
+		URL:         subReq.URL,
+		EventTypes:  subReq.EventTypes,
+		Description: subReq.Description,
+	}, subReq.Creator)
+	if err != nil {
+		llog.Errorf("error while calling WebhookMgr.CreateSubscription. got : %s", err.Error())
+		webhookErrorResponse(w, r, err)
+		return
+	}
+	helpers.HTTPResponseBuilder(r.Context(), w, r, 200, "webhook subscription "+subscription.SubscriptionID, subscription, 0)
+}
+
+// ListWebhookSubscriptions lists all webhook subscriptions
+func ListWebhookSubscriptions(w http.ResponseWriter, r *http.Request) {
+	requestID := r.Context().Value(contextkeys.XRequestID).(string)
+	llog := restLog.WithField("RequestID", requestID).WithField("function", "ListWebhookSubscriptions")
+	if r.Context().Err() != nil {
+		llog.Errorf("context is canceled : %s", r.Context().Err().Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 500, "request is canceled", "request is canceled", 0)
+		return
+	}
+	if WebhookMgr == nil {
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 501, "not implemented", "webhook manager is not available", 0)
+		return
+	}
+
+	subscriptions, err := WebhookMgr.ListSubscriptions(r.Context())
+	if err != nil {
+		llog.Errorf("error while calling WebhookMgr.ListSubscriptions. got : %s", err.Error())
+		webhookErrorResponse(w, r, err)
+		return
+	}
+	helpers.HTTPResponseBuilder(r.Context(), w, r, 200, "OK", subscriptions, 0)
+}
+
+// GetWebhookSubscription fetches a webhook subscription from its subscription ID
+func GetWebhookSubscription(w http.ResponseWriter, r *http.Request) {
+	requestID := r.Context().Value(contextkeys.XRequestID).(string)
+	llog := restLog.WithField("RequestID", requestID).WithField("function", "GetWebhookSubscription")
+	if r.Context().Err() != nil {
+		llog.Errorf("context is canceled : %s", r.Context().Err().Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 500, "request is canceled", "request is canceled", 0)
+		return
+	}
+	if WebhookMgr == nil {
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 501, "not implemented", "webhook manager is not available", 0)
+		return
+	}
+
+	m, err := helpers.ParsePathParams("/api/v1/webhooks/{SubscriptionID}", r.URL.Path)
+	if err != nil {
+		llog.Errorf("error while processing path template /api/v1/webhooks/{SubscriptionID}. got : %s", err.Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 404, "path not found", "path not found", 1)
+		return
+	}
+
+	subscription, err := WebhookMgr.GetSubscription(r.Context(), m["SubscriptionID"])
+	if err != nil {
+		llog.Errorf("error while calling WebhookMgr.GetSubscription. got : %s", err.Error())
+		webhookErrorResponse(w, r, err)
+		return
+	}
+	helpers.HTTPResponseBuilder(r.Context(), w, r, 200, "webhook subscription "+subscription.SubscriptionID, subscription, 0)
+}
+
+// DeleteWebhookSubscription removes a webhook subscription
+func DeleteWebhookSubscription(w http.ResponseWriter, r *http.Request) {
+	requestID := r.Context().Value(contextkeys.XRequestID).(string)
+	llog := restLog.WithField("RequestID", requestID).WithField("function", "DeleteWebhookSubscription")
+	if r.Context().Err() != nil {
+		llog.Errorf("context is canceled : %s", r.Context().Err().Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 500, "request is canceled", "request is canceled", 0)
+		return
+	}
+	if WebhookMgr == nil {
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 501, "not implemented", "webhook manager is not available", 0)
+		return
+	}
+
+	m, err := helpers.ParsePathParams("/api/v1/webhooks/{SubscriptionID}", r.URL.Path)
+	if err != nil {
+		llog.Errorf("error while processing path template /api/v1/webhooks/{SubscriptionID}. got : %s", err.Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 404, "path not found", "path not found", 1)
+		return
+	}
+
+	if before, err := WebhookMgr.GetSubscription(r.Context(), m["SubscriptionID"]); err == nil {
+		middlewares.AuditSnapshot(r.Context(), before, nil)
+	}
+	err = WebhookMgr.DeleteSubscription(r.Context(), m["SubscriptionID"])
+	if err != nil {
+		llog.Errorf("error while calling WebhookMgr.DeleteSubscription. got : %s", err.Error())
+		webhookErrorResponse(w, r, err)
+		return
+	}
+	helpers.HTTPResponseBuilder(r.Context(), w, r, 200, "webhook subscription "+m["SubscriptionID"]+" deleted", m["SubscriptionID"], 0)
+}
+
+// ListWebhookDeliveries lists the deliveries of a webhook subscription, optionally filtered by status.
+// Use status DEAD to list the dead letter queue.
+func ListWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	requestID := r.Context().Value(contextkeys.XRequestID).(string)
+	llog := restLog.WithField("RequestID", requestID).WithField("function", "ListWebhookDeliveries")
+	if r.Context().Err() != nil {
+		llog.Errorf("context is canceled : %s", r.Context().Err().Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 500, "request is canceled", "request is canceled", 0)
+		return
+	}
+	if WebhookMgr == nil {
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 501, "not implemented", "webhook manager is not available", 0)
+		return
+	}
+
+	m, err := helpers.ParsePathParams("/api/v1/webhooks/{SubscriptionID}/deliveries", r.URL.Path)
+	if err != nil {
+		llog.Errorf("error while processing path template /api/v1/webhooks/{SubscriptionID}/deliveries. got : %s", err.Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 404, "path not found", "path not found", 1)
+		return
+	}
+	pageRequest, msg := pageRequestFromQuery(r)
+	if len(msg) > 0 {
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 400, "invalid request", msg, 0)
+		return
+	}
+	status := strings.ToUpper(r.URL.Query().Get("status"))
+	switch status {
+	case "", connector.WebhookDeliveryStatusPending, connector.WebhookDeliveryStatusDelivered, connector.WebhookDeliveryStatusDead:
+	default:
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 400, "invalid request", "status must be PENDING, DELIVERED or DEAD", 0)
+		return
+	}
+
+	pr, deliveries, err := WebhookMgr.ListDeliveries(r.Context(), m["SubscriptionID"], status, pageRequest)
+	if err != nil {
+		llog.Errorf("error while calling WebhookMgr.ListDeliveries. got : %s", err.Error())
+		webhookErrorResponse(w, r, err)
+		return
+	}
+	helpers.HTTPResponseBuilder(r.Context(), w, r, 200, "OK", &PaginatedWebhookDeliveriesResponse{
+		Deliveries: deliveries,
+		Pagination: FromAccorePageResult(pr),
+	}, 0)
+}
+
+// ReplayWebhookDelivery schedules a delivered or dead webhook delivery to be delivered again
+func ReplayWebhookDelivery(w http.ResponseWriter, r *http.Request) {
+	requestID := r.Context().Value(contextkeys.XRequestID).(string)
+	llog := restLog.WithField("RequestID", requestID).WithField("function", "ReplayWebhookDelivery")
+	if r.Context().Err() != nil {
+		llog.Errorf("context is canceled : %s", r.Context().Err().Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 500, "request is canceled", "request is canceled", 0)
+		return
+	}
+	if WebhookMgr == nil {
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 501, "not implemented", "webhook manager is not available", 0)
+		return
+	}
+
+	m, err := helpers.ParsePathParams("/api/v1/webhook-deliveries/{DeliveryID}/replay", r.URL.Path)
+	if err != nil {
+		llog.Errorf("error while processing path template /api/v1/webhook-deliveries/{DeliveryID}/replay. got : %s", err.Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 404, "path not found", "path not found", 1)
+		return
+	}
+	deliveryID, err := strconv.ParseInt(m["DeliveryID"], 10, 64)
+	if err != nil {
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 400, "invalid request", "delivery id must be a number", 0)
+		return
+	}
+
+	err = WebhookMgr.ReplayDelivery(r.Context(), deliveryID)
+	if err != nil {
+		llog.Errorf("error while calling WebhookMgr.ReplayDelivery. got : %s", err.Error())
+		webhookErrorResponse(w, r, err)
+		return
+	}
+	helpers.HTTPResponseBuilder(r.Context(), w, r, 200, "webhook delivery "+m["DeliveryID"]+" replayed", deliveryID, 0)
+}
+
+// ReplayDeadWebhookDeliveries schedules every dead delivery of a webhook subscription to be delivered again
+func ReplayDeadWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	requestID := r.Context().Value(contextkeys.XRequestID).(string)
+	llog := restLog.WithField("RequestID", requestID).WithField("function", "ReplayDeadWebhookDeliveries")
+	if r.Context().Err() != nil {
+		llog.Errorf("context is canceled : %s", r.Context().Err().Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 500, "request is canceled", "request is canceled", 0)
+		return
+	}
+	if WebhookMgr == nil {
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 501, "not implemented", "webhook manager is not available", 0)
+		return
+	}
+
+	m, err := helpers.ParsePathParams("/api/v1/webhooks/{SubscriptionID}/dead-letters/replay", r.URL.Path)
+	if err != nil {
+		llog.Errorf("error while processing path template /api/v1/webhooks/{SubscriptionID}/dead-letters/replay. got : %s", err.Error())
+		helpers.HTTPResponseBuilder(r.Context(), w, r, 404, "path not found", "path not found", 1)
+		return
+	}
+
+	count, err := WebhookMgr.ReplayDeadDeliveries(r.Context(), m["SubscriptionID"])
+	if err != nil {
+		llog.Errorf("error while calling WebhookMgr.ReplayDeadDeliveries. got : %s", err.Error())
+		webhookErrorResponse(w, r, err)
+		return
+	}
+	helpers.HTTPResponseBuilder(r.Context(), w, r, 200, fmt.Sprintf("%d dead webhook deliveries replayed", count), count, 0)
+}
@@ -57,11 +57,57 @@ func LoadConfig() {
 	defCfg["health.delay"] = "5"     // seconds
 	defCfg["health.interval"] = "30" // seconds
 
-	defCfg["hmac.secret"] = "th1s?MusT#b3!4*veRY%d33p#53creT"
+	defCfg["hmac.secret"] = "" // the shared secret is disabled unless defined in environment, clients should use their own api key
 	defCfg["hmac.age.minute"] = "10"
+	defCfg["hmac.legacy.enabled"] = "false" // accept the timestamp only hmac, which can be replayed until it expires. never in production
+	defCfg["hmac.scopes"] = "system:admin"  // comma separated scopes or roles granted to the shared secret, enough to issue the first api keys
+
+	// authentication
+	defCfg["auth.authenticators"] = "apikey,hmac,session" // tried in order, among apikey, hmac, session and jwt
+	defCfg["auth.apikey.seal.secret"] = ""                // seals the keys the api keys sign requests with, required in production
+	defCfg["jwt.issuer"] = ""
+	defCfg["jwt.audience"] = "" // not checked if empty
+	defCfg["jwt.jwks.file"] = ""
+	defCfg["jwt.jwks.url"] = ""
+	defCfg["jwt.jwks.refresh.minute"] = "60"
+	defCfg["jwt.claim.principal"] = "sub"
+	defCfg["jwt.claim.scope"] = "scope"
+	defCfg["jwt.claim.tenant"] = "tenant"
+	defCfg["jwt.leeway.second"] = "60"
+	defCfg["auth.session.secret"] = ""       // signs the session tokens, required in production. a random key is used if empty so the tokens do not survive a restart
+	defCfg["auth.session.ttl.minute"] = "15" // validity of the session tokens issued to the dashboard
+
+	// rate limiting, per authenticated client
+	defCfg["ratelimit.enabled"] = "true"
+	defCfg["ratelimit.store"] = "memory" // memory counts per instance, db shares the counts between the instances
+	defCfg["ratelimit.window.second"] = "60"
+	defCfg["ratelimit.read.limit"] = "600"  // read requests per window, 0 is unlimited
+	defCfg["ratelimit.write.limit"] = "120" // write requests per window, 0 is unlimited
 
 	// cron
 	defCfg["cron.backup.daily"] = "0 1 30 2 *" // default at 1:00 am on feb 30th (disabled)
+	defCfg["cron.holds.expire"] = "@every 1m"
+	defCfg["cron.recurring.reload"] = "@every 1m" // picks up the recurring journals changed on the other instances
+	defCfg["cron.webhooks.deliver"] = "@every 10s"
+	defCfg["cron.ratelimits.purge"] = "@every 10m"
+
+	// holds
+	defCfg["hold.expiry.default.minute"] = "10080" // 7 days
+
+	// journals
+	defCfg["journal.batch.max.size"] = "100"
+
+	// ledger feed
+	defCfg["feed.limit.default"] = "100"
+	defCfg["feed.limit.max"] = "1000"
+
+	// account statement
+	defCfg["statement.lines.max"] = "10000"
+
+	// webhooks
+	defCfg["webhook.max.attempts"] = "8"
+	defCfg["webhook.backoff.base.second"] = "30" // doubled on every failed attempt
+	defCfg["webhook.timeout.second"] = "10"
 
 	// firebase
 	defCfg["firebase.storage.bucket"] = "bookkeeping.appspot.com"
@@ -141,6 +187,12 @@ func GetFloat(key string) float64 {
 	return f
 }
 
+// IsProduction tells whether the app runs in production, as configured by app.env.
+// Development conveniences are disabled in production.
+func IsProduction() bool {
+	return Get("app.env") == "production"
+}
+
 // Set configuration key value
 func Set(key, value string) {
 	defCfg[key] = value
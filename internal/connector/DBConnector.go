@@ -4,6 +4,7 @@ import (
 	"context"
 	"time"
 
+	"github.com/hyperjumptech/bookkeeping/internal/contextkeys"
 	"github.com/jmoiron/sqlx"
 	"github.com/sirupsen/logrus"
 
@@ -15,8 +16,45 @@ var (
 	log = logrus.WithField("module", "DBConnector")
 )
 
+// DefaultTenantID is the tenant of the books of the requests and the contexts not carrying any tenant
+const DefaultTenantID = "DEFAULT"
+
+// WithTenant returns a copy of the context carrying the tenant whose books the repository works on
+func WithTenant(ctx context.Context, tenantID string) context.Context {
+	return context.WithValue(ctx, contextkeys.TenantIDContextKey, tenantID)
+}
+
+// TenantFromContext returns the tenant carried in the context, or DefaultTenantID if it carries none.
+// Every repository method reads and writes the books of this tenant only, unless documented otherwise.
+func TenantFromContext(ctx context.Context) string {
+	if tenantID, ok := ctx.Value(contextkeys.TenantIDContextKey).(string); ok && len(tenantID) > 0 {
+		return tenantID
+	}
+	return DefaultTenantID
+}
+
+// TenantRecord an entity representative of Tenants table
+type TenantRecord struct {
+	// TenantID related to tenant_id column
+	TenantID string
+	// Name related to name column
+	Name string
+	// Description related to description column
+	Description string
+	// CreatedAt related to created_at column
+	CreatedAt time.Time
+	// CreatedBy related to created_by column
+	CreatedBy string
+	// UpdatedAt related to updated_at column
+	UpdatedAt time.Time
+	// UpdatedBy related to updated_by column
+	UpdatedBy string
+}
+
 // AccountRecord an entity representative of Account table
 type AccountRecord struct {
+	// TenantID related to tenant_id column, the tenant owning the account
+	TenantID string
 	// AccountNumber related to account_number column
 	AccountNumber string
 	// Name related to name column
@@ -31,6 +69,14 @@ type AccountRecord struct {
 	Balance int64
 	// Coa related to coa column
 	Coa string
+	// Status related to status column, one of the AccountStatus constants
+	Status string
+	// MinBalance related to min_balance column, nil means the account have no minimum balance
+	MinBalance *int64
+	// OverdraftLimit related to overdraft_limit column, nil means the account can not be overdrawn
+	OverdraftLimit *int64
+	// MaxBalance related to max_balance column, nil means the account have no maximum balance
+	MaxBalance *int64
 	// CreatedAt related to created_at column
 	CreatedAt time.Time
 	// CreatedBy related to created_by column
@@ -39,8 +85,21 @@ type AccountRecord struct {
 	UpdatedAt time.Time
 	// UpdatedBy related to updated_by column
 	UpdatedBy string
+	// ExternalReference related to external_reference column, the reference the creator knows the account by, empty if it has none
+	ExternalReference string
 }
 
+const (
+	// AccountStatusActive is the status of an account that accepts any posting
+	AccountStatusActive = "ACTIVE"
+	// AccountStatusDebitFrozen is the status of an account that accepts CREDIT postings only
+	AccountStatusDebitFrozen = "DEBIT_FROZEN"
+	// AccountStatusFrozen is the status of an account that accepts no posting at all
+	AccountStatusFrozen = "FROZEN"
+	// AccountStatusClosed is the status of an account that has been permanently closed
+	AccountStatusClosed = "CLOSED"
+)
+
 // JournalRecord an entity representative of Journal table
 type JournalRecord struct {
 	// JournalID related to journal_id column
@@ -59,6 +118,12 @@ type JournalRecord struct {
 	CreatedAt time.Time
 	// CreatedBy related to created_by column
 	CreatedBy string
+	// Sequence related to sequence column, the gapless number of the journal in commit order
+	Sequence int64
+	// Metadata related to metadata column, the JSON encoded metadata of the journal, empty if it has none
+	Metadata string
+	// ExternalReference related to external_reference column, the reference the creator knows the journal by, empty if it has none
+	ExternalReference string
 }
 
 // TransactionRecord an entity representative of Transaction table
@@ -83,6 +148,63 @@ type TransactionRecord struct {
 	CreatedAt time.Time
 	// CreatedBy related to created_by column
 	CreatedBy string
+	// Metadata related to metadata column, the JSON encoded metadata of the transaction, empty if it has none
+	Metadata string
+}
+
+// MetadataFilter selects the journals or transactions by their metadata, each key must have the value it is mapped to.
+// A key mapped to an empty value only needs to be in the metadata, with any value.
+type MetadataFilter map[string]string
+
+// TransactionSearchFilter specifies the criteria of transaction search. Empty fields are not filtered.
+type TransactionSearchFilter struct {
+	// AccountNumbers selects the transactions of any of the accounts
+	AccountNumbers []string
+	// Coa selects the transactions of the accounts whose COA starts with it
+	Coa string
+	// Alignment is DEBIT or CREDIT
+	Alignment string
+	// MinAmount and MaxAmount are the inclusive amount range
+	MinAmount *int64
+	MaxAmount *int64
+	CreatedBy string
+	// Reversal selects the transactions of reversal journals if true, or of the other journals if false
+	Reversal *bool
+	// Description selects the transactions whose description contains it
+	Description string
+	From        time.Time
+	To          time.Time
+}
+
+// JournalSearchFilter specifies the criteria of journal search. Empty fields are not filtered.
+type JournalSearchFilter struct {
+	// AccountNumbers selects the journals posting to any of the accounts
+	AccountNumbers []string
+	// Coa selects the journals posting to an account whose COA starts with it
+	Coa string
+	// Alignment selects the journals posting on that side, to the selected accounts if any
+	Alignment string
+	// MinAmount and MaxAmount are the inclusive range of the total amount
+	MinAmount *int64
+	MaxAmount *int64
+	CreatedBy string
+	Reversal  *bool
+	// Description selects the journals whose description contains it
+	Description string
+	From        time.Time
+	To          time.Time
+}
+
+// SearchSort is a sort order of the search, Field is one of the keys of TransactionSearchSorts or JournalSearchSorts.
+type SearchSort struct {
+	Field     string
+	Ascending bool
+}
+
+// ListCursor is the position a keyset paginated list continues after, the time and the id of the last record listed.
+type ListCursor struct {
+	Time time.Time
+	ID   string
 }
 
 // CurrenciesRecord an entity representative of Currency table
@@ -103,7 +225,352 @@ type CurrenciesRecord struct {
 	UpdatedBy string
 }
 
-// DBRepository is the database structure
+// HoldRecord an entity representative of Holds table
+type HoldRecord struct {
+	// HoldID related to hold_id column
+	HoldID string
+	// AccountNumber related to account_number column
+	AccountNumber string
+	// Description related to description column
+	Description string
+	// Amount related to amount column
+	Amount int64
+	// Status related to status column, one of the HoldStatus constants
+	Status string
+	// ExpiresAt related to expires_at column
+	ExpiresAt time.Time
+	// JournalID related to journal_id column, the journal that captured the hold
+	JournalID string
+	// CreatedAt related to created_at column
+	CreatedAt time.Time
+	// CreatedBy related to created_by column
+	CreatedBy string
+	// UpdatedAt related to updated_at column
+	UpdatedAt time.Time
+	// UpdatedBy related to updated_by column
+	UpdatedBy string
+}
+
+const (
+	// HoldStatusActive is the status of a hold that still reserves the account fund
+	HoldStatusActive = "ACTIVE"
+	// HoldStatusCaptured is the status of a hold that have been turned into a journal
+	HoldStatusCaptured = "CAPTURED"
+	// HoldStatusVoided is the status of a hold that have been released
+	HoldStatusVoided = "VOIDED"
+	// HoldStatusExpired is the status of a hold that have been released because its expired
+	HoldStatusExpired = "EXPIRED"
+)
+
+// RecurringJournalRecord an entity representative of Recurring_Journals table
+type RecurringJournalRecord struct {
+	// ScheduleID related to schedule_id column
+	ScheduleID string
+	// TenantID related to tenant_id column, the tenant the journal is posted to
+	TenantID string
+	// CronExpression related to cron_expression column
+	CronExpression string
+	// Description related to description column
+	Description string
+	// Transactions related to transactions column, the JSON encoded transactions of the journal to post
+	Transactions string
+	// Metadata related to metadata column, the JSON encoded metadata of the journal to post, empty if it has none
+	Metadata string
+	// Status related to status column, one of the RecurringJournalStatus constants
+	Status string
+	// CreatedAt related to created_at column
+	CreatedAt time.Time
+	// CreatedBy related to created_by column
+	CreatedBy string
+	// UpdatedAt related to updated_at column
+	UpdatedAt time.Time
+	// UpdatedBy related to updated_by column
+	UpdatedBy string
+}
+
+const (
+	// RecurringJournalStatusActive is the status of a recurring journal that is posted on schedule
+	RecurringJournalStatusActive = "ACTIVE"
+	// RecurringJournalStatusPaused is the status of a recurring journal that is not posted until resumed
+	RecurringJournalStatusPaused = "PAUSED"
+)
+
+// RecurringJournalRunRecord an entity representative of Recurring_Journal_Runs table
+type RecurringJournalRunRecord struct {
+	// RunID related to run_id column
+	RunID string
+	// ScheduleID related to schedule_id column
+	ScheduleID string
+	// RunAt related to run_at column
+	RunAt time.Time
+	// Success related to success column
+	Success bool
+	// JournalID related to journal_id column, the posted journal if the run is successful
+	JournalID string
+	// ErrorMessage related to error_message column, the reason of failure if the run is not successful
+	ErrorMessage string
+	// FireTime related to fire_time column, the schedule time the run is claimed for, nil for a manual run
+	FireTime *time.Time
+}
+
+// PendingJournalRecord an entity representative of Pending_Journals table
+type PendingJournalRecord struct {
+	// PendingID related to pending_id column
+	PendingID string
+	// Description related to description column
+	Description string
+	// Transactions related to transactions column, the JSON encoded transactions of the journal to post
+	Transactions string
+	// Metadata related to metadata column, the JSON encoded metadata of the journal to post, empty if it has none
+	Metadata string
+	// ExternalReference related to external_reference column, the external reference of the journal to post, empty if it has none
+	ExternalReference string
+	// Amount related to amount column, the total debit of the journal
+	Amount int64
+	// Status related to status column, one of the PendingJournalStatus constants
+	Status string
+	// JournalID related to journal_id column, the posted journal if the pending journal is approved
+	JournalID string
+	// ReviewNote related to review_note column
+	ReviewNote string
+	// SubmittedAt related to submitted_at column
+	SubmittedAt time.Time
+	// SubmittedBy related to submitted_by column
+	SubmittedBy string
+	// ReviewedAt related to reviewed_at column, nil if not yet reviewed
+	ReviewedAt *time.Time
+	// ReviewedBy related to reviewed_by column
+	ReviewedBy string
+}
+
+const (
+	// PendingJournalStatusPending is the status of a pending journal waiting for approval
+	PendingJournalStatusPending = "PENDING"
+	// PendingJournalStatusApproved is the status of a pending journal that have been approved and posted
+	PendingJournalStatusApproved = "APPROVED"
+	// PendingJournalStatusRejected is the status of a pending journal that have been rejected
+	PendingJournalStatusRejected = "REJECTED"
+)
+
+// ApprovalRuleRecord an entity representative of Approval_Rules table
+type ApprovalRuleRecord struct {
+	// RuleID related to rule_id column
+	RuleID string
+	// Description related to description column
+	Description string
+	// MinAmount related to min_amount column, nil means the rule applies to any amount
+	MinAmount *int64
+	// Coa related to coa column, empty means the rule applies to any account
+	Coa string
+	// CreatedAt related to created_at column
+	CreatedAt time.Time
+	// CreatedBy related to created_by column
+	CreatedBy string
+}
+
+// PostingTemplateRecord an entity representative of Posting_Templates table
+type PostingTemplateRecord struct {
+	// Name related to name column
+	Name string
+	// Description related to description column
+	Description string
+	// Definition related to definition column, the JSON encoded parameters, variables and lines of the template
+	Definition string
+	// CreatedAt related to created_at column
+	CreatedAt time.Time
+	// CreatedBy related to created_by column
+	CreatedBy string
+	// UpdatedAt related to updated_at column
+	UpdatedAt time.Time
+	// UpdatedBy related to updated_by column
+	UpdatedBy string
+}
+
+// IdempotencyKeyRecord an entity representative of Idempotency_Keys table
+type IdempotencyKeyRecord struct {
+	// ClientID related to client_id column, the client who made the request, keys of different clients do not collide
+	ClientID string
+	// Scope related to scope column, the kind of request the key is used for, one of the IdempotencyScope constants
+	Scope string
+	// IdempotencyKey related to idempotency_key column
+	IdempotencyKey string
+	// RequestDigest related to request_digest column, the SHA256 hex digest of the request payload
+	RequestDigest string
+	// ResponseCode related to response_code column, the http status of the original response
+	ResponseCode int
+	// ResourceID related to resource_id column, the journal, pending journal or account created by the request
+	ResourceID string
+	// CreatedAt related to created_at column
+	CreatedAt time.Time
+	// CreatedBy related to created_by column
+	CreatedBy string
+}
+
+const (
+	// IdempotencyScopeJournal is the scope of idempotency keys used to create journals
+	IdempotencyScopeJournal = "JOURNAL"
+	// IdempotencyScopeAccount is the scope of idempotency keys used to create accounts
+	IdempotencyScopeAccount = "ACCOUNT"
+)
+
+// OutboxEventRecord an entity representative of Outbox_Events table
+type OutboxEventRecord struct {
+	// EventID related to event_id column, assigned by the database in the order the events are written
+	EventID int64
+	// TenantID related to tenant_id column, the tenant the event happened in
+	TenantID string
+	// EventType related to event_type column
+	EventType string
+	// Payload related to payload column, the JSON encoded event payload
+	Payload string
+	// Dispatched related to dispatched column, true once the event is fanned out into webhook deliveries
+	Dispatched bool
+	// CreatedAt related to created_at column
+	CreatedAt time.Time
+	// CreatedBy related to created_by column
+	CreatedBy string
+}
+
+// WebhookSubscriptionRecord an entity representative of Webhook_Subscriptions table
+type WebhookSubscriptionRecord struct {
+	// SubscriptionID related to subscription_id column
+	SubscriptionID string
+	// URL related to url column, the endpoint the events are delivered to
+	URL string
+	// EventTypes related to event_types column, comma separated event types or "*" for all events
+	EventTypes string
+	// Secret related to secret column, used to sign the deliveries
+	Secret string
+	// Description related to description column
+	Description string
+	// CreatedAt related to created_at column
+	CreatedAt time.Time
+	// CreatedBy related to created_by column
+	CreatedBy string
+}
+
+// WebhookDeliveryRecord an entity representative of Webhook_Deliveries table
+type WebhookDeliveryRecord struct {
+	// DeliveryID related to delivery_id column
+	DeliveryID int64
+	// TenantID related to tenant_id column, the tenant of the delivered event
+	TenantID string
+	// EventID related to event_id column
+	EventID int64
+	// SubscriptionID related to subscription_id column
+	SubscriptionID string
+	// Status related to status column, one of the WebhookDeliveryStatus constants
+	Status string
+	// Attempts related to attempts column, the number of delivery attempts made
+	Attempts int
+	// NextAttemptAt related to next_attempt_at column
+	NextAttemptAt time.Time
+	// LastResponseCode related to last_response_code column, the http status of the last attempt, 0 if there's no response
+	LastResponseCode int
+	// LastError related to last_error column
+	LastError string
+	// CreatedAt related to created_at column
+	CreatedAt time.Time
+	// UpdatedAt related to updated_at column
+	UpdatedAt time.Time
+	// DeliveredAt related to delivered_at column, nil if not yet delivered
+	DeliveredAt *time.Time
+}
+
+const (
+	// WebhookDeliveryStatusPending is the status of a delivery waiting to be attempted
+	WebhookDeliveryStatusPending = "PENDING"
+	// WebhookDeliveryStatusDelivered is the status of a delivery acknowledged by the subscriber
+	WebhookDeliveryStatusDelivered = "DELIVERED"
+	// WebhookDeliveryStatusDead is the status of a delivery that ran out of attempts, it stays in the dead letter queue until replayed
+	WebhookDeliveryStatusDead = "DEAD"
+)
+
+// AuditLogRecord an entity representative of Audit_Log table
+type AuditLogRecord struct {
+	// AuditID related to audit_id column
+	AuditID int64
+	// TenantID related to tenant_id column, the tenant of the caller
+	TenantID string
+	// Principal related to principal column, the authenticated caller of the request
+	Principal string
+	// RequestID related to request_id column
+	RequestID string
+	// Method related to method column
+	Method string
+	// Endpoint related to endpoint column, the route template of the request
+	Endpoint string
+	// Path related to path column, the actual path of the request
+	Path string
+	// PayloadDigest related to payload_digest column, hex encoded SHA-256 of the request body
+	PayloadDigest string
+	// BeforeSnapshot related to before_snapshot column, JSON of the resource before the change
+	BeforeSnapshot string
+	// AfterSnapshot related to after_snapshot column, JSON of the resource after the change
+	AfterSnapshot string
+	// StatusCode related to status_code column
+	StatusCode int
+	// Outcome related to outcome column, one of the AuditOutcome constants
+	Outcome string
+	// CreatedAt related to created_at column
+	CreatedAt time.Time
+}
+
+// AuditLogFilter specifies the criteria of audit log listing. Empty fields are not filtered.
+type AuditLogFilter struct {
+	Principal string
+	RequestID string
+	Method    string
+	Endpoint  string
+	Outcome   string
+	From      time.Time
+	To        time.Time
+}
+
+const (
+	// AuditOutcomeSuccess is the outcome of a write request that was served with a non error status
+	AuditOutcomeSuccess = "SUCCESS"
+	// AuditOutcomeFailure is the outcome of a write request that was served with an error status
+	AuditOutcomeFailure = "FAILURE"
+)
+
+// APIKeyRecord an entity representative of Api_Keys table
+type APIKeyRecord struct {
+	// KeyID related to key_id column
+	KeyID string
+	// TenantID related to tenant_id column, the tenant whose books the client of the key works on
+	TenantID string
+	// ClientID related to client_id column, the client owning the key
+	ClientID string
+	// KeyHash related to key_hash column, hex encoded SHA-256 of the secret of the key, only used to verify the secret
+	KeyHash string
+	// SigningKey related to signing_key column, the key the client signs its requests with sealed by the api key manager,
+	// empty for the keys issued before the signing keys were sealed
+	SigningKey string
+	// Scopes related to scopes column, comma separated scopes granted to the key
+	Scopes string
+	// Description related to description column
+	Description string
+	// ExpiresAt related to expires_at column, nil if the key never expires
+	ExpiresAt *time.Time
+	// Revoked related to revoked column
+	Revoked bool
+	// RevokedAt related to revoked_at column
+	RevokedAt *time.Time
+	// ReplacedBy related to replaced_by column, the key issued when this key was rotated
+	ReplacedBy string
+	// CreatedAt related to created_at column
+	CreatedAt time.Time
+	// CreatedBy related to created_by column
+	CreatedBy string
+}
+
+// JournalSequence is the name of the sequence numbering the journals
+const JournalSequence = "journal"
+
+// DBRepository is the database structure.
+// Every method works on the books of the tenant carried in the context, see TenantFromContext,
+// except the methods documented to work across the tenants.
 type DBRepository interface {
 	// Connect connect there repository to the database, it uses the configuration internally for connection arguments and parameters.
 	Connect(ctx context.Context) error
@@ -136,6 +603,23 @@ type DBRepository interface {
 	// The AccountNumber contained within the rec MUST be already persisted before.
 	UpdateAccount(ctx context.Context, rec *AccountRecord) error
 
+	// UpdateAccountDetails update the name, description and COA of an account entity record in the database,
+	// leaving its balance, currency, alignment, status and limits untouched.
+	// Throws error if the underlying database connection has problem.
+	// The accountNumber MUST be already persisted before.
+	UpdateAccountDetails(ctx context.Context, accountNumber, name, description, coa string) error
+
+	// UpdateAccountStatus update the status of an account entity record in the database.
+	// Throws error if the underlying database connection has problem.
+	// The accountNumber MUST be already persisted before.
+	UpdateAccountStatus(ctx context.Context, accountNumber, status string) error
+
+	// UpdateAccountLimits update the balance limits of an account entity record in the database.
+	// Throws error if the underlying database connection has problem.
+	// A nil limit will remove that limit from the account.
+	// The accountNumber MUST be already persisted before.
+	UpdateAccountLimits(ctx context.Context, accountNumber string, minBalance, overdraftLimit, maxBalance *int64) error
+
 	// DeleteAccount soft/logical delete an account.
 	// Throws error if the underlying database connection has problem.
 	// If the account number not exist, it will do nothing and return nil.
@@ -147,6 +631,15 @@ type DBRepository interface {
 	// It returns an instance of AccountRecord
 	GetAccount(ctx context.Context, accountNumber string) (*AccountRecord, error)
 
+	// GetAccountForUpdate retrieves an AccountRecord just like GetAccount, and locks the account
+	// until the database transaction carried in the context ends.
+	GetAccountForUpdate(ctx context.Context, accountNumber string) (*AccountRecord, error)
+
+	// GetAccountByExternalReference retrieves the AccountRecord created by the createdBy user with the specified external reference.
+	// Throws error if the underlying database connection has problem.
+	// It returns nil if the user created no Account with the reference.
+	GetAccountByExternalReference(ctx context.Context, createdBy, reference string) (*AccountRecord, error)
+
 	// ListAccount will list account in paginated fashion.
 	// Throws error if the underlying database connection has problem.
 	// It will return AccountRecords sorted, starting from the offset with total maximum number or item, specified
@@ -213,12 +706,34 @@ type DBRepository interface {
 	// It returns an instance of JournalRecord
 	GetJournal(ctx context.Context, journalID string) (*JournalRecord, error)
 
+	// GetJournalForUpdate retrieves a JournalRecord just like GetJournal, and locks the journal
+	// until the database transaction carried in the context ends.
+	GetJournalForUpdate(ctx context.Context, journalID string) (*JournalRecord, error)
+
 	// GetJournalByReversalID retrieves an JournalRecord from database where the reversedJournalID is specified.
 	// Throws error if  the underlying database connection has problem. or, if there is no Journal with
 	// specified reversedJournalID.
 	// It returns an instance of JournalRecord
 	GetJournalByReversalID(ctx context.Context, journalID string) (*JournalRecord, error)
 
+	// GetJournalByExternalReference retrieves the JournalRecord created by the createdBy user with the specified external reference.
+	// Throws error if the underlying database connection has problem.
+	// It returns nil if the user created no Journal with the reference.
+	GetJournalByExternalReference(ctx context.Context, createdBy, reference string) (*JournalRecord, error)
+
+	// ListJournalByReversedJournalID will list all reversal journals of the journal specified by journalID, sorted by journaling time.
+	// Throws error if the underlying database connection has problem.
+	ListJournalByReversedJournalID(ctx context.Context, journalID string) ([]*JournalRecord, error)
+
+	// SumReversedAmountByJournalID sums the amount of all reversal transactions of the journal specified by journalID,
+	// per account number. An account that is not reversed is not in the returned map.
+	// Throws error if the underlying database connection has problem.
+	SumReversedAmountByJournalID(ctx context.Context, journalID string) (map[string]int64, error)
+
+	// SumReversedAmountByJournalIDForUpdate sums the reversed amount just like SumReversedAmountByJournalID, as a locking read
+	// that counts the latest committed reversals and locks them until the database transaction carried in the context ends.
+	SumReversedAmountByJournalIDForUpdate(ctx context.Context, journalID string) (map[string]int64, error)
+
 	// ListJournalByTimeRange will list journals in paginated fashion where journal is in the specified time range.
 	// Throws error if the underlying database connection has problem.
 	// It will return JournalRecord sorted, starting from the offset with total maximum number or item, specified
@@ -231,6 +746,15 @@ type DBRepository interface {
 	// It will returns total number of journals in the database.
 	CountJournalByTimeRange(ctx context.Context, timeFrom, timeTo time.Time) (int, error)
 
+	// ListJournalByMetadata will list journals in paginated fashion where journal is in the specified time range
+	// and its metadata matches the filter, sorted by journaling time.
+	// Throws error if the underlying database connection has problem.
+	ListJournalByMetadata(ctx context.Context, timeFrom, timeTo time.Time, filter MetadataFilter, offset, length int) ([]*JournalRecord, error)
+
+	// CountJournalByMetadata will return the number of journals in the specified time range whose metadata matches the filter.
+	// Throws error if the underlying database connection has problem.
+	CountJournalByMetadata(ctx context.Context, timeFrom, timeTo time.Time, filter MetadataFilter) (int, error)
+
 	// InsertTransaction will insert the data specified in the rec argument into database
 	// will return error if the underlying database connection has problem. or if the
 	// Transaction ID in the journal already in the database.
@@ -275,6 +799,16 @@ type DBRepository interface {
 	// It will returns total number of transaction in the database as specified in the argument.
 	CountTransactionByAccountNumber(ctx context.Context, accountNumber string, timeFrom, timeTo time.Time) (int, error)
 
+	// ListTransactionByAccountNumberAndMetadata will list transactions of the accountNumber in paginated fashion,
+	// the transaction must be created within the time range and its metadata must match the filter.
+	// Throws error if the underlying database connection has problem.
+	ListTransactionByAccountNumberAndMetadata(ctx context.Context, accountNumber string, timeFrom, timeTo time.Time, filter MetadataFilter, offset, length int) ([]*TransactionRecord, error)
+
+	// CountTransactionByAccountNumberAndMetadata will return the number of transactions of the accountNumber
+	// created within the time range whose metadata matches the filter.
+	// Throws error if the underlying database connection has problem.
+	CountTransactionByAccountNumberAndMetadata(ctx context.Context, accountNumber string, timeFrom, timeTo time.Time, filter MetadataFilter) (int, error)
+
 	// ListTransactionByJournalID will list transactions , the transaction must belong to the
 	// specified journalID arguments.
 	// Throws error if the underlying database connection has problem.
@@ -282,6 +816,10 @@ type DBRepository interface {
 	// It returns list of TransactionRecord
 	ListTransactionByJournalID(ctx context.Context, journalID string) ([]*TransactionRecord, error)
 
+	// ListTransactionByJournalIDs retrieves all transactions of the specified journals, sorted by journal and transaction id.
+	// Throws error if the underlying database connection has problem.
+	ListTransactionByJournalIDs(ctx context.Context, journalIDs []string) ([]*TransactionRecord, error)
+
 	// InsertCurrency will insert the data specified in the rec argument into database
 	// will return error if the underlying database connection has problem. or if the
 	// Currency Code already in the database.
@@ -311,4 +849,344 @@ type DBRepository interface {
 	// specified code.
 	// It returns an instance of CurrenciesRecord
 	GetCurrency(ctx context.Context, code string) (*CurrenciesRecord, error)
+
+	// InsertHold will insert the data specified in the rec argument into database
+	// will return error if the underlying database connection has problem. or if the
+	// HoldID already in the database.
+	// Will return the HoldID saved if successful.
+	InsertHold(ctx context.Context, rec *HoldRecord) (string, error)
+
+	// GetHold retrieves a HoldRecord from database where the holdID is specified.
+	// Throws error if  the underlying database connection has problem.
+	// It returns an instance of HoldRecord or nil if record not found
+	GetHold(ctx context.Context, holdID string) (*HoldRecord, error)
+
+	// ListActiveHoldsByAccountNumber list all holds on the account that are still active and not yet expired at the specified time.
+	// Throws error if the underlying database connection has problem.
+	ListActiveHoldsByAccountNumber(ctx context.Context, accountNumber string, at time.Time) ([]*HoldRecord, error)
+
+	// SumActiveHoldsByAccountNumber returns the total amount of holds on the account that are still active and not yet expired
+	// at the specified time.
+	// Throws error if the underlying database connection has problem.
+	SumActiveHoldsByAccountNumber(ctx context.Context, accountNumber string, at time.Time) (int64, error)
+
+	// SumActiveHoldsByAccountNumberForUpdate sums the active holds just like SumActiveHoldsByAccountNumber, as a locking read
+	// that counts the latest committed holds and locks them until the database transaction carried in the context ends.
+	SumActiveHoldsByAccountNumberForUpdate(ctx context.Context, accountNumber string, at time.Time) (int64, error)
+
+	// UpdateHoldStatus change the status of a hold from fromStatus into toStatus, and record the capturing journalID.
+	// It returns false if the hold is not in the fromStatus, thus the status is not changed.
+	// Throws error if the underlying database connection has problem.
+	UpdateHoldStatus(ctx context.Context, holdID, fromStatus, toStatus, journalID string) (bool, error)
+
+	// ExpireHolds change the status of all active holds that have expired at the specified time into HoldStatusExpired,
+	// across the tenants.
+	// It returns the number of expired holds.
+	// Throws error if the underlying database connection has problem.
+	ExpireHolds(ctx context.Context, at time.Time) (int64, error)
+
+	// InsertRecurringJournal will insert the data specified in the rec argument into database
+	// will return error if the underlying database connection has problem. or if the
+	// ScheduleID already in the database.
+	// Will return the ScheduleID saved if successful.
+	InsertRecurringJournal(ctx context.Context, rec *RecurringJournalRecord) (string, error)
+
+	// GetRecurringJournal retrieves a RecurringJournalRecord from database where the scheduleID is specified.
+	// Throws error if  the underlying database connection has problem.
+	// It returns an instance of RecurringJournalRecord or nil if record not found
+	GetRecurringJournal(ctx context.Context, scheduleID string) (*RecurringJournalRecord, error)
+
+	// ListRecurringJournal will list recurring journals in paginated fashion, sorted by creation time.
+	// Throws error if the underlying database connection has problem.
+	ListRecurringJournal(ctx context.Context, offset, length int) ([]*RecurringJournalRecord, error)
+
+	// CountRecurringJournals returns the number of recurring journals in database.
+	// Throws error if the underlying database connection has problem.
+	CountRecurringJournals(ctx context.Context) (int, error)
+
+	// ListRecurringJournalByStatus will list all recurring journals of the specified status, across the tenants.
+	// Throws error if the underlying database connection has problem.
+	ListRecurringJournalByStatus(ctx context.Context, status string) ([]*RecurringJournalRecord, error)
+
+	// UpdateRecurringJournalStatus update the status of a recurring journal.
+	// Throws error if the underlying database connection has problem.
+	UpdateRecurringJournalStatus(ctx context.Context, scheduleID, status string) error
+
+	// GetRecurringJournalForUpdate retrieves a RecurringJournalRecord just like GetRecurringJournal, and locks the recurring journal
+	// until the database transaction carried in the context ends.
+	GetRecurringJournalForUpdate(ctx context.Context, scheduleID string) (*RecurringJournalRecord, error)
+
+	// InsertRecurringJournalRun will insert the data specified in the rec argument into database
+	// Throws error if the underlying database connection has problem, or ErrDuplicateKey if the fire time
+	// of the recurring journal is already run.
+	// Will return the RunID saved if successful.
+	InsertRecurringJournalRun(ctx context.Context, rec *RecurringJournalRunRecord) (string, error)
+
+	// ListRecurringJournalRun will list the runs of a recurring journal in paginated fashion, latest run first.
+	// Throws error if the underlying database connection has problem.
+	ListRecurringJournalRun(ctx context.Context, scheduleID string, offset, length int) ([]*RecurringJournalRunRecord, error)
+
+	// CountRecurringJournalRuns returns the number of runs of a recurring journal in database.
+	// Throws error if the underlying database connection has problem.
+	CountRecurringJournalRuns(ctx context.Context, scheduleID string) (int, error)
+
+	// InsertPendingJournal will insert the data specified in the rec argument into database
+	// will return error if the underlying database connection has problem. or if the
+	// PendingID already in the database.
+	// Will return the PendingID saved if successful.
+	InsertPendingJournal(ctx context.Context, rec *PendingJournalRecord) (string, error)
+
+	// GetPendingJournal retrieves a PendingJournalRecord from database where the pendingID is specified.
+	// Throws error if  the underlying database connection has problem.
+	// It returns an instance of PendingJournalRecord or nil if record not found
+	GetPendingJournal(ctx context.Context, pendingID string) (*PendingJournalRecord, error)
+
+	// ListPendingJournalByStatus will list pending journals of the specified status in paginated fashion, sorted by submission time.
+	// Throws error if the underlying database connection has problem.
+	ListPendingJournalByStatus(ctx context.Context, status string, offset, length int) ([]*PendingJournalRecord, error)
+
+	// CountPendingJournalsByStatus returns the number of pending journals of the specified status in database.
+	// Throws error if the underlying database connection has problem.
+	CountPendingJournalsByStatus(ctx context.Context, status string) (int, error)
+
+	// UpdatePendingJournalStatus change the status of a pending journal from fromStatus into toStatus, and record the reviewer
+	// taken from the context, the review note and the posted journalID.
+	// It returns false if the pending journal is not in the fromStatus, thus the status is not changed.
+	// Throws error if the underlying database connection has problem.
+	UpdatePendingJournalStatus(ctx context.Context, pendingID, fromStatus, toStatus, journalID, note string) (bool, error)
+
+	// InsertApprovalRule will insert the data specified in the rec argument into database
+	// will return error if the underlying database connection has problem. or if the
+	// RuleID already in the database.
+	// Will return the RuleID saved if successful.
+	InsertApprovalRule(ctx context.Context, rec *ApprovalRuleRecord) (string, error)
+
+	// ListApprovalRules will list all approval rules, sorted by creation time.
+	// Throws error if the underlying database connection has problem.
+	ListApprovalRules(ctx context.Context) ([]*ApprovalRuleRecord, error)
+
+	// DeleteApprovalRule will delete the approval rule of the specified ruleID.
+	// It returns false if there is no such rule.
+	// Throws error if the underlying database connection has problem.
+	DeleteApprovalRule(ctx context.Context, ruleID string) (bool, error)
+
+	// SavePostingTemplate will insert the data specified in the rec argument into database, or update
+	// the posting template of the same name if its already in the database.
+	// Throws error if the underlying database connection has problem.
+	SavePostingTemplate(ctx context.Context, rec *PostingTemplateRecord) error
+
+	// GetPostingTemplate retrieves a PostingTemplateRecord from database where the name is specified.
+	// Throws error if  the underlying database connection has problem.
+	// It returns an instance of PostingTemplateRecord or nil if record not found
+	GetPostingTemplate(ctx context.Context, name string) (*PostingTemplateRecord, error)
+
+	// ListPostingTemplates will list all posting templates, sorted by name.
+	// Throws error if the underlying database connection has problem.
+	ListPostingTemplates(ctx context.Context) ([]*PostingTemplateRecord, error)
+
+	// DeletePostingTemplate will delete the posting template of the specified name.
+	// It returns false if there is no such template.
+	// Throws error if the underlying database connection has problem.
+	DeletePostingTemplate(ctx context.Context, name string) (bool, error)
+
+	// InsertIdempotencyKey will insert the idempotency key specified in the rec argument into database, along with the resource
+	// created by the request in the database transaction carried in the context.
+	// Throws error if the underlying database connection has problem, or ErrDuplicateKey if the client already used the key
+	// within the same scope.
+	InsertIdempotencyKey(ctx context.Context, rec *IdempotencyKeyRecord) error
+
+	// GetIdempotencyKey retrieves an IdempotencyKeyRecord from database where the client, scope and key is specified.
+	// Throws error if  the underlying database connection has problem.
+	// It returns an instance of IdempotencyKeyRecord or nil if record not found
+	GetIdempotencyKey(ctx context.Context, clientID, scope, key string) (*IdempotencyKeyRecord, error)
+
+	// InsertOutboxEvent will insert the event specified in the rec argument into database as not yet dispatched.
+	// Throws error if the underlying database connection has problem.
+	// Will return the EventID assigned by the database if successful.
+	InsertOutboxEvent(ctx context.Context, rec *OutboxEventRecord) (int64, error)
+
+	// GetOutboxEvent retrieves an OutboxEventRecord from database where the eventID is specified.
+	// Throws error if  the underlying database connection has problem.
+	// It returns an instance of OutboxEventRecord or nil if record not found
+	GetOutboxEvent(ctx context.Context, eventID int64) (*OutboxEventRecord, error)
+
+	// ListUndispatchedOutboxEvents will list events not yet dispatched across the tenants, oldest event first, up to the specified length.
+	// Throws error if the underlying database connection has problem.
+	ListUndispatchedOutboxEvents(ctx context.Context, length int) ([]*OutboxEventRecord, error)
+
+	// MarkOutboxEventDispatched mark the event of the specified eventID as dispatched.
+	// It returns false if the event is already dispatched.
+	// Throws error if the underlying database connection has problem.
+	MarkOutboxEventDispatched(ctx context.Context, eventID int64) (bool, error)
+
+	// InsertWebhookSubscription will insert the data specified in the rec argument into database
+	// will return error if the underlying database connection has problem. or if the
+	// SubscriptionID already in the database.
+	// Will return the SubscriptionID saved if successful.
+	InsertWebhookSubscription(ctx context.Context, rec *WebhookSubscriptionRecord) (string, error)
+
+	// GetWebhookSubscription retrieves a WebhookSubscriptionRecord from database where the subscriptionID is specified.
+	// Throws error if  the underlying database connection has problem.
+	// It returns an instance of WebhookSubscriptionRecord or nil if record not found
+	GetWebhookSubscription(ctx context.Context, subscriptionID string) (*WebhookSubscriptionRecord, error)
+
+	// ListWebhookSubscriptions will list all webhook subscriptions, sorted by creation time.
+	// Throws error if the underlying database connection has problem.
+	ListWebhookSubscriptions(ctx context.Context) ([]*WebhookSubscriptionRecord, error)
+
+	// DeleteWebhookSubscription will delete the webhook subscription of the specified subscriptionID.
+	// It returns false if there is no such subscription.
+	// Throws error if the underlying database connection has problem.
+	DeleteWebhookSubscription(ctx context.Context, subscriptionID string) (bool, error)
+
+	// InsertWebhookDelivery will insert the delivery specified in the rec argument into database as PENDING.
+	// It returns false if the event is already scheduled for delivery to the same subscription.
+	// Throws error if the underlying database connection has problem.
+	InsertWebhookDelivery(ctx context.Context, rec *WebhookDeliveryRecord) (bool, error)
+
+	// GetWebhookDelivery retrieves a WebhookDeliveryRecord from database where the deliveryID is specified.
+	// Throws error if  the underlying database connection has problem.
+	// It returns an instance of WebhookDeliveryRecord or nil if record not found
+	GetWebhookDelivery(ctx context.Context, deliveryID int64) (*WebhookDeliveryRecord, error)
+
+	// ListWebhookDeliveries will list deliveries of a subscription in paginated fashion, latest delivery first.
+	// An empty status lists deliveries of any status.
+	// Throws error if the underlying database connection has problem.
+	ListWebhookDeliveries(ctx context.Context, subscriptionID, status string, offset, length int) ([]*WebhookDeliveryRecord, error)
+
+	// CountWebhookDeliveries returns the number of deliveries of a subscription in database.
+	// An empty status counts deliveries of any status.
+	// Throws error if the underlying database connection has problem.
+	CountWebhookDeliveries(ctx context.Context, subscriptionID, status string) (int, error)
+
+	// ListDueWebhookDeliveries will list PENDING deliveries across the tenants whose next attempt is due at the specified time,
+	// up to the specified length.
+	// Throws error if the underlying database connection has problem.
+	ListDueWebhookDeliveries(ctx context.Context, now time.Time, length int) ([]*WebhookDeliveryRecord, error)
+
+	// ClaimWebhookDelivery count a new attempt of a PENDING delivery that have made the specified number of attempts,
+	// and postpone its next attempt to nextAttemptAt, so no other worker attempt it at the same time.
+	// It returns false if the delivery is no longer PENDING or is claimed by another worker.
+	// Throws error if the underlying database connection has problem.
+	ClaimWebhookDelivery(ctx context.Context, deliveryID int64, attempts int, nextAttemptAt time.Time) (bool, error)
+
+	// UpdateWebhookDeliveryResult records the outcome of the last attempt of a PENDING delivery and change its status.
+	// It returns false if the delivery is no longer PENDING.
+	// Throws error if the underlying database connection has problem.
+	UpdateWebhookDeliveryResult(ctx context.Context, deliveryID int64, status string, responseCode int, lastError string) (bool, error)
+
+	// ReplayWebhookDelivery put a delivery that is not PENDING back into PENDING, with its attempts reset,
+	// so it is delivered again.
+	// It returns false if there is no such delivery or it is still PENDING.
+	// Throws error if the underlying database connection has problem.
+	ReplayWebhookDelivery(ctx context.Context, deliveryID int64) (bool, error)
+
+	// ReplayDeadWebhookDeliveries put all DEAD deliveries of a subscription back into PENDING, with their attempts reset.
+	// It returns the number of deliveries replayed.
+	// Throws error if the underlying database connection has problem.
+	ReplayDeadWebhookDeliveries(ctx context.Context, subscriptionID string) (int, error)
+
+	// NextSequence increments the sequence of the specified name and returns its new value, starting from 1.
+	// The sequence row stays locked until the database transaction carried in the context ends, so the values are
+	// gapless and handed out in commit order.
+	// Throws error if the underlying database connection has problem.
+	NextSequence(ctx context.Context, name string) (int64, error)
+
+	// ListJournalsAfterSequence will list journals whose sequence is greater than afterSequence, in sequence order,
+	// up to the specified length.
+	// Throws error if the underlying database connection has problem.
+	ListJournalsAfterSequence(ctx context.Context, afterSequence int64, length int) ([]*JournalRecord, error)
+
+	// InsertAuditLog will insert the audit entry specified in the rec argument into database.
+	// Throws error if the underlying database connection has problem.
+	// Will return the AuditID assigned by the database if successful.
+	InsertAuditLog(ctx context.Context, rec *AuditLogRecord) (int64, error)
+
+	// ListAuditLogs will list audit entries matching the filter in paginated fashion, latest entry first.
+	// Throws error if the underlying database connection has problem.
+	ListAuditLogs(ctx context.Context, filter *AuditLogFilter, offset, length int) ([]*AuditLogRecord, error)
+
+	// CountAuditLogs returns the number of audit entries matching the filter in database.
+	// Throws error if the underlying database connection has problem.
+	CountAuditLogs(ctx context.Context, filter *AuditLogFilter) (int, error)
+
+	// SearchTransactions will list the transactions matching the filter in paginated fashion, in the sort order.
+	// The transaction id breaks the ties, the transactions are sorted by transaction time when no sort is given.
+	// Throws error if the underlying database connection has problem, or a sort field is unknown.
+	SearchTransactions(ctx context.Context, filter *TransactionSearchFilter, sorts []SearchSort, offset, length int) ([]*TransactionRecord, error)
+
+	// CountTransactionSearch returns the number of transactions matching the filter.
+	// Throws error if the underlying database connection has problem.
+	CountTransactionSearch(ctx context.Context, filter *TransactionSearchFilter) (int, error)
+
+	// SearchJournals will list the journals matching the filter in paginated fashion, in the sort order.
+	// The journal id breaks the ties, the journals are sorted by journaling time when no sort is given.
+	// Throws error if the underlying database connection has problem, or a sort field is unknown.
+	SearchJournals(ctx context.Context, filter *JournalSearchFilter, sorts []SearchSort, offset, length int) ([]*JournalRecord, error)
+
+	// CountJournalSearch returns the number of journals matching the filter.
+	// Throws error if the underlying database connection has problem.
+	CountJournalSearch(ctx context.Context, filter *JournalSearchFilter) (int, error)
+
+	// ListTransactionByAccountNumberAfter will list up to length transactions of the accountNumber created within
+	// the time range whose metadata matches the filter, sorted by transaction time then transaction id, starting
+	// after the cursor, or from the first one if the cursor is nil.
+	// Throws error if the underlying database connection has problem.
+	ListTransactionByAccountNumberAfter(ctx context.Context, accountNumber string, timeFrom, timeTo time.Time, filter MetadataFilter, after *ListCursor, length int) ([]*TransactionRecord, error)
+
+	// ListJournalByTimeRangeAfter will list up to length journals within the time range whose metadata matches the filter,
+	// sorted by journaling time then journal id, starting after the cursor, or from the first one if the cursor is nil.
+	// Throws error if the underlying database connection has problem.
+	ListJournalByTimeRangeAfter(ctx context.Context, timeFrom, timeTo time.Time, filter MetadataFilter, after *ListCursor, length int) ([]*JournalRecord, error)
+
+	// GetAccountBalanceAt returns the balance the account had after its transactions up to the time at,
+	// that is its current balance less the transactions made after.
+	// Throws error if the underlying database connection has problem, or sql.ErrNoRows if the account does not exist.
+	GetAccountBalanceAt(ctx context.Context, accountNumber string, at time.Time) (int64, error)
+
+	// InsertAPIKey will insert the api key specified in the rec argument into database, for the tenant of the rec.
+	// Throws error if the underlying database connection has problem.
+	InsertAPIKey(ctx context.Context, rec *APIKeyRecord) error
+
+	// GetAPIKey retrieves an APIKeyRecord from database where the keyID is specified, across the tenants
+	// since the key tells the tenant of its client.
+	// Throws error if  the underlying database connection has problem.
+	// It returns an instance of APIKeyRecord or nil if record not found
+	GetAPIKey(ctx context.Context, keyID string) (*APIKeyRecord, error)
+
+	// ListAPIKeys will list the api keys of a client, latest key first. An empty clientID lists the keys of all clients.
+	// Throws error if the underlying database connection has problem.
+	ListAPIKeys(ctx context.Context, clientID string) ([]*APIKeyRecord, error)
+
+	// RevokeAPIKey revokes the api key of the specified keyID, replacedBy is the key issued in its place, if any.
+	// Returns true if the key was revoked, false if it is not found or already revoked.
+	// Throws error if the underlying database connection has problem.
+	RevokeAPIKey(ctx context.Context, keyID, replacedBy string) (bool, error)
+
+	// IncrementRateLimit counts a request of the key in the window starting at windowStart and ending at windowEnd,
+	// and returns the number of requests of the key counted in the window so far.
+	// Throws error if the underlying database connection has problem.
+	IncrementRateLimit(ctx context.Context, key string, windowStart, windowEnd time.Time) (int, error)
+
+	// DeleteRateLimitsBefore deletes the counts of the windows ended at or before the specified time.
+	// It returns the number of deleted counts.
+	// Throws error if the underlying database connection has problem.
+	DeleteRateLimitsBefore(ctx context.Context, before time.Time) (int64, error)
+
+	// InsertTenant will insert the tenant specified in the rec argument into database.
+	// Throws error if the underlying database connection has problem, or the tenant already exist.
+	InsertTenant(ctx context.Context, rec *TenantRecord) error
+
+	// GetTenant retrieves a TenantRecord from database where the tenantID is specified.
+	// Throws error if  the underlying database connection has problem.
+	// It returns an instance of TenantRecord or nil if record not found
+	GetTenant(ctx context.Context, tenantID string) (*TenantRecord, error)
+
+	// ListTenants will list the tenants in paginated fashion, sorted by their tenant id.
+	// Throws error if the underlying database connection has problem.
+	ListTenants(ctx context.Context, offset, length int) ([]*TenantRecord, error)
+
+	// CountTenants returns the number of tenants in database.
+	// Throws error if the underlying database connection has problem.
+	CountTenants(ctx context.Context) (int, error)
 }
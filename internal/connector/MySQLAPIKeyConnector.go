@@ -0,0 +1,162 @@
+package connector
+
+import (
+	"context"
+	"database/sql"
+	"html"
+	"time"
+
+	"github.com/hyperjumptech/bookkeeping/errors"
+	"github.com/hyperjumptech/bookkeeping/internal/contextkeys"
+)
+
+const apiKeyColumns = "key_id, tenant_id, client_id, key_hash, signing_key, scopes, description, expires_at, revoked, revoked_at, replaced_by, created_at, created_by"
+
+// scanAPIKey scan a row of the api_keys table
+func scanAPIKey(scanner interface{ Scan(...interface{}) error }) (*APIKeyRecord, error) {
+	kr := &APIKeyRecord{}
+	var signingKey, description, replacedBy sql.NullString
+	var expiresAt, revokedAt sql.NullTime
+	err := scanner.Scan(&kr.KeyID, &kr.TenantID, &kr.ClientID, &kr.KeyHash, &signingKey, &kr.Scopes, &description, &expiresAt, &kr.Revoked, &revokedAt,
+		&replacedBy, &kr.CreatedAt, &kr.CreatedBy)
+	if err != nil {
+		return nil, err
+	}
+	kr.SigningKey = signingKey.String
+	kr.Description = description.String
+	kr.ReplacedBy = replacedBy.String
+	if expiresAt.Valid {
+		kr.ExpiresAt = &expiresAt.Time
+	}
+	if revokedAt.Valid {
+		kr.RevokedAt = &revokedAt.Time
+	}
+	return kr, nil
+}
+
+// InsertAPIKey will insert the api key specified in the rec argument into database, for the tenant of the rec
+// or the tenant carried in the context if the rec have none.
+// Throws error if the underlying database connection has problem.
+func (repo *MySQLDBRepository) InsertAPIKey(ctx context.Context, rec *APIKeyRecord) error {
+	lLog := mysqlLog.WithField("function", "InsertAPIKey")
+
+	theUser, ok := ctx.Value(contextkeys.UserIDContextKey).(string)
+	if !ok {
+		lLog.Errorf("UserContext Key %s is not in context", contextkeys.UserIDContextKey)
+		return errors.ErrUserContextKeyMissing
+	}
+	if len(theUser) > 16 {
+		theUser = theUser[:16]
+	}
+
+	if len(rec.KeyID) > 20 {
+		lLog.Errorf("KeyID %s is too long. Should not more than 20 digit", rec.KeyID)
+		return errors.ErrStringDataTooLong
+	}
+	if len(rec.TenantID) == 0 {
+		rec.TenantID = TenantFromContext(ctx)
+	}
+	if len(rec.TenantID) > 16 {
+		lLog.Errorf("TenantID %s is too long. Should not more than 16 digit", rec.TenantID)
+		return errors.ErrStringDataTooLong
+	}
+	if len(rec.ClientID) > 16 {
+		lLog.Errorf("ClientID %s is too long. Should not more than 16 digit", rec.ClientID)
+		return errors.ErrStringDataTooLong
+	}
+	if len(rec.SigningKey) > 128 {
+		lLog.Errorf("SigningKey is too long. Should not more than 128 digit")
+		return errors.ErrStringDataTooLong
+	}
+	if len(rec.Scopes) > 255 {
+		lLog.Errorf("Scopes %s is too long. Should not more than 255 digit", rec.Scopes)
+		return errors.ErrStringDataTooLong
+	}
+	if len(rec.Description) > 255 {
+		lLog.Errorf("Description %s is too long. Should not more than 255 digit", rec.Description)
+		return errors.ErrStringDataTooLong
+	}
+
+	rec.Revoked = false
+	rec.CreatedBy = html.EscapeString(theUser)
+	rec.CreatedAt = time.Now()
+	q := "INSERT INTO api_keys(key_id, tenant_id, client_id, key_hash, signing_key, scopes, description, expires_at, revoked, created_at, created_by) VALUES(?, ?, ?, ?, ?, ?, ?, ?, FALSE, ?, ?)"
+	_, err := repo.conn(ctx).ExecContext(ctx, q, rec.KeyID, rec.TenantID, rec.ClientID, rec.KeyHash, rec.SigningKey, rec.Scopes, html.EscapeString(rec.Description),
+		rec.ExpiresAt, rec.CreatedAt, rec.CreatedBy)
+	if err != nil {
+		lLog.Errorf("error while inserting api key. got %s", err.Error())
+		return err
+	}
+	return nil
+}
+
+// GetAPIKey retrieves an APIKeyRecord from database where the keyID is specified, across the tenants
+// since the key tells the tenant of its client.
+// Throws error if  the underlying database connection has problem.
+// It returns an instance of APIKeyRecord or nil if record not found
+func (repo *MySQLDBRepository) GetAPIKey(ctx context.Context, keyID string) (*APIKeyRecord, error) {
+	lLog := mysqlLog.WithField("function", "GetAPIKey")
+	q := "SELECT " + apiKeyColumns + " FROM api_keys WHERE key_id=?"
+	row := repo.conn(ctx).QueryRowxContext(ctx, q, keyID)
+	if row.Err() != nil {
+		lLog.Errorf("error while retrieving api key. got %s", row.Err().Error())
+		return nil, row.Err()
+	}
+	kr, err := scanAPIKey(row)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		lLog.Errorf("error while scanning api key record. got %s", err.Error())
+		return nil, err
+	}
+	return kr, nil
+}
+
+// ListAPIKeys will list the api keys of a client, latest key first. An empty clientID lists the keys of all clients.
+// Throws error if the underlying database connection has problem.
+func (repo *MySQLDBRepository) ListAPIKeys(ctx context.Context, clientID string) ([]*APIKeyRecord, error) {
+	lLog := mysqlLog.WithField("function", "ListAPIKeys")
+	q := "SELECT " + apiKeyColumns + " FROM api_keys WHERE tenant_id=?"
+	args := []interface{}{TenantFromContext(ctx)}
+	if len(clientID) > 0 {
+		q += " AND client_id=?"
+		args = append(args, clientID)
+	}
+	q += " ORDER BY created_at DESC"
+	rows, err := repo.conn(ctx).QueryxContext(ctx, q, args...)
+	if err != nil {
+		lLog.Errorf("error while listing api keys. got %s", err.Error())
+		return nil, err
+	}
+	defer rows.Close()
+	ret := make([]*APIKeyRecord, 0)
+	for rows.Next() {
+		kr, err := scanAPIKey(rows)
+		if err != nil {
+			lLog.Errorf("error while scanning rows in ListAPIKeys function. got %s", err.Error())
+		} else {
+			ret = append(ret, kr)
+		}
+	}
+	return ret, nil
+}
+
+// RevokeAPIKey revokes the api key of the specified keyID, replacedBy is the key issued in its place, if any.
+// Returns true if the key was revoked, false if it is not found or already revoked.
+// Throws error if the underlying database connection has problem.
+func (repo *MySQLDBRepository) RevokeAPIKey(ctx context.Context, keyID, replacedBy string) (bool, error) {
+	lLog := mysqlLog.WithField("function", "RevokeAPIKey")
+	q := "UPDATE api_keys SET revoked=TRUE, revoked_at=?, replaced_by=? WHERE tenant_id=? AND key_id=? AND revoked=FALSE"
+	res, err := repo.conn(ctx).ExecContext(ctx, q, time.Now(), sql.NullString{String: replacedBy, Valid: len(replacedBy) > 0}, TenantFromContext(ctx), keyID)
+	if err != nil {
+		lLog.Errorf("error while revoking api key. got %s", err.Error())
+		return false, err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		lLog.Errorf("error while reading affected rows of api key revocation. got %s", err.Error())
+		return false, err
+	}
+	return affected == 1, nil
+}
@@ -0,0 +1,139 @@
+package connector
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+const auditLogColumns = "audit_id, tenant_id, principal, request_id, method, endpoint, path, payload_digest, before_snapshot, after_snapshot, status_code, outcome, created_at"
+
+// truncate cuts the string s so it is not longer than the length l
+func truncate(s string, l int) string {
+	if len(s) > l {
+		return s[:l]
+	}
+	return s
+}
+
+// InsertAuditLog will insert the audit entry specified in the rec argument into database.
+// Values longer than their column are truncated, an audit entry is never refused because of its size.
+// Throws error if the underlying database connection has problem.
+// Will return the AuditID assigned by the database if successful.
+func (repo *MySQLDBRepository) InsertAuditLog(ctx context.Context, rec *AuditLogRecord) (int64, error) {
+	lLog := mysqlLog.WithField("function", "InsertAuditLog")
+
+	rec.Principal = truncate(rec.Principal, 64)
+	rec.RequestID = truncate(rec.RequestID, 64)
+	rec.Method = truncate(rec.Method, 8)
+	rec.Endpoint = truncate(rec.Endpoint, 255)
+	rec.Path = truncate(rec.Path, 512)
+	if len(rec.TenantID) == 0 {
+		rec.TenantID = TenantFromContext(ctx)
+	}
+	if rec.CreatedAt.IsZero() {
+		rec.CreatedAt = time.Now()
+	}
+	q := "INSERT INTO audit_log(tenant_id, principal, request_id, method, endpoint, path, payload_digest, before_snapshot, after_snapshot, status_code, outcome, created_at) VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
+	res, err := repo.conn(ctx).ExecContext(ctx, q, rec.TenantID, rec.Principal, rec.RequestID, rec.Method, rec.Endpoint, rec.Path, rec.PayloadDigest,
+		sql.NullString{String: rec.BeforeSnapshot, Valid: len(rec.BeforeSnapshot) > 0},
+		sql.NullString{String: rec.AfterSnapshot, Valid: len(rec.AfterSnapshot) > 0},
+		rec.StatusCode, rec.Outcome, rec.CreatedAt)
+	if err != nil {
+		lLog.Errorf("error while inserting audit log. got %s", err.Error())
+		return 0, err
+	}
+	rec.AuditID, err = res.LastInsertId()
+	if err != nil {
+		lLog.Errorf("error while reading the id of inserted audit log. got %s", err.Error())
+		return 0, err
+	}
+	return rec.AuditID, nil
+}
+
+// auditLogWhere builds the WHERE clause and its arguments out of the tenant and the filter
+func auditLogWhere(tenantID string, filter *AuditLogFilter) (string, []interface{}) {
+	q := " WHERE tenant_id=?"
+	args := []interface{}{tenantID}
+	if filter == nil {
+		return q, args
+	}
+	if len(filter.Principal) > 0 {
+		q += " AND principal=?"
+		args = append(args, filter.Principal)
+	}
+	if len(filter.RequestID) > 0 {
+		q += " AND request_id=?"
+		args = append(args, filter.RequestID)
+	}
+	if len(filter.Method) > 0 {
+		q += " AND method=?"
+		args = append(args, filter.Method)
+	}
+	if len(filter.Endpoint) > 0 {
+		q += " AND endpoint=?"
+		args = append(args, filter.Endpoint)
+	}
+	if len(filter.Outcome) > 0 {
+		q += " AND outcome=?"
+		args = append(args, filter.Outcome)
+	}
+	if !filter.From.IsZero() {
+		q += " AND created_at>=?"
+		args = append(args, filter.From)
+	}
+	if !filter.To.IsZero() {
+		q += " AND created_at<?"
+		args = append(args, filter.To)
+	}
+	return q, args
+}
+
+// ListAuditLogs will list audit entries matching the filter in paginated fashion, latest entry first.
+// Throws error if the underlying database connection has problem.
+func (repo *MySQLDBRepository) ListAuditLogs(ctx context.Context, filter *AuditLogFilter, offset, length int) ([]*AuditLogRecord, error) {
+	lLog := mysqlLog.WithField("function", "ListAuditLogs")
+	where, args := auditLogWhere(TenantFromContext(ctx), filter)
+	q := "SELECT " + auditLogColumns + " FROM audit_log" + where + " ORDER BY audit_id DESC LIMIT ?,?"
+	args = append(args, offset, length)
+	rows, err := repo.conn(ctx).QueryxContext(ctx, q, args...)
+	if err != nil {
+		lLog.Errorf("error while listing audit logs. got %s", err.Error())
+		return nil, err
+	}
+	defer rows.Close()
+	ret := make([]*AuditLogRecord, 0)
+	for rows.Next() {
+		rec := &AuditLogRecord{}
+		var before, after sql.NullString
+		err := rows.Scan(&rec.AuditID, &rec.TenantID, &rec.Principal, &rec.RequestID, &rec.Method, &rec.Endpoint, &rec.Path, &rec.PayloadDigest,
+			&before, &after, &rec.StatusCode, &rec.Outcome, &rec.CreatedAt)
+		if err != nil {
+			lLog.Errorf("error while scanning rows in ListAuditLogs function. got %s", err.Error())
+		} else {
+			rec.BeforeSnapshot = before.String
+			rec.AfterSnapshot = after.String
+			ret = append(ret, rec)
+		}
+	}
+	return ret, nil
+}
+
+// CountAuditLogs returns the number of audit entries matching the filter in database.
+// Throws error if the underlying database connection has problem.
+func (repo *MySQLDBRepository) CountAuditLogs(ctx context.Context, filter *AuditLogFilter) (int, error) {
+	lLog := mysqlLog.WithField("function", "CountAuditLogs")
+	where, args := auditLogWhere(TenantFromContext(ctx), filter)
+	row := repo.conn(ctx).QueryRowxContext(ctx, "SELECT COUNT(*) FROM audit_log"+where, args...)
+	if row.Err() != nil {
+		lLog.Errorf("error while counting audit logs. got %s", row.Err().Error())
+		return 0, row.Err()
+	}
+	count := 0
+	err := row.Scan(&count)
+	if err != nil {
+		lLog.Errorf("error while scanning count of audit logs. got %s", err.Error())
+		return 0, err
+	}
+	return count, nil
+}
@@ -0,0 +1,75 @@
+package connector
+
+import (
+	"context"
+	"time"
+)
+
+// ListTransactionByAccountNumberAfter will list up to length transactions of the accountNumber created within
+// the time range whose metadata matches the filter, sorted by transaction time then transaction id, starting
+// after the cursor, or from the first one if the cursor is nil.
+// Unlike ListTransactionByAccountNumber, the rows before the cursor are not read, they are skipped using the
+// (tenant_id, account_number, transaction_time) index, which carries the transaction id as the primary key.
+// Throws error if the underlying database connection has problem.
+func (repo *MySQLDBRepository) ListTransactionByAccountNumberAfter(ctx context.Context, accountNumber string, timeFrom, timeTo time.Time, filter MetadataFilter, after *ListCursor, length int) ([]*TransactionRecord, error) {
+	lLog := mysqlLog.WithField("function", "ListTransactionByAccountNumberAfter")
+	cond, condArgs := metadataCondition(filter)
+	q := "SELECT " + transactionColumns +
+		" FROM transactions WHERE tenant_id=? AND account_number=? AND transaction_time > ? AND transaction_time < ? AND is_deleted=false" + cond
+	args := append([]interface{}{TenantFromContext(ctx), accountNumber, timeFrom, timeTo}, condArgs...)
+	if after != nil {
+		q += " AND (transaction_time > ? OR (transaction_time = ? AND transaction_id > ?))"
+		args = append(args, after.Time, after.Time, after.ID)
+	}
+	q += " ORDER BY transaction_time ASC, transaction_id ASC LIMIT ?"
+	rows, err := repo.conn(ctx).QueryxContext(ctx, q, append(args, length)...)
+	if err != nil {
+		lLog.Errorf("error while listing transaction by account number after cursor. got %s", err.Error())
+		return nil, err
+	}
+	defer rows.Close()
+	ret := make([]*TransactionRecord, 0)
+	for rows.Next() {
+		tr, err := scanTransaction(rows)
+		// a row that can not be read must not be skipped, or the next cursor would jump over it
+		if err != nil {
+			lLog.Errorf("error while scanning rows in ListTransactionByAccountNumberAfter function. got %s", err.Error())
+			return nil, err
+		}
+		ret = append(ret, tr)
+	}
+	return ret, nil
+}
+
+// ListJournalByTimeRangeAfter will list up to length journals within the time range whose metadata matches the filter,
+// sorted by journaling time then journal id, starting after the cursor, or from the first one if the cursor is nil.
+// Throws error if the underlying database connection has problem.
+func (repo *MySQLDBRepository) ListJournalByTimeRangeAfter(ctx context.Context, timeFrom, timeTo time.Time, filter MetadataFilter, after *ListCursor, length int) ([]*JournalRecord, error) {
+	lLog := mysqlLog.WithField("function", "ListJournalByTimeRangeAfter")
+	cond, condArgs := metadataCondition(filter)
+	q := "SELECT " + journalColumns +
+		" FROM journals WHERE tenant_id=? AND journaling_time > ? AND journaling_time < ? AND is_deleted=false" + cond
+	args := append([]interface{}{TenantFromContext(ctx), timeFrom, timeTo}, condArgs...)
+	if after != nil {
+		q += " AND (journaling_time > ? OR (journaling_time = ? AND journal_id > ?))"
+		args = append(args, after.Time, after.Time, after.ID)
+	}
+	q += " ORDER BY journaling_time ASC, journal_id ASC LIMIT ?"
+	rows, err := repo.conn(ctx).QueryxContext(ctx, q, append(args, length)...)
+	if err != nil {
+		lLog.Errorf("error while listing journals by time range after cursor. got %s", err.Error())
+		return nil, err
+	}
+	defer rows.Close()
+	ret := make([]*JournalRecord, 0)
+	for rows.Next() {
+		jr, err := scanJournal(rows)
+		// a row that can not be read must not be skipped, or the next cursor would jump over it
+		if err != nil {
+			lLog.Errorf("error while scanning rows in ListJournalByTimeRangeAfter function. got %s", err.Error())
+			return nil, err
+		}
+		ret = append(ret, jr)
+	}
+	return ret, nil
+}
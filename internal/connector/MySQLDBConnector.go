@@ -5,10 +5,13 @@ import (
 	"database/sql"
 	"fmt"
 	"html"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/hyperjumptech/bookkeeping/internal/contextkeys"
 
+	"github.com/go-sql-driver/mysql"
 	"github.com/hyperjumptech/acccore"
 	"github.com/hyperjumptech/bookkeeping/errors"
 	"github.com/hyperjumptech/bookkeeping/internal/config"
@@ -28,9 +31,9 @@ type MySQLDBRepository struct {
 // ClearTables clear all table for testing purpose
 func (repo *MySQLDBRepository) ClearTables(ctx context.Context) error {
 	lLog := mysqlLog.WithField("function", "ClearTables")
-	tablesToDrop := []string{"accounts", "currencies", "journals", "transactions"}
+	tablesToDrop := []string{"accounts", "currencies", "journals", "transactions", "holds", "recurring_journals", "recurring_journal_runs", "pending_journals", "approval_rules", "posting_templates", "idempotency_keys", "outbox_events", "webhook_subscriptions", "webhook_deliveries", "ledger_sequences", "audit_log", "api_keys", "rate_limits", "tenants"}
 	for _, t := range tablesToDrop {
-		_, err := repo.db.ExecContext(ctx, fmt.Sprintf("DELETE FROM %s", t))
+		_, err := repo.conn(ctx).ExecContext(ctx, fmt.Sprintf("DELETE FROM %s", t))
 		if err != nil {
 			lLog.Errorf("error dropping table %s. got %s", t, err.Error())
 			return err
@@ -98,6 +101,21 @@ func (repo *MySQLDBRepository) DB() *sqlx.DB {
 	return repo.db
 }
 
+// conn returns the database transaction carried in the context under contextkeys.DBTransactionContextKey,
+// or the database connection if there's no transaction going on.
+func (repo *MySQLDBRepository) conn(ctx context.Context) sqlx.ExtContext {
+	if tx, ok := ctx.Value(contextkeys.DBTransactionContextKey).(*sqlx.Tx); ok && tx != nil {
+		return tx
+	}
+	return repo.db
+}
+
+// isDuplicateKey tells if the error is MySQL refusing a row with the same unique key as an existing row
+func isDuplicateKey(err error) bool {
+	mysqlErr, ok := err.(*mysql.MySQLError)
+	return ok && mysqlErr.Number == 1062
+}
+
 // InsertAccount insert an entity record of account into database.
 // Throws error if the underlying connection have problem.
 // The rec argument contains the Account information to be written.
@@ -122,6 +140,10 @@ func (repo *MySQLDBRepository) InsertAccount(ctx context.Context, rec *AccountRe
 		lLog.Errorf("COA %s is too long. Should not more than 10 digit", rec.Coa)
 		return "", errors.ErrStringDataTooLong
 	}
+	if len(rec.ExternalReference) > 64 {
+		lLog.Errorf("External reference %s is too long. Should not more than 64 digit", rec.ExternalReference)
+		return "", errors.ErrStringDataTooLong
+	}
 	if len(rec.CreatedBy) > 16 {
 		rec.CreatedBy = rec.CreatedBy[:16]
 	}
@@ -145,14 +167,18 @@ func (repo *MySQLDBRepository) InsertAccount(ctx context.Context, rec *AccountRe
 	rec.UpdatedAt = time.Now()
 	rec.CreatedBy = html.EscapeString(theUser)
 	rec.CreatedAt = time.Now()
+	if len(rec.Status) == 0 {
+		rec.Status = AccountStatusActive
+	}
 
 	q := "INSERT INTO accounts(" +
-		"account_number, name, currency_code, description, alignment, balance, coa, created_at, created_by, updated_at, updated_by, is_deleted" +
-		") VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, false)"
+		"tenant_id, account_number, name, currency_code, description, alignment, balance, coa, status, min_balance, overdraft_limit, max_balance, created_at, created_by, updated_at, updated_by, is_deleted, external_reference" +
+		") VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, false, ?)"
 	args := []interface{}{
-		rec.AccountNumber, rec.Name, rec.CurrencyCode, rec.Description, rec.Alignment, rec.Balance, rec.Coa, rec.CreatedAt, rec.CreatedBy, rec.UpdatedAt, rec.UpdatedBy,
+		TenantFromContext(ctx), rec.AccountNumber, rec.Name, rec.CurrencyCode, rec.Description, rec.Alignment, rec.Balance, rec.Coa, rec.Status, rec.MinBalance, rec.OverdraftLimit, rec.MaxBalance, rec.CreatedAt, rec.CreatedBy, rec.UpdatedAt, rec.UpdatedBy,
+		sql.NullString{String: rec.ExternalReference, Valid: len(rec.ExternalReference) > 0},
 	}
-	_, err := repo.db.ExecContext(ctx, q, args...)
+	_, err := repo.conn(ctx).ExecContext(ctx, q, args...)
 	if err != nil {
 		lLog.Errorf("error when inserting account. got %s", err.Error())
 		return "", err
@@ -205,11 +231,11 @@ func (repo *MySQLDBRepository) UpdateAccount(ctx context.Context, rec *AccountRe
 	rec.UpdatedAt = time.Now()
 	q := "UPDATE accounts set" +
 		" name=?, currency_code=?, description=?, alignment=?, balance=?, coa=?, created_at=?, created_by=?, updated_at=?, updated_by=?" +
-		" WHERE account_number=? AND is_deleted=false"
+		" WHERE account_number=? AND tenant_id=? AND is_deleted=false"
 	args := []interface{}{
-		rec.Name, rec.CurrencyCode, rec.Description, rec.Alignment, rec.Balance, rec.Coa, rec.CreatedAt, rec.CreatedBy, rec.UpdatedAt, rec.UpdatedBy, rec.AccountNumber,
+		rec.Name, rec.CurrencyCode, rec.Description, rec.Alignment, rec.Balance, rec.Coa, rec.CreatedAt, rec.CreatedBy, rec.UpdatedAt, rec.UpdatedBy, rec.AccountNumber, TenantFromContext(ctx),
 	}
-	_, err := repo.db.ExecContext(ctx, q, args...)
+	_, err := repo.conn(ctx).ExecContext(ctx, q, args...)
 	if err != nil {
 		lLog.Errorf("error while updating account. got %s", err.Error())
 		return err
@@ -217,6 +243,104 @@ func (repo *MySQLDBRepository) UpdateAccount(ctx context.Context, rec *AccountRe
 	return nil
 }
 
+// UpdateAccountDetails update the name, description and COA of an account entity record in the database,
+// leaving its balance, currency, alignment, status and limits untouched.
+// Throws error if the underlying database connection has problem.
+// The accountNumber MUST be already persisted before.
+func (repo *MySQLDBRepository) UpdateAccountDetails(ctx context.Context, accountNumber, name, description, coa string) error {
+	lLog := mysqlLog.WithField("function", "UpdateAccountDetails")
+
+	if len(name) > 128 {
+		lLog.Errorf("Account name %s is too long. Should not more than 128 digit", name)
+		return errors.ErrStringDataTooLong
+	}
+	if len(coa) > 10 {
+		lLog.Errorf("COA %s is too long. Should not more than 10 digit", coa)
+		return errors.ErrStringDataTooLong
+	}
+
+	theUser, ok := ctx.Value(contextkeys.UserIDContextKey).(string)
+	if !ok {
+		lLog.Errorf("UserContext Key %s is not in context", contextkeys.UserIDContextKey)
+		return errors.ErrUserContextKeyMissing
+	}
+	if len(theUser) > 16 {
+		theUser = theUser[:16]
+	}
+
+	q := "UPDATE accounts set" +
+		" name=?, description=?, coa=?, updated_at=?, updated_by=?" +
+		" WHERE account_number=? AND tenant_id=? AND is_deleted=false"
+	args := []interface{}{
+		html.EscapeString(name), html.EscapeString(description), html.EscapeString(coa), time.Now(), html.EscapeString(theUser), html.EscapeString(accountNumber), TenantFromContext(ctx),
+	}
+	_, err := repo.conn(ctx).ExecContext(ctx, q, args...)
+	if err != nil {
+		lLog.Errorf("error while updating account details. got %s", err.Error())
+		return err
+	}
+	return nil
+}
+
+// UpdateAccountStatus update the status column of an account entity record in the database.
+// Throws error if the underlying database connection has problem.
+// The accountNumber MUST be already persisted before.
+func (repo *MySQLDBRepository) UpdateAccountStatus(ctx context.Context, accountNumber, status string) error {
+	lLog := mysqlLog.WithField("function", "UpdateAccountStatus")
+
+	theUser, ok := ctx.Value(contextkeys.UserIDContextKey).(string)
+	if !ok {
+		lLog.Errorf("UserContext Key %s is not in context", contextkeys.UserIDContextKey)
+		return errors.ErrUserContextKeyMissing
+	}
+	if len(theUser) > 16 {
+		theUser = theUser[:16]
+	}
+
+	q := "UPDATE accounts set" +
+		" status=?, updated_at=?, updated_by=?" +
+		" WHERE account_number=? AND tenant_id=? AND is_deleted=false"
+	args := []interface{}{
+		status, time.Now(), html.EscapeString(theUser), html.EscapeString(accountNumber), TenantFromContext(ctx),
+	}
+	_, err := repo.conn(ctx).ExecContext(ctx, q, args...)
+	if err != nil {
+		lLog.Errorf("error while updating account status. got %s", err.Error())
+		return err
+	}
+	return nil
+}
+
+// UpdateAccountLimits update the balance limit columns of an account entity record in the database.
+// Throws error if the underlying database connection has problem.
+// A nil limit will remove that limit from the account.
+// The accountNumber MUST be already persisted before.
+func (repo *MySQLDBRepository) UpdateAccountLimits(ctx context.Context, accountNumber string, minBalance, overdraftLimit, maxBalance *int64) error {
+	lLog := mysqlLog.WithField("function", "UpdateAccountLimits")
+
+	theUser, ok := ctx.Value(contextkeys.UserIDContextKey).(string)
+	if !ok {
+		lLog.Errorf("UserContext Key %s is not in context", contextkeys.UserIDContextKey)
+		return errors.ErrUserContextKeyMissing
+	}
+	if len(theUser) > 16 {
+		theUser = theUser[:16]
+	}
+
+	q := "UPDATE accounts set" +
+		" min_balance=?, overdraft_limit=?, max_balance=?, updated_at=?, updated_by=?" +
+		" WHERE account_number=? AND tenant_id=? AND is_deleted=false"
+	args := []interface{}{
+		minBalance, overdraftLimit, maxBalance, time.Now(), html.EscapeString(theUser), html.EscapeString(accountNumber), TenantFromContext(ctx),
+	}
+	_, err := repo.conn(ctx).ExecContext(ctx, q, args...)
+	if err != nil {
+		lLog.Errorf("error while updating account limits. got %s", err.Error())
+		return err
+	}
+	return nil
+}
+
 // DeleteAccount soft/logical delete an account.
 // Throws error if the underlying database connection has problem.
 // If the account number not exist, it will do nothing and return nil.
@@ -224,11 +348,11 @@ func (repo *MySQLDBRepository) DeleteAccount(ctx context.Context, accountNumber
 	lLog := mysqlLog.WithField("function", "DeleteAccount")
 	q := "UPDATE accounts " +
 		"set is_deleted=true" +
-		" WHERE account_number=? && is_deleted=true"
+		" WHERE account_number=? AND tenant_id=? && is_deleted=true"
 	args := []interface{}{
-		accountNumber,
+		accountNumber, TenantFromContext(ctx),
 	}
-	_, err := repo.db.ExecContext(ctx, q, args...)
+	_, err := repo.conn(ctx).ExecContext(ctx, q, args...)
 	if err != nil {
 		lLog.Errorf("error while deleting account. got %s", err.Error())
 		return err
@@ -243,10 +367,10 @@ func (repo *MySQLDBRepository) DeleteAccount(ctx context.Context, accountNumber
 // It returns list of AcccountRecords
 func (repo *MySQLDBRepository) ListAccount(ctx context.Context, sort string, offset, length int) ([]*AccountRecord, error) {
 	lLog := mysqlLog.WithField("function", "ListAccount")
-	q := "SELECT account_number, name, currency_code, description, alignment, balance, coa, created_at, created_by, updated_at, updated_by" +
-		" FROM accounts WHERE is_deleted=false ORDER BY " + sort + " ASC LIMIT ?,?"
+	q := "SELECT account_number, name, currency_code, description, alignment, balance, coa, status, min_balance, overdraft_limit, max_balance, created_at, created_by, updated_at, updated_by" +
+		" FROM accounts WHERE tenant_id=? AND is_deleted=false ORDER BY " + sort + " ASC LIMIT ?,?"
 	lLog.Infof("Q = %s", q)
-	rows, err := repo.db.QueryxContext(ctx, q, offset, length)
+	rows, err := repo.conn(ctx).QueryxContext(ctx, q, TenantFromContext(ctx), offset, length)
 	if err != nil {
 		lLog.Errorf("error while listing account. got %s", err.Error())
 		return nil, err
@@ -255,7 +379,7 @@ func (repo *MySQLDBRepository) ListAccount(ctx context.Context, sort string, off
 	ret := make([]*AccountRecord, 0)
 	for rows.Next() {
 		ar := &AccountRecord{}
-		err := rows.Scan(&ar.AccountNumber, &ar.Name, &ar.CurrencyCode, &ar.Description, &ar.Alignment, &ar.Balance, &ar.Coa, &ar.CreatedAt, &ar.CreatedBy, &ar.UpdatedAt, &ar.UpdatedBy)
+		err := rows.Scan(&ar.AccountNumber, &ar.Name, &ar.CurrencyCode, &ar.Description, &ar.Alignment, &ar.Balance, &ar.Coa, &ar.Status, &ar.MinBalance, &ar.OverdraftLimit, &ar.MaxBalance, &ar.CreatedAt, &ar.CreatedBy, &ar.UpdatedAt, &ar.UpdatedBy)
 		if err != nil {
 			lLog.Errorf("error while scanning rows in ListAccount function. got %s", err.Error())
 		} else {
@@ -271,8 +395,8 @@ func (repo *MySQLDBRepository) ListAccount(ctx context.Context, sort string, off
 func (repo *MySQLDBRepository) CountAccounts(ctx context.Context) (int, error) {
 	lLog := mysqlLog.WithField("function", "CountAccounts")
 	q := "SELECT COUNT(*) as accountCounts" +
-		" FROM accounts WHERE is_deleted=false"
-	row := repo.db.QueryRowxContext(ctx, q)
+		" FROM accounts WHERE tenant_id=? AND is_deleted=false"
+	row := repo.conn(ctx).QueryRowxContext(ctx, q, TenantFromContext(ctx))
 	if row.Err() != nil {
 		lLog.Errorf("error while counting account. got %s", row.Err().Error())
 		return 0, row.Err()
@@ -292,9 +416,9 @@ func (repo *MySQLDBRepository) CountAccounts(ctx context.Context) (int, error) {
 // It returns list of AcccountRecords
 func (repo *MySQLDBRepository) ListAccountByCoa(ctx context.Context, coa string, sort string, offset, length int) ([]*AccountRecord, error) {
 	lLog := mysqlLog.WithField("function", "ListAccountByCoa")
-	q := "SELECT account_number, name, currency_code, description, alignment, balance, coa, created_at, created_by, updated_at, updated_by" +
-		" FROM accounts WHERE coa LIKE ? AND is_deleted=false ORDER BY " + sort + " ASC LIMIT ?,?"
-	rows, err := repo.db.QueryxContext(ctx, q, coa, offset, length)
+	q := "SELECT account_number, name, currency_code, description, alignment, balance, coa, status, min_balance, overdraft_limit, max_balance, created_at, created_by, updated_at, updated_by" +
+		" FROM accounts WHERE tenant_id=? AND coa LIKE ? AND is_deleted=false ORDER BY " + sort + " ASC LIMIT ?,?"
+	rows, err := repo.conn(ctx).QueryxContext(ctx, q, TenantFromContext(ctx), coa, offset, length)
 	if err != nil {
 		lLog.Errorf("error while listing account by coa. got %s", err.Error())
 		return nil, err
@@ -303,7 +427,7 @@ func (repo *MySQLDBRepository) ListAccountByCoa(ctx context.Context, coa string,
 	ret := make([]*AccountRecord, 0)
 	for rows.Next() {
 		ar := &AccountRecord{}
-		err := rows.Scan(&ar.AccountNumber, &ar.Name, &ar.CurrencyCode, &ar.Description, &ar.Alignment, &ar.Balance, &ar.Coa, &ar.CreatedAt, &ar.CreatedBy, &ar.UpdatedAt, &ar.UpdatedBy)
+		err := rows.Scan(&ar.AccountNumber, &ar.Name, &ar.CurrencyCode, &ar.Description, &ar.Alignment, &ar.Balance, &ar.Coa, &ar.Status, &ar.MinBalance, &ar.OverdraftLimit, &ar.MaxBalance, &ar.CreatedAt, &ar.CreatedBy, &ar.UpdatedAt, &ar.UpdatedBy)
 		if err != nil {
 			lLog.Errorf("error while scanning rows in ListAccount function. got %s", err.Error())
 		} else {
@@ -319,8 +443,8 @@ func (repo *MySQLDBRepository) ListAccountByCoa(ctx context.Context, coa string,
 func (repo *MySQLDBRepository) CountAccountByCoa(ctx context.Context, coa string) (int, error) {
 	lLog := mysqlLog.WithField("function", "CountAccountByCoa")
 	q := "SELECT COUNT(*) as accountCounts" +
-		" FROM accounts WHERE coa LIKE ? AND is_deleted=false"
-	row := repo.db.QueryRowxContext(ctx, q, coa)
+		" FROM accounts WHERE tenant_id=? AND coa LIKE ? AND is_deleted=false"
+	row := repo.conn(ctx).QueryRowxContext(ctx, q, TenantFromContext(ctx), coa)
 	if row.Err() != nil {
 		lLog.Errorf("error while counting account by coa. got %s", row.Err().Error())
 		return 0, row.Err()
@@ -341,9 +465,9 @@ func (repo *MySQLDBRepository) CountAccountByCoa(ctx context.Context, coa string
 // It returns list of AcccountRecords
 func (repo *MySQLDBRepository) FindAccountByName(ctx context.Context, nameLike string, sort string, offset, length int) ([]*AccountRecord, error) {
 	lLog := mysqlLog.WithField("function", "FindAccountByName")
-	q := "SELECT account_number, name, currency_code, description, alignment, balance, coa, created_at, created_by, updated_at, updated_by" +
-		" FROM accounts WHERE (name LIKE ? OR account_number LIKE ?) AND is_deleted=false ORDER BY " + sort + " ASC LIMIT ?,?"
-	rows, err := repo.db.QueryxContext(ctx, q, html.EscapeString(nameLike), html.EscapeString(nameLike), offset, length)
+	q := "SELECT account_number, name, currency_code, description, alignment, balance, coa, status, min_balance, overdraft_limit, max_balance, created_at, created_by, updated_at, updated_by" +
+		" FROM accounts WHERE tenant_id=? AND (name LIKE ? OR account_number LIKE ?) AND is_deleted=false ORDER BY " + sort + " ASC LIMIT ?,?"
+	rows, err := repo.conn(ctx).QueryxContext(ctx, q, TenantFromContext(ctx), html.EscapeString(nameLike), html.EscapeString(nameLike), offset, length)
 	if err != nil {
 		lLog.Errorf("error while finding accounts by name. got %s", err.Error())
 		return nil, err
@@ -352,7 +476,7 @@ func (repo *MySQLDBRepository) FindAccountByName(ctx context.Context, nameLike s
 	ret := make([]*AccountRecord, 0)
 	for rows.Next() {
 		ar := &AccountRecord{}
-		err := rows.Scan(&ar.AccountNumber, &ar.Name, &ar.CurrencyCode, &ar.Description, &ar.Alignment, &ar.Balance, &ar.Coa, &ar.CreatedAt, &ar.CreatedBy, &ar.UpdatedAt, &ar.UpdatedBy)
+		err := rows.Scan(&ar.AccountNumber, &ar.Name, &ar.CurrencyCode, &ar.Description, &ar.Alignment, &ar.Balance, &ar.Coa, &ar.Status, &ar.MinBalance, &ar.OverdraftLimit, &ar.MaxBalance, &ar.CreatedAt, &ar.CreatedBy, &ar.UpdatedAt, &ar.UpdatedBy)
 		if err != nil {
 			lLog.Errorf("error while scanning rows in ListAccount function. got %s", err.Error())
 		} else {
@@ -368,8 +492,8 @@ func (repo *MySQLDBRepository) FindAccountByName(ctx context.Context, nameLike s
 func (repo *MySQLDBRepository) CountAccountByName(ctx context.Context, nameLike string) (int, error) {
 	lLog := mysqlLog.WithField("function", "CountAccountByName")
 	q := "SELECT COUNT(*) as accountCounts" +
-		" FROM accounts WHERE (name LIKE ? OR account_number LIKE ?) AND is_deleted=false"
-	row := repo.db.QueryRowxContext(ctx, q, nameLike, nameLike)
+		" FROM accounts WHERE tenant_id=? AND (name LIKE ? OR account_number LIKE ?) AND is_deleted=false"
+	row := repo.conn(ctx).QueryRowxContext(ctx, q, TenantFromContext(ctx), nameLike, nameLike)
 	if row.Err() != nil {
 		lLog.Errorf("error while counting account by name. got %s", row.Err().Error())
 		return 0, row.Err()
@@ -387,26 +511,98 @@ func (repo *MySQLDBRepository) CountAccountByName(ctx context.Context, nameLike
 // It returns an instance of AccountRecord or nil if there is no Account with
 // specified accountNumber.
 func (repo *MySQLDBRepository) GetAccount(ctx context.Context, accountNumber string) (*AccountRecord, error) {
-	lLog := mysqlLog.WithField("function", "GetAccount")
-	q := "SELECT account_number, name, currency_code, description, alignment, balance, coa, created_at, created_by, updated_at, updated_by" +
-		" FROM accounts WHERE account_number=? AND is_deleted=false"
-	row := repo.db.QueryRowxContext(ctx, q, html.EscapeString(accountNumber))
+	return repo.getAccount(ctx, accountNumber, false)
+}
+
+// GetAccountForUpdate retrieves an AccountRecord just like GetAccount, but also locks the account row
+// until the database transaction carried in the context ends.
+// It MUST be called with a context that carries a database transaction.
+func (repo *MySQLDBRepository) GetAccountForUpdate(ctx context.Context, accountNumber string) (*AccountRecord, error) {
+	if _, ok := ctx.Value(contextkeys.DBTransactionContextKey).(*sqlx.Tx); !ok {
+		mysqlLog.WithField("function", "GetAccountForUpdate").Errorf("DBTransaction Key %s is not in context", contextkeys.DBTransactionContextKey)
+		return nil, errors.ErrDBTransactionMissing
+	}
+	return repo.getAccount(ctx, accountNumber, true)
+}
+
+func (repo *MySQLDBRepository) getAccount(ctx context.Context, accountNumber string, forUpdate bool) (*AccountRecord, error) {
+	q := " AND account_number=? AND is_deleted=false"
+	if forUpdate {
+		q += " FOR UPDATE"
+	}
+	return repo.selectAccount(ctx, "GetAccount", q, html.EscapeString(accountNumber))
+}
+
+// GetAccountByExternalReference retrieves an AccountRecord from database created by the createdBy user with the specified external reference.
+// Throws error if  the underlying database connection has problem.
+// It returns an instance of AccountRecord or nil if the user created no Account with the reference.
+func (repo *MySQLDBRepository) GetAccountByExternalReference(ctx context.Context, createdBy, reference string) (*AccountRecord, error) {
+	return repo.selectAccount(ctx, "GetAccountByExternalReference", " AND created_by=? AND external_reference=? AND is_deleted=false", html.EscapeString(createdBy), reference)
+}
+
+// selectAccount retrieves the account of the tenant in context matching the condition, nil if there is none
+func (repo *MySQLDBRepository) selectAccount(ctx context.Context, function, condition string, args ...interface{}) (*AccountRecord, error) {
+	lLog := mysqlLog.WithField("function", function)
+	q := "SELECT tenant_id, account_number, name, currency_code, description, alignment, balance, coa, status, min_balance, overdraft_limit, max_balance, created_at, created_by, updated_at, updated_by, external_reference" +
+		" FROM accounts WHERE tenant_id=?" + condition
+	row := repo.conn(ctx).QueryRowxContext(ctx, q, append([]interface{}{TenantFromContext(ctx)}, args...)...)
 	if row.Err() != nil {
-		lLog.Errorf("error while retrieving account by account number. got %s", row.Err().Error())
+		lLog.Errorf("error while retrieving account. got %s", row.Err().Error())
 		return nil, row.Err()
 	}
 	ar := &AccountRecord{}
-	err := row.Scan(&ar.AccountNumber, &ar.Name, &ar.CurrencyCode, &ar.Description, &ar.Alignment, &ar.Balance, &ar.Coa, &ar.CreatedAt, &ar.CreatedBy, &ar.UpdatedAt, &ar.UpdatedBy)
+	var reference sql.NullString
+	err := row.Scan(&ar.TenantID, &ar.AccountNumber, &ar.Name, &ar.CurrencyCode, &ar.Description, &ar.Alignment, &ar.Balance, &ar.Coa, &ar.Status, &ar.MinBalance, &ar.OverdraftLimit, &ar.MaxBalance, &ar.CreatedAt, &ar.CreatedBy, &ar.UpdatedAt, &ar.UpdatedBy, &reference)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
 		}
-		lLog.Errorf("error while scanning count of account by account number. got %s", err.Error())
+		lLog.Errorf("error while scanning account. got %s", err.Error())
 		return nil, err
 	}
+	ar.ExternalReference = reference.String
 	return ar, nil
 }
 
+// journalColumns are the columns of the journals table read by scanJournal
+const journalColumns = "journal_id, journaling_time, description, is_reversal, reversed_journal_id, total_amount, created_at, created_by, metadata, external_reference"
+
+// scanJournal scan a row of the journalColumns
+func scanJournal(scanner interface{ Scan(...interface{}) error }) (*JournalRecord, error) {
+	jr := &JournalRecord{}
+	var metadata, reference sql.NullString
+	err := scanner.Scan(&jr.JournalID, &jr.JournalingTime, &jr.Description, &jr.IsReversal, &jr.ReversedJournalID, &jr.TotalAmount, &jr.CreatedAt, &jr.CreatedBy, &metadata, &reference)
+	if err != nil {
+		return nil, err
+	}
+	jr.Metadata = metadata.String
+	jr.ExternalReference = reference.String
+	return jr, nil
+}
+
+// metadataCondition returns the conditions on the metadata column for the filter, to be appended to a WHERE clause,
+// and their arguments. The keys are quoted in the JSON paths, so they may contain any character.
+func metadataCondition(filter MetadataFilter) (string, []interface{}) {
+	keys := make([]string, 0, len(filter))
+	for key := range filter {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var cond strings.Builder
+	args := make([]interface{}, 0, 2*len(keys))
+	for _, key := range keys {
+		path := `$."` + strings.ReplaceAll(strings.ReplaceAll(key, `\`, `\\`), `"`, `\"`) + `"`
+		if len(filter[key]) == 0 {
+			cond.WriteString(" AND JSON_CONTAINS_PATH(metadata, 'one', ?)")
+			args = append(args, path)
+		} else {
+			cond.WriteString(" AND JSON_UNQUOTE(JSON_EXTRACT(metadata, ?))=?")
+			args = append(args, path, filter[key])
+		}
+	}
+	return cond.String(), args
+}
+
 // InsertJournal will insert the data specified in the rec argument into database
 // will return error if the underlying database connection has problem. or if the
 // journalID, or Transaction ID in the journal already in the database.
@@ -428,20 +624,31 @@ func (repo *MySQLDBRepository) InsertJournal(ctx context.Context, rec *JournalRe
 		lLog.Errorf("Reversed journal id %s is too long. Should not more than 20 digit", rec.ReversedJournalID)
 		return "", errors.ErrStringDataTooLong
 	}
+	if len(rec.ExternalReference) > 64 {
+		lLog.Errorf("External reference %s is too long. Should not more than 64 digit", rec.ExternalReference)
+		return "", errors.ErrStringDataTooLong
+	}
 	if len(rec.CreatedBy) > 16 {
 		rec.CreatedBy = rec.CreatedBy[:16]
 	}
 
 	rec.CreatedBy = theUser
 	rec.CreatedAt = time.Now()
+	// a journal without sequence is stored with NULL sequence, so it do not collide with other unsequenced journals
+	var sequence *int64
+	if rec.Sequence > 0 {
+		sequence = &rec.Sequence
+	}
 	q := "INSERT INTO journals(" +
-		"journal_id, journaling_time, description, is_reversal, reversed_journal_id, total_amount, created_at, created_by, updated_at, updated_by, is_deleted" +
-		") VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
+		"tenant_id, journal_id, journaling_time, description, is_reversal, reversed_journal_id, total_amount, created_at, created_by, updated_at, updated_by, is_deleted, sequence, metadata, external_reference" +
+		") VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
 	args := []interface{}{
-		html.EscapeString(rec.JournalID), rec.JournalingTime, html.EscapeString(rec.Description),
-		rec.IsReversal, html.EscapeString(rec.ReversedJournalID), rec.TotalAmount, rec.CreatedAt, html.EscapeString(rec.CreatedBy), rec.CreatedAt, html.EscapeString(rec.CreatedBy), false,
+		TenantFromContext(ctx), html.EscapeString(rec.JournalID), rec.JournalingTime, html.EscapeString(rec.Description),
+		rec.IsReversal, html.EscapeString(rec.ReversedJournalID), rec.TotalAmount, rec.CreatedAt, html.EscapeString(rec.CreatedBy), rec.CreatedAt, html.EscapeString(rec.CreatedBy), false, sequence,
+		sql.NullString{String: rec.Metadata, Valid: len(rec.Metadata) > 0},
+		sql.NullString{String: rec.ExternalReference, Valid: len(rec.ExternalReference) > 0},
 	}
-	_, err := repo.db.ExecContext(ctx, q, args...)
+	_, err := repo.conn(ctx).ExecContext(ctx, q, args...)
 	if err != nil {
 		lLog.Errorf("error while inserting journal. got %s", err.Error())
 		return "", err
@@ -477,11 +684,11 @@ func (repo *MySQLDBRepository) UpdateJournal(ctx context.Context, rec *JournalRe
 	rec.CreatedAt = time.Now()
 	q := "UPDATE journals " +
 		"set journaling_time=?, description=?, is_reversal=?, reversed_journal_id=?, total_amount=?, updated_at=?, updated_by=?" +
-		" WHERE journal_id=? AND is_deleted=false"
+		" WHERE journal_id=? AND tenant_id=? AND is_deleted=false"
 	args := []interface{}{
-		rec.JournalingTime, html.EscapeString(rec.Description), rec.IsReversal, html.EscapeString(rec.ReversedJournalID), rec.TotalAmount, time.Now(), html.EscapeString(theUser), html.EscapeString(rec.JournalID),
+		rec.JournalingTime, html.EscapeString(rec.Description), rec.IsReversal, html.EscapeString(rec.ReversedJournalID), rec.TotalAmount, time.Now(), html.EscapeString(theUser), html.EscapeString(rec.JournalID), TenantFromContext(ctx),
 	}
-	_, err := repo.db.ExecContext(ctx, q, args...)
+	_, err := repo.conn(ctx).ExecContext(ctx, q, args...)
 	if err != nil {
 		lLog.Errorf("error while updating journal. got %s", err.Error())
 		return err
@@ -496,11 +703,11 @@ func (repo *MySQLDBRepository) DeleteJournal(ctx context.Context, journalID stri
 	lLog := mysqlLog.WithField("function", "DeleteJournal")
 	q := "UPDATE journals " +
 		"set is_deleted=true" +
-		" WHERE journal_id=? && is_deleted=true"
+		" WHERE journal_id=? AND tenant_id=? && is_deleted=true"
 	args := []interface{}{
-		html.EscapeString(journalID),
+		html.EscapeString(journalID), TenantFromContext(ctx),
 	}
-	_, err := repo.db.ExecContext(ctx, q, args...)
+	_, err := repo.conn(ctx).ExecContext(ctx, q, args...)
 	if err != nil {
 		lLog.Errorf("error while deleting journal. got %s", err.Error())
 		return err
@@ -515,9 +722,9 @@ func (repo *MySQLDBRepository) DeleteJournal(ctx context.Context, journalID stri
 // It returns list of JournalRecord
 func (repo *MySQLDBRepository) ListJournal(ctx context.Context, sort string, offset, length int) ([]*JournalRecord, error) {
 	lLog := mysqlLog.WithField("function", "ListJournal")
-	q := "SELECT journal_id, journaling_time, description, is_reversal, reversed_journal_id, total_amount, created_at, created_by" +
-		" FROM journals WHERE is_deleted=false ORDER BY " + sort + " ASC LIMIT ?,?"
-	rows, err := repo.db.QueryxContext(ctx, q, offset, length)
+	q := "SELECT " + journalColumns +
+		" FROM journals WHERE tenant_id=? AND is_deleted=false ORDER BY " + sort + " ASC LIMIT ?,?"
+	rows, err := repo.conn(ctx).QueryxContext(ctx, q, TenantFromContext(ctx), offset, length)
 	if err != nil {
 		lLog.Errorf("error while listing journals. got %s", err.Error())
 		return nil, err
@@ -525,8 +732,7 @@ func (repo *MySQLDBRepository) ListJournal(ctx context.Context, sort string, off
 	defer rows.Close()
 	ret := make([]*JournalRecord, 0)
 	for rows.Next() {
-		ar := &JournalRecord{}
-		err := rows.Scan(&ar.JournalID, &ar.JournalingTime, &ar.Description, &ar.Description, &ar.IsReversal, &ar.ReversedJournalID, &ar.TotalAmount, &ar.CreatedAt, &ar.CreatedBy)
+		ar, err := scanJournal(rows)
 		if err != nil {
 			lLog.Errorf("error while scanning rows in ListAccount function. got %s", err.Error())
 		} else {
@@ -541,16 +747,33 @@ func (repo *MySQLDBRepository) ListJournal(ctx context.Context, sort string, off
 // It returns an instance of JournalRecord or nil if there is no Journal with
 // specified journalID.
 func (repo *MySQLDBRepository) GetJournal(ctx context.Context, journalID string) (*JournalRecord, error) {
+	return repo.getJournal(ctx, journalID, false)
+}
+
+// GetJournalForUpdate retrieves a JournalRecord just like GetJournal, but also locks the journal row
+// until the database transaction carried in the context ends.
+// It MUST be called with a context that carries a database transaction.
+func (repo *MySQLDBRepository) GetJournalForUpdate(ctx context.Context, journalID string) (*JournalRecord, error) {
+	if _, ok := ctx.Value(contextkeys.DBTransactionContextKey).(*sqlx.Tx); !ok {
+		mysqlLog.WithField("function", "GetJournalForUpdate").Errorf("DBTransaction Key %s is not in context", contextkeys.DBTransactionContextKey)
+		return nil, errors.ErrDBTransactionMissing
+	}
+	return repo.getJournal(ctx, journalID, true)
+}
+
+func (repo *MySQLDBRepository) getJournal(ctx context.Context, journalID string, forUpdate bool) (*JournalRecord, error) {
 	lLog := mysqlLog.WithField("function", "GetJournal")
-	q := "SELECT  journal_id, journaling_time, description, is_reversal, reversed_journal_id, total_amount, created_at, created_by" +
-		" FROM journals WHERE journal_id=? AND is_deleted=false"
-	row := repo.db.QueryRowxContext(ctx, q, journalID)
+	q := "SELECT " + journalColumns +
+		" FROM journals WHERE tenant_id=? AND journal_id=? AND is_deleted=false"
+	if forUpdate {
+		q += " FOR UPDATE"
+	}
+	row := repo.conn(ctx).QueryRowxContext(ctx, q, TenantFromContext(ctx), journalID)
 	if row.Err() != nil {
 		lLog.Errorf("error while retrieving journal by journalID. got %s", row.Err().Error())
 		return nil, row.Err()
 	}
-	ar := &JournalRecord{}
-	err := row.Scan(&ar.JournalID, &ar.JournalingTime, &ar.Description, &ar.IsReversal, &ar.ReversedJournalID, &ar.TotalAmount, &ar.CreatedAt, &ar.CreatedBy)
+	ar, err := scanJournal(row)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, err
@@ -561,21 +784,43 @@ func (repo *MySQLDBRepository) GetJournal(ctx context.Context, journalID string)
 	return ar, nil
 }
 
+// GetJournalByExternalReference retrieves an JournalRecord from database created by the createdBy user with the specified external reference.
+// Throws error if  the underlying database connection has problem.
+// It returns an instance of JournalRecord or nil if the user created no Journal with the reference.
+func (repo *MySQLDBRepository) GetJournalByExternalReference(ctx context.Context, createdBy, reference string) (*JournalRecord, error) {
+	lLog := mysqlLog.WithField("function", "GetJournalByExternalReference")
+	q := "SELECT " + journalColumns +
+		" FROM journals WHERE tenant_id=? AND created_by=? AND external_reference=? AND is_deleted=false"
+	row := repo.conn(ctx).QueryRowxContext(ctx, q, TenantFromContext(ctx), html.EscapeString(createdBy), reference)
+	if row.Err() != nil {
+		lLog.Errorf("error while retrieving journal by external reference. got %s", row.Err().Error())
+		return nil, row.Err()
+	}
+	ar, err := scanJournal(row)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		lLog.Errorf("error while scanning record when retrieving journal. got %s", err.Error())
+		return nil, err
+	}
+	return ar, nil
+}
+
 // GetJournalByReversalID retrieves an JournalRecord from database where the reversedJournalID is specified.
 // Throws error if  the underlying database connection has problem.
 // It returns an instance of JournalRecord or nil if there is no Journal with
 // specified reversedJournalID.
 func (repo *MySQLDBRepository) GetJournalByReversalID(ctx context.Context, journalID string) (*JournalRecord, error) {
 	lLog := mysqlLog.WithField("function", "GetJournalByReversalID")
-	q := "SELECT  journal_id, journaling_time, description, is_reversal, reversed_journal_id, total_amount, created_at, created_by" +
-		" FROM journals WHERE reversed_journal_id=? AND is_deleted=false"
-	row := repo.db.QueryRowxContext(ctx, q, journalID)
+	q := "SELECT " + journalColumns +
+		" FROM journals WHERE tenant_id=? AND reversed_journal_id=? AND is_deleted=false"
+	row := repo.conn(ctx).QueryRowxContext(ctx, q, TenantFromContext(ctx), journalID)
 	if row.Err() != nil {
 		lLog.Errorf("error while retriving journals by reversal id. got %s", row.Err().Error())
 		return nil, row.Err()
 	}
-	ar := &JournalRecord{}
-	err := row.Scan(&ar.JournalID, &ar.JournalingTime, &ar.Description, &ar.Description, &ar.IsReversal, &ar.ReversedJournalID, &ar.TotalAmount, &ar.CreatedAt, &ar.CreatedBy)
+	ar, err := scanJournal(row)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
@@ -586,6 +831,76 @@ func (repo *MySQLDBRepository) GetJournalByReversalID(ctx context.Context, journ
 	return ar, nil
 }
 
+// ListJournalByReversedJournalID will list all reversal journals of the journal specified by journalID, sorted by journaling time.
+// Throws error if the underlying database connection has problem.
+func (repo *MySQLDBRepository) ListJournalByReversedJournalID(ctx context.Context, journalID string) ([]*JournalRecord, error) {
+	lLog := mysqlLog.WithField("function", "ListJournalByReversedJournalID")
+	q := "SELECT " + journalColumns +
+		" FROM journals WHERE tenant_id=? AND reversed_journal_id=? AND is_deleted=false ORDER BY journaling_time ASC"
+	rows, err := repo.conn(ctx).QueryxContext(ctx, q, TenantFromContext(ctx), journalID)
+	if err != nil {
+		lLog.Errorf("error while listing journals by reversed journal id. got %s", err.Error())
+		return nil, err
+	}
+	defer rows.Close()
+	ret := make([]*JournalRecord, 0)
+	for rows.Next() {
+		ar, err := scanJournal(rows)
+		if err != nil {
+			lLog.Errorf("error while scanning rows in ListJournalByReversedJournalID function. got %s", err.Error())
+		} else {
+			ret = append(ret, ar)
+		}
+	}
+	return ret, nil
+}
+
+// SumReversedAmountByJournalID sums the amount of all reversal transactions of the journal specified by journalID,
+// per account number. An account that is not reversed is not in the returned map.
+// Throws error if the underlying database connection has problem.
+func (repo *MySQLDBRepository) SumReversedAmountByJournalID(ctx context.Context, journalID string) (map[string]int64, error) {
+	return repo.sumReversedAmountByJournalID(ctx, journalID, false)
+}
+
+// SumReversedAmountByJournalIDForUpdate sums the reversed amount just like SumReversedAmountByJournalID, but as a locking read,
+// it counts the latest committed reversals, not the ones in the transaction snapshot, and locks them until the database
+// transaction carried in the context ends.
+// It MUST be called with a context that carries a database transaction.
+func (repo *MySQLDBRepository) SumReversedAmountByJournalIDForUpdate(ctx context.Context, journalID string) (map[string]int64, error) {
+	if _, ok := ctx.Value(contextkeys.DBTransactionContextKey).(*sqlx.Tx); !ok {
+		mysqlLog.WithField("function", "SumReversedAmountByJournalIDForUpdate").Errorf("DBTransaction Key %s is not in context", contextkeys.DBTransactionContextKey)
+		return nil, errors.ErrDBTransactionMissing
+	}
+	return repo.sumReversedAmountByJournalID(ctx, journalID, true)
+}
+
+func (repo *MySQLDBRepository) sumReversedAmountByJournalID(ctx context.Context, journalID string, forUpdate bool) (map[string]int64, error) {
+	lLog := mysqlLog.WithField("function", "SumReversedAmountByJournalID")
+	q := "SELECT t.account_number, SUM(t.amount) FROM transactions t JOIN journals j ON t.journal_id=j.journal_id AND t.tenant_id=j.tenant_id" +
+		" WHERE j.tenant_id=? AND j.reversed_journal_id=? AND j.is_deleted=false AND t.is_deleted=false GROUP BY t.account_number"
+	if forUpdate {
+		q += " FOR UPDATE"
+	}
+	rows, err := repo.conn(ctx).QueryxContext(ctx, q, TenantFromContext(ctx), journalID)
+	if err != nil {
+		lLog.Errorf("error while summing reversed amount. got %s", err.Error())
+		return nil, err
+	}
+	defer rows.Close()
+	ret := make(map[string]int64)
+	for rows.Next() {
+		var accountNumber string
+		var sum int64
+		err := rows.Scan(&accountNumber, &sum)
+		if err != nil {
+			lLog.Errorf("error while scanning rows in SumReversedAmountByJournalID function. got %s", err.Error())
+			return nil, err
+		}
+		ret[accountNumber] = sum
+	}
+	return ret, nil
+}
+
 // ListJournalByTimeRange will list journals in paginated fashion where journal is in the specified time range.
 // Throws error if the underlying database connection has problem.
 // It will return JournalRecord sorted, starting from the offset with total maximum number or item, specified
@@ -593,9 +908,9 @@ func (repo *MySQLDBRepository) GetJournalByReversalID(ctx context.Context, journ
 // It returns list of JournalRecord
 func (repo *MySQLDBRepository) ListJournalByTimeRange(ctx context.Context, timeFrom, timeTo time.Time, sort string, offset, length int) ([]*JournalRecord, error) {
 	lLog := mysqlLog.WithField("function", "ListJournalByTimeRange")
-	q := "SELECT journal_id, journaling_time, description, is_reversal, reversed_journal_id, total_amount, created_at, created_by" +
-		" FROM journals WHERE journaling_time > ? AND journaling_time < ? AND is_deleted=false ORDER BY " + sort + " ASC LIMIT ?,?"
-	rows, err := repo.db.QueryxContext(ctx, q, timeFrom, timeTo, offset, length)
+	q := "SELECT " + journalColumns +
+		" FROM journals WHERE tenant_id=? AND journaling_time > ? AND journaling_time < ? AND is_deleted=false ORDER BY " + sort + " ASC LIMIT ?,?"
+	rows, err := repo.conn(ctx).QueryxContext(ctx, q, TenantFromContext(ctx), timeFrom, timeTo, offset, length)
 	if err != nil {
 		lLog.Errorf("error while listing journals by time range. got %s", err.Error())
 		return nil, err
@@ -603,8 +918,7 @@ func (repo *MySQLDBRepository) ListJournalByTimeRange(ctx context.Context, timeF
 	defer rows.Close()
 	ret := make([]*JournalRecord, 0)
 	for rows.Next() {
-		ar := &JournalRecord{}
-		err := rows.Scan(&ar.JournalID, &ar.JournalingTime, &ar.Description, &ar.Description, &ar.IsReversal, &ar.ReversedJournalID, &ar.TotalAmount, &ar.CreatedAt, &ar.CreatedBy)
+		ar, err := scanJournal(rows)
 		if err != nil {
 			lLog.Errorf("error while scanning rows in ListAccount function. got %s", err.Error())
 		} else {
@@ -620,8 +934,8 @@ func (repo *MySQLDBRepository) ListJournalByTimeRange(ctx context.Context, timeF
 func (repo *MySQLDBRepository) CountJournalByTimeRange(ctx context.Context, timeFrom, timeTo time.Time) (int, error) {
 	lLog := mysqlLog.WithField("function", "CountJournalByTimeRange")
 	q := "SELECT COUNT(*) as journalCount" +
-		" FROM journals WHERE journaling_time > ? AND journaling_time < ? AND is_deleted=false"
-	row := repo.db.QueryRowxContext(ctx, q, timeFrom, timeTo)
+		" FROM journals WHERE tenant_id=? AND journaling_time > ? AND journaling_time < ? AND is_deleted=false"
+	row := repo.conn(ctx).QueryRowxContext(ctx, q, TenantFromContext(ctx), timeFrom, timeTo)
 	if row.Err() != nil {
 		lLog.Errorf("error while counting journals by time range. got %s", row.Err().Error())
 		return 0, row.Err()
@@ -635,6 +949,70 @@ func (repo *MySQLDBRepository) CountJournalByTimeRange(ctx context.Context, time
 	return count, nil
 }
 
+// ListJournalByMetadata will list journals in paginated fashion where journal is in the specified time range
+// and its metadata matches the filter, sorted by journaling time.
+// Throws error if the underlying database connection has problem.
+func (repo *MySQLDBRepository) ListJournalByMetadata(ctx context.Context, timeFrom, timeTo time.Time, filter MetadataFilter, offset, length int) ([]*JournalRecord, error) {
+	lLog := mysqlLog.WithField("function", "ListJournalByMetadata")
+	cond, condArgs := metadataCondition(filter)
+	q := "SELECT " + journalColumns +
+		" FROM journals WHERE tenant_id=? AND journaling_time > ? AND journaling_time < ? AND is_deleted=false" + cond + " ORDER BY journaling_time ASC LIMIT ?,?"
+	args := append([]interface{}{TenantFromContext(ctx), timeFrom, timeTo}, condArgs...)
+	rows, err := repo.conn(ctx).QueryxContext(ctx, q, append(args, offset, length)...)
+	if err != nil {
+		lLog.Errorf("error while listing journals by metadata. got %s", err.Error())
+		return nil, err
+	}
+	defer rows.Close()
+	ret := make([]*JournalRecord, 0)
+	for rows.Next() {
+		ar, err := scanJournal(rows)
+		if err != nil {
+			lLog.Errorf("error while scanning rows in ListJournalByMetadata function. got %s", err.Error())
+		} else {
+			ret = append(ret, ar)
+		}
+	}
+	return ret, nil
+}
+
+// CountJournalByMetadata will return the number of journals in the specified time range whose metadata matches the filter.
+// Throws error if the underlying database connection has problem.
+func (repo *MySQLDBRepository) CountJournalByMetadata(ctx context.Context, timeFrom, timeTo time.Time, filter MetadataFilter) (int, error) {
+	lLog := mysqlLog.WithField("function", "CountJournalByMetadata")
+	cond, condArgs := metadataCondition(filter)
+	q := "SELECT COUNT(*) as journalCount" +
+		" FROM journals WHERE tenant_id=? AND journaling_time > ? AND journaling_time < ? AND is_deleted=false" + cond
+	args := append([]interface{}{TenantFromContext(ctx), timeFrom, timeTo}, condArgs...)
+	row := repo.conn(ctx).QueryRowxContext(ctx, q, args...)
+	if row.Err() != nil {
+		lLog.Errorf("error while counting journals by metadata. got %s", row.Err().Error())
+		return 0, row.Err()
+	}
+	count := 0
+	err := row.Scan(&count)
+	if err != nil {
+		lLog.Errorf("error while scanning journals count when finding journal by metadata. got %s", err.Error())
+		return 0, err
+	}
+	return count, nil
+}
+
+// transactionColumns are the columns of the transactions table read by scanTransaction
+const transactionColumns = "transaction_id, transaction_time, account_number, journal_id, description, alignment, amount, balance, created_at, created_by, metadata"
+
+// scanTransaction scan a row of the transactionColumns
+func scanTransaction(scanner interface{ Scan(...interface{}) error }) (*TransactionRecord, error) {
+	tr := &TransactionRecord{}
+	var metadata sql.NullString
+	err := scanner.Scan(&tr.TransactionID, &tr.TransactionTime, &tr.AccountNumber, &tr.JournalID, &tr.Description, &tr.Alignment, &tr.Amount, &tr.Balance, &tr.CreatedAt, &tr.CreatedBy, &metadata)
+	if err != nil {
+		return nil, err
+	}
+	tr.Metadata = metadata.String
+	return tr, nil
+}
+
 // InsertTransaction will insert the data specified in the rec argument into database
 // will return error if the underlying database connection has problem. or if the
 // Transaction ID in the journal already in the database.
@@ -659,9 +1037,10 @@ func (repo *MySQLDBRepository) InsertTransaction(ctx context.Context, rec *Trans
 	}
 
 	q := "INSERT INTO transactions(" +
-		"transaction_id, transaction_time, account_number, journal_id, description, alignment, amount, balance, created_at, created_by, is_deleted" +
-		") VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, false)"
+		"tenant_id, transaction_id, transaction_time, account_number, journal_id, description, alignment, amount, balance, created_at, created_by, is_deleted, metadata" +
+		") VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, false, ?)"
 	args := []interface{}{
+		TenantFromContext(ctx),
 		html.EscapeString(rec.TransactionID),
 		rec.TransactionTime,
 		html.EscapeString(rec.AccountNumber),
@@ -672,8 +1051,9 @@ func (repo *MySQLDBRepository) InsertTransaction(ctx context.Context, rec *Trans
 		rec.Balance,
 		rec.CreatedAt,
 		html.EscapeString(rec.CreatedBy),
+		sql.NullString{String: rec.Metadata, Valid: len(rec.Metadata) > 0},
 	}
-	_, err := repo.db.ExecContext(ctx, q, args...)
+	_, err := repo.conn(ctx).ExecContext(ctx, q, args...)
 	if err != nil {
 		lLog.Errorf("error while inserting transaction. got %s", err.Error())
 		return "", err
@@ -706,7 +1086,7 @@ func (repo *MySQLDBRepository) UpdateTransaction(ctx context.Context, rec *Trans
 
 	q := "UPDATE transactions " +
 		"set transaction_time=?, account_number=?, journal_id=?, description=?, alignment=?, amount=?, balance=?, created_at=?, created_by=?" +
-		" WHERE journal_id=? and is_deleted=false"
+		" WHERE journal_id=? AND tenant_id=? and is_deleted=false"
 	args := []interface{}{
 		rec.TransactionTime,
 		html.EscapeString(rec.AccountNumber),
@@ -718,8 +1098,9 @@ func (repo *MySQLDBRepository) UpdateTransaction(ctx context.Context, rec *Trans
 		rec.CreatedAt,
 		html.EscapeString(rec.CreatedBy),
 		html.EscapeString(rec.JournalID),
+		TenantFromContext(ctx),
 	}
-	_, err := repo.db.ExecContext(ctx, q, args...)
+	_, err := repo.conn(ctx).ExecContext(ctx, q, args...)
 	if err != nil {
 		lLog.Errorf("error while updating transaction. got %s", err.Error())
 		return err
@@ -734,11 +1115,11 @@ func (repo *MySQLDBRepository) DeleteTransaction(ctx context.Context, transactio
 	lLog := mysqlLog.WithField("function", "DeleteTransaction")
 	q := "UPDATE transactions " +
 		"set is_deleted=true" +
-		" WHERE transaction_id=? && is_deleted=true"
+		" WHERE transaction_id=? AND tenant_id=? && is_deleted=true"
 	args := []interface{}{
-		transactionID,
+		transactionID, TenantFromContext(ctx),
 	}
-	_, err := repo.db.ExecContext(ctx, q, args...)
+	_, err := repo.conn(ctx).ExecContext(ctx, q, args...)
 	if err != nil {
 		lLog.Errorf("error while deleting transaction. got %s", err.Error())
 		return err
@@ -753,9 +1134,9 @@ func (repo *MySQLDBRepository) DeleteTransaction(ctx context.Context, transactio
 // It returns list of TransactionRecord
 func (repo *MySQLDBRepository) ListTransaction(ctx context.Context, sort string, offset, length int) ([]*TransactionRecord, error) {
 	lLog := mysqlLog.WithField("function", "ListTransaction")
-	q := "SELECT transaction_id, transaction_time, account_number, journal_id, description, alignment, amount, balance, created_at, created_by" +
-		" FROM transactions WHERE is_deleted=false ORDER BY " + sort + " ASC LIMIT ?,?"
-	rows, err := repo.db.QueryxContext(ctx, q, offset, length)
+	q := "SELECT " + transactionColumns +
+		" FROM transactions WHERE tenant_id=? AND is_deleted=false ORDER BY " + sort + " ASC LIMIT ?,?"
+	rows, err := repo.conn(ctx).QueryxContext(ctx, q, TenantFromContext(ctx), offset, length)
 	if err != nil {
 		lLog.Errorf("error while listing transaction in time-range. got %s", err.Error())
 		return nil, err
@@ -763,8 +1144,7 @@ func (repo *MySQLDBRepository) ListTransaction(ctx context.Context, sort string,
 	defer rows.Close()
 	ret := make([]*TransactionRecord, 0)
 	for rows.Next() {
-		ar := &TransactionRecord{}
-		err := rows.Scan(&ar.TransactionID, &ar.TransactionTime, &ar.AccountNumber, &ar.JournalID, &ar.Description, &ar.Alignment, &ar.Amount, &ar.Balance, &ar.CreatedAt, &ar.CreatedBy)
+		ar, err := scanTransaction(rows)
 		if err != nil {
 			lLog.Errorf("error while scanning rows in ListAccount function. got %s", err.Error())
 		} else {
@@ -780,15 +1160,14 @@ func (repo *MySQLDBRepository) ListTransaction(ctx context.Context, sort string,
 // specified transactionID.
 func (repo *MySQLDBRepository) GetTransaction(ctx context.Context, transactionID string) (*TransactionRecord, error) {
 	lLog := mysqlLog.WithField("function", "GetTransaction")
-	q := "SELECT  transaction_id, transaction_time, account_number, journal_id, description, alignment, amount, balance, created_at, created_by" +
-		" FROM transactions WHERE transaction_id=? and is_deleted=false"
-	row := repo.db.QueryRowxContext(ctx, q, transactionID)
+	q := "SELECT " + transactionColumns +
+		" FROM transactions WHERE tenant_id=? AND transaction_id=? and is_deleted=false"
+	row := repo.conn(ctx).QueryRowxContext(ctx, q, TenantFromContext(ctx), transactionID)
 	if row.Err() != nil {
 		lLog.Errorf("error while retrieving transaction. got %s", row.Err().Error())
 		return nil, row.Err()
 	}
-	ar := &TransactionRecord{}
-	err := row.Scan(&ar.TransactionID, &ar.TransactionTime, &ar.AccountNumber, &ar.JournalID, &ar.Description, &ar.Alignment, &ar.Amount, &ar.Balance, &ar.CreatedAt, &ar.CreatedBy)
+	ar, err := scanTransaction(row)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
@@ -807,9 +1186,9 @@ func (repo *MySQLDBRepository) GetTransaction(ctx context.Context, transactionID
 // It returns list of TransactionRecord
 func (repo *MySQLDBRepository) ListTransactionByAccountNumber(ctx context.Context, accountNumber string, timeFrom, timeTo time.Time, offset, length int) ([]*TransactionRecord, error) {
 	lLog := mysqlLog.WithField("function", "ListTransactionByAccountNumber")
-	q := "SELECT transaction_id, transaction_time, account_number, journal_id, description, alignment, amount, balance, created_at, created_by" +
-		" FROM transactions WHERE account_number=? AND transaction_time > ? AND transaction_time < ? AND is_deleted=false ORDER BY transaction_time ASC LIMIT ?,?"
-	rows, err := repo.db.QueryxContext(ctx, q, accountNumber, timeFrom, timeTo, offset, length)
+	q := "SELECT " + transactionColumns +
+		" FROM transactions WHERE tenant_id=? AND account_number=? AND transaction_time > ? AND transaction_time < ? AND is_deleted=false ORDER BY transaction_time ASC LIMIT ?,?"
+	rows, err := repo.conn(ctx).QueryxContext(ctx, q, TenantFromContext(ctx), accountNumber, timeFrom, timeTo, offset, length)
 	if err != nil {
 		lLog.Errorf("error while listing transaction by account number. got %s", err.Error())
 		return nil, err
@@ -817,8 +1196,7 @@ func (repo *MySQLDBRepository) ListTransactionByAccountNumber(ctx context.Contex
 	defer rows.Close()
 	ret := make([]*TransactionRecord, 0)
 	for rows.Next() {
-		ar := &TransactionRecord{}
-		err := rows.Scan(&ar.TransactionID, &ar.TransactionTime, &ar.AccountNumber, &ar.JournalID, &ar.Description, &ar.Alignment, &ar.Amount, &ar.Balance, &ar.CreatedAt, &ar.CreatedBy)
+		ar, err := scanTransaction(rows)
 		if err != nil {
 			lLog.Errorf("error while scanning rows in ListAccount function. got %s", err.Error())
 		} else {
@@ -835,8 +1213,8 @@ func (repo *MySQLDBRepository) ListTransactionByAccountNumber(ctx context.Contex
 func (repo *MySQLDBRepository) CountTransactionByAccountNumber(ctx context.Context, accountNumber string, timeFrom, timeTo time.Time) (int, error) {
 	lLog := mysqlLog.WithField("function", "CountTransactionByAccountNumber")
 	q := "SELECT COUNT(*) as trxCount" +
-		" FROM transactions WHERE account_number = ? AND transaction_time > ? AND transaction_time < ? AND is_deleted=false"
-	row := repo.db.QueryRowxContext(ctx, q, accountNumber, timeFrom, timeTo)
+		" FROM transactions WHERE tenant_id=? AND account_number = ? AND transaction_time > ? AND transaction_time < ? AND is_deleted=false"
+	row := repo.conn(ctx).QueryRowxContext(ctx, q, TenantFromContext(ctx), accountNumber, timeFrom, timeTo)
 	if row.Err() != nil {
 		lLog.Errorf("error while counting transaction by account number. got %s", row.Err().Error())
 		return 0, row.Err()
@@ -850,6 +1228,56 @@ func (repo *MySQLDBRepository) CountTransactionByAccountNumber(ctx context.Conte
 	return count, nil
 }
 
+// ListTransactionByAccountNumberAndMetadata will list transactions of the accountNumber in paginated fashion,
+// the transaction must be created within the time range and its metadata must match the filter.
+// Throws error if the underlying database connection has problem.
+func (repo *MySQLDBRepository) ListTransactionByAccountNumberAndMetadata(ctx context.Context, accountNumber string, timeFrom, timeTo time.Time, filter MetadataFilter, offset, length int) ([]*TransactionRecord, error) {
+	lLog := mysqlLog.WithField("function", "ListTransactionByAccountNumberAndMetadata")
+	cond, condArgs := metadataCondition(filter)
+	q := "SELECT " + transactionColumns +
+		" FROM transactions WHERE tenant_id=? AND account_number=? AND transaction_time > ? AND transaction_time < ? AND is_deleted=false" + cond + " ORDER BY transaction_time ASC LIMIT ?,?"
+	args := append([]interface{}{TenantFromContext(ctx), accountNumber, timeFrom, timeTo}, condArgs...)
+	rows, err := repo.conn(ctx).QueryxContext(ctx, q, append(args, offset, length)...)
+	if err != nil {
+		lLog.Errorf("error while listing transaction by account number and metadata. got %s", err.Error())
+		return nil, err
+	}
+	defer rows.Close()
+	ret := make([]*TransactionRecord, 0)
+	for rows.Next() {
+		ar, err := scanTransaction(rows)
+		if err != nil {
+			lLog.Errorf("error while scanning rows in ListTransactionByAccountNumberAndMetadata function. got %s", err.Error())
+		} else {
+			ret = append(ret, ar)
+		}
+	}
+	return ret, nil
+}
+
+// CountTransactionByAccountNumberAndMetadata will return the number of transactions of the accountNumber
+// created within the time range whose metadata matches the filter.
+// Throws error if the underlying database connection has problem.
+func (repo *MySQLDBRepository) CountTransactionByAccountNumberAndMetadata(ctx context.Context, accountNumber string, timeFrom, timeTo time.Time, filter MetadataFilter) (int, error) {
+	lLog := mysqlLog.WithField("function", "CountTransactionByAccountNumberAndMetadata")
+	cond, condArgs := metadataCondition(filter)
+	q := "SELECT COUNT(*) as trxCount" +
+		" FROM transactions WHERE tenant_id=? AND account_number = ? AND transaction_time > ? AND transaction_time < ? AND is_deleted=false" + cond
+	args := append([]interface{}{TenantFromContext(ctx), accountNumber, timeFrom, timeTo}, condArgs...)
+	row := repo.conn(ctx).QueryRowxContext(ctx, q, args...)
+	if row.Err() != nil {
+		lLog.Errorf("error while counting transaction by account number and metadata. got %s", row.Err().Error())
+		return 0, row.Err()
+	}
+	count := 0
+	err := row.Scan(&count)
+	if err != nil {
+		lLog.Errorf("error while counting transactions by account number and metadata. got %s", err.Error())
+		return 0, err
+	}
+	return count, nil
+}
+
 // ListTransactionByJournalID will list transactions , the transaction must belong to the
 // specified journalID arguments.
 // Throws error if the underlying database connection has problem.
@@ -857,9 +1285,9 @@ func (repo *MySQLDBRepository) CountTransactionByAccountNumber(ctx context.Conte
 // It returns list of TransactionRecord
 func (repo *MySQLDBRepository) ListTransactionByJournalID(ctx context.Context, journalID string) ([]*TransactionRecord, error) {
 	lLog := mysqlLog.WithField("function", "ListTransactionByJournalID")
-	q := "SELECT transaction_id, transaction_time, account_number, journal_id, description, alignment, amount, balance, created_at, created_by" +
-		" FROM transactions WHERE journal_id=? AND is_deleted=false"
-	rows, err := repo.db.QueryxContext(ctx, q, journalID)
+	q := "SELECT " + transactionColumns +
+		" FROM transactions WHERE tenant_id=? AND journal_id=? AND is_deleted=false"
+	rows, err := repo.conn(ctx).QueryxContext(ctx, q, TenantFromContext(ctx), journalID)
 	if err != nil {
 		lLog.Errorf("error while listing transaction by journalID. got %s", err.Error())
 		return nil, err
@@ -867,8 +1295,7 @@ func (repo *MySQLDBRepository) ListTransactionByJournalID(ctx context.Context, j
 	defer rows.Close()
 	ret := make([]*TransactionRecord, 0)
 	for rows.Next() {
-		ar := &TransactionRecord{}
-		err := rows.Scan(&ar.TransactionID, &ar.TransactionTime, &ar.AccountNumber, &ar.JournalID, &ar.Description, &ar.Alignment, &ar.Amount, &ar.Balance, &ar.CreatedAt, &ar.CreatedBy)
+		ar, err := scanTransaction(rows)
 		if err != nil {
 			lLog.Errorf("error while scanning rows in ListTransactionByJournalID function. got %s", err.Error())
 		} else {
@@ -878,6 +1305,37 @@ func (repo *MySQLDBRepository) ListTransactionByJournalID(ctx context.Context, j
 	return ret, nil
 }
 
+// ListTransactionByJournalIDs retrieves all transactions of the specified journals, sorted by journal and transaction id.
+// Throws error if the underlying database connection has problem.
+func (repo *MySQLDBRepository) ListTransactionByJournalIDs(ctx context.Context, journalIDs []string) ([]*TransactionRecord, error) {
+	lLog := mysqlLog.WithField("function", "ListTransactionByJournalIDs")
+	ret := make([]*TransactionRecord, 0)
+	if len(journalIDs) == 0 {
+		return ret, nil
+	}
+	q, args, err := sqlx.In("SELECT "+transactionColumns+
+		" FROM transactions WHERE tenant_id=? AND journal_id IN (?) AND is_deleted=false ORDER BY journal_id ASC, transaction_id ASC", TenantFromContext(ctx), journalIDs)
+	if err != nil {
+		lLog.Errorf("error while building query of transactions by journalIDs. got %s", err.Error())
+		return nil, err
+	}
+	rows, err := repo.conn(ctx).QueryxContext(ctx, q, args...)
+	if err != nil {
+		lLog.Errorf("error while listing transaction by journalIDs. got %s", err.Error())
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		ar, err := scanTransaction(rows)
+		if err != nil {
+			lLog.Errorf("error while scanning rows in ListTransactionByJournalIDs function. got %s", err.Error())
+		} else {
+			ret = append(ret, ar)
+		}
+	}
+	return ret, nil
+}
+
 // InsertCurrency will insert the data specified in the rec argument into database
 // will return error if the underlying database connection has problem. or if the
 // Currency Code already in the database.
@@ -899,9 +1357,10 @@ func (repo *MySQLDBRepository) InsertCurrency(ctx context.Context, rec *Currenci
 		rec.CreatedBy = rec.UpdatedBy[:16]
 	}
 	q := "INSERT INTO currencies(" +
-		"code, name, exchange, created_at, created_by, updated_at, updated_by, is_deleted" +
-		") VALUES(?, ?, ?, ?, ?, ?, ?, false)"
+		"tenant_id, code, name, exchange, created_at, created_by, updated_at, updated_by, is_deleted" +
+		") VALUES(?, ?, ?, ?, ?, ?, ?, ?, false)"
 	args := []interface{}{
+		TenantFromContext(ctx),
 		html.EscapeString(rec.Code),
 		html.EscapeString(rec.Name),
 		rec.Exchange, rec.CreatedAt,
@@ -909,7 +1368,7 @@ func (repo *MySQLDBRepository) InsertCurrency(ctx context.Context, rec *Currenci
 		rec.UpdatedAt,
 		html.EscapeString(rec.UpdatedBy),
 	}
-	_, err := repo.db.ExecContext(ctx, q, args...)
+	_, err := repo.conn(ctx).ExecContext(ctx, q, args...)
 	if err != nil {
 		lLog.Errorf("error while listing transaction by journalID. got %s", err.Error())
 		return "", err
@@ -939,7 +1398,7 @@ func (repo *MySQLDBRepository) UpdateCurrency(ctx context.Context, rec *Currenci
 	}
 	q := "UPDATE currencies " +
 		"set name=?, exchange=?, created_at=?, created_by=?, updated_at=?, updated_by=?" +
-		" WHERE code=? AND is_deleted=false"
+		" WHERE code=? AND tenant_id=? AND is_deleted=false"
 	args := []interface{}{
 		html.EscapeString(rec.Name),
 		rec.Exchange,
@@ -948,8 +1407,9 @@ func (repo *MySQLDBRepository) UpdateCurrency(ctx context.Context, rec *Currenci
 		rec.UpdatedAt,
 		html.EscapeString(rec.UpdatedBy),
 		html.EscapeString(rec.Code),
+		TenantFromContext(ctx),
 	}
-	_, err := repo.db.ExecContext(ctx, q, args...)
+	_, err := repo.conn(ctx).ExecContext(ctx, q, args...)
 	if err != nil {
 		lLog.Errorf("error while listing transaction by journalID. got %s", err.Error())
 		return err
@@ -964,11 +1424,11 @@ func (repo *MySQLDBRepository) DeleteCurrency(ctx context.Context, currencyCode
 	lLog := mysqlLog.WithField("function", "DeleteCurrency")
 	q := "UPDATE currencies " +
 		"set is_deleted=true" +
-		" WHERE code=? && is_deleted=true"
+		" WHERE code=? AND tenant_id=? && is_deleted=true"
 	args := []interface{}{
-		currencyCode,
+		currencyCode, TenantFromContext(ctx),
 	}
-	_, err := repo.db.ExecContext(ctx, q, args...)
+	_, err := repo.conn(ctx).ExecContext(ctx, q, args...)
 	if err != nil {
 		lLog.Errorf("error while deleting currency. got %s", err.Error())
 		return err
@@ -984,8 +1444,8 @@ func (repo *MySQLDBRepository) DeleteCurrency(ctx context.Context, currencyCode
 func (repo *MySQLDBRepository) ListCurrency(ctx context.Context, sort string, offset, length int) ([]*CurrenciesRecord, error) {
 	lLog := mysqlLog.WithField("function", "ListCurrency")
 	q := "SELECT code, name, exchange, created_at, created_by, updated_at, updated_by" +
-		" FROM currencies WHERE is_deleted=false ORDER BY " + sort + " ASC LIMIT ?,?"
-	rows, err := repo.db.QueryxContext(ctx, q, offset, length)
+		" FROM currencies WHERE tenant_id=? AND is_deleted=false ORDER BY " + sort + " ASC LIMIT ?,?"
+	rows, err := repo.conn(ctx).QueryxContext(ctx, q, TenantFromContext(ctx), offset, length)
 	if err != nil {
 		lLog.Errorf("error while listing currencies. got %s", err.Error())
 		return nil, err
@@ -1010,8 +1470,8 @@ func (repo *MySQLDBRepository) ListCurrency(ctx context.Context, sort string, of
 func (repo *MySQLDBRepository) GetCurrency(ctx context.Context, code string) (*CurrenciesRecord, error) {
 	lLog := mysqlLog.WithField("function", "GetCurrency")
 	q := "SELECT  code, name, exchange, created_at, created_by, updated_at, updated_by" +
-		" FROM currencies WHERE code=? AND is_deleted=false"
-	row := repo.db.QueryRowxContext(ctx, q, code)
+		" FROM currencies WHERE tenant_id=? AND code=? AND is_deleted=false"
+	row := repo.conn(ctx).QueryRowxContext(ctx, q, TenantFromContext(ctx), code)
 	if row.Err() != nil {
 		if row.Err() == sql.ErrNoRows {
 			return nil, acccore.ErrCurrencyNotFound
@@ -0,0 +1,197 @@
+package connector
+
+import (
+	"context"
+	"database/sql"
+	"html"
+	"time"
+
+	"github.com/hyperjumptech/bookkeeping/errors"
+	"github.com/hyperjumptech/bookkeeping/internal/contextkeys"
+	"github.com/jmoiron/sqlx"
+)
+
+// InsertHold will insert the data specified in the rec argument into database
+// will return error if the underlying database connection has problem. or if the
+// HoldID already in the database.
+// Will return the HoldID saved if successful.
+func (repo *MySQLDBRepository) InsertHold(ctx context.Context, rec *HoldRecord) (string, error) {
+	lLog := mysqlLog.WithField("function", "InsertHold")
+
+	theUser, ok := ctx.Value(contextkeys.UserIDContextKey).(string)
+	if !ok {
+		lLog.Errorf("UserContext Key %s is not in context", contextkeys.UserIDContextKey)
+		return "", errors.ErrUserContextKeyMissing
+	}
+	if len(theUser) > 16 {
+		theUser = theUser[:16]
+	}
+
+	if len(rec.HoldID) > 20 {
+		lLog.Errorf("HoldID %s is too long. Should not more than 20 digit", rec.HoldID)
+		return "", errors.ErrStringDataTooLong
+	}
+	if len(rec.AccountNumber) > 20 {
+		lLog.Errorf("Account Number %s is too long. Should not more than 20 digit", rec.AccountNumber)
+		return "", errors.ErrStringDataTooLong
+	}
+	if len(rec.Status) == 0 {
+		rec.Status = HoldStatusActive
+	}
+
+	rec.CreatedBy = html.EscapeString(theUser)
+	rec.CreatedAt = time.Now()
+	rec.UpdatedBy = rec.CreatedBy
+	rec.UpdatedAt = rec.CreatedAt
+	q := "INSERT INTO holds(" +
+		"tenant_id, hold_id, account_number, description, amount, status, expires_at, journal_id, created_at, created_by, updated_at, updated_by" +
+		") VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
+	args := []interface{}{
+		TenantFromContext(ctx), html.EscapeString(rec.HoldID), html.EscapeString(rec.AccountNumber), html.EscapeString(rec.Description), rec.Amount, rec.Status,
+		rec.ExpiresAt, html.EscapeString(rec.JournalID), rec.CreatedAt, rec.CreatedBy, rec.UpdatedAt, rec.UpdatedBy,
+	}
+	_, err := repo.conn(ctx).ExecContext(ctx, q, args...)
+	if err != nil {
+		lLog.Errorf("error while inserting hold. got %s", err.Error())
+		return "", err
+	}
+	return rec.HoldID, nil
+}
+
+// GetHold retrieves a HoldRecord from database where the holdID is specified.
+// Throws error if  the underlying database connection has problem.
+// It returns an instance of HoldRecord or nil if record not found
+func (repo *MySQLDBRepository) GetHold(ctx context.Context, holdID string) (*HoldRecord, error) {
+	lLog := mysqlLog.WithField("function", "GetHold")
+	q := "SELECT hold_id, account_number, description, amount, status, expires_at, journal_id, created_at, created_by, updated_at, updated_by" +
+		" FROM holds WHERE tenant_id=? AND hold_id=?"
+	row := repo.conn(ctx).QueryRowxContext(ctx, q, TenantFromContext(ctx), html.EscapeString(holdID))
+	if row.Err() != nil {
+		lLog.Errorf("error while retrieving hold. got %s", row.Err().Error())
+		return nil, row.Err()
+	}
+	hr := &HoldRecord{}
+	err := row.Scan(&hr.HoldID, &hr.AccountNumber, &hr.Description, &hr.Amount, &hr.Status, &hr.ExpiresAt, &hr.JournalID, &hr.CreatedAt, &hr.CreatedBy, &hr.UpdatedAt, &hr.UpdatedBy)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		lLog.Errorf("error while scanning hold record. got %s", err.Error())
+		return nil, err
+	}
+	return hr, nil
+}
+
+// ListActiveHoldsByAccountNumber list all holds on the account that are still active and not yet expired at the specified time.
+// Throws error if the underlying database connection has problem.
+func (repo *MySQLDBRepository) ListActiveHoldsByAccountNumber(ctx context.Context, accountNumber string, at time.Time) ([]*HoldRecord, error) {
+	lLog := mysqlLog.WithField("function", "ListActiveHoldsByAccountNumber")
+	q := "SELECT hold_id, account_number, description, amount, status, expires_at, journal_id, created_at, created_by, updated_at, updated_by" +
+		" FROM holds WHERE tenant_id=? AND account_number=? AND status=? AND expires_at > ? ORDER BY created_at ASC"
+	rows, err := repo.conn(ctx).QueryxContext(ctx, q, TenantFromContext(ctx), html.EscapeString(accountNumber), HoldStatusActive, at)
+	if err != nil {
+		lLog.Errorf("error while listing holds by account number. got %s", err.Error())
+		return nil, err
+	}
+	defer rows.Close()
+	ret := make([]*HoldRecord, 0)
+	for rows.Next() {
+		hr := &HoldRecord{}
+		err := rows.Scan(&hr.HoldID, &hr.AccountNumber, &hr.Description, &hr.Amount, &hr.Status, &hr.ExpiresAt, &hr.JournalID, &hr.CreatedAt, &hr.CreatedBy, &hr.UpdatedAt, &hr.UpdatedBy)
+		if err != nil {
+			lLog.Errorf("error while scanning rows in ListActiveHoldsByAccountNumber function. got %s", err.Error())
+		} else {
+			ret = append(ret, hr)
+		}
+	}
+	return ret, nil
+}
+
+// SumActiveHoldsByAccountNumber returns the total amount of holds on the account that are still active and not yet expired
+// at the specified time.
+// Throws error if the underlying database connection has problem.
+func (repo *MySQLDBRepository) SumActiveHoldsByAccountNumber(ctx context.Context, accountNumber string, at time.Time) (int64, error) {
+	return repo.sumActiveHoldsByAccountNumber(ctx, accountNumber, at, false)
+}
+
+// SumActiveHoldsByAccountNumberForUpdate sums the active holds just like SumActiveHoldsByAccountNumber, but as a locking read,
+// it counts the latest committed holds, not the ones in the transaction snapshot, and locks them until the database
+// transaction carried in the context ends.
+// It MUST be called with a context that carries a database transaction.
+func (repo *MySQLDBRepository) SumActiveHoldsByAccountNumberForUpdate(ctx context.Context, accountNumber string, at time.Time) (int64, error) {
+	if _, ok := ctx.Value(contextkeys.DBTransactionContextKey).(*sqlx.Tx); !ok {
+		mysqlLog.WithField("function", "SumActiveHoldsByAccountNumberForUpdate").Errorf("DBTransaction Key %s is not in context", contextkeys.DBTransactionContextKey)
+		return 0, errors.ErrDBTransactionMissing
+	}
+	return repo.sumActiveHoldsByAccountNumber(ctx, accountNumber, at, true)
+}
+
+func (repo *MySQLDBRepository) sumActiveHoldsByAccountNumber(ctx context.Context, accountNumber string, at time.Time, forUpdate bool) (int64, error) {
+	lLog := mysqlLog.WithField("function", "SumActiveHoldsByAccountNumber")
+	q := "SELECT COALESCE(SUM(amount), 0) FROM holds WHERE tenant_id=? AND account_number=? AND status=? AND expires_at > ?"
+	if forUpdate {
+		q += " FOR UPDATE"
+	}
+	row := repo.conn(ctx).QueryRowxContext(ctx, q, TenantFromContext(ctx), html.EscapeString(accountNumber), HoldStatusActive, at)
+	if row.Err() != nil {
+		lLog.Errorf("error while summing holds by account number. got %s", row.Err().Error())
+		return 0, row.Err()
+	}
+	var sum int64
+	err := row.Scan(&sum)
+	if err != nil {
+		lLog.Errorf("error while scanning sum of holds by account number. got %s", err.Error())
+		return 0, err
+	}
+	return sum, nil
+}
+
+// UpdateHoldStatus change the status of a hold from fromStatus into toStatus, and record the capturing journalID.
+// It returns false if the hold is not in the fromStatus, thus the status is not changed.
+// Throws error if the underlying database connection has problem.
+func (repo *MySQLDBRepository) UpdateHoldStatus(ctx context.Context, holdID, fromStatus, toStatus, journalID string) (bool, error) {
+	lLog := mysqlLog.WithField("function", "UpdateHoldStatus")
+
+	theUser, ok := ctx.Value(contextkeys.UserIDContextKey).(string)
+	if !ok {
+		lLog.Errorf("UserContext Key %s is not in context", contextkeys.UserIDContextKey)
+		return false, errors.ErrUserContextKeyMissing
+	}
+	if len(theUser) > 16 {
+		theUser = theUser[:16]
+	}
+
+	q := "UPDATE holds set" +
+		" status=?, journal_id=?, updated_at=?, updated_by=?" +
+		" WHERE hold_id=? AND tenant_id=? AND status=?"
+	args := []interface{}{
+		toStatus, html.EscapeString(journalID), time.Now(), html.EscapeString(theUser), html.EscapeString(holdID), TenantFromContext(ctx), fromStatus,
+	}
+	res, err := repo.conn(ctx).ExecContext(ctx, q, args...)
+	if err != nil {
+		lLog.Errorf("error while updating hold status. got %s", err.Error())
+		return false, err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		lLog.Errorf("error while reading affected rows of hold status update. got %s", err.Error())
+		return false, err
+	}
+	return affected == 1, nil
+}
+
+// ExpireHolds change the status of all active holds that have expired at the specified time into HoldStatusExpired.
+// It returns the number of expired holds.
+// Throws error if the underlying database connection has problem.
+func (repo *MySQLDBRepository) ExpireHolds(ctx context.Context, at time.Time) (int64, error) {
+	lLog := mysqlLog.WithField("function", "ExpireHolds")
+	q := "UPDATE holds set" +
+		" status=?, updated_at=?, updated_by=?" +
+		" WHERE status=? AND expires_at <= ?"
+	res, err := repo.conn(ctx).ExecContext(ctx, q, HoldStatusExpired, time.Now(), "SYSTEM", HoldStatusActive, at)
+	if err != nil {
+		lLog.Errorf("error while expiring holds. got %s", err.Error())
+		return 0, err
+	}
+	return res.RowsAffected()
+}
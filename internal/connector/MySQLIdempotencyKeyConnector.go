@@ -0,0 +1,87 @@
+package connector
+
+import (
+	"context"
+	"database/sql"
+	"html"
+	"time"
+
+	"github.com/hyperjumptech/bookkeeping/errors"
+	"github.com/hyperjumptech/bookkeeping/internal/contextkeys"
+)
+
+// clientIDOf returns the client id the way it is recorded, the same way as the users are.
+func clientIDOf(clientID string) string {
+	if len(clientID) > 16 {
+		clientID = clientID[:16]
+	}
+	return html.EscapeString(clientID)
+}
+
+// InsertIdempotencyKey will insert the idempotency key specified in the rec argument into database, along with the resource
+// created by the request in the database transaction carried in the context. The primary key lets the client use the key
+// within the scope only once, the request repeated concurrently waits for the transaction of the original request to end.
+// Throws error if the underlying database connection has problem, or ErrDuplicateKey if the client already used the key
+// within the same scope.
+func (repo *MySQLDBRepository) InsertIdempotencyKey(ctx context.Context, rec *IdempotencyKeyRecord) error {
+	lLog := mysqlLog.WithField("function", "InsertIdempotencyKey")
+
+	theUser, ok := ctx.Value(contextkeys.UserIDContextKey).(string)
+	if !ok {
+		lLog.Errorf("UserContext Key %s is not in context", contextkeys.UserIDContextKey)
+		return errors.ErrUserContextKeyMissing
+	}
+	if len(theUser) > 16 {
+		theUser = theUser[:16]
+	}
+
+	if len(rec.IdempotencyKey) > 64 {
+		lLog.Errorf("IdempotencyKey %s is too long. Should not more than 64 digit", rec.IdempotencyKey)
+		return errors.ErrStringDataTooLong
+	}
+	if len(rec.ResourceID) > 20 {
+		lLog.Errorf("ResourceID %s is too long. Should not more than 20 digit", rec.ResourceID)
+		return errors.ErrStringDataTooLong
+	}
+
+	rec.CreatedBy = html.EscapeString(theUser)
+	rec.CreatedAt = time.Now()
+	q := "INSERT INTO idempotency_keys(" +
+		"tenant_id, client_id, scope, idempotency_key, request_digest, response_code, resource_id, created_at, created_by" +
+		") VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?)"
+	_, err := repo.conn(ctx).ExecContext(ctx, q,
+		TenantFromContext(ctx), clientIDOf(rec.ClientID), rec.Scope, rec.IdempotencyKey, rec.RequestDigest, rec.ResponseCode, rec.ResourceID, rec.CreatedAt, rec.CreatedBy)
+	if isDuplicateKey(err) {
+		lLog.Warnf("idempotency key %s of %s is already used by %s", rec.IdempotencyKey, rec.Scope, rec.ClientID)
+		return errors.ErrDuplicateKey
+	}
+	if err != nil {
+		lLog.Errorf("error while inserting idempotency key. got %s", err.Error())
+		return err
+	}
+	return nil
+}
+
+// GetIdempotencyKey retrieves an IdempotencyKeyRecord from database where the client, scope and key is specified.
+// Throws error if  the underlying database connection has problem.
+// It returns an instance of IdempotencyKeyRecord or nil if record not found
+func (repo *MySQLDBRepository) GetIdempotencyKey(ctx context.Context, clientID, scope, key string) (*IdempotencyKeyRecord, error) {
+	lLog := mysqlLog.WithField("function", "GetIdempotencyKey")
+	q := "SELECT client_id, scope, idempotency_key, request_digest, response_code, resource_id, created_at, created_by" +
+		" FROM idempotency_keys WHERE tenant_id=? AND client_id=? AND scope=? AND idempotency_key=?"
+	row := repo.conn(ctx).QueryRowxContext(ctx, q, TenantFromContext(ctx), clientIDOf(clientID), scope, key)
+	if row.Err() != nil {
+		lLog.Errorf("error while retrieving idempotency key. got %s", row.Err().Error())
+		return nil, row.Err()
+	}
+	ir := &IdempotencyKeyRecord{}
+	err := row.Scan(&ir.ClientID, &ir.Scope, &ir.IdempotencyKey, &ir.RequestDigest, &ir.ResponseCode, &ir.ResourceID, &ir.CreatedAt, &ir.CreatedBy)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		lLog.Errorf("error while scanning idempotency key record. got %s", err.Error())
+		return nil, err
+	}
+	return ir, nil
+}
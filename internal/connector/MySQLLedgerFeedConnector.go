@@ -0,0 +1,58 @@
+package connector
+
+import (
+	"context"
+	"database/sql"
+)
+
+// NextSequence increments the sequence of the specified name of the tenant and returns its new value, starting from 1.
+// The sequence row stays locked until the database transaction carried in the context ends, so the values are
+// gapless and handed out in commit order.
+// Throws error if the underlying database connection has problem.
+func (repo *MySQLDBRepository) NextSequence(ctx context.Context, name string) (int64, error) {
+	lLog := mysqlLog.WithField("function", "NextSequence")
+	// LAST_INSERT_ID(expr) makes the new value available as the insert id of this very statement.
+	q := "INSERT INTO ledger_sequences(tenant_id, name, value) VALUES(?, ?, LAST_INSERT_ID(1))" +
+		" ON DUPLICATE KEY UPDATE value=LAST_INSERT_ID(value+1)"
+	res, err := repo.conn(ctx).ExecContext(ctx, q, TenantFromContext(ctx), name)
+	if err != nil {
+		lLog.Errorf("error while incrementing sequence %s. got %s", name, err.Error())
+		return 0, err
+	}
+	value, err := res.LastInsertId()
+	if err != nil {
+		lLog.Errorf("error while reading the value of sequence %s. got %s", name, err.Error())
+		return 0, err
+	}
+	return value, nil
+}
+
+// ListJournalsAfterSequence will list journals whose sequence is greater than afterSequence, in sequence order,
+// up to the specified length.
+// Throws error if the underlying database connection has problem.
+func (repo *MySQLDBRepository) ListJournalsAfterSequence(ctx context.Context, afterSequence int64, length int) ([]*JournalRecord, error) {
+	lLog := mysqlLog.WithField("function", "ListJournalsAfterSequence")
+	q := "SELECT " + journalColumns + ", sequence" +
+		" FROM journals WHERE tenant_id=? AND sequence > ? ORDER BY sequence ASC LIMIT ?"
+	rows, err := repo.conn(ctx).QueryxContext(ctx, q, TenantFromContext(ctx), afterSequence, length)
+	if err != nil {
+		lLog.Errorf("error while listing journals after sequence. got %s", err.Error())
+		return nil, err
+	}
+	defer rows.Close()
+	ret := make([]*JournalRecord, 0)
+	for rows.Next() {
+		jr := &JournalRecord{}
+		var metadata, reference sql.NullString
+		err := rows.Scan(&jr.JournalID, &jr.JournalingTime, &jr.Description, &jr.IsReversal, &jr.ReversedJournalID, &jr.TotalAmount, &jr.CreatedAt, &jr.CreatedBy, &metadata, &reference, &jr.Sequence)
+		// a row that can not be read must not be skipped, or the consumer would see a gap
+		if err != nil {
+			lLog.Errorf("error while scanning rows in ListJournalsAfterSequence function. got %s", err.Error())
+			return nil, err
+		}
+		jr.Metadata = metadata.String
+		jr.ExternalReference = reference.String
+		ret = append(ret, jr)
+	}
+	return ret, nil
+}
@@ -0,0 +1,247 @@
+package connector
+
+import (
+	"context"
+	"database/sql"
+	"html"
+	"time"
+
+	"github.com/hyperjumptech/bookkeeping/errors"
+	"github.com/hyperjumptech/bookkeeping/internal/contextkeys"
+)
+
+// InsertPendingJournal will insert the data specified in the rec argument into database. The description is stored
+// as is, since it is escaped when the journal is persisted on approval.
+// will return error if the underlying database connection has problem. or if the
+// PendingID already in the database.
+// Will return the PendingID saved if successful.
+func (repo *MySQLDBRepository) InsertPendingJournal(ctx context.Context, rec *PendingJournalRecord) (string, error) {
+	lLog := mysqlLog.WithField("function", "InsertPendingJournal")
+
+	theUser, ok := ctx.Value(contextkeys.UserIDContextKey).(string)
+	if !ok {
+		lLog.Errorf("UserContext Key %s is not in context", contextkeys.UserIDContextKey)
+		return "", errors.ErrUserContextKeyMissing
+	}
+	if len(theUser) > 16 {
+		theUser = theUser[:16]
+	}
+
+	if len(rec.PendingID) > 20 {
+		lLog.Errorf("PendingID %s is too long. Should not more than 20 digit", rec.PendingID)
+		return "", errors.ErrStringDataTooLong
+	}
+	if len(rec.Status) == 0 {
+		rec.Status = PendingJournalStatusPending
+	}
+
+	rec.SubmittedBy = html.EscapeString(theUser)
+	rec.SubmittedAt = time.Now()
+	q := "INSERT INTO pending_journals(" +
+		"tenant_id, pending_id, description, transactions, metadata, external_reference, amount, status, journal_id, review_note, submitted_at, submitted_by, reviewed_at, reviewed_by" +
+		") VALUES(?, ?, ?, ?, ?, ?, ?, ?, '', '', ?, ?, NULL, '')"
+	args := []interface{}{
+		TenantFromContext(ctx), html.EscapeString(rec.PendingID), rec.Description, rec.Transactions,
+		sql.NullString{String: rec.Metadata, Valid: len(rec.Metadata) > 0}, sql.NullString{String: rec.ExternalReference, Valid: len(rec.ExternalReference) > 0}, rec.Amount, rec.Status, rec.SubmittedAt, rec.SubmittedBy,
+	}
+	_, err := repo.conn(ctx).ExecContext(ctx, q, args...)
+	if err != nil {
+		lLog.Errorf("error while inserting pending journal. got %s", err.Error())
+		return "", err
+	}
+	return rec.PendingID, nil
+}
+
+// GetPendingJournal retrieves a PendingJournalRecord from database where the pendingID is specified.
+// Throws error if  the underlying database connection has problem.
+// It returns an instance of PendingJournalRecord or nil if record not found
+func (repo *MySQLDBRepository) GetPendingJournal(ctx context.Context, pendingID string) (*PendingJournalRecord, error) {
+	lLog := mysqlLog.WithField("function", "GetPendingJournal")
+	q := "SELECT pending_id, description, transactions, metadata, external_reference, amount, status, journal_id, review_note, submitted_at, submitted_by, reviewed_at, reviewed_by" +
+		" FROM pending_journals WHERE tenant_id=? AND pending_id=?"
+	row := repo.conn(ctx).QueryRowxContext(ctx, q, TenantFromContext(ctx), html.EscapeString(pendingID))
+	if row.Err() != nil {
+		lLog.Errorf("error while retrieving pending journal. got %s", row.Err().Error())
+		return nil, row.Err()
+	}
+	pr := &PendingJournalRecord{}
+	var metadata, reference sql.NullString
+	err := row.Scan(&pr.PendingID, &pr.Description, &pr.Transactions, &metadata, &reference, &pr.Amount, &pr.Status, &pr.JournalID, &pr.ReviewNote, &pr.SubmittedAt, &pr.SubmittedBy, &pr.ReviewedAt, &pr.ReviewedBy)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		lLog.Errorf("error while scanning pending journal record. got %s", err.Error())
+		return nil, err
+	}
+	pr.Metadata = metadata.String
+	pr.ExternalReference = reference.String
+	return pr, nil
+}
+
+// ListPendingJournalByStatus will list pending journals of the specified status in paginated fashion, sorted by submission time.
+// Throws error if the underlying database connection has problem.
+func (repo *MySQLDBRepository) ListPendingJournalByStatus(ctx context.Context, status string, offset, length int) ([]*PendingJournalRecord, error) {
+	lLog := mysqlLog.WithField("function", "ListPendingJournalByStatus")
+	q := "SELECT pending_id, description, transactions, metadata, external_reference, amount, status, journal_id, review_note, submitted_at, submitted_by, reviewed_at, reviewed_by" +
+		" FROM pending_journals WHERE tenant_id=? AND status=? ORDER BY submitted_at ASC LIMIT ?,?"
+	rows, err := repo.conn(ctx).QueryxContext(ctx, q, TenantFromContext(ctx), status, offset, length)
+	if err != nil {
+		lLog.Errorf("error while listing pending journals. got %s", err.Error())
+		return nil, err
+	}
+	defer rows.Close()
+	ret := make([]*PendingJournalRecord, 0)
+	for rows.Next() {
+		pr := &PendingJournalRecord{}
+		var metadata, reference sql.NullString
+		err := rows.Scan(&pr.PendingID, &pr.Description, &pr.Transactions, &metadata, &reference, &pr.Amount, &pr.Status, &pr.JournalID, &pr.ReviewNote, &pr.SubmittedAt, &pr.SubmittedBy, &pr.ReviewedAt, &pr.ReviewedBy)
+		if err != nil {
+			lLog.Errorf("error while scanning rows in ListPendingJournalByStatus function. got %s", err.Error())
+		} else {
+			pr.Metadata = metadata.String
+			pr.ExternalReference = reference.String
+			ret = append(ret, pr)
+		}
+	}
+	return ret, nil
+}
+
+// CountPendingJournalsByStatus returns the number of pending journals of the specified status in database.
+// Throws error if the underlying database connection has problem.
+func (repo *MySQLDBRepository) CountPendingJournalsByStatus(ctx context.Context, status string) (int, error) {
+	lLog := mysqlLog.WithField("function", "CountPendingJournalsByStatus")
+	q := "SELECT COUNT(*) FROM pending_journals WHERE tenant_id=? AND status=?"
+	row := repo.conn(ctx).QueryRowxContext(ctx, q, TenantFromContext(ctx), status)
+	if row.Err() != nil {
+		lLog.Errorf("error while counting pending journals. got %s", row.Err().Error())
+		return 0, row.Err()
+	}
+	count := 0
+	err := row.Scan(&count)
+	if err != nil {
+		lLog.Errorf("error while scanning count of pending journals. got %s", err.Error())
+		return 0, err
+	}
+	return count, nil
+}
+
+// UpdatePendingJournalStatus change the status of a pending journal from fromStatus into toStatus, and record the reviewer
+// taken from the context, the review note and the posted journalID.
+// It returns false if the pending journal is not in the fromStatus, thus the status is not changed.
+// Throws error if the underlying database connection has problem.
+func (repo *MySQLDBRepository) UpdatePendingJournalStatus(ctx context.Context, pendingID, fromStatus, toStatus, journalID, note string) (bool, error) {
+	lLog := mysqlLog.WithField("function", "UpdatePendingJournalStatus")
+
+	theUser, ok := ctx.Value(contextkeys.UserIDContextKey).(string)
+	if !ok {
+		lLog.Errorf("UserContext Key %s is not in context", contextkeys.UserIDContextKey)
+		return false, errors.ErrUserContextKeyMissing
+	}
+	if len(theUser) > 16 {
+		theUser = theUser[:16]
+	}
+
+	q := "UPDATE pending_journals set" +
+		" status=?, journal_id=?, review_note=?, reviewed_at=?, reviewed_by=?" +
+		" WHERE pending_id=? AND tenant_id=? AND status=?"
+	args := []interface{}{
+		toStatus, html.EscapeString(journalID), html.EscapeString(note), time.Now(), html.EscapeString(theUser), html.EscapeString(pendingID), TenantFromContext(ctx), fromStatus,
+	}
+	res, err := repo.conn(ctx).ExecContext(ctx, q, args...)
+	if err != nil {
+		lLog.Errorf("error while updating pending journal status. got %s", err.Error())
+		return false, err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		lLog.Errorf("error while reading affected rows of pending journal status update. got %s", err.Error())
+		return false, err
+	}
+	return affected == 1, nil
+}
+
+// InsertApprovalRule will insert the data specified in the rec argument into database
+// will return error if the underlying database connection has problem. or if the
+// RuleID already in the database.
+// Will return the RuleID saved if successful.
+func (repo *MySQLDBRepository) InsertApprovalRule(ctx context.Context, rec *ApprovalRuleRecord) (string, error) {
+	lLog := mysqlLog.WithField("function", "InsertApprovalRule")
+
+	theUser, ok := ctx.Value(contextkeys.UserIDContextKey).(string)
+	if !ok {
+		lLog.Errorf("UserContext Key %s is not in context", contextkeys.UserIDContextKey)
+		return "", errors.ErrUserContextKeyMissing
+	}
+	if len(theUser) > 16 {
+		theUser = theUser[:16]
+	}
+
+	if len(rec.RuleID) > 20 {
+		lLog.Errorf("RuleID %s is too long. Should not more than 20 digit", rec.RuleID)
+		return "", errors.ErrStringDataTooLong
+	}
+	if len(rec.Coa) > 10 {
+		lLog.Errorf("COA %s is too long. Should not more than 10 digit", rec.Coa)
+		return "", errors.ErrStringDataTooLong
+	}
+
+	rec.CreatedBy = html.EscapeString(theUser)
+	rec.CreatedAt = time.Now()
+	q := "INSERT INTO approval_rules(" +
+		"tenant_id, rule_id, description, min_amount, coa, created_at, created_by, is_deleted" +
+		") VALUES(?, ?, ?, ?, ?, ?, ?, false)"
+	args := []interface{}{
+		TenantFromContext(ctx), html.EscapeString(rec.RuleID), html.EscapeString(rec.Description), rec.MinAmount, html.EscapeString(rec.Coa), rec.CreatedAt, rec.CreatedBy,
+	}
+	_, err := repo.conn(ctx).ExecContext(ctx, q, args...)
+	if err != nil {
+		lLog.Errorf("error while inserting approval rule. got %s", err.Error())
+		return "", err
+	}
+	return rec.RuleID, nil
+}
+
+// ListApprovalRules will list all approval rules, sorted by creation time.
+// Throws error if the underlying database connection has problem.
+func (repo *MySQLDBRepository) ListApprovalRules(ctx context.Context) ([]*ApprovalRuleRecord, error) {
+	lLog := mysqlLog.WithField("function", "ListApprovalRules")
+	q := "SELECT rule_id, description, min_amount, coa, created_at, created_by" +
+		" FROM approval_rules WHERE tenant_id=? AND is_deleted=false ORDER BY created_at ASC"
+	rows, err := repo.conn(ctx).QueryxContext(ctx, q, TenantFromContext(ctx))
+	if err != nil {
+		lLog.Errorf("error while listing approval rules. got %s", err.Error())
+		return nil, err
+	}
+	defer rows.Close()
+	ret := make([]*ApprovalRuleRecord, 0)
+	for rows.Next() {
+		ar := &ApprovalRuleRecord{}
+		err := rows.Scan(&ar.RuleID, &ar.Description, &ar.MinAmount, &ar.Coa, &ar.CreatedAt, &ar.CreatedBy)
+		if err != nil {
+			lLog.Errorf("error while scanning rows in ListApprovalRules function. got %s", err.Error())
+		} else {
+			ret = append(ret, ar)
+		}
+	}
+	return ret, nil
+}
+
+// DeleteApprovalRule will delete the approval rule of the specified ruleID.
+// It returns false if there is no such rule.
+// Throws error if the underlying database connection has problem.
+func (repo *MySQLDBRepository) DeleteApprovalRule(ctx context.Context, ruleID string) (bool, error) {
+	lLog := mysqlLog.WithField("function", "DeleteApprovalRule")
+	q := "UPDATE approval_rules set is_deleted=true WHERE rule_id=? AND tenant_id=? AND is_deleted=false"
+	res, err := repo.conn(ctx).ExecContext(ctx, q, html.EscapeString(ruleID), TenantFromContext(ctx))
+	if err != nil {
+		lLog.Errorf("error while deleting approval rule. got %s", err.Error())
+		return false, err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		lLog.Errorf("error while reading affected rows of approval rule deletion. got %s", err.Error())
+		return false, err
+	}
+	return affected == 1, nil
+}
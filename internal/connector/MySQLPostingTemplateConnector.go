@@ -0,0 +1,117 @@
+package connector
+
+import (
+	"context"
+	"database/sql"
+	"html"
+	"time"
+
+	"github.com/hyperjumptech/bookkeeping/errors"
+	"github.com/hyperjumptech/bookkeeping/internal/contextkeys"
+)
+
+// SavePostingTemplate will insert the data specified in the rec argument into database, or update
+// the posting template of the same name if its already in the database.
+// Throws error if the underlying database connection has problem.
+func (repo *MySQLDBRepository) SavePostingTemplate(ctx context.Context, rec *PostingTemplateRecord) error {
+	lLog := mysqlLog.WithField("function", "SavePostingTemplate")
+
+	theUser, ok := ctx.Value(contextkeys.UserIDContextKey).(string)
+	if !ok {
+		lLog.Errorf("UserContext Key %s is not in context", contextkeys.UserIDContextKey)
+		return errors.ErrUserContextKeyMissing
+	}
+	if len(theUser) > 16 {
+		theUser = theUser[:16]
+	}
+
+	if len(rec.Name) > 64 {
+		lLog.Errorf("Name %s is too long. Should not more than 64 digit", rec.Name)
+		return errors.ErrStringDataTooLong
+	}
+
+	rec.UpdatedBy = html.EscapeString(theUser)
+	rec.UpdatedAt = time.Now()
+	q := "INSERT INTO posting_templates(" +
+		"tenant_id, name, description, definition, created_at, created_by, updated_at, updated_by, is_deleted" +
+		") VALUES(?, ?, ?, ?, ?, ?, ?, ?, false)" +
+		" ON DUPLICATE KEY UPDATE description=VALUES(description), definition=VALUES(definition)," +
+		" updated_at=VALUES(updated_at), updated_by=VALUES(updated_by), is_deleted=false"
+	args := []interface{}{
+		TenantFromContext(ctx), html.EscapeString(rec.Name), html.EscapeString(rec.Description), rec.Definition, rec.UpdatedAt, rec.UpdatedBy, rec.UpdatedAt, rec.UpdatedBy,
+	}
+	_, err := repo.conn(ctx).ExecContext(ctx, q, args...)
+	if err != nil {
+		lLog.Errorf("error while saving posting template. got %s", err.Error())
+		return err
+	}
+	return nil
+}
+
+// GetPostingTemplate retrieves a PostingTemplateRecord from database where the name is specified.
+// Throws error if  the underlying database connection has problem.
+// It returns an instance of PostingTemplateRecord or nil if record not found
+func (repo *MySQLDBRepository) GetPostingTemplate(ctx context.Context, name string) (*PostingTemplateRecord, error) {
+	lLog := mysqlLog.WithField("function", "GetPostingTemplate")
+	q := "SELECT name, description, definition, created_at, created_by, updated_at, updated_by" +
+		" FROM posting_templates WHERE tenant_id=? AND name=? AND is_deleted=false"
+	row := repo.conn(ctx).QueryRowxContext(ctx, q, TenantFromContext(ctx), html.EscapeString(name))
+	if row.Err() != nil {
+		lLog.Errorf("error while retrieving posting template. got %s", row.Err().Error())
+		return nil, row.Err()
+	}
+	pr := &PostingTemplateRecord{}
+	err := row.Scan(&pr.Name, &pr.Description, &pr.Definition, &pr.CreatedAt, &pr.CreatedBy, &pr.UpdatedAt, &pr.UpdatedBy)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		lLog.Errorf("error while scanning posting template record. got %s", err.Error())
+		return nil, err
+	}
+	return pr, nil
+}
+
+// ListPostingTemplates will list all posting templates, sorted by name.
+// Throws error if the underlying database connection has problem.
+func (repo *MySQLDBRepository) ListPostingTemplates(ctx context.Context) ([]*PostingTemplateRecord, error) {
+	lLog := mysqlLog.WithField("function", "ListPostingTemplates")
+	q := "SELECT name, description, definition, created_at, created_by, updated_at, updated_by" +
+		" FROM posting_templates WHERE tenant_id=? AND is_deleted=false ORDER BY name ASC"
+	rows, err := repo.conn(ctx).QueryxContext(ctx, q, TenantFromContext(ctx))
+	if err != nil {
+		lLog.Errorf("error while listing posting templates. got %s", err.Error())
+		return nil, err
+	}
+	defer rows.Close()
+	ret := make([]*PostingTemplateRecord, 0)
+	for rows.Next() {
+		pr := &PostingTemplateRecord{}
+		err := rows.Scan(&pr.Name, &pr.Description, &pr.Definition, &pr.CreatedAt, &pr.CreatedBy, &pr.UpdatedAt, &pr.UpdatedBy)
+		if err != nil {
+			lLog.Errorf("error while scanning rows in ListPostingTemplates function. got %s", err.Error())
+		} else {
+			ret = append(ret, pr)
+		}
+	}
+	return ret, nil
+}
+
+// DeletePostingTemplate will delete the posting template of the specified name.
+// It returns false if there is no such template.
+// Throws error if the underlying database connection has problem.
+func (repo *MySQLDBRepository) DeletePostingTemplate(ctx context.Context, name string) (bool, error) {
+	lLog := mysqlLog.WithField("function", "DeletePostingTemplate")
+	q := "UPDATE posting_templates set is_deleted=true WHERE tenant_id=? AND name=? AND is_deleted=false"
+	res, err := repo.conn(ctx).ExecContext(ctx, q, TenantFromContext(ctx), html.EscapeString(name))
+	if err != nil {
+		lLog.Errorf("error while deleting posting template. got %s", err.Error())
+		return false, err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		lLog.Errorf("error while reading affected rows of posting template deletion. got %s", err.Error())
+		return false, err
+	}
+	return affected == 1, nil
+}
@@ -0,0 +1,47 @@
+package connector
+
+import (
+	"context"
+	"time"
+
+	"github.com/hyperjumptech/bookkeeping/errors"
+)
+
+// IncrementRateLimit counts a request of the key in the window starting at windowStart and ending at windowEnd,
+// and returns the number of requests of the key counted in the window so far, by all instances.
+// Throws error if the underlying database connection has problem.
+func (repo *MySQLDBRepository) IncrementRateLimit(ctx context.Context, key string, windowStart, windowEnd time.Time) (int, error) {
+	lLog := mysqlLog.WithField("function", "IncrementRateLimit")
+	if len(key) > 128 {
+		lLog.Errorf("rate limit key %s is too long. Should not more than 128 digit", key)
+		return 0, errors.ErrStringDataTooLong
+	}
+
+	// LAST_INSERT_ID(expr) makes the incremented count readable on this connection without another round trip
+	q := "INSERT INTO rate_limits(limit_key, window_start, window_end, request_count) VALUES(?, ?, ?, LAST_INSERT_ID(1))" +
+		" ON DUPLICATE KEY UPDATE request_count=LAST_INSERT_ID(request_count + 1)"
+	res, err := repo.conn(ctx).ExecContext(ctx, q, key, windowStart, windowEnd)
+	if err != nil {
+		lLog.Errorf("error while incrementing rate limit. got %s", err.Error())
+		return 0, err
+	}
+	count, err := res.LastInsertId()
+	if err != nil {
+		lLog.Errorf("error while reading rate limit count. got %s", err.Error())
+		return 0, err
+	}
+	return int(count), nil
+}
+
+// DeleteRateLimitsBefore deletes the counts of the windows ended at or before the specified time.
+// It returns the number of deleted counts.
+// Throws error if the underlying database connection has problem.
+func (repo *MySQLDBRepository) DeleteRateLimitsBefore(ctx context.Context, before time.Time) (int64, error) {
+	lLog := mysqlLog.WithField("function", "DeleteRateLimitsBefore")
+	res, err := repo.conn(ctx).ExecContext(ctx, "DELETE FROM rate_limits WHERE window_end <= ?", before)
+	if err != nil {
+		lLog.Errorf("error while deleting rate limits. got %s", err.Error())
+		return 0, err
+	}
+	return res.RowsAffected()
+}
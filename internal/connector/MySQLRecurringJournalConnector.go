@@ -0,0 +1,264 @@
+package connector
+
+import (
+	"context"
+	"database/sql"
+	"html"
+	"time"
+
+	"github.com/hyperjumptech/bookkeeping/errors"
+	"github.com/hyperjumptech/bookkeeping/internal/contextkeys"
+	"github.com/jmoiron/sqlx"
+)
+
+// InsertRecurringJournal will insert the data specified in the rec argument into database. The description is stored
+// as is, since it is escaped when the journal is persisted on every run.
+// will return error if the underlying database connection has problem. or if the
+// ScheduleID already in the database.
+// Will return the ScheduleID saved if successful.
+func (repo *MySQLDBRepository) InsertRecurringJournal(ctx context.Context, rec *RecurringJournalRecord) (string, error) {
+	lLog := mysqlLog.WithField("function", "InsertRecurringJournal")
+
+	theUser, ok := ctx.Value(contextkeys.UserIDContextKey).(string)
+	if !ok {
+		lLog.Errorf("UserContext Key %s is not in context", contextkeys.UserIDContextKey)
+		return "", errors.ErrUserContextKeyMissing
+	}
+	if len(theUser) > 16 {
+		theUser = theUser[:16]
+	}
+
+	if len(rec.ScheduleID) > 20 {
+		lLog.Errorf("ScheduleID %s is too long. Should not more than 20 digit", rec.ScheduleID)
+		return "", errors.ErrStringDataTooLong
+	}
+	if len(rec.CronExpression) > 64 {
+		lLog.Errorf("Cron expression %s is too long. Should not more than 64 digit", rec.CronExpression)
+		return "", errors.ErrStringDataTooLong
+	}
+	if len(rec.Status) == 0 {
+		rec.Status = RecurringJournalStatusActive
+	}
+
+	rec.CreatedBy = html.EscapeString(theUser)
+	rec.CreatedAt = time.Now()
+	rec.UpdatedBy = rec.CreatedBy
+	rec.UpdatedAt = rec.CreatedAt
+	q := "INSERT INTO recurring_journals(" +
+		"tenant_id, schedule_id, cron_expression, description, transactions, metadata, status, created_at, created_by, updated_at, updated_by, is_deleted" +
+		") VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, false)"
+	args := []interface{}{
+		TenantFromContext(ctx), html.EscapeString(rec.ScheduleID), rec.CronExpression, rec.Description, rec.Transactions,
+		sql.NullString{String: rec.Metadata, Valid: len(rec.Metadata) > 0}, rec.Status,
+		rec.CreatedAt, rec.CreatedBy, rec.UpdatedAt, rec.UpdatedBy,
+	}
+	_, err := repo.conn(ctx).ExecContext(ctx, q, args...)
+	if err != nil {
+		lLog.Errorf("error while inserting recurring journal. got %s", err.Error())
+		return "", err
+	}
+	return rec.ScheduleID, nil
+}
+
+// GetRecurringJournal retrieves a RecurringJournalRecord from database where the scheduleID is specified.
+// Throws error if  the underlying database connection has problem.
+// It returns an instance of RecurringJournalRecord or nil if record not found
+func (repo *MySQLDBRepository) GetRecurringJournal(ctx context.Context, scheduleID string) (*RecurringJournalRecord, error) {
+	return repo.getRecurringJournal(ctx, scheduleID, false)
+}
+
+// GetRecurringJournalForUpdate retrieves a RecurringJournalRecord just like GetRecurringJournal, but also locks the recurring journal row
+// until the database transaction carried in the context ends.
+// It MUST be called with a context that carries a database transaction.
+func (repo *MySQLDBRepository) GetRecurringJournalForUpdate(ctx context.Context, scheduleID string) (*RecurringJournalRecord, error) {
+	if _, ok := ctx.Value(contextkeys.DBTransactionContextKey).(*sqlx.Tx); !ok {
+		mysqlLog.WithField("function", "GetRecurringJournalForUpdate").Errorf("DBTransaction Key %s is not in context", contextkeys.DBTransactionContextKey)
+		return nil, errors.ErrDBTransactionMissing
+	}
+	return repo.getRecurringJournal(ctx, scheduleID, true)
+}
+
+func (repo *MySQLDBRepository) getRecurringJournal(ctx context.Context, scheduleID string, forUpdate bool) (*RecurringJournalRecord, error) {
+	lLog := mysqlLog.WithField("function", "GetRecurringJournal")
+	q := "SELECT schedule_id, tenant_id, cron_expression, description, transactions, metadata, status, created_at, created_by, updated_at, updated_by" +
+		" FROM recurring_journals WHERE tenant_id=? AND schedule_id=? AND is_deleted=false"
+	if forUpdate {
+		q += " FOR UPDATE"
+	}
+	row := repo.conn(ctx).QueryRowxContext(ctx, q, TenantFromContext(ctx), html.EscapeString(scheduleID))
+	if row.Err() != nil {
+		lLog.Errorf("error while retrieving recurring journal. got %s", row.Err().Error())
+		return nil, row.Err()
+	}
+	rr := &RecurringJournalRecord{}
+	var metadata sql.NullString
+	err := row.Scan(&rr.ScheduleID, &rr.TenantID, &rr.CronExpression, &rr.Description, &rr.Transactions, &metadata, &rr.Status, &rr.CreatedAt, &rr.CreatedBy, &rr.UpdatedAt, &rr.UpdatedBy)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		lLog.Errorf("error while scanning recurring journal record. got %s", err.Error())
+		return nil, err
+	}
+	rr.Metadata = metadata.String
+	return rr, nil
+}
+
+// ListRecurringJournal will list recurring journals in paginated fashion, sorted by creation time.
+// Throws error if the underlying database connection has problem.
+func (repo *MySQLDBRepository) ListRecurringJournal(ctx context.Context, offset, length int) ([]*RecurringJournalRecord, error) {
+	q := "SELECT schedule_id, tenant_id, cron_expression, description, transactions, metadata, status, created_at, created_by, updated_at, updated_by" +
+		" FROM recurring_journals WHERE tenant_id=? AND is_deleted=false ORDER BY created_at ASC LIMIT ?,?"
+	return repo.listRecurringJournal(ctx, "ListRecurringJournal", q, TenantFromContext(ctx), offset, length)
+}
+
+// ListRecurringJournalByStatus will list all recurring journals of the specified status.
+// Throws error if the underlying database connection has problem.
+func (repo *MySQLDBRepository) ListRecurringJournalByStatus(ctx context.Context, status string) ([]*RecurringJournalRecord, error) {
+	q := "SELECT schedule_id, tenant_id, cron_expression, description, transactions, metadata, status, created_at, created_by, updated_at, updated_by" +
+		" FROM recurring_journals WHERE status=? AND is_deleted=false ORDER BY created_at ASC"
+	return repo.listRecurringJournal(ctx, "ListRecurringJournalByStatus", q, status)
+}
+
+func (repo *MySQLDBRepository) listRecurringJournal(ctx context.Context, function, q string, args ...interface{}) ([]*RecurringJournalRecord, error) {
+	lLog := mysqlLog.WithField("function", function)
+	rows, err := repo.conn(ctx).QueryxContext(ctx, q, args...)
+	if err != nil {
+		lLog.Errorf("error while listing recurring journals. got %s", err.Error())
+		return nil, err
+	}
+	defer rows.Close()
+	ret := make([]*RecurringJournalRecord, 0)
+	for rows.Next() {
+		rr := &RecurringJournalRecord{}
+		var metadata sql.NullString
+		err := rows.Scan(&rr.ScheduleID, &rr.TenantID, &rr.CronExpression, &rr.Description, &rr.Transactions, &metadata, &rr.Status, &rr.CreatedAt, &rr.CreatedBy, &rr.UpdatedAt, &rr.UpdatedBy)
+		if err != nil {
+			lLog.Errorf("error while scanning rows in %s function. got %s", function, err.Error())
+		} else {
+			rr.Metadata = metadata.String
+			ret = append(ret, rr)
+		}
+	}
+	return ret, nil
+}
+
+// CountRecurringJournals returns the number of recurring journals in database.
+// Throws error if the underlying database connection has problem.
+func (repo *MySQLDBRepository) CountRecurringJournals(ctx context.Context) (int, error) {
+	lLog := mysqlLog.WithField("function", "CountRecurringJournals")
+	q := "SELECT COUNT(*) FROM recurring_journals WHERE tenant_id=? AND is_deleted=false"
+	row := repo.conn(ctx).QueryRowxContext(ctx, q, TenantFromContext(ctx))
+	if row.Err() != nil {
+		lLog.Errorf("error while counting recurring journals. got %s", row.Err().Error())
+		return 0, row.Err()
+	}
+	count := 0
+	err := row.Scan(&count)
+	if err != nil {
+		lLog.Errorf("error while scanning count of recurring journals. got %s", err.Error())
+		return 0, err
+	}
+	return count, nil
+}
+
+// UpdateRecurringJournalStatus update the status of a recurring journal.
+// Throws error if the underlying database connection has problem.
+func (repo *MySQLDBRepository) UpdateRecurringJournalStatus(ctx context.Context, scheduleID, status string) error {
+	lLog := mysqlLog.WithField("function", "UpdateRecurringJournalStatus")
+
+	theUser, ok := ctx.Value(contextkeys.UserIDContextKey).(string)
+	if !ok {
+		lLog.Errorf("UserContext Key %s is not in context", contextkeys.UserIDContextKey)
+		return errors.ErrUserContextKeyMissing
+	}
+	if len(theUser) > 16 {
+		theUser = theUser[:16]
+	}
+
+	q := "UPDATE recurring_journals set" +
+		" status=?, updated_at=?, updated_by=?" +
+		" WHERE schedule_id=? AND tenant_id=? AND is_deleted=false"
+	args := []interface{}{
+		status, time.Now(), html.EscapeString(theUser), html.EscapeString(scheduleID), TenantFromContext(ctx),
+	}
+	_, err := repo.conn(ctx).ExecContext(ctx, q, args...)
+	if err != nil {
+		lLog.Errorf("error while updating recurring journal status. got %s", err.Error())
+		return err
+	}
+	return nil
+}
+
+// InsertRecurringJournalRun will insert the data specified in the rec argument into database
+// Throws error if the underlying database connection has problem, or ErrDuplicateKey if the fire time
+// of the recurring journal is already run, the unique index on fire time lets only one run claim it.
+// Will return the RunID saved if successful.
+func (repo *MySQLDBRepository) InsertRecurringJournalRun(ctx context.Context, rec *RecurringJournalRunRecord) (string, error) {
+	lLog := mysqlLog.WithField("function", "InsertRecurringJournalRun")
+
+	if len(rec.RunID) > 20 {
+		lLog.Errorf("RunID %s is too long. Should not more than 20 digit", rec.RunID)
+		return "", errors.ErrStringDataTooLong
+	}
+	q := "INSERT INTO recurring_journal_runs(" +
+		"tenant_id, run_id, schedule_id, run_at, fire_time, success, journal_id, error_message" +
+		") VALUES(?, ?, ?, ?, ?, ?, ?, ?)"
+	args := []interface{}{
+		TenantFromContext(ctx), html.EscapeString(rec.RunID), html.EscapeString(rec.ScheduleID), rec.RunAt, rec.FireTime, rec.Success, html.EscapeString(rec.JournalID), html.EscapeString(rec.ErrorMessage),
+	}
+	_, err := repo.conn(ctx).ExecContext(ctx, q, args...)
+	if isDuplicateKey(err) {
+		lLog.Warnf("recurring journal %s is already run at %v", rec.ScheduleID, rec.FireTime)
+		return "", errors.ErrDuplicateKey
+	}
+	if err != nil {
+		lLog.Errorf("error while inserting recurring journal run. got %s", err.Error())
+		return "", err
+	}
+	return rec.RunID, nil
+}
+
+// ListRecurringJournalRun will list the runs of a recurring journal in paginated fashion, latest run first.
+// Throws error if the underlying database connection has problem.
+func (repo *MySQLDBRepository) ListRecurringJournalRun(ctx context.Context, scheduleID string, offset, length int) ([]*RecurringJournalRunRecord, error) {
+	lLog := mysqlLog.WithField("function", "ListRecurringJournalRun")
+	q := "SELECT run_id, schedule_id, run_at, fire_time, success, journal_id, error_message" +
+		" FROM recurring_journal_runs WHERE tenant_id=? AND schedule_id=? ORDER BY run_at DESC LIMIT ?,?"
+	rows, err := repo.conn(ctx).QueryxContext(ctx, q, TenantFromContext(ctx), html.EscapeString(scheduleID), offset, length)
+	if err != nil {
+		lLog.Errorf("error while listing recurring journal runs. got %s", err.Error())
+		return nil, err
+	}
+	defer rows.Close()
+	ret := make([]*RecurringJournalRunRecord, 0)
+	for rows.Next() {
+		rr := &RecurringJournalRunRecord{}
+		err := rows.Scan(&rr.RunID, &rr.ScheduleID, &rr.RunAt, &rr.FireTime, &rr.Success, &rr.JournalID, &rr.ErrorMessage)
+		if err != nil {
+			lLog.Errorf("error while scanning rows in ListRecurringJournalRun function. got %s", err.Error())
+		} else {
+			ret = append(ret, rr)
+		}
+	}
+	return ret, nil
+}
+
+// CountRecurringJournalRuns returns the number of runs of a recurring journal in database.
+// Throws error if the underlying database connection has problem.
+func (repo *MySQLDBRepository) CountRecurringJournalRuns(ctx context.Context, scheduleID string) (int, error) {
+	lLog := mysqlLog.WithField("function", "CountRecurringJournalRuns")
+	q := "SELECT COUNT(*) FROM recurring_journal_runs WHERE tenant_id=? AND schedule_id=?"
+	row := repo.conn(ctx).QueryRowxContext(ctx, q, TenantFromContext(ctx), html.EscapeString(scheduleID))
+	if row.Err() != nil {
+		lLog.Errorf("error while counting recurring journal runs. got %s", row.Err().Error())
+		return 0, row.Err()
+	}
+	count := 0
+	err := row.Scan(&count)
+	if err != nil {
+		lLog.Errorf("error while scanning count of recurring journal runs. got %s", err.Error())
+		return 0, err
+	}
+	return count, nil
+}
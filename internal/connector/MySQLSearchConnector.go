@@ -0,0 +1,296 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+)
+
+var (
+	// TransactionSearchSorts maps the fields the transactions can be sorted by to their column
+	TransactionSearchSorts = map[string]string{
+		"transaction_time": "t.transaction_time",
+		"amount":           "t.amount",
+		"account_number":   "t.account_number",
+		"journal_id":       "t.journal_id",
+		"created_by":       "t.created_by",
+	}
+
+	// JournalSearchSorts maps the fields the journals can be sorted by to their column
+	JournalSearchSorts = map[string]string{
+		"journaling_time": "j.journaling_time",
+		"total_amount":    "j.total_amount",
+		"created_by":      "j.created_by",
+		"sequence":        "j.sequence",
+	}
+)
+
+// transactionSearchColumns are the transactionColumns of the transactions aliased t
+const transactionSearchColumns = "t.transaction_id, t.transaction_time, t.account_number, t.journal_id, t.description, t.alignment, t.amount, t.balance, t.created_at, t.created_by, t.metadata"
+
+// journalSearchColumns are the journalColumns of the journals aliased j
+const journalSearchColumns = "j.journal_id, j.journaling_time, j.description, j.is_reversal, j.reversed_journal_id, j.total_amount, j.created_at, j.created_by, j.metadata, j.external_reference"
+
+// likeEscaper escapes the LIKE wildcards, so they are matched literally
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// likeContains returns the LIKE pattern of the strings containing s
+func likeContains(s string) string {
+	return "%" + likeEscaper.Replace(s) + "%"
+}
+
+// likePrefix returns the LIKE pattern of the strings starting with s
+func likePrefix(s string) string {
+	return likeEscaper.Replace(s) + "%"
+}
+
+// searchOrderBy builds the ORDER BY clause of the sorts, with the tie breaker column last.
+// It returns error if a sort field is not in the columns.
+func searchOrderBy(sorts []SearchSort, columns map[string]string, defaultColumn, tieBreaker string) (string, error) {
+	order := make([]string, 0, len(sorts)+1)
+	for _, sort := range sorts {
+		column, ok := columns[sort.Field]
+		if !ok {
+			return "", fmt.Errorf("can not sort by %s", sort.Field)
+		}
+		if sort.Ascending {
+			order = append(order, column+" ASC")
+		} else {
+			order = append(order, column+" DESC")
+		}
+	}
+	if len(order) == 0 {
+		order = append(order, defaultColumn+" ASC")
+	}
+	return " ORDER BY " + strings.Join(order, ", ") + ", " + tieBreaker + " ASC", nil
+}
+
+// transactionSearchFrom builds the FROM and WHERE clauses, and their arguments, out of the tenant and the filter.
+// The accounts and journals are only joined when the filter requires them.
+func transactionSearchFrom(tenantID string, filter *TransactionSearchFilter) (string, []interface{}) {
+	from := " FROM transactions t"
+	where := " WHERE t.tenant_id=? AND t.is_deleted=false"
+	args := []interface{}{tenantID}
+	if filter == nil {
+		return from + where, args
+	}
+	if len(filter.Coa) > 0 {
+		from += " JOIN accounts a ON a.tenant_id=t.tenant_id AND a.account_number=t.account_number"
+		where += " AND a.coa LIKE ?"
+		args = append(args, likePrefix(filter.Coa))
+	}
+	if filter.Reversal != nil {
+		from += " JOIN journals j ON j.journal_id=t.journal_id AND j.tenant_id=t.tenant_id"
+		where += " AND j.is_reversal=?"
+		args = append(args, *filter.Reversal)
+	}
+	if len(filter.AccountNumbers) > 0 {
+		where += " AND t.account_number IN (?)"
+		args = append(args, filter.AccountNumbers)
+	}
+	if len(filter.Alignment) > 0 {
+		where += " AND t.alignment=?"
+		args = append(args, filter.Alignment)
+	}
+	if filter.MinAmount != nil {
+		where += " AND t.amount>=?"
+		args = append(args, *filter.MinAmount)
+	}
+	if filter.MaxAmount != nil {
+		where += " AND t.amount<=?"
+		args = append(args, *filter.MaxAmount)
+	}
+	if len(filter.CreatedBy) > 0 {
+		where += " AND t.created_by=?"
+		args = append(args, html.EscapeString(filter.CreatedBy))
+	}
+	if len(filter.Description) > 0 {
+		where += " AND t.description LIKE ?"
+		args = append(args, likeContains(html.EscapeString(filter.Description)))
+	}
+	if !filter.From.IsZero() {
+		where += " AND t.transaction_time>=?"
+		args = append(args, filter.From)
+	}
+	if !filter.To.IsZero() {
+		where += " AND t.transaction_time<?"
+		args = append(args, filter.To)
+	}
+	return from + where, args
+}
+
+// journalSearchFrom builds the FROM and WHERE clauses, and their arguments, out of the tenant and the filter.
+// The account, COA and alignment criteria are matched by any transaction of the journal.
+func journalSearchFrom(tenantID string, filter *JournalSearchFilter) (string, []interface{}) {
+	q := " FROM journals j WHERE j.tenant_id=? AND j.is_deleted=false"
+	args := []interface{}{tenantID}
+	if filter == nil {
+		return q, args
+	}
+	if filter.Reversal != nil {
+		q += " AND j.is_reversal=?"
+		args = append(args, *filter.Reversal)
+	}
+	if filter.MinAmount != nil {
+		q += " AND j.total_amount>=?"
+		args = append(args, *filter.MinAmount)
+	}
+	if filter.MaxAmount != nil {
+		q += " AND j.total_amount<=?"
+		args = append(args, *filter.MaxAmount)
+	}
+	if len(filter.CreatedBy) > 0 {
+		q += " AND j.created_by=?"
+		args = append(args, html.EscapeString(filter.CreatedBy))
+	}
+	if len(filter.Description) > 0 {
+		q += " AND j.description LIKE ?"
+		args = append(args, likeContains(html.EscapeString(filter.Description)))
+	}
+	if !filter.From.IsZero() {
+		q += " AND j.journaling_time>=?"
+		args = append(args, filter.From)
+	}
+	if !filter.To.IsZero() {
+		q += " AND j.journaling_time<?"
+		args = append(args, filter.To)
+	}
+	if len(filter.AccountNumbers) > 0 || len(filter.Coa) > 0 || len(filter.Alignment) > 0 {
+		q += " AND EXISTS (SELECT 1 FROM transactions t"
+		if len(filter.Coa) > 0 {
+			q += " JOIN accounts a ON a.tenant_id=t.tenant_id AND a.account_number=t.account_number"
+		}
+		q += " WHERE t.tenant_id=j.tenant_id AND t.journal_id=j.journal_id AND t.is_deleted=false"
+		if len(filter.AccountNumbers) > 0 {
+			q += " AND t.account_number IN (?)"
+			args = append(args, filter.AccountNumbers)
+		}
+		if len(filter.Coa) > 0 {
+			q += " AND a.coa LIKE ?"
+			args = append(args, likePrefix(filter.Coa))
+		}
+		if len(filter.Alignment) > 0 {
+			q += " AND t.alignment=?"
+			args = append(args, filter.Alignment)
+		}
+		q += ")"
+	}
+	return q, args
+}
+
+// SearchTransactions will list the transactions matching the filter in paginated fashion, in the sort order.
+// The transaction id breaks the ties, the transactions are sorted by transaction time when no sort is given.
+// Throws error if the underlying database connection has problem, or a sort field is unknown.
+func (repo *MySQLDBRepository) SearchTransactions(ctx context.Context, filter *TransactionSearchFilter, sorts []SearchSort, offset, length int) ([]*TransactionRecord, error) {
+	lLog := mysqlLog.WithField("function", "SearchTransactions")
+	orderBy, err := searchOrderBy(sorts, TransactionSearchSorts, "t.transaction_time", "t.transaction_id")
+	if err != nil {
+		return nil, err
+	}
+	from, args := transactionSearchFrom(TenantFromContext(ctx), filter)
+	q, args, err := sqlx.In("SELECT "+transactionSearchColumns+from+orderBy+" LIMIT ?,?", append(args, offset, length)...)
+	if err != nil {
+		lLog.Errorf("error while building the transaction search query. got %s", err.Error())
+		return nil, err
+	}
+	rows, err := repo.conn(ctx).QueryxContext(ctx, q, args...)
+	if err != nil {
+		lLog.Errorf("error while searching transactions. got %s", err.Error())
+		return nil, err
+	}
+	defer rows.Close()
+	ret := make([]*TransactionRecord, 0)
+	for rows.Next() {
+		tr, err := scanTransaction(rows)
+		if err != nil {
+			lLog.Errorf("error while scanning rows in SearchTransactions function. got %s", err.Error())
+		} else {
+			ret = append(ret, tr)
+		}
+	}
+	return ret, nil
+}
+
+// CountTransactionSearch returns the number of transactions matching the filter.
+// Throws error if the underlying database connection has problem.
+func (repo *MySQLDBRepository) CountTransactionSearch(ctx context.Context, filter *TransactionSearchFilter) (int, error) {
+	lLog := mysqlLog.WithField("function", "CountTransactionSearch")
+	from, args := transactionSearchFrom(TenantFromContext(ctx), filter)
+	q, args, err := sqlx.In("SELECT COUNT(*)"+from, args...)
+	if err != nil {
+		lLog.Errorf("error while building the transaction search count query. got %s", err.Error())
+		return 0, err
+	}
+	row := repo.conn(ctx).QueryRowxContext(ctx, q, args...)
+	if row.Err() != nil {
+		lLog.Errorf("error while counting transaction search. got %s", row.Err().Error())
+		return 0, row.Err()
+	}
+	count := 0
+	err = row.Scan(&count)
+	if err != nil {
+		lLog.Errorf("error while scanning count of transaction search. got %s", err.Error())
+		return 0, err
+	}
+	return count, nil
+}
+
+// SearchJournals will list the journals matching the filter in paginated fashion, in the sort order.
+// The journal id breaks the ties, the journals are sorted by journaling time when no sort is given.
+// Throws error if the underlying database connection has problem, or a sort field is unknown.
+func (repo *MySQLDBRepository) SearchJournals(ctx context.Context, filter *JournalSearchFilter, sorts []SearchSort, offset, length int) ([]*JournalRecord, error) {
+	lLog := mysqlLog.WithField("function", "SearchJournals")
+	orderBy, err := searchOrderBy(sorts, JournalSearchSorts, "j.journaling_time", "j.journal_id")
+	if err != nil {
+		return nil, err
+	}
+	from, args := journalSearchFrom(TenantFromContext(ctx), filter)
+	q, args, err := sqlx.In("SELECT "+journalSearchColumns+from+orderBy+" LIMIT ?,?", append(args, offset, length)...)
+	if err != nil {
+		lLog.Errorf("error while building the journal search query. got %s", err.Error())
+		return nil, err
+	}
+	rows, err := repo.conn(ctx).QueryxContext(ctx, q, args...)
+	if err != nil {
+		lLog.Errorf("error while searching journals. got %s", err.Error())
+		return nil, err
+	}
+	defer rows.Close()
+	ret := make([]*JournalRecord, 0)
+	for rows.Next() {
+		jr, err := scanJournal(rows)
+		if err != nil {
+			lLog.Errorf("error while scanning rows in SearchJournals function. got %s", err.Error())
+		} else {
+			ret = append(ret, jr)
+		}
+	}
+	return ret, nil
+}
+
+// CountJournalSearch returns the number of journals matching the filter.
+// Throws error if the underlying database connection has problem.
+func (repo *MySQLDBRepository) CountJournalSearch(ctx context.Context, filter *JournalSearchFilter) (int, error) {
+	lLog := mysqlLog.WithField("function", "CountJournalSearch")
+	from, args := journalSearchFrom(TenantFromContext(ctx), filter)
+	q, args, err := sqlx.In("SELECT COUNT(*)"+from, args...)
+	if err != nil {
+		lLog.Errorf("error while building the journal search count query. got %s", err.Error())
+		return 0, err
+	}
+	row := repo.conn(ctx).QueryRowxContext(ctx, q, args...)
+	if row.Err() != nil {
+		lLog.Errorf("error while counting journal search. got %s", row.Err().Error())
+		return 0, row.Err()
+	}
+	count := 0
+	err = row.Scan(&count)
+	if err != nil {
+		lLog.Errorf("error while scanning count of journal search. got %s", err.Error())
+		return 0, err
+	}
+	return count, nil
+}
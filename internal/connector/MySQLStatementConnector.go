@@ -0,0 +1,32 @@
+package connector
+
+import (
+	"context"
+	"time"
+)
+
+// GetAccountBalanceAt returns the balance the account had after its transactions up to the time at,
+// that is its current balance less the transactions made after.
+// Working back from the current balance, rather than reading the balance of the last transaction before the time,
+// does not depend on the order of the transactions made within the same second.
+// Both are read by the same statement, so a journal posted meanwhile is either in both or in neither.
+// Throws error if the underlying database connection has problem, or sql.ErrNoRows if the account does not exist.
+func (repo *MySQLDBRepository) GetAccountBalanceAt(ctx context.Context, accountNumber string, at time.Time) (int64, error) {
+	lLog := mysqlLog.WithField("function", "GetAccountBalanceAt")
+	q := "SELECT a.balance - COALESCE(SUM(CASE WHEN t.alignment=a.alignment THEN t.amount ELSE -t.amount END), 0)" +
+		" FROM accounts a LEFT JOIN transactions t ON t.tenant_id=a.tenant_id AND t.account_number=a.account_number" +
+		" AND t.transaction_time > ? AND t.is_deleted=false" +
+		" WHERE a.tenant_id=? AND a.account_number=? GROUP BY a.balance, a.alignment"
+	row := repo.conn(ctx).QueryRowxContext(ctx, q, at, TenantFromContext(ctx), accountNumber)
+	if row.Err() != nil {
+		lLog.Errorf("error while reading the balance of account %s at %s. got %s", accountNumber, at, row.Err().Error())
+		return 0, row.Err()
+	}
+	var balance int64
+	err := row.Scan(&balance)
+	if err != nil {
+		lLog.Errorf("error while scanning the balance of account %s at %s. got %s", accountNumber, at, err.Error())
+		return 0, err
+	}
+	return balance, nil
+}
@@ -0,0 +1,125 @@
+package connector
+
+import (
+	"context"
+	"database/sql"
+	"html"
+	"time"
+
+	"github.com/hyperjumptech/bookkeeping/errors"
+	"github.com/hyperjumptech/bookkeeping/internal/contextkeys"
+)
+
+const tenantColumns = "tenant_id, name, description, created_at, created_by, updated_at, updated_by"
+
+// scanTenant scan a row of the tenants table
+func scanTenant(scanner interface{ Scan(...interface{}) error }) (*TenantRecord, error) {
+	tr := &TenantRecord{}
+	var description sql.NullString
+	err := scanner.Scan(&tr.TenantID, &tr.Name, &description, &tr.CreatedAt, &tr.CreatedBy, &tr.UpdatedAt, &tr.UpdatedBy)
+	if err != nil {
+		return nil, err
+	}
+	tr.Description = description.String
+	return tr, nil
+}
+
+// InsertTenant will insert the tenant specified in the rec argument into database.
+// Throws error if the underlying database connection has problem, or the tenant already exist.
+func (repo *MySQLDBRepository) InsertTenant(ctx context.Context, rec *TenantRecord) error {
+	lLog := mysqlLog.WithField("function", "InsertTenant")
+
+	theUser, ok := ctx.Value(contextkeys.UserIDContextKey).(string)
+	if !ok {
+		lLog.Errorf("UserContext Key %s is not in context", contextkeys.UserIDContextKey)
+		return errors.ErrUserContextKeyMissing
+	}
+	if len(theUser) > 16 {
+		theUser = theUser[:16]
+	}
+
+	if len(rec.TenantID) > 16 {
+		lLog.Errorf("TenantID %s is too long. Should not more than 16 digit", rec.TenantID)
+		return errors.ErrStringDataTooLong
+	}
+	if len(rec.Name) > 128 {
+		lLog.Errorf("Tenant name %s is too long. Should not more than 128 digit", rec.Name)
+		return errors.ErrStringDataTooLong
+	}
+
+	rec.CreatedBy = html.EscapeString(theUser)
+	rec.CreatedAt = time.Now()
+	rec.UpdatedBy = rec.CreatedBy
+	rec.UpdatedAt = rec.CreatedAt
+	q := "INSERT INTO tenants(" + tenantColumns + ") VALUES(?, ?, ?, ?, ?, ?, ?)"
+	_, err := repo.conn(ctx).ExecContext(ctx, q, rec.TenantID, html.EscapeString(rec.Name), html.EscapeString(rec.Description),
+		rec.CreatedAt, rec.CreatedBy, rec.UpdatedAt, rec.UpdatedBy)
+	if err != nil {
+		lLog.Errorf("error while inserting tenant. got %s", err.Error())
+		return err
+	}
+	return nil
+}
+
+// GetTenant retrieves a TenantRecord from database where the tenantID is specified.
+// Throws error if  the underlying database connection has problem.
+// It returns an instance of TenantRecord or nil if record not found
+func (repo *MySQLDBRepository) GetTenant(ctx context.Context, tenantID string) (*TenantRecord, error) {
+	lLog := mysqlLog.WithField("function", "GetTenant")
+	q := "SELECT " + tenantColumns + " FROM tenants WHERE tenant_id=?"
+	row := repo.conn(ctx).QueryRowxContext(ctx, q, tenantID)
+	if row.Err() != nil {
+		lLog.Errorf("error while retrieving tenant. got %s", row.Err().Error())
+		return nil, row.Err()
+	}
+	tr, err := scanTenant(row)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		lLog.Errorf("error while scanning tenant record. got %s", err.Error())
+		return nil, err
+	}
+	return tr, nil
+}
+
+// ListTenants will list the tenants in paginated fashion, sorted by their tenant id.
+// Throws error if the underlying database connection has problem.
+func (repo *MySQLDBRepository) ListTenants(ctx context.Context, offset, length int) ([]*TenantRecord, error) {
+	lLog := mysqlLog.WithField("function", "ListTenants")
+	q := "SELECT " + tenantColumns + " FROM tenants ORDER BY tenant_id ASC LIMIT ?,?"
+	rows, err := repo.conn(ctx).QueryxContext(ctx, q, offset, length)
+	if err != nil {
+		lLog.Errorf("error while listing tenants. got %s", err.Error())
+		return nil, err
+	}
+	defer rows.Close()
+	ret := make([]*TenantRecord, 0)
+	for rows.Next() {
+		tr, err := scanTenant(rows)
+		if err != nil {
+			lLog.Errorf("error while scanning rows in ListTenants function. got %s", err.Error())
+		} else {
+			ret = append(ret, tr)
+		}
+	}
+	return ret, nil
+}
+
+// CountTenants returns the number of tenants in database.
+// Throws error if the underlying database connection has problem.
+func (repo *MySQLDBRepository) CountTenants(ctx context.Context) (int, error) {
+	lLog := mysqlLog.WithField("function", "CountTenants")
+	row := repo.conn(ctx).QueryRowxContext(ctx, "SELECT COUNT(*) FROM tenants")
+	if row.Err() != nil {
+		lLog.Errorf("error while counting tenants. got %s", row.Err().Error())
+		return 0, row.Err()
+	}
+	count := 0
+	err := row.Scan(&count)
+	if err != nil {
+		lLog.Errorf("error while scanning count of tenants. got %s", err.Error())
+		return 0, err
+	}
+	return count, nil
+}
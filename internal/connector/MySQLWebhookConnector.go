@@ -0,0 +1,473 @@
+package connector
+
+import (
+	"context"
+	"database/sql"
+	"html"
+	"time"
+
+	"github.com/hyperjumptech/bookkeeping/errors"
+	"github.com/hyperjumptech/bookkeeping/internal/contextkeys"
+)
+
+// InsertOutboxEvent will insert the event specified in the rec argument into database as not yet dispatched.
+// Throws error if the underlying database connection has problem.
+// Will return the EventID assigned by the database if successful.
+func (repo *MySQLDBRepository) InsertOutboxEvent(ctx context.Context, rec *OutboxEventRecord) (int64, error) {
+	lLog := mysqlLog.WithField("function", "InsertOutboxEvent")
+
+	theUser, ok := ctx.Value(contextkeys.UserIDContextKey).(string)
+	if !ok {
+		lLog.Errorf("UserContext Key %s is not in context", contextkeys.UserIDContextKey)
+		return 0, errors.ErrUserContextKeyMissing
+	}
+	if len(theUser) > 16 {
+		theUser = theUser[:16]
+	}
+
+	if len(rec.EventType) > 32 {
+		lLog.Errorf("EventType %s is too long. Should not more than 32 digit", rec.EventType)
+		return 0, errors.ErrStringDataTooLong
+	}
+
+	rec.TenantID = TenantFromContext(ctx)
+	rec.Dispatched = false
+	rec.CreatedBy = html.EscapeString(theUser)
+	rec.CreatedAt = time.Now()
+	q := "INSERT INTO outbox_events(tenant_id, event_type, payload, dispatched, created_at, created_by) VALUES(?, ?, ?, FALSE, ?, ?)"
+	res, err := repo.conn(ctx).ExecContext(ctx, q, TenantFromContext(ctx), rec.EventType, rec.Payload, rec.CreatedAt, rec.CreatedBy)
+	if err != nil {
+		lLog.Errorf("error while inserting outbox event. got %s", err.Error())
+		return 0, err
+	}
+	rec.EventID, err = res.LastInsertId()
+	if err != nil {
+		lLog.Errorf("error while reading the id of inserted outbox event. got %s", err.Error())
+		return 0, err
+	}
+	return rec.EventID, nil
+}
+
+// GetOutboxEvent retrieves an OutboxEventRecord from database where the eventID is specified.
+// Throws error if  the underlying database connection has problem.
+// It returns an instance of OutboxEventRecord or nil if record not found
+func (repo *MySQLDBRepository) GetOutboxEvent(ctx context.Context, eventID int64) (*OutboxEventRecord, error) {
+	lLog := mysqlLog.WithField("function", "GetOutboxEvent")
+	q := "SELECT event_id, tenant_id, event_type, payload, dispatched, created_at, created_by FROM outbox_events WHERE tenant_id=? AND event_id=?"
+	row := repo.conn(ctx).QueryRowxContext(ctx, q, TenantFromContext(ctx), eventID)
+	if row.Err() != nil {
+		lLog.Errorf("error while retrieving outbox event. got %s", row.Err().Error())
+		return nil, row.Err()
+	}
+	er := &OutboxEventRecord{}
+	err := row.Scan(&er.EventID, &er.TenantID, &er.EventType, &er.Payload, &er.Dispatched, &er.CreatedAt, &er.CreatedBy)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		lLog.Errorf("error while scanning outbox event record. got %s", err.Error())
+		return nil, err
+	}
+	return er, nil
+}
+
+// ListUndispatchedOutboxEvents will list events not yet dispatched across the tenants, oldest event first, up to the specified length.
+// Throws error if the underlying database connection has problem.
+func (repo *MySQLDBRepository) ListUndispatchedOutboxEvents(ctx context.Context, length int) ([]*OutboxEventRecord, error) {
+	lLog := mysqlLog.WithField("function", "ListUndispatchedOutboxEvents")
+	q := "SELECT event_id, tenant_id, event_type, payload, dispatched, created_at, created_by" +
+		" FROM outbox_events WHERE dispatched=FALSE ORDER BY event_id ASC LIMIT ?"
+	rows, err := repo.conn(ctx).QueryxContext(ctx, q, length)
+	if err != nil {
+		lLog.Errorf("error while listing outbox events. got %s", err.Error())
+		return nil, err
+	}
+	defer rows.Close()
+	ret := make([]*OutboxEventRecord, 0)
+	for rows.Next() {
+		er := &OutboxEventRecord{}
+		err := rows.Scan(&er.EventID, &er.TenantID, &er.EventType, &er.Payload, &er.Dispatched, &er.CreatedAt, &er.CreatedBy)
+		if err != nil {
+			lLog.Errorf("error while scanning rows in ListUndispatchedOutboxEvents function. got %s", err.Error())
+		} else {
+			ret = append(ret, er)
+		}
+	}
+	return ret, nil
+}
+
+// MarkOutboxEventDispatched mark the event of the specified eventID as dispatched.
+// It returns false if the event is already dispatched.
+// Throws error if the underlying database connection has problem.
+func (repo *MySQLDBRepository) MarkOutboxEventDispatched(ctx context.Context, eventID int64) (bool, error) {
+	lLog := mysqlLog.WithField("function", "MarkOutboxEventDispatched")
+	q := "UPDATE outbox_events SET dispatched=TRUE WHERE tenant_id=? AND event_id=? AND dispatched=FALSE"
+	res, err := repo.conn(ctx).ExecContext(ctx, q, TenantFromContext(ctx), eventID)
+	if err != nil {
+		lLog.Errorf("error while marking outbox event dispatched. got %s", err.Error())
+		return false, err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		lLog.Errorf("error while reading affected rows of outbox event update. got %s", err.Error())
+		return false, err
+	}
+	return affected == 1, nil
+}
+
+// InsertWebhookSubscription will insert the data specified in the rec argument into database
+// will return error if the underlying database connection has problem. or if the
+// SubscriptionID already in the database.
+// Will return the SubscriptionID saved if successful.
+func (repo *MySQLDBRepository) InsertWebhookSubscription(ctx context.Context, rec *WebhookSubscriptionRecord) (string, error) {
+	lLog := mysqlLog.WithField("function", "InsertWebhookSubscription")
+
+	theUser, ok := ctx.Value(contextkeys.UserIDContextKey).(string)
+	if !ok {
+		lLog.Errorf("UserContext Key %s is not in context", contextkeys.UserIDContextKey)
+		return "", errors.ErrUserContextKeyMissing
+	}
+	if len(theUser) > 16 {
+		theUser = theUser[:16]
+	}
+
+	if len(rec.SubscriptionID) > 20 {
+		lLog.Errorf("SubscriptionID %s is too long. Should not more than 20 digit", rec.SubscriptionID)
+		return "", errors.ErrStringDataTooLong
+	}
+	if len(rec.URL) > 512 {
+		lLog.Errorf("URL %s is too long. Should not more than 512 digit", rec.URL)
+		return "", errors.ErrStringDataTooLong
+	}
+	if len(rec.EventTypes) > 255 {
+		lLog.Errorf("EventTypes %s is too long. Should not more than 255 digit", rec.EventTypes)
+		return "", errors.ErrStringDataTooLong
+	}
+	if len(rec.Description) > 255 {
+		lLog.Errorf("Description %s is too long. Should not more than 255 digit", rec.Description)
+		return "", errors.ErrStringDataTooLong
+	}
+
+	rec.CreatedBy = html.EscapeString(theUser)
+	rec.CreatedAt = time.Now()
+	q := "INSERT INTO webhook_subscriptions(tenant_id, subscription_id, url, event_types, secret, description, created_at, created_by) VALUES(?, ?, ?, ?, ?, ?, ?, ?)"
+	_, err := repo.conn(ctx).ExecContext(ctx, q,
+		TenantFromContext(ctx), html.EscapeString(rec.SubscriptionID), rec.URL, rec.EventTypes, rec.Secret, html.EscapeString(rec.Description), rec.CreatedAt, rec.CreatedBy)
+	if err != nil {
+		lLog.Errorf("error while inserting webhook subscription. got %s", err.Error())
+		return "", err
+	}
+	return rec.SubscriptionID, nil
+}
+
+// GetWebhookSubscription retrieves a WebhookSubscriptionRecord from database where the subscriptionID is specified.
+// Throws error if  the underlying database connection has problem.
+// It returns an instance of WebhookSubscriptionRecord or nil if record not found
+func (repo *MySQLDBRepository) GetWebhookSubscription(ctx context.Context, subscriptionID string) (*WebhookSubscriptionRecord, error) {
+	lLog := mysqlLog.WithField("function", "GetWebhookSubscription")
+	q := "SELECT subscription_id, url, event_types, secret, description, created_at, created_by FROM webhook_subscriptions WHERE tenant_id=? AND subscription_id=?"
+	row := repo.conn(ctx).QueryRowxContext(ctx, q, TenantFromContext(ctx), subscriptionID)
+	if row.Err() != nil {
+		lLog.Errorf("error while retrieving webhook subscription. got %s", row.Err().Error())
+		return nil, row.Err()
+	}
+	sr := &WebhookSubscriptionRecord{}
+	err := row.Scan(&sr.SubscriptionID, &sr.URL, &sr.EventTypes, &sr.Secret, &sr.Description, &sr.CreatedAt, &sr.CreatedBy)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		lLog.Errorf("error while scanning webhook subscription record. got %s", err.Error())
+		return nil, err
+	}
+	return sr, nil
+}
+
+// ListWebhookSubscriptions will list all webhook subscriptions, sorted by creation time.
+// Throws error if the underlying database connection has problem.
+func (repo *MySQLDBRepository) ListWebhookSubscriptions(ctx context.Context) ([]*WebhookSubscriptionRecord, error) {
+	lLog := mysqlLog.WithField("function", "ListWebhookSubscriptions")
+	q := "SELECT subscription_id, url, event_types, secret, description, created_at, created_by FROM webhook_subscriptions WHERE tenant_id=? ORDER BY created_at ASC"
+	rows, err := repo.conn(ctx).QueryxContext(ctx, q, TenantFromContext(ctx))
+	if err != nil {
+		lLog.Errorf("error while listing webhook subscriptions. got %s", err.Error())
+		return nil, err
+	}
+	defer rows.Close()
+	ret := make([]*WebhookSubscriptionRecord, 0)
+	for rows.Next() {
+		sr := &WebhookSubscriptionRecord{}
+		err := rows.Scan(&sr.SubscriptionID, &sr.URL, &sr.EventTypes, &sr.Secret, &sr.Description, &sr.CreatedAt, &sr.CreatedBy)
+		if err != nil {
+			lLog.Errorf("error while scanning rows in ListWebhookSubscriptions function. got %s", err.Error())
+		} else {
+			ret = append(ret, sr)
+		}
+	}
+	return ret, nil
+}
+
+// DeleteWebhookSubscription will delete the webhook subscription of the specified subscriptionID.
+// It returns false if there is no such subscription.
+// Throws error if the underlying database connection has problem.
+func (repo *MySQLDBRepository) DeleteWebhookSubscription(ctx context.Context, subscriptionID string) (bool, error) {
+	lLog := mysqlLog.WithField("function", "DeleteWebhookSubscription")
+	q := "DELETE FROM webhook_subscriptions WHERE tenant_id=? AND subscription_id=?"
+	res, err := repo.conn(ctx).ExecContext(ctx, q, TenantFromContext(ctx), subscriptionID)
+	if err != nil {
+		lLog.Errorf("error while deleting webhook subscription. got %s", err.Error())
+		return false, err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		lLog.Errorf("error while reading affected rows of webhook subscription deletion. got %s", err.Error())
+		return false, err
+	}
+	return affected == 1, nil
+}
+
+// InsertWebhookDelivery will insert the delivery specified in the rec argument into database as PENDING.
+// It returns false if the event is already scheduled for delivery to the same subscription.
+// Throws error if the underlying database connection has problem.
+func (repo *MySQLDBRepository) InsertWebhookDelivery(ctx context.Context, rec *WebhookDeliveryRecord) (bool, error) {
+	lLog := mysqlLog.WithField("function", "InsertWebhookDelivery")
+
+	rec.TenantID = TenantFromContext(ctx)
+	rec.Status = WebhookDeliveryStatusPending
+	rec.Attempts = 0
+	rec.CreatedAt = time.Now()
+	rec.UpdatedAt = rec.CreatedAt
+	if rec.NextAttemptAt.IsZero() {
+		rec.NextAttemptAt = rec.CreatedAt
+	}
+	q := "INSERT IGNORE INTO webhook_deliveries(" +
+		"tenant_id, event_id, subscription_id, status, attempts, next_attempt_at, last_response_code, last_error, created_at, updated_at, delivered_at" +
+		") VALUES(?, ?, ?, ?, 0, ?, 0, '', ?, ?, NULL)"
+	res, err := repo.conn(ctx).ExecContext(ctx, q,
+		rec.TenantID, rec.EventID, rec.SubscriptionID, rec.Status, rec.NextAttemptAt, rec.CreatedAt, rec.UpdatedAt)
+	if err != nil {
+		lLog.Errorf("error while inserting webhook delivery. got %s", err.Error())
+		return false, err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		lLog.Errorf("error while reading affected rows of webhook delivery insertion. got %s", err.Error())
+		return false, err
+	}
+	if affected != 1 {
+		return false, nil
+	}
+	rec.DeliveryID, err = res.LastInsertId()
+	if err != nil {
+		lLog.Errorf("error while reading the id of inserted webhook delivery. got %s", err.Error())
+		return false, err
+	}
+	return true, nil
+}
+
+const webhookDeliveryColumns = "delivery_id, tenant_id, event_id, subscription_id, status, attempts, next_attempt_at, last_response_code, last_error, created_at, updated_at, delivered_at"
+
+// scanWebhookDelivery scans a row selected using webhookDeliveryColumns into a WebhookDeliveryRecord.
+func scanWebhookDelivery(scanner interface{ Scan(...interface{}) error }) (*WebhookDeliveryRecord, error) {
+	dr := &WebhookDeliveryRecord{}
+	var deliveredAt sql.NullTime
+	err := scanner.Scan(&dr.DeliveryID, &dr.TenantID, &dr.EventID, &dr.SubscriptionID, &dr.Status, &dr.Attempts, &dr.NextAttemptAt,
+		&dr.LastResponseCode, &dr.LastError, &dr.CreatedAt, &dr.UpdatedAt, &deliveredAt)
+	if err != nil {
+		return nil, err
+	}
+	if deliveredAt.Valid {
+		dr.DeliveredAt = &deliveredAt.Time
+	}
+	return dr, nil
+}
+
+// GetWebhookDelivery retrieves a WebhookDeliveryRecord from database where the deliveryID is specified.
+// Throws error if  the underlying database connection has problem.
+// It returns an instance of WebhookDeliveryRecord or nil if record not found
+func (repo *MySQLDBRepository) GetWebhookDelivery(ctx context.Context, deliveryID int64) (*WebhookDeliveryRecord, error) {
+	lLog := mysqlLog.WithField("function", "GetWebhookDelivery")
+	q := "SELECT " + webhookDeliveryColumns + " FROM webhook_deliveries WHERE tenant_id=? AND delivery_id=?"
+	row := repo.conn(ctx).QueryRowxContext(ctx, q, TenantFromContext(ctx), deliveryID)
+	if row.Err() != nil {
+		lLog.Errorf("error while retrieving webhook delivery. got %s", row.Err().Error())
+		return nil, row.Err()
+	}
+	dr, err := scanWebhookDelivery(row)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		lLog.Errorf("error while scanning webhook delivery record. got %s", err.Error())
+		return nil, err
+	}
+	return dr, nil
+}
+
+// ListWebhookDeliveries will list deliveries of a subscription in paginated fashion, latest delivery first.
+// An empty status lists deliveries of any status.
+// Throws error if the underlying database connection has problem.
+func (repo *MySQLDBRepository) ListWebhookDeliveries(ctx context.Context, subscriptionID, status string, offset, length int) ([]*WebhookDeliveryRecord, error) {
+	lLog := mysqlLog.WithField("function", "ListWebhookDeliveries")
+	q := "SELECT " + webhookDeliveryColumns + " FROM webhook_deliveries WHERE tenant_id=? AND subscription_id=?"
+	args := []interface{}{TenantFromContext(ctx), subscriptionID}
+	if len(status) > 0 {
+		q += " AND status=?"
+		args = append(args, status)
+	}
+	q += " ORDER BY delivery_id DESC LIMIT ?,?"
+	args = append(args, offset, length)
+	rows, err := repo.conn(ctx).QueryxContext(ctx, q, args...)
+	if err != nil {
+		lLog.Errorf("error while listing webhook deliveries. got %s", err.Error())
+		return nil, err
+	}
+	defer rows.Close()
+	ret := make([]*WebhookDeliveryRecord, 0)
+	for rows.Next() {
+		dr, err := scanWebhookDelivery(rows)
+		if err != nil {
+			lLog.Errorf("error while scanning rows in ListWebhookDeliveries function. got %s", err.Error())
+		} else {
+			ret = append(ret, dr)
+		}
+	}
+	return ret, nil
+}
+
+// CountWebhookDeliveries returns the number of deliveries of a subscription in database.
+// An empty status counts deliveries of any status.
+// Throws error if the underlying database connection has problem.
+func (repo *MySQLDBRepository) CountWebhookDeliveries(ctx context.Context, subscriptionID, status string) (int, error) {
+	lLog := mysqlLog.WithField("function", "CountWebhookDeliveries")
+	q := "SELECT COUNT(*) FROM webhook_deliveries WHERE tenant_id=? AND subscription_id=?"
+	args := []interface{}{TenantFromContext(ctx), subscriptionID}
+	if len(status) > 0 {
+		q += " AND status=?"
+		args = append(args, status)
+	}
+	row := repo.conn(ctx).QueryRowxContext(ctx, q, args...)
+	if row.Err() != nil {
+		lLog.Errorf("error while counting webhook deliveries. got %s", row.Err().Error())
+		return 0, row.Err()
+	}
+	count := 0
+	err := row.Scan(&count)
+	if err != nil {
+		lLog.Errorf("error while scanning count of webhook deliveries. got %s", err.Error())
+		return 0, err
+	}
+	return count, nil
+}
+
+// ListDueWebhookDeliveries will list PENDING deliveries across the tenants whose next attempt is due at the specified time,
+// up to the specified length.
+// Throws error if the underlying database connection has problem.
+func (repo *MySQLDBRepository) ListDueWebhookDeliveries(ctx context.Context, now time.Time, length int) ([]*WebhookDeliveryRecord, error) {
+	lLog := mysqlLog.WithField("function", "ListDueWebhookDeliveries")
+	q := "SELECT " + webhookDeliveryColumns + " FROM webhook_deliveries WHERE status=? AND next_attempt_at<=? ORDER BY next_attempt_at ASC LIMIT ?"
+	rows, err := repo.conn(ctx).QueryxContext(ctx, q, WebhookDeliveryStatusPending, now, length)
+	if err != nil {
+		lLog.Errorf("error while listing due webhook deliveries. got %s", err.Error())
+		return nil, err
+	}
+	defer rows.Close()
+	ret := make([]*WebhookDeliveryRecord, 0)
+	for rows.Next() {
+		dr, err := scanWebhookDelivery(rows)
+		if err != nil {
+			lLog.Errorf("error while scanning rows in ListDueWebhookDeliveries function. got %s", err.Error())
+		} else {
+			ret = append(ret, dr)
+		}
+	}
+	return ret, nil
+}
+
+// ClaimWebhookDelivery count a new attempt of a PENDING delivery that have made the specified number of attempts,
+// and postpone its next attempt to nextAttemptAt, so no other worker attempt it at the same time.
+// It returns false if the delivery is no longer PENDING or is claimed by another worker.
+// Throws error if the underlying database connection has problem.
+func (repo *MySQLDBRepository) ClaimWebhookDelivery(ctx context.Context, deliveryID int64, attempts int, nextAttemptAt time.Time) (bool, error) {
+	lLog := mysqlLog.WithField("function", "ClaimWebhookDelivery")
+	q := "UPDATE webhook_deliveries SET attempts=attempts+1, next_attempt_at=?, updated_at=? WHERE tenant_id=? AND delivery_id=? AND status=? AND attempts=?"
+	res, err := repo.conn(ctx).ExecContext(ctx, q, nextAttemptAt, time.Now(), TenantFromContext(ctx), deliveryID, WebhookDeliveryStatusPending, attempts)
+	if err != nil {
+		lLog.Errorf("error while claiming webhook delivery. got %s", err.Error())
+		return false, err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		lLog.Errorf("error while reading affected rows of webhook delivery claim. got %s", err.Error())
+		return false, err
+	}
+	return affected == 1, nil
+}
+
+// UpdateWebhookDeliveryResult records the outcome of the last attempt of a PENDING delivery and change its status.
+// It returns false if the delivery is no longer PENDING.
+// Throws error if the underlying database connection has problem.
+func (repo *MySQLDBRepository) UpdateWebhookDeliveryResult(ctx context.Context, deliveryID int64, status string, responseCode int, lastError string) (bool, error) {
+	lLog := mysqlLog.WithField("function", "UpdateWebhookDeliveryResult")
+	if len(lastError) > 255 {
+		lastError = lastError[:255]
+	}
+	now := time.Now()
+	var deliveredAt *time.Time
+	if status == WebhookDeliveryStatusDelivered {
+		deliveredAt = &now
+	}
+	q := "UPDATE webhook_deliveries SET status=?, last_response_code=?, last_error=?, updated_at=?, delivered_at=? WHERE tenant_id=? AND delivery_id=? AND status=?"
+	res, err := repo.conn(ctx).ExecContext(ctx, q, status, responseCode, lastError, now, deliveredAt, TenantFromContext(ctx), deliveryID, WebhookDeliveryStatusPending)
+	if err != nil {
+		lLog.Errorf("error while updating webhook delivery result. got %s", err.Error())
+		return false, err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		lLog.Errorf("error while reading affected rows of webhook delivery update. got %s", err.Error())
+		return false, err
+	}
+	return affected == 1, nil
+}
+
+// ReplayWebhookDelivery put a delivery that is not PENDING back into PENDING, with its attempts reset,
+// so it is delivered again.
+// It returns false if there is no such delivery or it is still PENDING.
+// Throws error if the underlying database connection has problem.
+func (repo *MySQLDBRepository) ReplayWebhookDelivery(ctx context.Context, deliveryID int64) (bool, error) {
+	lLog := mysqlLog.WithField("function", "ReplayWebhookDelivery")
+	now := time.Now()
+	q := "UPDATE webhook_deliveries SET status=?, attempts=0, next_attempt_at=?, updated_at=? WHERE tenant_id=? AND delivery_id=? AND status<>?"
+	res, err := repo.conn(ctx).ExecContext(ctx, q, WebhookDeliveryStatusPending, now, now, TenantFromContext(ctx), deliveryID, WebhookDeliveryStatusPending)
+	if err != nil {
+		lLog.Errorf("error while replaying webhook delivery. got %s", err.Error())
+		return false, err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		lLog.Errorf("error while reading affected rows of webhook delivery replay. got %s", err.Error())
+		return false, err
+	}
+	return affected == 1, nil
+}
+
+// ReplayDeadWebhookDeliveries put all DEAD deliveries of a subscription back into PENDING, with their attempts reset.
+// It returns the number of deliveries replayed.
+// Throws error if the underlying database connection has problem.
+func (repo *MySQLDBRepository) ReplayDeadWebhookDeliveries(ctx context.Context, subscriptionID string) (int, error) {
+	lLog := mysqlLog.WithField("function", "ReplayDeadWebhookDeliveries")
+	now := time.Now()
+	q := "UPDATE webhook_deliveries SET status=?, attempts=0, next_attempt_at=?, updated_at=? WHERE tenant_id=? AND subscription_id=? AND status=?"
+	res, err := repo.conn(ctx).ExecContext(ctx, q, WebhookDeliveryStatusPending, now, now, TenantFromContext(ctx), subscriptionID, WebhookDeliveryStatusDead)
+	if err != nil {
+		lLog.Errorf("error while replaying dead webhook deliveries. got %s", err.Error())
+		return 0, err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		lLog.Errorf("error while reading affected rows of dead webhook deliveries replay. got %s", err.Error())
+		return 0, err
+	}
+	return int(affected), nil
+}
@@ -9,4 +9,19 @@ const (
 
 	// UserIDContextKey is the context key to obtain the current user id using the API
 	UserIDContextKey ContextKeys = "USER_IDENTIFICATION"
+
+	// DBTransactionContextKey is the context key to obtain the on going database transaction, if any.
+	DBTransactionContextKey ContextKeys = "DB_TRANSACTION"
+
+	// AuditContextKey is the context key to obtain the audit trail of the current write request, if audited.
+	AuditContextKey ContextKeys = "AUDIT_TRAIL"
+
+	// ClientContextKey is the context key to obtain the client authenticated by an API key, if any.
+	ClientContextKey ContextKeys = "API_CLIENT"
+
+	// TenantIDContextKey is the context key to obtain the tenant whose books the current request works on.
+	TenantIDContextKey ContextKeys = "TENANT_ID"
+
+	// IdempotencyClaimContextKey is the context key to obtain the idempotency key claimed by the current request, if any.
+	IdempotencyClaimContextKey ContextKeys = "IDEMPOTENCY_CLAIM"
 )
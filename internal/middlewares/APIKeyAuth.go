@@ -0,0 +1,82 @@
+package middlewares
+
+import (
+	"context"
+	"regexp"
+	"strings"
+
+	"github.com/hyperjumptech/bookkeeping/internal/connector"
+	"github.com/hyperjumptech/bookkeeping/internal/contextkeys"
+)
+
+// APIKeyScheme is the scheme of the Authorization header carrying an API key, as in "ApiKey <key id>.<secret>"
+const APIKeyScheme = "ApiKey"
+
+var (
+	// APIKeys verifies the API keys presented by the clients. API keys are refused if it is nil.
+	APIKeys APIKeyAuthenticator
+
+	// ClientIDPattern is the format of the client id of an API key, it must fit into the created_by columns
+	ClientIDPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,16}$`)
+)
+
+// Client is the caller of the api authenticated by an API key
+type Client struct {
+	ClientID string
+	KeyID    string
+	Scopes   []string
+	// TenantID is the tenant whose books the client works on, the default tenant if empty
+	TenantID string
+	// Session tells the client is authenticated by a session token, see IssueSessionToken
+	Session bool
+}
+
+// APIKeyAuthenticator verifies the API keys
+type APIKeyAuthenticator interface {
+	// AuthenticateAPIKey returns the client owning the key if the secret matches, and the key is neither revoked nor expired.
+	// It returns nil client if the key is not valid, error is only returned if the key can not be verified.
+	AuthenticateAPIKey(ctx context.Context, keyID, secret string) (*Client, error)
+
+	// APIKeySigningKey returns the client owning the key and the key its requests are signed with, see SigningKey,
+	// if the key is neither revoked nor expired. It returns nil client if the key is not valid,
+	// error is only returned if the key can not be verified.
+	APIKeySigningKey(ctx context.Context, keyID string) (*Client, string, error)
+
+	// APIKeyClient returns the client owning the key if the key is neither revoked nor expired. It returns nil client
+	// if the key is not valid, error is only returned if the key can not be verified.
+	APIKeyClient(ctx context.Context, keyID string) (*Client, error)
+}
+
+// ParseAPIKey splits the Authorization header value "ApiKey <key id>.<secret>" into the key id and the secret.
+func ParseAPIKey(header string) (keyID, secret string, ok bool) {
+	splt := strings.SplitN(strings.TrimSpace(header), " ", 2)
+	if len(splt) != 2 || !strings.EqualFold(splt[0], APIKeyScheme) {
+		return "", "", false
+	}
+	key := strings.SplitN(strings.TrimSpace(splt[1]), ".", 2)
+	if len(key) != 2 || len(key[0]) == 0 || len(key[1]) == 0 {
+		return "", "", false
+	}
+	return key[0], key[1], true
+}
+
+// FormatAPIKey joins the key id and the secret into the API key handed over to the client.
+func FormatAPIKey(keyID, secret string) string {
+	return keyID + "." + secret
+}
+
+// ClientFromContext returns the client authenticated by an API key, or nil if the request is not authenticated by an API key.
+func ClientFromContext(ctx context.Context) *Client {
+	client, _ := ctx.Value(contextkeys.ClientContextKey).(*Client)
+	return client
+}
+
+// withClient puts the authenticated client into the context, its client id becomes the user of the request
+// and its tenant the tenant of the books the request works on.
+func withClient(ctx context.Context, client *Client) context.Context {
+	ctx = context.WithValue(context.WithValue(ctx, contextkeys.ClientContextKey, client), contextkeys.UserIDContextKey, client.ClientID)
+	if len(client.TenantID) > 0 {
+		ctx = connector.WithTenant(ctx, client.TenantID)
+	}
+	return ctx
+}
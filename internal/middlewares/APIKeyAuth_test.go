@@ -0,0 +1,98 @@
+package middlewares
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hyperjumptech/bookkeeping/internal/contextkeys"
+)
+
+type stubAPIKeys map[string]string
+
+func (s stubAPIKeys) AuthenticateAPIKey(ctx context.Context, keyID, secret string) (*Client, error) {
+	if s[keyID] != secret {
+		return nil, nil
+	}
+	return &Client{ClientID: "client-" + keyID, KeyID: keyID, Scopes: []string{"ledger:read"}}, nil
+}
+
+func (s stubAPIKeys) APIKeySigningKey(ctx context.Context, keyID string) (*Client, string, error) {
+	secret, ok := s[keyID]
+	if !ok {
+		return nil, "", nil
+	}
+	return &Client{ClientID: "client-" + keyID, KeyID: keyID, Scopes: []string{"ledger:read"}}, SigningKey(secret), nil
+}
+
+func (s stubAPIKeys) APIKeyClient(ctx context.Context, keyID string) (*Client, error) {
+	if _, ok := s[keyID]; !ok {
+		return nil, nil
+	}
+	return &Client{ClientID: "client-" + keyID, KeyID: keyID, Scopes: []string{"ledger:read"}}, nil
+}
+
+func TestParseAPIKey(t *testing.T) {
+	testData := []struct {
+		header string
+		keyID  string
+		secret string
+		ok     bool
+	}{
+		{"ApiKey K1.s3cr.et", "K1", "s3cr.et", true},
+		{"apikey  K1.secret ", "K1", "secret", true},
+		{"ApiKey K1", "", "", false},
+		{"ApiKey .secret", "", "", false},
+		{"Bearer K1.secret", "", "", false},
+		{"SzEuc2VjcmV0", "", "", false},
+	}
+	for _, td := range testData {
+		keyID, secret, ok := ParseAPIKey(td.header)
+		if keyID != td.keyID || secret != td.secret || ok != td.ok {
+			t.Errorf("parsing %q, expecting %s %s %v but %s %s %v", td.header, td.keyID, td.secret, td.ok, keyID, secret, ok)
+		}
+	}
+}
+
+func TestHMACMiddlewareAPIKey(t *testing.T) {
+	APIKeys = stubAPIKeys{"K1": "secret"}
+	secret := SecretKey
+	SecretKey = ""
+	defer func() {
+		APIKeys = nil
+		SecretKey = secret
+	}()
+
+	var user string
+	var client *Client
+	handler := SetupContextMiddleware(HMACMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, _ = r.Context().Value(contextkeys.UserIDContextKey).(string)
+		client = ClientFromContext(r.Context())
+	})))
+
+	testData := []struct {
+		authorization string
+		status        int
+	}{
+		{"ApiKey " + FormatAPIKey("K1", "secret"), http.StatusOK},
+		{"ApiKey " + FormatAPIKey("K1", "guess"), http.StatusUnauthorized},
+		{"ApiKey " + FormatAPIKey("K2", "secret"), http.StatusUnauthorized},
+		// the shared secret is disabled when it is not configured
+		{GenHMAC(), http.StatusUnauthorized},
+		{"", http.StatusUnauthorized},
+	}
+	for _, td := range testData {
+		user, client = "", nil
+		req := httptest.NewRequest("GET", "/api/v1/accounts", nil)
+		req.Header.Set("Authorization", td.authorization)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != td.status {
+			t.Errorf("authorization %q expecting status %d but %d", td.authorization, td.status, rec.Code)
+		}
+		if td.status == http.StatusOK && (user != "client-K1" || client == nil || client.KeyID != "K1") {
+			t.Errorf("expecting the client of the key in the context but user %s", user)
+		}
+	}
+}
@@ -0,0 +1,201 @@
+package middlewares
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/hyperjumptech/bookkeeping/internal/connector"
+	"github.com/hyperjumptech/bookkeeping/internal/contextkeys"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// AnonymousPrincipal is the principal recorded for requests made without authentication
+	AnonymousPrincipal = "anonymous"
+
+	// maxAuditedResponse is the maximum number of response bytes kept to build the after snapshot
+	maxAuditedResponse = 1 << 20
+)
+
+var (
+	// AuditLog is the sink every audited write request is recorded into. No request is audited if it is nil.
+	AuditLog AuditSink
+
+	auditLog = log.WithField("module", "AuditMiddleware")
+)
+
+// AuditRecord is the audit entry of a single write request
+type AuditRecord struct {
+	Principal     string
+	RequestID     string
+	Method        string
+	Endpoint      string
+	Path          string
+	PayloadDigest string
+	Before        string
+	After         string
+	StatusCode    int
+	Outcome       string
+	CreatedAt     time.Time
+}
+
+// AuditSink stores the audit entries
+type AuditSink interface {
+	// RecordAudit stores the audit entry
+	RecordAudit(ctx context.Context, rec *AuditRecord) error
+}
+
+// auditTrail is carried in the request context so the handlers are able to add the snapshots of the resource they change.
+type auditTrail struct {
+	before   string
+	after    string
+	hasAfter bool
+}
+
+// AuditSnapshot records the state of the resource changed by the current request before and after the change.
+// The snapshots are marshalled into JSON right away, so the resource may be changed after the call.
+// A nil after leaves the after snapshot to be taken from the data of the response.
+// It does nothing if the request is not audited.
+func AuditSnapshot(ctx context.Context, before, after interface{}) {
+	trail, ok := ctx.Value(contextkeys.AuditContextKey).(*auditTrail)
+	if !ok {
+		return
+	}
+	if before != nil {
+		trail.before = snapshotString(before)
+	}
+	if after != nil {
+		trail.after = snapshotString(after)
+		trail.hasAfter = true
+	}
+}
+
+// auditResponseWriter captures the status and the body of the response
+type auditResponseWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+// WriteHeader captures the status before writing it
+func (aw *auditResponseWriter) WriteHeader(status int) {
+	if aw.status == 0 {
+		aw.status = status
+	}
+	aw.ResponseWriter.WriteHeader(status)
+}
+
+// Write captures the body before writing it
+func (aw *auditResponseWriter) Write(b []byte) (int, error) {
+	if aw.status == 0 {
+		aw.status = http.StatusOK
+	}
+	if aw.body.Len() < maxAuditedResponse {
+		aw.body.Write(b)
+	}
+	return aw.ResponseWriter.Write(b)
+}
+
+// isAuditedRequest tells whether the request is a write toward the api
+func isAuditedRequest(r *http.Request) bool {
+	if !strings.HasPrefix(r.URL.Path, "/api/") {
+		return false
+	}
+	switch r.Method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// snapshotString marshals the snapshot into JSON
+func snapshotString(v interface{}) string {
+	if v == nil {
+		return ""
+	}
+	if raw, ok := v.(json.RawMessage); ok {
+		return string(raw)
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return ""
+	}
+	return string(b)
+}
+
+// AuditMiddleware records every write request into the AuditLog, along with the authenticated principal,
+// the request id, the digest of the payload, the snapshots of the changed resource and the outcome.
+// It must be placed after the authentication middleware so the principal is known.
+func AuditMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if AuditLog == nil || !isAuditedRequest(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		ctx := r.Context()
+		requestID, _ := ctx.Value(contextkeys.XRequestID).(string)
+		principal, ok := ctx.Value(contextkeys.UserIDContextKey).(string)
+		if !ok || len(principal) == 0 {
+			principal = AnonymousPrincipal
+		}
+
+		payload, err := io.ReadAll(r.Body)
+		if err != nil {
+			auditLog.WithField("RequestID", requestID).Errorf("error while reading body. got %s", err.Error())
+		}
+		r.Body = io.NopCloser(bytes.NewReader(payload))
+		digest := sha256.Sum256(payload)
+
+		endpoint := r.URL.Path
+		if route := mux.CurrentRoute(r); route != nil {
+			if tpl, err := route.GetPathTemplate(); err == nil {
+				endpoint = tpl
+			}
+		}
+
+		trail := &auditTrail{}
+		aw := &auditResponseWriter{ResponseWriter: w}
+		next.ServeHTTP(aw, r.WithContext(context.WithValue(ctx, contextkeys.AuditContextKey, trail)))
+		if aw.status == 0 {
+			aw.status = http.StatusOK
+		}
+
+		rec := &AuditRecord{
+			Principal:     principal,
+			RequestID:     requestID,
+			Method:        r.Method,
+			Endpoint:      endpoint,
+			Path:          r.URL.Path,
+			PayloadDigest: hex.EncodeToString(digest[:]),
+			Before:        trail.before,
+			StatusCode:    aw.status,
+			Outcome:       connector.AuditOutcomeFailure,
+			CreatedAt:     time.Now(),
+		}
+		if aw.status >= 200 && aw.status < 300 {
+			rec.Outcome = connector.AuditOutcomeSuccess
+			if trail.hasAfter {
+				rec.After = trail.after
+			} else {
+				resp := struct {
+					Data json.RawMessage `json:"data"`
+				}{}
+				if json.Unmarshal(aw.body.Bytes(), &resp) == nil && len(resp.Data) > 0 && string(resp.Data) != "null" {
+					rec.After = string(resp.Data)
+				}
+			}
+		}
+		// the request is already served, a failure to record its audit must not change the response.
+		if err := AuditLog.RecordAudit(context.WithValue(ctx, contextkeys.UserIDContextKey, principal), rec); err != nil {
+			auditLog.WithField("RequestID", requestID).Errorf("error while recording audit of %s %s. got %s", r.Method, r.URL.Path, err.Error())
+		}
+	})
+}
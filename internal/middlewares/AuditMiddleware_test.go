@@ -0,0 +1,102 @@
+package middlewares
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/hyperjumptech/bookkeeping/internal/connector"
+	"github.com/hyperjumptech/bookkeeping/internal/contextkeys"
+)
+
+type stubAuditSink struct {
+	records []*AuditRecord
+}
+
+func (s *stubAuditSink) RecordAudit(ctx context.Context, rec *AuditRecord) error {
+	s.records = append(s.records, rec)
+	return nil
+}
+
+func TestAuditMiddleware(t *testing.T) {
+	sink := &stubAuditSink{}
+	AuditLog = sink
+	secret := SecretKey
+	SecretKey = "audit-test-secret"
+	defer func() {
+		AuditLog = nil
+		SecretKey = secret
+	}()
+
+	r := mux.NewRouter()
+	r.Use(SetupContextMiddleware, HMACMiddleware, AuditMiddleware)
+	r.HandleFunc("/api/v1/things/{ID}", func(w http.ResponseWriter, r *http.Request) {
+		AuditSnapshot(r.Context(), map[string]string{"name": "old"}, nil)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(`{"message":"OK","status":"SUCCESS","data":{"name":"new"}}`))
+	}).Methods("PUT", "GET")
+	r.HandleFunc("/api/v1/broken", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"message":"bad","status":"FAIL","data":"bad"}`))
+	}).Methods("POST")
+
+	body := `{"name":"new"}`
+	req := httptest.NewRequest("PUT", "/api/v1/things/ABC", strings.NewReader(body))
+	if err := SignRequest(req, "", SecretKey, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("X-Request-ID", "audit-req-1")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	req = httptest.NewRequest("GET", "/api/v1/things/ABC", nil)
+	_ = SignRequest(req, "", SecretKey, time.Now())
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	req = httptest.NewRequest("POST", "/api/v1/broken", strings.NewReader(`{}`))
+	_ = SignRequest(req, "", SecretKey, time.Now())
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	if len(sink.records) != 2 {
+		t.Fatalf("expecting 2 audited writes but %d", len(sink.records))
+	}
+	rec := sink.records[0]
+	digest := sha256.Sum256([]byte(body))
+	if rec.Principal != HMACPrincipal {
+		t.Errorf("expecting principal %s but %s", HMACPrincipal, rec.Principal)
+	}
+	if rec.RequestID != "audit-req-1" {
+		t.Errorf("expecting request id audit-req-1 but %s", rec.RequestID)
+	}
+	if rec.Endpoint != "/api/v1/things/{ID}" || rec.Path != "/api/v1/things/ABC" || rec.Method != "PUT" {
+		t.Errorf("unexpected endpoint %s %s %s", rec.Method, rec.Endpoint, rec.Path)
+	}
+	if rec.PayloadDigest != hex.EncodeToString(digest[:]) {
+		t.Errorf("unexpected payload digest %s", rec.PayloadDigest)
+	}
+	if rec.Before != `{"name":"old"}` || rec.After != `{"name":"new"}` {
+		t.Errorf("unexpected snapshots before %s after %s", rec.Before, rec.After)
+	}
+	if rec.StatusCode != 200 || rec.Outcome != connector.AuditOutcomeSuccess {
+		t.Errorf("unexpected outcome %d %s", rec.StatusCode, rec.Outcome)
+	}
+
+	rec = sink.records[1]
+	if rec.StatusCode != 400 || rec.Outcome != connector.AuditOutcomeFailure {
+		t.Errorf("unexpected outcome %d %s", rec.StatusCode, rec.Outcome)
+	}
+	if len(rec.After) != 0 {
+		t.Errorf("expecting no after snapshot of a failed write but %s", rec.After)
+	}
+}
+
+func TestAuditSnapshotNotAudited(t *testing.T) {
+	// must not panic when the request is not audited
+	AuditSnapshot(context.WithValue(context.Background(), contextkeys.XRequestID, "x"), "before", "after")
+}
@@ -0,0 +1,143 @@
+package middlewares
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/hyperjumptech/bookkeeping/internal/config"
+	"github.com/hyperjumptech/bookkeeping/internal/contextkeys"
+	log "github.com/sirupsen/logrus"
+)
+
+// Authenticator authenticates the requests carrying its kind of credential in the Authorization header.
+type Authenticator interface {
+	// Applies tells whether the Authorization header carries the kind of credential of the authenticator
+	Applies(header string) bool
+
+	// Authenticate verifies the credential of the request. It returns the context of the authenticated request,
+	// carrying its principal, and http.StatusOK, or the status the request is refused with.
+	Authenticate(r *http.Request, header string) (context.Context, int)
+}
+
+// AuthenticatorFactory creates an authenticator, usually from the configuration
+type AuthenticatorFactory func() (Authenticator, error)
+
+var (
+	// Authenticators is the chain of authenticators, a request is authenticated by the first one the Authorization header applies to.
+	Authenticators = []Authenticator{&apiKeyAuth{}, &hmacAuth{}, &sessionAuth{}}
+
+	authenticatorFactories = map[string]AuthenticatorFactory{
+		"apikey":  func() (Authenticator, error) { return &apiKeyAuth{}, nil },
+		"hmac":    func() (Authenticator, error) { return &hmacAuth{}, nil },
+		"session": func() (Authenticator, error) { return &sessionAuth{}, nil },
+		"jwt":     func() (Authenticator, error) { return NewJWTAuthFromConfig() },
+	}
+)
+
+// RegisterAuthenticator makes an authenticator available to NewAuthenticatorChain under the name
+func RegisterAuthenticator(name string, factory AuthenticatorFactory) {
+	authenticatorFactories[name] = factory
+}
+
+// NewAuthenticatorChain creates the chain of the authenticators of the names, in order,
+// among "apikey", "hmac", "session", "jwt" and the ones registered with RegisterAuthenticator.
+func NewAuthenticatorChain(names []string) ([]Authenticator, error) {
+	chain := make([]Authenticator, 0, len(names))
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if len(name) == 0 {
+			continue
+		}
+		factory, ok := authenticatorFactories[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown authenticator %s", name)
+		}
+		authenticator, err := factory()
+		if err != nil {
+			return nil, fmt.Errorf("error while creating authenticator %s. got %w", name, err)
+		}
+		chain = append(chain, authenticator)
+	}
+	return chain, nil
+}
+
+// AuthMiddleware will handle the authentication for each request of all restricted endpoint,
+// the request is authenticated by the first of the Authenticators its Authorization header applies to.
+func AuthMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if (len(r.URL.Path) >= 5 && r.URL.Path[:5] == "/docs") || (len(r.URL.Path) >= 10 && r.URL.Path[:10] == "/dashboard") || r.URL.Path == "/health" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		header := strings.TrimSpace(r.Header.Get("Authorization"))
+		if len(header) > 0 {
+			for _, authenticator := range Authenticators {
+				if !authenticator.Applies(header) {
+					continue
+				}
+				ctx, status := authenticator.Authenticate(r, header)
+				if status != http.StatusOK {
+					w.WriteHeader(status)
+					_, _ = w.Write([]byte(http.StatusText(status)))
+					return
+				}
+				next.ServeHTTP(w, r.WithContext(ctx))
+				return
+			}
+		}
+		w.WriteHeader(http.StatusUnauthorized)
+		_, _ = w.Write([]byte("you are not authorized"))
+	})
+}
+
+// apiKeyAuth authenticates the requests carrying the API key of a client, as in "ApiKey <key id>.<secret>"
+type apiKeyAuth struct{}
+
+// Applies tells whether the header carries an API key
+func (a *apiKeyAuth) Applies(header string) bool {
+	_, _, ok := ParseAPIKey(header)
+	return ok
+}
+
+// Authenticate verifies the API key with APIKeys, the client of the key becomes the principal.
+func (a *apiKeyAuth) Authenticate(r *http.Request, header string) (context.Context, int) {
+	keyID, secret, _ := ParseAPIKey(header)
+	if APIKeys == nil {
+		return r.Context(), http.StatusUnauthorized
+	}
+	client, err := APIKeys.AuthenticateAPIKey(r.Context(), keyID, secret)
+	if err != nil {
+		log.WithField("RequestID", r.Context().Value(contextkeys.XRequestID)).Errorf("error while authenticating api key %s. got %s", keyID, err.Error())
+		return r.Context(), http.StatusInternalServerError
+	}
+	if client == nil {
+		return r.Context(), http.StatusUnauthorized
+	}
+	return withClient(r.Context(), client), http.StatusOK
+}
+
+// hmacAuth authenticates the requests signed with the API key of a client or with the shared secret,
+// and the legacy timestamp only HMAC if LegacyHMACEnabled, except in production.
+type hmacAuth struct{}
+
+// Applies tells whether the header carries a request signature or, having no scheme, a legacy HMAC
+func (h *hmacAuth) Applies(header string) bool {
+	if _, ok := ParseRequestSignature(header); ok {
+		return true
+	}
+	return !strings.Contains(header, " ")
+}
+
+// Authenticate verifies the request signature or the legacy HMAC.
+func (h *hmacAuth) Authenticate(r *http.Request, header string) (context.Context, int) {
+	if rs, ok := ParseRequestSignature(header); ok {
+		return authenticateSignedRequest(r, rs)
+	}
+	// the legacy HMAC is a development convenience, the shared secret is disabled unless it is configured
+	if !LegacyHMACEnabled || config.IsProduction() || len(SecretKey) == 0 || !ValidateHMAC(header) {
+		return r.Context(), http.StatusUnauthorized
+	}
+	return context.WithValue(r.Context(), contextkeys.UserIDContextKey, HMACPrincipal), http.StatusOK
+}
@@ -0,0 +1,122 @@
+package middlewares
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/hyperjumptech/bookkeeping/internal/contextkeys"
+	"github.com/hyperjumptech/bookkeeping/internal/helpers"
+	log "github.com/sirupsen/logrus"
+)
+
+// Scopes granted to the API keys, every api endpoint requires one of them.
+const (
+	// ScopeLedgerRead reads accounts, journals, transactions, currencies and exchange rates
+	ScopeLedgerRead = "ledger:read"
+	// ScopeJournalWrite posts journals, holds, recurring journals and posting templates
+	ScopeJournalWrite = "journal:write"
+	// ScopeJournalApprove approves or rejects pending journals
+	ScopeJournalApprove = "journal:approve"
+	// ScopeAccountAdmin creates accounts and changes their details, state and limits
+	ScopeAccountAdmin = "account:admin"
+	// ScopeFXAdmin changes the currencies, their exchange rates and the common denominator
+	ScopeFXAdmin = "fx:admin"
+	// ScopeSystemAdmin manages the api keys, approval rules and webhooks, and reads the audit log
+	ScopeSystemAdmin = "system:admin"
+	// ScopeTenantAdmin provisions the tenants and issues api keys for any of them
+	ScopeTenantAdmin = "tenant:admin"
+)
+
+// RolePrefix marks a scope of an API key that grants all the scopes of a role, as in "role:bookkeeper"
+const RolePrefix = "role:"
+
+// ErrorCodeInsufficientScope is the error code of the response to a request refused for lack of scope
+const ErrorCodeInsufficientScope = 4
+
+var (
+	// TenantScopes lists the scopes confined to the books of the tenant of the caller
+	TenantScopes = []string{ScopeLedgerRead, ScopeJournalWrite, ScopeJournalApprove, ScopeAccountAdmin, ScopeFXAdmin, ScopeSystemAdmin}
+
+	// AllScopes lists every scope
+	AllScopes = append(append([]string{}, TenantScopes...), ScopeTenantAdmin)
+
+	// Roles maps the name of a role to the scopes it grants
+	Roles = map[string][]string{
+		"reader":     {ScopeLedgerRead},
+		"bookkeeper": {ScopeLedgerRead, ScopeJournalWrite},
+		"approver":   {ScopeLedgerRead, ScopeJournalApprove},
+		"accountant": {ScopeLedgerRead, ScopeJournalWrite, ScopeAccountAdmin},
+		"treasurer":  {ScopeLedgerRead, ScopeFXAdmin},
+		"admin":      TenantScopes,
+	}
+)
+
+// KnownScope tells whether the scope is one of AllScopes or names one of the Roles
+func KnownScope(scope string) bool {
+	if strings.HasPrefix(scope, RolePrefix) {
+		_, ok := Roles[scope[len(RolePrefix):]]
+		return ok
+	}
+	for _, s := range AllScopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseScopes splits the comma separated scopes, ignoring the blank ones
+func ParseScopes(scopes string) []string {
+	ret := make([]string, 0)
+	for _, scope := range strings.Split(scopes, ",") {
+		if scope = strings.TrimSpace(scope); len(scope) > 0 {
+			ret = append(ret, scope)
+		}
+	}
+	return ret
+}
+
+// grantedScopes returns the scopes granted to the caller of the request, those of its API key or
+// HMACScopes for the requests authenticated with the shared secret.
+func grantedScopes(ctx context.Context) []string {
+	if client := ClientFromContext(ctx); client != nil {
+		return client.Scopes
+	}
+	if user, _ := ctx.Value(contextkeys.UserIDContextKey).(string); user == HMACPrincipal {
+		return HMACScopes
+	}
+	return nil
+}
+
+// HasScope tells whether the caller of the request is granted the scope, either directly or by one of its roles.
+// The requests authenticated with the shared secret are granted the HMACScopes.
+func HasScope(ctx context.Context, scope string) bool {
+	for _, granted := range grantedScopes(ctx) {
+		if granted == scope {
+			return true
+		}
+		if strings.HasPrefix(granted, RolePrefix) {
+			for _, s := range Roles[granted[len(RolePrefix):]] {
+				if s == scope {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// RequireScope wraps the handler of an endpoint so it is only served to the callers granted the scope,
+// others are refused with 403 and ErrorCodeInsufficientScope.
+func RequireScope(scope string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !HasScope(r.Context(), scope) {
+			log.WithField("RequestID", r.Context().Value(contextkeys.XRequestID)).WithField("function", "RequireScope").
+				Warnf("%v is refused %s %s, missing scope %s", r.Context().Value(contextkeys.UserIDContextKey), r.Method, r.URL.Path, scope)
+			helpers.HTTPResponseBuilder(r.Context(), w, r, http.StatusForbidden, "insufficient scope", "missing scope "+scope, ErrorCodeInsufficientScope)
+			return
+		}
+		next(w, r)
+	}
+}
@@ -0,0 +1,59 @@
+package middlewares
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hyperjumptech/bookkeeping/internal/contextkeys"
+)
+
+func TestHasScope(t *testing.T) {
+	bookkeeper := withClient(context.Background(), &Client{ClientID: "c1", Scopes: []string{RolePrefix + "bookkeeper", ScopeFXAdmin}})
+	testData := []struct {
+		ctx   context.Context
+		scope string
+		has   bool
+	}{
+		{bookkeeper, ScopeLedgerRead, true},
+		{bookkeeper, ScopeJournalWrite, true},
+		{bookkeeper, ScopeFXAdmin, true},
+		{bookkeeper, ScopeAccountAdmin, false},
+		{bookkeeper, ScopeSystemAdmin, false},
+		{withClient(context.Background(), &Client{ClientID: "c3", Scopes: []string{RolePrefix + "admin"}}), ScopeSystemAdmin, true},
+		{withClient(context.Background(), &Client{ClientID: "c3", Scopes: []string{RolePrefix + "admin"}}), ScopeTenantAdmin, false},
+		{withClient(context.Background(), &Client{ClientID: "c2", Scopes: []string{RolePrefix + "unknown"}}), ScopeLedgerRead, false},
+		{withClient(context.Background(), &Client{ClientID: HMACPrincipal}), ScopeLedgerRead, false},
+		{context.WithValue(context.Background(), contextkeys.UserIDContextKey, HMACPrincipal), ScopeSystemAdmin, true},
+		{context.WithValue(context.Background(), contextkeys.UserIDContextKey, HMACPrincipal), ScopeJournalWrite, false},
+		{context.WithValue(context.Background(), contextkeys.UserIDContextKey, HMACPrincipal), ScopeTenantAdmin, false},
+		{context.WithValue(context.Background(), contextkeys.UserIDContextKey, "someone"), ScopeLedgerRead, false},
+		{context.Background(), ScopeLedgerRead, false},
+	}
+	for i, td := range testData {
+		if HasScope(td.ctx, td.scope) != td.has {
+			t.Errorf("#%d expecting HasScope %s to be %v", i, td.scope, td.has)
+		}
+	}
+
+	// the shared secret is only granted the configured scopes
+	saved := HMACScopes
+	defer func() { HMACScopes = saved }()
+	HMACScopes = ParseScopes(" role:bookkeeper, tenant:admin,")
+	hmacCtx := context.WithValue(context.Background(), contextkeys.UserIDContextKey, HMACPrincipal)
+	if !HasScope(hmacCtx, ScopeJournalWrite) || !HasScope(hmacCtx, ScopeTenantAdmin) || HasScope(hmacCtx, ScopeSystemAdmin) {
+		t.Errorf("expecting the shared secret granted %v only", HMACScopes)
+	}
+}
+
+func TestKnownScope(t *testing.T) {
+	for _, scope := range append([]string{RolePrefix + "admin", RolePrefix + "reader"}, AllScopes...) {
+		if !KnownScope(scope) {
+			t.Errorf("expecting %s to be known", scope)
+		}
+	}
+	for _, scope := range []string{"", "ledger:write", RolePrefix + "root", "role:"} {
+		if KnownScope(scope) {
+			t.Errorf("expecting %s to be unknown", scope)
+		}
+	}
+}
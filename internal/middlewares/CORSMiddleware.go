@@ -1,8 +1,10 @@
 package middlewares
 
 import (
-	"github.com/rs/cors"
 	"net/http"
+
+	"github.com/hyperjumptech/bookkeeping/internal/config"
+	"github.com/rs/cors"
 )
 
 var (
@@ -21,7 +23,7 @@ func init() {
 		MaxAge:                 60 * 60 * 24 * 365,
 		AllowCredentials:       true,
 		OptionsPassthrough:     false,
-		Debug:                  true,
+		Debug:                  !config.IsProduction(),
 	})
 }
 
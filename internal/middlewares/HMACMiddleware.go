@@ -1,16 +1,18 @@
 package middlewares
 
 import (
+	"context"
 	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/base64"
 	"fmt"
-	"math/rand"
 	"net/http"
 	"strings"
 	"time"
 
 	"github.com/hyperjumptech/bookkeeping/internal/config"
+	"github.com/hyperjumptech/bookkeeping/internal/contextkeys"
+	log "github.com/sirupsen/logrus"
 )
 
 var (
@@ -18,35 +20,82 @@ var (
 	HMACAgeMinutes int
 	// SecretKey holds the hmac secret
 	SecretKey string
+	// LegacyHMACEnabled tells whether the HMAC of the timestamp only, as generated by GenHMAC, is accepted.
+	// Such HMAC can be replayed against any endpoint until it expires, requests should be signed with SignRequest instead.
+	LegacyHMACEnabled bool
+	// Nonces remembers the nonces of the signed requests so they can not be replayed
+	Nonces = NewNonceCache()
+	// HMACScopes lists the scopes or roles granted to the requests authenticated with the shared secret, as configured by hmac.scopes
+	HMACScopes []string
 )
 
+// HMACPrincipal is the principal of the requests authenticated with the shared hmac secret
+const HMACPrincipal = "hmac"
+
 func init() {
 	HMACAgeMinutes = config.GetInt("hmac.age.minute")
 	SecretKey = config.Get("hmac.secret")
+	LegacyHMACEnabled = config.GetBoolean("hmac.legacy.enabled")
+	HMACScopes = ParseScopes(config.Get("hmac.scopes"))
 }
 
-// HMACMiddleware will handle the HMAC verification for each request of all
-// restricted endpoint.
+// HMACMiddleware is the former name of AuthMiddleware, the HMAC is now one of the Authenticators.
 func HMACMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if (len(r.URL.Path) >= 5 && r.URL.Path[:5] == "/docs") || (len(r.URL.Path) >= 10 && r.URL.Path[:10] == "/dashboard") || r.URL.Path == "/health" || r.URL.Path == "/devkey" {
-			next.ServeHTTP(w, r)
-			return
+	return AuthMiddleware(next)
+}
+
+// authenticateSignedRequest verifies the signature of the request, it makes sure the signature is fresh, covers the
+// method, path, query and body of the request, and its nonce is not used before.
+// It returns the context of the authenticated request and http.StatusOK, or the status of the refusal.
+func authenticateSignedRequest(r *http.Request, rs *RequestSignature) (context.Context, int) {
+	ctx := r.Context()
+	lLog := log.WithField("RequestID", ctx.Value(contextkeys.XRequestID)).WithField("function", "authenticateSignedRequest")
+	signedAt, err := time.Parse(time.RFC3339, rs.Timestamp)
+	if err != nil {
+		return ctx, http.StatusUnauthorized
+	}
+	now := time.Now()
+	age := time.Duration(HMACAgeMinutes) * time.Minute
+	if signedAt.Before(now.Add(-age)) || signedAt.After(now.Add(age)) {
+		return ctx, http.StatusUnauthorized
+	}
+
+	var client *Client
+	signingKey := SecretKey
+	if len(rs.Credential) > 0 {
+		if APIKeys == nil {
+			return ctx, http.StatusUnauthorized
 		}
-		header := r.Header.Get("Authorization")
-		if len(header) == 0 {
-			w.WriteHeader(http.StatusUnauthorized)
-			_, _ = w.Write([]byte("you are not authorized"))
-			return
+		client, signingKey, err = APIKeys.APIKeySigningKey(ctx, rs.Credential)
+		if err != nil {
+			lLog.Errorf("error while retrieving the signing key of api key %s. got %s", rs.Credential, err.Error())
+			return ctx, http.StatusInternalServerError
 		}
-		hmacstr := strings.TrimSpace(header)
-		if !ValidateHMAC(hmacstr) {
-			w.WriteHeader(http.StatusUnauthorized)
-			_, _ = w.Write([]byte("you are not authorized"))
-			return
+		if client == nil {
+			return ctx, http.StatusUnauthorized
 		}
-		next.ServeHTTP(w, r)
-	})
+	} else if len(SecretKey) == 0 {
+		return ctx, http.StatusUnauthorized
+	}
+
+	body, err := readBody(r)
+	if err != nil {
+		lLog.Errorf("error while reading body. got %s", err.Error())
+		return ctx, http.StatusBadRequest
+	}
+	expected := ComputeRequestSignature(CanonicalRequest(r.Method, r.URL.Path, r.URL.Query(), BodyDigest(body), rs.Timestamp, rs.Nonce), signingKey)
+	if !hmac.Equal([]byte(expected), []byte(strings.ToLower(rs.Signature))) {
+		return ctx, http.StatusUnauthorized
+	}
+	// the nonce is remembered until the signature expires, after that the timestamp refuses the request.
+	if !Nonces.Remember(rs.Credential+"/"+rs.Nonce, signedAt.Add(age), now) {
+		lLog.Warnf("request signed by %q at %s with nonce %s is replayed", rs.Credential, rs.Timestamp, rs.Nonce)
+		return ctx, http.StatusUnauthorized
+	}
+	if client != nil {
+		return withClient(ctx, client), http.StatusOK
+	}
+	return context.WithValue(ctx, contextkeys.UserIDContextKey, HMACPrincipal), http.StatusOK
 }
 
 // ComputeHmac will calculate and create new HMAC-SHA256 string hash based on the
@@ -68,6 +117,39 @@ func GenHMAC() string {
 	return base64hmac
 }
 
+// SignPayload will generate an HMAC string for the payload, signed at the
+// specified time. The hash is computed over "time$payload" using ComputeHmac and
+// the result is encoded the same way GenHMAC encodes its token.
+func SignPayload(payload []byte, secret string, at time.Time) string {
+	timeStr := at.Format(time.RFC3339)
+	hash := ComputeHmac(fmt.Sprintf("%s$%s", timeStr, payload), secret)
+	toBase := fmt.Sprintf("%s$%s", timeStr, hash)
+	return base64.StdEncoding.EncodeToString([]byte(toBase))
+}
+
+// ValidatePayloadSignature will validate a signature produced by SignPayload,
+// it makes sure the signature is not older than maxAge and it equals to the hash
+// of the payload.
+func ValidatePayloadSignature(signature string, payload []byte, secret string, maxAge time.Duration) bool {
+	decode, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	splt := strings.SplitN(string(decode), "$", 2)
+	if len(splt) < 2 {
+		return false
+	}
+	timeToCheck, err := time.Parse(time.RFC3339, splt[0])
+	if err != nil {
+		return false
+	}
+	if time.Now().Add(-1 * maxAge).After(timeToCheck) {
+		return false
+	}
+	expected := ComputeHmac(fmt.Sprintf("%s$%s", splt[0], payload), secret)
+	return hmac.Equal([]byte(splt[1]), []byte(expected))
+}
+
 // ValidateHMAC will validate if a specific HMAC string is valid,
 // it will open the time payload and make sure the payload is not expired
 // and it equals to the hash
@@ -93,69 +175,3 @@ func ValidateHMAC(hmac string) bool {
 	signature := ComputeHmac(timeStr, SecretKey)
 	return signature64 == signature
 }
-
-// DevKey can be invoked from curl -X PUT -H "HocusPocus: AvadaCadavra" http://localhost:50051/devkey
-func DevKey(w http.ResponseWriter, r *http.Request) {
-	hocuspocus := r.Header.Get("HocusPocus")
-	w.Header().Add("Content-Type", "text/plain")
-	w.WriteHeader(http.StatusNotFound)
-	gHMAC := GenHMAC()
-	if hocuspocus == "AvadaCadavra" {
-		w.Write([]byte("Looking for magical incantation.... Found\n"))
-		first := rand.Intn(4)
-		for i := 0; i < first; i++ {
-			w.Write([]byte(fmt.Sprintf("%s\n", MakeResidue(gHMAC))))
-		}
-		w.Write([]byte(fmt.Sprintf("%s\n", gHMAC)))
-		for i := 0; i < 4-first; i++ {
-			w.Write([]byte(fmt.Sprintf("%s\n", MakeResidue(gHMAC))))
-		}
-	} else if len(hocuspocus) > 0 {
-		w.Write([]byte("Looking for magical incantation.... Not Found : nothing happen"))
-	} else {
-		w.Write([]byte("not found"))
-	}
-}
-
-const (
-	// RWords is a residual string used to mimic the actual HMAC
-	RWords = "ButImustexplaintoyouhowallthismistakenideaofdenouncingpleasureandpraisingpainwasbornandIwillgiveyouacompleteaccountofthesystemandexpoundtheactualteachingsofthegreatexplorerofthetruththemasterbuilderofhumanhappinessNoonerejectsdislikesoravoidspleasureitselfbecauseitispleasurebutbecausethosewhodonotknowhowtopursuepleasurerationallyencounterconsequencesthatareextremelypainfulNoragainisthereanyonewholovesorpursuesordesirestoobtainpainofitselfbecauseitispainbutbecauseoccasionallycircumstancesoccurinwhichtoilandpaincanprocurehimsomegreatpleasureTotakeatrivialexamplewhichofuseverundertakeslaboriousphysicalexerciseexcepttoobtainsomeadvantagefromitButwhohasanyrighttofindfaultwithamanwhochoosestoenjoyapleasurethathasnoannoyingconsequencesoronewhoavoidsapainthatproducesnoresultantpleasure"
-)
-
-// MakeResidue create a dummy HMAC
-func MakeResidue(hmac string) string {
-	l := len(hmac) - 2
-	off := len(RWords) - (len(hmac) - 2)
-	off = rand.Intn(off)
-	torandUp := []byte(strings.ToUpper(RWords)[off : off+l])
-	torandDw := []byte(strings.ToLower(RWords)[off : off+l])
-	res := make([]byte, len(torandUp))
-	for i, c := range torandUp {
-		var nc byte
-		switch rand.Intn(3) {
-		case 0: // upper
-			nc = c
-		case 1: // lower
-			nc = torandDw[i]
-		default: // numbers
-			switch c {
-			case 'A':
-				nc = '4'
-			case 'I', 'L':
-				nc = '1'
-			case 'E':
-				nc = '3'
-			case 'S':
-				nc = '5'
-			case 'G':
-				nc = '9'
-			case 'O':
-				nc = '0'
-			default:
-				nc = c
-			}
-		}
-		res[i] = nc
-	}
-	return string(res) + "=="
-}
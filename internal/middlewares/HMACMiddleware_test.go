@@ -28,3 +28,21 @@ func TestOldHMAC(t *testing.T) {
 		t.FailNow()
 	}
 }
+
+func TestPayloadSignature(t *testing.T) {
+	payload := []byte(`{"event_type":"journal.posted"}`)
+	sig := SignPayload(payload, "webhook-secret", time.Now())
+	if !ValidatePayloadSignature(sig, payload, "webhook-secret", 5*time.Minute) {
+		t.Errorf("expecting signature to be valid")
+	}
+	if ValidatePayloadSignature(sig, []byte(`{"event_type":"journal.reversed"}`), "webhook-secret", 5*time.Minute) {
+		t.Errorf("expecting signature of tampered payload to be invalid")
+	}
+	if ValidatePayloadSignature(sig, payload, "another-secret", 5*time.Minute) {
+		t.Errorf("expecting signature with another secret to be invalid")
+	}
+	old := SignPayload(payload, "webhook-secret", time.Now().Add(-10*time.Minute))
+	if ValidatePayloadSignature(old, payload, "webhook-secret", 5*time.Minute) {
+		t.Errorf("expecting expired signature to be invalid")
+	}
+}
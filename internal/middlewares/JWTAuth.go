@@ -0,0 +1,423 @@
+package middlewares
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hyperjumptech/bookkeeping/internal/config"
+	"github.com/hyperjumptech/bookkeeping/internal/contextkeys"
+	log "github.com/sirupsen/logrus"
+)
+
+// BearerScheme is the scheme of the Authorization header carrying a JWT, as in "Bearer <jwt>"
+const BearerScheme = "Bearer"
+
+// JWK is a public key of a JSON Web Key Set, only RSA and EC keys are supported
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// JWKS is a JSON Web Key Set
+type JWKS struct {
+	Keys []*JWK `json:"keys"`
+}
+
+// bigInt decodes a base64url encoded big endian integer
+func bigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
+
+// PublicKey returns the *rsa.PublicKey or the *ecdsa.PublicKey of the JWK
+func (k *JWK) PublicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := bigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := bigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		if !e.IsInt64() || e.Int64() < 3 || e.Int64() > 1<<31-1 {
+			return nil, fmt.Errorf("invalid exponent of key %s", k.Kid)
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %s of key %s", k.Crv, k.Kid)
+		}
+		x, err := bigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := bigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, fmt.Errorf("key %s is not on curve %s", k.Kid, k.Crv)
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %s of key %s", k.Kty, k.Kid)
+	}
+}
+
+// publicKeys decodes the signing keys of the key set by their key id
+func (ks *JWKS) publicKeys() (map[string]crypto.PublicKey, error) {
+	keys := make(map[string]crypto.PublicKey)
+	for _, k := range ks.Keys {
+		if len(k.Use) > 0 && k.Use != "sig" {
+			continue
+		}
+		pub, err := k.PublicKey()
+		if err != nil {
+			return nil, err
+		}
+		keys[k.Kid] = pub
+	}
+	return keys, nil
+}
+
+// KeySet provides the public keys the JWTs are verified with
+type KeySet interface {
+	// PublicKey returns the key of the key id, or nil if there is no such key
+	PublicKey(ctx context.Context, kid string) (crypto.PublicKey, error)
+}
+
+// keyOf returns the key of the key id, a token without key id is verified with the only key of the set
+func keyOf(keys map[string]crypto.PublicKey, kid string) crypto.PublicKey {
+	if len(kid) == 0 && len(keys) == 1 {
+		for _, k := range keys {
+			return k
+		}
+	}
+	return keys[kid]
+}
+
+// StaticKeySet is a KeySet that never changes, usually read from a file
+type StaticKeySet struct {
+	keys map[string]crypto.PublicKey
+}
+
+// NewStaticKeySet returns the KeySet of the keys of the JWKS
+func NewStaticKeySet(jwks *JWKS) (*StaticKeySet, error) {
+	keys, err := jwks.publicKeys()
+	if err != nil {
+		return nil, err
+	}
+	return &StaticKeySet{keys: keys}, nil
+}
+
+// LoadJWKSFile reads the JWKS in the file into a StaticKeySet
+func LoadJWKSFile(path string) (*StaticKeySet, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	jwks := &JWKS{}
+	if err := json.Unmarshal(b, jwks); err != nil {
+		return nil, err
+	}
+	return NewStaticKeySet(jwks)
+}
+
+// PublicKey returns the key of the key id, or nil if there is no such key
+func (ks *StaticKeySet) PublicKey(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	return keyOf(ks.keys, kid), nil
+}
+
+// minJWKSRefetch limits how often RemoteKeySet fetches the JWKS looking for an unknown key id
+const minJWKSRefetch = time.Minute
+
+// RemoteKeySet is a KeySet fetched from the JWKS url of the issuer. It is fetched again once it is older than
+// the refresh interval, or when a token is signed by a key it does not know, so the issuer can rotate its keys.
+type RemoteKeySet struct {
+	url     string
+	client  *http.Client
+	refresh time.Duration
+
+	mutex     sync.Mutex
+	keys      map[string]crypto.PublicKey
+	fetchedAt time.Time
+}
+
+// NewRemoteKeySet returns the KeySet of the JWKS served at the url, it is fetched on first use.
+func NewRemoteKeySet(url string, client *http.Client, refresh time.Duration) *RemoteKeySet {
+	return &RemoteKeySet{url: url, client: client, refresh: refresh}
+}
+
+// fetch retrieves the JWKS from the url
+func (ks *RemoteKeySet) fetch(ctx context.Context) (map[string]crypto.PublicKey, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, ks.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := ks.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("jwks %s responded %d", ks.url, resp.StatusCode)
+	}
+	jwks := &JWKS{}
+	if err := json.NewDecoder(resp.Body).Decode(jwks); err != nil {
+		return nil, err
+	}
+	return jwks.publicKeys()
+}
+
+// PublicKey returns the key of the key id, or nil if there is no such key even after fetching the JWKS again.
+func (ks *RemoteKeySet) PublicKey(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	ks.mutex.Lock()
+	defer ks.mutex.Unlock()
+	age := time.Since(ks.fetchedAt)
+	if ks.keys != nil && age < ks.refresh {
+		if key := keyOf(ks.keys, kid); key != nil || age < minJWKSRefetch {
+			return key, nil
+		}
+	}
+	keys, err := ks.fetch(ctx)
+	if err != nil {
+		if ks.keys != nil {
+			// keep verifying with the keys known so far while the issuer is not reachable
+			log.WithField("function", "RemoteKeySet.PublicKey").Warnf("error while fetching jwks %s. got %s", ks.url, err.Error())
+			return keyOf(ks.keys, kid), nil
+		}
+		return nil, err
+	}
+	ks.keys, ks.fetchedAt = keys, time.Now()
+	return keyOf(ks.keys, kid), nil
+}
+
+// JWTPrincipalPrefix prefixes the principal of a token into the client id of the request, so a principal never
+// shares the idempotency keys, the external references or the rate limit of the API key client of the same name.
+const JWTPrincipalPrefix = "jwt:"
+
+// jwtPrincipalPattern is the format of the principal claim, once prefixed it must fit into the created_by columns
+var jwtPrincipalPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,12}$`)
+
+// JWTAuth authenticates the requests carrying a JWT issued by the configured issuer, as in "Bearer <jwt>".
+// The principal, the scopes and the tenant of the request are taken from the claims of the token.
+type JWTAuth struct {
+	// Issuer is the expected iss claim
+	Issuer string
+	// Audience is the expected aud claim, it is not checked if empty
+	Audience string
+	// Keys verifies the signature of the tokens
+	Keys KeySet
+	// PrincipalClaim is the claim of the principal, usually "sub"
+	PrincipalClaim string
+	// ScopeClaim is the claim of the scopes, either a space separated string or an array of strings
+	ScopeClaim string
+	// TenantClaim is the claim of the tenant whose books the caller works on, the default tenant if the token has none
+	TenantClaim string
+	// Leeway is the clock skew tolerated when checking exp and nbf
+	Leeway time.Duration
+}
+
+// NewJWTAuthFromConfig creates the JWTAuth of the jwt.* configuration,
+// the keys are read from jwt.jwks.file if set, or fetched from jwt.jwks.url.
+func NewJWTAuthFromConfig() (Authenticator, error) {
+	if len(config.Get("jwt.issuer")) == 0 {
+		return nil, fmt.Errorf("jwt.issuer is not configured")
+	}
+	var keys KeySet
+	if file := config.Get("jwt.jwks.file"); len(file) > 0 {
+		ks, err := LoadJWKSFile(file)
+		if err != nil {
+			return nil, err
+		}
+		keys = ks
+	} else if url := config.Get("jwt.jwks.url"); len(url) > 0 {
+		keys = NewRemoteKeySet(url, &http.Client{Timeout: 10 * time.Second}, time.Duration(config.GetInt("jwt.jwks.refresh.minute"))*time.Minute)
+	} else {
+		return nil, fmt.Errorf("neither jwt.jwks.file nor jwt.jwks.url is configured")
+	}
+	return &JWTAuth{
+		Issuer:         config.Get("jwt.issuer"),
+		Audience:       config.Get("jwt.audience"),
+		Keys:           keys,
+		PrincipalClaim: config.Get("jwt.claim.principal"),
+		ScopeClaim:     config.Get("jwt.claim.scope"),
+		TenantClaim:    config.Get("jwt.claim.tenant"),
+		Leeway:         time.Duration(config.GetInt("jwt.leeway.second")) * time.Second,
+	}, nil
+}
+
+// Applies tells whether the header carries a bearer token
+func (ja *JWTAuth) Applies(header string) bool {
+	splt := strings.SplitN(header, " ", 2)
+	return len(splt) == 2 && strings.EqualFold(splt[0], BearerScheme)
+}
+
+// Authenticate verifies the token, the principal claim prefixed by JWTPrincipalPrefix becomes the client of the request,
+// the scope claim its scopes and the tenant claim its tenant. Tokens whose principal is not a short identifier are refused.
+func (ja *JWTAuth) Authenticate(r *http.Request, header string) (context.Context, int) {
+	ctx := r.Context()
+	lLog := log.WithField("RequestID", ctx.Value(contextkeys.XRequestID)).WithField("function", "JWTAuth.Authenticate")
+	token := strings.TrimSpace(strings.SplitN(header, " ", 2)[1])
+	claims, err := ja.verify(ctx, token, time.Now())
+	if err != nil {
+		lLog.Warnf("jwt is refused. got %s", err.Error())
+		return ctx, http.StatusUnauthorized
+	}
+	principal, _ := claims[ja.PrincipalClaim].(string)
+	if len(principal) == 0 {
+		lLog.Warnf("jwt has no %s claim", ja.PrincipalClaim)
+		return ctx, http.StatusUnauthorized
+	}
+	if !jwtPrincipalPattern.MatchString(principal) {
+		lLog.Warnf("jwt %s claim is not a valid principal", ja.PrincipalClaim)
+		return ctx, http.StatusUnauthorized
+	}
+	tenantID, _ := claims[ja.TenantClaim].(string)
+	return withClient(ctx, &Client{ClientID: JWTPrincipalPrefix + principal, Scopes: scopesOfClaim(claims[ja.ScopeClaim]), TenantID: tenantID}), http.StatusOK
+}
+
+// scopesOfClaim reads the scopes of a space separated string or an array of strings
+func scopesOfClaim(claim interface{}) []string {
+	scopes := make([]string, 0)
+	switch c := claim.(type) {
+	case string:
+		scopes = append(scopes, strings.Fields(c)...)
+	case []interface{}:
+		for _, s := range c {
+			if str, ok := s.(string); ok {
+				scopes = append(scopes, str)
+			}
+		}
+	}
+	return scopes
+}
+
+// jwtHashes maps the supported signing algorithms to their hash
+var jwtHashes = map[string]crypto.Hash{
+	"RS256": crypto.SHA256, "RS384": crypto.SHA384, "RS512": crypto.SHA512,
+	"ES256": crypto.SHA256, "ES384": crypto.SHA384, "ES512": crypto.SHA512,
+}
+
+// verify checks the signature, the issuer, the audience and the validity period of the token, and returns its claims.
+func (ja *JWTAuth) verify(ctx context.Context, token string, now time.Time) (map[string]interface{}, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("malformed token")
+	}
+	head := struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}{}
+	if err := decodeSegment(parts[0], &head); err != nil {
+		return nil, fmt.Errorf("malformed header. got %w", err)
+	}
+	hash, ok := jwtHashes[head.Alg]
+	if !ok {
+		return nil, fmt.Errorf("unsupported algorithm %q", head.Alg)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("malformed signature. got %w", err)
+	}
+	key, err := ja.Keys.PublicKey(ctx, head.Kid)
+	if err != nil {
+		return nil, fmt.Errorf("error while retrieving key %q. got %w", head.Kid, err)
+	}
+	if key == nil {
+		return nil, fmt.Errorf("unknown key %q", head.Kid)
+	}
+	h := hash.New()
+	h.Write([]byte(parts[0] + "." + parts[1]))
+	digest := h.Sum(nil)
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		if head.Alg[:2] != "RS" || rsa.VerifyPKCS1v15(k, hash, digest, sig) != nil {
+			return nil, fmt.Errorf("invalid signature")
+		}
+	case *ecdsa.PublicKey:
+		size := (k.Curve.Params().BitSize + 7) / 8
+		if head.Alg[:2] != "ES" || len(sig) != 2*size ||
+			!ecdsa.Verify(k, digest, new(big.Int).SetBytes(sig[:size]), new(big.Int).SetBytes(sig[size:])) {
+			return nil, fmt.Errorf("invalid signature")
+		}
+	default:
+		return nil, fmt.Errorf("unsupported key %q", head.Kid)
+	}
+
+	claims := make(map[string]interface{})
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("malformed claims. got %w", err)
+	}
+	if iss, _ := claims["iss"].(string); iss != ja.Issuer {
+		return nil, fmt.Errorf("unexpected issuer %q", iss)
+	}
+	if len(ja.Audience) > 0 && !containsAudience(claims["aud"], ja.Audience) {
+		return nil, fmt.Errorf("unexpected audience %v", claims["aud"])
+	}
+	exp, ok := claims["exp"].(float64)
+	if !ok || now.Add(-ja.Leeway).After(time.Unix(int64(exp), 0)) {
+		return nil, fmt.Errorf("token is expired")
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Add(ja.Leeway).Before(time.Unix(int64(nbf), 0)) {
+		return nil, fmt.Errorf("token is not valid yet")
+	}
+	return claims, nil
+}
+
+// decodeSegment decodes a base64url encoded JSON segment of a token
+func decodeSegment(segment string, v interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+// containsAudience tells whether the aud claim, a string or an array of strings, contains the audience
+func containsAudience(aud interface{}, audience string) bool {
+	switch a := aud.(type) {
+	case string:
+		return a == audience
+	case []interface{}:
+		for _, s := range a {
+			if s == audience {
+				return true
+			}
+		}
+	}
+	return false
+}
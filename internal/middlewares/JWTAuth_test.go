@@ -0,0 +1,251 @@
+package middlewares
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hyperjumptech/bookkeeping/internal/connector"
+	"github.com/hyperjumptech/bookkeeping/internal/contextkeys"
+)
+
+const testIssuer = "https://issuer.example.com"
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// signJWT signs the claims with the key, RS256 for an rsa key and ES256 for an ecdsa key
+func signJWT(t *testing.T, key crypto.Signer, alg, kid string, claims map[string]interface{}) string {
+	head, _ := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	body, _ := json.Marshal(claims)
+	signingInput := b64(head) + "." + b64(body)
+	digest := sha256.Sum256([]byte(signingInput))
+	var sig []byte
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		s, err := rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		sig = s
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, k, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		sig = make([]byte, 64)
+		r.FillBytes(sig[:32])
+		s.FillBytes(sig[32:])
+	}
+	return signingInput + "." + b64(sig)
+}
+
+func rsaJWK(kid string, key *rsa.PublicKey) *JWK {
+	return &JWK{Kty: "RSA", Kid: kid, Use: "sig", N: b64(key.N.Bytes()), E: b64(big.NewInt(int64(key.E)).Bytes())}
+}
+
+func ecJWK(kid string, key *ecdsa.PublicKey) *JWK {
+	return &JWK{Kty: "EC", Kid: kid, Crv: "P-256", X: b64(key.X.FillBytes(make([]byte, 32))), Y: b64(key.Y.FillBytes(make([]byte, 32)))}
+}
+
+func TestJWTAuth(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the issuer serves its rsa key, the ec key is in a local file
+	var fetched int32
+	issuer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fetched, 1)
+		_ = json.NewEncoder(w).Encode(&JWKS{Keys: []*JWK{rsaJWK("rsa-1", &rsaKey.PublicKey)}})
+	}))
+	defer issuer.Close()
+	file := filepath.Join(t.TempDir(), "jwks.json")
+	b, _ := json.Marshal(&JWKS{Keys: []*JWK{ecJWK("ec-1", &ecKey.PublicKey)}})
+	if err := os.WriteFile(file, b, 0600); err != nil {
+		t.Fatal(err)
+	}
+	fileKeys, err := LoadJWKSFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	remote := &JWTAuth{Issuer: testIssuer, Audience: "bookkeeping", Keys: NewRemoteKeySet(issuer.URL, issuer.Client(), time.Hour),
+		PrincipalClaim: "sub", ScopeClaim: "scope", Leeway: time.Minute}
+	local := &JWTAuth{Issuer: testIssuer, Keys: fileKeys, PrincipalClaim: "sub", ScopeClaim: "scp", Leeway: time.Minute}
+
+	now := time.Now()
+	claims := func(overrides map[string]interface{}) map[string]interface{} {
+		c := map[string]interface{}{"iss": testIssuer, "aud": "bookkeeping", "sub": "billing-svc", "scope": "ledger:read journal:write",
+			"scp": []string{"fx:admin"}, "exp": now.Add(5 * time.Minute).Unix(), "nbf": now.Add(-time.Minute).Unix()}
+		for k, v := range overrides {
+			if v == nil {
+				delete(c, k)
+			} else {
+				c[k] = v
+			}
+		}
+		return c
+	}
+
+	testData := []struct {
+		name   string
+		auth   *JWTAuth
+		token  string
+		status int
+		scopes []string
+	}{
+		{"rsa from url", remote, signJWT(t, rsaKey, "RS256", "rsa-1", claims(nil)), http.StatusOK, []string{"ledger:read", "journal:write"}},
+		{"ec from file", local, signJWT(t, ecKey, "ES256", "ec-1", claims(nil)), http.StatusOK, []string{"fx:admin"}},
+		{"audience array", remote, signJWT(t, rsaKey, "RS256", "rsa-1", claims(map[string]interface{}{"aud": []string{"other", "bookkeeping"}})), http.StatusOK, []string{"ledger:read", "journal:write"}},
+		{"no kid with a single key", local, signJWT(t, ecKey, "ES256", "", claims(nil)), http.StatusOK, []string{"fx:admin"}},
+		{"wrong issuer", remote, signJWT(t, rsaKey, "RS256", "rsa-1", claims(map[string]interface{}{"iss": "https://evil.example.com"})), http.StatusUnauthorized, nil},
+		{"wrong audience", remote, signJWT(t, rsaKey, "RS256", "rsa-1", claims(map[string]interface{}{"aud": "other"})), http.StatusUnauthorized, nil},
+		{"expired", remote, signJWT(t, rsaKey, "RS256", "rsa-1", claims(map[string]interface{}{"exp": now.Add(-2 * time.Minute).Unix()})), http.StatusUnauthorized, nil},
+		{"no expiry", remote, signJWT(t, rsaKey, "RS256", "rsa-1", claims(map[string]interface{}{"exp": nil})), http.StatusUnauthorized, nil},
+		{"not yet valid", remote, signJWT(t, rsaKey, "RS256", "rsa-1", claims(map[string]interface{}{"nbf": now.Add(5 * time.Minute).Unix()})), http.StatusUnauthorized, nil},
+		{"no principal", remote, signJWT(t, rsaKey, "RS256", "rsa-1", claims(map[string]interface{}{"sub": nil})), http.StatusUnauthorized, nil},
+		{"principal too long", remote, signJWT(t, rsaKey, "RS256", "rsa-1", claims(map[string]interface{}{"sub": "billing-service"})), http.StatusUnauthorized, nil},
+		{"principal not an identifier", remote, signJWT(t, rsaKey, "RS256", "rsa-1", claims(map[string]interface{}{"sub": "jwt:billing"})), http.StatusUnauthorized, nil},
+		{"signed by another key", remote, signJWT(t, otherKey, "RS256", "rsa-1", claims(nil)), http.StatusUnauthorized, nil},
+		{"unknown kid", remote, signJWT(t, otherKey, "RS256", "rsa-2", claims(nil)), http.StatusUnauthorized, nil},
+		{"algorithm mismatch", local, signJWT(t, ecKey, "RS256", "ec-1", claims(nil)), http.StatusUnauthorized, nil},
+		{"alg none", remote, b64([]byte(`{"alg":"none","kid":"rsa-1"}`)) + "." + b64([]byte(`{"iss":"`+testIssuer+`","sub":"x"}`)) + ".", http.StatusUnauthorized, nil},
+		{"malformed", remote, "not-a-jwt", http.StatusUnauthorized, nil},
+	}
+	for _, td := range testData {
+		req := httptest.NewRequest("GET", "/api/v1/accounts", nil)
+		header := BearerScheme + " " + td.token
+		if !td.auth.Applies(header) {
+			t.Errorf("%s expecting the bearer token to apply", td.name)
+			continue
+		}
+		ctx, status := td.auth.Authenticate(req, header)
+		if status != td.status {
+			t.Errorf("%s expecting status %d but %d", td.name, td.status, status)
+			continue
+		}
+		if status != http.StatusOK {
+			continue
+		}
+		client := ClientFromContext(ctx)
+		if user, _ := ctx.Value(contextkeys.UserIDContextKey).(string); user != "jwt:billing-svc" || client == nil || len(client.Scopes) != len(td.scopes) {
+			t.Errorf("%s unexpected principal %s client %v", td.name, user, client)
+			continue
+		}
+		for i, scope := range td.scopes {
+			if client.Scopes[i] != scope {
+				t.Errorf("%s expecting scopes %v but %v", td.name, td.scopes, client.Scopes)
+			}
+		}
+	}
+	// the unknown kid is looked up once, not on every request
+	if n := atomic.LoadInt32(&fetched); n != 1 {
+		t.Errorf("expecting the jwks fetched once but %d", n)
+	}
+	// the token works on the books of its tenant claim
+	remote.TenantClaim = "tenant"
+	req := httptest.NewRequest("GET", "/api/v1/accounts", nil)
+	ctx, status := remote.Authenticate(req, BearerScheme+" "+signJWT(t, rsaKey, "RS256", "rsa-1", claims(map[string]interface{}{"tenant": "wallet"})))
+	if status != http.StatusOK || connector.TenantFromContext(ctx) != "wallet" {
+		t.Errorf("expecting the wallet tenant but %d %s", status, connector.TenantFromContext(ctx))
+	}
+	ctx, status = remote.Authenticate(req, BearerScheme+" "+signJWT(t, rsaKey, "RS256", "rsa-1", claims(nil)))
+	if status != http.StatusOK || connector.TenantFromContext(ctx) != connector.DefaultTenantID {
+		t.Errorf("expecting the default tenant but %d %s", status, connector.TenantFromContext(ctx))
+	}
+
+	if remote.Applies("ApiKey K1.secret") || remote.Applies(GenHMAC()) {
+		t.Errorf("expecting only bearer tokens to apply")
+	}
+}
+
+func TestAuthenticatorChain(t *testing.T) {
+	if _, err := NewAuthenticatorChain([]string{"apikey", "ldap"}); err == nil {
+		t.Errorf("expecting an unknown authenticator to be refused")
+	}
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys, err := NewStaticKeySet(&JWKS{Keys: []*JWK{ecJWK("ec-1", &ecKey.PublicKey)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	RegisterAuthenticator("test-jwt", func() (Authenticator, error) {
+		return &JWTAuth{Issuer: testIssuer, Keys: keys, PrincipalClaim: "sub", ScopeClaim: "scope"}, nil
+	})
+	defer delete(authenticatorFactories, "test-jwt")
+
+	chain, err := NewAuthenticatorChain([]string{"test-jwt", " hmac", ""})
+	if err != nil {
+		t.Fatal(err)
+	}
+	authenticators, secret, nonces := Authenticators, SecretKey, Nonces
+	Authenticators, SecretKey, Nonces = chain, "chain-test-secret", NewNonceCache()
+	defer func() {
+		Authenticators, SecretKey, Nonces = authenticators, secret, nonces
+	}()
+
+	var user string
+	handler := SetupContextMiddleware(AuthMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, _ = r.Context().Value(contextkeys.UserIDContextKey).(string)
+	})))
+	serve := func(req *http.Request) int {
+		user = ""
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	token := signJWT(t, ecKey, "ES256", "ec-1", map[string]interface{}{"iss": testIssuer, "sub": "svc", "exp": time.Now().Add(time.Minute).Unix()})
+	req := httptest.NewRequest("GET", "/api/v1/accounts", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	if status := serve(req); status != http.StatusOK || user != "jwt:svc" {
+		t.Errorf("expecting the jwt accepted but %d %s", status, user)
+	}
+
+	// hmac remains available
+	req = httptest.NewRequest("GET", "/api/v1/accounts", nil)
+	if err := SignRequest(req, "", SecretKey, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if status := serve(req); status != http.StatusOK || user != HMACPrincipal {
+		t.Errorf("expecting the signed request accepted but %d %s", status, user)
+	}
+
+	// api keys are not in the chain
+	APIKeys = stubAPIKeys{"K1": "secret"}
+	defer func() {
+		APIKeys = nil
+	}()
+	req = httptest.NewRequest("GET", "/api/v1/accounts", nil)
+	req.Header.Set("Authorization", "ApiKey "+FormatAPIKey("K1", "secret"))
+	if status := serve(req); status != http.StatusUnauthorized {
+		t.Errorf("expecting the api key refused but %d", status)
+	}
+}
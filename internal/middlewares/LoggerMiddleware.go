@@ -10,10 +10,6 @@ import (
 // Logger middleware handles some logging with logrus
 func Logger(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if r.URL.Path == "/devkey" {
-			next.ServeHTTP(w, r)
-			return
-		}
 		ctx := r.Context()
 		requestHdr := ctx.Value(contextkeys.XRequestID).(string)
 		// Log this request
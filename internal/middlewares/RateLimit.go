@@ -0,0 +1,142 @@
+package middlewares
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hyperjumptech/bookkeeping/internal/config"
+	"github.com/hyperjumptech/bookkeeping/internal/connector"
+	"github.com/hyperjumptech/bookkeeping/internal/contextkeys"
+	"github.com/hyperjumptech/bookkeeping/internal/helpers"
+	log "github.com/sirupsen/logrus"
+)
+
+// ErrorCodeRateLimited is the error code of the response to a request refused because its client exceeded its rate limit
+const ErrorCodeRateLimited = 5
+
+var (
+	// Limiter counts the requests of the clients, the requests are not limited if it is nil
+	Limiter RateLimiter
+	// RateWindow is the window the requests are counted in
+	RateWindow time.Duration
+	// ReadLimit is the number of read requests a client can make in a window
+	ReadLimit int
+	// WriteLimit is the number of write requests a client can make in a window
+	WriteLimit int
+)
+
+func init() {
+	RateWindow = time.Duration(config.GetInt("ratelimit.window.second")) * time.Second
+	ReadLimit = config.GetInt("ratelimit.read.limit")
+	WriteLimit = config.GetInt("ratelimit.write.limit")
+	if config.GetBoolean("ratelimit.enabled") {
+		Limiter = NewMemoryRateLimiter()
+	}
+}
+
+// RateLimiter counts the requests of the clients in fixed windows
+type RateLimiter interface {
+	// Hit counts a request of the key in the window starting at windowStart and lasting window,
+	// and returns the number of requests of the key counted in the window so far.
+	Hit(ctx context.Context, key string, windowStart time.Time, window time.Duration) (int, error)
+}
+
+// isWrite tells whether the request is a write request, limited by WriteLimit instead of ReadLimit
+func isWrite(r *http.Request) bool {
+	return r.Method != http.MethodGet && r.Method != http.MethodHead && r.Method != http.MethodOptions
+}
+
+// RateLimitMiddleware limits the number of api requests each authenticated client can make in a RateWindow,
+// read and write requests are limited separately. A request over the limit is refused with 429 and Retry-After,
+// telling the number of seconds until the next window.
+func RateLimitMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if Limiter == nil || RateWindow <= 0 || !strings.HasPrefix(r.URL.Path, "/api/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		principal, _ := r.Context().Value(contextkeys.UserIDContextKey).(string)
+		kind, limit := "read", ReadLimit
+		if isWrite(r) {
+			kind, limit = "write", WriteLimit
+		}
+		if limit <= 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		now := time.Now()
+		windowStart := now.Truncate(RateWindow)
+		// the clients of different tenants may share their client id
+		count, err := Limiter.Hit(r.Context(), kind+":"+connector.TenantFromContext(r.Context())+":"+principal, windowStart, RateWindow)
+		if err != nil {
+			// the requests are not refused while the requests can not be counted
+			log.WithField("RequestID", r.Context().Value(contextkeys.XRequestID)).WithField("function", "RateLimitMiddleware").
+				Errorf("error while counting request of %s. got %s", principal, err.Error())
+			next.ServeHTTP(w, r)
+			return
+		}
+		remaining := limit - count
+		if remaining < 0 {
+			remaining = 0
+		}
+		w.Header().Set("X-RateLimit-Limit", strconv.Itoa(limit))
+		w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(remaining))
+		if count > limit {
+			retryAfter := int(windowStart.Add(RateWindow).Sub(now).Seconds() + 0.999)
+			if retryAfter < 1 {
+				retryAfter = 1
+			}
+			w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+			helpers.HTTPResponseBuilder(r.Context(), w, r, http.StatusTooManyRequests, "too many requests",
+				fmt.Sprintf("%s rate limit of %d requests per %s exceeded", kind, limit, RateWindow), ErrorCodeRateLimited)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// memoryWindow is the count of requests of a key in a window
+type memoryWindow struct {
+	start time.Time
+	end   time.Time
+	count int
+}
+
+// MemoryRateLimiter is a RateLimiter counting in memory, each instance counts its own requests.
+type MemoryRateLimiter struct {
+	mutex     sync.Mutex
+	windows   map[string]*memoryWindow
+	lastPurge time.Time
+}
+
+// NewMemoryRateLimiter returns an empty MemoryRateLimiter
+func NewMemoryRateLimiter() *MemoryRateLimiter {
+	return &MemoryRateLimiter{windows: make(map[string]*memoryWindow)}
+}
+
+// Hit counts a request of the key in the window, the counts of the ended windows are purged once a minute.
+func (ml *MemoryRateLimiter) Hit(ctx context.Context, key string, windowStart time.Time, window time.Duration) (int, error) {
+	ml.mutex.Lock()
+	defer ml.mutex.Unlock()
+	if windowStart.Sub(ml.lastPurge) > time.Minute {
+		for k, mw := range ml.windows {
+			if !mw.end.After(windowStart) {
+				delete(ml.windows, k)
+			}
+		}
+		ml.lastPurge = windowStart
+	}
+	mw, ok := ml.windows[key]
+	if !ok || !mw.start.Equal(windowStart) {
+		mw = &memoryWindow{start: windowStart, end: windowStart.Add(window)}
+		ml.windows[key] = mw
+	}
+	mw.count++
+	return mw.count, nil
+}
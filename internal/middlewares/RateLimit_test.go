@@ -0,0 +1,109 @@
+package middlewares
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+type failingLimiter struct{}
+
+func (f failingLimiter) Hit(ctx context.Context, key string, windowStart time.Time, window time.Duration) (int, error) {
+	return 0, errors.New("limiter is down")
+}
+
+func TestMemoryRateLimiter(t *testing.T) {
+	ml := NewMemoryRateLimiter()
+	start := time.Now().Truncate(time.Minute)
+	for i := 1; i <= 3; i++ {
+		if count, _ := ml.Hit(context.Background(), "read:c1", start, time.Minute); count != i {
+			t.Errorf("expecting count %d but %d", i, count)
+		}
+	}
+	if count, _ := ml.Hit(context.Background(), "read:c2", start, time.Minute); count != 1 {
+		t.Errorf("expecting the keys counted separately but %d", count)
+	}
+	if count, _ := ml.Hit(context.Background(), "read:c1", start.Add(time.Minute), time.Minute); count != 1 {
+		t.Errorf("expecting the next window to start over but %d", count)
+	}
+	// the ended windows are purged
+	ml.Hit(context.Background(), "read:c3", start.Add(5*time.Minute), time.Minute)
+	if len(ml.windows) != 1 {
+		t.Errorf("expecting the ended windows purged but %d remain", len(ml.windows))
+	}
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	limiter, window, readLimit, writeLimit := Limiter, RateWindow, ReadLimit, WriteLimit
+	defer func() {
+		Limiter, RateWindow, ReadLimit, WriteLimit = limiter, window, readLimit, writeLimit
+	}()
+	Limiter, RateWindow, ReadLimit, WriteLimit = NewMemoryRateLimiter(), time.Hour, 3, 2
+
+	handler := SetupContextMiddleware(RateLimitMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})))
+	serve := func(method, path, client string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		// the client is either a client id or a tenant/client id
+		tenant, clientID, ok := strings.Cut(client, "/")
+		if !ok {
+			tenant, clientID = "", client
+		}
+		req = req.WithContext(withClient(req.Context(), &Client{ClientID: clientID, TenantID: tenant}))
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	for i := 0; i < 2; i++ {
+		if rec := serve("POST", "/api/v1/journals", "c1"); rec.Code != http.StatusOK {
+			t.Fatalf("expecting write %d accepted but %d", i, rec.Code)
+		}
+	}
+	rec := serve("POST", "/api/v1/journals", "c1")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("expecting 429 but %d", rec.Code)
+	}
+	retryAfter, err := strconv.Atoi(rec.Header().Get("Retry-After"))
+	if err != nil || retryAfter < 1 || retryAfter > 3600 {
+		t.Errorf("expecting Retry-After until the next window but %q", rec.Header().Get("Retry-After"))
+	}
+	if rec.Header().Get("X-RateLimit-Remaining") != "0" || rec.Header().Get("X-RateLimit-Limit") != "2" {
+		t.Errorf("unexpected rate limit headers %v", rec.Header())
+	}
+
+	// the reads have their own budget
+	for i := 0; i < 3; i++ {
+		if rec := serve("GET", "/api/v1/accounts", "c1"); rec.Code != http.StatusOK {
+			t.Fatalf("expecting read %d accepted but %d", i, rec.Code)
+		}
+	}
+	if rec := serve("GET", "/api/v1/accounts", "c1"); rec.Code != http.StatusTooManyRequests {
+		t.Errorf("expecting the reads limited but %d", rec.Code)
+	}
+
+	// each client has its own budget
+	if rec := serve("POST", "/api/v1/journals", "c2"); rec.Code != http.StatusOK {
+		t.Errorf("expecting another client accepted but %d", rec.Code)
+	}
+
+	// the clients of another tenant sharing the client id have their own budget
+	if rec := serve("POST", "/api/v1/journals", "wallet/c1"); rec.Code != http.StatusOK {
+		t.Errorf("expecting the client of another tenant accepted but %d", rec.Code)
+	}
+
+	// only the api is limited
+	if rec := serve("GET", "/health", "c1"); rec.Code != http.StatusOK {
+		t.Errorf("expecting the health check not limited but %d", rec.Code)
+	}
+
+	// the requests are not refused while they can not be counted
+	Limiter = failingLimiter{}
+	if rec := serve("POST", "/api/v1/journals", "c1"); rec.Code != http.StatusOK {
+		t.Errorf("expecting the request accepted when the limiter fails but %d", rec.Code)
+	}
+}
@@ -0,0 +1,195 @@
+package middlewares
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SignatureScheme is the scheme of the Authorization header carrying a request signature, as in
+// "BK-HMAC-SHA256 Credential=<key id>, Timestamp=<RFC3339 time>, Nonce=<nonce>, Signature=<hex signature>".
+// The credential is empty for requests signed with the shared secret.
+const SignatureScheme = "BK-HMAC-SHA256"
+
+const (
+	// minNonceLength and maxNonceLength bound the length of the nonce of a request signature
+	minNonceLength = 8
+	maxNonceLength = 64
+)
+
+// RequestSignature is the content of the Authorization header of a signed request
+type RequestSignature struct {
+	Credential string
+	Timestamp  string
+	Nonce      string
+	Signature  string
+}
+
+// String formats the signature into the Authorization header value
+func (rs *RequestSignature) String() string {
+	return fmt.Sprintf("%s Credential=%s, Timestamp=%s, Nonce=%s, Signature=%s", SignatureScheme, rs.Credential, rs.Timestamp, rs.Nonce, rs.Signature)
+}
+
+// ParseRequestSignature reads the Authorization header value of a signed request.
+func ParseRequestSignature(header string) (*RequestSignature, bool) {
+	splt := strings.SplitN(strings.TrimSpace(header), " ", 2)
+	if len(splt) != 2 || splt[0] != SignatureScheme {
+		return nil, false
+	}
+	rs := &RequestSignature{}
+	for _, part := range strings.Split(splt[1], ",") {
+		kv := strings.SplitN(strings.TrimSpace(part), "=", 2)
+		if len(kv) != 2 {
+			return nil, false
+		}
+		switch kv[0] {
+		case "Credential":
+			rs.Credential = kv[1]
+		case "Timestamp":
+			rs.Timestamp = kv[1]
+		case "Nonce":
+			rs.Nonce = kv[1]
+		case "Signature":
+			rs.Signature = kv[1]
+		default:
+			return nil, false
+		}
+	}
+	if len(rs.Timestamp) == 0 || len(rs.Nonce) < minNonceLength || len(rs.Nonce) > maxNonceLength || len(rs.Signature) == 0 {
+		return nil, false
+	}
+	return rs, true
+}
+
+// uriEncode percent encodes every byte of s except the unreserved characters of RFC 3986.
+func uriEncode(s string) string {
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') || c == '-' || c == '_' || c == '.' || c == '~' {
+			sb.WriteByte(c)
+		} else {
+			fmt.Fprintf(&sb, "%%%02X", c)
+		}
+	}
+	return sb.String()
+}
+
+// canonicalQuery encodes the query parameters sorted by name then by value
+func canonicalQuery(query url.Values) string {
+	pairs := make([]string, 0, len(query))
+	for k, vs := range query {
+		for _, v := range vs {
+			pairs = append(pairs, uriEncode(k)+"="+uriEncode(v))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
+
+// BodyDigest returns the hex encoded SHA-256 of the request body
+func BodyDigest(body []byte) string {
+	h := sha256.Sum256(body)
+	return hex.EncodeToString(h[:])
+}
+
+// CanonicalRequest builds the string a request signature is computed over, one element per line:
+// the method, the path, the canonical query, the body digest, the timestamp and the nonce.
+func CanonicalRequest(method, path string, query url.Values, bodyDigest, timestamp, nonce string) string {
+	return strings.Join([]string{strings.ToUpper(method), path, canonicalQuery(query), bodyDigest, timestamp, nonce}, "\n")
+}
+
+// signingKeyLabel is what the secret of an api key signs to derive its signing key
+const signingKeyLabel = "BK-HMAC-SHA256 signing key"
+
+// SigningKey derives the key a client signs its requests with from the secret of its api key, the hex encoded
+// HMAC-SHA256 of signingKeyLabel keyed by the secret. It differs from the hash the secret is verified with,
+// so the stored hashes do not sign requests.
+func SigningKey(secret string) string {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write([]byte(signingKeyLabel))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// ComputeRequestSignature returns the hex encoded HMAC-SHA256 of the canonical request
+func ComputeRequestSignature(canonicalRequest, signingKey string) string {
+	h := hmac.New(sha256.New, []byte(signingKey))
+	h.Write([]byte(canonicalRequest))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// readBody reads the request body and put it back so it can be read again
+func readBody(r *http.Request) ([]byte, error) {
+	if r.Body == nil {
+		return []byte{}, nil
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
+
+// SignRequest signs the request on behalf of a client and sets its Authorization header.
+// credential is the key id of the api key and signingKey is SigningKey of its secret,
+// or an empty credential and the shared secret itself.
+func SignRequest(r *http.Request, credential, signingKey string, at time.Time) error {
+	body, err := readBody(r)
+	if err != nil {
+		return err
+	}
+	nonceBytes := make([]byte, 16)
+	if _, err := rand.Read(nonceBytes); err != nil {
+		return err
+	}
+	rs := &RequestSignature{
+		Credential: credential,
+		Timestamp:  at.UTC().Format(time.RFC3339),
+		Nonce:      hex.EncodeToString(nonceBytes),
+	}
+	rs.Signature = ComputeRequestSignature(CanonicalRequest(r.Method, r.URL.Path, r.URL.Query(), BodyDigest(body), rs.Timestamp, rs.Nonce), signingKey)
+	r.Header.Set("Authorization", rs.String())
+	return nil
+}
+
+// NonceCache remembers the nonces of the accepted requests until their signature expires, so a request can not be replayed.
+type NonceCache struct {
+	mutex     sync.Mutex
+	seen      map[string]time.Time
+	lastPurge time.Time
+}
+
+// NewNonceCache returns an empty NonceCache
+func NewNonceCache() *NonceCache {
+	return &NonceCache{seen: make(map[string]time.Time)}
+}
+
+// Remember records the nonce until expiresAt. It returns false if the nonce is already recorded and not yet expired.
+func (nc *NonceCache) Remember(nonce string, expiresAt, now time.Time) bool {
+	nc.mutex.Lock()
+	defer nc.mutex.Unlock()
+	if now.Sub(nc.lastPurge) > time.Minute {
+		for n, exp := range nc.seen {
+			if !exp.After(now) {
+				delete(nc.seen, n)
+			}
+		}
+		nc.lastPurge = now
+	}
+	if exp, ok := nc.seen[nonce]; ok && exp.After(now) {
+		return false
+	}
+	nc.seen[nonce] = expiresAt
+	return true
+}
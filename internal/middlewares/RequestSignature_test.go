@@ -0,0 +1,145 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hyperjumptech/bookkeeping/internal/contextkeys"
+)
+
+func TestParseRequestSignature(t *testing.T) {
+	rs := &RequestSignature{Credential: "K1", Timestamp: "2021-01-02T03:04:05Z", Nonce: "0123456789abcdef", Signature: "abcd"}
+	parsed, ok := ParseRequestSignature(rs.String())
+	if !ok || *parsed != *rs {
+		t.Fatalf("expecting %v but %v %v", rs, parsed, ok)
+	}
+	// requests signed with the shared secret have no credential
+	if _, ok := ParseRequestSignature("BK-HMAC-SHA256 Credential=, Timestamp=2021-01-02T03:04:05Z, Nonce=0123456789abcdef, Signature=abcd"); !ok {
+		t.Errorf("expecting an empty credential to be parsed")
+	}
+	for _, header := range []string{
+		"BK-HMAC-SHA256 Credential=K1, Timestamp=2021-01-02T03:04:05Z, Nonce=short, Signature=abcd",
+		"BK-HMAC-SHA256 Credential=K1, Nonce=0123456789abcdef, Signature=abcd",
+		"BK-HMAC-SHA256 Credential=K1, Timestamp=2021-01-02T03:04:05Z, Nonce=0123456789abcdef, Signature=abcd, Extra=1",
+		"ApiKey K1.secret",
+	} {
+		if _, ok := ParseRequestSignature(header); ok {
+			t.Errorf("expecting %q to be refused", header)
+		}
+	}
+}
+
+func TestCanonicalQuery(t *testing.T) {
+	query := url.Values{"b": {"2", "1"}, "a b": {"x/y"}, "c": {"~ok"}}
+	if q := canonicalQuery(query); q != "a%20b=x%2Fy&b=1&b=2&c=~ok" {
+		t.Errorf("unexpected canonical query %s", q)
+	}
+}
+
+func TestHMACMiddlewareSignedRequest(t *testing.T) {
+	APIKeys = stubAPIKeys{"K1": "secret"}
+	secret, legacy, nonces := SecretKey, LegacyHMACEnabled, Nonces
+	SecretKey, LegacyHMACEnabled, Nonces = "signature-test-secret", false, NewNonceCache()
+	defer func() {
+		APIKeys = nil
+		SecretKey, LegacyHMACEnabled, Nonces = secret, legacy, nonces
+	}()
+
+	var user, body string
+	handler := SetupContextMiddleware(HMACMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, _ = r.Context().Value(contextkeys.UserIDContextKey).(string)
+		b, _ := readBody(r)
+		body = string(b)
+	})))
+	serve := func(req *http.Request) int {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	signed := func(method, target, body, credential, signingKey string, at time.Time) *http.Request {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		if err := SignRequest(req, credential, signingKey, at); err != nil {
+			t.Fatal(err)
+		}
+		return req
+	}
+
+	// signed with the shared secret
+	req := signed("POST", "/api/v1/journals?x=1", `{"a":1}`, "", SecretKey, time.Now())
+	if status := serve(req); status != http.StatusOK || user != HMACPrincipal || body != `{"a":1}` {
+		t.Errorf("expecting the shared secret signature accepted but %d %s %s", status, user, body)
+	}
+
+	// signed with an api key
+	req = signed("GET", "/api/v1/accounts", "", "K1", SigningKey("secret"), time.Now())
+	if status := serve(req); status != http.StatusOK || user != "client-K1" {
+		t.Errorf("expecting the api key signature accepted but %d %s", status, user)
+	}
+
+	// replayed
+	req = signed("GET", "/api/v1/accounts", "", "K1", SigningKey("secret"), time.Now())
+	replay := httptest.NewRequest("GET", "/api/v1/accounts", nil)
+	replay.Header.Set("Authorization", req.Header.Get("Authorization"))
+	if status := serve(req); status != http.StatusOK {
+		t.Errorf("expecting the first request accepted but %d", status)
+	}
+	if status := serve(replay); status != http.StatusUnauthorized {
+		t.Errorf("expecting the replayed request refused but %d", status)
+	}
+
+	testData := []struct {
+		name   string
+		req    *http.Request
+		tamper func(r *http.Request) *http.Request
+	}{
+		{"tampered body", signed("POST", "/api/v1/journals", `{"amount":1}`, "", SecretKey, time.Now()), func(r *http.Request) *http.Request {
+			tampered := httptest.NewRequest("POST", "/api/v1/journals", strings.NewReader(`{"amount":1000}`))
+			tampered.Header.Set("Authorization", r.Header.Get("Authorization"))
+			return tampered
+		}},
+		{"tampered path", signed("GET", "/api/v1/accounts/A1", "", "K1", SigningKey("secret"), time.Now()), func(r *http.Request) *http.Request {
+			tampered := httptest.NewRequest("GET", "/api/v1/accounts/A2", nil)
+			tampered.Header.Set("Authorization", r.Header.Get("Authorization"))
+			return tampered
+		}},
+		{"tampered query", signed("GET", "/api/v1/accounts?page=1", "", "K1", SigningKey("secret"), time.Now()), func(r *http.Request) *http.Request {
+			tampered := httptest.NewRequest("GET", "/api/v1/accounts?page=2", nil)
+			tampered.Header.Set("Authorization", r.Header.Get("Authorization"))
+			return tampered
+		}},
+		{"tampered method", signed("GET", "/api/v1/accounts/A1", "", "K1", SigningKey("secret"), time.Now()), func(r *http.Request) *http.Request {
+			tampered := httptest.NewRequest("DELETE", "/api/v1/accounts/A1", nil)
+			tampered.Header.Set("Authorization", r.Header.Get("Authorization"))
+			return tampered
+		}},
+		{"stale timestamp", signed("GET", "/api/v1/accounts", "", "K1", SigningKey("secret"), time.Now().Add(-time.Duration(HMACAgeMinutes+1)*time.Minute)), nil},
+		{"future timestamp", signed("GET", "/api/v1/accounts", "", "K1", SigningKey("secret"), time.Now().Add(time.Duration(HMACAgeMinutes+1)*time.Minute)), nil},
+		{"wrong key", signed("GET", "/api/v1/accounts", "", "K1", SigningKey("guess"), time.Now()), nil},
+		{"unknown credential", signed("GET", "/api/v1/accounts", "", "K2", SigningKey("secret"), time.Now()), nil},
+		{"api key secret as signing key", signed("GET", "/api/v1/accounts", "", "K1", "secret", time.Now()), nil},
+	}
+	for _, td := range testData {
+		req := td.req
+		if td.tamper != nil {
+			req = td.tamper(req)
+		}
+		if status := serve(req); status != http.StatusUnauthorized {
+			t.Errorf("%s expecting status 401 but %d", td.name, status)
+		}
+	}
+
+	// the legacy token is refused unless enabled
+	req = httptest.NewRequest("GET", "/api/v1/accounts", nil)
+	req.Header.Set("Authorization", GenHMAC())
+	if status := serve(req); status != http.StatusUnauthorized {
+		t.Errorf("expecting the legacy token refused but %d", status)
+	}
+	LegacyHMACEnabled = true
+	if status := serve(req); status != http.StatusOK {
+		t.Errorf("expecting the legacy token accepted when enabled but %d", status)
+	}
+}
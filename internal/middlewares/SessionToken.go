@@ -0,0 +1,159 @@
+package middlewares
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/hyperjumptech/bookkeeping/internal/config"
+	"github.com/hyperjumptech/bookkeeping/internal/contextkeys"
+	log "github.com/sirupsen/logrus"
+)
+
+// SessionScheme is the scheme of the Authorization header carrying a session token, as in "Session <token>"
+const SessionScheme = "Session"
+
+var (
+	// SessionKey signs the session tokens
+	SessionKey []byte
+	// SessionTTL is the validity of the session tokens
+	SessionTTL time.Duration
+
+	// ErrSessionNotRenewable is returned when a session token is requested with a session token, a session can not extend itself
+	ErrSessionNotRenewable = errors.New("a session token can not be issued to a session")
+	// ErrSessionUnauthenticated is returned when a session token is requested by an unauthenticated request
+	ErrSessionUnauthenticated = errors.New("session token requested without authentication")
+	// ErrScopeNotGranted is returned when a session token is requested with a scope the caller is not granted
+	ErrScopeNotGranted = errors.New("scope is not granted")
+)
+
+func init() {
+	SessionTTL = time.Duration(config.GetInt("auth.session.ttl.minute")) * time.Minute
+	if secret := config.Get("auth.session.secret"); len(secret) > 0 {
+		SessionKey = []byte(secret)
+	} else {
+		SessionKey = make([]byte, 32)
+		if _, err := rand.Read(SessionKey); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// SessionToken is the content of a session token
+type SessionToken struct {
+	ID        string   `json:"jti"`
+	Principal string   `json:"sub"`
+	KeyID     string   `json:"kid,omitempty"`
+	TenantID  string   `json:"tid,omitempty"`
+	Scopes    []string `json:"scp"`
+	ExpiresAt int64    `json:"exp"`
+}
+
+// signSession returns the hex encoded HMAC-SHA256 of the encoded token content
+func signSession(payload string) string {
+	h := hmac.New(sha256.New, SessionKey)
+	h.Write([]byte(payload))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// IssueSessionToken issues a short lived token to the caller of the request, carrying its principal and the scopes.
+// The token is granted every scope of the caller if no scope is specified. It can not be issued to a request authenticated
+// by a session token, so a session ends when its token expires.
+func IssueSessionToken(ctx context.Context, scopes []string, now time.Time) (string, *SessionToken, error) {
+	principal, _ := ctx.Value(contextkeys.UserIDContextKey).(string)
+	if len(principal) == 0 {
+		return "", nil, ErrSessionUnauthenticated
+	}
+	client := ClientFromContext(ctx)
+	if client != nil && client.Session {
+		return "", nil, ErrSessionNotRenewable
+	}
+	for _, scope := range scopes {
+		if !HasScope(ctx, scope) {
+			return "", nil, ErrScopeNotGranted
+		}
+	}
+	if len(scopes) == 0 {
+		scopes = grantedScopes(ctx)
+	}
+	id := make([]byte, 12)
+	if _, err := rand.Read(id); err != nil {
+		return "", nil, err
+	}
+	st := &SessionToken{
+		ID:        hex.EncodeToString(id),
+		Principal: principal,
+		Scopes:    scopes,
+		ExpiresAt: now.Add(SessionTTL).Unix(),
+	}
+	if client != nil {
+		st.KeyID = client.KeyID
+		st.TenantID = client.TenantID
+	}
+	b, err := json.Marshal(st)
+	if err != nil {
+		return "", nil, err
+	}
+	payload := base64.RawURLEncoding.EncodeToString(b)
+	return payload + "." + signSession(payload), st, nil
+}
+
+// ParseSessionToken verifies the signature and the expiry of the token and returns its content
+func ParseSessionToken(token string, now time.Time) (*SessionToken, bool) {
+	splt := strings.SplitN(token, ".", 2)
+	if len(splt) != 2 || !hmac.Equal([]byte(signSession(splt[0])), []byte(splt[1])) {
+		return nil, false
+	}
+	b, err := base64.RawURLEncoding.DecodeString(splt[0])
+	if err != nil {
+		return nil, false
+	}
+	st := &SessionToken{}
+	if err := json.Unmarshal(b, st); err != nil || len(st.Principal) == 0 || !now.Before(time.Unix(st.ExpiresAt, 0)) {
+		return nil, false
+	}
+	return st, true
+}
+
+// sessionAuth authenticates the requests carrying a session token issued by IssueSessionToken, as in "Session <token>"
+type sessionAuth struct{}
+
+// Applies tells whether the header carries a session token
+func (s *sessionAuth) Applies(header string) bool {
+	splt := strings.SplitN(header, " ", 2)
+	return len(splt) == 2 && strings.EqualFold(splt[0], SessionScheme)
+}
+
+// Authenticate verifies the session token, the principal of the token becomes the client of the request.
+// The token of an api key is refused as soon as the key is revoked or expired.
+func (s *sessionAuth) Authenticate(r *http.Request, header string) (context.Context, int) {
+	lLog := log.WithField("RequestID", r.Context().Value(contextkeys.XRequestID)).WithField("function", "sessionAuth.Authenticate")
+	st, ok := ParseSessionToken(strings.TrimSpace(strings.SplitN(header, " ", 2)[1]), time.Now())
+	if !ok {
+		lLog.Warn("session token is refused")
+		return r.Context(), http.StatusUnauthorized
+	}
+	if len(st.KeyID) > 0 {
+		if APIKeys == nil {
+			return r.Context(), http.StatusUnauthorized
+		}
+		client, err := APIKeys.APIKeyClient(r.Context(), st.KeyID)
+		if err != nil {
+			lLog.Errorf("error while verifying api key %s of the session. got %s", st.KeyID, err.Error())
+			return r.Context(), http.StatusInternalServerError
+		}
+		if client == nil {
+			lLog.Warnf("session token of api key %s is refused, the key is no longer valid", st.KeyID)
+			return r.Context(), http.StatusUnauthorized
+		}
+	}
+	return withClient(r.Context(), &Client{ClientID: st.Principal, KeyID: st.KeyID, Scopes: st.Scopes, TenantID: st.TenantID, Session: true}), http.StatusOK
+}
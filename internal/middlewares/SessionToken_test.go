@@ -0,0 +1,143 @@
+package middlewares
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hyperjumptech/bookkeeping/internal/config"
+	"github.com/hyperjumptech/bookkeeping/internal/connector"
+	"github.com/hyperjumptech/bookkeeping/internal/contextkeys"
+)
+
+func TestSessionToken(t *testing.T) {
+	client := withClient(context.Background(), &Client{ClientID: "c1", KeyID: "K1", Scopes: []string{RolePrefix + "bookkeeper"}})
+	now := time.Now()
+
+	token, st, err := IssueSessionToken(client, nil, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Principal != "c1" || st.KeyID != "K1" || len(st.Scopes) != 1 || st.Scopes[0] != RolePrefix+"bookkeeper" {
+		t.Errorf("unexpected session %v", st)
+	}
+	parsed, ok := ParseSessionToken(token, now)
+	if !ok || parsed.ID != st.ID || parsed.Principal != "c1" {
+		t.Errorf("expecting the token to be valid but %v %v", parsed, ok)
+	}
+	if _, ok := ParseSessionToken(token, now.Add(SessionTTL)); ok {
+		t.Errorf("expecting the token to expire")
+	}
+	payload := strings.SplitN(token, ".", 2)[0]
+	forged := strings.Replace(token, payload, payload[:len(payload)-2]+"xx", 1)
+	if _, ok := ParseSessionToken(forged, now); ok {
+		t.Errorf("expecting the forged token to be refused")
+	}
+
+	// the scopes can be narrowed down but not widened
+	_, st, err = IssueSessionToken(client, []string{ScopeLedgerRead}, now)
+	if err != nil || len(st.Scopes) != 1 || st.Scopes[0] != ScopeLedgerRead {
+		t.Errorf("expecting a narrowed token but %v %v", st, err)
+	}
+	if _, _, err = IssueSessionToken(client, []string{ScopeFXAdmin}, now); err != ErrScopeNotGranted {
+		t.Errorf("expecting ErrScopeNotGranted but %v", err)
+	}
+
+	// the shared secret is granted its configured scopes only
+	_, st, err = IssueSessionToken(context.WithValue(context.Background(), contextkeys.UserIDContextKey, HMACPrincipal), nil, now)
+	if err != nil || len(st.Scopes) != len(HMACScopes) || st.Scopes[0] != HMACScopes[0] {
+		t.Errorf("expecting the scopes of the shared secret but %v %v", st, err)
+	}
+	if _, _, err = IssueSessionToken(context.WithValue(context.Background(), contextkeys.UserIDContextKey, HMACPrincipal), []string{ScopeTenantAdmin}, now); err != ErrScopeNotGranted {
+		t.Errorf("expecting ErrScopeNotGranted but %v", err)
+	}
+
+	if _, _, err = IssueSessionToken(context.Background(), nil, now); err != ErrSessionUnauthenticated {
+		t.Errorf("expecting ErrSessionUnauthenticated but %v", err)
+	}
+
+	// a session can not extend itself
+	APIKeys = stubAPIKeys{"K1": "secret"}
+	defer func() { APIKeys = nil }()
+	var session context.Context
+	handler := SetupContextMiddleware(AuthMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		session = r.Context()
+	})))
+	req := httptest.NewRequest("GET", "/api/v1/accounts", nil)
+	req.Header.Set("Authorization", SessionScheme+" "+token)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || ClientFromContext(session) == nil || !ClientFromContext(session).Session {
+		t.Fatalf("expecting the session token accepted but %d", rec.Code)
+	}
+	if !HasScope(session, ScopeJournalWrite) || HasScope(session, ScopeAccountAdmin) {
+		t.Errorf("expecting the session to have the scopes of its token")
+	}
+	if tenant := connector.TenantFromContext(session); tenant != connector.DefaultTenantID {
+		t.Errorf("expecting the session of a client without tenant on the default tenant but %s", tenant)
+	}
+	if _, _, err = IssueSessionToken(session, nil, now); err != ErrSessionNotRenewable {
+		t.Errorf("expecting ErrSessionNotRenewable but %v", err)
+	}
+
+	// the session works on the tenant of its client
+	walletToken, _, err := IssueSessionToken(withClient(context.Background(), &Client{ClientID: "c2", TenantID: "wallet", Scopes: []string{ScopeLedgerRead}}), nil, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req = httptest.NewRequest("GET", "/api/v1/accounts", nil)
+	req.Header.Set("Authorization", SessionScheme+" "+walletToken)
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || connector.TenantFromContext(session) != "wallet" {
+		t.Errorf("expecting the session on the wallet tenant but %d %s", rec.Code, connector.TenantFromContext(session))
+	}
+
+	// the session ends when its api key is no longer valid
+	APIKeys = stubAPIKeys{}
+	req = httptest.NewRequest("GET", "/api/v1/accounts", nil)
+	req.Header.Set("Authorization", SessionScheme+" "+token)
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("expecting the session of a revoked key refused but %d", rec.Code)
+	}
+
+	req = httptest.NewRequest("GET", "/api/v1/accounts", nil)
+	req.Header.Set("Authorization", SessionScheme+" "+forged)
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("expecting the forged token refused but %d", rec.Code)
+	}
+}
+
+func TestLegacyHMACAbsentInProduction(t *testing.T) {
+	env, secret, legacy := config.Get("app.env"), SecretKey, LegacyHMACEnabled
+	SecretKey, LegacyHMACEnabled = "legacy-test-secret", true
+	defer func() {
+		config.SetConfig("app.env", env)
+		SecretKey, LegacyHMACEnabled = secret, legacy
+	}()
+
+	handler := SetupContextMiddleware(AuthMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})))
+	serve := func() int {
+		req := httptest.NewRequest("GET", "/api/v1/accounts", nil)
+		req.Header.Set("Authorization", GenHMAC())
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	config.SetConfig("app.env", "development")
+	if status := serve(); status != http.StatusOK {
+		t.Errorf("expecting the legacy hmac accepted in development but %d", status)
+	}
+	config.SetConfig("app.env", "production")
+	if status := serve(); status != http.StatusUnauthorized {
+		t.Errorf("expecting the legacy hmac refused in production but %d", status)
+	}
+}
@@ -27,6 +27,15 @@ func NewRouter() *Router {
 	return &Router{}
 }
 
+// RouteScopes maps the method and path template of every api endpoint, as in "GET /api/v1/accounts", to the scope it requires.
+var RouteScopes = make(map[string]string)
+
+// handle registers the handler of an api endpoint, it is only served to the callers granted the scope.
+func handle(r *mux.Router, method, path, scope string, handler http.HandlerFunc) {
+	RouteScopes[method+" "+path] = scope
+	r.HandleFunc(path, middlewares.RequireScope(scope, handler)).Methods(method, "OPTIONS")
+}
+
 // InitRoutes creates our routes
 func InitRoutes(router *Router) {
 	rLog.WithField("fn", "InitRoutes()").Info("Initializing routes...")
@@ -35,35 +44,99 @@ func InitRoutes(router *Router) {
 
 	// register middlewares
 	// r.Use(apmgorilla.Middleware()) // apmgorilla.Instrument(r.MuxRouter) // elastic apm: DISABLED
-	r.Use(middlewares.CORSMiddleware, middlewares.SetupContextMiddleware, middlewares.Logger, middlewares.HMACMiddleware) // your faithfull logger
+	r.Use(middlewares.CORSMiddleware, middlewares.SetupContextMiddleware, middlewares.Logger, middlewares.AuthMiddleware, middlewares.RateLimitMiddleware, middlewares.AuditMiddleware) // your faithfull logger
 
 	// health check endpoint. Not in a version path as it will seems to be a permanent endpoint (famous last words)
 	r.HandleFunc("/health", healthhttp.HandleHealthJSON(health.H)).Methods("GET", "OPTIONS")
-	r.HandleFunc("/devkey", middlewares.DevKey).Methods("PUT", "OPTIONS")
-
-	r.HandleFunc("/api/v1/accounts/{AccountNumber}", accounting.GetAccount).Methods("GET", "OPTIONS")
-	r.HandleFunc("/api/v1/accounts/{accountNumber}/draw", accounting.DrawAccount).Methods("GET", "OPTIONS")
-	r.HandleFunc("/api/v1/accounts/{AccountNumber}/transactions", accounting.ListTransactionByAccount).Methods("GET", "OPTIONS")
-	r.HandleFunc("/api/v1/accounts", accounting.FindAccount).Methods("GET", "OPTIONS")
-	r.HandleFunc("/api/v1/accounts", accounting.CreateAccount).Methods("POST", "OPTIONS")
-
-	r.HandleFunc("/api/v1/journals", accounting.CreateJournal).Methods("POST", "OPTIONS")
-	r.HandleFunc("/api/v1/journals", accounting.ListJournal).Methods("GET", "OPTIONS")
-	r.HandleFunc("/api/v1/journals/reversal", accounting.CreateReversalJournal).Methods("POST", "OPTIONS")
-	r.HandleFunc("/api/v1/journals/{JournalID}", accounting.GetJournal).Methods("GET", "OPTIONS")
-	r.HandleFunc("/api/v1/journals/{JournalID}/draw", accounting.DrawJournal).Methods("GET", "OPTIONS")
-
-	r.HandleFunc("/api/v1/transactions/{TransactionID}", accounting.GetTransaction).Methods("GET", "OPTIONS")
-
-	r.HandleFunc("/api/v1/exchange/denom", accounting.GetCommonDenominator).Methods("GET", "OPTIONS")
-	r.HandleFunc("/api/v1/exchange/denom", accounting.SetCommonDenominator).Methods("PUT", "OPTIONS")
-
-	r.HandleFunc("/api/v1/currencies", accounting.ListCurrencies).Methods("GET", "OPTIONS")
-	r.HandleFunc("/api/v1/currencies/{code}", accounting.GetCurrency).Methods("GET", "OPTIONS")
-	r.HandleFunc("/api/v1/currencies/{code}", accounting.SetCurrency).Methods("PUT", "OPTIONS")
 
-	r.HandleFunc("/api/v1/exchange/{codefrom}/{codeto}", accounting.CalculateExchangeRate).Methods("GET", "OPTIONS")
-	r.HandleFunc("/api/v1/exchange/{codefrom}/{codeto}/{amount}", accounting.CalculateExchange).Methods("GET", "OPTIONS")
+	// registered before the {AccountNumber} routes, so "by-reference" is not taken for an account number
+	handle(r, "GET", "/api/v1/accounts/by-reference/{Reference}", middlewares.ScopeLedgerRead, accounting.GetAccountByReference)
+	handle(r, "GET", "/api/v1/accounts/{AccountNumber}", middlewares.ScopeLedgerRead, accounting.GetAccount)
+	handle(r, "PUT", "/api/v1/accounts/{AccountNumber}", middlewares.ScopeAccountAdmin, accounting.UpdateAccount)
+	handle(r, "PUT", "/api/v1/accounts/{AccountNumber}/freeze", middlewares.ScopeAccountAdmin, accounting.FreezeAccount)
+	handle(r, "PUT", "/api/v1/accounts/{AccountNumber}/unfreeze", middlewares.ScopeAccountAdmin, accounting.UnfreezeAccount)
+	handle(r, "PUT", "/api/v1/accounts/{AccountNumber}/close", middlewares.ScopeAccountAdmin, accounting.CloseAccount)
+	handle(r, "PUT", "/api/v1/accounts/{AccountNumber}/limits", middlewares.ScopeAccountAdmin, accounting.SetAccountLimits)
+	handle(r, "GET", "/api/v1/accounts/{AccountNumber}/holds", middlewares.ScopeLedgerRead, accounting.ListHoldsByAccount)
+	handle(r, "GET", "/api/v1/accounts/{accountNumber}/draw", middlewares.ScopeLedgerRead, accounting.DrawAccount)
+	handle(r, "GET", "/api/v1/accounts/{AccountNumber}/transactions", middlewares.ScopeLedgerRead, accounting.ListTransactionByAccount)
+	handle(r, "GET", "/api/v1/accounts/{AccountNumber}/statement", middlewares.ScopeLedgerRead, accounting.GetAccountStatement)
+	handle(r, "GET", "/api/v1/accounts", middlewares.ScopeLedgerRead, accounting.FindAccount)
+	handle(r, "POST", "/api/v1/accounts", middlewares.ScopeAccountAdmin, accounting.CreateAccount)
+
+	handle(r, "POST", "/api/v1/journals", middlewares.ScopeJournalWrite, accounting.CreateJournal)
+	handle(r, "GET", "/api/v1/journals", middlewares.ScopeLedgerRead, accounting.ListJournal)
+	handle(r, "POST", "/api/v1/journals/batch", middlewares.ScopeJournalWrite, accounting.CreateJournalBatch)
+	handle(r, "POST", "/api/v1/journals/simulate", middlewares.ScopeLedgerRead, accounting.SimulateJournal)
+	handle(r, "POST", "/api/v1/journals/reversal", middlewares.ScopeJournalWrite, accounting.CreateReversalJournal)
+	handle(r, "POST", "/api/v1/journals/from-template/{Name}", middlewares.ScopeJournalWrite, accounting.CreateJournalFromTemplate)
+	handle(r, "GET", "/api/v1/journals/by-reference/{Reference}", middlewares.ScopeLedgerRead, accounting.GetJournalByReference)
+	handle(r, "GET", "/api/v1/journals/search", middlewares.ScopeLedgerRead, accounting.SearchJournals)
+	handle(r, "GET", "/api/v1/journals/{JournalID}", middlewares.ScopeLedgerRead, accounting.GetJournal)
+	handle(r, "GET", "/api/v1/journals/{JournalID}/draw", middlewares.ScopeLedgerRead, accounting.DrawJournal)
+	handle(r, "GET", "/api/v1/journals/{JournalID}/reversals", middlewares.ScopeLedgerRead, accounting.GetJournalReversals)
+
+	handle(r, "GET", "/api/v1/feed", middlewares.ScopeLedgerRead, accounting.ListLedgerFeed)
+
+	handle(r, "POST", "/api/v1/holds", middlewares.ScopeJournalWrite, accounting.PlaceHold)
+	handle(r, "GET", "/api/v1/holds/{HoldID}", middlewares.ScopeLedgerRead, accounting.GetHold)
+	handle(r, "POST", "/api/v1/holds/{HoldID}/capture", middlewares.ScopeJournalWrite, accounting.CaptureHold)
+	handle(r, "POST", "/api/v1/holds/{HoldID}/void", middlewares.ScopeJournalWrite, accounting.VoidHold)
+
+	handle(r, "POST", "/api/v1/recurring-journals", middlewares.ScopeJournalWrite, accounting.CreateRecurringJournal)
+	handle(r, "GET", "/api/v1/recurring-journals", middlewares.ScopeLedgerRead, accounting.ListRecurringJournals)
+	handle(r, "GET", "/api/v1/recurring-journals/{ScheduleID}", middlewares.ScopeLedgerRead, accounting.GetRecurringJournal)
+	handle(r, "PUT", "/api/v1/recurring-journals/{ScheduleID}/pause", middlewares.ScopeJournalWrite, accounting.PauseRecurringJournal)
+	handle(r, "PUT", "/api/v1/recurring-journals/{ScheduleID}/resume", middlewares.ScopeJournalWrite, accounting.ResumeRecurringJournal)
+	handle(r, "GET", "/api/v1/recurring-journals/{ScheduleID}/runs", middlewares.ScopeLedgerRead, accounting.ListRecurringJournalRuns)
+
+	handle(r, "POST", "/api/v1/pending-journals", middlewares.ScopeJournalWrite, accounting.SubmitPendingJournal)
+	handle(r, "GET", "/api/v1/pending-journals", middlewares.ScopeLedgerRead, accounting.ListPendingJournals)
+	handle(r, "GET", "/api/v1/pending-journals/{PendingID}", middlewares.ScopeLedgerRead, accounting.GetPendingJournal)
+	handle(r, "POST", "/api/v1/pending-journals/{PendingID}/approve", middlewares.ScopeJournalApprove, accounting.ApprovePendingJournal)
+	handle(r, "POST", "/api/v1/pending-journals/{PendingID}/reject", middlewares.ScopeJournalApprove, accounting.RejectPendingJournal)
+	handle(r, "POST", "/api/v1/approval-rules", middlewares.ScopeSystemAdmin, accounting.CreateApprovalRule)
+	handle(r, "GET", "/api/v1/approval-rules", middlewares.ScopeSystemAdmin, accounting.ListApprovalRules)
+	handle(r, "DELETE", "/api/v1/approval-rules/{RuleID}", middlewares.ScopeSystemAdmin, accounting.DeleteApprovalRule)
+
+	handle(r, "GET", "/api/v1/posting-templates", middlewares.ScopeLedgerRead, accounting.ListPostingTemplates)
+	handle(r, "GET", "/api/v1/posting-templates/{Name}", middlewares.ScopeLedgerRead, accounting.GetPostingTemplate)
+	handle(r, "PUT", "/api/v1/posting-templates/{Name}", middlewares.ScopeSystemAdmin, accounting.SavePostingTemplate)
+	handle(r, "DELETE", "/api/v1/posting-templates/{Name}", middlewares.ScopeSystemAdmin, accounting.DeletePostingTemplate)
+
+	handle(r, "POST", "/api/v1/webhooks", middlewares.ScopeSystemAdmin, accounting.CreateWebhookSubscription)
+	handle(r, "GET", "/api/v1/webhooks", middlewares.ScopeSystemAdmin, accounting.ListWebhookSubscriptions)
+	handle(r, "GET", "/api/v1/webhooks/{SubscriptionID}", middlewares.ScopeSystemAdmin, accounting.GetWebhookSubscription)
+	handle(r, "DELETE", "/api/v1/webhooks/{SubscriptionID}", middlewares.ScopeSystemAdmin, accounting.DeleteWebhookSubscription)
+	handle(r, "GET", "/api/v1/webhooks/{SubscriptionID}/deliveries", middlewares.ScopeSystemAdmin, accounting.ListWebhookDeliveries)
+	handle(r, "POST", "/api/v1/webhooks/{SubscriptionID}/dead-letters/replay", middlewares.ScopeSystemAdmin, accounting.ReplayDeadWebhookDeliveries)
+	handle(r, "POST", "/api/v1/webhook-deliveries/{DeliveryID}/replay", middlewares.ScopeSystemAdmin, accounting.ReplayWebhookDelivery)
+
+	handle(r, "GET", "/api/v1/transactions/search", middlewares.ScopeLedgerRead, accounting.SearchTransactions)
+	handle(r, "GET", "/api/v1/transactions/{TransactionID}", middlewares.ScopeLedgerRead, accounting.GetTransaction)
+
+	handle(r, "GET", "/api/v1/exchange/denom", middlewares.ScopeLedgerRead, accounting.GetCommonDenominator)
+	handle(r, "PUT", "/api/v1/exchange/denom", middlewares.ScopeFXAdmin, accounting.SetCommonDenominator)
+
+	handle(r, "GET", "/api/v1/currencies", middlewares.ScopeLedgerRead, accounting.ListCurrencies)
+	handle(r, "GET", "/api/v1/currencies/{code}", middlewares.ScopeLedgerRead, accounting.GetCurrency)
+	handle(r, "PUT", "/api/v1/currencies/{code}", middlewares.ScopeFXAdmin, accounting.SetCurrency)
+
+	handle(r, "GET", "/api/v1/exchange/{codefrom}/{codeto}", middlewares.ScopeLedgerRead, accounting.CalculateExchangeRate)
+	handle(r, "GET", "/api/v1/exchange/{codefrom}/{codeto}/{amount}", middlewares.ScopeLedgerRead, accounting.CalculateExchange)
+
+	handle(r, "POST", "/api/v1/auth/token", middlewares.ScopeLedgerRead, accounting.IssueSessionToken)
+
+	handle(r, "GET", "/api/v1/admin/audit-logs", middlewares.ScopeSystemAdmin, accounting.ListAuditLogs)
+	handle(r, "POST", "/api/v1/admin/api-keys", middlewares.ScopeSystemAdmin, accounting.IssueAPIKey)
+	handle(r, "GET", "/api/v1/admin/api-keys", middlewares.ScopeSystemAdmin, accounting.ListAPIKeys)
+	handle(r, "GET", "/api/v1/admin/api-keys/{KeyID}", middlewares.ScopeSystemAdmin, accounting.GetAPIKey)
+	handle(r, "POST", "/api/v1/admin/api-keys/{KeyID}/rotate", middlewares.ScopeSystemAdmin, accounting.RotateAPIKey)
+	handle(r, "POST", "/api/v1/admin/api-keys/{KeyID}/revoke", middlewares.ScopeSystemAdmin, accounting.RevokeAPIKey)
+
+	handle(r, "POST", "/api/v1/admin/tenants", middlewares.ScopeTenantAdmin, accounting.CreateTenant)
+	handle(r, "GET", "/api/v1/admin/tenants", middlewares.ScopeTenantAdmin, accounting.ListTenants)
+	handle(r, "GET", "/api/v1/admin/tenants/{TenantID}", middlewares.ScopeTenantAdmin, accounting.GetTenant)
 
 	r.HandleFunc("/docs", StaticServer("")).Methods("GET")
 	r.HandleFunc("/docs/", StaticServer("")).Methods("GET")
@@ -0,0 +1,241 @@
+package router
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/hyperjumptech/bookkeeping/internal/config"
+	"github.com/hyperjumptech/bookkeeping/internal/middlewares"
+)
+
+// stubAPIKeys authenticates the key id as a client granted the scopes of the key id, e.g. "ApiKey reader.x"
+type stubAPIKeys map[string][]string
+
+func (s stubAPIKeys) AuthenticateAPIKey(ctx context.Context, keyID, secret string) (*middlewares.Client, error) {
+	scopes, ok := s[keyID]
+	if !ok {
+		return nil, nil
+	}
+	return &middlewares.Client{ClientID: keyID, KeyID: keyID, Scopes: scopes}, nil
+}
+
+func (s stubAPIKeys) APIKeySigningKey(ctx context.Context, keyID string) (*middlewares.Client, string, error) {
+	return nil, "", nil
+}
+
+func (s stubAPIKeys) APIKeyClient(ctx context.Context, keyID string) (*middlewares.Client, error) {
+	scopes, ok := s[keyID]
+	if !ok {
+		return nil, nil
+	}
+	return &middlewares.Client{ClientID: keyID, KeyID: keyID, Scopes: scopes}, nil
+}
+
+func newTestRouter() *mux.Router {
+	router := NewRouter()
+	router.Router = mux.NewRouter()
+	InitRoutes(router)
+	return router.Router
+}
+
+func TestRouteScopes(t *testing.T) {
+	r := newTestRouter()
+
+	testData := []struct {
+		route string
+		scope string
+	}{
+		{"GET /api/v1/accounts", middlewares.ScopeLedgerRead},
+		{"GET /api/v1/journals/{JournalID}", middlewares.ScopeLedgerRead},
+		{"GET /api/v1/journals/by-reference/{Reference}", middlewares.ScopeLedgerRead},
+		{"GET /api/v1/accounts/by-reference/{Reference}", middlewares.ScopeLedgerRead},
+		{"GET /api/v1/journals/search", middlewares.ScopeLedgerRead},
+		{"GET /api/v1/transactions/search", middlewares.ScopeLedgerRead},
+		{"GET /api/v1/accounts/{AccountNumber}/statement", middlewares.ScopeLedgerRead},
+		{"GET /api/v1/exchange/{codefrom}/{codeto}/{amount}", middlewares.ScopeLedgerRead},
+		{"POST /api/v1/journals/simulate", middlewares.ScopeLedgerRead},
+		{"POST /api/v1/journals", middlewares.ScopeJournalWrite},
+		{"POST /api/v1/journals/reversal", middlewares.ScopeJournalWrite},
+		{"POST /api/v1/holds", middlewares.ScopeJournalWrite},
+		{"POST /api/v1/pending-journals/{PendingID}/approve", middlewares.ScopeJournalApprove},
+		{"POST /api/v1/accounts", middlewares.ScopeAccountAdmin},
+		{"PUT /api/v1/accounts/{AccountNumber}/freeze", middlewares.ScopeAccountAdmin},
+		{"PUT /api/v1/currencies/{code}", middlewares.ScopeFXAdmin},
+		{"PUT /api/v1/exchange/denom", middlewares.ScopeFXAdmin},
+		{"POST /api/v1/admin/api-keys", middlewares.ScopeSystemAdmin},
+		{"GET /api/v1/admin/audit-logs", middlewares.ScopeSystemAdmin},
+		{"POST /api/v1/webhooks", middlewares.ScopeSystemAdmin},
+		{"PUT /api/v1/posting-templates/{Name}", middlewares.ScopeSystemAdmin},
+		{"DELETE /api/v1/posting-templates/{Name}", middlewares.ScopeSystemAdmin},
+		{"POST /api/v1/journals/from-template/{Name}", middlewares.ScopeJournalWrite},
+		{"POST /api/v1/admin/tenants", middlewares.ScopeTenantAdmin},
+	}
+	for _, td := range testData {
+		if RouteScopes[td.route] != td.scope {
+			t.Errorf("%s expecting scope %s but %q", td.route, td.scope, RouteScopes[td.route])
+		}
+	}
+
+	// every api endpoint requires a scope
+	err := r.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		path, err := route.GetPathTemplate()
+		if err != nil || !strings.HasPrefix(path, "/api/") {
+			return nil
+		}
+		methods, _ := route.GetMethods()
+		for _, method := range methods {
+			if method == http.MethodOptions {
+				continue
+			}
+			if _, ok := RouteScopes[method+" "+path]; !ok {
+				t.Errorf("%s %s does not require a scope", method, path)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestScopeEnforced(t *testing.T) {
+	middlewares.APIKeys = stubAPIKeys{
+		"reader":   {middlewares.ScopeLedgerRead},
+		"fx":       {middlewares.ScopeFXAdmin},
+		"admin":    {middlewares.RolePrefix + "admin"},
+		"nobody":   {},
+		"operator": {middlewares.RolePrefix + "bookkeeper"},
+	}
+	defer func() {
+		middlewares.APIKeys = nil
+	}()
+	r := newTestRouter()
+
+	testData := []struct {
+		keyID  string
+		method string
+		path   string
+		body   string
+		status int
+	}{
+		{"reader", "PUT", "/api/v1/currencies/GOLD", `{"name":"Gold","exchange":1}`, http.StatusForbidden},
+		{"reader", "PUT", "/api/v1/exchange/denom", `{"code":"GOLD"}`, http.StatusForbidden},
+		{"reader", "POST", "/api/v1/journals", `{}`, http.StatusForbidden},
+		{"operator", "PUT", "/api/v1/exchange/denom", `{"code":"GOLD"}`, http.StatusForbidden},
+		{"operator", "POST", "/api/v1/accounts", `{}`, http.StatusForbidden},
+		{"nobody", "GET", "/api/v1/currencies", "", http.StatusForbidden},
+		{"fx", "GET", "/api/v1/admin/audit-logs", "", http.StatusForbidden},
+		// granted, the audit manager is not available in this test
+		{"admin", "GET", "/api/v1/admin/audit-logs", "", http.StatusNotImplemented},
+		// routed to the by-reference lookups, not the {JournalID}/draw route, the reference manager is not available in this test
+		{"reader", "GET", "/api/v1/journals/by-reference/draw", "", http.StatusNotImplemented},
+		{"reader", "GET", "/api/v1/accounts/by-reference/ORD-1", "", http.StatusNotImplemented},
+		// routed to the searches, not the {JournalID} and {TransactionID} routes, the search manager is not available in this test
+		{"reader", "GET", "/api/v1/journals/search", "", http.StatusNotImplemented},
+		{"reader", "GET", "/api/v1/transactions/search", "", http.StatusNotImplemented},
+		{"nobody", "GET", "/api/v1/journals/search", "", http.StatusForbidden},
+		// the cursor selects the cursor pagination, the cursor manager is not available in this test
+		{"reader", "GET", "/api/v1/journals?from=2024-01-01T00:00:00&until=2024-02-01T00:00:00&size=10&cursor=", "", http.StatusNotImplemented},
+		// granted, the statement manager is not available in this test
+		{"reader", "GET", "/api/v1/accounts/CASH/statement?from=2024-01-01T00:00:00&until=2024-02-01T00:00:00", "", http.StatusNotImplemented},
+		{"nobody", "GET", "/api/v1/accounts/CASH/statement", "", http.StatusForbidden},
+	}
+	for _, td := range testData {
+		req := httptest.NewRequest(td.method, td.path, strings.NewReader(td.body))
+		req.Header.Set("Authorization", middlewares.APIKeyScheme+" "+middlewares.FormatAPIKey(td.keyID, "x"))
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		if rec.Code != td.status {
+			t.Errorf("%s %s by %s expecting status %d but %d", td.method, td.path, td.keyID, td.status, rec.Code)
+			continue
+		}
+		if td.status == http.StatusForbidden {
+			resp := struct {
+				Status    string `json:"status"`
+				ErrorCode int    `json:"error_code"`
+			}{}
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || resp.Status != "FAIL" || resp.ErrorCode != middlewares.ErrorCodeInsufficientScope {
+				t.Errorf("%s %s by %s unexpected refusal %s", td.method, td.path, td.keyID, rec.Body.String())
+			}
+		}
+	}
+}
+
+func TestNoDevKey(t *testing.T) {
+	env := config.Get("app.env")
+	defer config.SetConfig("app.env", env)
+	middlewares.APIKeys = stubAPIKeys{"admin": {middlewares.RolePrefix + "admin"}}
+	defer func() {
+		middlewares.APIKeys = nil
+	}()
+
+	for _, mode := range []string{"development", "production"} {
+		config.SetConfig("app.env", mode)
+		r := newTestRouter()
+		_ = r.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+			if path, _ := route.GetPathTemplate(); path == "/devkey" {
+				t.Errorf("expecting no /devkey route in %s", mode)
+			}
+			return nil
+		})
+		for _, authorization := range []string{"", middlewares.APIKeyScheme + " " + middlewares.FormatAPIKey("admin", "x")} {
+			req := httptest.NewRequest("PUT", "/devkey", nil)
+			req.Header.Set("HocusPocus", "AvadaCadavra")
+			req.Header.Set("Authorization", authorization)
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, req)
+			if rec.Code == http.StatusOK || strings.Contains(rec.Body.String(), "incantation") {
+				t.Errorf("expecting no key handed out by /devkey in %s but %d %s", mode, rec.Code, rec.Body.String())
+			}
+		}
+	}
+}
+
+func TestSessionLogin(t *testing.T) {
+	middlewares.APIKeys = stubAPIKeys{"reader": {middlewares.ScopeLedgerRead}}
+	defer func() {
+		middlewares.APIKeys = nil
+	}()
+	r := newTestRouter()
+
+	serve := func(authorization, method, path, body string) (int, string) {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", authorization)
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec.Code, rec.Body.String()
+	}
+
+	status, body := serve(middlewares.APIKeyScheme+" "+middlewares.FormatAPIKey("reader", "x"), "POST", "/api/v1/auth/token", "")
+	resp := struct {
+		Data struct {
+			Token     string   `json:"token"`
+			TokenType string   `json:"token_type"`
+			Principal string   `json:"principal"`
+			Scopes    []string `json:"scopes"`
+		} `json:"data"`
+	}{}
+	if err := json.Unmarshal([]byte(body), &resp); err != nil || status != http.StatusOK || len(resp.Data.Token) == 0 ||
+		resp.Data.TokenType != middlewares.SessionScheme || resp.Data.Principal != "reader" {
+		t.Fatalf("expecting a session token but %d %s", status, body)
+	}
+	session := middlewares.SessionScheme + " " + resp.Data.Token
+
+	if status, _ := serve(session, "GET", "/api/v1/admin/audit-logs", ""); status != http.StatusForbidden {
+		t.Errorf("expecting the session limited to the scopes of the key but %d", status)
+	}
+	if status, _ := serve(session, "POST", "/api/v1/auth/token", ""); status != http.StatusForbidden {
+		t.Errorf("expecting the session not renewable but %d", status)
+	}
+	if status, _ := serve(middlewares.APIKeyScheme+" "+middlewares.FormatAPIKey("reader", "x"), "POST", "/api/v1/auth/token", `{"scopes":["fx:admin"]}`); status != http.StatusForbidden {
+		t.Errorf("expecting a scope not granted refused but %d", status)
+	}
+	if status, _ := serve("", "POST", "/api/v1/auth/token", ""); status != http.StatusUnauthorized {
+		t.Errorf("expecting an unauthenticated login refused but %d", status)
+	}
+}
@@ -3,4 +3,19 @@ use bookkeeping;
 DELETE FROM accounts;
 DELETE FROM currencies;
 DELETE FROM journals;
-DELETE FROM transactions;
\ No newline at end of file
+DELETE FROM transactions;
+DELETE FROM holds;
+DELETE FROM recurring_journals;
+DELETE FROM recurring_journal_runs;
+DELETE FROM pending_journals;
+DELETE FROM approval_rules;
+DELETE FROM posting_templates;
+DELETE FROM idempotency_keys;
+DELETE FROM outbox_events;
+DELETE FROM webhook_subscriptions;
+DELETE FROM webhook_deliveries;
+DELETE FROM ledger_sequences;
+DELETE FROM audit_log;
+DELETE FROM api_keys;
+DELETE FROM rate_limits;
+DELETE FROM tenants;
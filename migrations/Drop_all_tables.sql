@@ -4,3 +4,18 @@ DROP TABLE accounts;
 DROP TABLE currencies;
 DROP TABLE journals;
 DROP TABLE transactions;
+DROP TABLE holds;
+DROP TABLE recurring_journals;
+DROP TABLE recurring_journal_runs;
+DROP TABLE pending_journals;
+DROP TABLE approval_rules;
+DROP TABLE posting_templates;
+DROP TABLE idempotency_keys;
+DROP TABLE outbox_events;
+DROP TABLE webhook_subscriptions;
+DROP TABLE webhook_deliveries;
+DROP TABLE ledger_sequences;
+DROP TABLE audit_log;
+DROP TABLE api_keys;
+DROP TABLE rate_limits;
+DROP TABLE tenants;
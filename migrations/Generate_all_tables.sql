@@ -1,6 +1,7 @@
 use bookkeeping;
 
 CREATE TABLE IF NOT EXISTS accounts (
+  `tenant_id` VARCHAR(16) NOT NULL DEFAULT 'DEFAULT',
   `account_number` VARCHAR(20) NOT NULL,
   `name` VARCHAR(128) NOT NULL,
   `currency_code` VARCHAR(10) NOT NULL,
@@ -8,16 +9,24 @@ CREATE TABLE IF NOT EXISTS accounts (
   `alignment` VARCHAR(6) NOT NULL,
   `balance` INT NOT NULL,
   `coa` VARCHAR(10),
+  `status` VARCHAR(12) NOT NULL DEFAULT 'ACTIVE',
+  `min_balance` BIGINT NULL,
+  `overdraft_limit` BIGINT NULL,
+  `max_balance` BIGINT NULL,
   `created_at` TIMESTAMP,
   `created_by` VARCHAR(16),
   `updated_at` TIMESTAMP,
   `updated_by` VARCHAR(16),
   `is_deleted` TINYINT(1) DEFAULT false ,
-  PRIMARY KEY (`account_number`),
-  INDEX(`coa`, `name`)
+  `external_reference` VARCHAR(64) NULL,
+  PRIMARY KEY (`tenant_id`, `account_number`),
+  INDEX(`coa`, `name`),
+  UNIQUE INDEX(`tenant_id`, `created_by`, `external_reference`),
+  INDEX(`tenant_id`, `coa`)
 );
 
 CREATE TABLE IF NOT EXISTS currencies (
+  `tenant_id` VARCHAR(16) NOT NULL DEFAULT 'DEFAULT',
   `code` VARCHAR(10) NOT NULL,
   `name` VARCHAR(30) NOT NULL,
   `exchange` FLOAT NOT NULL,
@@ -26,10 +35,11 @@ CREATE TABLE IF NOT EXISTS currencies (
   `updated_at` TIMESTAMP,
   `updated_by` VARCHAR(16),
   `is_deleted` TINYINT(1) DEFAULT false ,
-  PRIMARY KEY (`code`)
+  PRIMARY KEY (`tenant_id`, `code`)
 );
 
 CREATE TABLE IF NOT EXISTS journals (
+  `tenant_id` VARCHAR(16) NOT NULL DEFAULT 'DEFAULT',
   `journal_id` VARCHAR(20) NOT NULL,
   `journaling_time` TIMESTAMP NOT NULL,
   `description` TEXT,
@@ -41,10 +51,21 @@ CREATE TABLE IF NOT EXISTS journals (
   `updated_at` TIMESTAMP,
   `updated_by` VARCHAR(16),
   `is_deleted` TINYINT(1) DEFAULT false ,
-  PRIMARY KEY (`journal_id`)
+  `sequence` BIGINT NULL,
+  `metadata` JSON NULL,
+  `external_reference` VARCHAR(64) NULL,
+  PRIMARY KEY (`journal_id`),
+  INDEX(`reversed_journal_id`),
+  UNIQUE INDEX(`tenant_id`, `sequence`),
+  INDEX(`tenant_id`, `journaling_time`),
+  UNIQUE INDEX(`tenant_id`, `created_by`, `external_reference`),
+  INDEX(`tenant_id`, `created_by`, `journaling_time`),
+  INDEX(`tenant_id`, `is_reversal`, `journaling_time`),
+  INDEX(`tenant_id`, `total_amount`)
 );
 
 CREATE TABLE IF NOT EXISTS transactions (
+  `tenant_id` VARCHAR(16) NOT NULL DEFAULT 'DEFAULT',
   `transaction_id` VARCHAR(20) NOT NULL,
   `account_number` VARCHAR(20) NOT NULL,
   `transaction_time` TIMESTAMP NOT NULL,
@@ -58,8 +79,236 @@ CREATE TABLE IF NOT EXISTS transactions (
   `updated_at` TIMESTAMP,
   `updated_by` VARCHAR(16),
   `is_deleted` TINYINT(1) DEFAULT false ,
+  `metadata` JSON NULL,
   PRIMARY KEY (`transaction_id`),
-  INDEX(`account_number`, `journal_id`)
+  INDEX(`account_number`, `journal_id`),
+  INDEX(`tenant_id`, `account_number`, `transaction_time`),
+  INDEX(`tenant_id`, `transaction_time`),
+  INDEX(`tenant_id`, `journal_id`),
+  INDEX(`tenant_id`, `created_by`, `transaction_time`),
+  INDEX(`tenant_id`, `amount`)
 );
 
+CREATE TABLE IF NOT EXISTS holds (
+  `tenant_id` VARCHAR(16) NOT NULL DEFAULT 'DEFAULT',
+  `hold_id` VARCHAR(20) NOT NULL,
+  `account_number` VARCHAR(20) NOT NULL,
+  `description` TEXT,
+  `amount` BIGINT NOT NULL,
+  `status` VARCHAR(10) NOT NULL,
+  `expires_at` TIMESTAMP NOT NULL,
+  `journal_id` VARCHAR(20),
+  `created_at` TIMESTAMP,
+  `created_by` VARCHAR(16),
+  `updated_at` TIMESTAMP,
+  `updated_by` VARCHAR(16),
+  PRIMARY KEY (`hold_id`),
+  INDEX(`account_number`, `status`, `expires_at`),
+  INDEX(`status`, `expires_at`),
+  INDEX(`tenant_id`, `account_number`, `status`, `expires_at`)
+);
+
+CREATE TABLE IF NOT EXISTS recurring_journals (
+  `tenant_id` VARCHAR(16) NOT NULL DEFAULT 'DEFAULT',
+  `schedule_id` VARCHAR(20) NOT NULL,
+  `cron_expression` VARCHAR(64) NOT NULL,
+  `description` TEXT,
+  `transactions` TEXT NOT NULL,
+  `metadata` JSON NULL,
+  `status` VARCHAR(10) NOT NULL,
+  `created_at` TIMESTAMP,
+  `created_by` VARCHAR(16),
+  `updated_at` TIMESTAMP,
+  `updated_by` VARCHAR(16),
+  `is_deleted` TINYINT(1) DEFAULT false ,
+  PRIMARY KEY (`schedule_id`),
+  INDEX(`status`),
+  INDEX(`tenant_id`, `status`)
+);
+
+CREATE TABLE IF NOT EXISTS recurring_journal_runs (
+  `tenant_id` VARCHAR(16) NOT NULL DEFAULT 'DEFAULT',
+  `run_id` VARCHAR(20) NOT NULL,
+  `schedule_id` VARCHAR(20) NOT NULL,
+  `run_at` TIMESTAMP NOT NULL,
+  `fire_time` TIMESTAMP NULL DEFAULT NULL,
+  `success` TINYINT(1) NOT NULL,
+  `journal_id` VARCHAR(20),
+  `error_message` TEXT,
+  PRIMARY KEY (`run_id`),
+  INDEX(`schedule_id`, `run_at`),
+  UNIQUE INDEX(`schedule_id`, `fire_time`)
+);
+
+CREATE TABLE IF NOT EXISTS pending_journals (
+  `tenant_id` VARCHAR(16) NOT NULL DEFAULT 'DEFAULT',
+  `pending_id` VARCHAR(20) NOT NULL,
+  `description` TEXT,
+  `transactions` TEXT NOT NULL,
+  `metadata` JSON NULL,
+  `external_reference` VARCHAR(64) NULL,
+  `amount` BIGINT NOT NULL,
+  `status` VARCHAR(10) NOT NULL,
+  `journal_id` VARCHAR(20),
+  `review_note` TEXT,
+  `submitted_at` TIMESTAMP,
+  `submitted_by` VARCHAR(16),
+  `reviewed_at` TIMESTAMP NULL,
+  `reviewed_by` VARCHAR(16),
+  PRIMARY KEY (`pending_id`),
+  INDEX(`status`, `submitted_at`),
+  INDEX(`tenant_id`, `status`, `submitted_at`)
+);
+
+CREATE TABLE IF NOT EXISTS approval_rules (
+  `tenant_id` VARCHAR(16) NOT NULL DEFAULT 'DEFAULT',
+  `rule_id` VARCHAR(20) NOT NULL,
+  `description` TEXT,
+  `min_amount` BIGINT NULL,
+  `coa` VARCHAR(10),
+  `created_at` TIMESTAMP,
+  `created_by` VARCHAR(16),
+  `is_deleted` TINYINT(1) DEFAULT false ,
+  PRIMARY KEY (`rule_id`)
+);
+
+CREATE TABLE IF NOT EXISTS posting_templates (
+  `tenant_id` VARCHAR(16) NOT NULL DEFAULT 'DEFAULT',
+  `name` VARCHAR(64) NOT NULL,
+  `description` TEXT,
+  `definition` TEXT NOT NULL,
+  `created_at` TIMESTAMP,
+  `created_by` VARCHAR(16),
+  `updated_at` TIMESTAMP,
+  `updated_by` VARCHAR(16),
+  `is_deleted` TINYINT(1) DEFAULT false ,
+  PRIMARY KEY (`tenant_id`, `name`)
+);
+
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+  `tenant_id` VARCHAR(16) NOT NULL DEFAULT 'DEFAULT',
+  `client_id` VARCHAR(16) NOT NULL DEFAULT '',
+  `scope` VARCHAR(16) NOT NULL,
+  `idempotency_key` VARCHAR(64) NOT NULL,
+  `request_digest` CHAR(64) NOT NULL,
+  `response_code` INT,
+  `resource_id` VARCHAR(20) NOT NULL DEFAULT '',
+  `created_at` TIMESTAMP,
+  `created_by` VARCHAR(16),
+  PRIMARY KEY (`tenant_id`, `client_id`, `scope`, `idempotency_key`)
+);
+
+CREATE TABLE IF NOT EXISTS outbox_events (
+  `event_id` BIGINT NOT NULL AUTO_INCREMENT,
+  `tenant_id` VARCHAR(16) NOT NULL DEFAULT 'DEFAULT',
+  `event_type` VARCHAR(32) NOT NULL,
+  `payload` TEXT NOT NULL,
+  `dispatched` BOOLEAN NOT NULL DEFAULT FALSE,
+  `created_at` TIMESTAMP,
+  `created_by` VARCHAR(16),
+  PRIMARY KEY (`event_id`),
+  INDEX (`dispatched`, `event_id`)
+);
+
+CREATE TABLE IF NOT EXISTS webhook_subscriptions (
+  `tenant_id` VARCHAR(16) NOT NULL DEFAULT 'DEFAULT',
+  `subscription_id` VARCHAR(20) NOT NULL,
+  `url` VARCHAR(512) NOT NULL,
+  `event_types` VARCHAR(255) NOT NULL,
+  `secret` VARCHAR(64) NOT NULL,
+  `description` VARCHAR(255),
+  `created_at` TIMESTAMP,
+  `created_by` VARCHAR(16),
+  PRIMARY KEY (`subscription_id`),
+  INDEX (`tenant_id`)
+);
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+  `delivery_id` BIGINT NOT NULL AUTO_INCREMENT,
+  `tenant_id` VARCHAR(16) NOT NULL DEFAULT 'DEFAULT',
+  `event_id` BIGINT NOT NULL,
+  `subscription_id` VARCHAR(20) NOT NULL,
+  `status` VARCHAR(16) NOT NULL,
+  `attempts` INT NOT NULL DEFAULT 0,
+  `next_attempt_at` TIMESTAMP NULL,
+  `last_response_code` INT NOT NULL DEFAULT 0,
+  `last_error` VARCHAR(255) NOT NULL DEFAULT '',
+  `created_at` TIMESTAMP NULL,
+  `updated_at` TIMESTAMP NULL,
+  `delivered_at` TIMESTAMP NULL,
+  PRIMARY KEY (`delivery_id`),
+  UNIQUE (`event_id`, `subscription_id`),
+  INDEX (`status`, `next_attempt_at`),
+  INDEX (`subscription_id`, `status`)
+);
+
+CREATE TABLE IF NOT EXISTS ledger_sequences (
+  `tenant_id` VARCHAR(16) NOT NULL DEFAULT 'DEFAULT',
+  `name` VARCHAR(32) NOT NULL,
+  `value` BIGINT NOT NULL,
+  PRIMARY KEY (`tenant_id`, `name`)
+);
+
+CREATE TABLE IF NOT EXISTS audit_log (
+  `audit_id` BIGINT NOT NULL AUTO_INCREMENT,
+  `tenant_id` VARCHAR(16) NOT NULL DEFAULT 'DEFAULT',
+  `principal` VARCHAR(64) NOT NULL,
+  `request_id` VARCHAR(64) NOT NULL,
+  `method` VARCHAR(8) NOT NULL,
+  `endpoint` VARCHAR(255) NOT NULL,
+  `path` VARCHAR(512) NOT NULL,
+  `payload_digest` CHAR(64) NOT NULL,
+  `before_snapshot` MEDIUMTEXT,
+  `after_snapshot` MEDIUMTEXT,
+  `status_code` INT NOT NULL,
+  `outcome` VARCHAR(8) NOT NULL,
+  `created_at` TIMESTAMP NULL,
+  PRIMARY KEY (`audit_id`),
+  INDEX (`created_at`),
+  INDEX (`principal`, `created_at`),
+  INDEX (`endpoint`, `created_at`),
+  INDEX (`request_id`),
+  INDEX (`tenant_id`, `created_at`)
+);
+
+CREATE TABLE IF NOT EXISTS api_keys (
+  `key_id` VARCHAR(20) NOT NULL,
+  `tenant_id` VARCHAR(16) NOT NULL DEFAULT 'DEFAULT',
+  `client_id` VARCHAR(16) NOT NULL,
+  `key_hash` CHAR(64) NOT NULL,
+  `signing_key` VARCHAR(128),
+  `scopes` VARCHAR(255) NOT NULL,
+  `description` VARCHAR(255),
+  `expires_at` TIMESTAMP NULL,
+  `revoked` BOOLEAN NOT NULL DEFAULT FALSE,
+  `revoked_at` TIMESTAMP NULL,
+  `replaced_by` VARCHAR(20),
+  `created_at` TIMESTAMP,
+  `created_by` VARCHAR(16),
+  PRIMARY KEY (`key_id`),
+  INDEX (`client_id`),
+  INDEX (`tenant_id`, `client_id`)
+);
+
+CREATE TABLE IF NOT EXISTS rate_limits (
+  `limit_key` VARCHAR(128) NOT NULL,
+  `window_start` DATETIME NOT NULL,
+  `window_end` DATETIME NOT NULL,
+  `request_count` INT NOT NULL DEFAULT 0,
+  PRIMARY KEY (`limit_key`, `window_start`),
+  INDEX (`window_end`)
+);
+
+CREATE TABLE IF NOT EXISTS tenants (
+  `tenant_id` VARCHAR(16) NOT NULL,
+  `name` VARCHAR(128) NOT NULL,
+  `description` TEXT,
+  `created_at` TIMESTAMP,
+  `created_by` VARCHAR(16),
+  `updated_at` TIMESTAMP,
+  `updated_by` VARCHAR(16),
+  PRIMARY KEY (`tenant_id`)
+);
 
+INSERT IGNORE INTO tenants(`tenant_id`, `name`, `description`, `created_at`, `created_by`, `updated_at`, `updated_by`)
+  VALUES('DEFAULT', 'Default', 'the books of the requests without a tenant', NOW(), 'SYSTEM', NOW(), 'SYSTEM');
@@ -0,0 +1,3 @@
+use bookkeeping;
+
+ALTER TABLE accounts ADD COLUMN `status` VARCHAR(12) NOT NULL DEFAULT 'ACTIVE' AFTER `coa`;
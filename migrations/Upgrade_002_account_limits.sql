@@ -0,0 +1,6 @@
+use bookkeeping;
+
+ALTER TABLE accounts
+  ADD COLUMN `min_balance` BIGINT NULL AFTER `status`,
+  ADD COLUMN `overdraft_limit` BIGINT NULL AFTER `min_balance`,
+  ADD COLUMN `max_balance` BIGINT NULL AFTER `overdraft_limit`;
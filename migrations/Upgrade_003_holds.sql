@@ -0,0 +1,18 @@
+use bookkeeping;
+
+CREATE TABLE IF NOT EXISTS holds (
+  `hold_id` VARCHAR(20) NOT NULL,
+  `account_number` VARCHAR(20) NOT NULL,
+  `description` TEXT,
+  `amount` BIGINT NOT NULL,
+  `status` VARCHAR(10) NOT NULL,
+  `expires_at` TIMESTAMP NOT NULL,
+  `journal_id` VARCHAR(20),
+  `created_at` TIMESTAMP,
+  `created_by` VARCHAR(16),
+  `updated_at` TIMESTAMP,
+  `updated_by` VARCHAR(16),
+  PRIMARY KEY (`hold_id`),
+  INDEX(`account_number`, `status`, `expires_at`),
+  INDEX(`status`, `expires_at`)
+);
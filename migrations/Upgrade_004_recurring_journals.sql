@@ -0,0 +1,29 @@
+use bookkeeping;
+
+CREATE TABLE IF NOT EXISTS recurring_journals (
+  `schedule_id` VARCHAR(20) NOT NULL,
+  `cron_expression` VARCHAR(64) NOT NULL,
+  `description` TEXT,
+  `transactions` TEXT NOT NULL,
+  `status` VARCHAR(10) NOT NULL,
+  `created_at` TIMESTAMP,
+  `created_by` VARCHAR(16),
+  `updated_at` TIMESTAMP,
+  `updated_by` VARCHAR(16),
+  `is_deleted` TINYINT(1) DEFAULT false ,
+  PRIMARY KEY (`schedule_id`),
+  INDEX(`status`)
+);
+
+CREATE TABLE IF NOT EXISTS recurring_journal_runs (
+  `run_id` VARCHAR(20) NOT NULL,
+  `schedule_id` VARCHAR(20) NOT NULL,
+  `run_at` TIMESTAMP NOT NULL,
+  `fire_time` TIMESTAMP NULL DEFAULT NULL,
+  `success` TINYINT(1) NOT NULL,
+  `journal_id` VARCHAR(20),
+  `error_message` TEXT,
+  PRIMARY KEY (`run_id`),
+  INDEX(`schedule_id`, `run_at`),
+  UNIQUE INDEX(`schedule_id`, `fire_time`)
+);
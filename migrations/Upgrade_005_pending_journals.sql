@@ -0,0 +1,28 @@
+use bookkeeping;
+
+CREATE TABLE IF NOT EXISTS pending_journals (
+  `pending_id` VARCHAR(20) NOT NULL,
+  `description` TEXT,
+  `transactions` TEXT NOT NULL,
+  `amount` BIGINT NOT NULL,
+  `status` VARCHAR(10) NOT NULL,
+  `journal_id` VARCHAR(20),
+  `review_note` TEXT,
+  `submitted_at` TIMESTAMP,
+  `submitted_by` VARCHAR(16),
+  `reviewed_at` TIMESTAMP NULL,
+  `reviewed_by` VARCHAR(16),
+  PRIMARY KEY (`pending_id`),
+  INDEX(`status`, `submitted_at`)
+);
+
+CREATE TABLE IF NOT EXISTS approval_rules (
+  `rule_id` VARCHAR(20) NOT NULL,
+  `description` TEXT,
+  `min_amount` BIGINT NULL,
+  `coa` VARCHAR(10),
+  `created_at` TIMESTAMP,
+  `created_by` VARCHAR(16),
+  `is_deleted` TINYINT(1) DEFAULT false ,
+  PRIMARY KEY (`rule_id`)
+);
@@ -0,0 +1,13 @@
+use bookkeeping;
+
+CREATE TABLE IF NOT EXISTS posting_templates (
+  `name` VARCHAR(64) NOT NULL,
+  `description` TEXT,
+  `definition` TEXT NOT NULL,
+  `created_at` TIMESTAMP,
+  `created_by` VARCHAR(16),
+  `updated_at` TIMESTAMP,
+  `updated_by` VARCHAR(16),
+  `is_deleted` TINYINT(1) DEFAULT false ,
+  PRIMARY KEY (`name`)
+);
@@ -0,0 +1,13 @@
+use bookkeeping;
+
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+  `client_id` VARCHAR(16) NOT NULL DEFAULT '',
+  `scope` VARCHAR(16) NOT NULL,
+  `idempotency_key` VARCHAR(64) NOT NULL,
+  `request_digest` CHAR(64) NOT NULL,
+  `response_code` INT,
+  `resource_id` VARCHAR(20) NOT NULL DEFAULT '',
+  `created_at` TIMESTAMP,
+  `created_by` VARCHAR(16),
+  PRIMARY KEY (`client_id`, `scope`, `idempotency_key`)
+);
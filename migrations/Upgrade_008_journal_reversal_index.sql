@@ -0,0 +1,4 @@
+use bookkeeping;
+
+ALTER TABLE journals
+  ADD INDEX (`reversed_journal_id`);
@@ -0,0 +1,41 @@
+use bookkeeping;
+
+CREATE TABLE IF NOT EXISTS outbox_events (
+  `event_id` BIGINT NOT NULL AUTO_INCREMENT,
+  `event_type` VARCHAR(32) NOT NULL,
+  `payload` TEXT NOT NULL,
+  `dispatched` BOOLEAN NOT NULL DEFAULT FALSE,
+  `created_at` TIMESTAMP,
+  `created_by` VARCHAR(16),
+  PRIMARY KEY (`event_id`),
+  INDEX (`dispatched`, `event_id`)
+);
+
+CREATE TABLE IF NOT EXISTS webhook_subscriptions (
+  `subscription_id` VARCHAR(20) NOT NULL,
+  `url` VARCHAR(512) NOT NULL,
+  `event_types` VARCHAR(255) NOT NULL,
+  `secret` VARCHAR(64) NOT NULL,
+  `description` VARCHAR(255),
+  `created_at` TIMESTAMP,
+  `created_by` VARCHAR(16),
+  PRIMARY KEY (`subscription_id`)
+);
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+  `delivery_id` BIGINT NOT NULL AUTO_INCREMENT,
+  `event_id` BIGINT NOT NULL,
+  `subscription_id` VARCHAR(20) NOT NULL,
+  `status` VARCHAR(16) NOT NULL,
+  `attempts` INT NOT NULL DEFAULT 0,
+  `next_attempt_at` TIMESTAMP NULL,
+  `last_response_code` INT NOT NULL DEFAULT 0,
+  `last_error` VARCHAR(255) NOT NULL DEFAULT '',
+  `created_at` TIMESTAMP NULL,
+  `updated_at` TIMESTAMP NULL,
+  `delivered_at` TIMESTAMP NULL,
+  PRIMARY KEY (`delivery_id`),
+  UNIQUE (`event_id`, `subscription_id`),
+  INDEX (`status`, `next_attempt_at`),
+  INDEX (`subscription_id`, `status`)
+);
@@ -0,0 +1,17 @@
+use bookkeeping;
+
+CREATE TABLE IF NOT EXISTS ledger_sequences (
+  `name` VARCHAR(32) NOT NULL,
+  `value` BIGINT NOT NULL,
+  PRIMARY KEY (`name`)
+);
+
+ALTER TABLE journals
+  ADD COLUMN `sequence` BIGINT NULL,
+  ADD UNIQUE INDEX (`sequence`);
+
+-- number the existing journals in the order they were created
+SET @seq := 0;
+UPDATE journals SET `sequence` = (@seq := @seq + 1) ORDER BY created_at ASC, journal_id ASC;
+INSERT INTO ledger_sequences(`name`, `value`) SELECT 'journal', COALESCE(MAX(`sequence`), 0) FROM journals
+  ON DUPLICATE KEY UPDATE `value` = VALUES(`value`);
@@ -0,0 +1,21 @@
+use bookkeeping;
+
+CREATE TABLE IF NOT EXISTS audit_log (
+  `audit_id` BIGINT NOT NULL AUTO_INCREMENT,
+  `principal` VARCHAR(64) NOT NULL,
+  `request_id` VARCHAR(64) NOT NULL,
+  `method` VARCHAR(8) NOT NULL,
+  `endpoint` VARCHAR(255) NOT NULL,
+  `path` VARCHAR(512) NOT NULL,
+  `payload_digest` CHAR(64) NOT NULL,
+  `before_snapshot` MEDIUMTEXT,
+  `after_snapshot` MEDIUMTEXT,
+  `status_code` INT NOT NULL,
+  `outcome` VARCHAR(8) NOT NULL,
+  `created_at` TIMESTAMP NULL,
+  PRIMARY KEY (`audit_id`),
+  INDEX (`created_at`),
+  INDEX (`principal`, `created_at`),
+  INDEX (`endpoint`, `created_at`),
+  INDEX (`request_id`)
+);
@@ -0,0 +1,17 @@
+use bookkeeping;
+
+CREATE TABLE IF NOT EXISTS api_keys (
+  `key_id` VARCHAR(20) NOT NULL,
+  `client_id` VARCHAR(16) NOT NULL,
+  `key_hash` CHAR(64) NOT NULL,
+  `scopes` VARCHAR(255) NOT NULL,
+  `description` VARCHAR(255),
+  `expires_at` TIMESTAMP NULL,
+  `revoked` BOOLEAN NOT NULL DEFAULT FALSE,
+  `revoked_at` TIMESTAMP NULL,
+  `replaced_by` VARCHAR(20),
+  `created_at` TIMESTAMP,
+  `created_by` VARCHAR(16),
+  PRIMARY KEY (`key_id`),
+  INDEX (`client_id`)
+);
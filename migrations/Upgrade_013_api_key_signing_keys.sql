@@ -0,0 +1,6 @@
+use bookkeeping;
+
+-- the key an api key signs its requests with is derived from its secret apart from key_hash, and stored sealed
+-- by auth.apikey.seal.secret. The keys issued before have none, they authenticate but must be rotated to sign requests.
+ALTER TABLE api_keys
+  ADD COLUMN `signing_key` VARCHAR(128) NULL DEFAULT NULL AFTER `key_hash`;
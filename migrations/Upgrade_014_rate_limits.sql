@@ -0,0 +1,10 @@
+use bookkeeping;
+
+CREATE TABLE IF NOT EXISTS rate_limits (
+  `limit_key` VARCHAR(80) NOT NULL,
+  `window_start` DATETIME NOT NULL,
+  `window_end` DATETIME NOT NULL,
+  `request_count` INT NOT NULL DEFAULT 0,
+  PRIMARY KEY (`limit_key`, `window_start`),
+  INDEX (`window_end`)
+);
@@ -0,0 +1,91 @@
+use bookkeeping;
+
+CREATE TABLE IF NOT EXISTS tenants (
+  `tenant_id` VARCHAR(16) NOT NULL,
+  `name` VARCHAR(128) NOT NULL,
+  `description` TEXT,
+  `created_at` TIMESTAMP,
+  `created_by` VARCHAR(16),
+  `updated_at` TIMESTAMP,
+  `updated_by` VARCHAR(16),
+  PRIMARY KEY (`tenant_id`)
+);
+
+-- the existing books become the books of the DEFAULT tenant
+INSERT IGNORE INTO tenants(`tenant_id`, `name`, `description`, `created_at`, `created_by`, `updated_at`, `updated_by`)
+  VALUES('DEFAULT', 'Default', 'the books of the requests without a tenant', NOW(), 'SYSTEM', NOW(), 'SYSTEM');
+
+ALTER TABLE accounts
+  ADD COLUMN `tenant_id` VARCHAR(16) NOT NULL DEFAULT 'DEFAULT' FIRST,
+  DROP PRIMARY KEY,
+  ADD PRIMARY KEY (`tenant_id`, `account_number`);
+
+ALTER TABLE currencies
+  ADD COLUMN `tenant_id` VARCHAR(16) NOT NULL DEFAULT 'DEFAULT' FIRST,
+  DROP PRIMARY KEY,
+  ADD PRIMARY KEY (`tenant_id`, `code`);
+
+ALTER TABLE journals
+  ADD COLUMN `tenant_id` VARCHAR(16) NOT NULL DEFAULT 'DEFAULT' FIRST,
+  DROP INDEX `sequence`,
+  ADD UNIQUE INDEX (`tenant_id`, `sequence`),
+  ADD INDEX (`tenant_id`, `journaling_time`);
+
+ALTER TABLE transactions
+  ADD COLUMN `tenant_id` VARCHAR(16) NOT NULL DEFAULT 'DEFAULT' FIRST,
+  ADD INDEX (`tenant_id`, `account_number`, `transaction_time`);
+
+ALTER TABLE holds
+  ADD COLUMN `tenant_id` VARCHAR(16) NOT NULL DEFAULT 'DEFAULT' FIRST,
+  ADD INDEX (`tenant_id`, `account_number`, `status`, `expires_at`);
+
+ALTER TABLE recurring_journals
+  ADD COLUMN `tenant_id` VARCHAR(16) NOT NULL DEFAULT 'DEFAULT' FIRST,
+  ADD INDEX (`tenant_id`, `status`);
+
+ALTER TABLE recurring_journal_runs
+  ADD COLUMN `tenant_id` VARCHAR(16) NOT NULL DEFAULT 'DEFAULT' FIRST;
+
+ALTER TABLE pending_journals
+  ADD COLUMN `tenant_id` VARCHAR(16) NOT NULL DEFAULT 'DEFAULT' FIRST,
+  ADD INDEX (`tenant_id`, `status`, `submitted_at`);
+
+ALTER TABLE approval_rules
+  ADD COLUMN `tenant_id` VARCHAR(16) NOT NULL DEFAULT 'DEFAULT' FIRST;
+
+ALTER TABLE posting_templates
+  ADD COLUMN `tenant_id` VARCHAR(16) NOT NULL DEFAULT 'DEFAULT' FIRST,
+  DROP PRIMARY KEY,
+  ADD PRIMARY KEY (`tenant_id`, `name`);
+
+ALTER TABLE idempotency_keys
+  ADD COLUMN `tenant_id` VARCHAR(16) NOT NULL DEFAULT 'DEFAULT' FIRST,
+  DROP PRIMARY KEY,
+  ADD PRIMARY KEY (`tenant_id`, `client_id`, `scope`, `idempotency_key`);
+
+ALTER TABLE outbox_events
+  ADD COLUMN `tenant_id` VARCHAR(16) NOT NULL DEFAULT 'DEFAULT' AFTER `event_id`;
+
+ALTER TABLE webhook_subscriptions
+  ADD COLUMN `tenant_id` VARCHAR(16) NOT NULL DEFAULT 'DEFAULT' FIRST,
+  ADD INDEX (`tenant_id`);
+
+ALTER TABLE webhook_deliveries
+  ADD COLUMN `tenant_id` VARCHAR(16) NOT NULL DEFAULT 'DEFAULT' AFTER `delivery_id`;
+
+ALTER TABLE ledger_sequences
+  ADD COLUMN `tenant_id` VARCHAR(16) NOT NULL DEFAULT 'DEFAULT' FIRST,
+  DROP PRIMARY KEY,
+  ADD PRIMARY KEY (`tenant_id`, `name`);
+
+ALTER TABLE audit_log
+  ADD COLUMN `tenant_id` VARCHAR(16) NOT NULL DEFAULT 'DEFAULT' AFTER `audit_id`,
+  ADD INDEX (`tenant_id`, `created_at`);
+
+ALTER TABLE api_keys
+  ADD COLUMN `tenant_id` VARCHAR(16) NOT NULL DEFAULT 'DEFAULT' AFTER `key_id`,
+  ADD INDEX (`tenant_id`, `client_id`);
+
+-- the rate limit keys carry the tenant of the client
+ALTER TABLE rate_limits
+  MODIFY `limit_key` VARCHAR(128) NOT NULL;
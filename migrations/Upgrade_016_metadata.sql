@@ -0,0 +1,15 @@
+use bookkeeping;
+
+-- the metadata of the journals and transactions, a JSON object of string values
+ALTER TABLE journals
+  ADD COLUMN `metadata` JSON NULL;
+
+ALTER TABLE transactions
+  ADD COLUMN `metadata` JSON NULL;
+
+-- the metadata of the journals waiting to be posted
+ALTER TABLE pending_journals
+  ADD COLUMN `metadata` JSON NULL AFTER `transactions`;
+
+ALTER TABLE recurring_journals
+  ADD COLUMN `metadata` JSON NULL AFTER `transactions`;
@@ -0,0 +1,14 @@
+use bookkeeping;
+
+-- the reference the clients know their journals and accounts by, unique per creator in each tenant
+ALTER TABLE journals
+  ADD COLUMN `external_reference` VARCHAR(64) NULL,
+  ADD UNIQUE INDEX(`tenant_id`, `created_by`, `external_reference`);
+
+ALTER TABLE accounts
+  ADD COLUMN `external_reference` VARCHAR(64) NULL,
+  ADD UNIQUE INDEX(`tenant_id`, `created_by`, `external_reference`);
+
+-- the reference of the journals waiting to be posted
+ALTER TABLE pending_journals
+  ADD COLUMN `external_reference` VARCHAR(64) NULL AFTER `metadata`;
@@ -0,0 +1,18 @@
+use bookkeeping;
+
+-- the transaction search filters and sorts
+ALTER TABLE transactions
+  ADD INDEX(`tenant_id`, `transaction_time`),
+  ADD INDEX(`tenant_id`, `journal_id`),
+  ADD INDEX(`tenant_id`, `created_by`, `transaction_time`),
+  ADD INDEX(`tenant_id`, `amount`);
+
+-- the journal search filters and sorts
+ALTER TABLE journals
+  ADD INDEX(`tenant_id`, `created_by`, `journaling_time`),
+  ADD INDEX(`tenant_id`, `is_reversal`, `journaling_time`),
+  ADD INDEX(`tenant_id`, `total_amount`);
+
+-- the COA filter of both searches
+ALTER TABLE accounts
+  ADD INDEX(`tenant_id`, `coa`);
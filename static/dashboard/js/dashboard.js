@@ -26,46 +26,45 @@ function LoadCurrencyExchange() {
     $('#thecontent').load('currency-exchange.html');
 }
 
-function CurrentRFC3339Date() {
-    let currentdate = new Date();
+// the session token obtained by Login, the api key itself is not kept
+let sessionToken = "";
+let sessionExpiresAt = 0;
 
-    let ye = new Intl.DateTimeFormat('en', { year: 'numeric' }).format(currentdate);
-    let mo = new Intl.DateTimeFormat('en', { month: '2-digit' }).format(currentdate);
-    if (mo.length !== 2) {
-        mo = "0" + mo;
-    }
-    let da = new Intl.DateTimeFormat('en', { day: '2-digit' }).format(currentdate);
-    if (da.length !== 2) {
-        da = "0" + da;
-    }
-    let hr = new Intl.DateTimeFormat('en', { hour: '2-digit', hour12: false }).format(currentdate);
-    if (hr.length !== 2) {
-        hr = "0" + hr;
-    }
-    let mi = new Intl.DateTimeFormat('en', { minute: '2-digit' }).format(currentdate);
-    if (mi.length !== 2) {
-        mi = "0" + mi;
-    }
-    let se = new Intl.DateTimeFormat('en', { second: '2-digit' }).format(currentdate);
-    if (se.length !== 2) {
-        se = "0" + se;
-    }
-    return `${ye}-${mo}-${da}T${hr}:${mi}:${se}Z`;
+// Login exchanges the api key typed by the user for a short lived session token
+function Login() {
+    let apiKey = $("#theAPIKey").val().trim();
+    $.ajax({
+        url: "/api/v1/auth/token",
+        type: "POST",
+        headers: { 'Authorization': "ApiKey " + apiKey },
+        contentType: "application/json",
+        data: "{}",
+        success: function (data) {
+            sessionToken = data.data.token;
+            sessionExpiresAt = Date.parse(data.data.expires_at);
+            $("#theAPIKey").val("").attr("placeholder", "Logged in as " + data.data.principal);
+        },
+        error: function (data, errorThrown) {
+            sessionToken = "";
+            window.alert("Login failed, check your api key.");
+        }
+    });
+    return false;
 }
 
-function GenHMAC() {
-    let userSecret = $("#theSecretKey").val();
-    let date = CurrentRFC3339Date();
-    let newHMAC = CryptoJS.HmacSHA256(date, userSecret);
-    let base64encoded = CryptoJS.enc.Base64.stringify(newHMAC);
-    let toBase = `${date}$${base64encoded}`;
-    return btoa(toBase);
-}
+// every api call carries the session token, except the login itself
+$(document).ajaxSend(function (event, jqXHR, settings) {
+    if (settings.url.indexOf("/api/") === 0 && settings.url !== "/api/v1/auth/token") {
+        if (sessionToken === "" || Date.now() >= sessionExpiresAt) {
+            $("#theAPIKey").attr("placeholder", "Session expired, put your api key here");
+        }
+        jqXHR.setRequestHeader("Authorization", "Session " + sessionToken);
+    }
+});
 
 function GetCurrencyList() {
     $.ajax({
         url: '/api/v1/currencies',
-        headers: { 'Authorization': GenHMAC() },
         success: function (data) {
             if (data.status !== "SUCCESS") {
                 $("#currencyListBody").html("<tr><th scope=\"row\">&nbsp;</th><td colspan='3'>NO CURRENCY FOUND</td></tr>");
@@ -86,7 +85,7 @@ function GetCurrencyList() {
                 window.alert("Seems you're input is wrong, check for date formats or mandatory fields.");
             },
             401: function (xhr) {
-                window.alert("Seems your secret key is wrong.");
+                window.alert("Seems your session expired, log in with your api key.");
             }
         }
     });
@@ -101,7 +100,6 @@ function LoadAccountDetail(accNo) {
 
     $.ajax({
         url: "/api/v1/accounts/" + accNo,
-        headers: { 'Authorization': GenHMAC() },
         success: function (data) {
             if (data.status !== "SUCCESS") {
                 console.error("Error : " + data.message)
@@ -124,7 +122,7 @@ function LoadAccountDetail(accNo) {
                 window.alert("Seems you're input is wrong, check for date formats or mandatory fields.");
             },
             401: function (xhr) {
-                window.alert("Seems your secret key is wrong.");
+                window.alert("Seems your session expired, log in with your api key.");
             }
         }
     });
@@ -143,7 +141,6 @@ function LoadAccountTransactions(pageNo, items) {
 
     $.ajax({
         url: "/api/v1/accounts/" + accNo + "/transactions?from="+from+"&until="+until+"&page="+pageNo+"&size=" + items,
-        headers: { 'Authorization': GenHMAC() },
         success: function (data) {
             if (data.status !== "SUCCESS") {
                 console.error("Error : " + data.message)
@@ -182,7 +179,7 @@ function LoadAccountTransactions(pageNo, items) {
                 window.alert("Seems you're input is wrong, check for date formats or mandatory fields.");
             },
             401: function (xhr) {
-                window.alert("Seems your secret key is wrong.");
+                window.alert("Seems your session expired, log in with your api key.");
             }
         }
     });
@@ -196,7 +193,6 @@ function OpenJournal() {
     }
     $.ajax({
         url: "/api/v1/journals/"+journalNo,
-        headers: { 'Authorization': GenHMAC() },
         success: function (data) {
             if (data.status !== "SUCCESS") {
                 $("#findAccountRows").html("<tr><th scope=\"row\">&nbsp;</th><td colspan='6'>NO ACCOUNT WITH THAT CRITERIA IS FOUND</td></tr>");
@@ -256,7 +252,7 @@ function OpenJournal() {
                 window.alert("Seems you're input is wrong, check for date formats or mandatory fields.");
             },
             401: function (xhr) {
-                window.alert("Seems your secret key is wrong.");
+                window.alert("Seems your session expired, log in with your api key.");
             }
         }
     });
@@ -269,7 +265,6 @@ function FindAccount(name, page, items) {
     }
     $.ajax({
         url: "/api/v1/accounts?name="+name+"&page="+page+"&size="+items,
-        headers: { 'Authorization': GenHMAC() },
         success: function (data) {
             if (data.status !== "SUCCESS") {
                 $("#findAccountRows").html("<tr><th scope=\"row\">&nbsp;</th><td colspan='6'>NO ACCOUNT WITH THAT CRITERIA IS FOUND</td></tr>");
@@ -330,7 +325,7 @@ function FindAccount(name, page, items) {
                 window.alert("Seems you're input is wrong, check for date formats or mandatory fields.");
             },
             401: function (xhr) {
-                window.alert("Seems your secret key is wrong.");
+                window.alert("Seems your session expired, log in with your api key.");
             }
         }
     });
@@ -341,7 +336,6 @@ function FindAccount(name, page, items) {
 function PopulateCurrencies() {
     $.ajax({
         url: "/api/v1/currencies",
-        headers: { 'Authorization': GenHMAC() },
         success: function (data) {
             if (data.status !== "SUCCESS") {
                 $("#findAccountRows").html("<tr><th scope=\"row\">&nbsp;</th><td colspan='5'>NO ACCOUNT WITH THAT CRITERIA IS FOUND</td></tr>");
@@ -365,7 +359,7 @@ function PopulateCurrencies() {
                 window.alert("Seems you're input is wrong, check for date formats or mandatory fields.");
             },
             401: function (xhr) {
-                window.alert("Seems your secret key is wrong.");
+                window.alert("Seems your session expired, log in with your api key.");
             }
         }
     });
@@ -395,7 +389,6 @@ function CreateNewCurrency() {
 
     $.ajax({
         url: "/api/v1/currencies/" + code,
-        headers: {'Authorization': GenHMAC() },
         type: "PUT",
         contentType: "application/json",
         data : JSON.stringify({
@@ -418,7 +411,7 @@ function CreateNewCurrency() {
                 window.alert("Seems you're input is wrong, check for date formats or mandatory fields.");
             },
             401: function (xhr) {
-                window.alert("Seems your secret key is wrong.");
+                window.alert("Seems your session expired, log in with your api key.");
             }
         }
     });
@@ -442,7 +435,6 @@ function CreateNewAccount() {
 
     $.ajax({
         url: "/api/v1/accounts",
-        headers: {'Authorization': GenHMAC() },
         type: "POST",
         contentType: "application/json",
         data : JSON.stringify({
@@ -469,7 +461,7 @@ function CreateNewAccount() {
                 window.alert("Seems you're input is wrong, check for date formats or mandatory fields.");
             },
             401: function (xhr) {
-                window.alert("Seems your secret key is wrong.");
+                window.alert("Seems your session expired, log in with your api key.");
             }
         }
     });
@@ -479,7 +471,6 @@ function CreateNewAccount() {
 function PopulateCurrenciesForExchanges() {
     $.ajax({
         url: "/api/v1/currencies",
-        headers: { 'Authorization': GenHMAC() },
         success: function (data) {
             if (data.status !== "SUCCESS") {
                 console.error("error : " + data.message);
@@ -504,7 +495,7 @@ function PopulateCurrenciesForExchanges() {
                 window.alert("Seems you're input is wrong, check for date formats or mandatory fields.");
             },
             401: function (xhr) {
-                window.alert("Seems your secret key is wrong.");
+                window.alert("Seems your session expired, log in with your api key.");
             }
         }
     });
@@ -523,7 +514,6 @@ function CalculateExchange() {
 
     $.ajax({
         url: "/api/v1/exchange/" + source + "/" + target + "/" + samount.trim(),
-        headers: {'Authorization': GenHMAC() },
         type: "GET",
         success: function (data) {
             if (data.status !== "SUCCESS") {
@@ -540,7 +530,7 @@ function CalculateExchange() {
                 window.alert("Seems you're input is wrong, check for date formats or mandatory fields.");
             },
             401: function (xhr) {
-                window.alert("Seems your secret key is wrong.");
+                window.alert("Seems your session expired, log in with your api key.");
             }
         }
     });
@@ -553,7 +543,6 @@ function PopulateAccountList() {
     }
     $.ajax({
         url: "/api/v1/accounts?name="+name+"&page=1&size=10",
-        headers: { 'Authorization': GenHMAC() },
         success: function (data) {
             if (data.status !== "SUCCESS") {
                 console.error(data.message);
@@ -579,7 +568,7 @@ function PopulateAccountList() {
                 window.alert("Seems you're input is wrong, check for date formats or mandatory fields.");
             },
             401: function (xhr) {
-                window.alert("Seems your secret key is wrong.");
+                window.alert("Seems your session expired, log in with your api key.");
             }
         }
     });
@@ -602,7 +591,6 @@ function PostJournal() {
 
     $.ajax({
         url: "/api/v1/journals",
-        headers: {'Authorization': GenHMAC() },
         type: "POST",
         contentType: "application/json",
         data : JSON.stringify({